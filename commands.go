@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/collector"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/costdiff"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/dashboard"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/report"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/rules"
+)
+
+// commands maps subcommand names to their implementations. Each subcommand
+// accepts the same configuration flags as the server.
+var commands = map[string]func(args []string) error{
+	"cost-diff": runCostDiff,
+	"dashboard": runDashboard,
+	"report":    runReport,
+	"rules":     runRules,
+}
+
+// parseCommand parses the args of a subcommand into the configuration
+// flags of the server and the subcommand's own flags, which define
+// registers. Like the server, it reads the settings of --config-dir and
+// --config, so that a subcommand sees the deployed configuration: as the
+// settings are defaults of the flags, the flags are registered and parsed
+// again once they are read.
+func parseCommand(name string, args []string, define func(fs *flag.FlagSet)) (config, error) {
+	var cfg config
+	parse := func() error {
+		cfg = config{}
+		fs := flag.NewFlagSet(name, flag.ExitOnError)
+		registerFlags(fs, &cfg)
+		define(fs)
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		return cfg.settingsError()
+	}
+	if err := parse(); err != nil {
+		return config{}, err
+	}
+	if cfg.configDir == "" && cfg.configFile == "" {
+		return cfg, nil
+	}
+	if err := readConfigFiles(cfg.configDir, cfg.configFile); err != nil {
+		return config{}, fmt.Errorf("load configuration: %w", err)
+	}
+	if err := parse(); err != nil {
+		return config{}, err
+	}
+	return cfg, nil
+}
+
+// runDashboard generates Grafana dashboards for the configured metric schema.
+func runDashboard(args []string) error {
+	var kind, outDir string
+	cfg, err := parseCommand("dashboard", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&kind, "kind", "overview", "Dashboard to print (overview, account, team)")
+		fs.StringVar(&outDir, "out-dir", "", "Write all dashboards to this directory instead of printing one to stdout")
+	})
+	if err != nil {
+		return err
+	}
+
+	collectorOpts, err := cfg.collectorOptions()
+	if err != nil {
+		return err
+	}
+	coll := collector.New(cfg.newClient(), cfg.newCache(), collectorOpts...)
+	opts := dashboard.Options{
+		CostMetric:       coll.CostMetricName(),
+		SelfMetricPrefix: cfg.selfMetricsPrefix,
+		Labels:           coll.CostLabels(),
+		CostTypeMetric:   coll.CostTypeMetricName,
+	}
+
+	if outDir == "" {
+		return writeDashboard(os.Stdout, kind, opts)
+	}
+
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return fmt.Errorf("create output directory: %w", err)
+	}
+	for _, k := range dashboard.Kinds {
+		path := filepath.Join(outDir, "cloudcost-"+k+".json")
+		f, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("create %s: %w", path, err)
+		}
+		err = writeDashboard(f, k, opts)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "wrote", path)
+	}
+	return nil
+}
+
+func writeDashboard(w io.Writer, kind string, opts dashboard.Options) error {
+	d, err := dashboard.Generate(kind, opts)
+	if err != nil {
+		return err
+	}
+	out, err := d.JSON()
+	if err != nil {
+		return fmt.Errorf("encode dashboard: %w", err)
+	}
+	_, err = fmt.Fprintf(w, "%s\n", out)
+	return err
+}
+
+// runRules renders recommended recording and alerting rules for the
+// configured metric schema.
+func runRules(args []string) error {
+	var (
+		format, name                                    string
+		dailySpend, budget, spikePercent, anomalyZScore float64
+	)
+	cfg, err := parseCommand("rules", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&format, "format", "prometheus", "Output format (prometheus, prometheusrule)")
+		fs.StringVar(&name, "name", "opencost-cloudcost-exporter", "PrometheusRule resource name")
+		fs.Float64Var(&dailySpend, "daily-spend-threshold", 0, "Alert when daily spend exceeds this amount in USD (0 disables)")
+		fs.Float64Var(&budget, "budget", 0, "Alert when spend over the window exceeds this amount in USD (0 disables)")
+		fs.Float64Var(&spikePercent, "spike-percent", 20, "Alert on day-over-day increases above this percentage (0 disables)")
+		fs.Float64Var(&anomalyZScore, "anomaly-zscore", 3, "Alert when spend deviates more than this many standard deviations from the 7-day average (0 disables)")
+	})
+	if err != nil {
+		return err
+	}
+
+	collectorOpts, err := cfg.collectorOptions()
+	if err != nil {
+		return err
+	}
+	coll := collector.New(cfg.newClient(), cfg.newCache(), collectorOpts...)
+	rf := rules.Generate(rules.Options{
+		CostMetric:          coll.CostMetricName(),
+		SelfMetricPrefix:    cfg.selfMetricsPrefix,
+		Labels:              coll.CostLabels(),
+		CostTypeMetric:      coll.CostTypeMetricName,
+		StaleAfter:          cfg.cacheTTL + cfg.maxStale,
+		DailySpendThreshold: dailySpend,
+		Budget:              budget,
+		SpikePercent:        spikePercent,
+		AnomalyZScore:       anomalyZScore,
+	})
+
+	out, err := rules.Render(rf, format, name)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(out)
+	return err
+}
+
+// runCostDiff fetches two windows from OpenCost and prints per-group cost
+// deltas sorted by the size of the change.
+func runCostDiff(args []string) error {
+	var (
+		windowA, windowB, costType, groupBy string
+		top                                 int
+	)
+	cfg, err := parseCommand("cost-diff", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&windowA, "window-a", "7d", "Current window")
+		fs.StringVar(&windowB, "window-b", "7d offset 7d", "Window to compare against (supports \"<window> offset <duration>\")")
+		fs.StringVar(&costType, "cost-type", "amortized_net", "Cost type to compare (list, net, amortized_net, invoiced, amortized)")
+		fs.StringVar(&groupBy, "group-by", "account_id,service", "Comma-separated dimensions to group by (provider, account_id, service, category, region)")
+		fs.IntVar(&top, "top", 0, "Only print the N largest changes (0 prints all)")
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	cl := cfg.newClient()
+	a, err := cl.FetchCloudCostsWindow(ctx, windowA)
+	if err != nil {
+		return fmt.Errorf("fetch window %q: %w", windowA, err)
+	}
+	b, err := cl.FetchCloudCostsWindow(ctx, windowB)
+	if err != nil {
+		return fmt.Errorf("fetch window %q: %w", windowB, err)
+	}
+
+	dims := splitList(groupBy)
+	rows, err := costdiff.Compute(a, b, costdiff.Options{CostType: costType, GroupBy: dims})
+	if err != nil {
+		return err
+	}
+	if top > 0 && len(rows) > top {
+		rows = rows[:top]
+	}
+	return costdiff.Write(os.Stdout, dims, rows)
+}
+
+// runReport renders the monthly cost report for a month and prints it, or
+// delivers it to the configured destinations with --send.
+func runReport(args []string) error {
+	var (
+		month string
+		send  bool
+	)
+	cfg, err := parseCommand("report", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&month, "month", time.Now().UTC().AddDate(0, -1, 0).Format("2006-01"), "Month to report on (YYYY-MM)")
+		fs.BoolVar(&send, "send", false, "Deliver the report to the configured webhook/email instead of printing it")
+	})
+	if err != nil {
+		return err
+	}
+
+	m, err := time.Parse("2006-01", month)
+	if err != nil {
+		return fmt.Errorf("invalid month %q: %w", month, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	if send {
+		senders := cfg.reportSenders()
+		if len(senders) == 0 {
+			return fmt.Errorf("no report destination configured (--report-webhook-url or --report-smtp-addr)")
+		}
+		return report.NewScheduler(cfg.newClient(), report.Options{}, cfg.reportFormat, senders...).Send(ctx, m)
+	}
+
+	r, err := report.Generate(ctx, cfg.newClient(), m, report.Options{})
+	if err != nil {
+		return err
+	}
+	out, err := report.Render(r, cfg.reportFormat)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(out)
+	return err
+}
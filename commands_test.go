@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseCommand_Config(t *testing.T) {
+	t.Cleanup(func() { configFiles = nil })
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(file, []byte("client:\n  window: 30d\ncollector:\n  metric-namespace: acme\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	settings := filepath.Join(dir, "settings")
+	if err := os.Mkdir(settings, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(settings, "WINDOW"), []byte("14d"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var kind string
+	cfg, err := parseCommand("dashboard", []string{"--config", file, "--config-dir", settings, "--kind", "team"}, func(fs *flag.FlagSet) {
+		fs.StringVar(&kind, "kind", "overview", "")
+	})
+	if err != nil {
+		t.Fatalf("parseCommand() error = %v", err)
+	}
+	if cfg.metricNamespace != "acme" {
+		t.Errorf("metric namespace = %q, want acme from --config", cfg.metricNamespace)
+	}
+	if cfg.window != "14d" {
+		t.Errorf("window = %q, want 14d from --config-dir", cfg.window)
+	}
+	if kind != "team" {
+		t.Errorf("kind = %q, want team", kind)
+	}
+
+	cfg, err = parseCommand("dashboard", []string{"--config", file, "--metric-namespace", "flag"}, func(*flag.FlagSet) {})
+	if err != nil {
+		t.Fatalf("parseCommand() error = %v", err)
+	}
+	if cfg.metricNamespace != "flag" {
+		t.Errorf("metric namespace = %q, want flag to override --config", cfg.metricNamespace)
+	}
+}
+
+func TestParseCommand_InvalidSetting(t *testing.T) {
+	t.Setenv("RETRY_JITTER", "0,2")
+	if _, err := parseCommand("rules", nil, func(*flag.FlagSet) {}); err == nil {
+		t.Error("parseCommand() should fail on an invalid RETRY_JITTER")
+	}
+}
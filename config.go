@@ -0,0 +1,1075 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/alert"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/cache"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/client"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/collector"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/configwatch"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/costapi"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/cron"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/currency"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/downsample"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/export"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/federation"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/kube"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/leader"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/otlpmetrics"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/probe"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/report"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/requestid"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/secret"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/selfmetrics"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/servertls"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/upstream"
+)
+
+// config holds the exporter configuration shared by the server and all
+// subcommands, so that e.g. generated dashboards match the running exporter.
+type config struct {
+	opencostURL            string
+	sidecar                bool
+	opencostTokenFile      string
+	opencostAPIKeyHeader   string
+	opencostUsername       string
+	opencostHeaders        string
+	apiFlavor              string
+	federationSources      string
+	port                   string
+	tlsCert                string
+	tlsKey                 string
+	tlsClientCA            string
+	adminTokenFile         string
+	window                 string
+	windowFallbacks        string
+	windowShard            string
+	timezone               string
+	aggregate              string
+	cacheTTL               time.Duration
+	maxStale               time.Duration
+	cacheFile              string
+	refreshSchedule        string
+	maxRetries             int
+	retryInitialBackoff    time.Duration
+	retryMaxBackoff        time.Duration
+	retryBackoffMultiplier float64
+	retryJitter            float64
+	emitKubePercentMetrics bool
+	emitInvoiceEntity      bool
+	emitAccount            bool
+	emitHourlyRate         bool
+	emitCumulative         bool
+	accumulate             bool
+	maxSeries              int
+	topNServices           int
+	emitAllocation         bool
+	emitAssets             bool
+	restatementThreshold   float64
+	downsample             string
+	downsampleMinWindow    string
+	memoryThreshold        float64
+	currencySymbols        string
+	exchangeRateProvider   string
+	exchangeRateURL        string
+	exchangeRateFile       string
+	convertCurrencies      string
+	proxyCloudCost         bool
+	demo                   bool
+	proxyOpenCostMetrics   bool
+	probe                  bool
+	probeAllowedTargets    string
+	costAPI                bool
+	openCostMetricsURL     string
+	openCostMetricsAllow   string
+	exportURL              string
+	exportEndpoint         string
+	exportRegion           string
+	grpcPort               string
+	alertRules             string
+	alertWebhookURL        string
+	alertMessageTemplate   string
+	budgets                string
+	budgetLevels           string
+	opsgenieURL            string
+	slackWebhookURL        string
+	slackRoutes            string
+	teamsWebhookURL        string
+	teamsRoutes            string
+	reportFormat           string
+	reportWebhookURL       string
+	reportSMTPAddr         string
+	reportSMTPUsername     string
+	reportEmailFrom        string
+	reportEmailTo          string
+	tracingEndpoint        string
+	traceSampleRatio       float64
+	otlpMetricsEndpoint    string
+	otlpMetricsProtocol    string
+	otlpMetricsInterval    time.Duration
+	prometheusMetrics      bool
+	logLevel               string
+
+	leaderElection              bool
+	leaderElectionNamespace     string
+	leaderElectionLease         string
+	leaderElectionLeaseDuration time.Duration
+	leaderElectionAddress       string
+
+	shardIndex int
+	shardCount int
+	shardKey   string
+
+	configDir       string
+	configFile      string
+	operatorConfig  string
+	labelMappings   string
+	providerLabels  string
+	resourceLabels  string
+	cloudProvider   bool
+	resourceID      bool
+	ownershipFile   string
+	negativeCosts   string
+	metricNaming    string
+	metricNamespace string
+	missingFields   string
+	costPrecision   int
+	filterService   string
+	filterAccount   string
+	filterCategory  string
+	filterLabels    string
+
+	clusterName          string
+	clusterNameNodeLabel string
+
+	selfMetricsPrefix   string
+	selfMetricsDisabled bool
+	selfMetricsInstance string
+
+	legacyMetricNames bool
+
+	// invalid holds the errors of settings that failed to parse, see
+	// envValue.
+	invalid []error
+
+	// requestMetrics records the requests of the clients of newClientFor
+	// if set, as the server does.
+	requestMetrics *client.RequestMetrics
+}
+
+// registerFlags binds cfg to fs. Environment variables provide the defaults.
+func registerFlags(fs *flag.FlagSet, cfg *config) {
+	fs.StringVar(&cfg.opencostURL, "opencost-url", getEnv("OPENCOST_URL", defaultOpenCostURL), "OpenCost service URL (defaults to "+sidecarOpenCostURL+" with --sidecar)")
+	fs.BoolVar(&cfg.sidecar, "sidecar", getEnv("SIDECAR", "false") == "true", "Run as a sidecar in the OpenCost pod: query OpenCost on localhost with short timeouts and report ready only once it has cost data")
+	fs.StringVar(&cfg.opencostTokenFile, "opencost-token-file", getEnv("OPENCOST_TOKEN_FILE", ""), "Authenticate to OpenCost with the bearer token in this file, e.g. a projected service account token (re-read on every request)")
+	fs.StringVar(&cfg.opencostAPIKeyHeader, "opencost-api-key-header", getEnv("OPENCOST_API_KEY_HEADER", client.DefaultAPIKeyHeader), "Header the API key in OPENCOST_API_KEY or OPENCOST_API_KEY_FILE is sent to OpenCost in")
+	fs.StringVar(&cfg.opencostUsername, "opencost-username", getEnv("OPENCOST_USERNAME", ""), "Authenticate to OpenCost with basic auth as this user (password is read from OPENCOST_PASSWORD, re-read on every request)")
+	fs.StringVar(&cfg.opencostHeaders, "opencost-headers", getEnv("OPENCOST_HEADERS", ""), "Comma-separated Name=value headers to send with every OpenCost request, e.g. X-Scope-OrgID=finance")
+	fs.StringVar(&cfg.apiFlavor, "api-flavor", getEnv("API_FLAVOR", client.OpenCost.Name), "Dialect of the cloud cost API at --opencost-url and the federation sources: opencost, or kubecost for Kubecost's paths and paging")
+	fs.StringVar(&cfg.federationSources, "federation-sources", getEnv("FEDERATION_SOURCES", ""), "Comma-separated name=url[;setting=value...] OpenCost instances to merge instead of --opencost-url, in de-duplication priority order; settings: token-file, api-key-file, ca-file, cert-file, key-file, server-name, insecure-skip-verify, timeout, cluster")
+	fs.StringVar(&cfg.port, "port", getEnv("PORT", "9100"), "Metrics server port")
+	fs.StringVar(&cfg.tlsCert, "tls-cert", getEnv("TLS_CERT", ""), "PEM certificate file to serve HTTPS and gRPC with, reloaded on SIGHUP (empty for plaintext)")
+	fs.StringVar(&cfg.tlsKey, "tls-key", getEnv("TLS_KEY", ""), "PEM key file of --tls-cert")
+	fs.StringVar(&cfg.tlsClientCA, "tls-client-ca", getEnv("TLS_CLIENT_CA", ""), "PEM bundle of the CAs client certificates must be signed by (empty to not require client certificates)")
+	fs.StringVar(&cfg.adminTokenFile, "admin-token-file", getEnv("ADMIN_TOKEN_FILE", ""), "File of the bearer token authenticating requests to /-/cache/flush, /-/cache/refresh and /-/reload, re-read on every request (empty to disable the endpoints)")
+	fs.StringVar(&cfg.window, "window", getEnv("WINDOW", "2d"), "Time window for cost queries")
+	fs.StringVar(&cfg.windowFallbacks, "window-fallbacks", getEnv("WINDOW_FALLBACKS", ""), "Comma-separated larger windows to fetch in turn while the window holds no cost items, e.g. \"3d,7d\" for new accounts whose billing data lags")
+	fs.StringVar(&cfg.windowShard, "window-shard", getEnv("WINDOW_SHARD", ""), "Split windows longer than this into shards of it, e.g. \"7d\", fetched concurrently and merged, for OpenCost instances that time out on long windows (empty to fetch every window at once)")
+	fs.StringVar(&cfg.timezone, "timezone", getEnv("TIMEZONE", "UTC"), "Time zone calendar windows such as month are aligned in, e.g. Europe/Berlin")
+	fs.StringVar(&cfg.aggregate, "aggregate", getEnv("AGGREGATE", ""), "Comma-separated properties OpenCost aggregates cost items by, e.g. accountID,service,category (empty for one item per resource)")
+	fs.DurationVar(&cfg.cacheTTL, "cache-ttl", envValue(cfg, "CACHE_TTL", "1h", time.ParseDuration), "Cache TTL")
+	fs.DurationVar(&cfg.maxStale, "max-stale", envValue(cfg, "MAX_STALE", "6h", time.ParseDuration), "Maximum age for stale data")
+	fs.StringVar(&cfg.cacheFile, "cache-file", getEnv("CACHE_FILE", ""), "File the cached cost data is saved to and restored from at startup, within the max stale age (empty to keep it in memory only)")
+	fs.StringVar(&cfg.refreshSchedule, "refresh-schedule", getEnv("REFRESH_SCHEDULE", ""), "Cron expression, evaluated in --timezone, at which to refresh the cache instead of when a scrape finds it older than --cache-ttl, e.g. \"0 */6 * * *\"")
+	fs.IntVar(&cfg.maxRetries, "max-retries", envValue(cfg, "MAX_RETRIES", "3", strconv.Atoi), "Maximum number of retries of a failed OpenCost request")
+	fs.DurationVar(&cfg.retryInitialBackoff, "retry-initial-backoff", envValue(cfg, "RETRY_INITIAL_BACKOFF", "1s", time.ParseDuration), "Wait before the first retry of a failed OpenCost request")
+	fs.DurationVar(&cfg.retryMaxBackoff, "retry-max-backoff", envValue(cfg, "RETRY_MAX_BACKOFF", "30s", time.ParseDuration), "Maximum wait between retries of a failed OpenCost request")
+	fs.Float64Var(&cfg.retryBackoffMultiplier, "retry-backoff-multiplier", envValue(cfg, "RETRY_BACKOFF_MULTIPLIER", "2", parseFloat), "Factor the wait grows by with every retry of a failed OpenCost request")
+	fs.Float64Var(&cfg.retryJitter, "retry-jitter", envValue(cfg, "RETRY_JITTER", "0.2", parseFloat), "Fraction each retry wait is randomized by in either direction, so that exporters do not retry in lockstep (0 disables)")
+	fs.BoolVar(&cfg.emitKubePercentMetrics, "emit-kube-percent-metrics", getEnv("EMIT_KUBE_PERCENT_METRICS", "false") == "true", "Emit kubernetes percent metric")
+	fs.BoolVar(&cfg.emitInvoiceEntity, "emit-invoice-entity-metrics", getEnv("EMIT_INVOICE_ENTITY_METRICS", "false") == "true", "Emit cost rolled up by invoice entity (payer account)")
+	fs.BoolVar(&cfg.emitAccount, "emit-account-metrics", getEnv("EMIT_ACCOUNT_METRICS", "false") == "true", "Emit cost rolled up by account across services")
+	fs.BoolVar(&cfg.emitHourlyRate, "emit-hourly-rate-metrics", getEnv("EMIT_HOURLY_RATE_METRICS", "false") == "true", "Emit the hourly cost rate of the latest complete day when OpenCost returns daily sets")
+	fs.BoolVar(&cfg.emitCumulative, "emit-cumulative-metrics", getEnv("EMIT_CUMULATIVE_METRICS", "false") == "true", "Emit a counter of the spend observed since startup, for use with increase()")
+	fs.BoolVar(&cfg.accumulate, "accumulate", getEnv("ACCUMULATE", "true") == "true", "Sum the cost sets of the window into one series; false emits every set, e.g. each day, as series with a window_start label")
+	fs.IntVar(&cfg.maxSeries, "max-series", envValue(cfg, "MAX_SERIES", "0", strconv.Atoi), "Maximum number of cost metric series, beyond which the cheapest are dropped (0 disables the limit)")
+	fs.IntVar(&cfg.topNServices, "top-n-services", envValue(cfg, "TOP_N_SERVICES", "0", strconv.Atoi), "Number of most expensive services kept in the service label, the others folded into \"__other__\" (0 keeps all)")
+	fs.BoolVar(&cfg.emitAllocation, "emit-allocation-metrics", getEnv("EMIT_ALLOCATION_METRICS", "false") == "true", "Emit kube_allocation_cost_total, the cost of Kubernetes workloads by namespace, controller and pod from OpenCost's allocation API")
+	fs.BoolVar(&cfg.emitAssets, "emit-asset-metrics", getEnv("EMIT_ASSET_METRICS", "false") == "true", "Emit kube_asset_cost_total, the cost of cluster assets such as nodes, disks and load balancers from OpenCost's assets API")
+	fs.StringVar(&cfg.downsample, "downsample", getEnv("DOWNSAMPLE", ""), "Roll the daily cost sets of long windows up to week or month, merging items without their provider ID and availability zone, before they are exposed or exported")
+	fs.StringVar(&cfg.downsampleMinWindow, "downsample-min-window", getEnv("DOWNSAMPLE_MIN_WINDOW", "30d"), "Shortest span of cost sets that --downsample rolls up")
+	fs.Float64Var(&cfg.restatementThreshold, "restatement-threshold", envValue(cfg, "RESTATEMENT_THRESHOLD", "0", parseFloat), "Relative change of an ended window's cost totals between fetches reported as a restatement, e.g. 0.01 for 1% (0 disables)")
+	fs.StringVar(&cfg.currencySymbols, "currency-symbols", getEnv("CURRENCY_SYMBOLS", "CNY,EUR"), "Comma-separated ISO 4217 codes of the target currencies for exchange rates")
+	fs.StringVar(&cfg.exchangeRateProvider, "exchange-rate-provider", getEnv("EXCHANGE_RATE_PROVIDER", client.ProviderFrankfurter), "Source of exchange rates: "+strings.Join(client.ExchangeRateProviders, ", ")+" (exchangerate.host reads its access key from EXCHANGERATE_HOST_ACCESS_KEY)")
+	fs.StringVar(&cfg.exchangeRateURL, "exchange-rate-url", getEnv("EXCHANGE_RATE_URL", ""), "Endpoint of the exchange rate provider, e.g. a mirror for air-gapped clusters (defaults to the provider's public API)")
+	fs.StringVar(&cfg.exchangeRateFile, "exchange-rate-file", getEnv("EXCHANGE_RATE_FILE", ""), "JSON file of exchange rates in the Frankfurter format for --exchange-rate-provider=file, re-read on every fetch")
+	fs.StringVar(&cfg.convertCurrencies, "convert-currencies", getEnv("CONVERT_CURRENCIES", ""), "Comma-separated ISO 4217 codes of the currencies the cost metric is converted into, adding a currency label")
+	fs.BoolVar(&cfg.demo, "demo", getEnv("DEMO", "false") == "true", "Serve synthetic cost data instead of querying OpenCost")
+	fs.BoolVar(&cfg.proxyCloudCost, "proxy-cloudcost", getEnv("PROXY_CLOUDCOST", "false") == "true", "Serve OpenCost's /cloudCost API through the cache")
+	fs.BoolVar(&cfg.probe, "probe", getEnv("PROBE", "false") == "true", "Serve the cost metrics of any OpenCost instance at /probe?target=<url>[&window=<window>], fetched on demand without the OpenCost credentials")
+	fs.StringVar(&cfg.probeAllowedTargets, "probe-allowed-targets", getEnv("PROBE_ALLOWED_TARGETS", ""), "Regular expression the URLs of probe targets must fully match (empty allows any)")
+	fs.BoolVar(&cfg.costAPI, "cost-api", getEnv("COST_API", "false") == "true", "Serve the cached cost data as JSON at "+costapi.Path+", summed by the properties of the group_by parameter, and as a CSV table by account, service and category at "+costapi.CSVPath)
+	fs.BoolVar(&cfg.proxyOpenCostMetrics, "proxy-opencost-metrics", getEnv("PROXY_OPENCOST_METRICS", "false") == "true", "Re-expose allowlisted OpenCost self-metrics")
+	fs.StringVar(&cfg.openCostMetricsURL, "opencost-metrics-url", getEnv("OPENCOST_METRICS_URL", ""), "OpenCost metrics endpoint (defaults to <opencost-url>/metrics)")
+	fs.StringVar(&cfg.openCostMetricsAllow, "opencost-metrics-allowlist", getEnv("OPENCOST_METRICS_ALLOWLIST", strings.Join(upstream.DefaultAllowlist, ",")), "Comma-separated regular expressions of OpenCost metric names to re-expose")
+	fs.StringVar(&cfg.exportURL, "export-url", getEnv("EXPORT_URL", ""), "Object storage destination for Parquet exports (s3://bucket/prefix or gs://bucket/prefix)")
+	fs.StringVar(&cfg.exportEndpoint, "export-endpoint", getEnv("EXPORT_ENDPOINT", ""), "Custom object storage endpoint (e.g. MinIO)")
+	fs.StringVar(&cfg.exportRegion, "export-region", getEnv("EXPORT_REGION", ""), "AWS region for S3 exports (defaults to AWS_REGION)")
+	fs.StringVar(&cfg.grpcPort, "grpc-port", getEnv("GRPC_PORT", ""), "gRPC API port (empty to disable)")
+	fs.StringVar(&cfg.alertRules, "alert-rules", getEnv("ALERT_RULES", ""), "Semicolon-separated cost threshold rules evaluated after each refresh")
+	fs.StringVar(&cfg.alertWebhookURL, "alert-webhook-url", getEnv("ALERT_WEBHOOK_URL", ""), "POST threshold alerts as JSON to this URL")
+	fs.StringVar(&cfg.alertMessageTemplate, "alert-message-template", getEnv("ALERT_MESSAGE_TEMPLATE", ""), "Go template file for Slack/Teams alert messages")
+	fs.StringVar(&cfg.budgets, "budgets", getEnv("BUDGETS", ""), "Semicolon-separated budgets paged via PagerDuty/Opsgenie when levels are crossed")
+	fs.StringVar(&cfg.budgetLevels, "budget-levels", getEnv("BUDGET_LEVELS", "80=warning,100=error,120=critical"), "Budget consumption levels (percent=severity)")
+	fs.StringVar(&cfg.opsgenieURL, "opsgenie-url", getEnv("OPSGENIE_URL", alert.DefaultOpsgenieURL), "Opsgenie API URL (API key is read from OPSGENIE_API_KEY)")
+	fs.StringVar(&cfg.slackWebhookURL, "slack-webhook-url", getEnv("SLACK_WEBHOOK_URL", ""), "Slack incoming webhook receiving all threshold alerts")
+	fs.StringVar(&cfg.slackRoutes, "slack-routes", getEnv("SLACK_ROUTES", ""), "Per-rule Slack webhooks (rule[,rule]=url;...)")
+	fs.StringVar(&cfg.teamsWebhookURL, "teams-webhook-url", getEnv("TEAMS_WEBHOOK_URL", ""), "Microsoft Teams workflow webhook receiving all threshold alerts")
+	fs.StringVar(&cfg.teamsRoutes, "teams-routes", getEnv("TEAMS_ROUTES", ""), "Per-rule Microsoft Teams webhooks (rule[,rule]=url;...)")
+	fs.StringVar(&cfg.reportFormat, "report-format", getEnv("REPORT_FORMAT", "html"), "Monthly report format (markdown, html)")
+	fs.StringVar(&cfg.reportWebhookURL, "report-webhook-url", getEnv("REPORT_WEBHOOK_URL", ""), "POST the monthly report to this URL")
+	fs.StringVar(&cfg.reportSMTPAddr, "report-smtp-addr", getEnv("REPORT_SMTP_ADDR", ""), "SMTP server (host:port) for emailing the monthly report")
+	fs.StringVar(&cfg.reportSMTPUsername, "report-smtp-username", getEnv("REPORT_SMTP_USERNAME", ""), "SMTP username (password is read from REPORT_SMTP_PASSWORD)")
+	fs.StringVar(&cfg.reportEmailFrom, "report-email-from", getEnv("REPORT_EMAIL_FROM", ""), "Sender address of the monthly report email")
+	fs.StringVar(&cfg.reportEmailTo, "report-email-to", getEnv("REPORT_EMAIL_TO", ""), "Comma-separated recipients of the monthly report email")
+	fs.StringVar(&cfg.tracingEndpoint, "tracing-endpoint", getEnv("TRACING_ENDPOINT", ""), "OTLP/HTTP endpoint for traces, e.g. http://otel-collector:4318 (also honours OTEL_EXPORTER_OTLP_ENDPOINT)")
+	fs.Float64Var(&cfg.traceSampleRatio, "trace-sample-ratio", envValue(cfg, "TRACE_SAMPLE_RATIO", "1", parseFloat), "Fraction of traces to sample")
+	fs.StringVar(&cfg.otlpMetricsEndpoint, "otlp-metrics-endpoint", getEnv("OTLP_METRICS_ENDPOINT", ""), "OTLP endpoint the metrics are pushed to, e.g. http://otel-collector:4318 (also honours OTEL_EXPORTER_OTLP_METRICS_ENDPOINT; empty to not push)")
+	fs.StringVar(&cfg.otlpMetricsProtocol, "otlp-metrics-protocol", getEnv("OTLP_METRICS_PROTOCOL", otlpmetrics.ProtocolHTTP), "Transport of --otlp-metrics-endpoint ("+otlpmetrics.ProtocolGRPC+", "+otlpmetrics.ProtocolHTTP+")")
+	fs.DurationVar(&cfg.otlpMetricsInterval, "otlp-metrics-interval", envValue(cfg, "OTLP_METRICS_INTERVAL", "1m", time.ParseDuration), "Interval the metrics are pushed to --otlp-metrics-endpoint at")
+	fs.BoolVar(&cfg.prometheusMetrics, "prometheus-metrics", getEnv("PROMETHEUS_METRICS", "true") == "true", "Serve the metrics for scraping on /metrics; false with --otlp-metrics-endpoint pushes them only")
+	fs.BoolVar(&cfg.leaderElection, "leader-election", getEnv("LEADER_ELECTION", "false") == "true", "Elect a leader via a Kubernetes Lease; only the leader queries OpenCost")
+	fs.StringVar(&cfg.leaderElectionNamespace, "leader-election-namespace", getEnv("LEADER_ELECTION_NAMESPACE", ""), "Namespace of the leader election Lease (defaults to the pod's namespace)")
+	fs.StringVar(&cfg.leaderElectionLease, "leader-election-lease", getEnv("LEADER_ELECTION_LEASE", "opencost-cloudcost-exporter"), "Name of the leader election Lease")
+	fs.DurationVar(&cfg.leaderElectionLeaseDuration, "leader-election-lease-duration", envValue(cfg, "LEADER_ELECTION_LEASE_DURATION", "15s", time.ParseDuration), "How long leadership is held without renewal")
+	fs.StringVar(&cfg.leaderElectionAddress, "leader-election-address", getEnv("LEADER_ELECTION_ADDRESS", ""), "URL other replicas use to reach this one (defaults to http://$POD_IP:<port>)")
+	fs.IntVar(&cfg.shardIndex, "shard-index", envValue(cfg, "SHARD_INDEX", "0", strconv.Atoi), "Index of this replica's shard (0-based)")
+	fs.IntVar(&cfg.shardCount, "shard-count", envValue(cfg, "SHARD_COUNT", "1", strconv.Atoi), "Number of shards cost items are split across (1 disables sharding)")
+	fs.StringVar(&cfg.shardKey, "shard-key", getEnv("SHARD_KEY", "account"), "Property cost items are sharded by (account, service)")
+	fs.StringVar(&cfg.configFile, "config", getEnv("CONFIG_FILE", ""), "YAML file of settings in client, cache, collector, server, alerts, export and report sections, overridden by flags and environment variables")
+	fs.StringVar(&cfg.configDir, "config-dir", getEnv("CONFIG_DIR", ""), "Directory of settings named after their environment variables (e.g. a mounted ConfigMap), watched for changes")
+	fs.StringVar(&cfg.operatorConfig, "operator-config", getEnv("OPERATOR_CONFIG", ""), "CloudCostExporterConfig resource ([namespace/]name) reconciled into the runtime configuration")
+	fs.StringVar(&cfg.labelMappings, "label-mappings", getEnv("LABEL_MAPPINGS", ""), "OpenCost labels the owner, environment and cluster labels are read from (label=opencost_label,...)")
+	fs.BoolVar(&cfg.cloudProvider, "cloud-provider-label", getEnv("CLOUD_PROVIDER_LABEL", "false") == "true", "Add a provider label (aws, gcp, azure) to the cost metrics, telling the costs of mixed-provider responses apart")
+	fs.BoolVar(&cfg.resourceID, "resource-id-labels", getEnv("RESOURCE_ID_LABELS", "false") == "true", "Add resource_type and resource_name labels, parsed from the provider ID (AWS ARNs and IDs, GCP resource names, Azure resource IDs), to the cost metrics")
+	fs.StringVar(&cfg.ownershipFile, "ownership-file", getEnv("OWNERSHIP_FILE", ""), "YAML or CSV file mapping account IDs to the team and cost center added as team and cost_center labels, watched for changes")
+	fs.StringVar(&cfg.providerLabels, "provider-labels", getEnv("PROVIDER_LABELS", ""), "Comma-separated providers whose specific labels are added to the cost metric (azure, gcp)")
+	fs.StringVar(&cfg.resourceLabels, "labels", getEnv("LABELS", ""), "Comma-separated OpenCost labels added to the cost metric, their names sanitized (e.g. project,tag:cost-unit)")
+	fs.StringVar(&cfg.negativeCosts, "negative-costs", getEnv("NEGATIVE_COSTS", "passthrough"), "How negative costs such as credits are reported (passthrough, clamp, split)")
+	fs.StringVar(&cfg.metricNamespace, "metric-namespace", getEnv("METRIC_NAMESPACE", collector.DefaultNamespace), "Namespace of the cost metric names, e.g. acme for acme_cost_total")
+	fs.StringVar(&cfg.metricNaming, "metric-naming", getEnv("METRIC_NAMING", "label"), "How the cost metrics tell cost types apart: label (a cost_type label) or per-cost-type (a metric per cost type, e.g. aws_cloud_cost_amortized_net_usd)")
+	fs.StringVar(&cfg.missingFields, "missing-fields", getEnv("MISSING_FIELDS", "keep"), "How cost items without an account ID or service are handled (keep, unallocated, drop)")
+	fs.IntVar(&cfg.costPrecision, "cost-precision", envValue(cfg, "COST_PRECISION", "-1", strconv.Atoi), "Decimal places cost values are rounded to after aggregation (-1 disables rounding)")
+	fs.StringVar(&cfg.filterService, "filter-service", getEnv("FILTER_SERVICE", ""), "Regex of the services emitted as metrics, or with a ! prefix of those left out (e.g. !AWSSupport.*)")
+	fs.StringVar(&cfg.filterAccount, "filter-account", getEnv("FILTER_ACCOUNT", ""), "Regex of the account IDs emitted as metrics, or with a ! prefix of those left out")
+	fs.StringVar(&cfg.filterCategory, "filter-category", getEnv("FILTER_CATEGORY", ""), "Regex of the categories emitted as metrics, or with a ! prefix of those left out")
+	fs.StringVar(&cfg.filterLabels, "filter-labels", getEnv("FILTER_LABELS", ""), "Comma-separated OpenCost label matchers the emitted cost items must match (e.g. team=~platform|data,env!=dev)")
+	fs.StringVar(&cfg.clusterName, "cluster-name", getEnv("CLUSTER_NAME", ""), "Cluster label of cost items without one, and a constant label on all other metrics")
+	fs.StringVar(&cfg.clusterNameNodeLabel, "cluster-name-node-label", getEnv("CLUSTER_NAME_NODE_LABEL", "alpha.eksctl.io/cluster-name"), "Node label the cluster name is read from when --cluster-name is unset and NODE_NAME is set")
+	fs.StringVar(&cfg.selfMetricsPrefix, "self-metrics-prefix", getEnv("SELF_METRICS_PREFIX", selfmetrics.Prefix), "Prefix the exporter's own metrics are served with")
+	fs.BoolVar(&cfg.selfMetricsDisabled, "disable-self-metrics", getEnv("DISABLE_SELF_METRICS", "false") == "true", "Leave the exporter's own metrics out of /metrics")
+	fs.StringVar(&cfg.selfMetricsInstance, "self-metrics-instance", getEnv("SELF_METRICS_INSTANCE", ""), "Value of an exporter_instance label added to the exporter's own metrics, e.g. the pod name")
+	fs.BoolVar(&cfg.legacyMetricNames, "legacy-metric-names", getEnv("LEGACY_METRIC_NAMES", "true") == "true", "Also serve renamed metrics under their previous names, and metrics to be renamed under their upcoming names, while dashboards and alerts migrate")
+	fs.Float64Var(&cfg.memoryThreshold, "memory-pressure-threshold", envValue(cfg, "MEMORY_PRESSURE_THRESHOLD", "0.9", parseFloat), "Fraction of GOMEMLIMIT above which the exporter sheds memory (0 disables)")
+	fs.StringVar(&cfg.logLevel, "log-level", getEnv("LOG_LEVEL", "info"), "Log level (debug, info, warn, error)")
+
+	// Keep secrets from the environment out of -help.
+	for _, name := range secretFlags {
+		fs.Lookup(name).DefValue = ""
+	}
+}
+
+// secretFlags are the flags whose values are credentials, such as webhook
+// URLs with embedded tokens. They should be set through NAME_FILE rather
+// than on the command line, where any user can read them.
+var secretFlags = []string{
+	"alert-webhook-url", "slack-webhook-url", "slack-routes",
+	"teams-webhook-url", "teams-routes", "report-webhook-url", "opencost-headers",
+}
+
+// The OpenCost URLs used unless --opencost-url is set.
+const (
+	defaultOpenCostURL = "http://opencost.opencost:9003"
+	sidecarOpenCostURL = "http://localhost:9003"
+)
+
+// openCostURL returns the URL of OpenCost, which is on localhost with
+// --sidecar unless --opencost-url is set.
+func (cfg *config) openCostURL() string {
+	if cfg.sidecar && cfg.opencostURL == defaultOpenCostURL {
+		return sidecarOpenCostURL
+	}
+	return cfg.opencostURL
+}
+
+// newClient creates the OpenCost client from the configuration.
+func (cfg *config) newClient() *client.Client {
+	return cfg.newClientFor(cfg.openCostURL())
+}
+
+// newClientFor creates a client for the OpenCost instance at url. The
+// extra options override the configured ones.
+func (cfg *config) newClientFor(url string, extra ...client.Option) *client.Client {
+	opts := append(cfg.clientOptions(), cfg.credentialOptions()...)
+	return client.New(url, append(opts, extra...)...)
+}
+
+// newAnonymousClientFor creates a client for url like newClientFor, but
+// without the configured credentials and headers, for targets that are not
+// trusted with them.
+func (cfg *config) newAnonymousClientFor(url string, extra ...client.Option) *client.Client {
+	return client.New(url, append(cfg.clientOptions(), extra...)...)
+}
+
+// clientOptions returns the configured options of the OpenCost clients,
+// except their credentials and headers.
+func (cfg *config) clientOptions() []client.Option {
+	timeout := 30 * time.Second
+	if cfg.sidecar {
+		// OpenCost on localhost answers quickly or not at all.
+		timeout = 10 * time.Second
+	}
+	loc, err := time.LoadLocation(cfg.timezone)
+	if err != nil {
+		slog.Warn("invalid time zone, aligning calendar windows in UTC", "timezone", cfg.timezone, "error", err)
+		loc = time.UTC
+	}
+	retry, err := cfg.retryPolicy()
+	if err != nil {
+		slog.Warn("invalid retry policy, using the default", "error", err)
+		retry = client.DefaultRetryPolicy
+	}
+	flavor, err := cfg.flavor()
+	if err != nil {
+		slog.Warn("invalid API flavor, using opencost", "error", err)
+		flavor = client.OpenCost
+	}
+	shard, err := cfg.windowShardSize()
+	if err != nil {
+		slog.Warn("invalid window shard, fetching windows at once", "error", err)
+	}
+	opts := []client.Option{
+		client.WithWindow(cfg.window),
+		client.WithLocation(loc),
+		client.WithAggregate(cfg.aggregate),
+		client.WithTimeout(timeout),
+		client.WithStartupFailFast(cfg.sidecar),
+		client.WithRetryPolicy(retry),
+		client.WithFlavor(flavor),
+		client.WithWindowShard(shard),
+	}
+	rates, err := cfg.exchangeRates()
+	if err != nil {
+		slog.Warn("invalid exchange rate provider, using frankfurter", "error", err)
+		rates = client.Frankfurter{}
+	}
+	opts = append(opts, client.WithExchangeRateProvider(rates))
+	if cfg.requestMetrics != nil {
+		opts = append(opts, client.WithRequestMetrics(cfg.requestMetrics))
+	}
+	return opts
+}
+
+// credentialOptions returns the options authenticating the OpenCost
+// clients: the bearer token, API key, basic auth and --opencost-headers.
+func (cfg *config) credentialOptions() []client.Option {
+	var opts []client.Option
+	if cfg.opencostTokenFile != "" {
+		opts = append(opts, client.WithBearerToken(kube.TokenFile(cfg.opencostTokenFile)))
+	}
+	if key := secret.FromEnv("OPENCOST_API_KEY"); key != nil {
+		opts = append(opts, client.WithAPIKey(cfg.opencostAPIKeyHeader, key.Get))
+	}
+	if cfg.opencostUsername != "" {
+		opts = append(opts, client.WithBasicAuth(cfg.opencostUsername, secret.FromEnv("OPENCOST_PASSWORD").Get))
+	}
+	headers, err := cfg.openCostHeaders()
+	if err != nil {
+		slog.Warn("invalid OpenCost headers, sending none", "error", err)
+	}
+	return append(opts, client.WithHeaders(headers))
+}
+
+// newFederation creates the federation of --federation-sources. Fetches
+// follow the window of cl.
+func (cfg *config) newFederation(cl *client.Client) (*federation.Federation, error) {
+	endpoints, err := federation.ParseEndpoints(cfg.federationSources)
+	if err != nil {
+		return nil, err
+	}
+	sources := make([]federation.Source, len(endpoints))
+	for i, e := range endpoints {
+		var opts []client.Option
+		if e.TokenFile != "" {
+			opts = append(opts, client.WithBearerToken(kube.TokenFile(e.TokenFile)))
+		}
+		if e.APIKeyFile != "" {
+			opts = append(opts, client.WithAPIKey(cfg.opencostAPIKeyHeader, secret.File(e.APIKeyFile).Get))
+		}
+		if !e.TLS.IsZero() {
+			tlsConfig, err := e.TLS.Config()
+			if err != nil {
+				return nil, fmt.Errorf("federation source %q: %w", e.Name, err)
+			}
+			opts = append(opts, client.WithTLSConfig(tlsConfig))
+		}
+		if e.Timeout > 0 {
+			opts = append(opts, client.WithTimeout(e.Timeout))
+		}
+		sources[i] = federation.Source{Name: e.Name, Client: cfg.newClientFor(e.URL, opts...)}
+	}
+	return federation.New(cl.Window, sources...), nil
+}
+
+// newCache creates the response cache from the configuration.
+func (cfg *config) newCache() *cache.Cache {
+	var opts []cache.Option
+	if cfg.cacheFile != "" {
+		opts = append(opts, cache.WithFile(cfg.cacheFile))
+	}
+	return cache.New(cfg.cacheTTL, cfg.maxStale, opts...)
+}
+
+// newUpstreamCollector creates the collector re-exposing OpenCost's own
+// metrics.
+func (cfg *config) newUpstreamCollector() (*upstream.Collector, error) {
+	u := cfg.openCostMetricsURL
+	if u == "" {
+		var err error
+		if u, err = url.JoinPath(cfg.openCostURL(), "/metrics"); err != nil {
+			return nil, fmt.Errorf("invalid OpenCost URL: %w", err)
+		}
+	}
+	var opts []upstream.Option
+	if cfg.opencostTokenFile != "" {
+		opts = append(opts, upstream.WithBearerToken(kube.TokenFile(cfg.opencostTokenFile)))
+	}
+	return upstream.New(u, splitList(cfg.openCostMetricsAllow), opts...)
+}
+
+// openCostHeaders returns the validated headers of --opencost-headers.
+func (cfg *config) openCostHeaders() (http.Header, error) {
+	return client.ParseHeaders(cfg.opencostHeaders)
+}
+
+// exchangeRates returns the exchange rate provider of
+// --exchange-rate-provider.
+func (cfg *config) exchangeRates() (client.ExchangeRateProvider, error) {
+	opts := client.ExchangeRateOptions{URL: cfg.exchangeRateURL, File: cfg.exchangeRateFile}
+	if key := secret.FromEnv("EXCHANGERATE_HOST_ACCESS_KEY"); key != nil {
+		opts.AccessKey = key.Get
+	}
+	return client.NewExchangeRateProvider(cfg.exchangeRateProvider, opts)
+}
+
+// settingsError returns the errors of the environment variables and the
+// settings of --config-dir and --config that failed to parse, if any.
+func (cfg *config) settingsError() error {
+	return errors.Join(cfg.invalid...)
+}
+
+// retryPolicy returns the validated retry policy of OpenCost requests.
+func (cfg *config) retryPolicy() (client.RetryPolicy, error) {
+	p := client.RetryPolicy{
+		MaxRetries:     cfg.maxRetries,
+		InitialBackoff: cfg.retryInitialBackoff,
+		MaxBackoff:     cfg.retryMaxBackoff,
+		Multiplier:     cfg.retryBackoffMultiplier,
+		Jitter:         cfg.retryJitter,
+	}
+	if err := p.Validate(); err != nil {
+		return client.RetryPolicy{}, err
+	}
+	return p, nil
+}
+
+// downsampler returns the downsampling of --downsample. The hourly rate and
+// the cumulative cost need the daily sets it rolls up.
+func (cfg *config) downsampler() (downsample.Downsampler, error) {
+	period, err := downsample.ParsePeriod(cfg.downsample)
+	if err != nil || period == "" {
+		return downsample.Downsampler{}, err
+	}
+	if cfg.emitHourlyRate || cfg.emitCumulative {
+		return downsample.Downsampler{}, errors.New("downsampling cannot be combined with the hourly rate or cumulative cost metrics, which need daily cost sets")
+	}
+	minSpan, err := client.WindowDuration(cfg.downsampleMinWindow)
+	if err != nil {
+		return downsample.Downsampler{}, fmt.Errorf("invalid downsampling min window: %w", err)
+	}
+	loc, err := time.LoadLocation(cfg.timezone)
+	if err != nil {
+		return downsample.Downsampler{}, err
+	}
+	return downsample.Downsampler{Period: period, MinSpan: minSpan, Location: loc}, nil
+}
+
+// fallbackWindows parses --window-fallbacks: relative windows, each larger
+// than the one before it and than --window if that is relative as well.
+func (cfg *config) fallbackWindows() ([]string, error) {
+	windows := splitList(cfg.windowFallbacks)
+	prev, _ := client.WindowDuration(cfg.window)
+	for _, w := range windows {
+		d, err := client.WindowDuration(w)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fallback window %q: %w", w, err)
+		}
+		if d <= prev {
+			return nil, fmt.Errorf("fallback window %s is not larger than the window before it", w)
+		}
+		prev = d
+	}
+	return windows, nil
+}
+
+// windowShardSize parses --window-shard. It returns 0 if unset.
+func (cfg *config) windowShardSize() (time.Duration, error) {
+	if cfg.windowShard == "" {
+		return 0, nil
+	}
+	d, err := client.WindowDuration(cfg.windowShard)
+	if err != nil {
+		return 0, fmt.Errorf("invalid window shard: %w", err)
+	}
+	return d, nil
+}
+
+// flavor returns the dialect of the OpenCost API, parsed from --api-flavor.
+func (cfg *config) flavor() (client.Flavor, error) {
+	return client.ParseFlavor(cfg.apiFlavor)
+}
+
+// newRefreshSchedule parses --refresh-schedule. It returns nil if unset.
+func (cfg *config) newRefreshSchedule() (*cron.Schedule, error) {
+	if cfg.refreshSchedule == "" {
+		return nil, nil
+	}
+	loc, err := time.LoadLocation(cfg.timezone)
+	if err != nil {
+		return nil, err
+	}
+	return cron.Parse(cfg.refreshSchedule, loc)
+}
+
+// collectorOptions returns the collector options derived from the configuration.
+func (cfg *config) collectorOptions() ([]collector.Option, error) {
+	providers, err := collector.ParseProviderLabels(cfg.providerLabels)
+	if err != nil {
+		return nil, err
+	}
+	aggregate, err := types.ParseAggregate(cfg.aggregate)
+	if err != nil {
+		return nil, err
+	}
+	resourceLabels, err := collector.ParseResourceLabels(cfg.resourceLabels)
+	if err != nil {
+		return nil, err
+	}
+	var ownership collector.Ownership
+	if cfg.ownershipFile != "" {
+		if ownership, err = collector.LoadOwnership(cfg.ownershipFile); err != nil {
+			return nil, err
+		}
+	}
+	negativeCosts, err := collector.ParseNegativeCostPolicy(cfg.negativeCosts)
+	if err != nil {
+		return nil, err
+	}
+	missingFields, err := collector.ParseMissingFieldPolicy(cfg.missingFields)
+	if err != nil {
+		return nil, err
+	}
+	metricNaming, err := collector.ParseMetricNaming(cfg.metricNaming)
+	if err != nil {
+		return nil, err
+	}
+	if err := collector.ValidateNamespace(cfg.metricNamespace); err != nil {
+		return nil, err
+	}
+	symbols, err := cfg.symbols()
+	if err != nil {
+		return nil, err
+	}
+	conversions, err := currency.Parse(splitList(cfg.convertCurrencies))
+	if err != nil {
+		return nil, fmt.Errorf("invalid currencies to convert into: %w", err)
+	}
+	var convert []string
+	for _, c := range conversions {
+		if c.Code != "USD" {
+			convert = append(convert, c.Code)
+		}
+	}
+	if cfg.costPrecision > collector.MaxCostPrecision {
+		return nil, fmt.Errorf("cost precision %d exceeds %d decimal places", cfg.costPrecision, collector.MaxCostPrecision)
+	}
+	downsampler, err := cfg.downsampler()
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.accumulate && cfg.emitCumulative {
+		return nil, errors.New("the cumulative cost metric cannot be combined with --accumulate=false")
+	}
+	filter, err := cfg.filter()
+	if err != nil {
+		return nil, err
+	}
+	var sourceClusters map[string]string
+	if cfg.federationSources != "" {
+		endpoints, err := federation.ParseEndpoints(cfg.federationSources)
+		if err != nil {
+			return nil, fmt.Errorf("invalid federation sources: %w", err)
+		}
+		sourceClusters = federation.Clusters(endpoints)
+	}
+	return []collector.Option{
+		collector.WithKubePercentMetrics(cfg.emitKubePercentMetrics),
+		collector.WithInvoiceEntityMetrics(cfg.emitInvoiceEntity),
+		collector.WithAccountMetrics(cfg.emitAccount),
+		collector.WithHourlyRateMetrics(cfg.emitHourlyRate),
+		collector.WithCumulativeMetrics(cfg.emitCumulative),
+		collector.WithAccumulate(cfg.accumulate),
+		collector.WithMaxSeries(cfg.maxSeries),
+		collector.WithTopServices(cfg.topNServices),
+		collector.WithRestatementThreshold(cfg.restatementThreshold),
+		collector.WithDownsampling(downsampler),
+		collector.WithCurrencySymbols(symbols),
+		collector.WithCurrencyConversion(convert),
+		collector.WithClusterName(cfg.clusterName),
+		collector.WithSourceClusters(sourceClusters),
+		collector.WithSourceLabel(cfg.federationSources != ""),
+		collector.WithCloudProviderLabel(cfg.cloudProvider),
+		collector.WithResourceIDLabels(cfg.resourceID),
+		collector.WithProviderLabels(providers),
+		collector.WithResourceLabels(resourceLabels),
+		collector.WithOwnership(ownership),
+		collector.WithNegativeCostPolicy(negativeCosts),
+		collector.WithMissingFieldPolicy(missingFields),
+		collector.WithMetricNaming(metricNaming),
+		collector.WithNamespace(cfg.metricNamespace),
+		collector.WithAggregate(aggregate),
+		collector.WithCostPrecision(cfg.costPrecision),
+		collector.WithFilter(filter),
+	}, nil
+}
+
+// symbols returns the ISO 4217 codes of the currency symbols.
+func (cfg *config) symbols() ([]string, error) {
+	currencies, err := currency.Parse(splitList(cfg.currencySymbols))
+	if err != nil {
+		return nil, fmt.Errorf("invalid currency symbols: %w", err)
+	}
+	symbols := make([]string, len(currencies))
+	for i, c := range currencies {
+		symbols[i] = c.Code
+	}
+	return symbols, nil
+}
+
+// filter returns the filter of the cost items emitted as metrics.
+func (cfg *config) filter() (collector.Filter, error) {
+	var f collector.Filter
+	var err error
+	if f.Service, err = collector.ParseMatcher(cfg.filterService); err != nil {
+		return f, fmt.Errorf("invalid service filter: %w", err)
+	}
+	if f.Account, err = collector.ParseMatcher(cfg.filterAccount); err != nil {
+		return f, fmt.Errorf("invalid account filter: %w", err)
+	}
+	if f.Category, err = collector.ParseMatcher(cfg.filterCategory); err != nil {
+		return f, fmt.Errorf("invalid category filter: %w", err)
+	}
+	if f.Labels, err = collector.ParseLabelMatchers(cfg.filterLabels); err != nil {
+		return f, err
+	}
+	return f, nil
+}
+
+// probeOptions returns the options of the /probe handler.
+func (cfg *config) probeOptions() ([]probe.Option, error) {
+	var opts []probe.Option
+	if cfg.probeAllowedTargets != "" {
+		re, err := regexp.Compile("^(?:" + cfg.probeAllowedTargets + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed probe targets: %w", err)
+		}
+		opts = append(opts, probe.WithAllowedTargets(re))
+	}
+	return opts, nil
+}
+
+// probeFunc returns the probe of the /probe handler: a collector with opts
+// of the target, queried with the exporter's client settings, whose data is
+// fetched before it is returned. As anyone reaching /probe chooses the
+// target, the OpenCost credentials and headers are not sent to it.
+func (cfg *config) probeFunc(opts []collector.Option) probe.Func {
+	return func(ctx context.Context, target, window string) (prometheus.Collector, error) {
+		var clientOpts []client.Option
+		if window != "" {
+			clientOpts = append(clientOpts, client.WithWindow(window))
+		}
+		// Cached only for the duration of the probe.
+		c := collector.New(cfg.newAnonymousClientFor(target, clientOpts...), cache.New(time.Minute, 0), opts...)
+		if _, ok := c.Data(ctx); !ok {
+			return nil, errors.New("no cost data fetched")
+		}
+		return c, nil
+	}
+}
+
+// serverTLS returns the TLS files of the metrics server.
+func (cfg *config) serverTLS() servertls.Options {
+	return servertls.Options{CertFile: cfg.tlsCert, KeyFile: cfg.tlsKey, ClientCAFile: cfg.tlsClientCA}
+}
+
+// selfMetricsOptions returns the options of the gatherer serving the
+// exporter's own metrics.
+func (cfg *config) selfMetricsOptions() ([]selfmetrics.Option, error) {
+	if err := selfmetrics.ValidatePrefix(cfg.selfMetricsPrefix); err != nil {
+		return nil, err
+	}
+	return []selfmetrics.Option{
+		selfmetrics.WithPrefix(cfg.selfMetricsPrefix),
+		selfmetrics.WithDisabled(cfg.selfMetricsDisabled),
+		selfmetrics.WithInstance(cfg.selfMetricsInstance),
+	}, nil
+}
+
+// resolveClusterName fills in an unset --cluster-name from the
+// --cluster-name-node-label label of the node the pod runs on, which is
+// named by NODE_NAME from the downward API. Failures are logged, and the
+// metrics then carry no cluster identity.
+func (cfg *config) resolveClusterName(ctx context.Context) {
+	node := os.Getenv("NODE_NAME")
+	if cfg.clusterName != "" || node == "" || cfg.clusterNameNodeLabel == "" {
+		return
+	}
+	api, err := kube.InCluster()
+	if err != nil {
+		slog.Warn("cannot read the cluster name from the node", "error", err)
+		return
+	}
+	labels, err := api.NodeLabels(ctx, node)
+	if err != nil {
+		slog.Warn("cannot read the cluster name from the node", "node", node, "error", err)
+		return
+	}
+	if cfg.clusterName = labels[cfg.clusterNameNodeLabel]; cfg.clusterName == "" {
+		slog.Warn("node has no cluster name label", "node", node, "label", cfg.clusterNameNodeLabel)
+		return
+	}
+	slog.Info("read cluster name from node", "node", node, "cluster", cfg.clusterName)
+}
+
+// newElector creates the leader elector from the configuration. The
+// identity is the pod name (POD_NAME), falling back to the hostname.
+func (cfg *config) newElector() (*leader.Elector, error) {
+	identity := os.Getenv("POD_NAME")
+	if identity == "" {
+		var err error
+		if identity, err = os.Hostname(); err != nil {
+			return nil, fmt.Errorf("determine identity: %w", err)
+		}
+	}
+	address := cfg.leaderElectionAddress
+	if address == "" {
+		podIP := os.Getenv("POD_IP")
+		if podIP == "" {
+			return nil, errors.New("leader election requires POD_IP or --leader-election-address")
+		}
+		address = "http://" + net.JoinHostPort(podIP, cfg.port)
+	}
+	namespace := cfg.leaderElectionNamespace
+	if namespace == "" {
+		namespace = kube.Namespace()
+	}
+	return leader.New(leader.Config{
+		Namespace:     namespace,
+		Name:          cfg.leaderElectionLease,
+		Identity:      identity,
+		Address:       address,
+		LeaseDuration: cfg.leaderElectionLeaseDuration,
+	})
+}
+
+// shard returns the validated shard of this replica.
+func (cfg *config) shard() (collector.Shard, error) {
+	s := collector.Shard{Index: cfg.shardIndex, Count: cfg.shardCount, Key: cfg.shardKey}
+	if err := s.Validate(); err != nil {
+		return collector.Shard{}, err
+	}
+	return s, nil
+}
+
+// newExporter returns the Parquet exporter, or nil if export is disabled.
+func (cfg *config) newExporter() (*export.Exporter, error) {
+	if cfg.exportURL == "" {
+		return nil, nil
+	}
+	var sinkOpts []export.SinkOption
+	if cfg.exportEndpoint != "" {
+		sinkOpts = append(sinkOpts, export.WithEndpoint(cfg.exportEndpoint))
+	}
+	if cfg.exportRegion != "" {
+		sinkOpts = append(sinkOpts, export.WithRegion(cfg.exportRegion))
+	}
+	exp, err := export.NewFromURL(cfg.exportURL, sinkOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid export configuration: %w", err)
+	}
+	slog.Info("parquet export enabled", "destination", cfg.exportURL)
+	return exp, nil
+}
+
+// alertEngine returns the threshold and budget alert engine, or nil if no
+// rules or budgets are configured.
+func (cfg *config) alertEngine() (*alert.Engine, error) {
+	if cfg.alertRules == "" && cfg.budgets == "" {
+		return nil, nil
+	}
+	rules, err := cfg.alertRuleSet()
+	if err != nil {
+		return nil, fmt.Errorf("invalid alert rules: %w", err)
+	}
+	notifiers, err := cfg.alertNotifiers()
+	if err != nil {
+		return nil, fmt.Errorf("invalid alert notification configuration: %w", err)
+	}
+	slog.Info("cost alerts enabled", "rules", len(rules), "notifiers", len(notifiers))
+	return alert.NewEngine(rules, notifiers...), nil
+}
+
+// alertRuleSet returns the threshold rules and the rules expanded from
+// budgets.
+func (cfg *config) alertRuleSet() ([]alert.Rule, error) {
+	rules, err := alert.ParseRules(cfg.alertRules)
+	if err != nil {
+		return nil, err
+	}
+	levels, err := alert.ParseLevels(cfg.budgetLevels)
+	if err != nil {
+		return nil, err
+	}
+	budgets, err := alert.ParseBudgets(cfg.budgets, levels)
+	if err != nil {
+		return nil, err
+	}
+	return append(rules, budgets...), nil
+}
+
+// alertNotifiers returns the configured threshold alert destinations.
+func (cfg *config) alertNotifiers() ([]alert.Notifier, error) {
+	var notifiers []alert.Notifier
+	if cfg.alertWebhookURL != "" {
+		notifiers = append(notifiers, &alert.WebhookNotifier{URL: cfg.alertWebhookURL})
+	}
+	if key := secret.FromEnv("PAGERDUTY_ROUTING_KEY"); key != nil {
+		notifiers = append(notifiers, &alert.PagerDutyNotifier{RoutingKey: key})
+	}
+	if key := secret.FromEnv("OPSGENIE_API_KEY"); key != nil {
+		notifiers = append(notifiers, &alert.OpsgenieNotifier{APIKey: key, URL: cfg.opsgenieURL})
+	}
+
+	var text string
+	if cfg.alertMessageTemplate != "" {
+		b, err := os.ReadFile(cfg.alertMessageTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("read alert message template: %w", err)
+		}
+		text = string(b)
+	}
+	tmpl, err := alert.ParseMessageTemplate(text)
+	if err != nil {
+		return nil, err
+	}
+
+	chat := []struct {
+		url, routes string
+		newNotifier func(url string) alert.Notifier
+	}{
+		{cfg.slackWebhookURL, cfg.slackRoutes, func(url string) alert.Notifier { return &alert.SlackNotifier{URL: url, Template: tmpl} }},
+		{cfg.teamsWebhookURL, cfg.teamsRoutes, func(url string) alert.Notifier { return &alert.TeamsNotifier{URL: url, Template: tmpl} }},
+	}
+	for _, c := range chat {
+		if c.url != "" {
+			notifiers = append(notifiers, c.newNotifier(c.url))
+		}
+		for _, route := range strings.Split(c.routes, ";") {
+			if strings.TrimSpace(route) == "" {
+				continue
+			}
+			rules, url, ok := strings.Cut(route, "=")
+			if !ok || url == "" {
+				return nil, fmt.Errorf("invalid route %q: want rule[,rule]=url", route)
+			}
+			notifiers = append(notifiers, &alert.RuleFilter{Rules: splitList(rules), Notifier: c.newNotifier(strings.TrimSpace(url))})
+		}
+	}
+	return notifiers, nil
+}
+
+// reportSenders returns the configured monthly report destinations.
+func (cfg *config) reportSenders() []report.Sender {
+	var senders []report.Sender
+	if cfg.reportWebhookURL != "" {
+		senders = append(senders, &report.WebhookSender{URL: cfg.reportWebhookURL})
+	}
+	if cfg.reportSMTPAddr != "" {
+		senders = append(senders, &report.EmailSender{
+			Addr:     cfg.reportSMTPAddr,
+			From:     cfg.reportEmailFrom,
+			To:       splitList(cfg.reportEmailTo),
+			Username: cfg.reportSMTPUsername,
+			Password: secret.FromEnv("REPORT_SMTP_PASSWORD"),
+		})
+	}
+	return senders
+}
+
+// logLevel is the level of the default logger. It can change at runtime
+// when the configuration is reloaded.
+var logLevel slog.LevelVar
+
+// setupLogging configures structured JSON logging at the given level. Lines
+// logged during a refresh carry its request ID.
+func setupLogging(level string) {
+	logLevel.Set(parseLevel(level))
+	logger := slog.New(requestid.NewHandler(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		Level: &logLevel,
+	})))
+	slog.SetDefault(logger)
+}
+
+func parseLevel(level string) slog.Level {
+	switch level {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// splitList splits a comma-separated list, trimming blanks.
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// configFiles holds the settings read from --config-dir and --config.
+// getEnv consults them for variables that are not set in the environment.
+var configFiles map[string]string
+
+// loadConfig parses args with the settings in dir and file, if set, as
+// defaults, so that flags take precedence over environment variables,
+// which take precedence over the directory, which takes precedence over
+// the file. overrides, keyed by flag name, take precedence over all of
+// them. It also returns the value of every flag by name.
+func loadConfig(dir, file string, overrides map[string]string, args []string) (config, map[string]string, error) {
+	if err := readConfigFiles(dir, file); err != nil {
+		return config{}, nil, err
+	}
+
+	var cfg config
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	registerFlags(fs, &cfg)
+	fs.Bool("version", false, "")
+	if err := fs.Parse(args); err != nil {
+		return config{}, nil, fmt.Errorf("parse flags: %w", err)
+	}
+	if err := cfg.settingsError(); err != nil {
+		return config{}, nil, err
+	}
+	for name, value := range overrides {
+		if err := fs.Set(name, value); err != nil {
+			return config{}, nil, fmt.Errorf("invalid value %q for %s: %w", value, name, err)
+		}
+	}
+	return cfg, flagValues(fs), nil
+}
+
+// readConfigFiles reads the settings in dir and file, if set, into
+// configFiles, those in dir taking precedence.
+func readConfigFiles(dir, file string) error {
+	configFiles = nil
+	files := make(map[string]string)
+	if file != "" {
+		values, err := readConfigFile(file)
+		if err != nil {
+			return err
+		}
+		maps.Copy(files, values)
+	}
+	if dir != "" {
+		values, err := configwatch.Read(dir)
+		if err != nil {
+			return err
+		}
+		maps.Copy(files, values)
+	}
+	configFiles = files
+	return nil
+}
+
+// getEnv returns the value of the environment variable key, or of the file
+// named by key_FILE, falling back to --config-dir, --config and then
+// defaultVal.
+func getEnv(key, defaultVal string) string {
+	val, err := secret.FromEnv(key).Get()
+	if err != nil {
+		slog.Warn("failed to read setting from file", "env", key+"_FILE", "error", err)
+	}
+	if val != "" {
+		return val
+	}
+	if val := configFiles[key]; val != "" {
+		return val
+	}
+	return defaultVal
+}
+
+// envValue returns the setting key as getEnv does, parsed by parse. A value
+// that does not parse is recorded in cfg.invalid, for validation to fail,
+// and def is returned instead.
+func envValue[T any](cfg *config, key, def string, parse func(string) (T, error)) T {
+	s := getEnv(key, def)
+	v, err := parse(s)
+	if err == nil {
+		return v
+	}
+	cfg.invalid = append(cfg.invalid, fmt.Errorf("invalid %s %q: %w", key, s, err))
+	v, _ = parse(def)
+	return v
+}
+
+func parseFloat(s string) (float64, error) {
+	return strconv.ParseFloat(s, 64)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/collector"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/opencosttest"
+)
+
+func TestProbeFunc_NoCredentials(t *testing.T) {
+	server := opencosttest.NewServer(opencosttest.WithResponse(opencosttest.Response(
+		opencosttest.Item("123", "AmazonEC2", "Compute", 1),
+	)))
+	defer server.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("s3cret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("OPENCOST_API_KEY", "key")
+	t.Setenv("OPENCOST_PASSWORD", "password")
+	cfg := config{
+		window:                 "2d",
+		timezone:               "UTC",
+		retryBackoffMultiplier: 2,
+		opencostTokenFile:      tokenFile,
+		opencostAPIKeyHeader:   "X-API-Key",
+		opencostUsername:       "exporter",
+		opencostHeaders:        "X-Scope-OrgID=finance",
+		exchangeRateProvider:   "frankfurter",
+	}
+
+	probe := cfg.probeFunc([]collector.Option{collector.WithCurrencySymbols(nil)})
+	if _, err := probe(context.Background(), server.URL, ""); err != nil {
+		t.Fatalf("probe error = %v", err)
+	}
+	requests := server.Requests()
+	if len(requests) == 0 {
+		t.Fatal("probe sent no request")
+	}
+	for _, req := range requests {
+		for _, name := range []string{"Authorization", "X-API-Key", "X-Scope-OrgID"} {
+			if v := req.Header.Get(name); v != "" {
+				t.Errorf("%s %s: %s = %q, want no credentials sent to a probe target", req.Method, req.Path, name, v)
+			}
+		}
+	}
+}
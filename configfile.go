@@ -0,0 +1,144 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.yaml.in/yaml/v2"
+)
+
+// configSections assigns the settings of a --config file to its sections.
+// Settings are named after their flags, e.g.
+//
+//	client:
+//	  opencost-url: http://opencost.opencost:9003
+//	  window: 7d
+//	collector:
+//	  currency-symbols: [EUR, CNY]
+var configSections = map[string][]string{
+	"client": {
+		"opencost-url", "sidecar", "opencost-token-file", "opencost-api-key-header", "opencost-username", "opencost-headers", "api-flavor",
+		"federation-sources", "window", "window-fallbacks", "window-shard", "timezone", "aggregate",
+		"max-retries", "retry-initial-backoff", "retry-max-backoff", "retry-backoff-multiplier", "retry-jitter", "demo",
+	},
+	"cache": {"cache-ttl", "max-stale", "cache-file", "refresh-schedule"},
+	"collector": {
+		"emit-kube-percent-metrics", "emit-invoice-entity-metrics", "emit-account-metrics", "emit-hourly-rate-metrics", "emit-cumulative-metrics", "accumulate", "max-series", "top-n-services",
+		"emit-allocation-metrics", "emit-asset-metrics", "downsample", "downsample-min-window", "restatement-threshold", "currency-symbols", "exchange-rate-provider", "exchange-rate-url", "exchange-rate-file", "convert-currencies", "label-mappings",
+		"cloud-provider-label", "resource-id-labels", "ownership-file", "provider-labels", "labels", "negative-costs", "metric-namespace", "metric-naming", "missing-fields", "cost-precision",
+		"filter-service", "filter-account", "filter-category", "filter-labels",
+		"cluster-name", "cluster-name-node-label", "shard-index", "shard-count", "shard-key",
+	},
+	"server": {
+		"port", "tls-cert", "tls-key", "tls-client-ca", "admin-token-file", "grpc-port", "log-level", "memory-pressure-threshold",
+		"proxy-cloudcost", "proxy-opencost-metrics", "opencost-metrics-url", "opencost-metrics-allowlist", "probe", "probe-allowed-targets", "cost-api",
+		"tracing-endpoint", "trace-sample-ratio", "otlp-metrics-endpoint", "otlp-metrics-protocol", "otlp-metrics-interval", "prometheus-metrics",
+		"leader-election", "leader-election-namespace", "leader-election-lease", "leader-election-lease-duration", "leader-election-address",
+		"self-metrics-prefix", "disable-self-metrics", "self-metrics-instance", "legacy-metric-names",
+	},
+	"alerts": {
+		"alert-rules", "alert-webhook-url", "alert-message-template", "budgets", "budget-levels", "opsgenie-url",
+		"slack-webhook-url", "slack-routes", "teams-webhook-url", "teams-routes",
+	},
+	"export": {"export-url", "export-endpoint", "export-region"},
+	"report": {"report-format", "report-webhook-url", "report-smtp-addr", "report-smtp-username", "report-email-from", "report-email-to"},
+}
+
+// semicolonLists are the settings whose lists are separated by semicolons
+// rather than commas.
+var semicolonLists = []string{"alert-rules", "budgets", "slack-routes", "teams-routes"}
+
+// readConfigFile reads the settings of a --config file, keyed by their
+// environment variables like those of --config-dir. Every setting is
+// checked to be in its section and to parse as its flag does, so that a
+// mistake fails at startup rather than falling back to a default.
+func readConfigFile(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+	var sections map[string]map[string]any
+	if err := yaml.UnmarshalStrict(b, &sections); err != nil {
+		return nil, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	sectionOf := make(map[string]string)
+	for section, names := range configSections {
+		for _, name := range names {
+			sectionOf[name] = section
+		}
+	}
+	// A scratch flag set checks the values.
+	var scratch config
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	registerFlags(fs, &scratch)
+
+	values := make(map[string]string)
+	for _, section := range slices.Sorted(maps.Keys(sections)) {
+		if _, ok := configSections[section]; !ok {
+			return nil, fmt.Errorf("config file %s: unknown section %q, want one of %s", path, section, strings.Join(slices.Sorted(maps.Keys(configSections)), ", "))
+		}
+		for _, name := range slices.Sorted(maps.Keys(sections[section])) {
+			want, ok := sectionOf[name]
+			if !ok {
+				return nil, fmt.Errorf("config file %s: unknown setting %s.%s", path, section, name)
+			}
+			if want != section {
+				return nil, fmt.Errorf("config file %s: setting %s belongs in section %s, not %s", path, name, want, section)
+			}
+			value, err := configValue(name, sections[section][name])
+			if err != nil {
+				return nil, fmt.Errorf("config file %s: %s.%s: %w", path, section, name, err)
+			}
+			if err := fs.Set(name, value); err != nil {
+				return nil, fmt.Errorf("config file %s: %s.%s: invalid value %q: %w", path, section, name, value, err)
+			}
+			if f, ok := fs.Lookup(name).Value.(interface{ IsBoolFlag() bool }); ok && f.IsBoolFlag() {
+				// The flags read their settings as "true" or not, while
+				// the flag set also accepts e.g. 1 and TRUE.
+				b, _ := strconv.ParseBool(value)
+				value = strconv.FormatBool(b)
+			}
+			values[envName(name)] = value
+		}
+	}
+	return values, nil
+}
+
+// configValue returns the flag value of a setting: scalars as they are
+// written and lists joined by their separator.
+func configValue(name string, v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case []any:
+		sep := ","
+		if slices.Contains(semicolonLists, name) {
+			sep = ";"
+		}
+		items := make([]string, len(v))
+		for i, item := range v {
+			s, err := configValue(name, item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = s
+		}
+		return strings.Join(items, sep), nil
+	case map[any]any:
+		return "", fmt.Errorf("want a value or a list, not a mapping")
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// envName returns the environment variable of a flag, e.g. CACHE_TTL for
+// cache-ttl.
+func envName(flagName string) string {
+	return strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadConfigFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"scalars", "client:\n  window: 7d\n  max-retries: 5\n", map[string]string{"WINDOW": "7d", "MAX_RETRIES": "5"}, false},
+		{"list", "collector:\n  currency-symbols: [EUR, CNY]\n", map[string]string{"CURRENCY_SYMBOLS": "EUR,CNY"}, false},
+		{"semicolon list", "alerts:\n  budgets: [a, b]\n", map[string]string{"BUDGETS": "a;b"}, false},
+		{"bool", "collector:\n  emit-account-metrics: true\n", map[string]string{"EMIT_ACCOUNT_METRICS": "true"}, false},
+		{"bool as 1", "collector:\n  emit-kube-percent-metrics: 1\n", map[string]string{"EMIT_KUBE_PERCENT_METRICS": "true"}, false},
+		{"bool as TRUE", "collector:\n  accumulate: TRUE\n", map[string]string{"ACCUMULATE": "true"}, false},
+		{"bool as f", "server:\n  legacy-metric-names: f\n", map[string]string{"LEGACY_METRIC_NAMES": "false"}, false},
+		{"invalid bool", "collector:\n  emit-account-metrics: maybe\n", nil, true},
+		{"invalid number", "client:\n  max-retries: many\n", nil, true},
+		{"unknown section", "exporter:\n  window: 7d\n", nil, true},
+		{"unknown setting", "client:\n  windows: 7d\n", nil, true},
+		{"wrong section", "cache:\n  window: 7d\n", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.yaml), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			got, err := readConfigFile(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readConfigFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !maps.Equal(got, tt.want) {
+				t.Errorf("readConfigFile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
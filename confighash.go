@@ -0,0 +1,73 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"maps"
+	"slices"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// instanceFlags identify an instance rather than configure it, and differ
+// between instances of one fleet by design. They are left out of the
+// configuration hash.
+var instanceFlags = []string{
+	"cluster-name", "shard-index", "leader-election-address",
+	"self-metrics-instance", "version",
+}
+
+// configHash reports a fingerprint of the effective configuration as
+// cloudcost_exporter_config_hash{hash="..."} 1, so that fleet dashboards
+// can spot instances running divergent or stale configuration.
+type configHash struct {
+	desc *prometheus.Desc
+	hash atomic.Pointer[string]
+}
+
+func newConfigHash(values map[string]string) *configHash {
+	h := &configHash{
+		desc: prometheus.NewDesc(
+			"cloudcost_exporter_config_hash",
+			"Fingerprint of the effective configuration, as a hash label with the value 1",
+			[]string{"hash"}, nil,
+		),
+	}
+	h.set(values)
+	return h
+}
+
+// set replaces the configuration, given as flag values by name.
+func (h *configHash) set(values map[string]string) {
+	sum := sha256.New()
+	for _, name := range slices.Sorted(maps.Keys(values)) {
+		if slices.Contains(instanceFlags, name) {
+			continue
+		}
+		// Names and values cannot contain NUL.
+		sum.Write([]byte(name + "\x00" + values[name] + "\x00"))
+	}
+	hash := hex.EncodeToString(sum.Sum(nil))[:16]
+	h.hash.Store(&hash)
+}
+
+// Describe implements prometheus.Collector.
+func (h *configHash) Describe(ch chan<- *prometheus.Desc) {
+	ch <- h.desc
+}
+
+// Collect implements prometheus.Collector.
+func (h *configHash) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(h.desc, prometheus.GaugeValue, 1, *h.hash.Load())
+}
+
+// flagValues returns the value of every flag of fs by name.
+func flagValues(fs *flag.FlagSet) map[string]string {
+	values := make(map[string]string)
+	fs.VisitAll(func(f *flag.Flag) {
+		values[f.Name] = f.Value.String()
+	})
+	return values
+}
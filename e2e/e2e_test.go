@@ -0,0 +1,288 @@
+//go:build e2e
+
+// Package e2e runs the exporter against a real OpenCost and asserts on the
+// scraped metrics, catching schema drift between OpenCost releases.
+//
+// By default the suite starts OpenCost with docker compose (see
+// compose.yaml); set E2E_OPENCOST_URL to test an existing deployment
+// instead, e.g. one with a live cloud cost integration. Run with:
+//
+//	make test-e2e
+package e2e
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/client"
+)
+
+var (
+	opencostURL string
+	exporterURL string
+)
+
+func TestMain(m *testing.M) {
+	code, err := run(m)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "e2e:", err)
+		code = 1
+	}
+	os.Exit(code)
+}
+
+func run(m *testing.M) (int, error) {
+	opencostURL = os.Getenv("E2E_OPENCOST_URL")
+	if opencostURL == "" {
+		stop, err := startOpenCost()
+		if stop != nil {
+			defer stop()
+		}
+		if err != nil {
+			return 1, err
+		}
+		opencostURL = "http://localhost:9003"
+	}
+	if err := waitHealthy(opencostURL+"/healthz", 5*time.Minute); err != nil {
+		return 1, fmt.Errorf("OpenCost did not become healthy: %w", err)
+	}
+
+	stop, err := startExporter()
+	if stop != nil {
+		defer stop()
+	}
+	if err != nil {
+		return 1, err
+	}
+	return m.Run(), nil
+}
+
+// startOpenCost brings up compose.yaml. The returned function tears it
+// down unless E2E_KEEP is set.
+func startOpenCost() (func(), error) {
+	compose := func(args ...string) *exec.Cmd {
+		cmd := exec.Command("docker", append([]string{"compose", "-f", "compose.yaml", "-p", "cloudcost-e2e"}, args...)...)
+		cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+		return cmd
+	}
+	stop := func() {
+		if os.Getenv("E2E_KEEP") != "" {
+			return
+		}
+		compose("down", "-v").Run()
+	}
+	if err := compose("up", "-d").Run(); err != nil {
+		return stop, fmt.Errorf("docker compose up: %w", err)
+	}
+	return stop, nil
+}
+
+// startExporter builds the exporter and runs it against OpenCost on a free
+// port.
+func startExporter() (func(), error) {
+	dir, err := os.MkdirTemp("", "cloudcost-e2e")
+	if err != nil {
+		return nil, err
+	}
+	bin := filepath.Join(dir, "exporter")
+	build := exec.Command("go", "build", "-o", bin, "..")
+	build.Stdout, build.Stderr = os.Stderr, os.Stderr
+	if err := build.Run(); err != nil {
+		return func() { os.RemoveAll(dir) }, fmt.Errorf("build exporter: %w", err)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		return func() { os.RemoveAll(dir) }, err
+	}
+	cmd := exec.Command(bin,
+		"--opencost-url="+opencostURL,
+		"--port="+port,
+		"--window=7d",
+		"--currency-symbols=",
+	)
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	if err := cmd.Start(); err != nil {
+		return func() { os.RemoveAll(dir) }, fmt.Errorf("start exporter: %w", err)
+	}
+	stop := func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+		os.RemoveAll(dir)
+	}
+
+	exporterURL = "http://localhost:" + port
+	if err := waitHealthy(exporterURL+"/readyz", time.Minute); err != nil {
+		return stop, fmt.Errorf("exporter did not become ready: %w", err)
+	}
+	return stop, nil
+}
+
+func freePort() (string, error) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer lis.Close()
+	_, port, err := net.SplitHostPort(lis.Addr().String())
+	return port, err
+}
+
+func waitHealthy(url string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		resp, err := http.Get(url)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+			err = fmt.Errorf("status %d", resp.StatusCode)
+		}
+		if time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+// requiredItemFields are the cloudCost item fields the exporter reads.
+var requiredItemFields = map[string][]string{
+	"properties":       {"provider", "accountID", "service", "category"},
+	"window":           {"start", "end"},
+	"listCost":         {"cost", "kubernetesPercent"},
+	"netCost":          {"cost", "kubernetesPercent"},
+	"amortizedNetCost": {"cost", "kubernetesPercent"},
+	"invoicedCost":     {"cost", "kubernetesPercent"},
+	"amortizedCost":    {"cost", "kubernetesPercent"},
+}
+
+// TestCloudCostSchema checks the raw OpenCost response still carries every
+// field the exporter depends on.
+func TestCloudCostSchema(t *testing.T) {
+	resp, err := http.Get(opencostURL + "/cloudCost?window=7d")
+	if err != nil {
+		t.Fatalf("GET /cloudCost: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /cloudCost status %d: %s", resp.StatusCode, body)
+	}
+
+	var raw struct {
+		Code *int `json:"code"`
+		Data *struct {
+			Sets []struct {
+				CloudCosts map[string]map[string]json.RawMessage `json:"cloudCosts"`
+			} `json:"sets"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		t.Fatalf("decode /cloudCost: %v", err)
+	}
+	if raw.Code == nil || raw.Data == nil {
+		t.Fatalf("response lacks code or data: %s", body)
+	}
+
+	items := 0
+	for _, set := range raw.Data.Sets {
+		for key, item := range set.CloudCosts {
+			items++
+			for field, subfields := range requiredItemFields {
+				var obj map[string]json.RawMessage
+				if err := json.Unmarshal(item[field], &obj); err != nil {
+					t.Errorf("item %s: field %q missing or not an object", key, field)
+					continue
+				}
+				for _, sub := range subfields {
+					if _, ok := obj[sub]; !ok {
+						t.Errorf("item %s: field %s.%s missing", key, field, sub)
+					}
+				}
+			}
+		}
+	}
+	if items == 0 {
+		t.Log("OpenCost returned no cloud cost items; only the envelope was checked")
+	}
+
+	if _, err := client.New(opencostURL, client.WithWindow("7d")).FetchCloudCosts(context.Background()); err != nil {
+		t.Errorf("client.FetchCloudCosts() error = %v", err)
+	}
+}
+
+// TestMetrics scrapes the exporter and checks its output.
+func TestMetrics(t *testing.T) {
+	families := scrape(t)
+
+	for _, name := range []string{
+		"cloudcost_exporter_info",
+		"cloudcost_exporter_last_successful_scrape_timestamp",
+		"cloudcost_exporter_scrape_errors_total",
+	} {
+		if _, ok := families[name]; !ok {
+			t.Errorf("missing metric %s", name)
+		}
+	}
+	if mf, ok := families["cloudcost_exporter_scrape_errors_total"]; ok {
+		if v := mf.GetMetric()[0].GetCounter().GetValue(); v != 0 {
+			t.Errorf("scrape_errors_total = %v, want 0", v)
+		}
+	}
+	if mf, ok := families["cloudcost_exporter_last_successful_scrape_timestamp"]; ok {
+		if v := mf.GetMetric()[0].GetGauge().GetValue(); v == 0 {
+			t.Error("last_successful_scrape_timestamp should be set")
+		}
+	}
+
+	costs, ok := families["aws_cloud_cost_total"]
+	if !ok {
+		if os.Getenv("E2E_EXPECT_COSTS") != "" {
+			t.Fatal("missing aws_cloud_cost_total")
+		}
+		t.Skip("no cost series; set E2E_EXPECT_COSTS against an OpenCost with a cloud cost integration")
+	}
+	want := []string{"account_id", "service", "category", "cost_type"}
+	for _, m := range costs.GetMetric() {
+		labels := make(map[string]bool)
+		for _, l := range m.GetLabel() {
+			labels[l.GetName()] = true
+		}
+		for _, l := range want {
+			if !labels[l] {
+				t.Errorf("series %v lacks label %s", m.GetLabel(), l)
+			}
+		}
+	}
+}
+
+func scrape(t *testing.T) map[string]*dto.MetricFamily {
+	t.Helper()
+
+	resp, err := http.Get(exporterURL + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics: %v", err)
+	}
+	defer resp.Body.Close()
+
+	parser := expfmt.NewTextParser(model.UTF8Validation)
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		t.Fatalf("parse /metrics: %v", err)
+	}
+	return families
+}
@@ -4,20 +4,46 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/admin"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/alert"
 	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/cache"
 	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/client"
 	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/collector"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/compat"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/costapi"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/demo"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/export"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/grpcapi"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/kube"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/leader"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/memguard"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/operator"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/otlpmetrics"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/probe"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/proxy"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/report"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/secret"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/selfmetrics"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/servertls"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/tracing"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
 )
 
 // Build information - injected via ldflags
@@ -28,16 +54,18 @@ var (
 )
 
 func main() {
-	// CLI flags
-	opencostURL := flag.String("opencost-url", getEnv("OPENCOST_URL", "http://opencost.opencost:9003"), "OpenCost service URL")
-	port := flag.String("port", getEnv("PORT", "9100"), "Metrics server port")
-	window := flag.String("window", getEnv("WINDOW", "2d"), "Time window for cost queries")
-	aggregate := flag.String("aggregate", getEnv("AGGREGATE", "service,category"), "Aggregation dimensions")
-	cacheTTL := flag.Duration("cache-ttl", parseDuration(getEnv("CACHE_TTL", "1h")), "Cache TTL")
-	maxStale := flag.Duration("max-stale", parseDuration(getEnv("MAX_STALE", "6h")), "Maximum age for stale data")
-	emitKubePercentMetrics := flag.Bool("emit-kube-percent-metrics", getEnv("EMIT_KUBE_PERCENT_METRICS", "false") == "true", "Emit kubernetes percent metric")
-	currencySymbols := flag.String("currency-symbols", getEnv("CURRENCY_SYMBOLS", "CNY,EUR"), "Comma-separated target currency symbols for exchange rates")
-	logLevel := flag.String("log-level", getEnv("LOG_LEVEL", "info"), "Log level (debug, info, warn, error)")
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, "error:", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	var cfg config
+	registerFlags(flag.CommandLine, &cfg)
 	showVersion := flag.Bool("version", false, "Show version and exit")
 	flag.Parse()
 
@@ -46,34 +74,107 @@ func main() {
 		os.Exit(0)
 	}
 
-	// Configure structured JSON logging
-	var level slog.Level
-	switch *logLevel {
-	case "debug":
-		level = slog.LevelDebug
-	case "warn":
-		level = slog.LevelWarn
-	case "error":
-		level = slog.LevelError
-	default:
-		level = slog.LevelInfo
+	// Settings from --config-dir and --config fill in for unset environment
+	// variables. With either or --operator-config, the configuration can
+	// change at runtime.
+	reloadable := cfg.configDir != "" || cfg.configFile != "" || cfg.operatorConfig != ""
+	values := flagValues(flag.CommandLine)
+	if reloadable {
+		var err error
+		if cfg, values, err = loadConfig(cfg.configDir, cfg.configFile, nil, os.Args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, "error: failed to load configuration:", err)
+			os.Exit(1)
+		}
+	}
+
+	setupLogging(cfg.logLevel)
+	flag.Visit(func(f *flag.Flag) {
+		if slices.Contains(secretFlags, f.Name) {
+			slog.Warn("secret passed on the command line is visible in the process list; set it through the _FILE environment variable instead", "flag", f.Name)
+		}
+	})
+
+	if _, err := time.LoadLocation(cfg.timezone); err != nil {
+		slog.Error("invalid time zone", "timezone", cfg.timezone, "error", err)
+		os.Exit(1)
+	}
+	if err := cfg.settingsError(); err != nil {
+		slog.Error("invalid configuration", "error", err)
+		os.Exit(1)
+	}
+	if _, err := cfg.retryPolicy(); err != nil {
+		slog.Error("invalid retry policy", "error", err)
+		os.Exit(1)
+	}
+	if _, err := cfg.openCostHeaders(); err != nil {
+		slog.Error("invalid OpenCost headers", "error", err)
+		os.Exit(1)
+	}
+	if cfg.opencostUsername != "" && cfg.opencostTokenFile != "" {
+		slog.Error("basic auth with --opencost-username cannot be combined with --opencost-token-file")
+		os.Exit(1)
+	}
+	if _, err := cfg.exchangeRates(); err != nil {
+		slog.Error("invalid exchange rate provider", "error", err)
+		os.Exit(1)
+	}
+	if _, err := cfg.flavor(); err != nil {
+		slog.Error("invalid API flavor", "error", err)
+		os.Exit(1)
+	}
+	if _, err := cfg.windowShardSize(); err != nil {
+		slog.Error("invalid window shard", "error", err)
+		os.Exit(1)
+	}
+	fallbacks, err := cfg.fallbackWindows()
+	if err != nil {
+		slog.Error("invalid window fallbacks", "error", err)
+		os.Exit(1)
+	}
+	schedule, err := cfg.newRefreshSchedule()
+	if err != nil {
+		slog.Error("invalid refresh schedule", "schedule", cfg.refreshSchedule, "error", err)
+		os.Exit(1)
+	}
+
+	if cfg.demo {
+		demoURL, _, err := demo.New().Start()
+		if err != nil {
+			slog.Error("failed to start demo data server", "error", err)
+			os.Exit(1)
+		}
+		cfg.opencostURL = demoURL
+		slog.Warn("demo mode enabled: serving synthetic cost data instead of querying OpenCost")
 	}
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-		Level: level,
-	}))
-	slog.SetDefault(logger)
 
 	slog.Info("starting opencost-cloudcost-exporter",
 		"version", version,
 		"commit", commit,
 		"date", date,
-		"opencost_url", *opencostURL,
-		"port", *port,
-		"window", *window,
-		"cache_ttl", cacheTTL.String(),
-		"max_stale", maxStale.String(),
+		"opencost_url", secret.RedactURL(cfg.openCostURL()),
+		"port", cfg.port,
+		"window", cfg.window,
+		"timezone", cfg.timezone,
+		"cache_ttl", cfg.cacheTTL.String(),
+		"max_stale", cfg.maxStale.String(),
+		"refresh_schedule", cfg.refreshSchedule,
 	)
 
+	// Identify the cluster on the exporter's metrics. The collector labels
+	// its own; the others are registered with a constant label. Proxied
+	// OpenCost metrics are re-exposed unchanged.
+	cfg.resolveClusterName(context.Background())
+	reg := prometheus.DefaultRegisterer
+	if cfg.clusterName != "" {
+		reg = prometheus.WrapRegistererWith(prometheus.Labels{"cluster": cfg.clusterName}, reg)
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.tracingEndpoint, version, cfg.traceSampleRatio)
+	if err != nil {
+		slog.Error("failed to set up tracing", "error", err)
+		os.Exit(1)
+	}
+
 	// Register build info metric
 	buildInfo := prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Namespace: "cloudcost_exporter",
@@ -81,46 +182,360 @@ func main() {
 		Help:      "Build information about the opencost-cloudcost-exporter",
 	}, []string{"version", "commit", "date"})
 	buildInfo.WithLabelValues(version, commit, date).Set(1)
-	prometheus.MustRegister(buildInfo)
+	reg.MustRegister(buildInfo)
+	hash := newConfigHash(values)
+	reg.MustRegister(hash)
 
 	// Create components
-	cl := client.New(*opencostURL,
-		client.WithWindow(*window),
-		client.WithAggregate(*aggregate),
-		client.WithTimeout(30*time.Second),
-	)
-	ca := cache.New(*cacheTTL, *maxStale)
-	// Parse currency symbols
-	var symbols []string
-	if *currencySymbols != "" {
-		for _, s := range strings.Split(*currencySymbols, ",") {
-			s = strings.TrimSpace(s)
-			if s != "" {
-				symbols = append(symbols, s)
+	cfg.requestMetrics = client.NewRequestMetrics()
+	reg.MustRegister(cfg.requestMetrics)
+	cl := cfg.newClient()
+	ca := cfg.newCache()
+	if restored, err := ca.Load(); err != nil {
+		slog.Warn("failed to restore the cache file, starting empty", "path", cfg.cacheFile, "error", err)
+	} else if restored {
+		slog.Info("restored cost data from the cache file", "path", cfg.cacheFile, "age", ca.Age().Round(time.Second).String())
+	}
+	collectorOpts, err := cfg.collectorOptions()
+	if err != nil {
+		slog.Error("invalid collector configuration", "error", err)
+		os.Exit(1)
+	}
+	selfMetricsOpts, err := cfg.selfMetricsOptions()
+	if err != nil {
+		slog.Error("invalid self-metrics configuration", "error", err)
+		os.Exit(1)
+	}
+	var renames []compat.Rename
+	if cfg.legacyMetricNames {
+		renames = compat.Renames
+	}
+	gatherer := selfmetrics.Wrap(compat.Wrap(prometheus.DefaultGatherer, renames), selfMetricsOpts...)
+	mappings, err := collector.ParseLabelMappings(cfg.labelMappings)
+	if err != nil {
+		slog.Error("invalid label mappings", "error", err)
+		os.Exit(1)
+	}
+	collectorOpts = append(collectorOpts, collector.WithLabelMappings(mappings))
+	// Probes fetch their target themselves, without the sharding, hooks and
+	// schedule added below.
+	probeCollectorOpts := slices.Clip(collectorOpts)
+
+	// Federation of several OpenCost instances
+	fetch, ping := cl.FetchCloudCosts, cl.Ping
+	fetchWindow := cl.FetchCloudCostsWindow
+	if cfg.federationSources != "" {
+		fed, err := cfg.newFederation(cl)
+		if err != nil {
+			slog.Error("invalid federation configuration", "error", err)
+			os.Exit(1)
+		}
+		reg.MustRegister(fed)
+		fetch, ping = fed.Fetch, fed.Ping
+		fetchWindow = fed.FetchWindow
+		collectorOpts = append(collectorOpts, collector.WithFetcher(fetch))
+		slog.Info("federation enabled", "sources", len(strings.Split(cfg.federationSources, ",")))
+	}
+
+	// Larger windows while billing data of new accounts lags
+	if len(fallbacks) > 0 {
+		fetch = collector.FallbackFetcher(fetchWindow, cl.Window, fallbacks)
+		collectorOpts = append(collectorOpts, collector.WithFetcher(fetch))
+		slog.Info("window fallbacks enabled", "windows", fallbacks)
+	}
+
+	// Sharding across replicas
+	if cfg.shardCount > 1 {
+		shard, err := cfg.shard()
+		if err != nil {
+			slog.Error("invalid shard configuration", "error", err)
+			os.Exit(1)
+		}
+		if cfg.leaderElection {
+			slog.Error("sharding cannot be combined with leader election")
+			os.Exit(1)
+		}
+		collectorOpts = append(collectorOpts, collector.WithShard(shard))
+		slog.Info("sharding enabled", "shard_index", shard.Index, "shard_count", shard.Count, "shard_key", shard.Key)
+	}
+
+	// Leader election: only the leader queries OpenCost and runs side
+	// effects; followers read the leader's cache.
+	var elector *leader.Elector
+	electionDone := make(chan struct{})
+	stopElection := func() {}
+	if cfg.leaderElection {
+		elector, err = cfg.newElector()
+		if err != nil {
+			slog.Error("invalid leader election configuration", "error", err)
+			os.Exit(1)
+		}
+		reg.MustRegister(elector)
+		collectorOpts = append(collectorOpts, collector.WithFetcher(leader.Fetcher(elector, nil, fetch)))
+
+		var electionCtx context.Context
+		electionCtx, stopElection = context.WithCancel(context.Background())
+		go func() {
+			defer close(electionDone)
+			elector.Run(electionCtx)
+		}()
+		slog.Info("leader election enabled", "lease", cfg.leaderElectionLease)
+	} else {
+		close(electionDone)
+	}
+
+	// Parquet export to object storage. Like the alert engine below, the
+	// exporter is swapped when the configuration is reloaded.
+	var exporter atomic.Pointer[export.Exporter]
+	exp, err := cfg.newExporter()
+	if err != nil {
+		slog.Error("invalid export configuration", "error", err)
+		os.Exit(1)
+	}
+	exporter.Store(exp)
+	if exp != nil || reloadable {
+		collectorOpts = append(collectorOpts, collector.WithRefreshHook(leaderOnly(elector, func(ctx context.Context, data *types.CloudCostResponse) {
+			if exp := exporter.Load(); exp != nil {
+				if err := exp.Export(ctx, data); err != nil {
+					slog.ErrorContext(ctx, "failed to export cloud costs", "error", err)
+				}
 			}
+		})))
+	}
+
+	// TLS of the metrics and gRPC servers
+	var certs *servertls.Certs
+	if opts := cfg.serverTLS(); opts.Enabled() {
+		certs, err = servertls.New(opts)
+		if err != nil {
+			slog.Error("invalid TLS configuration", "error", err)
+			os.Exit(1)
 		}
+		go reloadCertsOnSIGHUP(certs)
 	}
 
-	coll := collector.New(cl, ca,
-		collector.WithKubePercentMetrics(*emitKubePercentMetrics),
-		collector.WithCurrencySymbols(symbols),
+	// gRPC API, served with the TLS and client certificates of the metrics
+	// server if configured
+	var (
+		grpcServer *grpc.Server
+		grpcAPI    *grpcapi.Server
 	)
+	if cfg.grpcPort != "" {
+		grpcAPI = grpcapi.New(ca)
+		var grpcOpts []grpc.ServerOption
+		if certs != nil {
+			grpcOpts = append(grpcOpts, grpc.Creds(credentials.NewTLS(certs.Config())))
+		}
+		grpcServer = grpc.NewServer(grpcOpts...)
+		grpcAPI.Register(grpcServer)
+		collectorOpts = append(collectorOpts, collector.WithRefreshHook(grpcAPI.Notify))
+
+		lis, err := net.Listen("tcp", ":"+cfg.grpcPort)
+		if err != nil {
+			slog.Error("failed to listen for gRPC", "error", err)
+			os.Exit(1)
+		}
+		go func() {
+			slog.Info("gRPC server listening", "addr", lis.Addr().String(), "tls", certs != nil)
+			if err := grpcServer.Serve(lis); err != nil {
+				slog.Error("gRPC server error", "error", err)
+			}
+		}()
+	}
+
+	// Threshold and budget alerts. The engine is swapped when the
+	// configuration is reloaded.
+	var alerts atomic.Pointer[alert.Engine]
+	engine, err := cfg.alertEngine()
+	if err != nil {
+		slog.Error("invalid alert configuration", "error", err)
+		os.Exit(1)
+	}
+	alerts.Store(engine)
+	if engine != nil || reloadable {
+		collectorOpts = append(collectorOpts, collector.WithRefreshHook(leaderOnly(elector, func(ctx context.Context, data *types.CloudCostResponse) {
+			if e := alerts.Load(); e != nil {
+				e.Hook(ctx, data)
+			}
+		})))
+	}
+
+	// Monthly report delivery
+	if senders := cfg.reportSenders(); len(senders) > 0 {
+		if !slices.Contains(report.Formats, cfg.reportFormat) {
+			slog.Error("invalid report format", "format", cfg.reportFormat)
+			os.Exit(1)
+		}
+		if elector != nil {
+			for i, s := range senders {
+				senders[i] = leaderSender{elector: elector, Sender: s}
+			}
+		}
+		go report.NewScheduler(cl, report.Options{}, cfg.reportFormat, senders...).Run(context.Background())
+		slog.Info("monthly report enabled", "format", cfg.reportFormat, "destinations", len(senders))
+	}
+
+	if schedule != nil {
+		collectorOpts = append(collectorOpts, collector.WithRefreshSchedule(schedule))
+	}
+	coll := collector.New(cl, ca, collectorOpts...)
+	go coll.RunSchedule(context.Background())
+	if cfg.ownershipFile != "" {
+		go watchOwnership(context.Background(), cfg.ownershipFile, coll)
+		slog.Info("ownership labels enabled", "file", cfg.ownershipFile)
+	}
 
 	// Register collector
 	prometheus.MustRegister(coll)
+	if cfg.emitAllocation {
+		prometheus.MustRegister(collector.NewAllocationCollector(cl, collector.WithAllocationTTL(cfg.cacheTTL, cfg.maxStale)))
+		slog.Info("allocation metrics enabled")
+	}
+	if cfg.emitAssets {
+		prometheus.MustRegister(collector.NewAssetsCollector(cl, collector.WithAssetsTTL(cfg.cacheTTL, cfg.maxStale)))
+		slog.Info("asset metrics enabled")
+	}
+
+	if cfg.proxyOpenCostMetrics {
+		up, err := cfg.newUpstreamCollector()
+		if err != nil {
+			slog.Error("invalid OpenCost metrics configuration", "error", err)
+			os.Exit(1)
+		}
+		prometheus.MustRegister(up)
+		slog.Info("proxying OpenCost metrics", "allowlist", cfg.openCostMetricsAllow)
+	}
+
+	// Live configuration reload from --config-dir, --config and the
+	// CloudCostExporterConfig resource
+	var rl *reloader
+	if reloadable {
+		rl = &reloader{
+			args:     os.Args[1:],
+			dir:      cfg.configDir,
+			file:     cfg.configFile,
+			values:   values,
+			hash:     hash,
+			client:   cl,
+			coll:     coll,
+			cache:    ca,
+			alerts:   &alerts,
+			exporter: &exporter,
+		}
+		reg.MustRegister(rl.init())
+		go rl.run(context.Background())
+		if cfg.configDir != "" {
+			slog.Info("watching config directory for changes", "dir", cfg.configDir)
+		}
+
+		if cfg.operatorConfig != "" {
+			api, err := kube.InCluster()
+			if err != nil {
+				slog.Error("operator mode requires running in Kubernetes", "error", err)
+				os.Exit(1)
+			}
+			namespace, name, err := operator.ParseRef(cfg.operatorConfig, kube.Namespace())
+			if err != nil {
+				slog.Error("invalid operator configuration", "error", err)
+				os.Exit(1)
+			}
+			go operator.New(api, namespace, name, rl.apply).Run(context.Background())
+			slog.Info("reconciling CloudCostExporterConfig", "namespace", namespace, "name", name)
+		}
+	}
+
+	// Pushing the metrics to an OpenTelemetry collector, alongside or
+	// instead of serving them for scraping
+	if !cfg.prometheusMetrics && !otlpmetrics.Enabled(cfg.otlpMetricsEndpoint) {
+		slog.Error("--prometheus-metrics=false requires --otlp-metrics-endpoint, or the metrics go nowhere")
+		os.Exit(1)
+	}
+	shutdownMetrics, err := otlpmetrics.Setup(context.Background(), gatherer, cfg.otlpMetricsEndpoint, cfg.otlpMetricsProtocol, cfg.otlpMetricsInterval, version)
+	if err != nil {
+		slog.Error("failed to set up OTLP metrics", "error", err)
+		os.Exit(1)
+	}
+	if otlpmetrics.Enabled(cfg.otlpMetricsEndpoint) {
+		slog.Info("pushing metrics over OTLP", "protocol", cfg.otlpMetricsProtocol, "interval", cfg.otlpMetricsInterval.String(), "prometheus", cfg.prometheusMetrics)
+	}
 
 	// HTTP server
 	mux := http.NewServeMux()
-	mux.Handle("/metrics", promhttp.Handler())
+	if cfg.prometheusMetrics {
+		mux.Handle("/metrics", promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{})))
+	}
 	mux.HandleFunc("/healthz", healthzHandler)
-	mux.HandleFunc("/readyz", readyzHandler(cl, ca))
+	if cfg.sidecar {
+		mux.HandleFunc("/readyz", sidecarReadyzHandler(coll.Data))
+	} else {
+		mux.HandleFunc("/readyz", readyzHandler(ping, ca))
+	}
+	if elector != nil {
+		mux.Handle(leader.CachePath, leader.CacheHandler(coll.Data))
+	}
+	var px *proxy.Proxy
+	if cfg.proxyCloudCost {
+		px = proxy.New(cl, cfg.cacheTTL, cfg.maxStale)
+		reg.MustRegister(px)
+		mux.Handle(proxy.Prefix, px)
+		mux.Handle(proxy.Prefix+"/", px)
+		slog.Info("caching OpenCost API proxy enabled", "path", proxy.Prefix)
+	}
+	if cfg.probe {
+		probeOpts, err := cfg.probeOptions()
+		if err != nil {
+			slog.Error("invalid probe configuration", "error", err)
+			os.Exit(1)
+		}
+		mux.Handle(probe.Path, probe.New(cfg.probeFunc(probeCollectorOpts), probeOpts...))
+		slog.Info("multi-target probes enabled", "path", probe.Path)
+	}
+	if cfg.adminTokenFile != "" {
+		token := kube.TokenFile(cfg.adminTokenFile)
+		if _, err := token(); err != nil {
+			slog.Error("invalid admin token file", "error", err)
+			os.Exit(1)
+		}
+		if rl != nil {
+			// Reloads over HTTP need the admin token; without it, only
+			// SIGHUP and the watched directory reload.
+			mux.Handle(reloadPath, admin.RequireToken(token, rl))
+			slog.Info("configuration reload endpoint enabled", "path", reloadPath)
+		}
+		h := admin.New(coll, token)
+		mux.Handle(admin.FlushPath, h)
+		mux.Handle(admin.RefreshPath, h)
+		slog.Info("cache admin endpoints enabled", "paths", []string{admin.FlushPath, admin.RefreshPath})
+	}
+	if cfg.costAPI {
+		api := costapi.New(coll.Data)
+		mux.Handle(costapi.Path, api)
+		mux.Handle(costapi.CSVPath, api)
+		slog.Info("cost API enabled", "path", costapi.Path, "csv_path", costapi.CSVPath)
+	}
+
+	// Degradation under memory pressure
+	if cfg.memoryThreshold < 0 || cfg.memoryThreshold > 1 {
+		slog.Error("invalid memory pressure threshold, want a fraction between 0 and 1", "threshold", cfg.memoryThreshold)
+		os.Exit(1)
+	}
+	guardOpts := []memguard.Option{memguard.WithHook(coll.SetDegraded)}
+	if px != nil {
+		guardOpts = append(guardOpts, memguard.WithHook(px.SetDegraded))
+	}
+	guard := memguard.New(cfg.memoryThreshold, guardOpts...)
+	reg.MustRegister(guard)
+	go guard.Run(context.Background())
 
 	server := &http.Server{
-		Addr:         ":" + *port,
+		Addr:         ":" + cfg.port,
 		Handler:      mux,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 30 * time.Second,
 	}
+	if certs != nil {
+		server.TLSConfig = certs.Config()
+	}
 
 	// Graceful shutdown
 	go func() {
@@ -131,16 +546,97 @@ func main() {
 		slog.Info("shutting down server")
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
+		grpcStopped := make(chan struct{})
+		go func() {
+			defer close(grpcStopped)
+			if grpcServer != nil {
+				grpcAPI.Close()
+				stopGRPC(ctx, grpcServer)
+			}
+		}()
+		// Release the lease so another replica takes over immediately.
+		stopElection()
+		<-electionDone
 		server.Shutdown(ctx)
+		<-grpcStopped
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("failed to flush traces", "error", err)
+		}
+		if err := shutdownMetrics(ctx); err != nil {
+			slog.Error("failed to push metrics", "error", err)
+		}
 	}()
 
-	slog.Info("server listening", "addr", server.Addr)
-	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+	slog.Info("server listening", "addr", server.Addr, "tls", certs != nil, "client_certificates", cfg.tlsClientCA != "")
+	if certs != nil {
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err != http.ErrServerClosed {
 		slog.Error("server error", "error", err)
 		os.Exit(1)
 	}
 }
 
+// reloadCertsOnSIGHUP reloads the certificates of the metrics server on
+// SIGHUP, keeping the previous ones if the files cannot be read.
+func reloadCertsOnSIGHUP(certs *servertls.Certs) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	for range sighup {
+		if err := certs.Reload(); err != nil {
+			slog.Error("failed to reload TLS certificates, serving the previous ones", "error", err)
+			continue
+		}
+		slog.Info("reloaded TLS certificates")
+	}
+}
+
+// stopGRPC stops gs gracefully, waiting for the open RPCs, and closes
+// those still open once ctx is done.
+func stopGRPC(ctx context.Context, gs *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		gs.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		slog.Warn("gRPC calls still open at the shutdown deadline, closing them")
+		gs.Stop()
+	}
+}
+
+// leaderOnly wraps a refresh hook so it runs only on the leader, or always
+// when leader election is disabled.
+func leaderOnly(e *leader.Elector, hook func(context.Context, *types.CloudCostResponse)) func(context.Context, *types.CloudCostResponse) {
+	if e == nil {
+		return hook
+	}
+	return func(ctx context.Context, data *types.CloudCostResponse) {
+		if e.IsLeader() {
+			hook(ctx, data)
+		}
+	}
+}
+
+// leaderSender delivers reports only while the elector leads, so replicas
+// don't send duplicates.
+type leaderSender struct {
+	report.Sender
+	elector *leader.Elector
+}
+
+func (s leaderSender) Send(ctx context.Context, subject, contentType string, body []byte) error {
+	if !s.elector.IsLeader() {
+		slog.Debug("not the leader, skipping report delivery", "subject", subject)
+		return nil
+	}
+	return s.Sender.Send(ctx, subject, contentType, body)
+}
+
 // healthzHandler returns 200 OK if the server is running.
 func healthzHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
@@ -148,14 +644,14 @@ func healthzHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // readyzHandler returns 200 OK if OpenCost is reachable and cache is populated.
-func readyzHandler(cl *client.Client, ca *cache.Cache) http.HandlerFunc {
+func readyzHandler(ping func(context.Context) error, ca *cache.Cache) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Check if cache is populated
 		if !ca.IsPopulated() {
 			// Try to ping OpenCost
 			ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 			defer cancel()
-			if err := cl.Ping(ctx); err != nil {
+			if err := ping(ctx); err != nil {
 				w.WriteHeader(http.StatusServiceUnavailable)
 				w.Write([]byte("not ready: " + err.Error()))
 				return
@@ -166,17 +662,28 @@ func readyzHandler(cl *client.Client, ca *cache.Cache) http.HandlerFunc {
 	}
 }
 
-func getEnv(key, defaultVal string) string {
-	if val := os.Getenv(key); val != "" {
-		return val
-	}
-	return defaultVal
-}
-
-func parseDuration(s string) time.Duration {
-	d, err := time.ParseDuration(s)
-	if err != nil {
-		return time.Hour
+// sidecarReadyzHandler returns 200 OK once there are cost items, fetching
+// them if needed. As a sidecar, the exporter thereby gates the readiness of
+// the OpenCost pod on both containers having data: OpenCost serves no items
+// until it has ingested the billing data.
+func sidecarReadyzHandler(data func(context.Context) (*types.CloudCostResponse, bool)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// A fetch outlasting the probe timeout still fills the cache for the
+		// next probe.
+		d, ok := data(context.WithoutCancel(r.Context()))
+		if !ok {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("not ready: no data from OpenCost"))
+			return
+		}
+		for _, set := range d.Data.Sets {
+			if len(set.CloudCosts) > 0 {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte("ready"))
+				return
+			}
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("not ready: OpenCost has no cloud cost data yet"))
 	}
-	return d
 }
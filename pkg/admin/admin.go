@@ -0,0 +1,125 @@
+// Package admin serves the administrative endpoints acting on the cached
+// cost data, e.g. to fetch it again right after fixing the tags OpenCost
+// reports rather than waiting for the cache to expire. Every request must
+// carry the configured bearer token; RequireToken protects the other
+// administrative endpoints of the exporter with it.
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+// The paths the handler is served at.
+const (
+	FlushPath   = "/-/cache/flush"
+	RefreshPath = "/-/cache/refresh"
+)
+
+// Cache is the cached cost data the endpoints act on.
+type Cache interface {
+	// Flush drops the cached data and fetches it again.
+	Flush(ctx context.Context) (*types.CloudCostResponse, bool)
+	// Refresh fetches the data again, keeping the cached data on failure.
+	Refresh(ctx context.Context) (*types.CloudCostResponse, bool)
+}
+
+// Response is the body of a successful request: the window of the newly
+// fetched data and its size.
+type Response struct {
+	Window types.Window `json:"window"`
+	Sets   int          `json:"sets"`
+	Items  int          `json:"items"`
+}
+
+// Handler serves the cache endpoints.
+type Handler struct {
+	cache Cache
+	token func() (string, error)
+}
+
+// New creates a handler acting on cache for requests authenticated with
+// the bearer token returned by token, e.g. kube.TokenFile, which is called
+// on every request so that the token can be rotated.
+func New(cache Cache, token func() (string, error)) *Handler {
+	return &Handler{cache: cache, token: token}
+}
+
+// ServeHTTP implements http.Handler. A POST to FlushPath drops the cached
+// data before fetching it again, so that a failed fetch leaves nothing to
+// serve; a POST to RefreshPath keeps the cached data if the fetch fails.
+// Both wait for the fetch and answer with a Response, or 502 if it failed.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed, use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorized(w, r, h.token) {
+		return
+	}
+
+	var (
+		data *types.CloudCostResponse
+		ok   bool
+	)
+	switch r.URL.Path {
+	case FlushPath:
+		slog.Info("flushing the cache on request")
+		data, ok = h.cache.Flush(r.Context())
+	case RefreshPath:
+		slog.Info("refreshing the cache on request")
+		data, ok = h.cache.Refresh(r.Context())
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if !ok {
+		http.Error(w, "failed to fetch cloud costs from OpenCost", http.StatusBadGateway)
+		return
+	}
+
+	resp := Response{Window: data.Bounds(), Sets: len(data.Data.Sets)}
+	for _, set := range data.Data.Sets {
+		resp.Items += len(set.CloudCosts)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.Debug("failed to write admin response", "error", err)
+	}
+}
+
+// RequireToken returns a handler passing the requests carrying the bearer
+// token returned by token on to next and answering the others with 401.
+// token is called on every request, like that of New.
+func RequireToken(token func() (string, error), next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if authorized(w, r, token) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// authorized reports whether r carries the bearer token returned by token,
+// answering it with an error if not.
+func authorized(w http.ResponseWriter, r *http.Request, token func() (string, error)) bool {
+	want, err := token()
+	if err != nil {
+		slog.Error("failed to read the admin token", "error", err)
+		http.Error(w, "admin token unavailable", http.StatusInternalServerError)
+		return false
+	}
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(want)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
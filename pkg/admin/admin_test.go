@@ -0,0 +1,117 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/opencosttest"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+// fakeCache records the calls and answers with data, or fails if nil.
+type fakeCache struct {
+	data             *types.CloudCostResponse
+	flushes, refresh int
+}
+
+func (c *fakeCache) Flush(context.Context) (*types.CloudCostResponse, bool) {
+	c.flushes++
+	return c.data, c.data != nil
+}
+
+func (c *fakeCache) Refresh(context.Context) (*types.CloudCostResponse, bool) {
+	c.refresh++
+	return c.data, c.data != nil
+}
+
+func token() (string, error) { return "s3cret", nil }
+
+func request(h http.Handler, method, path, auth string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, nil)
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandler(t *testing.T) {
+	data := opencosttest.Response(
+		opencosttest.Item("111", "AmazonEC2", "Compute", 1),
+		opencosttest.Item("222", "AmazonS3", "Storage", 2),
+	)
+	window := types.Window{Start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)}
+	data.Data.Sets[0].Window = window
+	cache := &fakeCache{data: data}
+	h := New(cache, token)
+
+	for _, path := range []string{FlushPath, RefreshPath} {
+		rec := request(h, http.MethodPost, path, "Bearer s3cret")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, want 200: %s", path, rec.Code, rec.Body)
+		}
+		var resp Response
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: decode response: %v", path, err)
+		}
+		if want := (Response{Window: window, Sets: 1, Items: 2}); resp != want {
+			t.Errorf("%s: response = %+v, want %+v", path, resp, want)
+		}
+	}
+	if cache.flushes != 1 || cache.refresh != 1 {
+		t.Errorf("flushes = %d, refreshes = %d, want 1 each", cache.flushes, cache.refresh)
+	}
+}
+
+func TestHandler_Errors(t *testing.T) {
+	tests := []struct {
+		name               string
+		cache              *fakeCache
+		token              func() (string, error)
+		method, path, auth string
+		want               int
+	}{
+		{"no token", &fakeCache{}, token, http.MethodPost, FlushPath, "", http.StatusUnauthorized},
+		{"wrong token", &fakeCache{}, token, http.MethodPost, FlushPath, "Bearer guess", http.StatusUnauthorized},
+		{"basic auth", &fakeCache{}, token, http.MethodPost, FlushPath, "Basic czNjcmV0", http.StatusUnauthorized},
+		{"GET", &fakeCache{}, token, http.MethodGet, RefreshPath, "Bearer s3cret", http.StatusMethodNotAllowed},
+		{"unknown path", &fakeCache{}, token, http.MethodPost, "/-/cache/drop", "Bearer s3cret", http.StatusNotFound},
+		{"fetch fails", &fakeCache{}, token, http.MethodPost, RefreshPath, "Bearer s3cret", http.StatusBadGateway},
+		{"token unreadable", &fakeCache{}, func() (string, error) { return "", errors.New("missing") }, http.MethodPost, FlushPath, "Bearer s3cret", http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := request(New(tt.cache, tt.token), tt.method, tt.path, tt.auth)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusUnauthorized && (tt.cache.flushes != 0 || tt.cache.refresh != 0) {
+				t.Error("unauthorized request reached the cache")
+			}
+		})
+	}
+}
+
+func TestRequireToken(t *testing.T) {
+	var served int
+	h := RequireToken(token, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+	}))
+	for _, auth := range []string{"", "Bearer guess", "Basic czNjcmV0"} {
+		if rec := request(h, http.MethodPost, "/-/reload", auth); rec.Code != http.StatusUnauthorized {
+			t.Errorf("%q: status = %d, want 401", auth, rec.Code)
+		}
+	}
+	if served != 0 {
+		t.Fatal("unauthorized request reached the handler")
+	}
+	if rec := request(h, http.MethodPost, "/-/reload", "Bearer s3cret"); rec.Code != http.StatusOK || served != 1 {
+		t.Errorf("status = %d, served = %d, want the request passed on", rec.Code, served)
+	}
+}
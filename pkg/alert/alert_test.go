@@ -0,0 +1,381 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/secret"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+func item(account, service string, cost float64) types.CloudCostItem {
+	return types.CloudCostItem{
+		Properties:       types.CloudCostProperties{AccountID: account, Service: service, Labels: map[string]string{"team": "team-" + account}},
+		ListCost:         types.CostValue{Cost: cost * 2},
+		AmortizedNetCost: types.CostValue{Cost: cost},
+	}
+}
+
+func response(items ...types.CloudCostItem) *types.CloudCostResponse {
+	m := make(map[string]types.CloudCostItem, len(items))
+	for i, it := range items {
+		m[string(rune('a'+i))] = it
+	}
+	return &types.CloudCostResponse{Data: types.CloudCostData{Sets: []types.CloudCostSet{{CloudCosts: m}}}}
+}
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Rule
+		wantErr bool
+	}{
+		{
+			in:   "big-account: account_id=123 amortized_net > 5000",
+			want: Rule{Name: "big-account", Matchers: map[string]string{"account_id": "123"}, CostType: "amortized_net", Op: ">", Threshold: 5000},
+		},
+		{
+			in:   "list by service,region > $1000.5",
+			want: Rule{Name: "list by service,region > $1000.5", CostType: "list", GroupBy: []string{"service", "region"}, Op: ">", Threshold: 1000.5},
+		},
+		{
+			in:   "account_id=1,team=web net < 1",
+			want: Rule{Name: "account_id=1,team=web net < 1", Matchers: map[string]string{"account_id": "1", "team": "web"}, CostType: "net", Op: "<", Threshold: 1},
+		},
+		{in: "amortized_net >= 5", wantErr: true},
+		{in: "bogus > 5", wantErr: true},
+		{in: "list > five", wantErr: true},
+		{in: "=x list > 5", wantErr: true},
+		{in: "list", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseRule(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRule() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules("a: list > 1; ; b: net < 2")
+	if err != nil {
+		t.Fatalf("ParseRules() error = %v", err)
+	}
+	if len(rules) != 2 || rules[0].Name != "a" || rules[1].Name != "b" {
+		t.Errorf("ParseRules() = %+v", rules)
+	}
+	if _, err := ParseRules("list > 1; nope"); err == nil {
+		t.Error("ParseRules() should fail on an invalid rule")
+	}
+}
+
+func TestEngine_Evaluate(t *testing.T) {
+	data := response(item("123", "AmazonEC2", 4000), item("123", "AmazonS3", 2000), item("456", "AmazonEC2", 100))
+
+	tests := []struct {
+		rule       string
+		wantAlerts int
+		wantValue  float64
+	}{
+		{"account_id=123 amortized_net > 5000", 1, 6000},
+		{"account_id=123 amortized_net > 7000", 0, 0},
+		{"account_id=123 list > 7000", 1, 12000},
+		{"team=team-456 amortized_net < 500", 1, 100},
+		{"amortized_net by account_id > 50", 2, 6000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			r, err := ParseRule(tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			alerts := NewEngine([]Rule{r}).Evaluate(data)
+			if len(alerts) != tt.wantAlerts {
+				t.Fatalf("got %d alerts, want %d", len(alerts), tt.wantAlerts)
+			}
+			if tt.wantAlerts > 0 && alerts[0].Value != tt.wantValue {
+				t.Errorf("value = %v, want %v", alerts[0].Value, tt.wantValue)
+			}
+		})
+	}
+
+	r, _ := ParseRule("account_id=123 amortized_net > 5000")
+	a := NewEngine([]Rule{r}).Evaluate(data)[0]
+	if len(a.Series) != 2 || a.Series[0].Labels["service"] != "AmazonEC2" || a.Series[0].Cost != 4000 {
+		t.Errorf("series = %+v, want AmazonEC2 first", a.Series)
+	}
+}
+
+type recorder struct{ batches [][]Alert }
+
+func (r *recorder) Notify(_ context.Context, alerts []Alert) error {
+	r.batches = append(r.batches, alerts)
+	return nil
+}
+
+func TestEngine_Hook(t *testing.T) {
+	r, _ := ParseRule("spend: amortized_net > 100")
+	rec := &recorder{}
+	e := NewEngine([]Rule{r}, rec)
+	e.now = func() time.Time { return time.Unix(1000, 0) }
+
+	high := response(item("1", "AmazonEC2", 150))
+	low := response(item("1", "AmazonEC2", 50))
+
+	e.Hook(context.Background(), high)
+	e.Hook(context.Background(), high)
+	e.Hook(context.Background(), low)
+	e.Hook(context.Background(), low)
+
+	if len(rec.batches) != 2 {
+		t.Fatalf("got %d notifications, want 2 (firing, resolved)", len(rec.batches))
+	}
+	if got := rec.batches[0][0]; got.Status != StatusFiring || got.StartsAt.Unix() != 1000 {
+		t.Errorf("first notification = %+v, want firing", got)
+	}
+	if got := rec.batches[1][0]; got.Status != StatusResolved || got.EndsAt.IsZero() {
+		t.Errorf("second notification = %+v, want resolved", got)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var got struct {
+		Alerts []Alert `json:"alerts"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Content-Type = %q", r.Header.Get("Content-Type"))
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer server.Close()
+
+	n := &WebhookNotifier{URL: server.URL}
+	alerts := []Alert{{Rule: "spend", Status: StatusFiring, Value: 150, Series: []Series{{Labels: map[string]string{"service": "AmazonEC2"}, Cost: 150}}}}
+	if err := n.Notify(context.Background(), alerts); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if len(got.Alerts) != 1 || got.Alerts[0].Rule != "spend" || got.Alerts[0].Series[0].Labels["service"] != "AmazonEC2" {
+		t.Errorf("unexpected payload %+v", got)
+	}
+
+	// The URL may carry a token and must not leak into logged errors.
+	server.Close()
+	if err := n.Notify(context.Background(), alerts); err == nil || strings.Contains(err.Error(), server.URL) {
+		t.Errorf("Notify() to a closed server error = %v, want an error without the URL", err)
+	}
+}
+
+func testAlert() Alert {
+	return Alert{
+		Rule:      "web-spend",
+		Expr:      "team=web amortized_net > 100",
+		Status:    StatusFiring,
+		CostType:  "amortized_net",
+		Value:     180,
+		Threshold: 100,
+		Series: []Series{
+			{Labels: map[string]string{"service": "AmazonEC2", "account_id": "1"}, Cost: 90},
+			{Labels: map[string]string{"service": "AmazonS3"}, Cost: 30},
+			{Labels: map[string]string{"service": "AmazonEC2", "account_id": "2"}, Cost: 60},
+		},
+	}
+}
+
+func TestAlert_TopServices(t *testing.T) {
+	got := testAlert().TopServices(1)
+	if len(got) != 1 || got[0].Service != "AmazonEC2" || got[0].Cost != 150 {
+		t.Errorf("TopServices(1) = %+v, want AmazonEC2 150", got)
+	}
+}
+
+func TestChatNotifiers(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+	}))
+	defer server.Close()
+
+	custom, err := ParseMessageTemplate(`{{.Rule}} {{range .TopServices 1}}{{.Service}}={{money .Cost}}{{end}}`)
+	if err != nil {
+		t.Fatalf("ParseMessageTemplate() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		notifier Notifier
+		text     func() string
+		want     string
+	}{
+		{
+			name:     "slack default template",
+			notifier: &SlackNotifier{URL: server.URL},
+			text:     func() string { return body["text"].(string) },
+			want:     "*web-spend* is firing\namortized_net cost $180.00 (threshold team=web amortized_net > 100)\n• AmazonEC2: $150.00\n• AmazonS3: $30.00",
+		},
+		{
+			name:     "slack custom template",
+			notifier: &SlackNotifier{URL: server.URL, Template: custom},
+			text:     func() string { return body["text"].(string) },
+			want:     "web-spend AmazonEC2=$150.00",
+		},
+		{
+			name:     "teams",
+			notifier: &TeamsNotifier{URL: server.URL, Template: custom},
+			text: func() string {
+				card := body["attachments"].([]any)[0].(map[string]any)["content"].(map[string]any)
+				return card["body"].([]any)[0].(map[string]any)["text"].(string)
+			},
+			want: "web-spend AmazonEC2=$150.00",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body = nil
+			if err := tt.notifier.Notify(context.Background(), []Alert{testAlert()}); err != nil {
+				t.Fatalf("Notify() error = %v", err)
+			}
+			if got := tt.text(); !strings.Contains(got, tt.want) {
+				t.Errorf("message = %q, want it to contain %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleFilter(t *testing.T) {
+	rec := &recorder{}
+	f := &RuleFilter{Rules: []string{"web-spend"}, Notifier: rec}
+
+	other := testAlert()
+	other.Rule = "data-spend"
+	f.Notify(context.Background(), []Alert{other})
+	f.Notify(context.Background(), []Alert{other, testAlert()})
+
+	if len(rec.batches) != 1 || len(rec.batches[0]) != 1 || rec.batches[0][0].Rule != "web-spend" {
+		t.Errorf("batches = %+v, want only web-spend", rec.batches)
+	}
+}
+
+func TestParseBudget(t *testing.T) {
+	rules, err := ParseBudget("prod: account_id=123 amortized_net 10000", DefaultLevels)
+	if err != nil {
+		t.Fatalf("ParseBudget() error = %v", err)
+	}
+	if len(rules) != 3 {
+		t.Fatalf("got %d rules, want 3", len(rules))
+	}
+	want := []struct {
+		threshold float64
+		severity  string
+	}{{8000, "warning"}, {10000, "error"}, {12000, "critical"}}
+	for i, w := range want {
+		r := rules[i]
+		if r.Name != "prod" || r.Budget != 10000 || r.Threshold != w.threshold || r.Severity != w.severity || r.Matchers["account_id"] != "123" {
+			t.Errorf("rule %d = %+v", i, r)
+		}
+	}
+
+	for _, bad := range []string{"amortized_net", "prod: amortized_net lots", "prod: bogus 100"} {
+		if _, err := ParseBudget(bad, DefaultLevels); err == nil {
+			t.Errorf("ParseBudget(%q) expected error", bad)
+		}
+	}
+}
+
+func TestParseLevels(t *testing.T) {
+	levels, err := ParseLevels("50%=info, 90=critical")
+	if err != nil {
+		t.Fatalf("ParseLevels() error = %v", err)
+	}
+	if !reflect.DeepEqual(levels, []Level{{50, "info"}, {90, "critical"}}) {
+		t.Errorf("ParseLevels() = %+v", levels)
+	}
+	for _, bad := range []string{"80", "x=warning", "80=sev1"} {
+		if _, err := ParseLevels(bad); err == nil {
+			t.Errorf("ParseLevels(%q) expected error", bad)
+		}
+	}
+}
+
+func TestEngine_BudgetLevels(t *testing.T) {
+	rules, _ := ParseBudgets("prod: amortized_net 100", DefaultLevels)
+	alerts := NewEngine(rules).Evaluate(response(item("1", "AmazonEC2", 105)))
+
+	if len(alerts) != 2 {
+		t.Fatalf("got %d alerts, want 2 (80%% and 100%%)", len(alerts))
+	}
+	if DedupKey(alerts[0]) == DedupKey(alerts[1]) {
+		t.Error("budget levels should have distinct dedup keys")
+	}
+	if again := NewEngine(rules).Evaluate(response(item("1", "AmazonEC2", 105))); DedupKey(again[0]) != DedupKey(alerts[0]) {
+		t.Error("dedup key should be stable across evaluations")
+	}
+}
+
+func TestIncidentNotifiers(t *testing.T) {
+	type request struct {
+		path, auth string
+		body       map[string]any
+	}
+	var got []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		got = append(got, request{r.URL.RequestURI(), r.Header.Get("Authorization"), body})
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	firing := Alert{Rule: "prod", Expr: "amortized_net > 100", Status: StatusFiring, CostType: "amortized_net", Value: 105, Threshold: 100, Budget: 100, Severity: "error"}
+	resolved := firing
+	resolved.Status = StatusResolved
+	plain := Alert{Rule: "threshold", Status: StatusFiring}
+
+	t.Run("pagerduty", func(t *testing.T) {
+		got = nil
+		n := &PagerDutyNotifier{RoutingKey: secret.Inline("rk"), URL: server.URL + "/v2/enqueue"}
+		if err := n.Notify(context.Background(), []Alert{firing, plain, resolved}); err != nil {
+			t.Fatalf("Notify() error = %v", err)
+		}
+		if len(got) != 2 {
+			t.Fatalf("got %d events, want 2 (non-budget alerts are skipped)", len(got))
+		}
+		if got[0].body["event_action"] != "trigger" || got[0].body["routing_key"] != "rk" || got[0].body["dedup_key"] != DedupKey(firing) {
+			t.Errorf("trigger event = %+v", got[0].body)
+		}
+		if payload := got[0].body["payload"].(map[string]any); payload["severity"] != "error" || !strings.Contains(payload["summary"].(string), "105%") {
+			t.Errorf("payload = %+v", payload)
+		}
+		if got[1].body["event_action"] != "resolve" || got[1].body["dedup_key"] != DedupKey(firing) {
+			t.Errorf("resolve event = %+v", got[1].body)
+		}
+	})
+
+	t.Run("opsgenie", func(t *testing.T) {
+		got = nil
+		n := &OpsgenieNotifier{APIKey: secret.Inline("key"), URL: server.URL}
+		if err := n.Notify(context.Background(), []Alert{firing, plain, resolved}); err != nil {
+			t.Fatalf("Notify() error = %v", err)
+		}
+		if len(got) != 2 {
+			t.Fatalf("got %d requests, want 2", len(got))
+		}
+		if got[0].path != "/v2/alerts" || got[0].auth != "GenieKey key" || got[0].body["priority"] != "P2" || got[0].body["alias"] != DedupKey(firing) {
+			t.Errorf("create request = %+v", got[0])
+		}
+		if want := "/v2/alerts/" + DedupKey(firing) + "/close?identifierType=alias"; got[1].path != want {
+			t.Errorf("close path = %s, want %s", got[1].path, want)
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package alert
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Level is a budget consumption percentage and the severity raised when it
+// is crossed.
+type Level struct {
+	Percent  float64
+	Severity string
+}
+
+// DefaultLevels are the budget levels used when none are configured.
+var DefaultLevels = []Level{
+	{Percent: 80, Severity: "warning"},
+	{Percent: 100, Severity: "error"},
+	{Percent: 120, Severity: "critical"},
+}
+
+// ParseLevels parses levels of the form "80=warning,100=error,120=critical".
+// Valid severities are info, warning, error and critical.
+func ParseLevels(s string) ([]Level, error) {
+	var levels []Level
+	for _, part := range splitTrim(s, ",") {
+		pct, severity, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid budget level %q: want percent=severity", part)
+		}
+		p, err := strconv.ParseFloat(strings.TrimSuffix(pct, "%"), 64)
+		if err != nil || p <= 0 {
+			return nil, fmt.Errorf("invalid budget level %q: bad percentage", part)
+		}
+		if !slices.Contains([]string{"info", "warning", "error", "critical"}, severity) {
+			return nil, fmt.Errorf("invalid budget level %q: unknown severity %q", part, severity)
+		}
+		levels = append(levels, Level{Percent: p, Severity: severity})
+	}
+	return levels, nil
+}
+
+// ParseBudget parses a budget of the form
+//
+//	[name:] [key=value,...] <cost_type> [by key,...] <amount>
+//
+// and expands it into one rule per level, firing when spend exceeds the
+// level's share of the amount.
+func ParseBudget(s string, levels []Level) ([]Rule, error) {
+	s = strings.TrimSpace(s)
+	i := strings.LastIndexAny(s, " \t")
+	if i < 0 {
+		return nil, fmt.Errorf("invalid budget %q: want [key=value,...] <cost_type> [by key,...] <amount>", s)
+	}
+	base, err := ParseRule(s[:i] + " > " + s[i+1:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid budget %q: %w", s, err)
+	}
+	if base.Name == s[:i]+" > "+s[i+1:] {
+		base.Name = s
+	}
+
+	rules := make([]Rule, 0, len(levels))
+	for _, l := range levels {
+		r := base
+		r.Budget = base.Threshold
+		r.Threshold = base.Threshold * l.Percent / 100
+		r.Severity = l.Severity
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// ParseBudgets parses semicolon-separated budgets, see ParseBudget.
+func ParseBudgets(s string, levels []Level) ([]Rule, error) {
+	var rules []Rule
+	for _, part := range splitTrim(s, ";") {
+		r, err := ParseBudget(part, levels)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r...)
+	}
+	return rules, nil
+}
+
+func splitTrim(s, sep string) []string {
+	var out []string
+	for _, part := range strings.Split(s, sep) {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
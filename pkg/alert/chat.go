@@ -0,0 +1,90 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"text/template"
+)
+
+// SlackNotifier posts alerts to a Slack incoming webhook.
+type SlackNotifier struct {
+	URL string
+	// Template renders each alert; nil uses DefaultMessageTemplate.
+	Template *template.Template
+	Client   *http.Client
+}
+
+// Notify implements Notifier.
+func (n *SlackNotifier) Notify(ctx context.Context, alerts []Alert) error {
+	text, err := renderMessages(n.Template, alerts)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return fmt.Errorf("encode slack message: %w", err)
+	}
+	return postJSON(ctx, n.Client, n.URL, body, nil)
+}
+
+// TeamsNotifier posts alerts to a Microsoft Teams workflow webhook as an
+// Adaptive Card.
+type TeamsNotifier struct {
+	URL string
+	// Template renders each alert; nil uses DefaultMessageTemplate.
+	Template *template.Template
+	Client   *http.Client
+}
+
+// Notify implements Notifier.
+func (n *TeamsNotifier) Notify(ctx context.Context, alerts []Alert) error {
+	text, err := renderMessages(n.Template, alerts)
+	if err != nil {
+		return err
+	}
+	card := map[string]any{
+		"type": "message",
+		"attachments": []map[string]any{{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content": map[string]any{
+				"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+				"type":    "AdaptiveCard",
+				"version": "1.4",
+				"body": []map[string]any{{
+					"type": "TextBlock",
+					"text": text,
+					"wrap": true,
+				}},
+			},
+		}},
+	}
+	body, err := json.Marshal(card)
+	if err != nil {
+		return fmt.Errorf("encode teams message: %w", err)
+	}
+	return postJSON(ctx, n.Client, n.URL, body, nil)
+}
+
+// RuleFilter forwards only alerts of the listed rules, e.g. to route a
+// team's rules to the team's channel.
+type RuleFilter struct {
+	Rules    []string
+	Notifier Notifier
+}
+
+// Notify implements Notifier.
+func (f *RuleFilter) Notify(ctx context.Context, alerts []Alert) error {
+	var matched []Alert
+	for _, a := range alerts {
+		if slices.Contains(f.Rules, a.Rule) {
+			matched = append(matched, a)
+		}
+	}
+	if len(matched) == 0 {
+		return nil
+	}
+	return f.Notifier.Notify(ctx, matched)
+}
@@ -0,0 +1,233 @@
+package alert
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+// maxSeries caps the offending series included in a notification.
+const maxSeries = 20
+
+// Alert statuses.
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+)
+
+// Series is a single cost item contributing to an alert.
+type Series struct {
+	Labels map[string]string `json:"labels"`
+	Cost   float64           `json:"cost"`
+}
+
+// Alert is a rule violation, or its resolution.
+type Alert struct {
+	Rule      string            `json:"rule"`
+	Expr      string            `json:"expr"`
+	Status    string            `json:"status"`
+	Group     map[string]string `json:"group,omitempty"`
+	CostType  string            `json:"cost_type"`
+	Value     float64           `json:"value"`
+	Threshold float64           `json:"threshold"`
+	Severity  string            `json:"severity,omitempty"`
+	Budget    float64           `json:"budget,omitempty"`
+	StartsAt  time.Time         `json:"starts_at"`
+	EndsAt    time.Time         `json:"ends_at,omitzero"`
+	// Series are the largest contributing items, most expensive first.
+	Series []Series `json:"series,omitempty"`
+}
+
+// Fingerprint identifies an alert across evaluations.
+func (a Alert) Fingerprint() string {
+	keys := make([]string, 0, len(a.Group))
+	for k := range a.Group {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	var b strings.Builder
+	b.WriteString(a.Rule + "\x00" + a.Expr)
+	for _, k := range keys {
+		b.WriteString("\x00" + k + "=" + a.Group[k])
+	}
+	return b.String()
+}
+
+// Notifier delivers alert state changes.
+type Notifier interface {
+	Notify(ctx context.Context, alerts []Alert) error
+}
+
+// Engine evaluates rules after each refresh and notifies on state changes:
+// once when an alert starts firing and once when it resolves.
+type Engine struct {
+	rules     []Rule
+	notifiers []Notifier
+
+	mu     sync.Mutex
+	active map[string]Alert
+	now    func() time.Time
+}
+
+// NewEngine creates an engine for the given rules.
+func NewEngine(rules []Rule, notifiers ...Notifier) *Engine {
+	return &Engine{
+		rules:     rules,
+		notifiers: notifiers,
+		active:    make(map[string]Alert),
+		now:       time.Now,
+	}
+}
+
+// Evaluate returns the alerts violated by data, without changing state.
+func (e *Engine) Evaluate(data *types.CloudCostResponse) []Alert {
+	var alerts []Alert
+	for _, r := range e.rules {
+		alerts = append(alerts, evaluate(r, data)...)
+	}
+	return alerts
+}
+
+// Hook evaluates data and notifies about alerts that started firing or
+// resolved since the previous evaluation. It matches collector.RefreshHook.
+func (e *Engine) Hook(ctx context.Context, data *types.CloudCostResponse) {
+	changes := e.update(e.Evaluate(data))
+	if len(changes) == 0 {
+		return
+	}
+
+	for _, a := range changes {
+		slog.InfoContext(ctx, "cost alert "+a.Status, "rule", a.Rule, "group", a.Group, "value", a.Value, "threshold", a.Threshold)
+	}
+	for _, n := range e.notifiers {
+		if err := n.Notify(ctx, changes); err != nil {
+			slog.ErrorContext(ctx, "failed to send cost alert notification", "error", err)
+		}
+	}
+}
+
+// update records the current alerts and returns the state changes.
+func (e *Engine) update(current []Alert) []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.now()
+	seen := make(map[string]bool, len(current))
+	var changes []Alert
+	for _, a := range current {
+		fp := a.Fingerprint()
+		seen[fp] = true
+		if prev, ok := e.active[fp]; ok {
+			a.StartsAt = prev.StartsAt
+			e.active[fp] = a
+			continue
+		}
+		a.StartsAt = now
+		e.active[fp] = a
+		changes = append(changes, a)
+	}
+	for fp, a := range e.active {
+		if seen[fp] {
+			continue
+		}
+		delete(e.active, fp)
+		a.Status = StatusResolved
+		a.EndsAt = now
+		changes = append(changes, a)
+	}
+	return changes
+}
+
+func evaluate(r Rule, data *types.CloudCostResponse) []Alert {
+	if data == nil {
+		return nil
+	}
+
+	type group struct {
+		values map[string]string
+		total  float64
+		series []Series
+	}
+	groups := make(map[string]*group)
+	for _, s := range data.Data.Sets {
+		for _, item := range s.CloudCosts {
+			if !matches(r.Matchers, item.Properties) {
+				continue
+			}
+			values := make(map[string]string, len(r.GroupBy))
+			keyParts := make([]string, len(r.GroupBy))
+			for i, k := range r.GroupBy {
+				values[k] = dimension(item.Properties, k)
+				keyParts[i] = values[k]
+			}
+			key := strings.Join(keyParts, "\x00")
+			g, ok := groups[key]
+			if !ok {
+				g = &group{values: values}
+				groups[key] = g
+			}
+			cost, _ := item.CostByType(r.CostType)
+			g.total += cost.Cost
+			g.series = append(g.series, Series{Labels: seriesLabels(item.Properties), Cost: cost.Cost})
+		}
+	}
+
+	var alerts []Alert
+	for _, g := range groups {
+		if !(r.Op == ">" && g.total > r.Threshold) && !(r.Op == "<" && g.total < r.Threshold) {
+			continue
+		}
+		slices.SortFunc(g.series, func(a, b Series) int { return cmp.Compare(b.Cost, a.Cost) })
+		if len(g.series) > maxSeries {
+			g.series = g.series[:maxSeries]
+		}
+		a := Alert{
+			Rule:      r.Name,
+			Expr:      r.String(),
+			Status:    StatusFiring,
+			CostType:  r.CostType,
+			Value:     g.total,
+			Threshold: r.Threshold,
+			Severity:  r.Severity,
+			Budget:    r.Budget,
+			Series:    g.series,
+		}
+		if len(g.values) > 0 {
+			a.Group = g.values
+		}
+		alerts = append(alerts, a)
+	}
+	slices.SortFunc(alerts, func(a, b Alert) int { return cmp.Compare(a.Fingerprint(), b.Fingerprint()) })
+	return alerts
+}
+
+func matches(matchers map[string]string, p types.CloudCostProperties) bool {
+	for k, v := range matchers {
+		if dimension(p, k) != v {
+			return false
+		}
+	}
+	return true
+}
+
+func seriesLabels(p types.CloudCostProperties) map[string]string {
+	labels := map[string]string{
+		"provider_id": p.ProviderID,
+		"account_id":  p.AccountID,
+		"service":     p.Service,
+		"category":    p.Category,
+		"region":      p.RegionID,
+	}
+	for k, v := range labels {
+		if v == "" {
+			delete(labels, k)
+		}
+	}
+	return labels
+}
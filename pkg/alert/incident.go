@@ -0,0 +1,158 @@
+package alert
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/secret"
+)
+
+// Default incident management API endpoints.
+const (
+	DefaultPagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+	DefaultOpsgenieURL  = "https://api.opsgenie.com"
+)
+
+// DedupKey returns a stable key for an alert, so that incidents raised for
+// the same budget level and group are deduplicated across refreshes and
+// restarts.
+func DedupKey(a Alert) string {
+	sum := sha256.Sum256([]byte(a.Fingerprint()))
+	return "cloudcost-" + hex.EncodeToString(sum[:12])
+}
+
+func budgetSummary(a Alert) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Budget %s at %.0f%% (%.2f of %.2f %s)", a.Rule, a.Value/a.Budget*100, a.Value, a.Budget, a.CostType)
+	for k, v := range a.Group {
+		fmt.Fprintf(&b, " %s=%s", k, v)
+	}
+	return b.String()
+}
+
+// PagerDutyNotifier sends budget alerts to the PagerDuty Events API v2.
+// Alerts not derived from a budget are ignored.
+type PagerDutyNotifier struct {
+	RoutingKey *secret.Value
+	// URL defaults to DefaultPagerDutyURL.
+	URL    string
+	Client *http.Client
+}
+
+// Notify implements Notifier.
+func (n *PagerDutyNotifier) Notify(ctx context.Context, alerts []Alert) error {
+	endpoint := n.URL
+	if endpoint == "" {
+		endpoint = DefaultPagerDutyURL
+	}
+
+	routingKey, err := n.RoutingKey.Get()
+	if err != nil {
+		return fmt.Errorf("pagerduty: routing key: %w", err)
+	}
+
+	var errs []error
+	for _, a := range alerts {
+		if a.Budget == 0 {
+			continue
+		}
+		event := map[string]any{
+			"routing_key":  routingKey,
+			"event_action": "trigger",
+			"dedup_key":    DedupKey(a),
+		}
+		if a.Status == StatusResolved {
+			event["event_action"] = "resolve"
+		} else {
+			event["payload"] = map[string]any{
+				"summary":        budgetSummary(a),
+				"source":         "opencost-cloudcost-exporter",
+				"severity":       a.Severity,
+				"timestamp":      a.StartsAt,
+				"custom_details": a,
+			}
+		}
+		body, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("encode pagerduty event: %w", err)
+		}
+		if err := postJSON(ctx, n.Client, endpoint, body, nil); err != nil {
+			errs = append(errs, fmt.Errorf("pagerduty: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// opsgeniePriority maps severities to Opsgenie priorities.
+var opsgeniePriority = map[string]string{
+	"critical": "P1",
+	"error":    "P2",
+	"warning":  "P3",
+	"info":     "P5",
+}
+
+// OpsgenieNotifier creates and closes Opsgenie alerts for budget alerts.
+// Alerts not derived from a budget are ignored.
+type OpsgenieNotifier struct {
+	APIKey *secret.Value
+	// URL is the API base URL; defaults to DefaultOpsgenieURL. Use
+	// https://api.eu.opsgenie.com for the EU instance.
+	URL    string
+	Client *http.Client
+}
+
+// Notify implements Notifier.
+func (n *OpsgenieNotifier) Notify(ctx context.Context, alerts []Alert) error {
+	base := strings.TrimSuffix(n.URL, "/")
+	if base == "" {
+		base = DefaultOpsgenieURL
+	}
+
+	apiKey, err := n.APIKey.Get()
+	if err != nil {
+		return fmt.Errorf("opsgenie: API key: %w", err)
+	}
+
+	var errs []error
+	for _, a := range alerts {
+		if a.Budget == 0 {
+			continue
+		}
+		alias := DedupKey(a)
+		endpoint := base + "/v2/alerts"
+		var payload map[string]any
+		if a.Status == StatusResolved {
+			endpoint = fmt.Sprintf("%s/v2/alerts/%s/close?identifierType=alias", base, url.PathEscape(alias))
+			payload = map[string]any{"source": "opencost-cloudcost-exporter"}
+		} else {
+			details := map[string]string{"rule": a.Rule, "cost_type": a.CostType}
+			for k, v := range a.Group {
+				details[k] = v
+			}
+			payload = map[string]any{
+				"message":  budgetSummary(a),
+				"alias":    alias,
+				"priority": opsgeniePriority[a.Severity],
+				"source":   "opencost-cloudcost-exporter",
+				"tags":     []string{"cloudcost", "budget", a.Severity},
+				"details":  details,
+			}
+		}
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("encode opsgenie alert: %w", err)
+		}
+		header := http.Header{"Authorization": {"GenieKey " + apiKey}}
+		if err := postJSON(ctx, n.Client, endpoint, body, header); err != nil {
+			errs = append(errs, fmt.Errorf("opsgenie: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,85 @@
+package alert
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// DefaultMessageTemplate is the chat message rendered for each alert. It is
+// executed with an Alert and may use the money function and the
+// TopServices method.
+const DefaultMessageTemplate = `{{if eq .Status "firing"}}:rotating_light: *{{.Rule}}* is firing{{else}}:white_check_mark: *{{.Rule}}* resolved{{end}}
+{{.CostType}} cost {{money .Value}} (threshold {{.Expr}})
+{{- range $k, $v := .Group}} {{$k}}={{$v}}{{end}}
+{{- if eq .Status "firing"}}{{range .TopServices 5}}
+• {{.Service}}: {{money .Cost}}{{end}}{{end}}`
+
+// ServiceCost is the cost of one service within an alert.
+type ServiceCost struct {
+	Service string
+	Cost    float64
+}
+
+// TopServices sums the alert's series by service and returns the n most
+// expensive.
+func (a Alert) TopServices(n int) []ServiceCost {
+	totals := make(map[string]float64)
+	for _, s := range a.Series {
+		totals[s.Labels["service"]] += s.Cost
+	}
+	out := make([]ServiceCost, 0, len(totals))
+	for svc, cost := range totals {
+		if svc == "" {
+			svc = "(unknown)"
+		}
+		out = append(out, ServiceCost{Service: svc, Cost: cost})
+	}
+	slices.SortFunc(out, func(a, b ServiceCost) int {
+		if c := cmp.Compare(b.Cost, a.Cost); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Service, b.Service)
+	})
+	if len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
+
+// ParseMessageTemplate parses a chat message template; an empty text selects
+// DefaultMessageTemplate.
+func ParseMessageTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		text = DefaultMessageTemplate
+	}
+	t, err := template.New("message").Funcs(template.FuncMap{
+		"money": func(v float64) string { return fmt.Sprintf("$%.2f", v) },
+	}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse message template: %w", err)
+	}
+	return t, nil
+}
+
+// renderMessages renders one message per alert and joins them.
+func renderMessages(t *template.Template, alerts []Alert) (string, error) {
+	if t == nil {
+		var err error
+		if t, err = ParseMessageTemplate(""); err != nil {
+			return "", err
+		}
+	}
+	parts := make([]string, 0, len(alerts))
+	for _, a := range alerts {
+		var buf bytes.Buffer
+		if err := t.Execute(&buf, a); err != nil {
+			return "", fmt.Errorf("render message: %w", err)
+		}
+		parts = append(parts, buf.String())
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
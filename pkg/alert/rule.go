@@ -0,0 +1,150 @@
+// Package alert evaluates cost threshold rules against freshly fetched cloud
+// costs and notifies webhooks, Slack or Microsoft Teams when rules start or
+// stop firing.
+package alert
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+// Rule compares the summed cost of matching items against a threshold.
+type Rule struct {
+	// Name identifies the rule in notifications.
+	Name string
+	// Matchers restrict the rule to items whose dimension equals the value.
+	// Keys are property names (account_id, service, ...) or provider labels.
+	Matchers map[string]string
+	// CostType is the cost compared, e.g. amortized_net.
+	CostType string
+	// GroupBy evaluates the rule separately per distinct value combination.
+	GroupBy []string
+	// Op is ">" or "<".
+	Op string
+	// Threshold is the amount the cost is compared against.
+	Threshold float64
+	// Severity is attached to alerts of budget rules, see Budget.
+	Severity string
+	// Budget is the budget amount the threshold was derived from, if any.
+	Budget float64
+}
+
+// String renders the rule in the syntax accepted by ParseRule.
+func (r Rule) String() string {
+	var parts []string
+	if len(r.Matchers) > 0 {
+		keys := make([]string, 0, len(r.Matchers))
+		for k := range r.Matchers {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		m := make([]string, len(keys))
+		for i, k := range keys {
+			m[i] = k + "=" + r.Matchers[k]
+		}
+		parts = append(parts, strings.Join(m, ","))
+	}
+	parts = append(parts, r.CostType)
+	if len(r.GroupBy) > 0 {
+		parts = append(parts, "by", strings.Join(r.GroupBy, ","))
+	}
+	parts = append(parts, r.Op, strconv.FormatFloat(r.Threshold, 'f', -1, 64))
+	return strings.Join(parts, " ")
+}
+
+// ParseRule parses a rule of the form
+//
+//	[name:] [key=value,...] <cost_type> [by key,...] <op> <threshold>
+//
+// for example "big-account: account_id=123 amortized_net > 5000" or
+// "list by service > 1000". The name defaults to the rule text.
+func ParseRule(s string) (Rule, error) {
+	s = strings.TrimSpace(s)
+	var r Rule
+	if name, rest, ok := strings.Cut(s, ":"); ok && !strings.ContainsAny(name, " =") {
+		r.Name = strings.TrimSpace(name)
+		s = strings.TrimSpace(rest)
+	}
+	if r.Name == "" {
+		r.Name = s
+	}
+
+	fields := strings.Fields(s)
+	if len(fields) > 0 && strings.Contains(fields[0], "=") {
+		r.Matchers = make(map[string]string)
+		for _, m := range strings.Split(fields[0], ",") {
+			k, v, ok := strings.Cut(m, "=")
+			if !ok || k == "" {
+				return Rule{}, fmt.Errorf("invalid matcher %q in rule %q", m, s)
+			}
+			r.Matchers[k] = v
+		}
+		fields = fields[1:]
+	}
+
+	if len(fields) == 5 && fields[1] == "by" {
+		r.GroupBy = strings.Split(fields[2], ",")
+		fields = append(fields[:1], fields[3:]...)
+	}
+	if len(fields) != 3 {
+		return Rule{}, fmt.Errorf("invalid rule %q: want [key=value,...] <cost_type> [by key,...] <op> <threshold>", s)
+	}
+
+	r.CostType = fields[0]
+	if !slices.Contains(types.CostTypes, r.CostType) {
+		return Rule{}, fmt.Errorf("invalid rule %q: unknown cost type %q", s, r.CostType)
+	}
+	r.Op = fields[1]
+	if r.Op != ">" && r.Op != "<" {
+		return Rule{}, fmt.Errorf("invalid rule %q: operator must be > or <", s)
+	}
+	threshold, err := strconv.ParseFloat(strings.TrimPrefix(fields[2], "$"), 64)
+	if err != nil {
+		return Rule{}, fmt.Errorf("invalid rule %q: bad threshold: %w", s, err)
+	}
+	r.Threshold = threshold
+	return r, nil
+}
+
+// ParseRules parses semicolon-separated rules, ignoring empty entries.
+func ParseRules(s string) ([]Rule, error) {
+	var rules []Rule
+	for _, part := range splitTrim(s, ";") {
+		r, err := ParseRule(part)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// dimension returns the value of a property or provider label.
+func dimension(p types.CloudCostProperties, key string) string {
+	switch key {
+	case "provider":
+		return p.Provider
+	case "provider_id":
+		return p.ProviderID
+	case "account_id":
+		return p.AccountID
+	case "account_name":
+		return p.AccountName
+	case "invoice_entity_id":
+		return p.InvoiceEntityID
+	case "service":
+		return p.Service
+	case "category":
+		return p.Category
+	case "region":
+		return p.RegionID
+	case "availability_zone":
+		return p.AvailabilityZone
+	default:
+		return p.Labels[key]
+	}
+}
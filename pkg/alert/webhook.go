@@ -0,0 +1,56 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/secret"
+)
+
+// WebhookNotifier POSTs alerts as JSON ({"alerts": [...]}) to a URL.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+// Notify implements Notifier.
+func (n *WebhookNotifier) Notify(ctx context.Context, alerts []Alert) error {
+	body, err := json.Marshal(struct {
+		Alerts []Alert `json:"alerts"`
+	}{alerts})
+	if err != nil {
+		return fmt.Errorf("encode alerts: %w", err)
+	}
+	return postJSON(ctx, n.Client, n.URL, body, nil)
+}
+
+// postJSON POSTs body to url with the extra headers and fails on non-2xx
+// responses.
+func postJSON(ctx context.Context, hc *http.Client, url string, body []byte, header http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if hc == nil {
+		hc = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		// The URL of a chat webhook is a secret itself.
+		return fmt.Errorf("post alerts: %w", secret.StripURL(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,757 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: cloudcost/v1/cloudcost.proto
+
+package cloudcostv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// CostValues holds the amount for each cost type in USD.
+type CostValues struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	List          float64                `protobuf:"fixed64,1,opt,name=list,proto3" json:"list,omitempty"`
+	Net           float64                `protobuf:"fixed64,2,opt,name=net,proto3" json:"net,omitempty"`
+	AmortizedNet  float64                `protobuf:"fixed64,3,opt,name=amortized_net,json=amortizedNet,proto3" json:"amortized_net,omitempty"`
+	Invoiced      float64                `protobuf:"fixed64,4,opt,name=invoiced,proto3" json:"invoiced,omitempty"`
+	Amortized     float64                `protobuf:"fixed64,5,opt,name=amortized,proto3" json:"amortized,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CostValues) Reset() {
+	*x = CostValues{}
+	mi := &file_cloudcost_v1_cloudcost_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CostValues) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CostValues) ProtoMessage() {}
+
+func (x *CostValues) ProtoReflect() protoreflect.Message {
+	mi := &file_cloudcost_v1_cloudcost_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CostValues.ProtoReflect.Descriptor instead.
+func (*CostValues) Descriptor() ([]byte, []int) {
+	return file_cloudcost_v1_cloudcost_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *CostValues) GetList() float64 {
+	if x != nil {
+		return x.List
+	}
+	return 0
+}
+
+func (x *CostValues) GetNet() float64 {
+	if x != nil {
+		return x.Net
+	}
+	return 0
+}
+
+func (x *CostValues) GetAmortizedNet() float64 {
+	if x != nil {
+		return x.AmortizedNet
+	}
+	return 0
+}
+
+func (x *CostValues) GetInvoiced() float64 {
+	if x != nil {
+		return x.Invoiced
+	}
+	return 0
+}
+
+func (x *CostValues) GetAmortized() float64 {
+	if x != nil {
+		return x.Amortized
+	}
+	return 0
+}
+
+// CostItem is a single cloud cost line item.
+type CostItem struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	ProviderId        string                 `protobuf:"bytes,1,opt,name=provider_id,json=providerId,proto3" json:"provider_id,omitempty"`
+	Provider          string                 `protobuf:"bytes,2,opt,name=provider,proto3" json:"provider,omitempty"`
+	AccountId         string                 `protobuf:"bytes,3,opt,name=account_id,json=accountId,proto3" json:"account_id,omitempty"`
+	AccountName       string                 `protobuf:"bytes,4,opt,name=account_name,json=accountName,proto3" json:"account_name,omitempty"`
+	Service           string                 `protobuf:"bytes,5,opt,name=service,proto3" json:"service,omitempty"`
+	Category          string                 `protobuf:"bytes,6,opt,name=category,proto3" json:"category,omitempty"`
+	Region            string                 `protobuf:"bytes,7,opt,name=region,proto3" json:"region,omitempty"`
+	AvailabilityZone  string                 `protobuf:"bytes,8,opt,name=availability_zone,json=availabilityZone,proto3" json:"availability_zone,omitempty"`
+	Labels            map[string]string      `protobuf:"bytes,9,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	WindowStart       *timestamppb.Timestamp `protobuf:"bytes,10,opt,name=window_start,json=windowStart,proto3" json:"window_start,omitempty"`
+	WindowEnd         *timestamppb.Timestamp `protobuf:"bytes,11,opt,name=window_end,json=windowEnd,proto3" json:"window_end,omitempty"`
+	Costs             *CostValues            `protobuf:"bytes,12,opt,name=costs,proto3" json:"costs,omitempty"`
+	KubernetesPercent float64                `protobuf:"fixed64,13,opt,name=kubernetes_percent,json=kubernetesPercent,proto3" json:"kubernetes_percent,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *CostItem) Reset() {
+	*x = CostItem{}
+	mi := &file_cloudcost_v1_cloudcost_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CostItem) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CostItem) ProtoMessage() {}
+
+func (x *CostItem) ProtoReflect() protoreflect.Message {
+	mi := &file_cloudcost_v1_cloudcost_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CostItem.ProtoReflect.Descriptor instead.
+func (*CostItem) Descriptor() ([]byte, []int) {
+	return file_cloudcost_v1_cloudcost_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CostItem) GetProviderId() string {
+	if x != nil {
+		return x.ProviderId
+	}
+	return ""
+}
+
+func (x *CostItem) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+func (x *CostItem) GetAccountId() string {
+	if x != nil {
+		return x.AccountId
+	}
+	return ""
+}
+
+func (x *CostItem) GetAccountName() string {
+	if x != nil {
+		return x.AccountName
+	}
+	return ""
+}
+
+func (x *CostItem) GetService() string {
+	if x != nil {
+		return x.Service
+	}
+	return ""
+}
+
+func (x *CostItem) GetCategory() string {
+	if x != nil {
+		return x.Category
+	}
+	return ""
+}
+
+func (x *CostItem) GetRegion() string {
+	if x != nil {
+		return x.Region
+	}
+	return ""
+}
+
+func (x *CostItem) GetAvailabilityZone() string {
+	if x != nil {
+		return x.AvailabilityZone
+	}
+	return ""
+}
+
+func (x *CostItem) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *CostItem) GetWindowStart() *timestamppb.Timestamp {
+	if x != nil {
+		return x.WindowStart
+	}
+	return nil
+}
+
+func (x *CostItem) GetWindowEnd() *timestamppb.Timestamp {
+	if x != nil {
+		return x.WindowEnd
+	}
+	return nil
+}
+
+func (x *CostItem) GetCosts() *CostValues {
+	if x != nil {
+		return x.Costs
+	}
+	return nil
+}
+
+func (x *CostItem) GetKubernetesPercent() float64 {
+	if x != nil {
+		return x.KubernetesPercent
+	}
+	return 0
+}
+
+type ListCostsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Optional exact-match filters; empty values match everything.
+	AccountId     string `protobuf:"bytes,1,opt,name=account_id,json=accountId,proto3" json:"account_id,omitempty"`
+	Service       string `protobuf:"bytes,2,opt,name=service,proto3" json:"service,omitempty"`
+	Category      string `protobuf:"bytes,3,opt,name=category,proto3" json:"category,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListCostsRequest) Reset() {
+	*x = ListCostsRequest{}
+	mi := &file_cloudcost_v1_cloudcost_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListCostsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListCostsRequest) ProtoMessage() {}
+
+func (x *ListCostsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cloudcost_v1_cloudcost_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListCostsRequest.ProtoReflect.Descriptor instead.
+func (*ListCostsRequest) Descriptor() ([]byte, []int) {
+	return file_cloudcost_v1_cloudcost_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ListCostsRequest) GetAccountId() string {
+	if x != nil {
+		return x.AccountId
+	}
+	return ""
+}
+
+func (x *ListCostsRequest) GetService() string {
+	if x != nil {
+		return x.Service
+	}
+	return ""
+}
+
+func (x *ListCostsRequest) GetCategory() string {
+	if x != nil {
+		return x.Category
+	}
+	return ""
+}
+
+type ListCostsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Items         []*CostItem            `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+	FetchedAt     *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=fetched_at,json=fetchedAt,proto3" json:"fetched_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListCostsResponse) Reset() {
+	*x = ListCostsResponse{}
+	mi := &file_cloudcost_v1_cloudcost_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListCostsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListCostsResponse) ProtoMessage() {}
+
+func (x *ListCostsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_cloudcost_v1_cloudcost_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListCostsResponse.ProtoReflect.Descriptor instead.
+func (*ListCostsResponse) Descriptor() ([]byte, []int) {
+	return file_cloudcost_v1_cloudcost_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ListCostsResponse) GetItems() []*CostItem {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+func (x *ListCostsResponse) GetFetchedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.FetchedAt
+	}
+	return nil
+}
+
+type GetSummaryRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Dimensions to group by: provider, account_id, service, category, region.
+	// Empty returns a single grand total.
+	GroupBy       []string `protobuf:"bytes,1,rep,name=group_by,json=groupBy,proto3" json:"group_by,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetSummaryRequest) Reset() {
+	*x = GetSummaryRequest{}
+	mi := &file_cloudcost_v1_cloudcost_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetSummaryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetSummaryRequest) ProtoMessage() {}
+
+func (x *GetSummaryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cloudcost_v1_cloudcost_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetSummaryRequest.ProtoReflect.Descriptor instead.
+func (*GetSummaryRequest) Descriptor() ([]byte, []int) {
+	return file_cloudcost_v1_cloudcost_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetSummaryRequest) GetGroupBy() []string {
+	if x != nil {
+		return x.GroupBy
+	}
+	return nil
+}
+
+type SummaryRow struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Group         map[string]string      `protobuf:"bytes,1,rep,name=group,proto3" json:"group,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Costs         *CostValues            `protobuf:"bytes,2,opt,name=costs,proto3" json:"costs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SummaryRow) Reset() {
+	*x = SummaryRow{}
+	mi := &file_cloudcost_v1_cloudcost_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SummaryRow) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SummaryRow) ProtoMessage() {}
+
+func (x *SummaryRow) ProtoReflect() protoreflect.Message {
+	mi := &file_cloudcost_v1_cloudcost_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SummaryRow.ProtoReflect.Descriptor instead.
+func (*SummaryRow) Descriptor() ([]byte, []int) {
+	return file_cloudcost_v1_cloudcost_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *SummaryRow) GetGroup() map[string]string {
+	if x != nil {
+		return x.Group
+	}
+	return nil
+}
+
+func (x *SummaryRow) GetCosts() *CostValues {
+	if x != nil {
+		return x.Costs
+	}
+	return nil
+}
+
+type GetSummaryResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Rows          []*SummaryRow          `protobuf:"bytes,1,rep,name=rows,proto3" json:"rows,omitempty"`
+	Total         *CostValues            `protobuf:"bytes,2,opt,name=total,proto3" json:"total,omitempty"`
+	FetchedAt     *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=fetched_at,json=fetchedAt,proto3" json:"fetched_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetSummaryResponse) Reset() {
+	*x = GetSummaryResponse{}
+	mi := &file_cloudcost_v1_cloudcost_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetSummaryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetSummaryResponse) ProtoMessage() {}
+
+func (x *GetSummaryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_cloudcost_v1_cloudcost_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetSummaryResponse.ProtoReflect.Descriptor instead.
+func (*GetSummaryResponse) Descriptor() ([]byte, []int) {
+	return file_cloudcost_v1_cloudcost_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *GetSummaryResponse) GetRows() []*SummaryRow {
+	if x != nil {
+		return x.Rows
+	}
+	return nil
+}
+
+func (x *GetSummaryResponse) GetTotal() *CostValues {
+	if x != nil {
+		return x.Total
+	}
+	return nil
+}
+
+func (x *GetSummaryResponse) GetFetchedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.FetchedAt
+	}
+	return nil
+}
+
+type StreamUpdatesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamUpdatesRequest) Reset() {
+	*x = StreamUpdatesRequest{}
+	mi := &file_cloudcost_v1_cloudcost_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamUpdatesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamUpdatesRequest) ProtoMessage() {}
+
+func (x *StreamUpdatesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cloudcost_v1_cloudcost_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamUpdatesRequest.ProtoReflect.Descriptor instead.
+func (*StreamUpdatesRequest) Descriptor() ([]byte, []int) {
+	return file_cloudcost_v1_cloudcost_proto_rawDescGZIP(), []int{7}
+}
+
+type CostUpdate struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FetchedAt     *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=fetched_at,json=fetchedAt,proto3" json:"fetched_at,omitempty"`
+	ItemCount     int64                  `protobuf:"varint,2,opt,name=item_count,json=itemCount,proto3" json:"item_count,omitempty"`
+	Total         *CostValues            `protobuf:"bytes,3,opt,name=total,proto3" json:"total,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CostUpdate) Reset() {
+	*x = CostUpdate{}
+	mi := &file_cloudcost_v1_cloudcost_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CostUpdate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CostUpdate) ProtoMessage() {}
+
+func (x *CostUpdate) ProtoReflect() protoreflect.Message {
+	mi := &file_cloudcost_v1_cloudcost_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CostUpdate.ProtoReflect.Descriptor instead.
+func (*CostUpdate) Descriptor() ([]byte, []int) {
+	return file_cloudcost_v1_cloudcost_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *CostUpdate) GetFetchedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.FetchedAt
+	}
+	return nil
+}
+
+func (x *CostUpdate) GetItemCount() int64 {
+	if x != nil {
+		return x.ItemCount
+	}
+	return 0
+}
+
+func (x *CostUpdate) GetTotal() *CostValues {
+	if x != nil {
+		return x.Total
+	}
+	return nil
+}
+
+var File_cloudcost_v1_cloudcost_proto protoreflect.FileDescriptor
+
+const file_cloudcost_v1_cloudcost_proto_rawDesc = "" +
+	"\n" +
+	"\x1ccloudcost/v1/cloudcost.proto\x12\fcloudcost.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x91\x01\n" +
+	"\n" +
+	"CostValues\x12\x12\n" +
+	"\x04list\x18\x01 \x01(\x01R\x04list\x12\x10\n" +
+	"\x03net\x18\x02 \x01(\x01R\x03net\x12#\n" +
+	"\ramortized_net\x18\x03 \x01(\x01R\famortizedNet\x12\x1a\n" +
+	"\binvoiced\x18\x04 \x01(\x01R\binvoiced\x12\x1c\n" +
+	"\tamortized\x18\x05 \x01(\x01R\tamortized\"\xd4\x04\n" +
+	"\bCostItem\x12\x1f\n" +
+	"\vprovider_id\x18\x01 \x01(\tR\n" +
+	"providerId\x12\x1a\n" +
+	"\bprovider\x18\x02 \x01(\tR\bprovider\x12\x1d\n" +
+	"\n" +
+	"account_id\x18\x03 \x01(\tR\taccountId\x12!\n" +
+	"\faccount_name\x18\x04 \x01(\tR\vaccountName\x12\x18\n" +
+	"\aservice\x18\x05 \x01(\tR\aservice\x12\x1a\n" +
+	"\bcategory\x18\x06 \x01(\tR\bcategory\x12\x16\n" +
+	"\x06region\x18\a \x01(\tR\x06region\x12+\n" +
+	"\x11availability_zone\x18\b \x01(\tR\x10availabilityZone\x12:\n" +
+	"\x06labels\x18\t \x03(\v2\".cloudcost.v1.CostItem.LabelsEntryR\x06labels\x12=\n" +
+	"\fwindow_start\x18\n" +
+	" \x01(\v2\x1a.google.protobuf.TimestampR\vwindowStart\x129\n" +
+	"\n" +
+	"window_end\x18\v \x01(\v2\x1a.google.protobuf.TimestampR\twindowEnd\x12.\n" +
+	"\x05costs\x18\f \x01(\v2\x18.cloudcost.v1.CostValuesR\x05costs\x12-\n" +
+	"\x12kubernetes_percent\x18\r \x01(\x01R\x11kubernetesPercent\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"g\n" +
+	"\x10ListCostsRequest\x12\x1d\n" +
+	"\n" +
+	"account_id\x18\x01 \x01(\tR\taccountId\x12\x18\n" +
+	"\aservice\x18\x02 \x01(\tR\aservice\x12\x1a\n" +
+	"\bcategory\x18\x03 \x01(\tR\bcategory\"|\n" +
+	"\x11ListCostsResponse\x12,\n" +
+	"\x05items\x18\x01 \x03(\v2\x16.cloudcost.v1.CostItemR\x05items\x129\n" +
+	"\n" +
+	"fetched_at\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\tfetchedAt\".\n" +
+	"\x11GetSummaryRequest\x12\x19\n" +
+	"\bgroup_by\x18\x01 \x03(\tR\agroupBy\"\xb1\x01\n" +
+	"\n" +
+	"SummaryRow\x129\n" +
+	"\x05group\x18\x01 \x03(\v2#.cloudcost.v1.SummaryRow.GroupEntryR\x05group\x12.\n" +
+	"\x05costs\x18\x02 \x01(\v2\x18.cloudcost.v1.CostValuesR\x05costs\x1a8\n" +
+	"\n" +
+	"GroupEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xad\x01\n" +
+	"\x12GetSummaryResponse\x12,\n" +
+	"\x04rows\x18\x01 \x03(\v2\x18.cloudcost.v1.SummaryRowR\x04rows\x12.\n" +
+	"\x05total\x18\x02 \x01(\v2\x18.cloudcost.v1.CostValuesR\x05total\x129\n" +
+	"\n" +
+	"fetched_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\tfetchedAt\"\x16\n" +
+	"\x14StreamUpdatesRequest\"\x96\x01\n" +
+	"\n" +
+	"CostUpdate\x129\n" +
+	"\n" +
+	"fetched_at\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\tfetchedAt\x12\x1d\n" +
+	"\n" +
+	"item_count\x18\x02 \x01(\x03R\titemCount\x12.\n" +
+	"\x05total\x18\x03 \x01(\v2\x18.cloudcost.v1.CostValuesR\x05total2\x82\x02\n" +
+	"\x10CloudCostService\x12L\n" +
+	"\tListCosts\x12\x1e.cloudcost.v1.ListCostsRequest\x1a\x1f.cloudcost.v1.ListCostsResponse\x12O\n" +
+	"\n" +
+	"GetSummary\x12\x1f.cloudcost.v1.GetSummaryRequest\x1a .cloudcost.v1.GetSummaryResponse\x12O\n" +
+	"\rStreamUpdates\x12\".cloudcost.v1.StreamUpdatesRequest\x1a\x18.cloudcost.v1.CostUpdate0\x01BRZPgithub.com/hawky-4s-/opencost-cloudcost-exporter/pkg/api/cloudcostv1;cloudcostv1b\x06proto3"
+
+var (
+	file_cloudcost_v1_cloudcost_proto_rawDescOnce sync.Once
+	file_cloudcost_v1_cloudcost_proto_rawDescData []byte
+)
+
+func file_cloudcost_v1_cloudcost_proto_rawDescGZIP() []byte {
+	file_cloudcost_v1_cloudcost_proto_rawDescOnce.Do(func() {
+		file_cloudcost_v1_cloudcost_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_cloudcost_v1_cloudcost_proto_rawDesc), len(file_cloudcost_v1_cloudcost_proto_rawDesc)))
+	})
+	return file_cloudcost_v1_cloudcost_proto_rawDescData
+}
+
+var file_cloudcost_v1_cloudcost_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_cloudcost_v1_cloudcost_proto_goTypes = []any{
+	(*CostValues)(nil),            // 0: cloudcost.v1.CostValues
+	(*CostItem)(nil),              // 1: cloudcost.v1.CostItem
+	(*ListCostsRequest)(nil),      // 2: cloudcost.v1.ListCostsRequest
+	(*ListCostsResponse)(nil),     // 3: cloudcost.v1.ListCostsResponse
+	(*GetSummaryRequest)(nil),     // 4: cloudcost.v1.GetSummaryRequest
+	(*SummaryRow)(nil),            // 5: cloudcost.v1.SummaryRow
+	(*GetSummaryResponse)(nil),    // 6: cloudcost.v1.GetSummaryResponse
+	(*StreamUpdatesRequest)(nil),  // 7: cloudcost.v1.StreamUpdatesRequest
+	(*CostUpdate)(nil),            // 8: cloudcost.v1.CostUpdate
+	nil,                           // 9: cloudcost.v1.CostItem.LabelsEntry
+	nil,                           // 10: cloudcost.v1.SummaryRow.GroupEntry
+	(*timestamppb.Timestamp)(nil), // 11: google.protobuf.Timestamp
+}
+var file_cloudcost_v1_cloudcost_proto_depIdxs = []int32{
+	9,  // 0: cloudcost.v1.CostItem.labels:type_name -> cloudcost.v1.CostItem.LabelsEntry
+	11, // 1: cloudcost.v1.CostItem.window_start:type_name -> google.protobuf.Timestamp
+	11, // 2: cloudcost.v1.CostItem.window_end:type_name -> google.protobuf.Timestamp
+	0,  // 3: cloudcost.v1.CostItem.costs:type_name -> cloudcost.v1.CostValues
+	1,  // 4: cloudcost.v1.ListCostsResponse.items:type_name -> cloudcost.v1.CostItem
+	11, // 5: cloudcost.v1.ListCostsResponse.fetched_at:type_name -> google.protobuf.Timestamp
+	10, // 6: cloudcost.v1.SummaryRow.group:type_name -> cloudcost.v1.SummaryRow.GroupEntry
+	0,  // 7: cloudcost.v1.SummaryRow.costs:type_name -> cloudcost.v1.CostValues
+	5,  // 8: cloudcost.v1.GetSummaryResponse.rows:type_name -> cloudcost.v1.SummaryRow
+	0,  // 9: cloudcost.v1.GetSummaryResponse.total:type_name -> cloudcost.v1.CostValues
+	11, // 10: cloudcost.v1.GetSummaryResponse.fetched_at:type_name -> google.protobuf.Timestamp
+	11, // 11: cloudcost.v1.CostUpdate.fetched_at:type_name -> google.protobuf.Timestamp
+	0,  // 12: cloudcost.v1.CostUpdate.total:type_name -> cloudcost.v1.CostValues
+	2,  // 13: cloudcost.v1.CloudCostService.ListCosts:input_type -> cloudcost.v1.ListCostsRequest
+	4,  // 14: cloudcost.v1.CloudCostService.GetSummary:input_type -> cloudcost.v1.GetSummaryRequest
+	7,  // 15: cloudcost.v1.CloudCostService.StreamUpdates:input_type -> cloudcost.v1.StreamUpdatesRequest
+	3,  // 16: cloudcost.v1.CloudCostService.ListCosts:output_type -> cloudcost.v1.ListCostsResponse
+	6,  // 17: cloudcost.v1.CloudCostService.GetSummary:output_type -> cloudcost.v1.GetSummaryResponse
+	8,  // 18: cloudcost.v1.CloudCostService.StreamUpdates:output_type -> cloudcost.v1.CostUpdate
+	16, // [16:19] is the sub-list for method output_type
+	13, // [13:16] is the sub-list for method input_type
+	13, // [13:13] is the sub-list for extension type_name
+	13, // [13:13] is the sub-list for extension extendee
+	0,  // [0:13] is the sub-list for field type_name
+}
+
+func init() { file_cloudcost_v1_cloudcost_proto_init() }
+func file_cloudcost_v1_cloudcost_proto_init() {
+	if File_cloudcost_v1_cloudcost_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_cloudcost_v1_cloudcost_proto_rawDesc), len(file_cloudcost_v1_cloudcost_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   11,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_cloudcost_v1_cloudcost_proto_goTypes,
+		DependencyIndexes: file_cloudcost_v1_cloudcost_proto_depIdxs,
+		MessageInfos:      file_cloudcost_v1_cloudcost_proto_msgTypes,
+	}.Build()
+	File_cloudcost_v1_cloudcost_proto = out.File
+	file_cloudcost_v1_cloudcost_proto_goTypes = nil
+	file_cloudcost_v1_cloudcost_proto_depIdxs = nil
+}
@@ -0,0 +1,211 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: cloudcost/v1/cloudcost.proto
+
+package cloudcostv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	CloudCostService_ListCosts_FullMethodName     = "/cloudcost.v1.CloudCostService/ListCosts"
+	CloudCostService_GetSummary_FullMethodName    = "/cloudcost.v1.CloudCostService/GetSummary"
+	CloudCostService_StreamUpdates_FullMethodName = "/cloudcost.v1.CloudCostService/StreamUpdates"
+)
+
+// CloudCostServiceClient is the client API for CloudCostService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// CloudCostService serves the exporter's cached cloud cost data.
+type CloudCostServiceClient interface {
+	// ListCosts returns the cached cost line items, optionally filtered.
+	ListCosts(ctx context.Context, in *ListCostsRequest, opts ...grpc.CallOption) (*ListCostsResponse, error)
+	// GetSummary returns costs summed by the requested dimensions.
+	GetSummary(ctx context.Context, in *GetSummaryRequest, opts ...grpc.CallOption) (*GetSummaryResponse, error)
+	// StreamUpdates sends a message after every successful refresh.
+	StreamUpdates(ctx context.Context, in *StreamUpdatesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[CostUpdate], error)
+}
+
+type cloudCostServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCloudCostServiceClient(cc grpc.ClientConnInterface) CloudCostServiceClient {
+	return &cloudCostServiceClient{cc}
+}
+
+func (c *cloudCostServiceClient) ListCosts(ctx context.Context, in *ListCostsRequest, opts ...grpc.CallOption) (*ListCostsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListCostsResponse)
+	err := c.cc.Invoke(ctx, CloudCostService_ListCosts_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cloudCostServiceClient) GetSummary(ctx context.Context, in *GetSummaryRequest, opts ...grpc.CallOption) (*GetSummaryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetSummaryResponse)
+	err := c.cc.Invoke(ctx, CloudCostService_GetSummary_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cloudCostServiceClient) StreamUpdates(ctx context.Context, in *StreamUpdatesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[CostUpdate], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &CloudCostService_ServiceDesc.Streams[0], CloudCostService_StreamUpdates_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamUpdatesRequest, CostUpdate]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type CloudCostService_StreamUpdatesClient = grpc.ServerStreamingClient[CostUpdate]
+
+// CloudCostServiceServer is the server API for CloudCostService service.
+// All implementations must embed UnimplementedCloudCostServiceServer
+// for forward compatibility.
+//
+// CloudCostService serves the exporter's cached cloud cost data.
+type CloudCostServiceServer interface {
+	// ListCosts returns the cached cost line items, optionally filtered.
+	ListCosts(context.Context, *ListCostsRequest) (*ListCostsResponse, error)
+	// GetSummary returns costs summed by the requested dimensions.
+	GetSummary(context.Context, *GetSummaryRequest) (*GetSummaryResponse, error)
+	// StreamUpdates sends a message after every successful refresh.
+	StreamUpdates(*StreamUpdatesRequest, grpc.ServerStreamingServer[CostUpdate]) error
+	mustEmbedUnimplementedCloudCostServiceServer()
+}
+
+// UnimplementedCloudCostServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedCloudCostServiceServer struct{}
+
+func (UnimplementedCloudCostServiceServer) ListCosts(context.Context, *ListCostsRequest) (*ListCostsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListCosts not implemented")
+}
+func (UnimplementedCloudCostServiceServer) GetSummary(context.Context, *GetSummaryRequest) (*GetSummaryResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetSummary not implemented")
+}
+func (UnimplementedCloudCostServiceServer) StreamUpdates(*StreamUpdatesRequest, grpc.ServerStreamingServer[CostUpdate]) error {
+	return status.Error(codes.Unimplemented, "method StreamUpdates not implemented")
+}
+func (UnimplementedCloudCostServiceServer) mustEmbedUnimplementedCloudCostServiceServer() {}
+func (UnimplementedCloudCostServiceServer) testEmbeddedByValue()                          {}
+
+// UnsafeCloudCostServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CloudCostServiceServer will
+// result in compilation errors.
+type UnsafeCloudCostServiceServer interface {
+	mustEmbedUnimplementedCloudCostServiceServer()
+}
+
+func RegisterCloudCostServiceServer(s grpc.ServiceRegistrar, srv CloudCostServiceServer) {
+	// If the following call panics, it indicates UnimplementedCloudCostServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&CloudCostService_ServiceDesc, srv)
+}
+
+func _CloudCostService_ListCosts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCostsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CloudCostServiceServer).ListCosts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CloudCostService_ListCosts_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CloudCostServiceServer).ListCosts(ctx, req.(*ListCostsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CloudCostService_GetSummary_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSummaryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CloudCostServiceServer).GetSummary(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CloudCostService_GetSummary_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CloudCostServiceServer).GetSummary(ctx, req.(*GetSummaryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CloudCostService_StreamUpdates_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamUpdatesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CloudCostServiceServer).StreamUpdates(m, &grpc.GenericServerStream[StreamUpdatesRequest, CostUpdate]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type CloudCostService_StreamUpdatesServer = grpc.ServerStreamingServer[CostUpdate]
+
+// CloudCostService_ServiceDesc is the grpc.ServiceDesc for CloudCostService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var CloudCostService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "cloudcost.v1.CloudCostService",
+	HandlerType: (*CloudCostServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListCosts",
+			Handler:    _CloudCostService_ListCosts_Handler,
+		},
+		{
+			MethodName: "GetSummary",
+			Handler:    _CloudCostService_GetSummary_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamUpdates",
+			Handler:       _CloudCostService_StreamUpdates_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "cloudcost/v1/cloudcost.proto",
+}
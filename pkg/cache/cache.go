@@ -17,18 +17,31 @@ type Cache struct {
 	fetchedAt time.Time
 	ttl       time.Duration
 	maxStale  time.Duration
+	// file persists the data across restarts if set; see WithFile. fileMu
+	// serializes its writes, which happen outside mu; saved is the fetch
+	// time of the data last written.
+	file   string
+	fileMu sync.Mutex
+	saved  time.Time
 
 	// Metrics (atomic for thread-safety)
 	hits   atomic.Int64
 	misses atomic.Int64
 }
 
+// Option configures a Cache.
+type Option func(*Cache)
+
 // New creates a new Cache with the specified TTL and max stale duration.
-func New(ttl, maxStale time.Duration) *Cache {
-	return &Cache{
+func New(ttl, maxStale time.Duration, opts ...Option) *Cache {
+	c := &Cache{
 		ttl:      ttl,
 		maxStale: maxStale,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 // Get retrieves the cached data if available and not expired.
@@ -61,13 +74,49 @@ func (c *Cache) Get() (data *types.CloudCostResponse, isStale bool, ok bool) {
 	return nil, false, false
 }
 
-// Set stores new data in the cache.
+// Snapshot returns the cached data and the time it was fetched without
+// affecting hit/miss statistics. Data older than ttl+maxStale is not returned.
+func (c *Cache) Snapshot() (data *types.CloudCostResponse, fetchedAt time.Time, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.data == nil || time.Since(c.fetchedAt) > c.ttl+c.maxStale {
+		return nil, time.Time{}, false
+	}
+	return c.data, c.fetchedAt, true
+}
+
+// Set stores new data in the cache and, with a file, saves it there.
 func (c *Cache) Set(data *types.CloudCostResponse) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
-
 	c.data = data
 	c.fetchedAt = time.Now()
+	fetchedAt := c.fetchedAt
+	c.mu.Unlock()
+
+	c.save(data, fetchedAt)
+}
+
+// SetTTL changes the TTL and max stale duration, e.g. on a configuration
+// reload. The cached data is kept and judged by the new durations.
+func (c *Cache) SetTTL(ttl, maxStale time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ttl = ttl
+	c.maxStale = maxStale
+}
+
+// Invalidate drops the cached data, e.g. after the query it answers has
+// changed, so the next Get misses. A saved file is removed, so that the
+// data is not loaded again after a restart.
+func (c *Cache) Invalidate() {
+	c.mu.Lock()
+	c.data = nil
+	c.fetchedAt = time.Time{}
+	c.mu.Unlock()
+
+	c.remove()
 }
 
 // Age returns the age of the cached data.
@@ -77,6 +77,39 @@ func TestCache_TTL(t *testing.T) {
 	}
 }
 
+func TestCache_SetTTL(t *testing.T) {
+	c := New(time.Hour, time.Hour)
+	c.Set(&types.CloudCostResponse{Code: 200})
+
+	c.SetTTL(0, time.Hour)
+	if _, isStale, ok := c.Get(); !ok || !isStale {
+		t.Errorf("Get() after shortening TTL: stale=%v ok=%v, want stale data", isStale, ok)
+	}
+
+	c.SetTTL(0, 0)
+	if _, _, ok := c.Get(); ok {
+		t.Error("Get() should miss once max stale is shortened")
+	}
+
+	c.SetTTL(time.Hour, 0)
+	if _, isStale, ok := c.Get(); !ok || isStale {
+		t.Errorf("Get() after extending TTL: stale=%v ok=%v, want fresh data", isStale, ok)
+	}
+}
+
+func TestCache_Invalidate(t *testing.T) {
+	c := New(time.Hour, time.Hour)
+	c.Set(&types.CloudCostResponse{Code: 200})
+	c.Invalidate()
+
+	if _, _, ok := c.Get(); ok {
+		t.Error("Get() should miss after Invalidate()")
+	}
+	if c.IsPopulated() {
+		t.Error("IsPopulated() should be false after Invalidate()")
+	}
+}
+
 func TestCache_Age(t *testing.T) {
 	c := New(time.Hour, time.Hour*6)
 
@@ -150,3 +183,27 @@ func TestCache_ConcurrentAccess(t *testing.T) {
 		<-done
 	}
 }
+
+func TestCache_Snapshot(t *testing.T) {
+	c := New(10*time.Millisecond, 10*time.Millisecond)
+
+	if _, _, ok := c.Snapshot(); ok {
+		t.Error("Snapshot() on empty cache should return ok=false")
+	}
+
+	c.Set(&types.CloudCostResponse{Code: 200})
+	data, fetchedAt, ok := c.Snapshot()
+	if !ok || data == nil || fetchedAt.IsZero() {
+		t.Fatalf("Snapshot() = %v, %v, %v; want data", data, fetchedAt, ok)
+	}
+
+	hits, misses := c.Stats()
+	if hits != 0 || misses != 0 {
+		t.Errorf("Snapshot() should not affect stats, got hits=%d misses=%d", hits, misses)
+	}
+
+	time.Sleep(25 * time.Millisecond)
+	if _, _, ok := c.Snapshot(); ok {
+		t.Error("Snapshot() should not return data older than ttl+maxStale")
+	}
+}
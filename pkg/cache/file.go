@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+// WithFile persists the cached data to path, e.g. on a volume, so that it
+// can be served after a restart until OpenCost answers again. The data is
+// saved on every Set and restored by Load.
+func WithFile(path string) Option {
+	return func(c *Cache) {
+		c.file = path
+	}
+}
+
+// snapshot is the file format of a persisted cache.
+type snapshot struct {
+	FetchedAt time.Time                `json:"fetchedAt"`
+	Data      *types.CloudCostResponse `json:"data"`
+}
+
+// Load restores the data saved to the file of WithFile with the time it was
+// fetched, unless it is older than the TTL plus the max stale age. It
+// returns whether data was restored; a missing file is not an error.
+func (c *Cache) Load() (bool, error) {
+	if c.file == "" {
+		return false, nil
+	}
+	b, err := os.ReadFile(c.file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("read cache file: %w", err)
+	}
+	var snap snapshot
+	if err := json.Unmarshal(b, &snap); err != nil {
+		return false, fmt.Errorf("parse cache file %s: %w", c.file, err)
+	}
+	if snap.Data == nil {
+		return false, fmt.Errorf("cache file %s holds no data", c.file)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Since(snap.FetchedAt) > c.ttl+c.maxStale {
+		return false, nil
+	}
+	if c.data == nil {
+		c.data = snap.Data
+		c.fetchedAt = snap.FetchedAt
+	}
+	return true, nil
+}
+
+// save writes data to the file, if any. The file is replaced atomically so
+// that a crash leaves the previous snapshot intact. Failures are logged, as
+// the cached data remains usable.
+func (c *Cache) save(data *types.CloudCostResponse, fetchedAt time.Time) {
+	if c.file == "" {
+		return
+	}
+	c.fileMu.Lock()
+	defer c.fileMu.Unlock()
+	if fetchedAt.Before(c.saved) {
+		return // a concurrent Set already saved newer data
+	}
+	if err := writeFile(c.file, snapshot{FetchedAt: fetchedAt, Data: data}); err != nil {
+		slog.Warn("failed to save cache file", "path", c.file, "error", err)
+		return
+	}
+	c.saved = fetchedAt
+}
+
+// remove deletes the file, if any.
+func (c *Cache) remove() {
+	if c.file == "" {
+		return
+	}
+	c.fileMu.Lock()
+	defer c.fileMu.Unlock()
+	c.saved = time.Now()
+	if err := os.Remove(c.file); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		slog.Warn("failed to remove cache file", "path", c.file, "error", err)
+	}
+}
+
+// writeFile writes snap as JSON to a temporary file next to path and
+// renames it to path.
+func writeFile(path string, snap snapshot) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after the rename
+	if err := json.NewEncoder(tmp).Encode(snap); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/opencosttest"
+)
+
+func TestCache_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	data := opencosttest.Response(opencosttest.Item("111111111111", "AmazonEC2", "Compute", 12.5))
+
+	c := New(time.Hour, 6*time.Hour, WithFile(path))
+	if ok, err := c.Load(); ok || err != nil {
+		t.Fatalf("Load() without a file = %v, %v, want false, nil", ok, err)
+	}
+	c.Set(data)
+
+	// A restarted exporter serves the saved data with its age.
+	restarted := New(time.Hour, 6*time.Hour, WithFile(path))
+	if ok, err := restarted.Load(); !ok || err != nil {
+		t.Fatalf("Load() = %v, %v, want true, nil", ok, err)
+	}
+	got, isStale, ok := restarted.Get()
+	if !ok || isStale {
+		t.Fatalf("Get() after Load() = stale %v, ok %v, want fresh data", isStale, ok)
+	}
+	if cost := got.Data.Sets[0].CloudCosts; len(cost) != 1 {
+		t.Errorf("loaded %d cost items, want 1", len(cost))
+	}
+	_, fetchedAt, _ := c.Snapshot()
+	if _, loadedAt, _ := restarted.Snapshot(); !loadedAt.Equal(fetchedAt) {
+		t.Errorf("loaded fetch time = %v, want %v", loadedAt, fetchedAt)
+	}
+
+	// Data past the max stale age is not loaded.
+	expired := New(time.Nanosecond, time.Nanosecond, WithFile(path))
+	if ok, err := expired.Load(); ok || err != nil || expired.IsPopulated() {
+		t.Errorf("Load() of expired data = %v, %v, want false, nil", ok, err)
+	}
+
+	// Invalidated data is not loaded after a restart either.
+	c.Invalidate()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("cache file after Invalidate() error = %v, want it removed", err)
+	}
+
+	if err := os.WriteFile(path, []byte("{broken"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := New(time.Hour, time.Hour, WithFile(path)).Load(); ok || err == nil {
+		t.Errorf("Load() of a broken file = %v, %v, want an error", ok, err)
+	}
+}
+
+func TestCache_FileWriteError(t *testing.T) {
+	// The data stays cached when the file cannot be written.
+	c := New(time.Hour, time.Hour, WithFile(filepath.Join(t.TempDir(), "missing", "cache.json")))
+	c.Set(opencosttest.Response())
+	if !c.IsPopulated() {
+		t.Error("IsPopulated() = false after a failed save")
+	}
+}
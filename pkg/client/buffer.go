@@ -0,0 +1,39 @@
+package client
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// maxPooledBuffer caps the size of the buffers kept for reuse, so that one
+// exceptionally large response does not pin its memory for good.
+const maxPooledBuffer = 64 << 20
+
+// buffers holds the buffers the bodies of failed requests are read into,
+// so that repeated failures reuse one buffer. cloudCost responses are not
+// read whole but decoded as they are read; see decode.
+var buffers = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// readBody reads r into a pooled buffer, sized up front by the expected
+// length if known. The returned release func must be called once the body
+// is no longer used, and the body must not be retained after it.
+func readBody(r io.Reader, length int64) (body []byte, release func(), err error) {
+	buf := buffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	release = func() {
+		if buf.Cap() <= maxPooledBuffer {
+			buffers.Put(buf)
+		}
+	}
+	if length > 0 && length <= maxPooledBuffer {
+		buf.Grow(int(length))
+	}
+	if _, err := buf.ReadFrom(r); err != nil {
+		release()
+		return nil, nil, err
+	}
+	return buf.Bytes(), release, nil
+}
@@ -0,0 +1,149 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"runtime/metrics"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/opencosttest"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+func TestReadBody(t *testing.T) {
+	for _, length := range []int64{-1, 5, 100} {
+		body, release, err := readBody(strings.NewReader("hello"), length)
+		if err != nil {
+			t.Fatalf("readBody() error = %v", err)
+		}
+		if string(body) != "hello" {
+			t.Errorf("readBody(length %d) = %q, want hello", length, body)
+		}
+		release()
+	}
+
+	if _, _, err := readBody(iotest.ErrReader(io.ErrUnexpectedEOF), -1); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("readBody() error = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func BenchmarkClient_FetchCloudCosts(b *testing.B) {
+	benchmarks := []struct {
+		name string
+		spec opencosttest.Spec
+	}{
+		{"items=1000", opencosttest.Spec{Items: 1000, Accounts: 50, Services: 100}},
+		{"items=5000", opencosttest.Spec{Items: 5000, Accounts: 50, Services: 100}},
+		{"items=1000,sets=30", opencosttest.Spec{Sets: 30, Items: 1000, Accounts: 50, Services: 100}},
+		{"items=5000,labels=10", opencosttest.Spec{Items: 5000, Accounts: 50, Services: 100, Labels: 10}},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			body, err := json.Marshal(opencosttest.Generate(bm.spec))
+			if err != nil {
+				b.Fatal(err)
+			}
+			// Serve the encoded body so that only the client is measured.
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write(body)
+			}))
+			defer server.Close()
+			client := New(server.URL)
+
+			b.SetBytes(int64(len(body)))
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := client.FetchCloudCosts(context.Background()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkDecode compares decoding a response of daily sets over a month
+// as it is read with reading it whole and unmarshaling it, as the client
+// did before. peak-heap-B is the most heap memory in use at once during an
+// operation, sampled every 100µs.
+func BenchmarkDecode(b *testing.B) {
+	body, err := json.Marshal(opencosttest.Generate(opencosttest.Spec{Sets: 30, Items: 2000, Accounts: 50, Services: 100, Labels: 10}))
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchmarks := []struct {
+		name   string
+		decode func(r io.Reader) error
+	}{
+		{"buffered", func(r io.Reader) error {
+			body, err := io.ReadAll(r)
+			if err != nil {
+				return err
+			}
+			var resp types.CloudCostResponse
+			return json.Unmarshal(body, &resp)
+		}},
+		{"streamed", func(r io.Reader) error {
+			_, err := decode(context.Background(), &countingReader{r: r})
+			return err
+		}},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			b.SetBytes(int64(len(body)))
+			b.ReportAllocs()
+			var peak uint64
+			for b.Loop() {
+				b.StopTimer()
+				runtime.GC()
+				base := heapInUse()
+				done := make(chan struct{})
+				sampled := make(chan uint64)
+				go func() {
+					var max uint64
+					ticker := time.NewTicker(100 * time.Microsecond)
+					defer ticker.Stop()
+					for {
+						if inUse := heapInUse(); inUse > base && inUse-base > max {
+							max = inUse - base
+						}
+						select {
+						case <-done:
+							sampled <- max
+							return
+						case <-ticker.C:
+						}
+					}
+				}()
+				b.StartTimer()
+
+				if err := bm.decode(bytes.NewReader(body)); err != nil {
+					b.Fatal(err)
+				}
+
+				b.StopTimer()
+				close(done)
+				peak = max(peak, <-sampled)
+				b.StartTimer()
+			}
+			b.ReportMetric(float64(peak), "peak-heap-B")
+		})
+	}
+}
+
+// heapInUse returns the bytes of the heap objects in use, reachable or not
+// yet collected.
+func heapInUse() uint64 {
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(sample)
+	return sample[0].Value.Uint64()
+}
@@ -2,25 +2,68 @@
 package client
 
 import (
+	"bufio"
 	"context"
-	"encoding/json"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"net/http"
 	"net/url"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/requestid"
 	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
 )
 
+var tracer = otel.Tracer("github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/client")
+
 // Client is an HTTP client for the OpenCost cloudCost API.
 type Client struct {
-	baseURL    string
-	httpClient *http.Client
-	window     string
-	aggregate  string
-	maxRetries int
+	baseURL       string
+	httpClient    *http.Client
+	rates         *http.Client // exchange rate requests, without the TLS configuration
+	exchangeRates ExchangeRateProvider
+	window        atomic.Pointer[string]
+	aggregate     string
+	retry         RetryPolicy
+	token         func() (string, error)
+	apiKey        func() (string, error)
+	keyHeader     string
+	username      string
+	password      func() (string, error)
+	headers       http.Header
+	flavor        Flavor
+	location      *time.Location
+	windowShard   time.Duration
+
+	requestMetrics *RequestMetrics
+
+	// failFastUntilUp skips retries of refused connections until OpenCost
+	// has answered once; answered records that it has.
+	failFastUntilUp bool
+	answered        atomic.Bool
+
+	throttles map[string]*throttle
+	exhausted atomic.Int64
+
+	// transferred and decompressed count the response bytes; see Transfers.
+	transferred  atomic.Int64
+	decompressed atomic.Int64
+
+	retryMu sync.Mutex
+	retries map[string]int64 // by reason
 }
 
 // Option is a functional option for configuring the Client.
@@ -30,17 +73,21 @@ type Option func(*Client)
 func WithTimeout(timeout time.Duration) Option {
 	return func(c *Client) {
 		c.httpClient.Timeout = timeout
+		c.rates.Timeout = timeout
 	}
 }
 
 // WithWindow sets the time window for cost queries.
 func WithWindow(window string) Option {
 	return func(c *Client) {
-		c.window = window
+		c.SetWindow(window)
 	}
 }
 
-// WithAggregate sets the aggregation dimensions.
+// WithAggregate sets the comma-separated properties OpenCost aggregates cost
+// items by, e.g. "accountID,service,category". Properties not aggregated by
+// are empty in the response. The default, empty, requests one item per
+// resource. See types.ParseAggregate.
 func WithAggregate(aggregate string) Option {
 	return func(c *Client) {
 		c.aggregate = aggregate
@@ -50,7 +97,93 @@ func WithAggregate(aggregate string) Option {
 // WithMaxRetries sets the maximum number of retry attempts.
 func WithMaxRetries(retries int) Option {
 	return func(c *Client) {
-		c.maxRetries = retries
+		c.retry.MaxRetries = retries
+	}
+}
+
+// WithRetryPolicy sets the retries and backoff of failed OpenCost requests
+// (default DefaultRetryPolicy).
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = p
+	}
+}
+
+// WithLocation sets the time zone calendar windows such as "month" are
+// aligned in (default UTC). See WindowBounds.
+func WithLocation(loc *time.Location) Option {
+	return func(c *Client) {
+		c.location = loc
+	}
+}
+
+// WithStartupFailFast makes fetches fail immediately, without retries and
+// backoff, when the connection is refused and OpenCost has not answered yet.
+// It suits OpenCost on localhost, e.g. with the exporter as a sidecar, where
+// a refused connection means that OpenCost is still starting and the next
+// scrape is a better time to try again.
+func WithStartupFailFast(enabled bool) Option {
+	return func(c *Client) {
+		c.failFastUntilUp = enabled
+	}
+}
+
+// WithBearerToken authenticates OpenCost requests with the token returned by
+// token, which is called for every request so that rotated tokens are used,
+// e.g. for OpenCost behind kube-rbac-proxy. Exchange rate requests are not
+// authenticated.
+func WithBearerToken(token func() (string, error)) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// DefaultAPIKeyHeader is the header API keys are sent in unless another is
+// given to WithAPIKey.
+const DefaultAPIKeyHeader = "X-API-Key"
+
+// WithAPIKey authenticates OpenCost requests with the API key returned by
+// key, sent in the header named header (DefaultAPIKeyHeader if empty), as
+// hosted OpenCost-compatible APIs expect instead of a bearer token. key is
+// called for every request so that rotated keys are used. Exchange rate
+// requests are not authenticated.
+func WithAPIKey(header string, key func() (string, error)) Option {
+	return func(c *Client) {
+		if header == "" {
+			header = DefaultAPIKeyHeader
+		}
+		c.apiKey, c.keyHeader = key, header
+	}
+}
+
+// WithBasicAuth authenticates OpenCost requests with HTTP basic
+// authentication as username, e.g. for OpenCost behind an ingress with
+// basic auth. password is called for every request so that a rotated
+// password is used. A bearer token takes precedence. Exchange rate requests
+// are not authenticated.
+func WithBasicAuth(username string, password func() (string, error)) Option {
+	return func(c *Client) {
+		c.username, c.password = username, password
+	}
+}
+
+// WithHeaders adds headers to every OpenCost request, e.g. the tenant or
+// credentials an authenticating proxy expects. Like credentials, they are
+// not logged, and they are overridden by the authentication options.
+// Exchange rate requests do not carry them.
+func WithHeaders(headers http.Header) Option {
+	return func(c *Client) {
+		c.headers = headers.Clone()
+	}
+}
+
+// WithTLSConfig sets the TLS configuration of OpenCost requests, e.g. from
+// TLSOptions.Config for an OpenCost that requires client certificates.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(c *Client) {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = cfg
+		c.httpClient.Transport = transport
 	}
 }
 
@@ -61,21 +194,108 @@ func New(baseURL string, opts ...Option) *Client {
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
-		window:     "1d",
-		aggregate:  "service,category",
-		maxRetries: 3,
+		rates: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		exchangeRates: Frankfurter{},
+		retry:         DefaultRetryPolicy,
+		location:      time.UTC,
+		flavor:        OpenCost,
+		throttles:     make(map[string]*throttle, len(Targets)),
+		retries:       make(map[string]int64),
 	}
+	for _, target := range Targets {
+		c.throttles[target] = &throttle{target: target}
+	}
+	c.SetWindow("1d")
 
 	for _, opt := range opts {
 		opt(c)
 	}
+	if c.requestMetrics != nil {
+		c.httpClient.Transport = c.requestMetrics.instrument(c.httpClient.Transport, TargetOpenCost)
+		c.rates.Transport = c.requestMetrics.instrument(c.rates.Transport, c.exchangeRates.Name())
+	}
 
 	return c
 }
 
+// SetWindow changes the time window of FetchCloudCosts at runtime, e.g. on
+// a configuration reload.
+func (c *Client) SetWindow(window string) {
+	c.window.Store(&window)
+}
+
+// Window returns the time window of FetchCloudCosts.
+func (c *Client) Window() string {
+	return *c.window.Load()
+}
+
+// WindowBounds returns the bounds of the configured window now, or zero
+// bounds if OpenCost resolves the window itself. See WindowBounds.
+func (c *Client) WindowBounds() (types.Window, error) {
+	return WindowBounds(c.Window(), time.Now().In(c.location))
+}
+
+// Throttling reports the 429 Too Many Requests responses of target, one of
+// Targets.
+func (c *Client) Throttling(target string) ThrottleStats {
+	if t, ok := c.throttles[target]; ok {
+		return t.snapshot()
+	}
+	return ThrottleStats{}
+}
+
+// RetryStats reports the retries of OpenCost requests so far.
+func (c *Client) RetryStats() RetryStats {
+	c.retryMu.Lock()
+	defer c.retryMu.Unlock()
+	stats := RetryStats{ByReason: maps.Clone(c.retries), Exhausted: c.exhausted.Load()}
+	for _, n := range c.retries {
+		stats.Retries += n
+	}
+	return stats
+}
+
 // FetchCloudCosts fetches cloud cost data from the OpenCost API with retry support.
 func (c *Client) FetchCloudCosts(ctx context.Context) (*types.CloudCostResponse, error) {
-	endpoint, err := url.JoinPath(c.baseURL, "/cloudCost")
+	return c.FetchCloudCostsWindow(ctx, c.Window())
+}
+
+// FetchCloudCostsWindow fetches cloud cost data for the given window instead
+// of the configured one. Calendar keywords and the "<window> offset
+// <duration>" syntax are resolved by ResolveWindow.
+//
+// After a 429 response, retries wait for the target's Retry-After or an
+// increasing backoff beyond the normal schedule, and fetches fail with a
+// *ThrottledError without a request until then. Other unexpected statuses
+// fail with a *StatusError: 4xx without retries, and 5xx retried no
+// earlier than their Retry-After.
+func (c *Client) FetchCloudCostsWindow(ctx context.Context, window string) (_ *types.CloudCostResponse, err error) {
+	ctx, span := tracer.Start(ctx, "FetchCloudCosts", trace.WithAttributes(attribute.String("opencost.window", window)))
+	defer func() { endSpan(span, err) }()
+
+	now := time.Now().In(c.location)
+	window, err = ResolveWindow(window, now)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.throttles[TargetOpenCost].check(); err != nil {
+		return nil, err
+	}
+	if c.windowShard > 0 {
+		if shards := shardWindow(window, c.windowShard, now); shards != nil {
+			span.SetAttributes(attribute.Int("opencost.window_shards", len(shards)))
+			return c.fetchShards(ctx, shards)
+		}
+	}
+	return c.fetchWindow(ctx, window)
+}
+
+// fetchWindow fetches the resolved window, page by page for flavors with
+// pages.
+func (c *Client) fetchWindow(ctx context.Context, window string) (*types.CloudCostResponse, error) {
+	endpoint, err := url.JoinPath(c.baseURL, c.flavor.CloudCostPath)
 	if err != nil {
 		return nil, fmt.Errorf("invalid base URL: %w", err)
 	}
@@ -87,29 +307,61 @@ func (c *Client) FetchCloudCosts(ctx context.Context) (*types.CloudCostResponse,
 	}
 
 	q := u.Query()
-	q.Set("window", c.window)
-	//q.Set("aggregate", c.aggregate)
+	q.Set("window", window)
+	if c.aggregate != "" {
+		q.Set("aggregate", c.aggregate)
+	}
 	u.RawQuery = q.Encode()
 
+	if c.flavor.PageSize > 0 {
+		return c.fetchPages(ctx, u)
+	}
+	return c.fetchRetrying(ctx, u.String())
+}
+
+// fetchRetrying fetches url, retrying failures according to the retry
+// policy.
+func (c *Client) fetchRetrying(ctx context.Context, url string) (*types.CloudCostResponse, error) {
+	return retrying(ctx, c, func(ctx context.Context) (*types.CloudCostResponse, error) {
+		return c.doFetch(ctx, url)
+	})
+}
+
+// retrying calls fetch, retrying failures according to the retry policy of
+// c.
+func retrying[T any](ctx context.Context, c *Client, fetch func(context.Context) (T, error)) (T, error) {
+	var zero T
+	span := trace.SpanFromContext(ctx)
 	var lastErr error
-	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+	for attempt := 0; attempt <= c.retry.MaxRetries; attempt++ {
 		if attempt > 0 {
-			// Exponential backoff: 1s, 2s, 4s...
-			backoff := time.Duration(1<<(attempt-1)) * time.Second
-			slog.Warn("retrying OpenCost API request",
+			backoff := c.retry.Wait(attempt)
+			// Asking a throttling or unavailable OpenCost again before
+			// the wait it asked for only prolongs it.
+			if wait := minWait(lastErr); wait > backoff {
+				backoff = wait
+				if deadline, ok := ctx.Deadline(); ok && time.Now().Add(backoff).After(deadline) {
+					return zero, lastErr
+				}
+			}
+			slog.WarnContext(ctx, "retrying OpenCost API request",
 				"attempt", attempt,
-				"max_retries", c.maxRetries,
+				"max_retries", c.retry.MaxRetries,
 				"backoff", backoff.String(),
 				"last_error", lastErr.Error(),
 			)
 			select {
 			case <-ctx.Done():
-				return nil, ctx.Err()
+				return zero, ctx.Err()
 			case <-time.After(backoff):
 			}
+			c.retryMu.Lock()
+			c.retries[retryReason(lastErr)]++
+			c.retryMu.Unlock()
 		}
 
-		result, err := c.doFetch(ctx, u.String())
+		span.SetAttributes(attribute.Int("opencost.attempts", attempt+1))
+		result, err := fetch(ctx)
 		if err == nil {
 			return result, nil
 		}
@@ -117,30 +369,53 @@ func (c *Client) FetchCloudCosts(ctx context.Context) (*types.CloudCostResponse,
 
 		// Don't retry on context cancellation
 		if ctx.Err() != nil {
-			return nil, ctx.Err()
+			return zero, ctx.Err()
+		}
+		// OpenCost answered; asking again gets the same answer.
+		if errors.Is(err, types.ErrInvalidResponse) || permanent(err) {
+			return zero, err
+		}
+		if c.failFastUntilUp && !c.answered.Load() && errors.Is(err, syscall.ECONNREFUSED) {
+			return zero, fmt.Errorf("OpenCost is not up yet: %w", err)
 		}
 	}
 
-	return nil, fmt.Errorf("after %d retries: %w", c.maxRetries, lastErr)
+	c.exhausted.Add(1)
+	return zero, fmt.Errorf("after %d retries: %w", c.retry.MaxRetries, lastErr)
 }
 
-func (c *Client) doFetch(ctx context.Context, url string) (*types.CloudCostResponse, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+func (c *Client) doFetch(ctx context.Context, url string) (_ *types.CloudCostResponse, err error) {
+	ctx, span := tracer.Start(ctx, "GET "+c.flavor.CloudCostPath, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", http.MethodGet),
+			attribute.String("url.full", url),
+		))
+	defer func() { endSpan(span, err) }()
+
+	req, err := http.NewRequestWithContext(withEndpoint(ctx, c.flavor.CloudCostPath), http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("create request: %w", err)
 	}
 
 	req.Header.Set("Accept", "application/json")
+	acceptGzip(req)
+	requestid.SetHeader(req)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 
-	slog.Debug("sending HTTP request",
+	slog.DebugContext(ctx, "sending HTTP request",
 		"method", req.Method,
 		"url", url,
 		"headers", req.Header,
 	)
 
+	// Added after logging so the token never appears in the logs.
+	if err := c.authorize(req); err != nil {
+		return nil, err
+	}
+	start := time.Now()
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		slog.Debug("HTTP request failed",
+		slog.DebugContext(ctx, "HTTP request failed",
 			"method", req.Method,
 			"url", url,
 			"error", err,
@@ -148,36 +423,181 @@ func (c *Client) doFetch(ctx context.Context, url string) (*types.CloudCostRespo
 		return nil, fmt.Errorf("do request: %w", err)
 	}
 	defer resp.Body.Close()
+	c.answered.Store(true)
 
-	// Read body for logging and parsing
-	body, err := io.ReadAll(resp.Body)
+	observeStage(ctx, StageFetch, start)
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	respBody, err := c.responseBody(resp)
 	if err != nil {
-		return nil, fmt.Errorf("read response body: %w", err)
+		return nil, err
 	}
 
-	// Log response details at debug level
-	bodyPreview := string(body)
-	if len(bodyPreview) > 500 {
-		bodyPreview = bodyPreview[:500] + "... (truncated)"
+	if resp.StatusCode != http.StatusOK {
+		// Error bodies are small; read them whole for the error.
+		body, release, err := readBody(respBody, resp.ContentLength)
+		if err != nil {
+			return nil, fmt.Errorf("read response body: %w", err)
+		}
+		defer release()
+		logResponse(ctx, resp, body)
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return nil, c.throttles[TargetOpenCost].record(resp.Header.Get("Retry-After"))
+		}
+		return nil, newStatusError(resp, body)
 	}
-	slog.Debug("received HTTP response",
+	c.throttles[TargetOpenCost].reset()
+
+	// Decode the body as it is read rather than reading it whole first, as
+	// responses of long windows run into hundreds of megabytes.
+	br := bufio.NewReader(respBody)
+	preview, _ := br.Peek(previewSize + 1)
+	logResponse(ctx, resp, preview)
+	body := &countingReader{r: br}
+	start = time.Now()
+	result, err := decode(ctx, body)
+	observeStage(ctx, StageDecode, start)
+	span.SetAttributes(attribute.Int64("http.response.body.size", body.n))
+	return result, err
+}
+
+// previewSize is how much of a response body is logged at debug level.
+const previewSize = 500
+
+// logResponse logs resp at debug level with a preview of its body, of
+// which body holds at least the beginning.
+func logResponse(ctx context.Context, resp *http.Response, body []byte) {
+	bodyPreview := string(body[:min(len(body), previewSize)])
+	if len(body) > previewSize {
+		bodyPreview += "... (truncated)"
+	}
+	slog.DebugContext(ctx, "received HTTP response",
 		"status_code", resp.StatusCode,
 		"status", resp.Status,
 		"content_length", resp.ContentLength,
 		"headers", resp.Header,
 		"body_preview", bodyPreview,
 	)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
-	}
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
 
-	var result types.CloudCostResponse
-	if err := json.Unmarshal(body, &result); err != nil {
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// decode parses a cloudCost response body as it is read from body. Items
+// that cannot be decoded or fail validation are left out, so that one
+// malformed item does not cost the whole response; see
+// CloudCostResponse.Skipped.
+//
+// Not holding the whole body halves the peak heap of decoding a month of
+// daily sets (60000 items, 39 MB) in BenchmarkDecode:
+//
+//	buffered: 3.3 s/op  193453864 peak-heap-B  316866638 B/op
+//	streamed: 2.3 s/op   97697360 peak-heap-B  200915932 B/op
+func decode(ctx context.Context, body *countingReader) (_ *types.CloudCostResponse, err error) {
+	_, span := tracer.Start(ctx, "decode")
+	defer func() {
+		span.SetAttributes(attribute.Int64("http.response.body.size", body.n))
+		endSpan(span, err)
+	}()
+
+	result, err := types.DecodeCloudCostResponse(body)
+	if err != nil {
 		return nil, fmt.Errorf("decode response: %w", err)
 	}
+	result.DropInvalid()
+
+	items, invalid, unrecognized := 0, 0, 0
+	for _, set := range result.Data.Sets {
+		items += len(set.CloudCosts)
+		for _, item := range set.CloudCosts {
+			if item.Window.Validate() != nil {
+				invalid++
+			}
+			if p := item.Properties; p.AccountID == "" && p.Service == "" && p.Category == "" {
+				unrecognized++
+			}
+		}
+	}
+	if err := result.Validate(); err != nil {
+		return nil, err
+	}
+
+	coerced, skipped := result.Coercions(), result.Skipped()
+	span.SetAttributes(attribute.Int("opencost.sets", len(result.Data.Sets)), attribute.Int("opencost.items", items),
+		attribute.Int("opencost.coerced_values", coerced), attribute.Int("opencost.skipped_items", len(skipped)))
+	if len(skipped) > 0 {
+		slog.WarnContext(ctx, "left out malformed cost items, serving the rest", "items", len(skipped), "first_error", skipped[0])
+	}
+	if coerced > 0 {
+		slog.WarnContext(ctx, "OpenCost sent cost values that are not numbers; strings were parsed and null or unparsable values taken as 0", "values", coerced)
+	}
+	if unrecognized > 0 && unrecognized == items {
+		slog.WarnContext(ctx, "no cost item has an account, service or category; the response schema of this OpenCost version may not be supported", "items", items)
+	}
+	if invalid > 0 {
+		slog.WarnContext(ctx, "cost items without a valid window are left out of the window metrics", "items", invalid)
+	}
+	return result, nil
+}
 
-	return &result, nil
+// endSpan records err on span, if any, and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Get performs a GET request for path and rawQuery against the OpenCost API
+// without retries. The caller must close the response body.
+func (c *Client) Get(ctx context.Context, path, rawQuery string) (*http.Response, error) {
+	req, err := c.newRequest(ctx, path, rawQuery)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(req)
+}
+
+// newRequest creates a GET request for path and rawQuery against the
+// OpenCost API, not yet authorized.
+func (c *Client) newRequest(ctx context.Context, path, rawQuery string) (*http.Request, error) {
+	endpoint, err := url.JoinPath(c.baseURL, c.flavor.path(path))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	if rawQuery != "" {
+		endpoint += "?" + rawQuery
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	requestid.SetHeader(req)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	return req, nil
+}
+
+// do sends req, authorized.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	if err := c.authorize(req); err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+	return resp, nil
 }
 
 // Ping checks if the OpenCost API is reachable.
@@ -187,19 +607,23 @@ func (c *Client) Ping(ctx context.Context) error {
 		return fmt.Errorf("invalid base URL: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	req, err := http.NewRequestWithContext(withEndpoint(ctx, "/healthz"), http.MethodGet, endpoint, nil)
 	if err != nil {
 		return fmt.Errorf("create request: %w", err)
 	}
+	requestid.SetHeader(req)
 
-	slog.Debug("sending HTTP request",
+	slog.DebugContext(ctx, "sending HTTP request",
 		"method", req.Method,
 		"url", endpoint,
 	)
 
+	if err := c.authorize(req); err != nil {
+		return err
+	}
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		slog.Debug("HTTP request failed",
+		slog.DebugContext(ctx, "HTTP request failed",
 			"method", req.Method,
 			"url", endpoint,
 			"error", err,
@@ -208,7 +632,7 @@ func (c *Client) Ping(ctx context.Context) error {
 	}
 	defer resp.Body.Close()
 
-	slog.Debug("received HTTP response",
+	slog.DebugContext(ctx, "received HTTP response",
 		"status_code", resp.StatusCode,
 		"status", resp.Status,
 	)
@@ -220,85 +644,35 @@ func (c *Client) Ping(ctx context.Context) error {
 	return nil
 }
 
-// DefaultExchangeRateURL is the default Frankfurter API endpoint.
-const DefaultExchangeRateURL = "https://api.frankfurter.dev/v1/latest"
-
-// FetchExchangeRates fetches currency exchange rates from the Frankfurter API.
-func (c *Client) FetchExchangeRates(ctx context.Context, base string, symbols []string) (*types.ExchangeRateResponse, error) {
-	u, err := url.Parse(DefaultExchangeRateURL)
-	if err != nil {
-		return nil, fmt.Errorf("parse exchange rate URL: %w", err)
-	}
-
-	q := u.Query()
-	q.Set("base", base)
-	if len(symbols) > 0 {
-		symbolStr := ""
-		for i, s := range symbols {
-			if i > 0 {
-				symbolStr += ","
-			}
-			symbolStr += s
+// authorize adds the configured headers and credentials to an OpenCost
+// request.
+func (c *Client) authorize(req *http.Request) error {
+	for name, values := range c.headers {
+		req.Header.Del(name)
+		for _, v := range values {
+			req.Header.Add(name, v)
 		}
-		q.Set("symbols", symbolStr)
-	}
-	u.RawQuery = q.Encode()
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
-	if err != nil {
-		return nil, fmt.Errorf("create request: %w", err)
-	}
-
-	req.Header.Set("Accept", "application/json")
-
-	slog.Debug("sending HTTP request",
-		"method", req.Method,
-		"url", u.String(),
-		"headers", req.Header,
-	)
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		slog.Debug("HTTP request failed",
-			"method", req.Method,
-			"url", u.String(),
-			"error", err,
-		)
-		return nil, fmt.Errorf("do request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("read response body: %w", err)
 	}
-
-	bodyPreview := string(body)
-	if len(bodyPreview) > 500 {
-		bodyPreview = bodyPreview[:500] + "... (truncated)"
+	if c.password != nil {
+		password, err := c.password()
+		if err != nil {
+			return fmt.Errorf("read OpenCost password: %w", err)
+		}
+		req.SetBasicAuth(c.username, password)
 	}
-	slog.Debug("received HTTP response",
-		"status_code", resp.StatusCode,
-		"status", resp.Status,
-		"content_length", resp.ContentLength,
-		"headers", resp.Header,
-		"body_preview", bodyPreview,
-	)
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+	if c.token != nil {
+		token, err := c.token()
+		if err != nil {
+			return fmt.Errorf("read OpenCost token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
 	}
-
-	var result types.ExchangeRateResponse
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("decode response: %w", err)
+	if c.apiKey != nil {
+		key, err := c.apiKey()
+		if err != nil {
+			return fmt.Errorf("read OpenCost API key: %w", err)
+		}
+		req.Header.Set(c.keyHeader, key)
 	}
-
-	slog.Debug("parsed exchange rates",
-		"base", result.Base,
-		"date", result.Date,
-		"rates", result.Rates,
-	)
-
-	return &result, nil
+	return nil
 }
@@ -3,45 +3,29 @@ package client
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/opencosttest"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/requestid"
 	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
 )
 
 func TestClient_FetchCloudCosts_Success(t *testing.T) {
-	// Create mock server
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path != "/cloudCost" {
-			t.Errorf("unexpected path: %s", r.URL.Path)
-		}
-		if r.URL.Query().Get("window") != "1d" {
-			t.Errorf("unexpected window: %s", r.URL.Query().Get("window"))
-		}
-
-		resp := types.CloudCostResponse{
-			Code: 200,
-			Data: types.CloudCostData{
-				Sets: []types.CloudCostSet{
-					{
-						CloudCosts: map[string]types.CloudCostItem{
-							"test": {
-								Properties: types.CloudCostProperties{
-									Service:  "AmazonEC2",
-									Category: "Compute",
-								},
-								ListCost: types.CostValue{Cost: 100.50},
-							},
-						},
-					},
-				},
-			},
-		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(resp)
-	}))
+	server := opencosttest.NewServer(opencosttest.WithResponse(opencosttest.Response(
+		opencosttest.Item("", "AmazonEC2", "Compute", 100.50),
+	)))
 	defer server.Close()
 
 	client := New(server.URL)
@@ -56,13 +40,18 @@ func TestClient_FetchCloudCosts_Success(t *testing.T) {
 	if len(resp.Data.Sets) != 1 {
 		t.Errorf("Sets count = %v, want 1", len(resp.Data.Sets))
 	}
+
+	reqs := server.Requests()
+	if len(reqs) != 1 || reqs[0].Path != "/cloudCost" {
+		t.Fatalf("requests = %+v, want one /cloudCost request", reqs)
+	}
+	if w := reqs[0].Query.Get("window"); w != "1d" {
+		t.Errorf("unexpected window: %s", w)
+	}
 }
 
 func TestClient_FetchCloudCosts_ServerError(t *testing.T) {
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("internal error"))
-	}))
+	server := opencosttest.NewServer(opencosttest.WithFailures(4, http.StatusInternalServerError))
 	defer server.Close()
 
 	client := New(server.URL)
@@ -73,9 +62,7 @@ func TestClient_FetchCloudCosts_ServerError(t *testing.T) {
 }
 
 func TestClient_FetchCloudCosts_InvalidJSON(t *testing.T) {
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte("not json"))
-	}))
+	server := opencosttest.NewServer(opencosttest.WithRawResponse("not json"))
 	defer server.Close()
 
 	client := New(server.URL)
@@ -85,13 +72,99 @@ func TestClient_FetchCloudCosts_InvalidJSON(t *testing.T) {
 	}
 }
 
-func TestClient_FetchCloudCosts_Timeout(t *testing.T) {
+func TestClient_FetchCloudCosts_Gzip(t *testing.T) {
+	data := opencosttest.Generate(opencosttest.Spec{Items: 200, Accounts: 5, Services: 10})
+	body, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, compress := range []bool{true, false} {
+		opts := []opencosttest.Option{opencosttest.WithResponse(data)}
+		if compress {
+			opts = append(opts, opencosttest.WithGzip())
+		}
+		server := opencosttest.NewServer(opts...)
+		defer server.Close()
+
+		client := New(server.URL)
+		resp, err := client.FetchCloudCosts(context.Background())
+		if err != nil {
+			t.Fatalf("FetchCloudCosts(gzip %v) error = %v", compress, err)
+		}
+		if got := len(resp.Data.Sets[0].CloudCosts); got != 200 {
+			t.Errorf("FetchCloudCosts(gzip %v) items = %d, want 200", compress, got)
+		}
+		if got := server.Requests()[0].Header.Get("Accept-Encoding"); got != "gzip" {
+			t.Errorf("Accept-Encoding = %q, want gzip", got)
+		}
+
+		stats := client.Transfers()
+		if stats.Bytes != int64(len(body)) {
+			t.Errorf("Transfers(gzip %v).Bytes = %d, want %d", compress, stats.Bytes, len(body))
+		}
+		if compress && stats.TransferredBytes >= stats.Bytes/2 {
+			t.Errorf("Transfers().TransferredBytes = %d, want a fraction of %d", stats.TransferredBytes, stats.Bytes)
+		}
+		if !compress && stats.TransferredBytes != stats.Bytes {
+			t.Errorf("Transfers().TransferredBytes = %d uncompressed, want %d", stats.TransferredBytes, stats.Bytes)
+		}
+	}
+}
+
+func TestClient_FetchCloudCosts_CorruptGzip(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		time.Sleep(100 * time.Millisecond)
-		w.WriteHeader(http.StatusOK)
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write([]byte(`{"code": 200, "data": {"sets": []}}`))
 	}))
 	defer server.Close()
 
+	client := New(server.URL, WithMaxRetries(0))
+	if _, err := client.FetchCloudCosts(context.Background()); err == nil || !strings.Contains(err.Error(), "decompress") {
+		t.Errorf("FetchCloudCosts() error = %v, want a decompression error", err)
+	}
+}
+
+func TestClient_FetchCloudCosts_InvalidResponse(t *testing.T) {
+	server := opencosttest.NewServer(opencosttest.WithRawResponse(`{"code": 500, "data": {"sets": []}}`))
+	defer server.Close()
+
+	client := New(server.URL)
+	_, err := client.FetchCloudCosts(context.Background())
+	if !errors.Is(err, types.ErrInvalidResponse) {
+		t.Errorf("FetchCloudCosts() error = %v, want ErrInvalidResponse", err)
+	}
+	if n := len(server.Requests()); n != 1 {
+		t.Errorf("requests = %d, want 1 (invalid responses are not retried)", n)
+	}
+}
+
+func TestClient_FetchCloudCosts_PartialData(t *testing.T) {
+	server := opencosttest.NewServer(opencosttest.WithRawResponse(`{"code": 200, "data": {"sets": [{"cloudCosts": {
+		"nan": {"properties": {"service": "AmazonEC2"}, "listCost": {"cost": "NaN"}},
+		"malformed": {"properties": {"service": ["AmazonS3"]}},
+		"ok": {"properties": {"service": "AmazonRDS"}, "listCost": {"cost": 10}}
+	}}]}}`))
+	defer server.Close()
+
+	client := New(server.URL, WithMaxRetries(0))
+	data, err := client.FetchCloudCosts(context.Background())
+	if err != nil {
+		t.Fatalf("FetchCloudCosts() error = %v, want the valid items", err)
+	}
+	items := data.Data.Sets[0].CloudCosts
+	if _, ok := items["ok"]; !ok || len(items) != 1 {
+		t.Errorf("items = %v, want only the valid one", items)
+	}
+	if !data.Partial() || len(data.Skipped()) != 2 {
+		t.Errorf("Skipped() = %v, want the two malformed items", data.Skipped())
+	}
+}
+
+func TestClient_FetchCloudCosts_Timeout(t *testing.T) {
+	server := opencosttest.NewServer(opencosttest.WithLatency(100 * time.Millisecond))
+	defer server.Close()
+
 	client := New(server.URL, WithTimeout(10*time.Millisecond))
 	_, err := client.FetchCloudCosts(context.Background())
 	if err == nil {
@@ -100,10 +173,7 @@ func TestClient_FetchCloudCosts_Timeout(t *testing.T) {
 }
 
 func TestClient_FetchCloudCosts_ContextCanceled(t *testing.T) {
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		time.Sleep(100 * time.Millisecond)
-		w.WriteHeader(http.StatusOK)
-	}))
+	server := opencosttest.NewServer(opencosttest.WithLatency(100 * time.Millisecond))
 	defer server.Close()
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -116,29 +186,237 @@ func TestClient_FetchCloudCosts_ContextCanceled(t *testing.T) {
 	}
 }
 
+func TestClient_WithStartupFailFast(t *testing.T) {
+	server := opencosttest.NewServer()
+	client := New(server.URL, WithStartupFailFast(true), WithMaxRetries(1))
+	server.Close()
+
+	// Before OpenCost has answered, a refused connection is not retried.
+	start := time.Now()
+	if _, err := client.FetchCloudCosts(context.Background()); err == nil {
+		t.Fatal("FetchCloudCosts() should fail while OpenCost is down")
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("FetchCloudCosts() took %v, want no retry backoff", elapsed)
+	}
+
+	// Once it has, refused connections are retried as usual.
+	client.answered.Store(true)
+	start = time.Now()
+	if _, err := client.FetchCloudCosts(context.Background()); err == nil {
+		t.Fatal("FetchCloudCosts() should fail while OpenCost is down")
+	}
+	// The default jitter shortens the 1s backoff by up to 20%.
+	if elapsed := time.Since(start); elapsed < 800*time.Millisecond {
+		t.Errorf("FetchCloudCosts() took %v, want a retry after about 1s backoff", elapsed)
+	}
+}
+
 func TestClient_WithWindow(t *testing.T) {
-	var receivedWindow string
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		receivedWindow = r.URL.Query().Get("window")
-		json.NewEncoder(w).Encode(types.CloudCostResponse{Code: 200})
-	}))
+	server := opencosttest.NewServer()
 	defer server.Close()
 
 	client := New(server.URL, WithWindow("7d"))
 	client.FetchCloudCosts(context.Background())
 
-	if receivedWindow != "7d" {
-		t.Errorf("window = %v, want 7d", receivedWindow)
+	if w := server.Requests()[0].Query.Get("window"); w != "7d" {
+		t.Errorf("window = %v, want 7d", w)
+	}
+
+	client.SetWindow("30d")
+	client.FetchCloudCosts(context.Background())
+	if w := server.Requests()[1].Query.Get("window"); w != "30d" {
+		t.Errorf("window after SetWindow = %v, want 30d", w)
 	}
 }
 
-func TestClient_Ping_Success(t *testing.T) {
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path != "/healthz" {
-			t.Errorf("unexpected path: %s", r.URL.Path)
+func TestClient_WithAggregate(t *testing.T) {
+	server := opencosttest.NewServer()
+	defer server.Close()
+
+	New(server.URL).FetchCloudCosts(context.Background())
+	New(server.URL, WithAggregate("accountID,service")).FetchCloudCosts(context.Background())
+
+	reqs := server.Requests()
+	if q := reqs[0].Query; q.Has("aggregate") {
+		t.Errorf("aggregate = %q by default, want none", q.Get("aggregate"))
+	}
+	if a := reqs[1].Query.Get("aggregate"); a != "accountID,service" {
+		t.Errorf("aggregate = %q, want accountID,service", a)
+	}
+}
+
+func TestClient_WithBearerToken(t *testing.T) {
+	server := opencosttest.NewServer()
+	defer server.Close()
+
+	tokens := []string{"first", "rotated"}
+	client := New(server.URL, WithMaxRetries(0), WithBearerToken(func() (string, error) {
+		if len(tokens) == 0 {
+			return "", errors.New("token file is empty")
 		}
-		w.WriteHeader(http.StatusOK)
+		token := tokens[0]
+		tokens = tokens[1:]
+		return token, nil
 	}))
+	ctx := context.Background()
+
+	client.FetchCloudCosts(ctx)
+	client.Ping(ctx)
+	for i, want := range []string{"Bearer first", "Bearer rotated"} {
+		if got := server.Requests()[i].Header.Get("Authorization"); got != want {
+			t.Errorf("request %d Authorization = %q, want %q", i, got, want)
+		}
+	}
+
+	if _, err := client.FetchCloudCosts(ctx); err == nil || !strings.Contains(err.Error(), "read OpenCost token") {
+		t.Errorf("FetchCloudCosts() error = %v, want a token error", err)
+	}
+	if n := len(server.Requests()); n != 2 {
+		t.Errorf("%d requests sent, want none without a token", n-2)
+	}
+}
+
+func TestClient_WithAPIKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{name: "default header", want: DefaultAPIKeyHeader},
+		{name: "custom header", header: "Api-Key", want: "Api-Key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := opencosttest.NewServer()
+			defer server.Close()
+
+			client := New(server.URL, WithMaxRetries(0), WithAPIKey(tt.header, func() (string, error) { return "key", nil }))
+			ctx := context.Background()
+			if _, err := client.FetchCloudCosts(ctx); err != nil {
+				t.Fatalf("FetchCloudCosts() error = %v", err)
+			}
+			client.Ping(ctx)
+			for i, req := range server.Requests() {
+				if got := req.Header.Get(tt.want); got != "key" {
+					t.Errorf("request %d %s = %q, want key", i, tt.want, got)
+				}
+				if got := req.Header.Get("Authorization"); got != "" {
+					t.Errorf("request %d Authorization = %q, want none", i, got)
+				}
+			}
+		})
+	}
+
+	server := opencosttest.NewServer()
+	defer server.Close()
+	client := New(server.URL, WithMaxRetries(0), WithAPIKey("", func() (string, error) { return "", errors.New("no such file") }))
+	if _, err := client.FetchCloudCosts(context.Background()); err == nil || !strings.Contains(err.Error(), "read OpenCost API key") {
+		t.Errorf("FetchCloudCosts() error = %v, want an API key error", err)
+	}
+	if n := len(server.Requests()); n != 0 {
+		t.Errorf("%d requests sent, want none without an API key", n)
+	}
+}
+
+func TestClient_WithBasicAuth(t *testing.T) {
+	server := opencosttest.NewServer()
+	defer server.Close()
+
+	passwords := []string{"first", "rotated"}
+	client := New(server.URL, WithMaxRetries(0), WithBasicAuth("exporter", func() (string, error) {
+		if len(passwords) == 0 {
+			return "", errors.New("password file is empty")
+		}
+		password := passwords[0]
+		passwords = passwords[1:]
+		return password, nil
+	}))
+	ctx := context.Background()
+
+	client.FetchCloudCosts(ctx)
+	client.Ping(ctx)
+	for i, want := range []string{"first", "rotated"} {
+		req := &http.Request{Header: server.Requests()[i].Header}
+		if user, password, ok := req.BasicAuth(); !ok || user != "exporter" || password != want {
+			t.Errorf("request %d basic auth = %q, %q, %v, want exporter, %s", i, user, password, ok, want)
+		}
+	}
+
+	if _, err := client.FetchCloudCosts(ctx); err == nil || !strings.Contains(err.Error(), "read OpenCost password") {
+		t.Errorf("FetchCloudCosts() error = %v, want a password error", err)
+	}
+	if n := len(server.Requests()); n != 2 {
+		t.Errorf("%d requests sent, want none without a password", n-2)
+	}
+}
+
+func TestClient_WithHeaders(t *testing.T) {
+	server := opencosttest.NewServer()
+	defer server.Close()
+
+	headers := http.Header{"X-Scope-Orgid": {"finance"}, "Authorization": {"Basic overridden"}}
+	client := New(server.URL, WithMaxRetries(0), WithHeaders(headers),
+		WithBearerToken(func() (string, error) { return "token", nil }))
+	headers.Set("X-Scope-Orgid", "changed")
+	ctx := context.Background()
+
+	client.FetchCloudCosts(ctx)
+	client.Ping(ctx)
+	for i, req := range server.Requests() {
+		if got := req.Header.Get("X-Scope-OrgID"); got != "finance" {
+			t.Errorf("request %d X-Scope-OrgID = %q, want finance", i, got)
+		}
+		if got := req.Header.Get("Authorization"); got != "Bearer token" {
+			t.Errorf("request %d Authorization = %q, want the bearer token", i, got)
+		}
+	}
+	if n := len(server.Requests()); n != 2 {
+		t.Errorf("%d requests sent, want 2", n)
+	}
+}
+
+func TestClient_RequestID(t *testing.T) {
+	server := opencosttest.NewServer(opencosttest.WithFailures(1, http.StatusServiceUnavailable))
+	defer server.Close()
+
+	client := New(server.URL, WithRetryPolicy(RetryPolicy{MaxRetries: 1, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, Multiplier: 1}))
+	if _, err := client.FetchCloudCosts(requestid.NewContext(context.Background(), "abc")); err != nil {
+		t.Fatalf("FetchCloudCosts() error = %v", err)
+	}
+	client.Ping(context.Background())
+
+	reqs := server.Requests()
+	for i, want := range []string{"abc", "abc", ""} {
+		if got := reqs[i].Header.Get(requestid.Header); got != want {
+			t.Errorf("request %d %s = %q, want %q", i, requestid.Header, got, want)
+		}
+	}
+}
+
+func TestClient_StageObserver(t *testing.T) {
+	server := opencosttest.NewServer(opencosttest.WithResponse(opencosttest.Response(
+		opencosttest.Item("", "AmazonEC2", "Compute", 1),
+	)))
+	defer server.Close()
+
+	var stages []string
+	ctx := ContextWithStageObserver(context.Background(), func(stage string, d time.Duration) {
+		if d < 0 {
+			t.Errorf("stage %s took %s", stage, d)
+		}
+		stages = append(stages, stage)
+	})
+	if _, err := New(server.URL).FetchCloudCosts(ctx); err != nil {
+		t.Fatalf("FetchCloudCosts() error = %v", err)
+	}
+	if want := []string{StageFetch, StageDecode}; !slices.Equal(stages, want) {
+		t.Errorf("observed stages %v, want %v", stages, want)
+	}
+}
+
+func TestClient_Ping_Success(t *testing.T) {
+	server := opencosttest.NewServer()
 	defer server.Close()
 
 	client := New(server.URL)
@@ -146,13 +424,15 @@ func TestClient_Ping_Success(t *testing.T) {
 	if err != nil {
 		t.Errorf("Ping() error = %v", err)
 	}
+	if p := server.Requests()[0].Path; p != "/healthz" {
+		t.Errorf("unexpected path: %s", p)
+	}
 }
 
 func TestClient_Ping_Unhealthy(t *testing.T) {
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusServiceUnavailable)
-	}))
+	server := opencosttest.NewServer()
 	defer server.Close()
+	server.SetHealthy(false)
 
 	client := New(server.URL)
 	err := client.Ping(context.Background())
@@ -184,25 +464,10 @@ func TestClient_FetchExchangeRates_Success(t *testing.T) {
 	}))
 	defer server.Close()
 
-	// Override the default URL for testing
-	originalURL := DefaultExchangeRateURL
-	defer func() {
-		// Can't actually restore since it's a const, but the test uses a mock server
-	}()
-	_ = originalURL
-
-	// Create a custom test that uses the mock server
-	client := New(server.URL)
-	// We need to modify the test to use the mock server URL
-	// Since FetchExchangeRates uses a constant URL, we'll test the parsing logic
-	resp := &types.ExchangeRateResponse{
-		Amount: 1.0,
-		Base:   "USD",
-		Date:   "2026-01-20",
-		Rates: map[string]float64{
-			"CNY": 6.9589,
-			"EUR": 0.85266,
-		},
+	client := New("http://opencost.invalid", WithExchangeRateProvider(Frankfurter{URL: server.URL}))
+	resp, err := client.FetchExchangeRates(context.Background(), "USD", []string{"CNY", "EUR"})
+	if err != nil {
+		t.Fatalf("FetchExchangeRates() error = %v", err)
 	}
 
 	if resp.Base != "USD" {
@@ -214,8 +479,6 @@ func TestClient_FetchExchangeRates_Success(t *testing.T) {
 	if resp.Rates["CNY"] != 6.9589 {
 		t.Errorf("CNY rate = %v, want 6.9589", resp.Rates["CNY"])
 	}
-
-	_ = client // Avoid unused variable
 }
 
 func TestExchangeRateResponse_Parsing(t *testing.T) {
@@ -246,3 +509,71 @@ func TestExchangeRateResponse_Parsing(t *testing.T) {
 		t.Errorf("CNY rate = %v, want 6.9589", resp.Rates["CNY"])
 	}
 }
+
+func TestClient_FetchCloudCostsWindow(t *testing.T) {
+	server := opencosttest.NewServer()
+	defer server.Close()
+
+	client := New(server.URL, WithWindow("2d"))
+	if _, err := client.FetchCloudCostsWindow(context.Background(), "7d offset 7d"); err != nil {
+		t.Fatalf("FetchCloudCostsWindow() error = %v", err)
+	}
+
+	if w := server.Requests()[0].Query.Get("window"); !strings.Contains(w, ",") {
+		t.Errorf("window = %v, want explicit start,end range", w)
+	}
+}
+
+func TestClient_FetchCloudCosts_Tracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	before := otel.GetTracerProvider()
+	beforePropagator := otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(before)
+		otel.SetTextMapPropagator(beforePropagator)
+	})
+
+	server := opencosttest.NewServer(opencosttest.WithResponse(opencosttest.Response()))
+	defer server.Close()
+
+	if _, err := New(server.URL).FetchCloudCosts(context.Background()); err != nil {
+		t.Fatalf("FetchCloudCosts() error = %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	names := make(map[string]tracetest.SpanStub)
+	for _, s := range spans {
+		names[s.Name] = s
+	}
+	for _, want := range []string{"FetchCloudCosts", "GET /cloudCost", "decode"} {
+		if _, ok := names[want]; !ok {
+			t.Errorf("missing span %q (got %d spans)", want, len(spans))
+		}
+	}
+	if names["decode"].Parent.SpanID() != names["GET /cloudCost"].SpanContext.SpanID() {
+		t.Error("decode span should be a child of the HTTP span")
+	}
+	if server.Requests()[0].Header.Get("Traceparent") == "" {
+		t.Error("trace context should be propagated to OpenCost")
+	}
+}
+
+func TestClient_Get(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path + "?" + r.URL.RawQuery))
+	}))
+	defer server.Close()
+
+	resp, err := New(server.URL).Get(context.Background(), "/cloudCost/view/graph", "window=7d")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "/cloudCost/view/graph?window=7d" {
+		t.Errorf("upstream received %q", body)
+	}
+}
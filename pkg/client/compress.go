@@ -0,0 +1,59 @@
+package client
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// TransferStats reports the size of the OpenCost API responses read so
+// far, which OpenCost compresses with gzip if it supports it.
+type TransferStats struct {
+	// TransferredBytes is the size of the response bodies as received,
+	// compressed or not.
+	TransferredBytes int64
+	// Bytes is the size of the response bodies after decompression.
+	Bytes int64
+}
+
+// Transfers reports the size of the OpenCost API responses read so far.
+// Responses passed through Get are not counted.
+func (c *Client) Transfers() TransferStats {
+	return TransferStats{TransferredBytes: c.transferred.Load(), Bytes: c.decompressed.Load()}
+}
+
+// acceptGzip asks for a gzip-compressed response, which responseBody
+// decompresses. Setting the header turns off the transparent decompression
+// of http.Transport, which hides the compressed size.
+func acceptGzip(req *http.Request) {
+	req.Header.Set("Accept-Encoding", "gzip")
+}
+
+// responseBody returns the body of resp, decompressed if gzip-compressed,
+// counting the bytes read from it in Transfers.
+func (c *Client) responseBody(resp *http.Response) (io.Reader, error) {
+	var body io.Reader = &meteredReader{r: resp.Body, n: &c.transferred}
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("decompress response body: %w", err)
+		}
+		body = zr
+	}
+	return &meteredReader{r: body, n: &c.decompressed}, nil
+}
+
+// meteredReader adds the bytes read from r to n.
+type meteredReader struct {
+	r io.Reader
+	n *atomic.Int64
+}
+
+func (r *meteredReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,373 @@
+package client
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/requestid"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/secret"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+// ExchangeRateProvider is a source of currency exchange rates.
+type ExchangeRateProvider interface {
+	// Name identifies the provider, e.g. "frankfurter".
+	Name() string
+	// ExchangeRates returns the rates from base into symbols, or into all
+	// currencies the provider knows if symbols is empty. Symbols the
+	// provider does not know are left out. Requests are sent with hc, which
+	// carries neither OpenCost's credentials nor its TLS configuration.
+	ExchangeRates(ctx context.Context, hc *http.Client, base string, symbols []string) (*types.ExchangeRateResponse, error)
+}
+
+// Names of the exchange rate providers of NewExchangeRateProvider.
+const (
+	ProviderFrankfurter      = "frankfurter"
+	ProviderExchangeRateHost = "exchangerate.host"
+	ProviderECB              = "ecb"
+	ProviderFile             = "file"
+)
+
+// ExchangeRateProviders lists the names of all exchange rate providers.
+var ExchangeRateProviders = []string{ProviderFrankfurter, ProviderExchangeRateHost, ProviderECB, ProviderFile}
+
+// Default endpoints of the exchange rate providers.
+const (
+	// DefaultExchangeRateURL is the default Frankfurter API endpoint.
+	DefaultExchangeRateURL     = "https://api.frankfurter.dev/v1/latest"
+	DefaultExchangeRateHostURL = "https://api.exchangerate.host/live"
+	DefaultECBURL              = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-daily.xml"
+)
+
+// ExchangeRateOptions configures the provider of NewExchangeRateProvider.
+type ExchangeRateOptions struct {
+	// URL overrides the endpoint of an HTTP provider, e.g. for a mirror
+	// reachable from an air-gapped cluster.
+	URL string
+	// File is the rates file of the file provider.
+	File string
+	// AccessKey returns the access key of exchangerate.host.
+	AccessKey func() (string, error)
+}
+
+// NewExchangeRateProvider returns the provider named name, one of
+// ExchangeRateProviders.
+func NewExchangeRateProvider(name string, opts ExchangeRateOptions) (ExchangeRateProvider, error) {
+	switch name {
+	case ProviderFrankfurter:
+		return Frankfurter{URL: opts.URL}, nil
+	case ProviderExchangeRateHost:
+		if opts.AccessKey == nil {
+			return nil, errors.New("exchangerate.host needs an access key")
+		}
+		return ExchangeRateHost{URL: opts.URL, AccessKey: opts.AccessKey}, nil
+	case ProviderECB:
+		return ECB{URL: opts.URL}, nil
+	case ProviderFile:
+		if opts.File == "" {
+			return nil, errors.New("the file provider needs a rates file")
+		}
+		return RatesFile{Path: opts.File}, nil
+	default:
+		return nil, fmt.Errorf("unknown exchange rate provider %q, want one of %s", name, strings.Join(ExchangeRateProviders, ", "))
+	}
+}
+
+// WithExchangeRateProvider sets the source of FetchExchangeRates, Frankfurter
+// by default.
+func WithExchangeRateProvider(p ExchangeRateProvider) Option {
+	return func(c *Client) {
+		c.exchangeRates = p
+	}
+}
+
+// FetchExchangeRates fetches currency exchange rates from the configured
+// provider. After a 429 response, it fails with a *ThrottledError without a
+// request until the provider's Retry-After or an increasing backoff has
+// passed.
+func (c *Client) FetchExchangeRates(ctx context.Context, base string, symbols []string) (*types.ExchangeRateResponse, error) {
+	if err := c.throttles[TargetExchangeRates].check(); err != nil {
+		return nil, err
+	}
+	result, err := c.exchangeRates.ExchangeRates(withEndpoint(ctx, "rates"), c.rates, base, symbols)
+	var limited *rateLimitedError
+	if errors.As(err, &limited) {
+		return nil, c.throttles[TargetExchangeRates].record(limited.retryAfter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", c.exchangeRates.Name(), err)
+	}
+	c.throttles[TargetExchangeRates].reset()
+
+	slog.DebugContext(ctx, "parsed exchange rates",
+		"provider", c.exchangeRates.Name(),
+		"base", result.Base,
+		"date", result.Date,
+		"rates", result.Rates,
+	)
+	return result, nil
+}
+
+// Frankfurter fetches the reference rates of the European Central Bank from
+// the Frankfurter API, which converts them to any base.
+type Frankfurter struct {
+	// URL is the endpoint, DefaultExchangeRateURL if empty.
+	URL string
+}
+
+// Name implements ExchangeRateProvider.
+func (Frankfurter) Name() string { return ProviderFrankfurter }
+
+// ExchangeRates implements ExchangeRateProvider.
+func (f Frankfurter) ExchangeRates(ctx context.Context, hc *http.Client, base string, symbols []string) (*types.ExchangeRateResponse, error) {
+	q := url.Values{"base": {base}}
+	if len(symbols) > 0 {
+		q.Set("symbols", strings.Join(symbols, ","))
+	}
+	body, err := getRates(ctx, hc, cmp.Or(f.URL, DefaultExchangeRateURL), q)
+	if err != nil {
+		return nil, err
+	}
+	var result types.ExchangeRateResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	return &result, nil
+}
+
+// ExchangeRateHost fetches live rates from exchangerate.host, which needs an
+// access key. Bases other than USD need a paid plan.
+type ExchangeRateHost struct {
+	// URL is the endpoint, DefaultExchangeRateHostURL if empty.
+	URL string
+	// AccessKey is called for every request so that a rotated key is used.
+	AccessKey func() (string, error)
+}
+
+// Name implements ExchangeRateProvider.
+func (ExchangeRateHost) Name() string { return ProviderExchangeRateHost }
+
+// ExchangeRates implements ExchangeRateProvider.
+func (e ExchangeRateHost) ExchangeRates(ctx context.Context, hc *http.Client, base string, symbols []string) (*types.ExchangeRateResponse, error) {
+	key, err := e.AccessKey()
+	if err != nil {
+		return nil, fmt.Errorf("read access key: %w", err)
+	}
+	q := url.Values{"access_key": {key}, "source": {base}}
+	if len(symbols) > 0 {
+		q.Set("currencies", strings.Join(symbols, ","))
+	}
+	body, err := getRates(ctx, hc, cmp.Or(e.URL, DefaultExchangeRateHostURL), q)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Success   bool               `json:"success"`
+		Timestamp int64              `json:"timestamp"`
+		Source    string             `json:"source"`
+		Quotes    map[string]float64 `json:"quotes"`
+		Error     struct {
+			Code int    `json:"code"`
+			Info string `json:"info"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("error %d: %s", resp.Error.Code, resp.Error.Info)
+	}
+	// Quotes are keyed by the source and the currency, e.g. USDEUR.
+	rates := make(map[string]float64, len(resp.Quotes))
+	for pair, rate := range resp.Quotes {
+		if currency, ok := strings.CutPrefix(pair, resp.Source); ok {
+			rates[currency] = rate
+		}
+	}
+	return &types.ExchangeRateResponse{
+		Amount: 1,
+		Base:   resp.Source,
+		Date:   time.Unix(resp.Timestamp, 0).UTC().Format(time.DateOnly),
+		Rates:  rates,
+	}, nil
+}
+
+// ECB fetches the daily reference rates of the European Central Bank, which
+// are quoted in EUR, and converts them to the base.
+type ECB struct {
+	// URL is the endpoint, DefaultECBURL if empty.
+	URL string
+}
+
+// Name implements ExchangeRateProvider.
+func (ECB) Name() string { return ProviderECB }
+
+// ExchangeRates implements ExchangeRateProvider.
+func (e ECB) ExchangeRates(ctx context.Context, hc *http.Client, base string, symbols []string) (*types.ExchangeRateResponse, error) {
+	body, err := getRates(ctx, hc, cmp.Or(e.URL, DefaultECBURL), nil)
+	if err != nil {
+		return nil, err
+	}
+	var envelope struct {
+		Cube struct {
+			Cube struct {
+				Time  string `xml:"time,attr"`
+				Rates []struct {
+					Currency string  `xml:"currency,attr"`
+					Rate     float64 `xml:"rate,attr"`
+				} `xml:"Cube"`
+			} `xml:"Cube"`
+		} `xml:"Cube"`
+	}
+	if err := xml.Unmarshal(body, &envelope); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	day := envelope.Cube.Cube
+	if len(day.Rates) == 0 {
+		return nil, errors.New("no rates in response")
+	}
+	rates := make(map[string]float64, len(day.Rates))
+	for _, r := range day.Rates {
+		rates[r.Currency] = r.Rate
+	}
+	return rebase(&types.ExchangeRateResponse{Amount: 1, Base: "EUR", Date: day.Time, Rates: rates}, base, symbols)
+}
+
+// RatesFile reads rates from a JSON file in the format of the Frankfurter
+// API, e.g. for air-gapped clusters:
+//
+//	{"base": "USD", "date": "2026-01-20", "rates": {"EUR": 0.85266, "CNY": 6.9589}}
+//
+// The file is re-read on every fetch, so updated rates apply without a
+// restart. Rates are converted to other bases.
+type RatesFile struct {
+	Path string
+}
+
+// Name implements ExchangeRateProvider.
+func (RatesFile) Name() string { return ProviderFile }
+
+// ExchangeRates implements ExchangeRateProvider.
+func (f RatesFile) ExchangeRates(_ context.Context, _ *http.Client, base string, symbols []string) (*types.ExchangeRateResponse, error) {
+	b, err := os.ReadFile(f.Path)
+	if err != nil {
+		return nil, fmt.Errorf("read rates file: %w", err)
+	}
+	var rates types.ExchangeRateResponse
+	if err := json.Unmarshal(b, &rates); err != nil {
+		return nil, fmt.Errorf("parse rates file %s: %w", f.Path, err)
+	}
+	if rates.Base == "" {
+		return nil, fmt.Errorf("rates file %s has no base currency", f.Path)
+	}
+	return rebase(&rates, base, symbols)
+}
+
+// rebase converts rates to base and keeps only symbols, unless empty.
+func rebase(rates *types.ExchangeRateResponse, base string, symbols []string) (*types.ExchangeRateResponse, error) {
+	quotes := make(map[string]float64, len(rates.Rates)+1)
+	for currency, rate := range rates.Rates {
+		quotes[currency] = rate
+	}
+	quotes[rates.Base] = 1
+	divisor, ok := quotes[base]
+	if !ok || divisor <= 0 {
+		return nil, fmt.Errorf("no rate for base currency %s", base)
+	}
+	result := &types.ExchangeRateResponse{Amount: 1, Base: base, Date: rates.Date, Rates: make(map[string]float64)}
+	for currency, rate := range quotes {
+		if currency == base || (len(symbols) > 0 && !slices.Contains(symbols, currency)) {
+			continue
+		}
+		result.Rates[currency] = rate / divisor
+	}
+	return result, nil
+}
+
+// rateLimitedError is returned by getRates on a 429 response.
+type rateLimitedError struct {
+	retryAfter string
+}
+
+func (e *rateLimitedError) Error() string {
+	return "too many requests"
+}
+
+// getRates requests endpoint with the query q from an exchange rate API and
+// returns the body of a successful response.
+func getRates(ctx context.Context, hc *http.Client, endpoint string, q url.Values) ([]byte, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parse exchange rate URL: %w", err)
+	}
+	if len(q) > 0 {
+		query := u.Query()
+		for name, values := range q {
+			query[name] = values
+		}
+		u.RawQuery = query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	requestid.SetHeader(req)
+
+	// Access keys are part of the query, so only the path is logged.
+	logURL := u.Scheme + "://" + u.Host + u.Path
+	slog.DebugContext(ctx, "sending HTTP request",
+		"method", req.Method,
+		"url", logURL,
+		"headers", req.Header,
+	)
+
+	resp, err := hc.Do(req)
+	if err != nil {
+		slog.DebugContext(ctx, "HTTP request failed",
+			"method", req.Method,
+			"url", logURL,
+			"error", err,
+		)
+		return nil, fmt.Errorf("do request %s: %w", logURL, secret.StripURL(err))
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response body: %w", err)
+	}
+
+	bodyPreview := string(body)
+	if len(bodyPreview) > 500 {
+		bodyPreview = bodyPreview[:500] + "... (truncated)"
+	}
+	slog.DebugContext(ctx, "received HTTP response",
+		"status_code", resp.StatusCode,
+		"status", resp.Status,
+		"content_length", resp.ContentLength,
+		"headers", resp.Header,
+		"body_preview", bodyPreview,
+	)
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, &rateLimitedError{retryAfter: resp.Header.Get("Retry-After")}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+	}
+	return body, nil
+}
@@ -0,0 +1,178 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ratesServer serves body with status and records the last request query.
+func ratesServer(t *testing.T, status int, body string) (*httptest.Server, *string) {
+	t.Helper()
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "60")
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server, &query
+}
+
+// approx reports whether got is within rounding of want.
+func approx(got, want float64) bool {
+	return math.Abs(got-want) < 1e-9
+}
+
+func TestNewExchangeRateProvider(t *testing.T) {
+	key := func() (string, error) { return "key", nil }
+	tests := []struct {
+		name    string
+		opts    ExchangeRateOptions
+		want    string
+		wantErr bool
+	}{
+		{name: ProviderFrankfurter, want: ProviderFrankfurter},
+		{name: ProviderExchangeRateHost, opts: ExchangeRateOptions{AccessKey: key}, want: ProviderExchangeRateHost},
+		{name: ProviderExchangeRateHost, wantErr: true},
+		{name: ProviderECB, want: ProviderECB},
+		{name: ProviderFile, opts: ExchangeRateOptions{File: "rates.json"}, want: ProviderFile},
+		{name: ProviderFile, wantErr: true},
+		{name: "openexchangerates", wantErr: true},
+	}
+	for _, tt := range tests {
+		p, err := NewExchangeRateProvider(tt.name, tt.opts)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NewExchangeRateProvider(%q, %+v) error = %v, wantErr %v", tt.name, tt.opts, err, tt.wantErr)
+			continue
+		}
+		if err == nil && p.Name() != tt.want {
+			t.Errorf("NewExchangeRateProvider(%q).Name() = %q, want %q", tt.name, p.Name(), tt.want)
+		}
+	}
+}
+
+func TestExchangeRateHost(t *testing.T) {
+	server, query := ratesServer(t, http.StatusOK,
+		`{"success":true,"timestamp":1768906800,"source":"USD","quotes":{"USDEUR":0.85266,"USDCNY":6.9589}}`)
+	client := New("http://opencost.invalid", WithExchangeRateProvider(ExchangeRateHost{
+		URL:       server.URL,
+		AccessKey: func() (string, error) { return "secret", nil },
+	}))
+
+	resp, err := client.FetchExchangeRates(context.Background(), "USD", []string{"EUR", "CNY"})
+	if err != nil {
+		t.Fatalf("FetchExchangeRates() error = %v", err)
+	}
+	if resp.Base != "USD" || resp.Date != "2026-01-20" || resp.Rates["EUR"] != 0.85266 || resp.Rates["CNY"] != 6.9589 {
+		t.Errorf("FetchExchangeRates() = %+v", resp)
+	}
+	if want := "access_key=secret&currencies=EUR%2CCNY&source=USD"; *query != want {
+		t.Errorf("query = %q, want %q", *query, want)
+	}
+
+	server, _ = ratesServer(t, http.StatusOK, `{"success":false,"error":{"code":101,"info":"invalid access key"}}`)
+	client = New("http://opencost.invalid", WithExchangeRateProvider(ExchangeRateHost{
+		URL:       server.URL,
+		AccessKey: func() (string, error) { return "wrong", nil },
+	}))
+	if _, err := client.FetchExchangeRates(context.Background(), "USD", nil); err == nil || !strings.Contains(err.Error(), "invalid access key") {
+		t.Errorf("FetchExchangeRates() error = %v, want the API error", err)
+	}
+}
+
+func TestECB(t *testing.T) {
+	server, _ := ratesServer(t, http.StatusOK, `<?xml version="1.0" encoding="UTF-8"?>
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<gesmes:subject>Reference rates</gesmes:subject>
+	<Cube>
+		<Cube time="2026-01-20">
+			<Cube currency="USD" rate="1.25"/>
+			<Cube currency="JPY" rate="150"/>
+			<Cube currency="CNY" rate="8.5"/>
+		</Cube>
+	</Cube>
+</gesmes:Envelope>`)
+	client := New("http://opencost.invalid", WithExchangeRateProvider(ECB{URL: server.URL}))
+
+	resp, err := client.FetchExchangeRates(context.Background(), "USD", []string{"EUR", "CNY", "XXX"})
+	if err != nil {
+		t.Fatalf("FetchExchangeRates() error = %v", err)
+	}
+	if resp.Base != "USD" || resp.Date != "2026-01-20" {
+		t.Errorf("FetchExchangeRates() base, date = %s, %s, want USD, 2026-01-20", resp.Base, resp.Date)
+	}
+	want := map[string]float64{"EUR": 0.8, "CNY": 6.8}
+	if len(resp.Rates) != len(want) {
+		t.Errorf("Rates = %v, want %v", resp.Rates, want)
+	}
+	for currency, rate := range want {
+		if !approx(resp.Rates[currency], rate) {
+			t.Errorf("%s rate = %v, want %v", currency, resp.Rates[currency], rate)
+		}
+	}
+
+	if _, err := client.FetchExchangeRates(context.Background(), "GBP", nil); err == nil {
+		t.Error("FetchExchangeRates() with an unknown base should fail")
+	}
+}
+
+func TestRatesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	write := func(s string) {
+		if err := os.WriteFile(path, []byte(s), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client := New("http://opencost.invalid", WithExchangeRateProvider(RatesFile{Path: path}))
+	ctx := context.Background()
+
+	write(`{"base": "USD", "date": "2026-01-20", "rates": {"EUR": 0.8, "CNY": 7}}`)
+	resp, err := client.FetchExchangeRates(ctx, "USD", []string{"EUR"})
+	if err != nil {
+		t.Fatalf("FetchExchangeRates() error = %v", err)
+	}
+	if len(resp.Rates) != 1 || resp.Rates["EUR"] != 0.8 {
+		t.Errorf("Rates = %v, want only EUR 0.8", resp.Rates)
+	}
+
+	// The file is re-read, and rates from another base are converted.
+	write(`{"base": "EUR", "date": "2026-01-21", "rates": {"USD": 1.25}}`)
+	resp, err = client.FetchExchangeRates(ctx, "USD", nil)
+	if err != nil {
+		t.Fatalf("FetchExchangeRates() error = %v", err)
+	}
+	if resp.Date != "2026-01-21" || !approx(resp.Rates["EUR"], 0.8) {
+		t.Errorf("FetchExchangeRates() = %+v, want EUR 0.8 of 2026-01-21", resp)
+	}
+
+	for _, invalid := range []string{`{"rates": {"EUR": 0.8}}`, `not json`} {
+		write(invalid)
+		if _, err := client.FetchExchangeRates(ctx, "USD", nil); err == nil {
+			t.Errorf("FetchExchangeRates() of %q should fail", invalid)
+		}
+	}
+}
+
+func TestClient_FetchExchangeRates_Throttled(t *testing.T) {
+	server, _ := ratesServer(t, http.StatusTooManyRequests, "")
+	client := New("http://opencost.invalid", WithExchangeRateProvider(Frankfurter{URL: server.URL}))
+
+	_, err := client.FetchExchangeRates(context.Background(), "USD", nil)
+	var throttled *ThrottledError
+	if !errors.As(err, &throttled) || throttled.Target != TargetExchangeRates {
+		t.Fatalf("FetchExchangeRates() error = %v, want a ThrottledError", err)
+	}
+	if stats := client.Throttling(TargetExchangeRates); stats.Count != 1 {
+		t.Errorf("Throttling() = %+v, want one 429", stats)
+	}
+}
@@ -0,0 +1,159 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+// Flavor is a dialect of the cloud cost API: OpenCost's, or that of a
+// product serving a compatible one under other paths.
+type Flavor struct {
+	Name string
+	// CloudCostPath is the path of the cloud cost endpoint, relative to
+	// the base URL.
+	CloudCostPath string
+	// AllocationPath is the path of the Kubernetes allocation endpoint.
+	AllocationPath string
+	// AssetsPath is the path of the cluster assets endpoint.
+	AssetsPath string
+	// PageSize is the number of cost items per set requested at once with
+	// the limit and offset parameters, or 0 to request all items in one
+	// response.
+	PageSize int
+}
+
+var (
+	// OpenCost is the API of OpenCost, the default.
+	OpenCost = Flavor{Name: "opencost", CloudCostPath: "/cloudCost", AllocationPath: "/allocation", AssetsPath: "/assets"}
+	// Kubecost is the API of Kubecost, which serves cloud costs under
+	// /model/cloudCost and pages large responses. Kubecost adds fields
+	// OpenCost does not send; decoding ignores them.
+	Kubecost = Flavor{Name: "kubecost", CloudCostPath: "/model/cloudCost", AllocationPath: "/model/allocation", AssetsPath: "/model/assets", PageSize: 1000}
+)
+
+// Flavors lists the supported flavors.
+var Flavors = []Flavor{OpenCost, Kubecost}
+
+// ParseFlavor returns the flavor called name.
+func ParseFlavor(name string) (Flavor, error) {
+	names := make([]string, len(Flavors))
+	for i, f := range Flavors {
+		if strings.EqualFold(f.Name, name) {
+			return f, nil
+		}
+		names[i] = f.Name
+	}
+	return Flavor{}, fmt.Errorf("unknown API flavor %q, want one of %s", name, strings.Join(names, ", "))
+}
+
+// WithFlavor sets the dialect of the API; the default is OpenCost.
+func WithFlavor(f Flavor) Option {
+	return func(c *Client) {
+		c.flavor = f
+	}
+}
+
+// Flavor returns the dialect of the API.
+func (c *Client) Flavor() Flavor {
+	return c.flavor
+}
+
+// path maps a path of OpenCost's cloud cost API, e.g. as requested from the
+// caching proxy, to the flavor's.
+func (f Flavor) path(p string) string {
+	if rest, ok := strings.CutPrefix(p, OpenCost.CloudCostPath); ok && (rest == "" || rest[0] == '/') {
+		return f.CloudCostPath + rest
+	}
+	return p
+}
+
+// fetchPages fetches the response for u page by page and merges the pages.
+// Fetching stops at the first page on which no set is full, or that adds no
+// items, so that a server ignoring the paging parameters is asked only
+// twice.
+func (c *Client) fetchPages(ctx context.Context, u *url.URL) (*types.CloudCostResponse, error) {
+	var result *types.CloudCostResponse
+	for offset := 0; ; offset += c.flavor.PageSize {
+		q := u.Query()
+		q.Set("limit", strconv.Itoa(c.flavor.PageSize))
+		q.Set("offset", strconv.Itoa(offset))
+		page := *u
+		page.RawQuery = q.Encode()
+
+		resp, err := c.fetchRetrying(ctx, page.String())
+		if err != nil {
+			if offset > 0 {
+				return nil, fmt.Errorf("page at offset %d: %w", offset, err)
+			}
+			return nil, err
+		}
+		if result == nil {
+			result = resp
+			if !fullPage(resp, c.flavor.PageSize) {
+				return result, nil
+			}
+			continue
+		}
+		if mergePage(result, resp) == 0 || !fullPage(resp, c.flavor.PageSize) {
+			return result, nil
+		}
+	}
+}
+
+// fullPage reports whether a set of page holds size items or more, so that
+// more may follow.
+func fullPage(page *types.CloudCostResponse, size int) bool {
+	for _, set := range page.Data.Sets {
+		if len(set.CloudCosts)+len(set.Skipped()) >= size {
+			return true
+		}
+	}
+	return false
+}
+
+// mergePage adds the items of page to the sets of dst with the same window,
+// or its sets to dst if dst has none with their window, and returns the
+// number of items added.
+func mergePage(dst, page *types.CloudCostResponse) int {
+	added := 0
+	for _, set := range page.Data.Sets {
+		i := setIndex(dst, set.Window)
+		if i < 0 {
+			dst.Data.Sets = append(dst.Data.Sets, set)
+			added += len(set.CloudCosts)
+			continue
+		}
+		into := &dst.Data.Sets[i]
+		if into.CloudCosts == nil {
+			into.CloudCosts = make(map[string]types.CloudCostItem, len(set.CloudCosts))
+		}
+		n := 0
+		for key, item := range set.CloudCosts {
+			if _, ok := into.CloudCosts[key]; !ok {
+				into.CloudCosts[key] = item
+				n++
+			}
+		}
+		// A page repeating items repeats their skipped ones as well.
+		if n > 0 {
+			into.Skip(set.Skipped()...)
+		}
+		added += n
+	}
+	return added
+}
+
+// setIndex returns the index of the set of r with window w, or -1.
+func setIndex(r *types.CloudCostResponse, w types.Window) int {
+	for i, set := range r.Data.Sets {
+		if set.Window.Start.Equal(w.Start) && set.Window.End.Equal(w.End) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/opencosttest"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+// kubecostServer serves items cost items from /model/cloudCost, paged by
+// limit and offset unless ignorePaging is set, and records the requests.
+func kubecostServer(t *testing.T, items int, ignorePaging bool) (*httptest.Server, func() []string) {
+	t.Helper()
+	all := make([]types.CloudCostItem, items)
+	for i := range all {
+		all[i] = opencosttest.Item("", fmt.Sprintf("service-%d", i), "Compute", 1)
+		all[i].Properties.ProviderID = fmt.Sprintf("id-%d", i)
+	}
+	var mu sync.Mutex
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		queries = append(queries, r.URL.RawQuery)
+		mu.Unlock()
+		if r.URL.Path != "/model/cloudCost" {
+			http.NotFound(w, r)
+			return
+		}
+		page := all
+		if !ignorePaging {
+			offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+			limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+			page = all[min(offset, len(all)):min(offset+limit, len(all))]
+		}
+		json.NewEncoder(w).Encode(opencosttest.Response(page...))
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), queries...)
+	}
+}
+
+func TestClient_WithFlavor_Kubecost(t *testing.T) {
+	tests := []struct {
+		name         string
+		items        int
+		ignorePaging bool
+		wantRequests int
+	}{
+		{name: "one page", items: 1, wantRequests: 1},
+		{name: "full pages", items: 4, wantRequests: 3},
+		{name: "last page partial", items: 5, wantRequests: 3},
+		{name: "paging ignored", items: 5, ignorePaging: true, wantRequests: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, queries := kubecostServer(t, tt.items, tt.ignorePaging)
+			flavor := Kubecost
+			flavor.PageSize = 2
+
+			client := New(server.URL, WithMaxRetries(0), WithFlavor(flavor))
+			resp, err := client.FetchCloudCosts(context.Background())
+			if err != nil {
+				t.Fatalf("FetchCloudCosts() error = %v", err)
+			}
+			if len(resp.Data.Sets) != 1 || len(resp.Data.Sets[0].CloudCosts) != tt.items {
+				t.Errorf("FetchCloudCosts() = %+v, want one set of %d items", resp.Data.Sets, tt.items)
+			}
+			got := queries()
+			if len(got) != tt.wantRequests {
+				t.Fatalf("requests = %q, want %d", got, tt.wantRequests)
+			}
+			if want := "limit=2&offset=2&window=1d"; len(got) > 1 && got[1] != want {
+				t.Errorf("second request query = %q, want %q", got[1], want)
+			}
+		})
+	}
+}
+
+func TestClient_WithFlavor_Get(t *testing.T) {
+	server, queries := kubecostServer(t, 1, false)
+	client := New(server.URL, WithFlavor(Kubecost))
+	resp, err := client.Get(context.Background(), "/cloudCost", "window=1d")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Get(/cloudCost) status = %d, want it mapped to /model/cloudCost", resp.StatusCode)
+	}
+	if got := queries(); len(got) != 1 || got[0] != "window=1d" {
+		t.Errorf("requests = %q, want one unpaged request", got)
+	}
+}
+
+func TestParseFlavor(t *testing.T) {
+	for _, name := range []string{"opencost", "Kubecost"} {
+		if _, err := ParseFlavor(name); err != nil {
+			t.Errorf("ParseFlavor(%q) error = %v", name, err)
+		}
+	}
+	if _, err := ParseFlavor("cloudability"); err == nil {
+		t.Error("ParseFlavor(cloudability) should fail")
+	}
+}
@@ -0,0 +1,42 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ParseHeaders parses comma-separated Name=value headers for WithHeaders,
+// e.g. "X-Scope-OrgID=finance,X-Forwarded-User=exporter". A name given
+// more than once is sent with all its values.
+func ParseHeaders(s string) (http.Header, error) {
+	headers := make(http.Header)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(item, "=")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid header %q: want Name=value", item)
+		}
+		if strings.ContainsFunc(name, func(r rune) bool { return !isTokenChar(r) }) {
+			return nil, fmt.Errorf("invalid header name %q", name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("invalid value of header %s: contains a line break", name)
+		}
+		headers.Add(name, value)
+	}
+	return headers, nil
+}
+
+// isTokenChar reports whether r may appear in a header name (RFC 9110).
+func isTokenChar(r rune) bool {
+	switch {
+	case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+		return true
+	}
+	return strings.ContainsRune("!#$%&'*+-.^_`|~", r)
+}
@@ -0,0 +1,42 @@
+package client
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestParseHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    http.Header
+		wantErr bool
+	}{
+		{name: "empty", input: "", want: http.Header{}},
+		{name: "one", input: "X-Scope-OrgID=finance", want: http.Header{"X-Scope-Orgid": {"finance"}}},
+		{
+			name:  "several with spaces",
+			input: " x-tenant = finance , X-Forwarded-User=exporter,",
+			want:  http.Header{"X-Tenant": {"finance"}, "X-Forwarded-User": {"exporter"}},
+		},
+		{name: "repeated", input: "X-Team=a,X-Team=b", want: http.Header{"X-Team": {"a", "b"}}},
+		{name: "value with equals sign", input: "Cookie=session=abc", want: http.Header{"Cookie": {"session=abc"}}},
+		{name: "empty value", input: "X-Empty=", want: http.Header{"X-Empty": {""}}},
+		{name: "missing value", input: "X-Tenant", wantErr: true},
+		{name: "missing name", input: "=finance", wantErr: true},
+		{name: "invalid name", input: "X Tenant=finance", wantErr: true},
+		{name: "line break in value", input: "X-Tenant=a\r\nX-Admin: true", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseHeaders(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseHeaders(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseHeaders(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
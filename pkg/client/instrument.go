@@ -0,0 +1,107 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// endpointOther is the endpoint of requests made through Get, e.g. by the
+// caching proxy, whose paths are chosen by its callers.
+const endpointOther = "other"
+
+// RequestMetrics counts and times the HTTP requests of clients, like
+// promhttp.InstrumentRoundTripperCounter and
+// promhttp.InstrumentRoundTripperDuration, by target and endpoint, so that
+// a slow or failing backend can be told apart. One RequestMetrics may be
+// shared by several clients. It implements prometheus.Collector.
+type RequestMetrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// NewRequestMetrics creates the request metrics.
+func NewRequestMetrics() *RequestMetrics {
+	return &RequestMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "cloudcost_exporter",
+			Name:      "client_requests_total",
+			Help:      "HTTP requests to OpenCost and the exchange rate provider by target, endpoint and status code, or error for requests without a response",
+		}, []string{"target", "endpoint", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "cloudcost_exporter",
+			Name:      "client_request_duration_seconds",
+			Help:      "Time until the response headers of HTTP requests to OpenCost and the exchange rate provider arrived, by target and endpoint",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"target", "endpoint"}),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (m *RequestMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.requests.Describe(ch)
+	m.duration.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (m *RequestMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.requests.Collect(ch)
+	m.duration.Collect(ch)
+}
+
+// WithRequestMetrics records the client's requests in m: those to OpenCost
+// under the target "opencost" and the path of the API, e.g. "/cloudCost",
+// and those to the exchange rate provider under its name and "rates".
+func WithRequestMetrics(m *RequestMetrics) Option {
+	return func(c *Client) {
+		c.requestMetrics = m
+	}
+}
+
+// instrument wraps next, http.DefaultTransport if nil, to record its
+// requests under target.
+func (m *RequestMetrics) instrument(next http.RoundTripper, target string) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return instrumentedTransport{next: next, target: target, m: m}
+}
+
+type instrumentedTransport struct {
+	next   http.RoundTripper
+	target string
+	m      *RequestMetrics
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	endpoint := endpointFrom(req.Context())
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	t.m.requests.WithLabelValues(t.target, endpoint, code).Inc()
+	t.m.duration.WithLabelValues(t.target, endpoint).Observe(time.Since(start).Seconds())
+	return resp, err
+}
+
+type endpointKey struct{}
+
+// withEndpoint returns a copy of ctx whose requests are recorded under
+// endpoint.
+func withEndpoint(ctx context.Context, endpoint string) context.Context {
+	return context.WithValue(ctx, endpointKey{}, endpoint)
+}
+
+// endpointFrom returns the endpoint of withEndpoint, or endpointOther.
+func endpointFrom(ctx context.Context) string {
+	if endpoint, ok := ctx.Value(endpointKey{}).(string); ok {
+		return endpoint
+	}
+	return endpointOther
+}
@@ -0,0 +1,69 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/opencosttest"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+func TestWithRequestMetrics(t *testing.T) {
+	server := opencosttest.NewServer()
+	defer server.Close()
+	rates := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(types.ExchangeRateResponse{Base: "USD", Rates: map[string]float64{"EUR": 0.9}})
+	}))
+	defer rates.Close()
+
+	m := NewRequestMetrics()
+	client := New(server.URL, WithRequestMetrics(m), WithMaxRetries(0),
+		WithExchangeRateProvider(Frankfurter{URL: rates.URL}))
+	if _, err := client.FetchCloudCosts(context.Background()); err != nil {
+		t.Fatalf("FetchCloudCosts() error = %v", err)
+	}
+	if err := client.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping() error = %v", err)
+	}
+	if _, err := client.FetchExchangeRates(context.Background(), "USD", []string{"EUR"}); err != nil {
+		t.Fatalf("FetchExchangeRates() error = %v", err)
+	}
+	resp, err := client.Get(context.Background(), "/cloudCost/status", "")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	resp.Body.Close()
+	server.FailNext(1, http.StatusServiceUnavailable)
+	if _, err := client.FetchCloudCosts(context.Background()); err == nil {
+		t.Fatal("FetchCloudCosts() should fail on 503")
+	}
+
+	// A second client sharing the metrics, whose OpenCost is down.
+	down := New("http://127.0.0.1:1", WithRequestMetrics(m), WithMaxRetries(0))
+	if _, err := down.FetchCloudCosts(context.Background()); err == nil {
+		t.Fatal("FetchCloudCosts() should fail without OpenCost")
+	}
+
+	want := `
+# HELP cloudcost_exporter_client_requests_total HTTP requests to OpenCost and the exchange rate provider by target, endpoint and status code, or error for requests without a response
+# TYPE cloudcost_exporter_client_requests_total counter
+cloudcost_exporter_client_requests_total{code="200",endpoint="/cloudCost",target="opencost"} 1
+cloudcost_exporter_client_requests_total{code="200",endpoint="/healthz",target="opencost"} 1
+cloudcost_exporter_client_requests_total{code="200",endpoint="rates",target="frankfurter"} 1
+cloudcost_exporter_client_requests_total{code="404",endpoint="other",target="opencost"} 1
+cloudcost_exporter_client_requests_total{code="503",endpoint="/cloudCost",target="opencost"} 1
+cloudcost_exporter_client_requests_total{code="error",endpoint="/cloudCost",target="opencost"} 1
+`
+	if err := testutil.CollectAndCompare(m, strings.NewReader(want), "cloudcost_exporter_client_requests_total"); err != nil {
+		t.Error(err)
+	}
+	if n := testutil.CollectAndCount(m, "cloudcost_exporter_client_request_duration_seconds"); n != 4 {
+		t.Errorf("duration series = %d, want one per target and endpoint", n)
+	}
+}
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+// AllocationAggregate is the aggregation FetchAllocations requests.
+const AllocationAggregate = "namespace,controller,pod"
+
+// FetchAllocations fetches the cost of the Kubernetes workloads over the
+// configured window from the allocation API, aggregated by namespace,
+// controller and pod and accumulated into one set, with retry support.
+func (c *Client) FetchAllocations(ctx context.Context) (_ *types.AllocationResponse, err error) {
+	ctx, span := tracer.Start(ctx, "FetchAllocations", trace.WithAttributes(attribute.String("opencost.window", c.Window())))
+	defer func() { endSpan(span, err) }()
+
+	q := url.Values{}
+	q.Set("aggregate", AllocationAggregate)
+	q.Set("accumulate", "true")
+	return fetchModel[types.AllocationResponse](ctx, c, c.flavor.AllocationPath, q)
+}
+
+// FetchAssets fetches the cost of the cluster assets, such as nodes, disks
+// and load balancers, over the configured window from the assets API,
+// accumulated into one set, with retry support.
+func (c *Client) FetchAssets(ctx context.Context) (_ *types.AssetResponse, err error) {
+	ctx, span := tracer.Start(ctx, "FetchAssets", trace.WithAttributes(attribute.String("opencost.window", c.Window())))
+	defer func() { endSpan(span, err) }()
+
+	q := url.Values{}
+	q.Set("accumulate", "true")
+	return fetchModel[types.AssetResponse](ctx, c, c.flavor.AssetsPath, q)
+}
+
+// validator is a response of an OpenCost API that can check itself.
+type validator[T any] interface {
+	*T
+	Validate() error
+}
+
+// fetchModel fetches the response of an OpenCost API other than the cloud
+// cost one for the configured window, retrying failures.
+func fetchModel[T any, PT validator[T]](ctx context.Context, c *Client, path string, q url.Values) (*T, error) {
+	window, err := ResolveWindow(c.Window(), time.Now().In(c.location))
+	if err != nil {
+		return nil, err
+	}
+	q.Set("window", window)
+
+	if err := c.throttles[TargetOpenCost].check(); err != nil {
+		return nil, err
+	}
+	return retrying(ctx, c, func(ctx context.Context) (*T, error) {
+		return getModel[T, PT](ctx, c, path, q.Encode())
+	})
+}
+
+func getModel[T any, PT validator[T]](ctx context.Context, c *Client, path, rawQuery string) (*T, error) {
+	start := time.Now()
+	req, err := c.newRequest(withEndpoint(ctx, path), path, rawQuery)
+	if err != nil {
+		return nil, err
+	}
+	acceptGzip(req)
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	c.answered.Store(true)
+
+	respBody, err := c.responseBody(resp)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(respBody)
+	if err != nil {
+		return nil, fmt.Errorf("read response body: %w", err)
+	}
+	observeStage(ctx, StageFetch, start)
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, c.throttles[TargetOpenCost].record(resp.Header.Get("Retry-After"))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newStatusError(resp, body)
+	}
+	c.throttles[TargetOpenCost].reset()
+
+	start = time.Now()
+	defer observeStage(ctx, StageDecode, start)
+	var result T
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	if err := PT(&result).Validate(); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
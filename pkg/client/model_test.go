@@ -0,0 +1,122 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+const allocationBody = `{"code": 200, "data": [{
+	"kube-system/coredns/coredns-5d78c9869d-abcde": {
+		"name": "kube-system/coredns/coredns-5d78c9869d-abcde",
+		"properties": {"cluster": "main", "namespace": "kube-system", "controllerKind": "deployment", "controller": "coredns", "pod": "coredns-5d78c9869d-abcde"},
+		"window": {"start": "2026-01-01T00:00:00Z", "end": "2026-01-02T00:00:00Z"},
+		"cpuCost": 1.5, "gpuCost": 0, "ramCost": 0.5, "pvCost": null, "networkCost": 0.1,
+		"loadBalancerCost": 0, "sharedCost": 0, "externalCost": 0, "totalCost": 2.1
+	}
+}]}`
+
+func TestClient_FetchAllocations(t *testing.T) {
+	tests := []struct {
+		name     string
+		flavor   Flavor
+		path     string
+		code     int
+		body     string
+		wantCost float64
+		wantErr  error
+	}{
+		{name: "opencost", flavor: OpenCost, path: "/allocation", body: allocationBody, wantCost: 1.5},
+		{name: "kubecost", flavor: Kubecost, path: "/model/allocation", body: allocationBody, wantCost: 1.5},
+		{name: "failed", flavor: OpenCost, path: "/allocation", body: `{"code": 500, "message": "boom"}`, wantErr: types.ErrInvalidResponse},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var query map[string][]string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != tt.path {
+					http.NotFound(w, r)
+					return
+				}
+				query = r.URL.Query()
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			c := New(server.URL, WithFlavor(tt.flavor), WithWindow("1d"), WithMaxRetries(0))
+			resp, err := c.FetchAllocations(context.Background())
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("FetchAllocations() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FetchAllocations() error = %v", err)
+			}
+			if got := query["aggregate"]; len(got) != 1 || got[0] != AllocationAggregate {
+				t.Errorf("aggregate = %v, want %s", got, AllocationAggregate)
+			}
+			if got := query["accumulate"]; len(got) != 1 || got[0] != "true" {
+				t.Errorf("accumulate = %v, want true", got)
+			}
+			if len(resp.Data) != 1 {
+				t.Fatalf("got %d sets, want 1", len(resp.Data))
+			}
+			a := resp.Data[0]["kube-system/coredns/coredns-5d78c9869d-abcde"]
+			if a.CPUCost != tt.wantCost || a.Properties.Controller != "coredns" || a.Properties.Pod != "coredns-5d78c9869d-abcde" {
+				t.Errorf("allocation = %+v", a)
+			}
+		})
+	}
+}
+
+func TestClient_FetchAssets(t *testing.T) {
+	const node = `{"type": "Node", "properties": {"category": "Compute", "provider": "AWS", "cluster": "main", "name": "ip-10-0-0-1", "providerID": "i-0abc"}, "totalCost": %v}`
+	tests := []struct {
+		name     string
+		flavor   Flavor
+		path     string
+		body     string
+		wantCost float64
+	}{
+		{name: "opencost set", flavor: OpenCost, path: "/assets", body: `{"code": 200, "data": {"main/node/ip-10-0-0-1": ` + fmt.Sprintf(node, 2.5) + `}}`, wantCost: 2.5},
+		{name: "kubecost sets", flavor: Kubecost, path: "/model/assets", body: `{"code": 200, "data": [{"main/node/ip-10-0-0-1": ` + fmt.Sprintf(node, 2.5) + `}, {"main/node/ip-10-0-0-1": ` + fmt.Sprintf(node, 1) + `}]}`, wantCost: 3.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var query url.Values
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != tt.path {
+					http.NotFound(w, r)
+					return
+				}
+				query = r.URL.Query()
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			c := New(server.URL, WithFlavor(tt.flavor), WithWindow("1d"), WithMaxRetries(0))
+			resp, err := c.FetchAssets(context.Background())
+			if err != nil {
+				t.Fatalf("FetchAssets() error = %v", err)
+			}
+			if query.Get("window") != "1d" || query.Get("accumulate") != "true" {
+				t.Errorf("query = %v, want window 1d accumulated", query)
+			}
+			a, ok := resp.Data["main/node/ip-10-0-0-1"]
+			if !ok || len(resp.Data) != 1 {
+				t.Fatalf("assets = %+v, want the node", resp.Data)
+			}
+			if a.TotalCost != tt.wantCost || a.TypeName() != "node" || a.Properties.ProviderID != "i-0abc" {
+				t.Errorf("asset = %+v, want node i-0abc costing %v", a, tt.wantCost)
+			}
+		})
+	}
+}
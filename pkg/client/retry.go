@@ -0,0 +1,141 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy configures the retries of failed OpenCost requests. The n-th
+// retry waits InitialBackoff * Multiplier^(n-1), capped at MaxBackoff, and
+// randomized by Jitter.
+type RetryPolicy struct {
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	// Jitter randomizes each wait by up to that fraction of itself in
+	// either direction, e.g. 0.2 for ±20%, so that exporters failing
+	// together do not retry in lockstep. 0 disables it.
+	Jitter float64
+}
+
+// DefaultRetryPolicy retries three times after about 1s, 2s and 4s.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries:     3,
+	InitialBackoff: time.Second,
+	MaxBackoff:     30 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
+// Validate checks that the policy is usable.
+func (p RetryPolicy) Validate() error {
+	var errs []error
+	if p.MaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("max retries %d is negative", p.MaxRetries))
+	}
+	if p.InitialBackoff <= 0 {
+		errs = append(errs, fmt.Errorf("initial backoff %s is not positive", p.InitialBackoff))
+	}
+	if p.MaxBackoff < p.InitialBackoff {
+		errs = append(errs, fmt.Errorf("max backoff %s is less than the initial backoff %s", p.MaxBackoff, p.InitialBackoff))
+	}
+	if p.Multiplier < 1 {
+		errs = append(errs, fmt.Errorf("backoff multiplier %g is less than 1", p.Multiplier))
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		errs = append(errs, fmt.Errorf("jitter %g is not between 0 and 1", p.Jitter))
+	}
+	return errors.Join(errs...)
+}
+
+// Backoff returns the wait before the given retry, counting from 1.
+func (p RetryPolicy) Backoff(retry int) time.Duration {
+	d := float64(p.InitialBackoff)
+	for i := 1; i < retry && d < float64(p.MaxBackoff); i++ {
+		d *= p.Multiplier
+	}
+	return min(time.Duration(d), p.MaxBackoff)
+}
+
+// Wait returns the wait before the given retry, counting from 1: the
+// backoff randomized by the jitter. It may exceed MaxBackoff by the jitter.
+func (p RetryPolicy) Wait(retry int) time.Duration {
+	d := p.Backoff(retry)
+	if p.Jitter <= 0 {
+		return d
+	}
+	return time.Duration(float64(d) * (1 + p.Jitter*(2*rand.Float64()-1)))
+}
+
+// RetryStats reports the retries of OpenCost requests.
+type RetryStats struct {
+	// Retries is the number of retried requests.
+	Retries int64
+	// ByReason counts the retries by the failure they followed: the HTTP
+	// status code, e.g. "503", or "error" for failures without a response
+	// such as refused connections, and for undecodable responses.
+	ByReason map[string]int64
+	// Exhausted is the number of fetches that failed after all retries.
+	Exhausted int64
+}
+
+// StatusError is returned when OpenCost answers with an unexpected HTTP
+// status other than 429 Too Many Requests, which is a ThrottledError.
+type StatusError struct {
+	StatusCode int
+	Body       string
+	// RetryAfter is the wait the Retry-After header asked for, or 0.
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, e.Body)
+}
+
+// newStatusError returns the error of a response with an unexpected status.
+func newStatusError(resp *http.Response, body []byte) *StatusError {
+	return &StatusError{
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+// permanent reports whether err is a client error that asking again does
+// not change, i.e. a 4xx status other than 429.
+func permanent(err error) bool {
+	var status *StatusError
+	return errors.As(err, &status) && status.StatusCode >= 400 && status.StatusCode < 500
+}
+
+// retryReason returns the reason a retry of err is counted under.
+func retryReason(err error) string {
+	var status *StatusError
+	if errors.As(err, &status) {
+		return strconv.Itoa(status.StatusCode)
+	}
+	var throttled *ThrottledError
+	if errors.As(err, &throttled) {
+		return strconv.Itoa(http.StatusTooManyRequests)
+	}
+	return "error"
+}
+
+// minWait returns the wait err asks for before the next request: until the
+// end of a throttle, or the Retry-After of a status error.
+func minWait(err error) time.Duration {
+	var throttled *ThrottledError
+	if errors.As(err, &throttled) {
+		return time.Until(throttled.Until)
+	}
+	var status *StatusError
+	if errors.As(err, &status) {
+		return status.RetryAfter
+	}
+	return 0
+}
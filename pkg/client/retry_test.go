@@ -0,0 +1,167 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/opencosttest"
+)
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{MaxRetries: 6, InitialBackoff: 500 * time.Millisecond, MaxBackoff: 5 * time.Second, Multiplier: 3}
+	want := []time.Duration{500 * time.Millisecond, 1500 * time.Millisecond, 4500 * time.Millisecond, 5 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		if got := p.Backoff(i + 1); got != w {
+			t.Errorf("Backoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+
+	// The default keeps the former fixed schedule.
+	for i, w := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		if got := DefaultRetryPolicy.Backoff(i + 1); got != w {
+			t.Errorf("DefaultRetryPolicy.Backoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+}
+
+func TestRetryPolicy_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*RetryPolicy)
+	}{
+		{"negative retries", func(p *RetryPolicy) { p.MaxRetries = -1 }},
+		{"zero initial backoff", func(p *RetryPolicy) { p.InitialBackoff = 0 }},
+		{"max below initial", func(p *RetryPolicy) { p.MaxBackoff = p.InitialBackoff / 2 }},
+		{"shrinking backoff", func(p *RetryPolicy) { p.Multiplier = 0.5 }},
+		{"jitter above 1", func(p *RetryPolicy) { p.Jitter = 1.5 }},
+	}
+	if err := DefaultRetryPolicy.Validate(); err != nil {
+		t.Errorf("DefaultRetryPolicy.Validate() error = %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := DefaultRetryPolicy
+			tt.modify(&p)
+			if err := p.Validate(); err == nil {
+				t.Error("Validate() should fail")
+			}
+		})
+	}
+}
+
+func TestClient_RetryStats(t *testing.T) {
+	server := opencosttest.NewServer(opencosttest.WithFailures(3, http.StatusInternalServerError))
+	defer server.Close()
+
+	client := New(server.URL, WithRetryPolicy(RetryPolicy{
+		MaxRetries:     1,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+		Multiplier:     1,
+	}))
+
+	// Two failures exhaust the retries; the third is retried successfully.
+	if _, err := client.FetchCloudCosts(context.Background()); err == nil {
+		t.Fatal("FetchCloudCosts() should fail")
+	}
+	if _, err := client.FetchCloudCosts(context.Background()); err != nil {
+		t.Fatalf("FetchCloudCosts() error = %v", err)
+	}
+	want := RetryStats{Retries: 2, ByReason: map[string]int64{"500": 2}, Exhausted: 1}
+	if got := client.RetryStats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("RetryStats() = %+v, want %+v", got, want)
+	}
+	if n := server.CloudCostRequests(); n != 4 {
+		t.Errorf("requests = %d, want 4", n)
+	}
+}
+
+func TestRetryPolicy_Wait(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second, Multiplier: 2, Jitter: 0.25}
+	seen := make(map[time.Duration]bool)
+	for range 100 {
+		d := p.Wait(2)
+		if d < 1500*time.Millisecond || d > 2500*time.Millisecond {
+			t.Fatalf("Wait(2) = %v, want 2s ±25%%", d)
+		}
+		seen[d] = true
+	}
+	if len(seen) < 2 {
+		t.Error("Wait() is not randomized")
+	}
+
+	p.Jitter = 0
+	if d := p.Wait(2); d != 2*time.Second {
+		t.Errorf("Wait(2) without jitter = %v, want 2s", d)
+	}
+}
+
+func TestClient_RetryStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		retryAfter string
+		maxRetries int
+		requests   int
+		minElapsed time.Duration
+	}{
+		{"client error not retried", http.StatusNotFound, "", 2, 1, 0},
+		{"unauthorized not retried", http.StatusUnauthorized, "", 2, 1, 0},
+		{"server error retried", http.StatusInternalServerError, "", 2, 3, 0},
+		{"Retry-After honored", http.StatusServiceUnavailable, "1", 1, 2, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			client := New(server.URL, WithRetryPolicy(RetryPolicy{
+				MaxRetries:     tt.maxRetries,
+				InitialBackoff: time.Millisecond,
+				MaxBackoff:     time.Millisecond,
+				Multiplier:     1,
+			}))
+			start := time.Now()
+			_, err := client.FetchCloudCosts(context.Background())
+			var status *StatusError
+			if !errors.As(err, &status) || status.StatusCode != tt.status {
+				t.Fatalf("FetchCloudCosts() error = %v, want a StatusError of %d", err, tt.status)
+			}
+			if n := int(requests.Load()); n != tt.requests {
+				t.Errorf("requests = %d, want %d", n, tt.requests)
+			}
+			if elapsed := time.Since(start); elapsed < tt.minElapsed {
+				t.Errorf("elapsed = %v, want at least %v", elapsed, tt.minElapsed)
+			}
+		})
+	}
+
+	// A Retry-After beyond the deadline fails without waiting.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	if _, err := New(server.URL).FetchCloudCosts(ctx); err == nil {
+		t.Fatal("FetchCloudCosts() should fail")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("elapsed = %v, want no wait beyond the deadline", elapsed)
+	}
+}
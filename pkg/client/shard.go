@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/parallel"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+// shardWorkers is the number of window shards fetched at once, few enough
+// not to overload OpenCost with the queries sharding is meant to lighten.
+const shardWorkers = 4
+
+// WithWindowShard splits windows longer than size into consecutive shards
+// of size, the last one possibly shorter, which are fetched separately, up
+// to four at a time, and merged, for OpenCost instances that time out on
+// long windows. Zero, the default, fetches every window in one request.
+//
+// Relative windows such as "30d" are taken as the days up to now, from
+// midnight in the location of WithLocation; windows whose bounds are
+// unknown, such as Unix timestamp ranges, are not split.
+func WithWindowShard(size time.Duration) Option {
+	return func(c *Client) {
+		c.windowShard = size
+	}
+}
+
+// shardWindow splits window, resolved at now, into shards of size, or
+// returns nil if it is not longer than size or its bounds are unknown.
+func shardWindow(window string, size time.Duration, now time.Time) []types.Window {
+	bounds, err := WindowBounds(window, now)
+	if err != nil {
+		return nil
+	}
+	if bounds.Start.IsZero() {
+		length, err := WindowDuration(window)
+		if err != nil {
+			return nil
+		}
+		bounds = types.Window{Start: now.Add(-length), End: now}
+		if days := length / (24 * time.Hour); length%(24*time.Hour) == 0 {
+			y, m, d := now.Date()
+			bounds.Start = time.Date(y, m, d, 0, 0, 0, 0, now.Location()).AddDate(0, 0, 1-int(days))
+		}
+	}
+	if bounds.Duration() <= size {
+		return nil
+	}
+	bounds.Start, bounds.End = bounds.Start.In(now.Location()), bounds.End.In(now.Location())
+
+	// Shards of whole days end at midnight in the location of now, even
+	// across DST changes.
+	next := func(t time.Time) time.Time { return t.Add(size) }
+	if size%(24*time.Hour) == 0 {
+		next = func(t time.Time) time.Time { return t.AddDate(0, 0, int(size/(24*time.Hour))) }
+	}
+	var shards []types.Window
+	for start := bounds.Start; start.Before(bounds.End); start = next(start) {
+		end := next(start)
+		if end.After(bounds.End) {
+			end = bounds.End
+		}
+		shards = append(shards, types.Window{Start: start, End: end})
+	}
+	return shards
+}
+
+// fetchShards fetches the shards concurrently and merges their sets in
+// shard order. The first shard to fail cancels the others and fails the
+// fetch.
+func (c *Client) fetchShards(ctx context.Context, shards []types.Window) (*types.CloudCostResponse, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		mu       sync.Mutex
+		firstErr error
+	)
+	responses := make([]*types.CloudCostResponse, len(shards))
+	parallel.For(len(shards), shardWorkers, func(i int) {
+		if ctx.Err() != nil {
+			return
+		}
+		window := shards[i].Start.UTC().Format(time.RFC3339) + "," + shards[i].End.UTC().Format(time.RFC3339)
+		resp, err := c.fetchWindow(ctx, window)
+		if err != nil {
+			mu.Lock()
+			if firstErr == nil {
+				firstErr = fmt.Errorf("window shard %s: %w", window, err)
+				cancel()
+			}
+			mu.Unlock()
+			return
+		}
+		responses[i] = resp
+	})
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	result := &types.CloudCostResponse{Code: responses[0].Code, Data: types.CloudCostData{Sets: []types.CloudCostSet{}}}
+	for _, resp := range responses {
+		result.Data.Sets = append(result.Data.Sets, resp.Data.Sets...)
+	}
+	return result, nil
+}
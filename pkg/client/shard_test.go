@@ -0,0 +1,103 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/opencosttest"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+func TestShardWindow(t *testing.T) {
+	now := time.Date(2026, 3, 15, 10, 30, 0, 0, time.UTC)
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 0, 0, 0, 0, time.UTC) }
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		window string
+		size   time.Duration
+		now    time.Time
+		want   []types.Window
+	}{
+		{"relative days", "10d", 7 * 24 * time.Hour, now, []types.Window{
+			{Start: day(6), End: day(13)},
+			{Start: day(13), End: now},
+		}},
+		{"explicit range", "2026-03-01T00:00:00Z,2026-03-04T00:00:00Z", 24 * time.Hour, now, []types.Window{
+			{Start: day(1), End: day(2)},
+			{Start: day(2), End: day(3)},
+			{Start: day(3), End: day(4)},
+		}},
+		{"relative hours", "36h", 24 * time.Hour, now, []types.Window{
+			{Start: now.Add(-36 * time.Hour), End: now.Add(-12 * time.Hour)},
+			{Start: now.Add(-12 * time.Hour), End: now},
+		}},
+		{"across DST", "2026-03-28T00:00:00+01:00,2026-03-31T00:00:00+02:00", 24 * time.Hour, now.In(berlin), []types.Window{
+			{Start: time.Date(2026, 3, 28, 0, 0, 0, 0, berlin), End: time.Date(2026, 3, 29, 0, 0, 0, 0, berlin)},
+			{Start: time.Date(2026, 3, 29, 0, 0, 0, 0, berlin), End: time.Date(2026, 3, 30, 0, 0, 0, 0, berlin)},
+			{Start: time.Date(2026, 3, 30, 0, 0, 0, 0, berlin), End: time.Date(2026, 3, 31, 0, 0, 0, 0, berlin)},
+		}},
+		{"not longer than the shard", "7d", 7 * 24 * time.Hour, now, nil},
+		{"unknown bounds", "1741000000,1742000000", 24 * time.Hour, now, nil},
+		{"calendar keyword of the shard size", "lastweek", 7 * 24 * time.Hour, now, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			window, err := ResolveWindow(tt.window, tt.now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := shardWindow(window, tt.size, tt.now)
+			if len(got) != len(tt.want) {
+				t.Fatalf("shardWindow(%s) = %v, want %v", tt.window, got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Start.Equal(tt.want[i].Start) || !got[i].End.Equal(tt.want[i].End) {
+					t.Errorf("shard %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestClient_WithWindowShard(t *testing.T) {
+	server := opencosttest.NewServer(opencosttest.WithResponse(opencosttest.Response(
+		opencosttest.Item("111", "AmazonEC2", "Compute", 1),
+	)))
+	defer server.Close()
+
+	client := New(server.URL, WithWindowShard(24*time.Hour))
+	resp, err := client.FetchCloudCostsWindow(context.Background(), "2026-03-01T00:00:00Z,2026-03-04T00:00:00Z")
+	if err != nil {
+		t.Fatalf("FetchCloudCostsWindow() error = %v", err)
+	}
+	if len(resp.Data.Sets) != 3 {
+		t.Errorf("sets = %d, want one per shard", len(resp.Data.Sets))
+	}
+	var windows []string
+	for _, req := range server.Requests() {
+		windows = append(windows, req.Query.Get("window"))
+	}
+	slices.Sort(windows)
+	want := []string{
+		"2026-03-01T00:00:00Z,2026-03-02T00:00:00Z",
+		"2026-03-02T00:00:00Z,2026-03-03T00:00:00Z",
+		"2026-03-03T00:00:00Z,2026-03-04T00:00:00Z",
+	}
+	if !reflect.DeepEqual(windows, want) {
+		t.Errorf("requested windows = %v, want %v", windows, want)
+	}
+
+	server.FailNext(10, http.StatusBadRequest)
+	if _, err := client.FetchCloudCostsWindow(context.Background(), "2026-03-01T00:00:00Z,2026-03-04T00:00:00Z"); err == nil {
+		t.Error("FetchCloudCostsWindow() should fail if a shard fails")
+	}
+}
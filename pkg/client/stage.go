@@ -0,0 +1,37 @@
+package client
+
+import (
+	"context"
+	"time"
+)
+
+// Stages of a cloud cost fetch reported to a StageObserver.
+const (
+	// StageFetch is an HTTP request, until its response body is read, or
+	// for cloudCost responses, which are decoded as read, until its
+	// headers arrive.
+	StageFetch = "fetch"
+	// StageDecode is the decoding and validation of a response body,
+	// including reading it if it is decoded as read.
+	StageDecode = "decode"
+)
+
+// StageObserver is told how long a stage of a request took.
+type StageObserver func(stage string, d time.Duration)
+
+type stageObserverKey struct{}
+
+// ContextWithStageObserver returns a copy of ctx whose fetches report the
+// duration of their stages to observe, once per request, so that fetches
+// through federation or window fallbacks are timed as well.
+func ContextWithStageObserver(ctx context.Context, observe StageObserver) context.Context {
+	return context.WithValue(ctx, stageObserverKey{}, observe)
+}
+
+// observeStage reports the time since start as stage to the observer of
+// ctx, if any.
+func observeStage(ctx context.Context, stage string, start time.Time) {
+	if observe, ok := ctx.Value(stageObserverKey{}).(StageObserver); ok {
+		observe(stage, time.Since(start))
+	}
+}
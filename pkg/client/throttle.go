@@ -0,0 +1,115 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Targets of the client's requests, as reported by Throttling.
+const (
+	TargetOpenCost      = "opencost"
+	TargetExchangeRates = "frankfurter" // any exchange rate provider
+)
+
+// Targets lists all request targets.
+var Targets = []string{TargetOpenCost, TargetExchangeRates}
+
+const (
+	// throttleBackoff is the wait after a first 429 response without a
+	// longer Retry-After; it doubles with every further one in a row.
+	throttleBackoff = 5 * time.Second
+	// maxThrottleBackoff caps the wait after a 429 response.
+	maxThrottleBackoff = 5 * time.Minute
+)
+
+// ThrottledError is returned when a target answers 429 Too Many Requests,
+// or when a request is skipped because the target throttled an earlier one
+// and its backoff has not passed yet.
+type ThrottledError struct {
+	Target string
+	// Until is when requests to the target are sent again.
+	Until time.Time
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("%s is throttling requests until %s", e.Target, e.Until.Format(time.RFC3339))
+}
+
+// ThrottleStats reports the 429 responses of a target.
+type ThrottleStats struct {
+	// Count is the number of 429 responses received.
+	Count int64
+	// Last is when the last one was received, or zero if none was.
+	Last time.Time
+}
+
+// throttle tracks the 429 responses of one target. Requests are held back
+// for longer than the normal retry schedule after a 429, honoring the
+// target's Retry-After, and for longer still if it keeps throttling.
+type throttle struct {
+	target string
+
+	mu     sync.Mutex
+	stats  ThrottleStats
+	streak int
+	until  time.Time
+}
+
+// record registers a 429 response with the given Retry-After header and
+// returns the error to report.
+func (t *throttle) record(retryAfter string) *ThrottledError {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	wait := min(throttleBackoff<<min(t.streak, 10), maxThrottleBackoff)
+	if d := parseRetryAfter(retryAfter, now); d > wait {
+		wait = min(d, maxThrottleBackoff)
+	}
+	t.stats.Count++
+	t.stats.Last = now
+	t.streak++
+	t.until = now.Add(wait)
+	return &ThrottledError{Target: t.target, Until: t.until}
+}
+
+// reset ends the streak of 429 responses after a successful request.
+func (t *throttle) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.streak = 0
+}
+
+// check returns an error if requests are held back after a 429 response.
+func (t *throttle) check() *ThrottledError {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if time.Now().Before(t.until) {
+		return &ThrottledError{Target: t.target, Until: t.until}
+	}
+	return nil
+}
+
+func (t *throttle) snapshot() ThrottleStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.stats
+}
+
+// parseRetryAfter parses a Retry-After header of either delay seconds or an
+// HTTP date. It returns 0 if the header is absent or invalid.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if s, err := strconv.Atoi(v); err == nil && s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	if at, err := http.ParseTime(v); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
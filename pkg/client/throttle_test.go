@@ -0,0 +1,112 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/opencosttest"
+)
+
+func TestClient_FetchCloudCosts_Throttled(t *testing.T) {
+	server := opencosttest.NewServer(opencosttest.WithFailures(1, http.StatusTooManyRequests))
+	defer server.Close()
+
+	client := New(server.URL)
+	// The throttle backoff exceeds the deadline, so the retry is given up.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err := client.FetchCloudCosts(ctx)
+	var throttled *ThrottledError
+	if !errors.As(err, &throttled) || throttled.Target != TargetOpenCost {
+		t.Fatalf("FetchCloudCosts() error = %v, want a ThrottledError", err)
+	}
+	if wait := time.Until(throttled.Until); wait < 4*time.Second {
+		t.Errorf("backoff = %v, want beyond the normal schedule", wait)
+	}
+
+	// Until the backoff has passed, fetches fail without a request.
+	if _, err := client.FetchCloudCosts(context.Background()); !errors.As(err, &throttled) {
+		t.Errorf("FetchCloudCosts() error = %v, want a ThrottledError", err)
+	}
+	if n := server.CloudCostRequests(); n != 1 {
+		t.Errorf("requests = %d, want 1", n)
+	}
+
+	stats := client.Throttling(TargetOpenCost)
+	if stats.Count != 1 || stats.Last.IsZero() {
+		t.Errorf("Throttling() = %+v, want one 429", stats)
+	}
+	if stats := client.Throttling(TargetExchangeRates); stats.Count != 0 {
+		t.Errorf("Throttling(%q) = %+v, want none", TargetExchangeRates, stats)
+	}
+}
+
+func TestThrottle_Backoff(t *testing.T) {
+	th := &throttle{target: TargetOpenCost}
+	wait := func(retryAfter string) time.Duration {
+		return time.Until(th.record(retryAfter).Until).Round(time.Second)
+	}
+
+	if got := wait(""); got != throttleBackoff {
+		t.Errorf("first backoff = %v, want %v", got, throttleBackoff)
+	}
+	if got := wait(""); got != 2*throttleBackoff {
+		t.Errorf("second backoff = %v, want %v", got, 2*throttleBackoff)
+	}
+	if got := wait("60"); got != time.Minute {
+		t.Errorf("backoff with Retry-After = %v, want 1m", got)
+	}
+	if got := wait("86400"); got != maxThrottleBackoff {
+		t.Errorf("backoff with a long Retry-After = %v, want %v", got, maxThrottleBackoff)
+	}
+
+	th.reset()
+	if got := wait(""); got != throttleBackoff {
+		t.Errorf("backoff after a success = %v, want %v", got, throttleBackoff)
+	}
+	if n := th.snapshot().Count; n != 5 {
+		t.Errorf("count = %d, want 5", n)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"120", 2 * time.Minute},
+		{"-1", 0},
+		{now.Add(30 * time.Second).Format(http.TimeFormat), 30 * time.Second},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{"soon", 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestClient_RetryAfterHeader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, WithMaxRetries(0))
+	_, err := client.FetchCloudCosts(context.Background())
+	var throttled *ThrottledError
+	if !errors.As(err, &throttled) {
+		t.Fatalf("FetchCloudCosts() error = %v, want a ThrottledError", err)
+	}
+	if wait := time.Until(throttled.Until); wait < 110*time.Second {
+		t.Errorf("backoff = %v, want Retry-After to be honored", wait)
+	}
+}
@@ -0,0 +1,64 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// TLSOptions configures TLS for OpenCost requests. The zero value uses the
+// system roots and no client certificate.
+type TLSOptions struct {
+	// CAFile is a PEM bundle of the CAs trusted instead of the system roots.
+	CAFile string
+	// CertFile and KeyFile are the PEM client certificate and key. They are
+	// read again on every handshake, so that renewed certificates are used.
+	CertFile string
+	KeyFile  string
+	// ServerName overrides the name the server certificate is verified for.
+	ServerName string
+	// InsecureSkipVerify skips verifying the server certificate.
+	InsecureSkipVerify bool
+}
+
+// IsZero reports whether o leaves the TLS configuration at its defaults.
+func (o TLSOptions) IsZero() bool {
+	return o == TLSOptions{}
+}
+
+// Config returns the TLS configuration for o, failing if the files cannot
+// be read.
+func (o TLSOptions) Config() (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName:         o.ServerName,
+		InsecureSkipVerify: o.InsecureSkipVerify,
+	}
+	if o.CAFile != "" {
+		pem, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read CA file: %w", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in CA file %s", o.CAFile)
+		}
+	}
+	if (o.CertFile == "") != (o.KeyFile == "") {
+		return nil, errors.New("client certificate needs both a certificate and a key file")
+	}
+	if o.CertFile != "" {
+		if _, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile); err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("load client certificate: %w", err)
+			}
+			return &cert, nil
+		}
+	}
+	return cfg, nil
+}
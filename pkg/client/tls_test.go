@@ -0,0 +1,134 @@
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCert writes a self-signed client certificate and its key as PEM
+// files to dir and returns the certificate and the file paths.
+func writeCert(t *testing.T, dir string) (*x509.Certificate, string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "exporter"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+	return cert, certFile, keyFile
+}
+
+func writePEM(t *testing.T, path, typ string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClient_WithTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	clientCert, certFile, keyFile := writeCert(t, dir)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"code": 200, "data": {"sets": []}}`))
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+	caFile := filepath.Join(dir, "ca.crt")
+	writePEM(t, caFile, "CERTIFICATE", server.Certificate().Raw)
+
+	tests := []struct {
+		name    string
+		opts    TLSOptions
+		wantErr bool
+	}{
+		{"system roots", TLSOptions{}, true},
+		{"no client certificate", TLSOptions{CAFile: caFile}, true},
+		{"mutual TLS", TLSOptions{CAFile: caFile, CertFile: certFile, KeyFile: keyFile}, false},
+		{"insecure", TLSOptions{InsecureSkipVerify: true, CertFile: certFile, KeyFile: keyFile}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := tt.opts.Config()
+			if err != nil {
+				t.Fatalf("Config() error = %v", err)
+			}
+			client := New(server.URL, WithMaxRetries(0), WithTLSConfig(cfg))
+			if _, err := client.FetchCloudCosts(context.Background()); (err != nil) != tt.wantErr {
+				t.Errorf("FetchCloudCosts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTLSOptions_Config(t *testing.T) {
+	dir := t.TempDir()
+	_, certFile, keyFile := writeCert(t, dir)
+	notPEM := filepath.Join(dir, "not.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		opts    TLSOptions
+		wantErr bool
+	}{
+		{"zero", TLSOptions{}, false},
+		{"CA", TLSOptions{CAFile: certFile}, false},
+		{"missing CA", TLSOptions{CAFile: filepath.Join(dir, "missing.crt")}, true},
+		{"CA without certificates", TLSOptions{CAFile: notPEM}, true},
+		{"certificate without key", TLSOptions{CertFile: certFile}, true},
+		{"invalid key", TLSOptions{CertFile: certFile, KeyFile: notPEM}, true},
+		{"client certificate", TLSOptions{CertFile: certFile, KeyFile: keyFile}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.opts.Config(); (err != nil) != tt.wantErr {
+				t.Errorf("Config() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	if !(TLSOptions{}).IsZero() || (TLSOptions{ServerName: "opencost"}).IsZero() {
+		t.Error("IsZero() is wrong")
+	}
+}
@@ -0,0 +1,119 @@
+package client
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+// ResolveWindow translates window expressions into explicit RFC3339 ranges
+// where the exporter, not OpenCost, should decide their bounds. See
+// WindowBounds. Other windows, such as "7d" or explicit ranges, are
+// returned unchanged.
+func ResolveWindow(window string, now time.Time) (string, error) {
+	if strings.Contains(window, ",") {
+		return window, nil
+	}
+	bounds, err := WindowBounds(window, now)
+	if err != nil {
+		return "", err
+	}
+	if bounds.Start.IsZero() {
+		return window, nil
+	}
+	return bounds.Start.UTC().Format(time.RFC3339) + "," + bounds.End.UTC().Format(time.RFC3339), nil
+}
+
+// WindowBounds returns the bounds of window at now:
+//
+//   - "today", "yesterday", "week", "lastweek", "month" and "lastmonth" are
+//     aligned to days, weeks starting on Sunday, and months in the location
+//     of now, so that e.g. "month" starts at local midnight on the 1st
+//     rather than at whatever OpenCost takes to be midnight;
+//   - "<window> offset <duration>" is the window ending duration before
+//     now, e.g. "7d offset 7d" the 7 days ending 7 days ago;
+//   - explicit "start,end" RFC3339 ranges are parsed.
+//
+// Windows that OpenCost resolves itself, such as "7d", have zero bounds.
+func WindowBounds(window string, now time.Time) (types.Window, error) {
+	if start, end, ok := strings.Cut(window, ","); ok {
+		s, errStart := time.Parse(time.RFC3339, strings.TrimSpace(start))
+		e, errEnd := time.Parse(time.RFC3339, strings.TrimSpace(end))
+		if errStart != nil || errEnd != nil {
+			// Unix timestamps and other forms are left to OpenCost.
+			return types.Window{}, nil
+		}
+		return types.Window{Start: s, End: e}, nil
+	}
+	if w, ok := calendarWindow(window, now); ok {
+		return w, nil
+	}
+
+	base, offset, ok := strings.Cut(window, " offset ")
+	if !ok {
+		return types.Window{}, nil
+	}
+	length, err := parseWindowDuration(strings.TrimSpace(base))
+	if err != nil {
+		return types.Window{}, fmt.Errorf("invalid window %q: %w", window, err)
+	}
+	shift, err := parseWindowDuration(strings.TrimSpace(offset))
+	if err != nil {
+		return types.Window{}, fmt.Errorf("invalid window offset %q: %w", window, err)
+	}
+	end := now.Add(-shift)
+	return types.Window{Start: end.Add(-length), End: end}, nil
+}
+
+// calendarWindow resolves the calendar keywords OpenCost understands, with
+// OpenCost's semantics, in the location of now.
+func calendarWindow(window string, now time.Time) (types.Window, bool) {
+	y, m, d := now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	week := today.AddDate(0, 0, -int(today.Weekday()))
+	month := time.Date(y, m, 1, 0, 0, 0, 0, now.Location())
+	switch window {
+	case "today":
+		return types.Window{Start: today, End: now}, true
+	case "yesterday":
+		return types.Window{Start: today.AddDate(0, 0, -1), End: today}, true
+	case "week":
+		return types.Window{Start: week, End: now}, true
+	case "lastweek":
+		return types.Window{Start: week.AddDate(0, 0, -7), End: week}, true
+	case "month":
+		return types.Window{Start: month, End: now}, true
+	case "lastmonth":
+		return types.Window{Start: month.AddDate(0, -1, 0), End: month}, true
+	default:
+		return types.Window{}, false
+	}
+}
+
+// WindowDuration returns the length of a relative window such as "3d". It
+// fails for calendar keywords, offsets and explicit ranges.
+func WindowDuration(window string) (time.Duration, error) {
+	return parseWindowDuration(window)
+}
+
+// parseWindowDuration parses durations with an optional day ("d") or week
+// ("w") unit in addition to the units accepted by time.ParseDuration.
+func parseWindowDuration(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			v, err := strconv.Atoi(n)
+			if err != nil || v <= 0 {
+				return 0, fmt.Errorf("bad duration %q", s)
+			}
+			return time.Duration(v) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("bad duration %q", s)
+	}
+	return d, nil
+}
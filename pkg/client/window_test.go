@@ -0,0 +1,82 @@
+package client
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResolveWindow(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		window  string
+		want    string
+		wantErr bool
+	}{
+		{"7d", "7d", false},
+		{"2024-01-01T00:00:00Z,2024-01-02T00:00:00Z", "2024-01-01T00:00:00Z,2024-01-02T00:00:00Z", false},
+		{"7d offset 7d", "2024-03-01T12:00:00Z,2024-03-08T12:00:00Z", false},
+		{"1w offset 1w", "2024-03-01T12:00:00Z,2024-03-08T12:00:00Z", false},
+		{"24h offset 1d", "2024-03-13T12:00:00Z,2024-03-14T12:00:00Z", false},
+		{"month", "2024-03-01T00:00:00Z,2024-03-15T12:00:00Z", false},
+		{"lastmonth", "2024-02-01T00:00:00Z,2024-03-01T00:00:00Z", false},
+		{"xd offset 7d", "", true},
+		{"7d offset -1d", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.window, func(t *testing.T) {
+			got, err := ResolveWindow(tt.window, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveWindow() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ResolveWindow() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWindowBounds(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("time zone database not available:", err)
+	}
+	// A Friday, in the week after the switch to daylight saving time.
+	now := time.Date(2024, 4, 5, 1, 30, 0, 0, berlin)
+	at := func(month time.Month, day int) time.Time {
+		return time.Date(2024, month, day, 0, 0, 0, 0, berlin)
+	}
+
+	tests := []struct {
+		window    string
+		wantStart time.Time
+		wantEnd   time.Time
+	}{
+		{"today", at(4, 5), now},
+		{"yesterday", at(4, 4), at(4, 5)},
+		{"week", at(3, 31), now},
+		{"lastweek", at(3, 24), at(3, 31)},
+		{"month", at(4, 1), now},
+		{"lastmonth", at(3, 1), at(4, 1)},
+		{"1d offset 1d", now.Add(-48 * time.Hour), now.Add(-24 * time.Hour)},
+		{"2024-01-01T00:00:00Z,2024-01-02T00:00:00Z", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"7d", time.Time{}, time.Time{}},
+		{"1704067200,1704153600", time.Time{}, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.window, func(t *testing.T) {
+			got, err := WindowBounds(tt.window, now)
+			if err != nil {
+				t.Fatalf("WindowBounds() error = %v", err)
+			}
+			if !got.Start.Equal(tt.wantStart) || !got.End.Equal(tt.wantEnd) {
+				t.Errorf("WindowBounds() = %v - %v, want %v - %v", got.Start, got.End, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+
+	// Calendar windows start at local midnight, which OpenCost gets as UTC.
+	if got, _ := ResolveWindow("month", now); got != "2024-03-31T22:00:00Z,2024-04-04T23:30:00Z" {
+		t.Errorf("ResolveWindow(month) = %q", got)
+	}
+}
@@ -0,0 +1,108 @@
+package collector
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/client"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+// AllocationFetcher fetches Kubernetes allocation data.
+type AllocationFetcher func(ctx context.Context) (*types.AllocationResponse, error)
+
+// AllocationCollector collects the cost of Kubernetes workloads from
+// OpenCost's allocation API, by namespace, controller and pod. Data is
+// fetched when scraped and reused for the TTL; after a failed fetch the
+// previous data is served until it is older than the max stale age.
+type AllocationCollector struct {
+	cache *scrapeCache[*types.AllocationResponse]
+
+	cost   *prometheus.Desc
+	upDesc *prometheus.Desc
+}
+
+// AllocationOption configures an AllocationCollector.
+type AllocationOption func(*AllocationCollector)
+
+// WithAllocationTTL sets how long fetched allocations are served before
+// they are fetched again, and how long past that after failed fetches.
+func WithAllocationTTL(ttl, maxStale time.Duration) AllocationOption {
+	return func(c *AllocationCollector) {
+		c.cache.ttl = ttl
+		c.cache.maxStale = maxStale
+	}
+}
+
+// WithAllocationFetcher replaces the client's FetchAllocations as the
+// source of allocation data.
+func WithAllocationFetcher(fetch AllocationFetcher) AllocationOption {
+	return func(c *AllocationCollector) {
+		c.cache.fetch = fetch
+	}
+}
+
+// NewAllocationCollector creates a collector of the allocations c fetches
+// for its window.
+func NewAllocationCollector(c *client.Client, opts ...AllocationOption) *AllocationCollector {
+	collector := &AllocationCollector{
+		cache: newScrapeCache("allocations", c.FetchAllocations),
+		cost: prometheus.NewDesc(
+			"kube_allocation_cost_total",
+			"Cost in USD of Kubernetes workloads over the configured window, by resource",
+			[]string{"namespace", "controller", "pod", "cost_type"},
+			nil,
+		),
+		upDesc: prometheus.NewDesc(
+			selfNamespace+"_allocation_up",
+			"Whether the last fetch from the OpenCost allocation API succeeded",
+			nil,
+			nil,
+		),
+	}
+	for _, opt := range opts {
+		opt(collector)
+	}
+	return collector
+}
+
+// Describe implements prometheus.Collector.
+func (c *AllocationCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.cost
+	ch <- c.upDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *AllocationCollector) Collect(ch chan<- prometheus.Metric) {
+	data, ok, up := c.cache.get()
+	sendUp(ch, c.upDesc, up)
+	if !ok {
+		return
+	}
+
+	type allocationKey struct{ namespace, controller, pod string }
+	costs := make(map[allocationKey][]float64)
+	for _, set := range data.Data {
+		for _, a := range set {
+			key := allocationKey{
+				namespace:  labelValue(a.Properties.Namespace),
+				controller: labelValue(a.Properties.Controller),
+				pod:        labelValue(a.Properties.Pod),
+			}
+			if costs[key] == nil {
+				costs[key] = make([]float64, len(types.AllocationCostTypes))
+			}
+			for i, costType := range types.AllocationCostTypes {
+				v, _ := a.CostByType(costType)
+				costs[key][i] += v
+			}
+		}
+	}
+	for key, values := range costs {
+		for i, costType := range types.AllocationCostTypes {
+			sendGauge(ch, c.cost, values[i], key.namespace, key.controller, key.pod, costType)
+		}
+	}
+}
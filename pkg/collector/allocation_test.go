@@ -0,0 +1,83 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/client"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+func TestAllocationCollector(t *testing.T) {
+	pod := func(namespace, controller, name string, cpu, ram float64) types.Allocation {
+		return types.Allocation{
+			Properties: types.AllocationProperties{Namespace: namespace, Controller: controller, Pod: name},
+			CPUCost:    cpu,
+			RAMCost:    ram,
+		}
+	}
+	var fetches int
+	var fetchErr error
+	fetch := func(context.Context) (*types.AllocationResponse, error) {
+		fetches++
+		if fetchErr != nil {
+			return nil, fetchErr
+		}
+		return &types.AllocationResponse{Code: 200, Data: []map[string]types.Allocation{{
+			"web/api/api-1": pod("web", "api", "api-1", 2, 1),
+			"web/api/api-2": pod("web", "api", "api-2", 3, 1),
+		}}}, nil
+	}
+	c := NewAllocationCollector(client.New("http://unused"), WithAllocationFetcher(fetch), WithAllocationTTL(time.Hour, time.Hour))
+
+	const want = `
+# HELP cloudcost_exporter_allocation_up Whether the last fetch from the OpenCost allocation API succeeded
+# TYPE cloudcost_exporter_allocation_up gauge
+cloudcost_exporter_allocation_up %s
+# HELP kube_allocation_cost_total Cost in USD of Kubernetes workloads over the configured window, by resource
+# TYPE kube_allocation_cost_total gauge
+kube_allocation_cost_total{controller="api",cost_type="cpu",namespace="web",pod="api-1"} 2
+kube_allocation_cost_total{controller="api",cost_type="cpu",namespace="web",pod="api-2"} 3
+kube_allocation_cost_total{controller="api",cost_type="external",namespace="web",pod="api-1"} 0
+kube_allocation_cost_total{controller="api",cost_type="external",namespace="web",pod="api-2"} 0
+kube_allocation_cost_total{controller="api",cost_type="gpu",namespace="web",pod="api-1"} 0
+kube_allocation_cost_total{controller="api",cost_type="gpu",namespace="web",pod="api-2"} 0
+kube_allocation_cost_total{controller="api",cost_type="load_balancer",namespace="web",pod="api-1"} 0
+kube_allocation_cost_total{controller="api",cost_type="load_balancer",namespace="web",pod="api-2"} 0
+kube_allocation_cost_total{controller="api",cost_type="network",namespace="web",pod="api-1"} 0
+kube_allocation_cost_total{controller="api",cost_type="network",namespace="web",pod="api-2"} 0
+kube_allocation_cost_total{controller="api",cost_type="pv",namespace="web",pod="api-1"} 0
+kube_allocation_cost_total{controller="api",cost_type="pv",namespace="web",pod="api-2"} 0
+kube_allocation_cost_total{controller="api",cost_type="ram",namespace="web",pod="api-1"} 1
+kube_allocation_cost_total{controller="api",cost_type="ram",namespace="web",pod="api-2"} 1
+kube_allocation_cost_total{controller="api",cost_type="shared",namespace="web",pod="api-1"} 0
+kube_allocation_cost_total{controller="api",cost_type="shared",namespace="web",pod="api-2"} 0
+`
+	compare := func(up string) {
+		t.Helper()
+		if err := testutil.CollectAndCompare(c, strings.NewReader(strings.ReplaceAll(want, "%s", up))); err != nil {
+			t.Error(err)
+		}
+	}
+	compare("1")
+	compare("1")
+	if fetches != 1 {
+		t.Errorf("fetched %d times within the TTL, want 1", fetches)
+	}
+
+	// A failed fetch serves the previous data.
+	c.cache.fetchedAt = time.Now().Add(-90 * time.Minute)
+	fetchErr = errors.New("boom")
+	compare("0")
+
+	// Data older than the TTL and max stale age is dropped.
+	c.cache.fetchedAt = time.Now().Add(-3 * time.Hour)
+	if n := testutil.CollectAndCount(c, "kube_allocation_cost_total"); n != 0 {
+		t.Errorf("collected %d cost series from expired data, want 0", n)
+	}
+}
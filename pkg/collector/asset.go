@@ -0,0 +1,102 @@
+package collector
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/client"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+// AssetFetcher fetches cluster asset data.
+type AssetFetcher func(ctx context.Context) (*types.AssetResponse, error)
+
+// AssetsCollector collects the cost of cluster assets, such as nodes, disks
+// and load balancers, from OpenCost's assets API. Its provider_id label
+// matches that of the cloud cost metric, so that the infrastructure of a
+// cluster can be correlated with the cloud bill. Data is fetched when
+// scraped, like that of AllocationCollector.
+type AssetsCollector struct {
+	cache *scrapeCache[*types.AssetResponse]
+
+	cost   *prometheus.Desc
+	upDesc *prometheus.Desc
+}
+
+// AssetsOption configures an AssetsCollector.
+type AssetsOption func(*AssetsCollector)
+
+// WithAssetsTTL sets how long fetched assets are served before they are
+// fetched again, and how long past that after failed fetches.
+func WithAssetsTTL(ttl, maxStale time.Duration) AssetsOption {
+	return func(c *AssetsCollector) {
+		c.cache.ttl = ttl
+		c.cache.maxStale = maxStale
+	}
+}
+
+// WithAssetFetcher replaces the client's FetchAssets as the source of
+// asset data.
+func WithAssetFetcher(fetch AssetFetcher) AssetsOption {
+	return func(c *AssetsCollector) {
+		c.cache.fetch = fetch
+	}
+}
+
+// NewAssetsCollector creates a collector of the assets c fetches for its
+// window.
+func NewAssetsCollector(c *client.Client, opts ...AssetsOption) *AssetsCollector {
+	collector := &AssetsCollector{
+		cache: newScrapeCache("assets", c.FetchAssets),
+		cost: prometheus.NewDesc(
+			"kube_asset_cost_total",
+			"Cost in USD of cluster assets over the configured window",
+			[]string{"asset_type", "name", "provider_id", "provider", "cluster"},
+			nil,
+		),
+		upDesc: prometheus.NewDesc(
+			selfNamespace+"_assets_up",
+			"Whether the last fetch from the OpenCost assets API succeeded",
+			nil,
+			nil,
+		),
+	}
+	for _, opt := range opts {
+		opt(collector)
+	}
+	return collector
+}
+
+// Describe implements prometheus.Collector.
+func (c *AssetsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.cost
+	ch <- c.upDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *AssetsCollector) Collect(ch chan<- prometheus.Metric) {
+	data, ok, up := c.cache.get()
+	sendUp(ch, c.upDesc, up)
+	if !ok {
+		return
+	}
+
+	type assetKey struct{ assetType, name, providerID, provider, cluster string }
+	costs := make(map[assetKey]float64)
+	for _, a := range data.Data {
+		key := assetKey{
+			assetType:  labelValue(a.TypeName()),
+			name:       labelValue(a.Properties.Name),
+			providerID: labelValue(a.Properties.ProviderID),
+			provider:   labelValue(strings.ToLower(a.Properties.Provider)),
+			cluster:    labelValue(a.Properties.Cluster),
+		}
+		costs[key] += a.TotalCost
+	}
+	for key, cost := range costs {
+		sendGauge(ch, c.cost, cost, key.assetType, key.name, key.providerID, key.provider, key.cluster)
+	}
+}
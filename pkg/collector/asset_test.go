@@ -0,0 +1,44 @@
+package collector
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/client"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+func TestAssetsCollector(t *testing.T) {
+	asset := func(assetType, name, providerID string, cost float64) types.Asset {
+		return types.Asset{
+			Type:       assetType,
+			Properties: types.AssetProperties{Provider: "AWS", Cluster: "main", Name: name, ProviderID: providerID},
+			TotalCost:  cost,
+		}
+	}
+	fetch := func(context.Context) (*types.AssetResponse, error) {
+		return &types.AssetResponse{Code: 200, Data: types.AssetSet{
+			"main/node/ip-1":  asset("Node", "ip-1", "i-0abc", 12.5),
+			"main/disk/vol-1": asset("Disk", "pvc-1", "vol-1", 0.8),
+			"main/lb/lb-1":    asset("LoadBalancer", "web", "arn:aws:elasticloadbalancing:lb-1", 0.6),
+		}}, nil
+	}
+	c := NewAssetsCollector(client.New("http://unused"), WithAssetFetcher(fetch))
+
+	const want = `
+# HELP cloudcost_exporter_assets_up Whether the last fetch from the OpenCost assets API succeeded
+# TYPE cloudcost_exporter_assets_up gauge
+cloudcost_exporter_assets_up 1
+# HELP kube_asset_cost_total Cost in USD of cluster assets over the configured window
+# TYPE kube_asset_cost_total gauge
+kube_asset_cost_total{asset_type="disk",cluster="main",name="pvc-1",provider="aws",provider_id="vol-1"} 0.8
+kube_asset_cost_total{asset_type="load_balancer",cluster="main",name="web",provider="aws",provider_id="arn:aws:elasticloadbalancing:lb-1"} 0.6
+kube_asset_cost_total{asset_type="node",cluster="main",name="ip-1",provider="aws",provider_id="i-0abc"} 12.5
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
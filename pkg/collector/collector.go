@@ -3,48 +3,171 @@ package collector
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
 	"log/slog"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/cache"
 	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/client"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/cron"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/currency"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/downsample"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/parallel"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/requestid"
 	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
 )
 
-const namespace = "aws_cloud"
+// DefaultNamespace is the namespace of the cost metric names, e.g.
+// aws_cloud_cost_total.
+const DefaultNamespace = "aws_cloud"
+
+const selfNamespace = "cloudcost_exporter"
+
+var tracer = otel.Tracer("github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/collector")
+
+// costLabels are the label names of the cost metric, in order.
+var costLabels = []string{"provider_id", "account_id", "service", "category", "cost_type", "region", "availability_zone", "owner", "environment", "cluster"}
+
+// amortizedNet is the index of the cost type the Kubernetes percent is
+// emitted for.
+var amortizedNet = slices.Index(types.CostTypes, "amortized_net")
 
 // CloudCostCollector collects AWS cloud cost metrics from OpenCost.
 type CloudCostCollector struct {
-	client *client.Client
+	source CostSource
 	cache  *cache.Cache
+	fetch  Fetcher
 
 	// Config options
 	emitKubePercentMetrics bool
-	currencySymbols        []string
+	invoiceEntityMetrics   bool
+	accountMetrics         bool
+	hourlyRateMetrics      bool
+	cumulative             *cumulativeCosts
+	restatements           *restatements
+	downsampler            downsample.Downsampler
+	currencySymbols        atomic.Pointer[[]string]
+	convertCurrencies      []string
+	refreshHooks           []RefreshHook
+	schedule               *cron.Schedule
+	shard                  Shard
+	perSet                 bool
+	perCostType            bool
+	namespace              string
+	maxSeries              int
+	topServices            int
+	filter                 Filter
+	labelMappings          atomic.Pointer[map[string]string]
+	clusterName            string
+	sourceClusters         map[string]string
+	sourceLabel            bool
+	cloudProviderLabel     bool
+	resourceIDLabels       bool
+	ownershipLabels        bool
+	ownership              atomic.Pointer[Ownership]
+	providers              []string
+	providerLabels         []providerLabel
+	resourceLabels         []resourceLabel
+	negativeCosts          NegativeCostPolicy
+	missingFields          MissingFieldPolicy
+	aggregate              []string
+	costPrecision          int
+	costLabels             []string
 
 	// Cost metrics
-	costTotal    *prometheus.Desc
-	kubePercent  *prometheus.Desc
-	exchangeRate *prometheus.Desc
+	costTotal       []costMetric // in USD, then in each of convertCurrencies
+	creditTotal     costMetric
+	hourlyRate      costMetric
+	cumulativeTotal costMetric
+	kubePercent     *prometheus.Desc
+	entityCost      *prometheus.Desc
+	accountCost     *prometheus.Desc
+	exchangeRate    *prometheus.Desc
+	currencyInfo    *prometheus.Desc
+	windowStart     *prometheus.Desc
+	windowEnd       *prometheus.Desc
+	restated        *prometheus.Desc
+	restatedDelta   *prometheus.Desc
 
 	// Self-observability metrics
+	queryWindowStart     *prometheus.Desc
+	queryWindowEnd       *prometheus.Desc
+	effectiveWindow      *prometheus.Desc
+	nextRefresh          *prometheus.Desc
+	throttled            *prometheus.Desc
+	lastThrottled        *prometheus.Desc
+	retries              *prometheus.Desc
+	retriesExhausted     *prometheus.Desc
+	responseBytes        *prometheus.Desc
+	transferredBytes     *prometheus.Desc
 	scrapeDuration       prometheus.Histogram
+	stageDuration        *prometheus.HistogramVec
 	scrapeErrors         prometheus.Counter
+	coercedValues        prometheus.Counter
+	invalidResponses     prometheus.Counter
+	skippedItems         prometheus.Counter
+	overlappingSets      prometheus.Counter
+	missingFieldItems    *prometheus.CounterVec
+	seriesDropped        prometheus.Counter
+	partialData          prometheus.Gauge
 	cacheHits            prometheus.Counter
 	cacheMisses          prometheus.Counter
 	cacheAge             prometheus.Gauge
 	lastSuccessfulScrape prometheus.Gauge
 
+	degraded    atomic.Bool
+	lastDropped atomic.Int64 // cost series dropped by the last scrape
+
 	mu         sync.Mutex
-	refreshing bool // prevents concurrent refresh goroutines
+	refreshing bool       // prevents concurrent refresh goroutines
+	inflight   *fetchCall // the fetch concurrent callers share
+}
+
+// fetchCall is a fetch shared by concurrent callers. data is set before
+// done is closed.
+type fetchCall struct {
+	done chan struct{}
+	data *types.CloudCostResponse
 }
 
 // Option is a functional option for configuring the CloudCostCollector.
 type Option func(*CloudCostCollector)
 
+// WithNamespace sets the namespace of the cost metric names, e.g. acme
+// for acme_cost_total, instead of DefaultNamespace, so that the exporter
+// can run alongside another exporting metrics of that namespace. See
+// ValidateNamespace. The exporter's own metrics are renamed with
+// selfmetrics.WithPrefix instead.
+func WithNamespace(namespace string) Option {
+	return func(c *CloudCostCollector) {
+		c.namespace = namespace
+	}
+}
+
+// ValidateNamespace checks that namespace yields valid metric names.
+func ValidateNamespace(namespace string) error {
+	if namespace == "" || !model.LegacyValidation.IsValidMetricName(namespace+"_cost_total") {
+		return fmt.Errorf("invalid metric namespace %q", namespace)
+	}
+	return nil
+}
+
 // WithKubePercentMetrics enables or disables the kubernetes percent metric.
 func WithKubePercentMetrics(enabled bool) Option {
 	return func(c *CloudCostCollector) {
@@ -52,103 +175,1026 @@ func WithKubePercentMetrics(enabled bool) Option {
 	}
 }
 
+// WithInvoiceEntityMetrics enables or disables the invoice entity metric,
+// which rolls costs up by the entity invoiced for them, e.g. the payer
+// account of an AWS organization with consolidated billing.
+func WithInvoiceEntityMetrics(enabled bool) Option {
+	return func(c *CloudCostCollector) {
+		c.invoiceEntityMetrics = enabled
+	}
+}
+
+// WithAccountMetrics enables or disables the account metric, which rolls
+// the costs of all services up by account, so that dashboards need not sum
+// the high-cardinality cost metric at query time.
+func WithAccountMetrics(enabled bool) Option {
+	return func(c *CloudCostCollector) {
+		c.accountMetrics = enabled
+	}
+}
+
+// WithHourlyRateMetrics enables or disables the hourly cost rate metric,
+// the cost of the latest complete day divided by 24. Unlike the cost metric,
+// whose value grows with the window, it suits threshold alerts. It is only
+// emitted when OpenCost returns daily sets.
+func WithHourlyRateMetrics(enabled bool) Option {
+	return func(c *CloudCostCollector) {
+		c.hourlyRateMetrics = enabled
+	}
+}
+
+// WithCumulativeMetrics enables or disables the cumulative cost counter,
+// which accumulates the spend observed in the cost sets so that increase()
+// works for cost as for other counters. Each set's spend is counted once by
+// its window, so it needs sets with known, non-overlapping windows, such as
+// the daily sets OpenCost returns unless it accumulates the window.
+func WithCumulativeMetrics(enabled bool) Option {
+	return func(c *CloudCostCollector) {
+		c.cumulative = nil
+		if enabled {
+			c.cumulative = newCumulativeCosts()
+		}
+	}
+}
+
+// WithAccumulate sets whether the cost sets of the window are summed into
+// one series each, the default. Without accumulation, every set, such as
+// each day of the window OpenCost returns, is emitted as series of its own
+// with a window_start label holding the RFC 3339 start of the set, so that
+// the daily cost history is queryable. Labels rather than sample timestamps
+// tell the sets apart, as Prometheus rejects samples older than its head
+// block. The cumulative cost counter, which needs one series per item
+// across sets, cannot be combined with it.
+func WithAccumulate(accumulate bool) Option {
+	return func(c *CloudCostCollector) {
+		c.perSet = !accumulate
+	}
+}
+
+// WithRestatementThreshold enables the detection of restated cost data:
+// windows that had ended when they were fetched before, and whose totals
+// changed by more than threshold, e.g. 0.01 for 1%, since. A threshold of 0
+// or less disables it.
+func WithRestatementThreshold(threshold float64) Option {
+	return func(c *CloudCostCollector) {
+		c.restatements = nil
+		if threshold > 0 {
+			c.restatements = newRestatements(threshold)
+		}
+	}
+}
+
+// WithDownsampling rolls the daily sets of long windows up to weeks or
+// months before they are cached, so that the cost metrics, refresh hooks
+// and other cache consumers all see the rolled up data. See
+// downsample.Downsampler.
+func WithDownsampling(d downsample.Downsampler) Option {
+	return func(c *CloudCostCollector) {
+		c.downsampler = d
+	}
+}
+
 // WithCurrencySymbols sets the target currency symbols for exchange rates.
+// They are expected to be ISO 4217 codes, as validated by currency.Parse.
 func WithCurrencySymbols(symbols []string) Option {
 	return func(c *CloudCostCollector) {
-		c.currencySymbols = symbols
+		c.SetCurrencySymbols(symbols)
+	}
+}
+
+// SetCurrencySymbols replaces the currency symbols at runtime, e.g. on a
+// configuration reload. It takes effect on the next scrape.
+func (c *CloudCostCollector) SetCurrencySymbols(symbols []string) {
+	c.currencySymbols.Store(&symbols)
+}
+
+// symbols returns the currency symbols.
+func (c *CloudCostCollector) symbols() []string {
+	if s := c.currencySymbols.Load(); s != nil {
+		return *s
+	}
+	return nil
+}
+
+// WithCurrencyConversion duplicates the cost metric into the given
+// currencies, converted with the exchange rates from USD fetched when
+// scraped, and adds a currency label telling the series apart, "USD" for
+// the original costs. The codes are expected to be ISO 4217 codes, as
+// validated by currency.Parse.
+func WithCurrencyConversion(codes []string) Option {
+	return func(c *CloudCostCollector) {
+		c.convertCurrencies = codes
+	}
+}
+
+// Fetcher fetches cloud costs. The default is the FetchCloudCosts of the
+// collector's source.
+type Fetcher func(ctx context.Context) (*types.CloudCostResponse, error)
+
+// WithFetcher replaces the source of cloud cost data, e.g. to read from a
+// leader replica instead of OpenCost.
+func WithFetcher(fetch Fetcher) Option {
+	return func(c *CloudCostCollector) {
+		c.fetch = fetch
+	}
+}
+
+// RefreshHook is invoked with freshly fetched data after every successful
+// refresh. Hooks run in the background and must not modify data.
+type RefreshHook func(ctx context.Context, data *types.CloudCostResponse)
+
+// WithRefreshHook registers a hook that runs after each successful refresh.
+func WithRefreshHook(hook RefreshHook) Option {
+	return func(c *CloudCostCollector) {
+		c.refreshHooks = append(c.refreshHooks, hook)
+	}
+}
+
+// WithRefreshSchedule refreshes the cache at the activations of schedule,
+// e.g. right after the provider's billing data is expected to update,
+// instead of when a scrape finds it older than the TTL. Scrapes still fetch
+// synchronously if the cache is empty or older than the TTL plus the max
+// stale duration. The refreshes are run by RunSchedule.
+func WithRefreshSchedule(schedule *cron.Schedule) Option {
+	return func(c *CloudCostCollector) {
+		c.schedule = schedule
+	}
+}
+
+// Shard assigns this replica a subset of the cost items so that several
+// replicas can split a large organization. Items are assigned by hashing
+// their account ID or service; all replicas must use the same Count and Key.
+// Label sets are unchanged, so sum() across replicas yields the totals.
+type Shard struct {
+	Index int
+	Count int
+	Key   string
+}
+
+// ShardKeys are the supported values of Shard.Key.
+var ShardKeys = []string{"account", "service"}
+
+// Validate checks that the shard is well-formed.
+func (s Shard) Validate() error {
+	if s.Count < 1 {
+		return fmt.Errorf("shard count must be at least 1, got %d", s.Count)
+	}
+	if s.Index < 0 || s.Index >= s.Count {
+		return fmt.Errorf("shard index %d out of range [0, %d)", s.Index, s.Count)
+	}
+	if !slices.Contains(ShardKeys, s.Key) {
+		return fmt.Errorf("unknown shard key %q (want one of %s)", s.Key, strings.Join(ShardKeys, ", "))
+	}
+	return nil
+}
+
+// Contains reports whether item belongs to the shard. Every item belongs to
+// an unsharded (Count <= 1) shard.
+func (s Shard) Contains(item types.CloudCostItem) bool {
+	if s.Count <= 1 {
+		return true
+	}
+	key := item.Properties.AccountID
+	if s.Key == "service" {
+		key = item.Properties.Service
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32()%uint32(s.Count)) == s.Index
+}
+
+// filter returns a copy of data holding only the shard's items.
+func (s Shard) filter(data *types.CloudCostResponse) *types.CloudCostResponse {
+	if s.Count <= 1 {
+		return data
+	}
+	out := &types.CloudCostResponse{Code: data.Code, Data: types.CloudCostData{Sets: make([]types.CloudCostSet, len(data.Data.Sets))}, Window: data.Window}
+	for i, set := range data.Data.Sets {
+		items := make(map[string]types.CloudCostItem)
+		for k, item := range set.CloudCosts {
+			if s.Contains(item) {
+				items[k] = item
+			}
+		}
+		out.Data.Sets[i].CloudCosts = items
+		out.Data.Sets[i].Window = set.Window
+	}
+	return out
+}
+
+// WithShard restricts the collector to the items of one shard. Data is
+// filtered before it is cached, so refresh hooks and other cache consumers
+// also see only the shard. Exchange rates are emitted by shard 0 only.
+func WithShard(s Shard) Option {
+	return func(c *CloudCostCollector) {
+		c.shard = s
+	}
+}
+
+// WithClusterName identifies the cluster the exporter runs in. Cost items
+// without a cluster label of their own get name as their cluster label, and
+// all other metrics of the collector get a constant cluster label, so that
+// dashboards spanning several clusters can tell the exporters apart.
+func WithClusterName(name string) Option {
+	return func(c *CloudCostCollector) {
+		c.clusterName = name
+	}
+}
+
+// WithSourceClusters sets the cluster label of the cost items without one
+// by the OpenCost instance they were fetched from in federation mode, keyed
+// by source name, so that one exporter can serve the OpenCost of several
+// clusters. Items of other sources fall back to WithClusterName.
+func WithSourceClusters(clusters map[string]string) Option {
+	return func(c *CloudCostCollector) {
+		c.sourceClusters = clusters
+	}
+}
+
+// WithSourceLabel adds a source label, the OpenCost instance an item was
+// fetched from in federation mode, to the cost metrics.
+func WithSourceLabel(enabled bool) Option {
+	return func(c *CloudCostCollector) {
+		c.sourceLabel = enabled
+	}
+}
+
+// WithCloudProviderLabel adds a provider label to the cost metrics: the
+// lowercased provider of an item, e.g. "aws", "gcp" or "azure", so that the
+// costs of mixed-provider responses can be told apart.
+func WithCloudProviderLabel(enabled bool) Option {
+	return func(c *CloudCostCollector) {
+		c.cloudProviderLabel = enabled
+	}
+}
+
+// WithResourceIDLabels adds resource_type and resource_name labels to the
+// cost metrics, parsed from the provider ID of an item with
+// types.ParseResource, e.g. "db" and "orders" for
+// arn:aws:rds:us-east-1:123:db:orders. Items whose provider ID is not in a
+// known format leave them empty.
+func WithResourceIDLabels(enabled bool) Option {
+	return func(c *CloudCostCollector) {
+		c.resourceIDLabels = enabled
+	}
+}
+
+// providerLabel is a cost metric label specific to one cloud provider.
+type providerLabel struct {
+	name  string
+	value func(types.CloudCostProperties) string
+}
+
+// providerLabels are the provider-specific cost metric labels enabled by
+// WithProviderLabels, by provider. Items of other providers leave them
+// empty.
+var providerLabels = map[string][]providerLabel{
+	"azure": {
+		{"subscription_id", func(p types.CloudCostProperties) string { a, _ := p.Azure(); return a.SubscriptionID }},
+		{"subscription_name", func(p types.CloudCostProperties) string { a, _ := p.Azure(); return a.SubscriptionName }},
+		{"resource_group", func(p types.CloudCostProperties) string { a, _ := p.Azure(); return a.ResourceGroup }},
+	},
+	"gcp": {
+		{"project_id", func(p types.CloudCostProperties) string { g, _ := p.GCP(); return g.ProjectID }},
+		{"project_name", func(p types.CloudCostProperties) string { g, _ := p.GCP(); return g.ProjectName }},
+		{"billing_account_id", func(p types.CloudCostProperties) string { g, _ := p.GCP(); return g.BillingAccountID }},
+	},
+}
+
+// ParseProviderLabels parses a comma-separated list of providers whose
+// specific labels are added to the cost metric, e.g. "azure,gcp".
+func ParseProviderLabels(s string) ([]string, error) {
+	var providers []string
+	for _, p := range strings.Split(s, ",") {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p == "" || slices.Contains(providers, p) {
+			continue
+		}
+		if _, ok := providerLabels[p]; !ok {
+			return nil, fmt.Errorf("no provider labels for %q: want one of %s", p, strings.Join(slices.Sorted(maps.Keys(providerLabels)), ", "))
+		}
+		providers = append(providers, p)
+	}
+	return providers, nil
+}
+
+// WithProviderLabels adds the labels specific to the given providers to the
+// cost metric, e.g. the subscription and resource group for "azure" or the
+// project and billing account for "gcp", so that their costs are not only
+// attributable by account. See ParseProviderLabels.
+func WithProviderLabels(providers []string) Option {
+	return func(c *CloudCostCollector) {
+		c.providers = providers
+	}
+}
+
+// resourceLabel is a cost metric label read from an OpenCost item label.
+type resourceLabel struct {
+	name   string
+	source types.LabelSource
+	key    string
+}
+
+// ParseResourceLabels parses a comma-separated list of OpenCost labels added
+// to the cost metric, e.g. "team,cost-center,tag:Project". Like the label
+// mappings, a label may be prefixed with "tag:" or "k8s:". Labels whose
+// sanitized names collide with each other or with the built-in labels,
+// including those only some options add, are rejected. See
+// ResourceLabelName.
+func ParseResourceLabels(s string) ([]string, error) {
+	reserved := slices.Concat(costLabels, []string{"provider", "resource_type", "resource_name", "team", "cost_center", "source", "window_start", "currency"})
+	for _, labels := range providerLabels {
+		for _, l := range labels {
+			reserved = append(reserved, l.name)
+		}
+	}
+	var keys []string
+	seen := make(map[string]string)
+	for _, key := range strings.Split(s, ",") {
+		key = strings.TrimSpace(key)
+		if key == "" || slices.Contains(keys, key) {
+			continue
+		}
+		name := ResourceLabelName(key)
+		if name == "" {
+			return nil, fmt.Errorf("invalid resource label %q: missing label name", key)
+		}
+		if strings.HasPrefix(name, "__") {
+			return nil, fmt.Errorf("invalid resource label %q: metric label %s is reserved", key, name)
+		}
+		if slices.Contains(reserved, name) {
+			return nil, fmt.Errorf("invalid resource label %q: metric label %s is built in", key, name)
+		}
+		if other, ok := seen[name]; ok {
+			return nil, fmt.Errorf("invalid resource label %q: metric label %s is already taken by %q", key, name, other)
+		}
+		seen[name] = key
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// ResourceLabelName returns the metric label an OpenCost label is exposed
+// as: the label without its "tag:" or "k8s:" prefix, with the characters
+// Prometheus does not allow replaced by underscores, e.g. "cost_center" for
+// "tag:cost-center" and "_2fa" for "2fa".
+func ResourceLabelName(key string) string {
+	_, key = labelSource(key)
+	var b strings.Builder
+	for i, r := range key {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+		default:
+			r = '_'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// WithResourceLabels adds the given OpenCost labels to the cost metric, after
+// the provider labels, named by ResourceLabelName. Items without a label
+// leave it empty. See ParseResourceLabels.
+func WithResourceLabels(keys []string) Option {
+	return func(c *CloudCostCollector) {
+		c.resourceLabels = nil
+		for _, key := range keys {
+			source, name := labelSource(key)
+			c.resourceLabels = append(c.resourceLabels, resourceLabel{ResourceLabelName(key), source, name})
+		}
+	}
+}
+
+// NegativeCostPolicy decides how negative costs, such as credits and
+// refunds, are reported.
+type NegativeCostPolicy string
+
+const (
+	// NegativeCostsPassThrough sums negative costs into the cost metric.
+	NegativeCostsPassThrough NegativeCostPolicy = "passthrough"
+	// NegativeCostsClamp reports series summing to a negative cost as 0.
+	NegativeCostsClamp NegativeCostPolicy = "clamp"
+	// NegativeCostsSplit leaves negative costs out of the cost metric and
+	// reports their amounts as positive values of the credit metric, so that
+	// the net cost is the cost minus the credit.
+	NegativeCostsSplit NegativeCostPolicy = "split"
+)
+
+// NegativeCostPolicies are the supported negative cost policies.
+var NegativeCostPolicies = []NegativeCostPolicy{NegativeCostsPassThrough, NegativeCostsClamp, NegativeCostsSplit}
+
+// ParseNegativeCostPolicy parses a negative cost policy; empty selects
+// NegativeCostsPassThrough.
+func ParseNegativeCostPolicy(s string) (NegativeCostPolicy, error) {
+	p := NegativeCostPolicy(strings.ToLower(strings.TrimSpace(s)))
+	if p == "" {
+		return NegativeCostsPassThrough, nil
+	}
+	if !slices.Contains(NegativeCostPolicies, p) {
+		return "", fmt.Errorf("unknown negative cost policy %q (want one of passthrough, clamp, split)", s)
+	}
+	return p, nil
+}
+
+// WithNegativeCostPolicy sets how negative costs are reported. The default
+// is NegativeCostsPassThrough.
+func WithNegativeCostPolicy(p NegativeCostPolicy) Option {
+	return func(c *CloudCostCollector) {
+		c.negativeCosts = p
+	}
+}
+
+// MaxCostPrecision is the largest number of decimal places cost values can
+// be rounded to; float64 cannot represent more for typical costs.
+const MaxCostPrecision = 10
+
+// WithCostPrecision rounds the aggregated cost and credit values to the
+// given number of decimal places, so that float sums such as
+// 1234.5600000000002 do not make dashboards and threshold alerts flap. A
+// negative value, the default, disables rounding. Values are summed with
+// compensated summation either way.
+func WithCostPrecision(decimals int) Option {
+	return func(c *CloudCostCollector) {
+		c.costPrecision = min(decimals, MaxCostPrecision)
+	}
+}
+
+// MissingFieldPolicy decides how cost items without an account ID or
+// service are handled.
+type MissingFieldPolicy string
+
+const (
+	// MissingFieldsKeep reports the items with blank labels.
+	MissingFieldsKeep MissingFieldPolicy = "keep"
+	// MissingFieldsUnallocated reports the missing fields as Unallocated.
+	MissingFieldsUnallocated MissingFieldPolicy = "unallocated"
+	// MissingFieldsDrop leaves the items out.
+	MissingFieldsDrop MissingFieldPolicy = "drop"
+)
+
+// MissingFieldPolicies are the supported missing field policies.
+var MissingFieldPolicies = []MissingFieldPolicy{MissingFieldsKeep, MissingFieldsUnallocated, MissingFieldsDrop}
+
+// Unallocated is the account ID or service of items without one with
+// MissingFieldsUnallocated.
+const Unallocated = "unallocated"
+
+// ParseMissingFieldPolicy parses a missing field policy; empty selects
+// MissingFieldsKeep.
+func ParseMissingFieldPolicy(s string) (MissingFieldPolicy, error) {
+	p := MissingFieldPolicy(strings.ToLower(strings.TrimSpace(s)))
+	if p == "" {
+		return MissingFieldsKeep, nil
+	}
+	if !slices.Contains(MissingFieldPolicies, p) {
+		return "", fmt.Errorf("unknown missing field policy %q (want one of keep, unallocated, drop)", s)
 	}
+	return p, nil
+}
+
+// WithMissingFieldPolicy sets how cost items without an account ID or
+// service are handled. The policy is applied before the data is cached, so
+// cache consumers see the same items as the cost metric. The default is
+// MissingFieldsKeep.
+func WithMissingFieldPolicy(p MissingFieldPolicy) Option {
+	return func(c *CloudCostCollector) {
+		c.missingFields = p
+	}
+}
+
+// WithAggregate tells the collector the properties OpenCost was asked to
+// aggregate cost items by, as configured with client.WithAggregate.
+// Aggregated properties missing from an item are read from its key, and
+// properties not aggregated by, which OpenCost leaves empty, are not
+// treated as missing fields. Empty means one item per resource.
+func WithAggregate(aggregate []string) Option {
+	return func(c *CloudCostCollector) {
+		c.aggregate = aggregate
+	}
+}
+
+// MappedLabels are the cost metric labels taken from OpenCost item labels.
+// By default each is read from the item label of the same name.
+var MappedLabels = []string{"owner", "environment", "cluster"}
+
+// ParseLabelMappings parses "metric_label=opencost_label,..." pairs that
+// read a mapped metric label from a differently named OpenCost label, e.g.
+// "owner=team,environment=env". The OpenCost label may be prefixed with
+// "tag:" or "k8s:" to read only provider tags or Kubernetes labels, e.g.
+// "owner=tag:team". See types.CloudCostProperties.Label.
+func ParseLabelMappings(s string) (map[string]string, error) {
+	mappings := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		label, key, ok := strings.Cut(pair, "=")
+		label, key = strings.TrimSpace(label), strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid label mapping %q: want metric_label=opencost_label", pair)
+		}
+		if !slices.Contains(MappedLabels, label) {
+			return nil, fmt.Errorf("invalid label mapping %q: %q is not one of %s", pair, label, strings.Join(MappedLabels, ", "))
+		}
+		if _, name := labelSource(key); name == "" {
+			return nil, fmt.Errorf("invalid label mapping %q: missing label name", pair)
+		}
+		mappings[label] = key
+	}
+	return mappings, nil
+}
+
+// WithLabelMappings sets the OpenCost labels the mapped metric labels are
+// read from. See ParseLabelMappings.
+func WithLabelMappings(mappings map[string]string) Option {
+	return func(c *CloudCostCollector) {
+		c.SetLabelMappings(mappings)
+	}
+}
+
+// SetLabelMappings replaces the label mappings at runtime, e.g. on a
+// configuration reload. It takes effect on the next scrape.
+func (c *CloudCostCollector) SetLabelMappings(mappings map[string]string) {
+	c.labelMappings.Store(&mappings)
 }
 
-// New creates a new CloudCostCollector.
-func New(c *client.Client, ca *cache.Cache, opts ...Option) *CloudCostCollector {
+// labelKey returns the OpenCost label a mapped metric label is read from.
+func labelKey(mappings map[string]string, label string) (types.LabelSource, string) {
+	if key, ok := mappings[label]; ok {
+		return labelSource(key)
+	}
+	return types.AnyLabel, label
+}
+
+// labelSource splits the "tag:" or "k8s:" prefix off an OpenCost label.
+func labelSource(key string) (types.LabelSource, string) {
+	if name, ok := strings.CutPrefix(key, "tag:"); ok {
+		return types.ProviderTag, name
+	}
+	if name, ok := strings.CutPrefix(key, "k8s:"); ok {
+		return types.KubernetesLabel, name
+	}
+	return types.AnyLabel, key
+}
+
+// New creates a new CloudCostCollector of the cloud costs source fetches,
+// usually a *client.Client.
+func New(source CostSource, ca *cache.Cache, opts ...Option) *CloudCostCollector {
 	collector := &CloudCostCollector{
-		client:                 c,
+		source:                 source,
 		cache:                  ca,
-		emitKubePercentMetrics: false,           // disabled by default
-		currencySymbols:        []string{"CNY", "EUR"}, // default symbols
-		costTotal: prometheus.NewDesc(
-			namespace+"_cost_total",
-			"AWS cloud cost in USD",
-			[]string{"provider_id", "account_id", "service", "category", "cost_type", "region", "availability_zone", "owner", "environment", "cluster"},
-			nil,
-		),
-		kubePercent: prometheus.NewDesc(
-			namespace+"_cost_kubernetes_percent",
-			"Percentage of cost attributed to Kubernetes",
-			[]string{"provider_id", "account_id", "service", "category", "cost_type", "region"},
-			nil,
-		),
-		exchangeRate: prometheus.NewDesc(
-			"currency_exchange_rate",
-			"Currency exchange rate from base to target currency",
-			[]string{"base", "target"},
-			nil,
-		),
-		scrapeDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
-			Namespace: "cloudcost_exporter",
-			Name:      "scrape_duration_seconds",
-			Help:      "Time to fetch cloud costs from OpenCost",
-			Buckets:   prometheus.DefBuckets,
-		}),
-		scrapeErrors: prometheus.NewCounter(prometheus.CounterOpts{
-			Namespace: "cloudcost_exporter",
-			Name:      "scrape_errors_total",
-			Help:      "Total number of scrape errors",
-		}),
-		cacheHits: prometheus.NewCounter(prometheus.CounterOpts{
-			Namespace: "cloudcost_exporter",
-			Name:      "cache_hits_total",
-			Help:      "Total number of cache hits",
-		}),
-		cacheMisses: prometheus.NewCounter(prometheus.CounterOpts{
-			Namespace: "cloudcost_exporter",
-			Name:      "cache_misses_total",
-			Help:      "Total number of cache misses",
-		}),
-		cacheAge: prometheus.NewGauge(prometheus.GaugeOpts{
-			Namespace: "cloudcost_exporter",
-			Name:      "cache_age_seconds",
-			Help:      "Age of cached data in seconds",
-		}),
-		lastSuccessfulScrape: prometheus.NewGauge(prometheus.GaugeOpts{
-			Namespace: "cloudcost_exporter",
-			Name:      "last_successful_scrape_timestamp",
-			Help:      "Unix timestamp of last successful scrape",
-		}),
+		emitKubePercentMetrics: false, // disabled by default
+		costPrecision:          -1,    // no rounding
+		namespace:              DefaultNamespace,
 	}
-
+	collector.fetch = source.FetchCloudCosts
+	collector.SetCurrencySymbols([]string{"CNY", "EUR"}) // default symbols
 	for _, opt := range opts {
 		opt(collector)
 	}
 
+	// The cost metric carries the cluster as a regular label instead.
+	var constLabels prometheus.Labels
+	if collector.clusterName != "" {
+		constLabels = prometheus.Labels{"cluster": collector.clusterName}
+	}
+
+	collector.costLabels = slices.Clone(costLabels)
+	if collector.cloudProviderLabel {
+		collector.costLabels = append(collector.costLabels, "provider")
+	}
+	if collector.resourceIDLabels {
+		collector.costLabels = append(collector.costLabels, "resource_type", "resource_name")
+	}
+	if collector.ownershipLabels {
+		collector.costLabels = append(collector.costLabels, "team", "cost_center")
+	}
+	for _, p := range collector.providers {
+		for _, l := range providerLabels[p] {
+			collector.providerLabels = append(collector.providerLabels, l)
+			collector.costLabels = append(collector.costLabels, l.name)
+		}
+	}
+	for _, l := range collector.resourceLabels {
+		collector.costLabels = append(collector.costLabels, l.name)
+	}
+	kubePercentLabels := []string{"provider_id", "account_id", "service", "category", "cost_type", "region"}
+	if collector.cloudProviderLabel {
+		kubePercentLabels = append(kubePercentLabels, "provider")
+	}
+	if collector.sourceLabel {
+		collector.costLabels = append(collector.costLabels, "source")
+		kubePercentLabels = append(kubePercentLabels, "source")
+	}
+	if collector.perSet {
+		collector.costLabels = append(collector.costLabels, "window_start")
+		kubePercentLabels = append(kubePercentLabels, "window_start")
+	}
+
+	costHelp := "AWS cloud cost in USD"
+	if len(collector.convertCurrencies) > 0 {
+		costHelp = "AWS cloud cost in USD and converted into the currency of the currency label"
+	}
+	costTotalLabels := collector.costLabels
+	if len(collector.convertCurrencies) > 0 && !collector.perCostType {
+		costTotalLabels = append(slices.Clone(costTotalLabels), "currency")
+	}
+	for _, code := range slices.Concat([]string{"USD"}, collector.convertCurrencies) {
+		if len(collector.costTotal) > 0 && !collector.perCostType {
+			// The currency label tells the conversions apart.
+			collector.costTotal = append(collector.costTotal, collector.costTotal[0])
+			continue
+		}
+		help := costHelp
+		if collector.perCostType {
+			help = "AWS cloud cost in " + code
+		}
+		collector.costTotal = append(collector.costTotal, collector.newCostMetric(
+			collector.namespace+"_cost_total",
+			func(costType string) string { return collector.costMetricName(costType, code) },
+			help,
+			costTotalLabels,
+		))
+	}
+	collector.creditTotal = collector.newCostMetric(
+		collector.namespace+"_credit_total",
+		func(costType string) string { return collector.namespace + "_credit_" + costType + "_usd" },
+		"AWS cloud credits in USD, as positive amounts, with the split negative cost policy",
+		collector.costLabels,
+	)
+	collector.hourlyRate = collector.newCostMetric(
+		collector.namespace+"_cost_hourly_rate",
+		func(costType string) string { return collector.costMetricName(costType, "USD") + "_per_hour" },
+		"AWS cloud cost in USD per hour, from the latest complete daily cost set",
+		collector.costLabels,
+	)
+	collector.cumulativeTotal = collector.newCostMetric(
+		collector.namespace+"_cost_usd_cumulative_total",
+		func(costType string) string { return collector.costMetricName(costType, "USD") + "_cumulative_total" },
+		"AWS cloud spend in USD observed since the exporter started",
+		collector.costLabels,
+	)
+	collector.kubePercent = prometheus.NewDesc(
+		collector.namespace+"_cost_kubernetes_percent",
+		"Percentage of cost attributed to Kubernetes",
+		kubePercentLabels,
+		constLabels,
+	)
+	entityLabels := []string{"invoice_entity_id", "invoice_entity_name", "cost_type"}
+	if collector.sourceLabel {
+		entityLabels = append(entityLabels, "source")
+	}
+	if collector.perSet {
+		entityLabels = append(entityLabels, "window_start")
+	}
+	entityHelp := "AWS cloud cost in USD by invoice entity"
+	if len(collector.convertCurrencies) > 0 {
+		entityLabels = append(entityLabels, "currency")
+		entityHelp = "AWS cloud cost in USD and converted into the currency of the currency label, by invoice entity"
+	}
+	collector.entityCost = prometheus.NewDesc(
+		collector.namespace+"_invoice_entity_cost_total",
+		entityHelp,
+		entityLabels,
+		constLabels,
+	)
+	accountLabels := []string{"account_id", "cost_type"}
+	if collector.sourceLabel {
+		accountLabels = append(accountLabels, "source")
+	}
+	if collector.perSet {
+		accountLabels = append(accountLabels, "window_start")
+	}
+	accountHelp := "AWS cloud cost in USD by account, summed across services"
+	if len(collector.convertCurrencies) > 0 {
+		accountLabels = append(accountLabels, "currency")
+		accountHelp = "AWS cloud cost in USD and converted into the currency of the currency label, by account, summed across services"
+	}
+	collector.accountCost = prometheus.NewDesc(
+		collector.namespace+"_account_cost_total",
+		accountHelp,
+		accountLabels,
+		constLabels,
+	)
+	collector.exchangeRate = prometheus.NewDesc(
+		"currency_exchange_rate",
+		"Currency exchange rate from base to target currency",
+		[]string{"base", "target"},
+		constLabels,
+	)
+	collector.currencyInfo = prometheus.NewDesc(
+		"currency_info",
+		"ISO 4217 currency of the costs and exchange rates, with its symbol and minor unit digits",
+		[]string{"code", "symbol", "decimals"},
+		constLabels,
+	)
+	collector.windowStart = prometheus.NewDesc(
+		collector.namespace+"_cost_window_start_timestamp_seconds",
+		"Unix timestamp of the earliest start of the cost item windows",
+		nil,
+		constLabels,
+	)
+	collector.windowEnd = prometheus.NewDesc(
+		collector.namespace+"_cost_window_end_timestamp_seconds",
+		"Unix timestamp of the latest end of the cost item windows",
+		nil,
+		constLabels,
+	)
+	collector.restated = prometheus.NewDesc(
+		collector.namespace+"_cost_restated_total",
+		"Number of ended cost windows whose totals changed by more than the restatement threshold when fetched again",
+		[]string{"cost_type"},
+		constLabels,
+	)
+	collector.restatedDelta = prometheus.NewDesc(
+		collector.namespace+"_cost_restatement_delta",
+		"Change in USD of the totals of the latest restated cost window",
+		[]string{"cost_type"},
+		constLabels,
+	)
+	collector.queryWindowStart = prometheus.NewDesc(
+		selfNamespace+"_query_window_start_timestamp_seconds",
+		"Unix timestamp of the start of the configured window as resolved by the exporter",
+		[]string{"window"},
+		constLabels,
+	)
+	collector.queryWindowEnd = prometheus.NewDesc(
+		selfNamespace+"_query_window_end_timestamp_seconds",
+		"Unix timestamp of the end of the configured window as resolved by the exporter",
+		[]string{"window"},
+		constLabels,
+	)
+	collector.effectiveWindow = prometheus.NewDesc(
+		selfNamespace+"_effective_window_info",
+		"Window the cost metrics were fetched for, which differs from the configured window after a fallback to a larger one",
+		[]string{"window", "configured_window"},
+		constLabels,
+	)
+	collector.nextRefresh = prometheus.NewDesc(
+		selfNamespace+"_next_refresh_timestamp_seconds",
+		"Unix timestamp of the next scheduled cache refresh",
+		nil,
+		constLabels,
+	)
+	collector.throttled = prometheus.NewDesc(
+		selfNamespace+"_throttled_total",
+		"Number of 429 Too Many Requests responses by request target",
+		[]string{"target"},
+		constLabels,
+	)
+	collector.lastThrottled = prometheus.NewDesc(
+		selfNamespace+"_last_throttled_timestamp_seconds",
+		"Unix timestamp of the last 429 Too Many Requests response by request target",
+		[]string{"target"},
+		constLabels,
+	)
+	collector.retries = prometheus.NewDesc(
+		selfNamespace+"_client_retries_total",
+		"Number of retried OpenCost requests, by the status code of the failure retried or error for failures without a response",
+		[]string{"reason"},
+		constLabels,
+	)
+	collector.retriesExhausted = prometheus.NewDesc(
+		selfNamespace+"_retry_budget_exhausted_total",
+		"Number of OpenCost fetches that failed after all retries",
+		nil,
+		constLabels,
+	)
+	collector.responseBytes = prometheus.NewDesc(
+		selfNamespace+"_client_response_bytes_total",
+		"Bytes of the OpenCost responses read, after decompression",
+		nil,
+		constLabels,
+	)
+	collector.transferredBytes = prometheus.NewDesc(
+		selfNamespace+"_client_response_transferred_bytes_total",
+		"Bytes of the OpenCost responses read as transferred, compressed if OpenCost compressed them",
+		nil,
+		constLabels,
+	)
+	collector.scrapeDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace:   selfNamespace,
+		Name:        "scrape_duration_seconds",
+		Help:        "Time to fetch cloud costs from OpenCost",
+		Buckets:     prometheus.DefBuckets,
+		ConstLabels: constLabels,
+	})
+	collector.stageDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:   selfNamespace,
+		Name:        "stage_duration_seconds",
+		Help:        "Time spent in each stage of fetching and exposing cloud costs: fetch and decode per OpenCost request, aggregate, emit and exchange_rate per scrape",
+		Buckets:     prometheus.DefBuckets,
+		ConstLabels: constLabels,
+	}, []string{"stage"})
+	collector.scrapeErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace:   selfNamespace,
+		Name:        "scrape_errors_total",
+		Help:        "Total number of scrape errors",
+		ConstLabels: constLabels,
+	})
+	collector.coercedValues = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace:   selfNamespace,
+		Name:        "coerced_values_total",
+		Help:        "Total number of cost values OpenCost sent as strings or null instead of numbers",
+		ConstLabels: constLabels,
+	})
+	collector.invalidResponses = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace:   selfNamespace,
+		Name:        "invalid_responses_total",
+		Help:        "Total number of OpenCost responses rejected by validation",
+		ConstLabels: constLabels,
+	})
+	collector.skippedItems = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace:   selfNamespace,
+		Name:        "skipped_items_total",
+		Help:        "Total number of malformed cost items left out of OpenCost responses",
+		ConstLabels: constLabels,
+	})
+	collector.overlappingSets = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace:   selfNamespace,
+		Name:        "overlapping_sets_total",
+		Help:        "Total number of OpenCost cost sets discarded because their window overlaps a more recent set",
+		ConstLabels: constLabels,
+	})
+	collector.missingFieldItems = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   selfNamespace,
+		Name:        "missing_field_items_total",
+		Help:        "Total number of fetched cost items without an account ID or service, by missing field",
+		ConstLabels: constLabels,
+	}, []string{"field"})
+	for _, field := range []string{"account_id", "service"} {
+		collector.missingFieldItems.WithLabelValues(field)
+	}
+	collector.seriesDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace:   selfNamespace,
+		Name:        "series_dropped_total",
+		Help:        "Total number of cost series left out of scrapes for exceeding the series limit",
+		ConstLabels: constLabels,
+	})
+	collector.partialData = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   selfNamespace,
+		Name:        "partial_data",
+		Help:        "Whether malformed cost items were left out of the last fetched data (1) or not (0)",
+		ConstLabels: constLabels,
+	})
+	collector.cacheHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace:   selfNamespace,
+		Name:        "cache_hits_total",
+		Help:        "Total number of cache hits",
+		ConstLabels: constLabels,
+	})
+	collector.cacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace:   selfNamespace,
+		Name:        "cache_misses_total",
+		Help:        "Total number of cache misses",
+		ConstLabels: constLabels,
+	})
+	collector.cacheAge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   selfNamespace,
+		Name:        "cache_age_seconds",
+		Help:        "Age of cached data in seconds",
+		ConstLabels: constLabels,
+	})
+	collector.lastSuccessfulScrape = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   selfNamespace,
+		Name:        "last_successful_scrape_timestamp",
+		Help:        "Unix timestamp of last successful scrape",
+		ConstLabels: constLabels,
+	})
+
 	return collector
 }
 
+// CostMetricName returns the fully-qualified name of the cost metric.
+func (c *CloudCostCollector) CostMetricName() string {
+	return c.namespace + "_cost_total"
+}
+
+// CostLabels returns the label names of the cost metric.
+func (c *CloudCostCollector) CostLabels() []string {
+	if c.perCostType {
+		return slices.Delete(slices.Clone(c.costLabels), 4, 5) // cost_type
+	}
+	if len(c.convertCurrencies) > 0 {
+		return append(slices.Clone(c.costLabels), "currency")
+	}
+	return slices.Clone(c.costLabels)
+}
+
+// SelfMetricPrefix returns the prefix the exporter's own metrics are
+// registered with, before any renaming by the selfmetrics package.
+func (c *CloudCostCollector) SelfMetricPrefix() string {
+	return selfNamespace
+}
+
+// SetDegraded leaves out the per-item detail while degraded is true, e.g.
+// under memory pressure: the provider_id label of the cost metrics is left
+// empty, so that all items of a series are summed into one and scrapes
+// create far fewer series.
+func (c *CloudCostCollector) SetDegraded(degraded bool) {
+	c.degraded.Store(degraded)
+}
+
 // Describe implements prometheus.Collector.
 func (c *CloudCostCollector) Describe(ch chan<- *prometheus.Desc) {
-	ch <- c.costTotal
+	for i, m := range c.costTotal {
+		if i == 0 || c.perCostType {
+			m.describe(ch)
+		}
+	}
+	if c.negativeCosts == NegativeCostsSplit {
+		c.creditTotal.describe(ch)
+	}
 	if c.emitKubePercentMetrics {
 		ch <- c.kubePercent
 	}
+	if c.invoiceEntityMetrics {
+		ch <- c.entityCost
+	}
+	if c.accountMetrics {
+		ch <- c.accountCost
+	}
+	if c.hourlyRateMetrics {
+		c.hourlyRate.describe(ch)
+	}
+	if c.cumulative != nil {
+		c.cumulativeTotal.describe(ch)
+	}
 	ch <- c.exchangeRate
+	ch <- c.currencyInfo
+	ch <- c.windowStart
+	ch <- c.windowEnd
+	if c.restatements != nil {
+		ch <- c.restated
+		ch <- c.restatedDelta
+	}
+	ch <- c.queryWindowStart
+	ch <- c.queryWindowEnd
+	ch <- c.effectiveWindow
+	ch <- c.nextRefresh
+	ch <- c.throttled
+	ch <- c.lastThrottled
+	ch <- c.retries
+	ch <- c.retriesExhausted
+	ch <- c.responseBytes
+	ch <- c.transferredBytes
 	c.scrapeDuration.Describe(ch)
+	c.stageDuration.Describe(ch)
 	c.scrapeErrors.Describe(ch)
+	c.coercedValues.Describe(ch)
+	c.invalidResponses.Describe(ch)
+	c.skippedItems.Describe(ch)
+	c.overlappingSets.Describe(ch)
+	c.missingFieldItems.Describe(ch)
+	c.seriesDropped.Describe(ch)
+	c.partialData.Describe(ch)
 	c.cacheHits.Describe(ch)
 	c.cacheMisses.Describe(ch)
 	c.cacheAge.Describe(ch)
 	c.lastSuccessfulScrape.Describe(ch)
 }
 
-// Collect implements prometheus.Collector.
-func (c *CloudCostCollector) Collect(ch chan<- prometheus.Metric) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// Data returns the cached cloud costs, fetching them if the cache is empty.
+// It reports false if no data is available.
+func (c *CloudCostCollector) Data(ctx context.Context) (*types.CloudCostResponse, bool) {
+	data := c.load(ctx)
+	return data, data != nil
+}
+
+// Refresh fetches the cost data now rather than when the cache expires, and
+// caches it. A fetch already in flight is shared, as with scrapes. It
+// reports false if the fetch failed, keeping the cached data.
+func (c *CloudCostCollector) Refresh(ctx context.Context) (*types.CloudCostResponse, bool) {
+	data := c.sharedFetch(ctx)
+	return data, data != nil
+}
+
+// Flush drops the cached data and fetches it again like Refresh, so that
+// nothing fetched before is served even if the fetch fails.
+func (c *CloudCostCollector) Flush(ctx context.Context) (*types.CloudCostResponse, bool) {
+	c.cache.Invalidate()
+	return c.Refresh(ctx)
+}
+
+// load returns the cached data, refreshing it in the background when stale
+// and fetching it synchronously when the cache is empty. With a refresh
+// schedule, stale data is left to the scheduled refresh.
+func (c *CloudCostCollector) load(ctx context.Context) *types.CloudCostResponse {
+	span := trace.SpanFromContext(ctx)
 
 	// Try cache first
 	data, isStale, ok := c.cache.Get()
+	span.SetAttributes(attribute.Bool("cache.hit", ok), attribute.Bool("cache.stale", isStale))
 	if ok {
 		c.cacheHits.Inc()
-		if isStale && !c.refreshing {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if isStale && !c.refreshing && c.schedule == nil {
 			// Try to refresh in background, but use stale data
 			c.refreshing = true
 			go func() {
@@ -158,87 +1204,514 @@ func (c *CloudCostCollector) Collect(ch chan<- prometheus.Metric) {
 				c.mu.Unlock()
 			}()
 		}
-	} else {
-		c.cacheMisses.Inc()
-		data = c.fetchAndCache()
+		return data
 	}
+	c.cacheMisses.Inc()
+	return c.sharedFetch(ctx)
+}
+
+// sharedFetch fetches and caches the cost data like fetchAndCache, sharing
+// the fetch with concurrent callers, so that simultaneous scrapes of an
+// empty cache and background refreshes send one request to OpenCost and
+// wait for it together. The fetch runs with the context of the caller that
+// started it; the others stop waiting when their own context ends.
+func (c *CloudCostCollector) sharedFetch(ctx context.Context) *types.CloudCostResponse {
+	c.mu.Lock()
+	if call := c.inflight; call != nil {
+		c.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.data
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	call := &fetchCall{done: make(chan struct{})}
+	c.inflight = call
+	c.mu.Unlock()
+
+	call.data = c.fetchAndCache(ctx)
+
+	c.mu.Lock()
+	c.inflight = nil
+	c.mu.Unlock()
+	close(call.done)
+	return call.data
+}
+
+// Collect implements prometheus.Collector.
+func (c *CloudCostCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, span := tracer.Start(context.Background(), "Collect")
+	defer span.End()
+
+	data := c.load(ctx)
 
 	// Update cache age metric
 	c.cacheAge.Set(c.cache.Age().Seconds())
 
 	// Emit self-observability metrics
 	c.scrapeDuration.Collect(ch)
+	c.stageDuration.Collect(ch)
 	c.scrapeErrors.Collect(ch)
+	c.coercedValues.Collect(ch)
+	c.invalidResponses.Collect(ch)
+	c.skippedItems.Collect(ch)
+	c.overlappingSets.Collect(ch)
+	c.missingFieldItems.Collect(ch)
+	// Collected last, so that the series dropped by this scrape count.
+	defer c.seriesDropped.Collect(ch)
+	c.partialData.Collect(ch)
 	c.cacheHits.Collect(ch)
 	c.cacheMisses.Collect(ch)
 	c.cacheAge.Collect(ch)
 	c.lastSuccessfulScrape.Collect(ch)
+	c.emitQueryWindow(ch)
+	c.emitRequestStats(ch)
+	if c.schedule != nil {
+		if next := c.schedule.Next(time.Now()); !next.IsZero() {
+			sendGauge(ch, c.nextRefresh, float64(next.Unix()))
+		}
+	}
+
+	c.emitCurrencyInfo(ch)
+	c.emitRestatements(ch)
 
 	if data == nil {
 		return
 	}
+	c.emitEffectiveWindow(ch, data)
+
+	rates := c.fetchExchangeRates(ctx)
+
+	// Emit cost metrics
+	c.emitCostMetrics(ctx, ch, data, rates)
+
+	// Emit exchange rate metrics
+	c.emitExchangeRates(ch, rates)
+}
+
+// emitQueryWindow reports the bounds of the configured window if the
+// exporter resolves it, e.g. for calendar keywords such as "month".
+func (c *CloudCostCollector) emitQueryWindow(ch chan<- prometheus.Metric) {
+	source, ok := c.source.(windowSource)
+	if !ok {
+		return
+	}
+	bounds, err := source.WindowBounds()
+	if err != nil || bounds.Start.IsZero() {
+		return
+	}
+	window := source.Window()
+	sendGauge(ch, c.queryWindowStart, float64(bounds.Start.Unix()), window)
+	sendGauge(ch, c.queryWindowEnd, float64(bounds.End.Unix()), window)
+}
+
+// emitRestatements reports the restatements detected so far, if enabled.
+func (c *CloudCostCollector) emitRestatements(ch chan<- prometheus.Metric) {
+	if c.restatements == nil {
+		return
+	}
+	count, delta := c.restatements.snapshot()
+	for i, costType := range types.CostTypes {
+		sendCounter(ch, c.restated, count[i], costType)
+		if count[i] > 0 {
+			sendGauge(ch, c.restatedDelta, c.round(delta[i]), costType)
+		}
+	}
+}
 
-	// Emit cost metrics
-	c.emitCostMetrics(ch, data)
+// emitEffectiveWindow reports the window data was fetched for.
+func (c *CloudCostCollector) emitEffectiveWindow(ch chan<- prometheus.Metric, data *types.CloudCostResponse) {
+	var configured string
+	if source, ok := c.source.(windowSource); ok {
+		configured = source.Window()
+	}
+	window := data.Window
+	if window == "" {
+		window = configured
+	}
+	sendGauge(ch, c.effectiveWindow, 1, window, configured)
+}
 
-	// Emit exchange rate metrics
-	c.emitExchangeRates(ch)
+// emitRequestStats reports the retries, response sizes and 429 responses
+// of the requests to OpenCost and the exchange rate provider.
+func (c *CloudCostCollector) emitRequestStats(ch chan<- prometheus.Metric) {
+	source, ok := c.source.(requestStatsSource)
+	if !ok {
+		return
+	}
+	retries := source.RetryStats()
+	for reason, n := range retries.ByReason {
+		ch <- prometheus.MustNewConstMetric(c.retries, prometheus.CounterValue, float64(n), reason)
+	}
+	ch <- prometheus.MustNewConstMetric(c.retriesExhausted, prometheus.CounterValue, float64(retries.Exhausted))
+	transfers := source.Transfers()
+	ch <- prometheus.MustNewConstMetric(c.responseBytes, prometheus.CounterValue, float64(transfers.Bytes))
+	ch <- prometheus.MustNewConstMetric(c.transferredBytes, prometheus.CounterValue, float64(transfers.TransferredBytes))
+	for _, target := range client.Targets {
+		stats := source.Throttling(target)
+		ch <- prometheus.MustNewConstMetric(c.throttled, prometheus.CounterValue, float64(stats.Count), target)
+		if !stats.Last.IsZero() {
+			sendGauge(ch, c.lastThrottled, float64(stats.Last.Unix()), target)
+		}
+	}
+}
+
+// emitCurrencyInfo describes the currencies of the cost and exchange rate
+// metrics. Like the exchange rates, only the first shard emits them.
+func (c *CloudCostCollector) emitCurrencyInfo(ch chan<- prometheus.Metric) {
+	if c.shard.Index != 0 {
+		return
+	}
+	var currencies []currency.Currency
+	for _, code := range slices.Concat([]string{"USD"}, c.symbols(), c.convertCurrencies) {
+		if cur, ok := currency.Lookup(code); ok && !slices.Contains(currencies, cur) {
+			currencies = append(currencies, cur)
+		}
+	}
+	for _, cur := range currencies {
+		sendGauge(ch, c.currencyInfo, 1, cur.Code, cur.Symbol, strconv.Itoa(cur.Decimals))
+	}
 }
 
-func (c *CloudCostCollector) fetchAndCache() *types.CloudCostResponse {
+func (c *CloudCostCollector) fetchAndCache(ctx context.Context) *types.CloudCostResponse {
+	// Tie the requests and log lines of this fetch together.
+	ctx = requestid.Ensure(ctx)
+	ctx = client.ContextWithStageObserver(ctx, c.observeStage)
 	start := time.Now()
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	data, err := c.client.FetchCloudCosts(ctx)
+	data, err := c.fetch(ctx)
 	c.scrapeDuration.Observe(time.Since(start).Seconds())
 
+	var throttled *client.ThrottledError
+	if errors.As(err, &throttled) {
+		// Counted by cloudcost_exporter_throttled_total instead, so that
+		// OpenCost capacity issues are not taken for failures.
+		slog.WarnContext(ctx, "OpenCost is throttling requests", "until", throttled.Until)
+		return nil
+	}
 	if err != nil {
 		c.scrapeErrors.Inc()
-		slog.Error("failed to fetch cloud costs", "error", err)
+		if errors.Is(err, types.ErrInvalidResponse) {
+			c.invalidResponses.Inc()
+		}
+		slog.ErrorContext(ctx, "failed to fetch cloud costs", "error", err)
 		return nil
 	}
 
+	// Skipped items cannot be assigned to a shard, so every shard counts
+	// them.
+	c.skippedItems.Add(float64(len(data.Skipped())))
+	if data.Partial() {
+		c.partialData.Set(1)
+	} else {
+		c.partialData.Set(0)
+	}
+	if n := data.DropOverlapping(); n > 0 {
+		// Summing overlapping sets would count their costs twice.
+		slog.WarnContext(ctx, "discarded cost sets overlapping a more recent set", "sets", n)
+		c.overlappingSets.Add(float64(n))
+	}
+	if n := c.fillAggregated(data); n > 0 {
+		slog.WarnContext(ctx, "cost item keys do not match the aggregate properties", "items", n, "aggregate", strings.Join(c.aggregate, ","))
+	}
+	data = c.shard.filter(data)
+	if n := c.handleMissingFields(data); n > 0 && c.missingFields == MissingFieldsDrop {
+		slog.WarnContext(ctx, "left out cost items without an account ID or service", "items", n)
+	}
+	if c.restatements != nil {
+		if n := c.restatements.observe(data.Data.Sets, time.Now()); n > 0 {
+			slog.WarnContext(ctx, "OpenCost restated the costs of ended windows; the samples scraped for them no longer hold", "windows", n)
+		}
+	}
+	c.coercedValues.Add(float64(data.Coercions()))
+	if rolled, ok := c.downsampler.Apply(data); ok {
+		slog.DebugContext(ctx, "downsampled cost sets", "period", c.downsampler.Period, "sets", len(data.Data.Sets), "downsampled_sets", len(rolled.Data.Sets))
+		data = rolled
+	}
 	c.cache.Set(data)
 	c.lastSuccessfulScrape.SetToCurrentTime()
+	c.runRefreshHooks(ctx, data)
 	return data
 }
 
+// fillAggregated fills in the aggregated properties that the items of
+// data lack from their keys, since OpenCost may only encode them there. It
+// returns the number of items whose keys cannot be parsed.
+func (c *CloudCostCollector) fillAggregated(data *types.CloudCostResponse) int {
+	if len(c.aggregate) == 0 {
+		return 0
+	}
+	n := 0
+	for i := range data.Data.Sets {
+		set := &data.Data.Sets[i]
+		for key, item := range set.CloudCosts {
+			fromKey, err := types.ParseKey(key, c.aggregate)
+			if err != nil {
+				n++
+				continue
+			}
+			filled := false
+			for _, a := range c.aggregate {
+				if v, _ := item.Properties.Property(a); v != "" {
+					continue
+				}
+				if v, _ := fromKey.Property(a); v != "" {
+					item.Properties.SetProperty(a, v)
+					filled = true
+				}
+			}
+			if filled {
+				set.CloudCosts[key] = item
+			}
+		}
+	}
+	return n
+}
+
+// handleMissingFields counts the items of data without an account ID or
+// service and applies the missing field policy to them. It returns their
+// number. With an aggregate, only the aggregated properties count as
+// missing.
+func (c *CloudCostCollector) handleMissingFields(data *types.CloudCostResponse) int {
+	checked := map[string]string{"accountID": "account_id", "service": "service"}
+	if len(c.aggregate) > 0 {
+		for property := range checked {
+			if !slices.Contains(c.aggregate, property) {
+				delete(checked, property)
+			}
+		}
+	}
+	if len(checked) == 0 {
+		return 0
+	}
+	n := 0
+	for i := range data.Data.Sets {
+		set := &data.Data.Sets[i]
+		for key, item := range set.CloudCosts {
+			missing := false
+			for property, field := range checked {
+				if v, _ := item.Properties.Property(property); strings.TrimSpace(v) != "" {
+					continue
+				}
+				c.missingFieldItems.WithLabelValues(field).Inc()
+				item.Properties.SetProperty(property, Unallocated)
+				missing = true
+			}
+			if !missing {
+				continue
+			}
+			n++
+			switch c.missingFields {
+			case MissingFieldsDrop:
+				delete(set.CloudCosts, key)
+			case MissingFieldsUnallocated:
+				set.CloudCosts[key] = item
+			}
+		}
+	}
+	return n
+}
+
+// runRefreshHooks runs the registered hooks in the background so slow
+// consumers (e.g. object storage uploads) never delay a scrape. The hooks
+// log with the request ID of the fetch in ctx.
+func (c *CloudCostCollector) runRefreshHooks(ctx context.Context, data *types.CloudCostResponse) {
+	if len(c.refreshHooks) == 0 {
+		return
+	}
+	id := requestid.FromContext(ctx)
+	go func() {
+		ctx, cancel := context.WithTimeout(requestid.NewContext(context.Background(), id), 5*time.Minute)
+		defer cancel()
+		for _, hook := range c.refreshHooks {
+			hook(ctx, data)
+		}
+	}()
+}
+
+// RunSchedule refreshes the cache at each activation of the refresh
+// schedule until ctx is canceled. It returns immediately without one.
+func (c *CloudCostCollector) RunSchedule(ctx context.Context) {
+	if c.schedule == nil {
+		return
+	}
+	for {
+		next := c.schedule.Next(time.Now())
+		if next.IsZero() {
+			slog.Warn("refresh schedule has no further activations", "schedule", c.schedule.String())
+			return
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		slog.Debug("scheduled cache refresh", "schedule", c.schedule.String())
+		c.refreshCache()
+	}
+}
+
 func (c *CloudCostCollector) refreshCache() {
-	c.fetchAndCache()
+	ctx, span := tracer.Start(context.Background(), "refreshCache")
+	defer span.End()
+	c.sharedFetch(ctx)
+}
+
+// costKey identifies a series of the cost metric.
+type costKey struct {
+	providerID       string
+	accountID        string
+	service          string
+	category         string
+	region           string
+	availabilityZone string
+	owner            string
+	environment      string
+	cluster          string
+	cloudProvider    string
+	resourceType     string
+	resourceName     string
+	team             string
+	costCenter       string
+	provider         string // provider label values, joined
+	resource         string // resource label values, joined
+	source           string
+	windowStart      string // start of the cost set, without accumulation
 }
 
-func (c *CloudCostCollector) emitCostMetrics(ch chan<- prometheus.Metric, data *types.CloudCostResponse) {
-	// Aggregate costs by service/category/labels
-	type costKey struct {
-		providerID       string
-		accountID        string
-		service          string
-		category         string
-		region           string
-		availabilityZone string
-		owner            string
-		environment      string
-		cluster          string
+// labels returns the label values of the key, without the cost type.
+func (c *CloudCostCollector) labels(key costKey) []string {
+	labels := []string{key.providerID, key.accountID, key.service, key.category, key.region, key.availabilityZone, key.owner, key.environment, key.cluster}
+	if c.cloudProviderLabel {
+		labels = append(labels, key.cloudProvider)
+	}
+	if c.resourceIDLabels {
+		labels = append(labels, key.resourceType, key.resourceName)
+	}
+	if c.ownershipLabels {
+		labels = append(labels, key.team, key.costCenter)
+	}
+	if len(c.providerLabels) > 0 {
+		labels = append(labels, strings.Split(key.provider, "\x00")...)
 	}
+	if len(c.resourceLabels) > 0 {
+		labels = append(labels, strings.Split(key.resource, "\x00")...)
+	}
+	if c.sourceLabel {
+		labels = append(labels, key.source)
+	}
+	if c.perSet {
+		labels = append(labels, key.windowStart)
+	}
+	return labels
+}
 
-	aggregated := make(map[costKey]*aggregatedCost)
+// entityKey identifies an invoice entity rollup series.
+type entityKey struct {
+	id          string
+	name        string
+	source      string
+	windowStart string // start of the cost set, without accumulation
+}
+
+// accountKey identifies an account rollup series.
+type accountKey struct {
+	id          string
+	source      string
+	windowStart string // start of the cost set, without accumulation
+}
+
+// setAggregate holds the aggregated costs of one cost set.
+type setAggregate struct {
+	costs    map[costKey]*aggregatedCost
+	entities map[entityKey]*aggregatedCost
+	accounts map[accountKey]*aggregatedCost
+	// observed holds the per-item detail for the cumulative cost.
+	observed map[costKey]*aggregatedCost
+	window   types.Window // bounds of all valid item windows
+}
+
+func (c *CloudCostCollector) emitCostMetrics(ctx context.Context, ch chan<- prometheus.Metric, data *types.CloudCostResponse, exchangeRates *types.ExchangeRateResponse) {
+	_, span := tracer.Start(ctx, "aggregate")
+	defer span.End()
+	start := time.Now()
+
+	rateSet := -1
+	if c.hourlyRateMetrics {
+		rateSet = latestDailySet(data, time.Now())
+	}
+
+	var mappings map[string]string
+	if m := c.labelMappings.Load(); m != nil {
+		mappings = *m
+	}
+	ownerSource, ownerKey := labelKey(mappings, "owner")
+	environmentSource, environmentKey := labelKey(mappings, "environment")
+	clusterSource, clusterKey := labelKey(mappings, "cluster")
+	var ownership Ownership
+	if o := c.ownership.Load(); o != nil {
+		ownership = *o
+	}
 
 	slog.Debug("processing cloud cost data",
 		"num_sets", len(data.Data.Sets),
 	)
 
-	for setIdx, set := range data.Data.Sets {
+	degraded := c.degraded.Load()
+	split := c.negativeCosts == NegativeCostsSplit
+	add := func(m map[costKey]*aggregatedCost, key costKey, item types.CloudCostItem) {
+		if m[key] == nil {
+			m[key] = &aggregatedCost{}
+		}
+		m[key].add(item, split)
+	}
+
+	// Aggregate costs by service/category/labels, set by set concurrently
+	sets := make([]setAggregate, len(data.Data.Sets))
+	parallel.For(len(sets), parallel.Workers(), func(setIdx int) {
+		set := data.Data.Sets[setIdx]
 		slog.Debug("processing cloud cost set",
 			"set_index", setIdx,
 			"num_items", len(set.CloudCosts),
 		)
+		agg := &sets[setIdx]
+		agg.costs = make(map[costKey]*aggregatedCost)
+		if c.invoiceEntityMetrics {
+			agg.entities = make(map[entityKey]*aggregatedCost)
+		}
+		if c.accountMetrics {
+			agg.accounts = make(map[accountKey]*aggregatedCost)
+		}
+		if c.cumulative != nil {
+			agg.observed = make(map[costKey]*aggregatedCost)
+		}
+		var windowStart string
+		if c.perSet {
+			if bounds := set.Bounds(); !bounds.Start.IsZero() {
+				windowStart = bounds.Start.UTC().Format(time.RFC3339)
+			}
+		}
 
 		for _, item := range set.CloudCosts {
+			if !c.filter.Matches(item) {
+				continue
+			}
+
 			// Extract labels
-			owner := item.Properties.Labels["owner"]
-			environment := item.Properties.Labels["environment"]
-			cluster := item.Properties.Labels["cluster"]
+			owner := item.Properties.Label(ownerSource, ownerKey)
+			environment := item.Properties.Label(environmentSource, environmentKey)
+			cluster := item.Properties.Label(clusterSource, clusterKey)
+			if cluster == "" {
+				cluster = c.sourceClusters[item.Source]
+			}
+			if cluster == "" {
+				cluster = c.clusterName
+			}
 			region := item.Properties.RegionID
 			availabilityZone := item.Properties.AvailabilityZone
 
@@ -264,104 +1737,550 @@ func (c *CloudCostCollector) emitCostMetrics(ch chan<- prometheus.Metric, data *
 				"kube_percent", item.ListCost.KubernetesPercent,
 			)
 
+			if item.Window.Validate() == nil {
+				agg.window = extendWindow(agg.window, item.Window)
+			}
+
 			key := costKey{
-				providerID:       item.Properties.ProviderID,
-				accountID:        item.Properties.AccountID,
-				service:          item.Properties.Service,
-				category:         item.Properties.Category,
-				region:           region,
-				availabilityZone: availabilityZone,
-				owner:            owner,
-				environment:      environment,
-				cluster:          cluster,
+				providerID:       labelValue(item.Properties.ProviderID),
+				accountID:        labelValue(item.Properties.AccountID),
+				service:          labelValue(item.Properties.Service),
+				category:         labelValue(item.Properties.Category),
+				region:           labelValue(region),
+				availabilityZone: labelValue(availabilityZone),
+				owner:            labelValue(owner),
+				environment:      labelValue(environment),
+				cluster:          labelValue(cluster),
+				source:           labelValue(item.Source),
+			}
+			if c.cloudProviderLabel {
+				key.cloudProvider = labelValue(strings.ToLower(item.Properties.Provider))
+			}
+			if c.resourceIDLabels {
+				resource := item.Properties.Resource()
+				key.resourceType = labelValue(resource.Type)
+				key.resourceName = labelValue(resource.Name)
+			}
+			if c.ownershipLabels {
+				accountOwner := ownership[item.Properties.AccountID]
+				key.team = labelValue(accountOwner.Team)
+				key.costCenter = labelValue(accountOwner.CostCenter)
+			}
+			if len(c.providerLabels) > 0 {
+				values := make([]string, len(c.providerLabels))
+				for i, l := range c.providerLabels {
+					values[i] = labelValue(l.value(item.Properties))
+				}
+				key.provider = strings.Join(values, "\x00")
+			}
+			if len(c.resourceLabels) > 0 {
+				values := make([]string, len(c.resourceLabels))
+				for i, l := range c.resourceLabels {
+					values[i] = labelValue(item.Properties.Label(l.source, l.key))
+				}
+				key.resource = strings.Join(values, "\x00")
+			}
+
+			// The cumulative cost keeps the per-item detail, as
+			// dropping it would count the spend again.
+			if agg.observed != nil {
+				add(agg.observed, key, item)
+			}
+
+			if degraded {
+				key.providerID = ""
+				key.resourceName = ""
+			}
+			key.windowStart = windowStart
+
+			add(agg.costs, key, item)
+
+			if agg.entities != nil {
+				entity := entityKey{
+					id:          labelValue(item.Properties.InvoiceEntityID),
+					name:        labelValue(item.Properties.InvoiceEntityName),
+					source:      key.source,
+					windowStart: key.windowStart,
+				}
+				if agg.entities[entity] == nil {
+					agg.entities[entity] = &aggregatedCost{}
+				}
+				agg.entities[entity].add(item, split)
+			}
+			if agg.accounts != nil {
+				account := accountKey{id: key.accountID, source: key.source, windowStart: key.windowStart}
+				if agg.accounts[account] == nil {
+					agg.accounts[account] = &aggregatedCost{}
+				}
+				agg.accounts[account].add(item, split)
 			}
+		}
+	})
 
+	// Sum the aggregates of the sets with merge, in set order so that the
+	// totals do not depend on which worker finished first.
+	aggregated := make(map[costKey]*aggregatedCost)
+	entities := make(map[entityKey]*aggregatedCost)
+	accounts := make(map[accountKey]*aggregatedCost)
+	var rates map[costKey]*aggregatedCost
+	var window types.Window
+	var observed []observedSet
+	for setIdx, agg := range sets {
+		for key, cost := range agg.costs {
 			if aggregated[key] == nil {
 				aggregated[key] = &aggregatedCost{}
 			}
-
-			aggregated[key].listCost += item.ListCost.Cost
-			aggregated[key].netCost += item.NetCost.Cost
-			aggregated[key].amortizedNetCost += item.AmortizedNetCost.Cost
-			aggregated[key].invoicedCost += item.InvoicedCost.Cost
-			aggregated[key].amortizedCost += item.AmortizedCost.Cost
-			aggregated[key].kubePercent = item.ListCost.KubernetesPercent
+			aggregated[key].merge(cost)
+		}
+		for key, cost := range agg.entities {
+			if entities[key] == nil {
+				entities[key] = &aggregatedCost{}
+			}
+			entities[key].merge(cost)
+		}
+		for key, cost := range agg.accounts {
+			if accounts[key] == nil {
+				accounts[key] = &aggregatedCost{}
+			}
+			accounts[key].merge(cost)
+		}
+		if setIdx == rateSet {
+			rates = agg.costs
+		}
+		if !agg.window.Start.IsZero() {
+			window = extendWindow(window, agg.window)
+		}
+		if agg.observed != nil {
+			costs := make(map[costKey][5]float64, len(agg.observed))
+			for key, cost := range agg.observed {
+				var values [5]float64
+				for i := range values {
+					values[i] = c.costValue(cost.costs[i])
+				}
+				costs[key] = values
+			}
+			observed = append(observed, observedSet{window: data.Data.Sets[setIdx].Bounds(), costs: costs})
 		}
 	}
 
 	slog.Debug("aggregation complete",
 		"num_unique_keys", len(aggregated),
 	)
+	if keep := c.rankServices(aggregated); keep != nil {
+		aggregated = foldServices(aggregated, keep)
+		rates = foldServices(rates, keep)
+	}
+	c.limitSeries(aggregated)
+	span.SetAttributes(attribute.Int("opencost.sets", len(data.Data.Sets)), attribute.Int("aggregate.series", len(aggregated)))
+	c.observeStage(stageAggregate, time.Since(start))
+	start = time.Now()
+	defer func() { c.observeStage(stageEmit, time.Since(start)) }()
+
+	if !window.Start.IsZero() {
+		sendGauge(ch, c.windowStart, float64(window.Start.Unix()))
+		sendGauge(ch, c.windowEnd, float64(window.End.Unix()))
+	}
+
+	// The Kubernetes percent has fewer labels than the cost, so the series
+	// of several keys are averaged into one, by its label values.
+	type kubeSeries struct {
+		labels  []string
+		percent kubePercent
+	}
+	var kube map[string]*kubeSeries
+	if c.emitKubePercentMetrics {
+		kube = make(map[string]*kubeSeries)
+	}
 
 	// Emit metrics for each aggregated cost
 	for key, cost := range aggregated {
-	labels := []string{key.providerID, key.accountID, key.service, key.category, key.region, key.availabilityZone, key.owner, key.environment, key.cluster}
+		labels := c.labels(key)
 
 		// Emit each cost type
-		c.emitCost(ch, labels, "list", cost.listCost)
-		c.emitCost(ch, labels, "net", cost.netCost)
-		c.emitCost(ch, labels, "amortized_net", cost.amortizedNetCost)
-		c.emitCost(ch, labels, "invoiced", cost.invoicedCost)
-		c.emitCost(ch, labels, "amortized", cost.amortizedCost)
+		for i := range types.CostTypes {
+			c.emitCostTotal(ch, labels, i, cost.costs[i], exchangeRates)
+			if credit := c.round(cost.credits[i].value()); credit > 0 {
+				c.creditTotal.send(ch, prometheus.GaugeValue, i, credit, labels)
+			}
+			if rate := rates[key]; rate != nil {
+				c.hourlyRate.send(ch, prometheus.GaugeValue, i, c.costValue(rate.costs[i].div(24)), labels)
+			}
+		}
 
-		// Emit kubernetes percent (only for amortized_net, to avoid duplication)
+		// Emit the Kubernetes percent of amortized_net only, to avoid duplication
 		if c.emitKubePercentMetrics {
-			ch <- prometheus.MustNewConstMetric(
-				c.kubePercent,
-				prometheus.GaugeValue,
-				cost.kubePercent,
-				key.providerID, key.accountID, key.service, key.category, "amortized_net", key.region,
-			)
+			kubeLabels := []string{key.providerID, key.accountID, key.service, key.category, "amortized_net", key.region}
+			if c.cloudProviderLabel {
+				kubeLabels = append(kubeLabels, key.cloudProvider)
+			}
+			if c.sourceLabel {
+				kubeLabels = append(kubeLabels, key.source)
+			}
+			if c.perSet {
+				kubeLabels = append(kubeLabels, key.windowStart)
+			}
+			id := strings.Join(kubeLabels, "\x00")
+			if kube[id] == nil {
+				kube[id] = &kubeSeries{labels: kubeLabels}
+			}
+			kube[id].percent.merge(cost.kubePercent[amortizedNet])
+		}
+	}
+	for _, series := range kube {
+		sendGauge(ch, c.kubePercent, series.percent.value(), series.labels...)
+	}
+
+	// Emit the cumulative costs, including those of series no longer in
+	// the data
+	if c.cumulative != nil {
+		if n := c.cumulative.observe(observed); n > 0 {
+			slog.Debug("cost sets not counted in the cumulative cost", "sets", n)
+		}
+		for key, total := range c.cumulative.snapshot() {
+			labels := c.labels(key)
+			for i := range types.CostTypes {
+				c.cumulativeTotal.send(ch, prometheus.CounterValue, i, c.round(total[i]), labels)
+			}
+		}
+	}
+
+	// Emit the invoice entity rollups
+	for key, cost := range entities {
+		for i, costType := range types.CostTypes {
+			labels := []string{key.id, key.name, costType}
+			if c.sourceLabel {
+				labels = append(labels, key.source)
+			}
+			if c.perSet {
+				labels = append(labels, key.windowStart)
+			}
+			c.emitRollup(ch, c.entityCost, labels, cost.costs[i], exchangeRates)
+		}
+	}
+
+	// Emit the account rollups
+	for key, cost := range accounts {
+		for i, costType := range types.CostTypes {
+			labels := []string{key.id, costType}
+			if c.sourceLabel {
+				labels = append(labels, key.source)
+			}
+			if c.perSet {
+				labels = append(labels, key.windowStart)
+			}
+			c.emitRollup(ch, c.accountCost, labels, cost.costs[i], exchangeRates)
+		}
+	}
+}
+
+// emitRollup sends an invoice entity or account rollup in USD and, like
+// emitCostTotal, converted into the currencies of WithCurrencyConversion.
+func (c *CloudCostCollector) emitRollup(ch chan<- prometheus.Metric, desc *prometheus.Desc, labels []string, s sum, rates *types.ExchangeRateResponse) {
+	if len(c.convertCurrencies) == 0 {
+		sendGauge(ch, desc, c.costValue(s), labels...)
+		return
+	}
+	sendGauge(ch, desc, c.costValue(s), append(slices.Clip(labels), "USD")...)
+	if rates == nil {
+		return
+	}
+	for _, code := range c.convertCurrencies {
+		if rate, ok := rates.Rates[code]; ok {
+			sendGauge(ch, desc, c.convertedCostValue(s, rate), append(slices.Clip(labels), code)...)
+		}
+	}
+}
+
+// Stages of a scrape timed by cloudcost_exporter_stage_duration_seconds, in
+// addition to client.StageFetch and client.StageDecode.
+const (
+	stageAggregate    = "aggregate"
+	stageEmit         = "emit"
+	stageExchangeRate = "exchange_rate"
+)
+
+// observeStage records the duration of a stage.
+func (c *CloudCostCollector) observeStage(stage string, d time.Duration) {
+	c.stageDuration.WithLabelValues(stage).Observe(d.Seconds())
+}
+
+// emitCostTotal sends the series of the cost metric of the cost type
+// types.CostTypes[i] and, with currency conversion, its conversions into
+// the currencies rates are known for.
+func (c *CloudCostCollector) emitCostTotal(ch chan<- prometheus.Metric, labels []string, i int, s sum, rates *types.ExchangeRateResponse) {
+	if len(c.convertCurrencies) == 0 || c.perCostType {
+		c.costTotal[0].send(ch, prometheus.GaugeValue, i, c.costValue(s), labels)
+	} else {
+		c.costTotal[0].send(ch, prometheus.GaugeValue, i, c.costValue(s), append(slices.Clip(labels), "USD"))
+	}
+	if rates == nil {
+		return
+	}
+	for j, code := range c.convertCurrencies {
+		rate, ok := rates.Rates[code]
+		if !ok {
+			continue
+		}
+		values := labels
+		if !c.perCostType {
+			values = append(slices.Clip(labels), code)
 		}
+		c.costTotal[j+1].send(ch, prometheus.GaugeValue, i, c.convertedCostValue(s, rate), values)
 	}
 }
 
-func (c *CloudCostCollector) emitCost(ch chan<- prometheus.Metric, labels []string, costType string, value float64) {
-	// Labels order: provider_id, account_id, service, category, region, availability_zone, owner, environment, cluster
-	// Metric expects: provider_id, account_id, service, category, cost_type, region, availability_zone, owner, environment, cluster
+// withCostType returns the label values of a cost metric series.
+func withCostType(labels []string, costType string) []string {
+	// Labels order: provider_id, account_id, service, category, region, availability_zone, owner, environment, cluster[, provider][, provider labels...][, resource labels...][, source][, window_start]
+	// Metric expects: provider_id, account_id, service, category, cost_type, region, availability_zone, owner, environment, cluster[, provider][, provider labels...][, resource labels...][, source][, window_start]
 	// We need to insert cost_type after category (index 4)
 	fullLabels := make([]string, 0, len(labels)+1)
 	fullLabels = append(fullLabels, labels[:4]...) // provider_id, account_id, service, category
-	fullLabels = append(fullLabels, costType)       // cost_type
+	fullLabels = append(fullLabels, costType)      // cost_type
 	fullLabels = append(fullLabels, labels[4:]...) // region, owner, environment, cluster
-	ch <- prometheus.MustNewConstMetric(
-		c.costTotal,
-		prometheus.GaugeValue,
-		value,
-		fullLabels...,
-	)
+	return fullLabels
 }
 
-type aggregatedCost struct {
-	listCost         float64
-	netCost          float64
-	amortizedNetCost float64
-	invoicedCost     float64
-	amortizedCost    float64
-	kubePercent      float64
+// sendGauge sends a gauge, dropping it with a warning instead of panicking
+// if the label values are rejected.
+func sendGauge(ch chan<- prometheus.Metric, desc *prometheus.Desc, value float64, labels ...string) {
+	sendConst(ch, desc, prometheus.GaugeValue, value, labels...)
 }
 
-func (c *CloudCostCollector) emitExchangeRates(ch chan<- prometheus.Metric) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+// sendCounter sends a counter like sendGauge.
+func sendCounter(ch chan<- prometheus.Metric, desc *prometheus.Desc, value float64, labels ...string) {
+	sendConst(ch, desc, prometheus.CounterValue, value, labels...)
+}
 
-	// Fetch exchange rates for configured currency symbols
-	if len(c.currencySymbols) == 0 {
+func sendConst(ch chan<- prometheus.Metric, desc *prometheus.Desc, valueType prometheus.ValueType, value float64, labels ...string) {
+	m, err := prometheus.NewConstMetric(desc, valueType, value, labels...)
+	if err != nil {
+		slog.Warn("dropping invalid metric", "error", err)
 		return
 	}
-	rates, err := c.client.FetchExchangeRates(ctx, "USD", c.currencySymbols)
+	ch <- m
+}
+
+// labelValue replaces invalid UTF-8, which Prometheus rejects, in a label
+// value taken from OpenCost data. It is applied before aggregation so
+// sanitized values cannot produce duplicate series.
+func labelValue(s string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+	return strings.ToValidUTF8(s, "\uFFFD")
+}
+
+// costValue returns the value of a summed cost after applying the cost
+// precision and the clamp negative cost policy.
+func (c *CloudCostCollector) costValue(s sum) float64 {
+	return c.convertedCostValue(s, 1)
+}
+
+// convertedCostValue returns the cost value of s converted at the exchange
+// rate, rounded after the conversion.
+func (c *CloudCostCollector) convertedCostValue(s sum, rate float64) float64 {
+	value := c.round(s.value() * rate)
+	if c.negativeCosts == NegativeCostsClamp {
+		value = max(value, 0)
+	}
+	return value
+}
+
+// round rounds v to the cost precision.
+func (c *CloudCostCollector) round(v float64) float64 {
+	if c.costPrecision < 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+		return v
+	}
+	scale := math.Pow10(c.costPrecision)
+	r := math.Round(v*scale) / scale
+	if math.IsInf(r, 0) {
+		// v*scale overflowed; such values have no fractional digits anyway.
+		return v
+	}
+	if r == 0 {
+		return 0 // not -0
+	}
+	return r
+}
+
+// sum adds floats with Neumaier's compensated summation, so that the sum of
+// many cost items does not accumulate rounding errors.
+type sum struct {
+	total        float64
+	compensation float64
+}
+
+func (s *sum) add(v float64) {
+	t := s.total + v
+	if math.Abs(s.total) >= math.Abs(v) {
+		s.compensation += (s.total - t) + v
+	} else {
+		s.compensation += (v - t) + s.total
+	}
+	s.total = t
+}
+
+// merge adds the sum o, keeping its compensation.
+func (s *sum) merge(o sum) {
+	s.add(o.total)
+	s.add(o.compensation)
+}
+
+func (s sum) value() float64 {
+	return s.total + s.compensation
+}
+
+func (s sum) div(d float64) sum {
+	return sum{total: s.total / d, compensation: s.compensation / d}
+}
+
+// extendWindow returns the bounds of w, which may be zero, and other.
+func extendWindow(w, other types.Window) types.Window {
+	if w.Start.IsZero() || other.Start.Before(w.Start) {
+		w.Start = other.Start
+	}
+	if other.End.After(w.End) {
+		w.End = other.End
+	}
+	return w
+}
+
+// latestDailySet returns the index of the set covering the most recent day
+// that ended by now, or -1 if data has no daily sets. Later days are still
+// accumulating costs.
+func latestDailySet(data *types.CloudCostResponse, now time.Time) int {
+	latest := -1
+	var end time.Time
+	for i, set := range data.Data.Sets {
+		w := set.Bounds()
+		if w.Validate() != nil || w.End.Sub(w.Start) != 24*time.Hour || w.End.After(now) {
+			continue
+		}
+		if latest < 0 || w.End.After(end) {
+			latest, end = i, w.End
+		}
+	}
+	return latest
+}
+
+type aggregatedCost struct {
+	costs       [5]sum         // indexed like types.CostTypes
+	credits     [5]sum         // negative costs as positive amounts, when split
+	kubePercent [5]kubePercent // indexed like types.CostTypes
+}
+
+// add adds the costs of item. With split, negative costs are added to the
+// credits instead.
+func (a *aggregatedCost) add(item types.CloudCostItem, split bool) {
+	for i, costType := range types.CostTypes {
+		v, _ := item.CostByType(costType)
+		if split && v.Cost < 0 {
+			a.credits[i].add(-v.Cost)
+		} else {
+			a.costs[i].add(v.Cost)
+		}
+		a.kubePercent[i].add(v)
+	}
+}
+
+// merge adds the costs of o to those of a.
+func (a *aggregatedCost) merge(o *aggregatedCost) {
+	for i := range a.costs {
+		a.costs[i].merge(o.costs[i])
+		a.credits[i].merge(o.credits[i])
+		a.kubePercent[i].merge(o.kubePercent[i])
+	}
+}
+
+// kubePercent averages the Kubernetes percent of the items of a series,
+// weighted by their cost, so that an item of a few cents does not outweigh
+// one of thousands. Weights are absolute costs, which keeps the average
+// within the range of the items' percents when credits are mixed in.
+type kubePercent struct {
+	weighted sum // sum of |cost| * percent
+	weight   sum // sum of |cost|
+	percents sum // sum of percent, for items without cost
+	n        int
+}
+
+func (k *kubePercent) add(v types.CostValue) {
+	w := math.Abs(v.Cost)
+	k.weighted.add(w * v.KubernetesPercent)
+	k.weight.add(w)
+	k.percents.add(v.KubernetesPercent)
+	k.n++
+}
+
+func (k *kubePercent) merge(o kubePercent) {
+	k.weighted.merge(o.weighted)
+	k.weight.merge(o.weight)
+	k.percents.merge(o.percents)
+	k.n += o.n
+}
+
+// value returns the cost-weighted average, or the plain average if the
+// items have no cost.
+func (k kubePercent) value() float64 {
+	if w := k.weight.value(); w > 0 {
+		return k.weighted.value() / w
+	}
+	if k.n == 0 {
+		return 0
+	}
+	return k.percents.value() / float64(k.n)
+}
+
+// fetchExchangeRates fetches the exchange rates from USD of the currency
+// symbols and of the currencies costs are converted into. Shards other than
+// the first only need the latter. It returns nil if there are none, the
+// source does not fetch exchange rates or the fetch fails.
+func (c *CloudCostCollector) fetchExchangeRates(ctx context.Context) *types.ExchangeRateResponse {
+	source, ok := c.source.(exchangeRateSource)
+	if !ok {
+		return nil
+	}
+	var symbols []string
+	if c.shard.Index == 0 {
+		symbols = slices.Clone(c.symbols())
+	}
+	for _, code := range c.convertCurrencies {
+		if !slices.Contains(symbols, code) {
+			symbols = append(symbols, code)
+		}
+	}
+	if len(symbols) == 0 {
+		return nil
+	}
+
+	ctx, span := tracer.Start(ctx, "exchangeRates")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	start := time.Now()
+	defer func() { c.observeStage(stageExchangeRate, time.Since(start)) }()
+	rates, err := source.FetchExchangeRates(ctx, "USD", symbols)
+	var throttled *client.ThrottledError
+	if errors.As(err, &throttled) {
+		slog.Warn("exchange rate provider is throttling requests, skipping exchange rates", "until", throttled.Until)
+		return nil
+	}
 	if err != nil {
 		slog.Error("failed to fetch exchange rates", "error", err)
-		return
+		return nil
 	}
+	return rates
+}
 
-	// Emit a metric for each currency rate
+// emitExchangeRates sends the exchange rates of the currency symbols.
+// Shards other than the first skip them to avoid duplicate series.
+func (c *CloudCostCollector) emitExchangeRates(ch chan<- prometheus.Metric, rates *types.ExchangeRateResponse) {
+	if rates == nil || c.shard.Index != 0 {
+		return
+	}
 	for currency, rate := range rates.Rates {
-		ch <- prometheus.MustNewConstMetric(
-			c.exchangeRate,
-			prometheus.GaugeValue,
-			rate,
-			rates.Base, currency,
-		)
+		if slices.Contains(c.symbols(), currency) {
+			sendGauge(ch, c.exchangeRate, rate, labelValue(rates.Base), labelValue(currency))
+		}
 	}
 }
@@ -1,22 +1,42 @@
 package collector
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"maps"
+	"math"
 	"net/http"
-	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 
 	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/cache"
 	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/client"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/cron"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/opencosttest"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/requestid"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
 )
 
 func TestCloudCostCollector_Describe(t *testing.T) {
 	c := newTestCollector(t, `{"code": 200, "data": {"sets": []}}`)
-	ch := make(chan *prometheus.Desc, 10)
+	ch := make(chan *prometheus.Desc, 30)
 
 	c.Describe(ch)
 	close(ch)
@@ -31,6 +51,62 @@ func TestCloudCostCollector_Describe(t *testing.T) {
 	}
 }
 
+func TestCloudCostCollector_Namespace(t *testing.T) {
+	item := opencosttest.Item("123", "AmazonEC2", "Compute", 10)
+	item.Properties.InvoiceEntityID = "999"
+	for _, naming := range MetricNamings {
+		t.Run(string(naming), func(t *testing.T) {
+			server := opencosttest.NewServer(opencosttest.WithResponse(opencosttest.Response(item)))
+			defer server.Close()
+			c := New(client.New(server.URL), cache.New(time.Hour, 6*time.Hour),
+				WithNamespace("acme"),
+				WithMetricNaming(naming),
+				WithKubePercentMetrics(true),
+				WithInvoiceEntityMetrics(true),
+				WithHourlyRateMetrics(true),
+				WithCumulativeMetrics(true),
+				WithNegativeCostPolicy(NegativeCostsSplit),
+			)
+			if got := c.CostMetricName(); got != "acme_cost_total" {
+				t.Errorf("CostMetricName() = %q, want acme_cost_total", got)
+			}
+
+			// The pedantic registry fails on metrics that were not described.
+			reg := prometheus.NewPedanticRegistry()
+			reg.MustRegister(c)
+			families, err := reg.Gather()
+			if err != nil {
+				t.Fatalf("Gather() error = %v", err)
+			}
+			var costs int
+			for _, mf := range families {
+				if strings.HasPrefix(mf.GetName(), DefaultNamespace+"_") {
+					t.Errorf("%s has the default namespace", mf.GetName())
+				}
+				if strings.HasPrefix(mf.GetName(), "acme_cost_") {
+					costs++
+				}
+			}
+			if costs == 0 {
+				t.Error("no cost metrics in the acme namespace")
+			}
+		})
+	}
+}
+
+func TestValidateNamespace(t *testing.T) {
+	for _, ns := range []string{"aws_cloud", "acme", "cloud:cost"} {
+		if err := ValidateNamespace(ns); err != nil {
+			t.Errorf("ValidateNamespace(%q) error = %v", ns, err)
+		}
+	}
+	for _, ns := range []string{"", "1cloud", "aws-cloud"} {
+		if err := ValidateNamespace(ns); err == nil {
+			t.Errorf("ValidateNamespace(%q) should fail", ns)
+		}
+	}
+}
+
 func TestCloudCostCollector_Collect_EmptyResponse(t *testing.T) {
 	c := newTestCollector(t, `{"code": 200, "data": {"sets": []}}`)
 
@@ -150,6 +226,996 @@ func TestCloudCostCollector_RegionLabel(t *testing.T) {
 	}
 }
 
+func TestParseLabelMappings(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"empty", "", map[string]string{}, false},
+		{"single", "owner=team", map[string]string{"owner": "team"}, false},
+		{"multiple with spaces", " owner = team , cluster=kubernetes_cluster", map[string]string{"owner": "team", "cluster": "kubernetes_cluster"}, false},
+		{"missing key", "owner=", nil, true},
+		{"missing separator", "owner", nil, true},
+		{"unmapped label", "service=app", nil, true},
+		{"label sources", "owner=tag:team,environment=k8s:env", map[string]string{"owner": "tag:team", "environment": "k8s:env"}, false},
+		{"source without name", "owner=tag:", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLabelMappings(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLabelMappings() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("ParseLabelMappings() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCloudCostCollector_LabelMappings(t *testing.T) {
+	item := opencosttest.Item("123", "AmazonEC2", "Compute", 1)
+	item.Properties.Labels = map[string]string{"team": "payments", "owner": "ignored"}
+	server := opencosttest.NewServer(opencosttest.WithResponse(opencosttest.Response(item)))
+	defer server.Close()
+
+	c := New(client.New(server.URL), cache.New(time.Hour, 6*time.Hour),
+		WithCurrencySymbols(nil),
+		WithLabelMappings(map[string]string{"owner": "team"}),
+	)
+	owner := func() string {
+		ch := make(chan prometheus.Metric, 100)
+		c.Collect(ch)
+		close(ch)
+		for m := range ch {
+			var pb dto.Metric
+			m.Write(&pb)
+			for _, l := range pb.GetLabel() {
+				if l.GetName() == "owner" {
+					return l.GetValue()
+				}
+			}
+		}
+		return ""
+	}
+
+	if got := owner(); got != "payments" {
+		t.Errorf("owner = %q, want %q", got, "payments")
+	}
+	c.SetLabelMappings(nil)
+	if got := owner(); got != "ignored" {
+		t.Errorf("owner after reset = %q, want %q", got, "ignored")
+	}
+
+	// The team label comes from Kubernetes, so it is no provider tag.
+	c.cache.Invalidate()
+	item.Properties.KubernetesLabels = map[string]string{"team": "payments"}
+	server.SetResponse(opencosttest.Response(item))
+	for mapping, want := range map[string]string{"k8s:team": "payments", "tag:team": "", "tag:owner": "ignored"} {
+		c.SetLabelMappings(map[string]string{"owner": mapping})
+		if got := owner(); got != want {
+			t.Errorf("owner with mapping %s = %q, want %q", mapping, got, want)
+		}
+	}
+}
+
+func TestCloudCostCollector_ClusterName(t *testing.T) {
+	own := opencosttest.Item("123", "AmazonEKS", "Compute", 1)
+	own.Properties.Labels = map[string]string{"cluster": "eks-other"}
+	// Items of federation sources without a cluster label get the source's.
+	federated := opencosttest.Item("123", "AWSLambda", "Compute", 1)
+	federated.Source = "us"
+	server := opencosttest.NewServer(opencosttest.WithResponse(opencosttest.Response(
+		opencosttest.Item("123", "AmazonEC2", "Compute", 1), own, federated,
+	)))
+	defer server.Close()
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(New(client.New(server.URL), cache.New(time.Hour, 6*time.Hour),
+		WithCurrencySymbols(nil),
+		WithClusterName("eks-main"),
+		WithSourceClusters(map[string]string{"us": "eks-us", "eu": "eks-eu"}),
+	))
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+
+	clusters := make(map[string]string) // service -> cluster of the cost metric
+	for _, mf := range families {
+		for _, m := range mf.GetMetric() {
+			var cluster, service string
+			for _, l := range m.GetLabel() {
+				switch l.GetName() {
+				case "cluster":
+					cluster = l.GetValue()
+				case "service":
+					service = l.GetValue()
+				}
+			}
+			if mf.GetName() == DefaultNamespace+"_cost_total" {
+				clusters[service] = cluster
+			} else if cluster != "eks-main" {
+				t.Errorf("%s: cluster = %q, want eks-main", mf.GetName(), cluster)
+			}
+		}
+	}
+	want := map[string]string{"AmazonEC2": "eks-main", "AmazonEKS": "eks-other", "AWSLambda": "eks-us"}
+	if !reflect.DeepEqual(clusters, want) {
+		t.Errorf("cost metric clusters = %v, want %v", clusters, want)
+	}
+}
+
+func TestParseProviderLabels(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{input: "", want: nil},
+		{input: " Azure ,azure", want: []string{"azure"}},
+		{input: "gcp,azure", want: []string{"gcp", "azure"}},
+		{input: "oracle", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseProviderLabels(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ParseProviderLabels(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseProviderLabels(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestCloudCostCollector_ProviderLabels(t *testing.T) {
+	vm := opencosttest.Item("0b1f6471-1bf0-4dda-aec3-111122223333", "Virtual Machines", "Compute", 5)
+	vm.Properties.Provider = "Azure"
+	vm.Properties.AccountName = "prod-subscription"
+	vm.Properties.ProviderID = "/subscriptions/0b1f6471-1bf0-4dda-aec3-111122223333/resourceGroups/Web-RG/providers/Microsoft.Compute/virtualMachines/web-1"
+	disk := opencosttest.Item("0b1f6471-1bf0-4dda-aec3-111122223333", "Storage", "Storage", 1)
+	disk.Properties.Provider = "Azure"
+	disk.Properties.ProviderID = "/subscriptions/0b1f6471-1bf0-4dda-aec3-111122223333/resourcegroups/web-rg/providers/Microsoft.Compute/disks/web-1-os"
+	ec2 := opencosttest.Item("123", "AmazonEC2", "Compute", 2)
+	server := opencosttest.NewServer(opencosttest.WithResponse(opencosttest.Response(vm, disk, ec2)))
+	defer server.Close()
+
+	c := New(client.New(server.URL), cache.New(time.Hour, 6*time.Hour),
+		WithCurrencySymbols(nil),
+		WithProviderLabels([]string{"azure"}),
+		WithSourceLabel(true),
+	)
+	if got, want := c.CostLabels()[len(costLabels):], []string{"subscription_id", "subscription_name", "resource_group", "source"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("extra cost labels = %v, want %v", got, want)
+	}
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(c)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	groups := make(map[string]string) // service -> resource group
+	for _, mf := range families {
+		if mf.GetName() != DefaultNamespace+"_cost_total" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			labels := make(map[string]string)
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			groups[labels["service"]] = labels["resource_group"]
+			if labels["service"] == "Virtual Machines" && labels["subscription_name"] != "prod-subscription" {
+				t.Errorf("subscription_name = %q, want prod-subscription", labels["subscription_name"])
+			}
+		}
+	}
+	want := map[string]string{"Virtual Machines": "web-rg", "Storage": "web-rg", "AmazonEC2": ""}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("resource groups = %v, want %v", groups, want)
+	}
+}
+
+func TestParseResourceLabels(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{"empty", "", nil, false},
+		{"list with spaces and duplicates", " project , tag:cost-unit,project", []string{"project", "tag:cost-unit"}, false},
+		{"built-in label", "owner", nil, true},
+		{"provider label", "resource-group", nil, true},
+		{"currency of converted costs", "tag:currency", nil, true},
+		{"window start of per-set costs", "window_start", nil, true},
+		{"ownership labels", "team", nil, true},
+		{"sanitized ownership label", "tag:cost-center", nil, true},
+		{"colliding names", "cost-center,cost.center", nil, true},
+		{"reserved name", "__name__", nil, true},
+		{"source without name", "k8s:", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseResourceLabels(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseResourceLabels() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseResourceLabels() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResourceLabelName(t *testing.T) {
+	tests := map[string]string{
+		"team":                       "team",
+		"CostCenter":                 "CostCenter",
+		"tag:cost-center":            "cost_center",
+		"k8s:app.kubernetes.io/name": "app_kubernetes_io_name",
+		"2fa":                        "_2fa",
+		"kostenstelle-ä":             "kostenstelle__",
+	}
+	for key, want := range tests {
+		if got := ResourceLabelName(key); got != want {
+			t.Errorf("ResourceLabelName(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestCloudCostCollector_ResourceLabels(t *testing.T) {
+	tagged := opencosttest.Item("123", "AmazonEC2", "Compute", 5)
+	tagged.Properties.Labels = map[string]string{"project": "payments", "cost-unit": "cc-42"}
+	untagged := opencosttest.Item("123", "AmazonS3", "Storage", 1)
+	server := opencosttest.NewServer(opencosttest.WithResponse(opencosttest.Response(tagged, untagged)))
+	defer server.Close()
+
+	keys, err := ParseResourceLabels("project,cost-unit")
+	if err != nil {
+		t.Fatalf("ParseResourceLabels() error = %v", err)
+	}
+	c := New(client.New(server.URL), cache.New(time.Hour, 6*time.Hour),
+		WithCurrencySymbols(nil),
+		WithResourceLabels(keys),
+		WithSourceLabel(true),
+	)
+	if got, want := c.CostLabels()[len(costLabels):], []string{"project", "cost_unit", "source"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("extra cost labels = %v, want %v", got, want)
+	}
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(c)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	teams := make(map[string]string) // service -> team/cost center
+	for _, mf := range families {
+		if mf.GetName() != DefaultNamespace+"_cost_total" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			labels := make(map[string]string)
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			teams[labels["service"]] = labels["project"] + "/" + labels["cost_unit"]
+		}
+	}
+	want := map[string]string{"AmazonEC2": "payments/cc-42", "AmazonS3": "/"}
+	if !reflect.DeepEqual(teams, want) {
+		t.Errorf("resource labels = %v, want %v", teams, want)
+	}
+}
+
+func TestCloudCostCollector_CloudProviderLabel(t *testing.T) {
+	// Items of all providers with the same properties otherwise.
+	var items []types.CloudCostItem
+	for i, provider := range []string{"AWS", "GCP", "Azure", ""} {
+		item := opencosttest.Item("123", "Compute", "Compute", float64(i+1))
+		item.Properties.Provider = provider
+		items = append(items, item)
+	}
+	server := opencosttest.NewServer(opencosttest.WithResponse(opencosttest.Response(items...)))
+	defer server.Close()
+
+	c := New(client.New(server.URL), cache.New(time.Hour, 6*time.Hour),
+		WithCurrencySymbols(nil),
+		WithCloudProviderLabel(true),
+		WithKubePercentMetrics(true),
+	)
+	if got, want := c.CostLabels()[len(costLabels):], []string{"provider"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("extra cost labels = %v, want %v", got, want)
+	}
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(c)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	costs := make(map[string]float64) // provider -> list cost
+	for _, mf := range families {
+		if mf.GetName() != DefaultNamespace+"_cost_total" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			labels := make(map[string]string)
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["cost_type"] == "list" {
+				costs[labels["provider"]] += m.GetGauge().GetValue()
+			}
+		}
+	}
+	want := map[string]float64{"aws": 1, "gcp": 2, "azure": 3, "": 4}
+	if !reflect.DeepEqual(costs, want) {
+		t.Errorf("list costs by provider = %v, want %v", costs, want)
+	}
+}
+
+func TestCloudCostCollector_ResourceIDLabels(t *testing.T) {
+	db := opencosttest.Item("123", "AmazonRDS", "Database", 1)
+	db.Properties.ProviderID = "arn:aws:rds:us-east-1:123:db:orders"
+	vm := opencosttest.Item("123", "AmazonEC2", "Compute", 2)
+	vm.Properties.ProviderID = "i-0abc"
+	other := opencosttest.Item("123", "AWSSupport", "Support", 4)
+	server := opencosttest.NewServer(opencosttest.WithResponse(opencosttest.Response(db, vm, other)))
+	defer server.Close()
+
+	c := New(client.New(server.URL), cache.New(time.Hour, 6*time.Hour),
+		WithCurrencySymbols(nil),
+		WithResourceIDLabels(true),
+	)
+	if got, want := c.CostLabels()[len(costLabels):], []string{"resource_type", "resource_name"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("extra cost labels = %v, want %v", got, want)
+	}
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(c)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	costs := make(map[string]float64) // resource_type/resource_name -> list cost
+	for _, mf := range families {
+		if mf.GetName() != DefaultNamespace+"_cost_total" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			labels := make(map[string]string)
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["cost_type"] == "list" {
+				costs[labels["resource_type"]+"/"+labels["resource_name"]] += m.GetGauge().GetValue()
+			}
+		}
+	}
+	want := map[string]float64{"db/orders": 1, "instance/i-0abc": 2, "/": 4}
+	if !reflect.DeepEqual(costs, want) {
+		t.Errorf("list costs by resource = %v, want %v", costs, want)
+	}
+}
+
+func TestParseNegativeCostPolicy(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    NegativeCostPolicy
+		wantErr bool
+	}{
+		{input: "", want: NegativeCostsPassThrough},
+		{input: " Clamp", want: NegativeCostsClamp},
+		{input: "split", want: NegativeCostsSplit},
+		{input: "drop", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseNegativeCostPolicy(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ParseNegativeCostPolicy(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("ParseNegativeCostPolicy(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestCloudCostCollector_NegativeCosts(t *testing.T) {
+	usage := opencosttest.Item("123", "AmazonEC2", "Compute", 3)
+	credit := opencosttest.Item("123", "AmazonEC2", "Compute", -4)
+	server := opencosttest.NewServer(opencosttest.WithResponse(opencosttest.Response(usage, credit)))
+	defer server.Close()
+
+	tests := []struct {
+		policy     NegativeCostPolicy
+		wantCost   float64
+		wantCredit float64 // 0 if no credit series is expected
+	}{
+		{NegativeCostsPassThrough, -1, 0},
+		{NegativeCostsClamp, 0, 0},
+		{NegativeCostsSplit, 3, 4},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			c := New(client.New(server.URL), cache.New(time.Hour, 6*time.Hour),
+				WithCurrencySymbols(nil),
+				WithNegativeCostPolicy(tt.policy),
+			)
+			reg := prometheus.NewPedanticRegistry()
+			reg.MustRegister(c)
+			families, err := reg.Gather()
+			if err != nil {
+				t.Fatalf("Gather() error = %v", err)
+			}
+			values := make(map[string][]float64)
+			for _, mf := range families {
+				for _, m := range mf.GetMetric() {
+					values[mf.GetName()] = append(values[mf.GetName()], m.GetGauge().GetValue())
+				}
+			}
+			if got := values[DefaultNamespace+"_cost_total"]; len(got) != len(types.CostTypes) || got[0] != tt.wantCost {
+				t.Errorf("cost = %v, want %v for each cost type", got, tt.wantCost)
+			}
+			got := values[DefaultNamespace+"_credit_total"]
+			if tt.wantCredit == 0 && len(got) > 0 {
+				t.Errorf("credit = %v, want none", got)
+			}
+			if tt.wantCredit != 0 && (len(got) != len(types.CostTypes) || got[0] != tt.wantCredit) {
+				t.Errorf("credit = %v, want %v for each cost type", got, tt.wantCredit)
+			}
+		})
+	}
+}
+
+func TestCloudCostCollector_MissingFields(t *testing.T) {
+	response := `{"code": 200, "data": {"sets": [{"cloudCosts": {
+		"ec2": {"properties": {"accountID": "123", "service": "AmazonEC2"}, "listCost": {"cost": 10}},
+		"no-account": {"properties": {"service": "AmazonS3"}, "listCost": {"cost": 2}},
+		"no-service": {"properties": {"accountID": "123", "service": " "}, "listCost": {"cost": 1}}
+	}}]}}`
+
+	tests := []struct {
+		policy MissingFieldPolicy
+		want   map[string]types.CloudCostProperties // item key -> account and service
+	}{
+		{MissingFieldsKeep, map[string]types.CloudCostProperties{
+			"ec2":        {AccountID: "123", Service: "AmazonEC2"},
+			"no-account": {Service: "AmazonS3"},
+			"no-service": {AccountID: "123", Service: " "},
+		}},
+		{MissingFieldsUnallocated, map[string]types.CloudCostProperties{
+			"ec2":        {AccountID: "123", Service: "AmazonEC2"},
+			"no-account": {AccountID: Unallocated, Service: "AmazonS3"},
+			"no-service": {AccountID: "123", Service: Unallocated},
+		}},
+		{MissingFieldsDrop, map[string]types.CloudCostProperties{
+			"ec2": {AccountID: "123", Service: "AmazonEC2"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			c := newTestCollectorWithOptions(t, response, WithMissingFieldPolicy(tt.policy))
+			data, ok := c.Data(context.Background())
+			if !ok {
+				t.Fatal("Data() returned no data")
+			}
+			got := make(map[string]types.CloudCostProperties)
+			for key, item := range data.Data.Sets[0].CloudCosts {
+				got[key] = types.CloudCostProperties{AccountID: item.Properties.AccountID, Service: item.Properties.Service}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("items = %v, want %v", got, tt.want)
+			}
+			for _, field := range []string{"account_id", "service"} {
+				if n := testutil.ToFloat64(c.missingFieldItems.WithLabelValues(field)); n != 1 {
+					t.Errorf("missing %s = %v, want 1", field, n)
+				}
+			}
+		})
+	}
+}
+
+func TestCloudCostCollector_Aggregate(t *testing.T) {
+	// Aggregated by service and category, one item leaving its properties
+	// to the key and none carrying an account ID.
+	response := `{"code": 200, "data": {"sets": [{"cloudCosts": {
+		"AmazonEC2/Compute": {"properties": {"service": "AmazonEC2", "category": "Compute"}, "listCost": {"cost": 10}},
+		"AmazonS3/Storage": {"properties": {}, "listCost": {"cost": 2}},
+		"/Network": {"properties": {"category": "Network"}, "listCost": {"cost": 1}}
+	}}]}}`
+
+	c := newTestCollectorWithOptions(t, response,
+		WithAggregate([]string{"service", "category"}),
+		WithMissingFieldPolicy(MissingFieldsUnallocated),
+	)
+	data, ok := c.Data(context.Background())
+	if !ok {
+		t.Fatal("Data() returned no data")
+	}
+	got := make(map[string]types.CloudCostProperties)
+	for key, item := range data.Data.Sets[0].CloudCosts {
+		got[key] = types.CloudCostProperties{AccountID: item.Properties.AccountID, Service: item.Properties.Service, Category: item.Properties.Category}
+	}
+	want := map[string]types.CloudCostProperties{
+		"AmazonEC2/Compute": {Service: "AmazonEC2", Category: "Compute"},
+		"AmazonS3/Storage":  {Service: "AmazonS3", Category: "Storage"},
+		"/Network":          {Service: Unallocated, Category: "Network"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("items = %v, want %v", got, want)
+	}
+	if n := testutil.ToFloat64(c.missingFieldItems.WithLabelValues("account_id")); n != 0 {
+		t.Errorf("missing account_id = %v, want 0 when not aggregated by", n)
+	}
+	if n := testutil.ToFloat64(c.missingFieldItems.WithLabelValues("service")); n != 1 {
+		t.Errorf("missing service = %v, want 1", n)
+	}
+}
+
+func TestCloudCostCollector_CostPrecision(t *testing.T) {
+	var items []types.CloudCostItem
+	for range 10 {
+		items = append(items, opencosttest.Item("123", "AmazonEC2", "Compute", 0.1))
+	}
+	items = append(items, opencosttest.Item("123", "AmazonS3", "Storage", 1.23456), opencosttest.Item("123", "AmazonSNS", "Other", -0.0001))
+	server := opencosttest.NewServer(opencosttest.WithResponse(opencosttest.Response(items...)))
+	defer server.Close()
+
+	tests := []struct {
+		precision int
+		want      map[string]float64 // service -> cost
+	}{
+		// Compensated summation yields exactly 1 for ten times 0.1.
+		{-1, map[string]float64{"AmazonEC2": 1, "AmazonS3": 1.23456, "AmazonSNS": -0.0001}},
+		{2, map[string]float64{"AmazonEC2": 1, "AmazonS3": 1.23, "AmazonSNS": 0}},
+	}
+	for _, tt := range tests {
+		c := New(client.New(server.URL), cache.New(time.Hour, 6*time.Hour),
+			WithCurrencySymbols(nil),
+			WithCostPrecision(tt.precision),
+		)
+		reg := prometheus.NewPedanticRegistry()
+		reg.MustRegister(c)
+		families, err := reg.Gather()
+		if err != nil {
+			t.Fatalf("Gather() error = %v", err)
+		}
+		got := make(map[string]float64)
+		for _, mf := range families {
+			if mf.GetName() != DefaultNamespace+"_cost_total" {
+				continue
+			}
+			for _, m := range mf.GetMetric() {
+				for _, l := range m.GetLabel() {
+					if l.GetName() == "service" {
+						got[l.GetValue()] = m.GetGauge().GetValue()
+					}
+				}
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("precision %d: costs = %v, want %v", tt.precision, got, tt.want)
+		}
+		if v := got["AmazonSNS"]; v == 0 && math.Signbit(v) {
+			t.Errorf("precision %d: rounded cost is -0", tt.precision)
+		}
+	}
+}
+
+func TestKubePercent(t *testing.T) {
+	tests := []struct {
+		name  string
+		items []types.CostValue
+		want  float64
+	}{
+		{"no items", nil, 0},
+		{"one item", []types.CostValue{{Cost: 5, KubernetesPercent: 0.4}}, 0.4},
+		{"weighted by cost", []types.CostValue{{Cost: 90, KubernetesPercent: 1}, {Cost: 10, KubernetesPercent: 0}}, 0.9},
+		{"credits weigh by amount", []types.CostValue{{Cost: 30, KubernetesPercent: 1}, {Cost: -10, KubernetesPercent: 0}}, 0.75},
+		{"no cost", []types.CostValue{{KubernetesPercent: 0.2}, {KubernetesPercent: 0.6}}, 0.4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var k kubePercent
+			for _, v := range tt.items {
+				k.add(v)
+			}
+			if got := k.value(); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("value() = %v, want %v", got, tt.want)
+			}
+
+			// Merging the halves aggregated concurrently gives the same.
+			var a, b kubePercent
+			for i, v := range tt.items {
+				if i%2 == 0 {
+					a.add(v)
+				} else {
+					b.add(v)
+				}
+			}
+			a.merge(b)
+			if got := a.value(); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("merged value() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCloudCostCollector_KubePercent(t *testing.T) {
+	// Three items fold into one series; the large one dominates regardless
+	// of its position.
+	item := func(cost, percent float64) types.CloudCostItem {
+		i := opencosttest.Item("123", "AmazonEC2", "Compute", cost)
+		i.AmortizedNetCost.KubernetesPercent = percent
+		i.ListCost.KubernetesPercent = 1 - percent
+		return i
+	}
+	server := opencosttest.NewServer(opencosttest.WithResponse(opencosttest.Response(
+		item(10, 0), item(980, 1), item(10, 0),
+	)))
+	defer server.Close()
+
+	c := New(client.New(server.URL), cache.New(time.Hour, 6*time.Hour), WithCurrencySymbols(nil), WithKubePercentMetrics(true))
+	want := `# HELP aws_cloud_cost_kubernetes_percent Percentage of cost attributed to Kubernetes
+# TYPE aws_cloud_cost_kubernetes_percent gauge
+aws_cloud_cost_kubernetes_percent{account_id="123",category="Compute",cost_type="amortized_net",provider_id="",region="",service="AmazonEC2"} 0.98
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want), DefaultNamespace+"_cost_kubernetes_percent"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCloudCostCollector_KubePercentAcrossZones(t *testing.T) {
+	// The cost series of the zones share one percent series.
+	item := func(zone string, cost, percent float64) types.CloudCostItem {
+		i := opencosttest.Item("123", "AmazonEC2", "Compute", cost)
+		i.Properties.AvailabilityZone = zone
+		i.AmortizedNetCost.KubernetesPercent = percent
+		return i
+	}
+	server := opencosttest.NewServer(opencosttest.WithResponse(opencosttest.Response(
+		item("us-east-1a", 10, 0), item("us-east-1b", 30, 1),
+	)))
+	defer server.Close()
+
+	c := New(client.New(server.URL), cache.New(time.Hour, 6*time.Hour), WithCurrencySymbols(nil), WithKubePercentMetrics(true))
+	want := `# HELP aws_cloud_cost_kubernetes_percent Percentage of cost attributed to Kubernetes
+# TYPE aws_cloud_cost_kubernetes_percent gauge
+aws_cloud_cost_kubernetes_percent{account_id="123",category="Compute",cost_type="amortized_net",provider_id="",region="",service="AmazonEC2"} 0.75
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want), DefaultNamespace+"_cost_kubernetes_percent"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCloudCostCollector_InvoiceEntityMetrics(t *testing.T) {
+	payer := func(item types.CloudCostItem) types.CloudCostItem {
+		item.Properties.InvoiceEntityID = "453316427866"
+		item.Properties.InvoiceEntityName = "payer"
+		return item
+	}
+	server := opencosttest.NewServer(opencosttest.WithResponse(opencosttest.Response(
+		payer(opencosttest.Item("123", "AmazonEC2", "Compute", 10)),
+		payer(opencosttest.Item("456", "AmazonS3", "Storage", 2.5)),
+		opencosttest.Item("789", "AmazonRDS", "Database", 4),
+	)))
+	defer server.Close()
+
+	c := New(client.New(server.URL), cache.New(time.Hour, 6*time.Hour),
+		WithCurrencySymbols(nil),
+		WithInvoiceEntityMetrics(true),
+	)
+	want := `
+# HELP aws_cloud_invoice_entity_cost_total AWS cloud cost in USD by invoice entity
+# TYPE aws_cloud_invoice_entity_cost_total gauge
+aws_cloud_invoice_entity_cost_total{cost_type="amortized",invoice_entity_id="",invoice_entity_name=""} 4
+aws_cloud_invoice_entity_cost_total{cost_type="amortized",invoice_entity_id="453316427866",invoice_entity_name="payer"} 12.5
+aws_cloud_invoice_entity_cost_total{cost_type="amortized_net",invoice_entity_id="",invoice_entity_name=""} 4
+aws_cloud_invoice_entity_cost_total{cost_type="amortized_net",invoice_entity_id="453316427866",invoice_entity_name="payer"} 12.5
+aws_cloud_invoice_entity_cost_total{cost_type="invoiced",invoice_entity_id="",invoice_entity_name=""} 4
+aws_cloud_invoice_entity_cost_total{cost_type="invoiced",invoice_entity_id="453316427866",invoice_entity_name="payer"} 12.5
+aws_cloud_invoice_entity_cost_total{cost_type="list",invoice_entity_id="",invoice_entity_name=""} 4
+aws_cloud_invoice_entity_cost_total{cost_type="list",invoice_entity_id="453316427866",invoice_entity_name="payer"} 12.5
+aws_cloud_invoice_entity_cost_total{cost_type="net",invoice_entity_id="",invoice_entity_name=""} 4
+aws_cloud_invoice_entity_cost_total{cost_type="net",invoice_entity_id="453316427866",invoice_entity_name="payer"} 12.5
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want), DefaultNamespace+"_invoice_entity_cost_total"); err != nil {
+		t.Error(err)
+	}
+
+	// Disabled by default.
+	c = New(client.New(server.URL), cache.New(time.Hour, 6*time.Hour), WithCurrencySymbols(nil))
+	if n := testutil.CollectAndCount(c, DefaultNamespace+"_invoice_entity_cost_total"); n != 0 {
+		t.Errorf("invoice entity series = %d, want 0 by default", n)
+	}
+}
+
+func TestCloudCostCollector_AccountMetrics(t *testing.T) {
+	server := opencosttest.NewServer(opencosttest.WithResponse(opencosttest.Response(
+		opencosttest.Item("123", "AmazonEC2", "Compute", 10),
+		opencosttest.Item("123", "AmazonS3", "Storage", 2.5),
+		opencosttest.Item("456", "AmazonRDS", "Database", 4),
+		opencosttest.Item("789", "AWSSupport", "Support", 1),
+	)))
+	defer server.Close()
+
+	service, err := ParseMatcher("!AWSSupport")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := New(client.New(server.URL), cache.New(time.Hour, 6*time.Hour),
+		WithCurrencySymbols(nil),
+		WithAccountMetrics(true),
+		WithFilter(Filter{Service: service}),
+	)
+	want := `
+# HELP aws_cloud_account_cost_total AWS cloud cost in USD by account, summed across services
+# TYPE aws_cloud_account_cost_total gauge
+aws_cloud_account_cost_total{account_id="123",cost_type="amortized"} 12.5
+aws_cloud_account_cost_total{account_id="123",cost_type="amortized_net"} 12.5
+aws_cloud_account_cost_total{account_id="123",cost_type="invoiced"} 12.5
+aws_cloud_account_cost_total{account_id="123",cost_type="list"} 12.5
+aws_cloud_account_cost_total{account_id="123",cost_type="net"} 12.5
+aws_cloud_account_cost_total{account_id="456",cost_type="amortized"} 4
+aws_cloud_account_cost_total{account_id="456",cost_type="amortized_net"} 4
+aws_cloud_account_cost_total{account_id="456",cost_type="invoiced"} 4
+aws_cloud_account_cost_total{account_id="456",cost_type="list"} 4
+aws_cloud_account_cost_total{account_id="456",cost_type="net"} 4
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want), DefaultNamespace+"_account_cost_total"); err != nil {
+		t.Error(err)
+	}
+
+	// Disabled by default.
+	c = New(client.New(server.URL), cache.New(time.Hour, 6*time.Hour), WithCurrencySymbols(nil))
+	if n := testutil.CollectAndCount(c, DefaultNamespace+"_account_cost_total"); n != 0 {
+		t.Errorf("account series = %d, want 0 by default", n)
+	}
+}
+
+func TestCloudCostCollector_RollupsConversionAndSets(t *testing.T) {
+	day1 := opencosttest.Response(opencosttest.Item("123", "AmazonEC2", "Compute", 10))
+	day2 := opencosttest.Response(opencosttest.Item("123", "AmazonS3", "Storage", 2))
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	day1.Data.Sets[0].Window = types.Window{Start: start, End: start.AddDate(0, 0, 1)}
+	day2.Data.Sets[0].Window = types.Window{Start: start.AddDate(0, 0, 1), End: start.AddDate(0, 0, 2)}
+	data := &types.CloudCostResponse{Code: http.StatusOK, Data: types.CloudCostData{Sets: append(day1.Data.Sets, day2.Data.Sets...)}}
+	rates := &types.ExchangeRateResponse{Base: "USD", Rates: map[string]float64{"EUR": 0.5}}
+
+	c := New(client.New("http://unused"), cache.New(time.Hour, 6*time.Hour),
+		WithCurrencySymbols(nil),
+		WithAccountMetrics(true),
+		WithInvoiceEntityMetrics(true),
+		WithAccumulate(false),
+		WithCurrencyConversion([]string{"EUR"}),
+	)
+	ch := make(chan prometheus.Metric, 200)
+	c.emitCostMetrics(context.Background(), ch, data, rates)
+	close(ch)
+
+	got := make(map[string]float64) // rollup window_start/currency -> cost
+	for m := range ch {
+		var rollup string
+		desc := m.Desc().String()
+		switch {
+		case strings.Contains(desc, DefaultNamespace+"_account_cost_total"):
+			rollup = "account"
+		case strings.Contains(desc, DefaultNamespace+"_invoice_entity_cost_total"):
+			rollup = "entity"
+		default:
+			continue
+		}
+		if !strings.Contains(desc, "converted into the currency of the currency label") {
+			t.Errorf("help of %s does not describe the currency label", desc)
+		}
+		var pb dto.Metric
+		m.Write(&pb)
+		labels := make(map[string]string)
+		for _, l := range pb.GetLabel() {
+			labels[l.GetName()] = l.GetValue()
+		}
+		if labels["cost_type"] == "amortized_net" {
+			got[rollup+" "+labels["window_start"]+"/"+labels["currency"]] = pb.GetGauge().GetValue()
+		}
+	}
+	want := map[string]float64{
+		"account 2026-01-01T00:00:00Z/USD": 10,
+		"account 2026-01-01T00:00:00Z/EUR": 5,
+		"account 2026-01-02T00:00:00Z/USD": 2,
+		"account 2026-01-02T00:00:00Z/EUR": 1,
+		"entity 2026-01-01T00:00:00Z/USD":  10,
+		"entity 2026-01-01T00:00:00Z/EUR":  5,
+		"entity 2026-01-02T00:00:00Z/USD":  2,
+		"entity 2026-01-02T00:00:00Z/EUR":  1,
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("rollup costs = %v, want %v", got, want)
+	}
+}
+
+func TestLatestDailySet(t *testing.T) {
+	day := func(d int, hours time.Duration) types.CloudCostSet {
+		start := time.Date(2026, 1, d, 0, 0, 0, 0, time.UTC)
+		return types.CloudCostSet{Window: types.Window{Start: start, End: start.Add(hours)}}
+	}
+	now := time.Date(2026, 1, 8, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		sets []types.CloudCostSet
+		want int
+	}{
+		{"no sets", nil, -1},
+		{"latest complete day", []types.CloudCostSet{day(6, 24*time.Hour), day(7, 24*time.Hour), day(5, 24*time.Hour)}, 1},
+		{"today is incomplete", []types.CloudCostSet{day(7, 24*time.Hour), day(8, 24*time.Hour)}, 0},
+		{"accumulated window", []types.CloudCostSet{day(1, 7*24*time.Hour)}, -1},
+		{"unknown window", []types.CloudCostSet{{}}, -1},
+	}
+	for _, tt := range tests {
+		data := &types.CloudCostResponse{Data: types.CloudCostData{Sets: tt.sets}}
+		if got := latestDailySet(data, now); got != tt.want {
+			t.Errorf("%s: latestDailySet() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCloudCostCollector_Degraded(t *testing.T) {
+	first := opencosttest.Item("123", "AmazonEC2", "Compute", 2)
+	first.Properties.ProviderID = "i-1"
+	second := opencosttest.Item("123", "AmazonEC2", "Compute", 3)
+	second.Properties.ProviderID = "i-2"
+	server := opencosttest.NewServer(opencosttest.WithResponse(opencosttest.Response(first, second)))
+	defer server.Close()
+
+	c := New(client.New(server.URL), cache.New(time.Hour, 6*time.Hour), WithCurrencySymbols(nil))
+	if n := testutil.CollectAndCount(c, DefaultNamespace+"_cost_total"); n != 2*len(types.CostTypes) {
+		t.Errorf("cost series = %d, want %d", n, 2*len(types.CostTypes))
+	}
+
+	// The items are summed into one series per cost type.
+	c.SetDegraded(true)
+	want := `
+# HELP aws_cloud_cost_total AWS cloud cost in USD
+# TYPE aws_cloud_cost_total gauge
+aws_cloud_cost_total{account_id="123",availability_zone="",category="Compute",cluster="",cost_type="amortized",environment="",owner="",provider_id="",region="",service="AmazonEC2"} 5
+aws_cloud_cost_total{account_id="123",availability_zone="",category="Compute",cluster="",cost_type="amortized_net",environment="",owner="",provider_id="",region="",service="AmazonEC2"} 5
+aws_cloud_cost_total{account_id="123",availability_zone="",category="Compute",cluster="",cost_type="invoiced",environment="",owner="",provider_id="",region="",service="AmazonEC2"} 5
+aws_cloud_cost_total{account_id="123",availability_zone="",category="Compute",cluster="",cost_type="list",environment="",owner="",provider_id="",region="",service="AmazonEC2"} 5
+aws_cloud_cost_total{account_id="123",availability_zone="",category="Compute",cluster="",cost_type="net",environment="",owner="",provider_id="",region="",service="AmazonEC2"} 5
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want), DefaultNamespace+"_cost_total"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCloudCostCollector_ManySets(t *testing.T) {
+	// Daily sets over 30 days are aggregated concurrently and merged.
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	resp := &types.CloudCostResponse{Code: 200}
+	for day := range 30 {
+		w := types.Window{Start: start.AddDate(0, 0, day), End: start.AddDate(0, 0, day+1)}
+		set := types.CloudCostSet{Window: w, CloudCosts: make(map[string]types.CloudCostItem)}
+		for i := range 10 {
+			item := opencosttest.Item("123", "AmazonEC2", "Compute", 0.1)
+			item.Window = w
+			set.CloudCosts[fmt.Sprintf("item-%d", i)] = item
+		}
+		resp.Data.Sets = append(resp.Data.Sets, set)
+	}
+	server := opencosttest.NewServer(opencosttest.WithResponse(resp))
+	defer server.Close()
+
+	c := New(client.New(server.URL), cache.New(time.Hour, 6*time.Hour), WithCurrencySymbols(nil))
+	want := fmt.Sprintf(`
+# HELP aws_cloud_cost_total AWS cloud cost in USD
+# TYPE aws_cloud_cost_total gauge
+aws_cloud_cost_total{account_id="123",availability_zone="",category="Compute",cluster="",cost_type="amortized",environment="",owner="",provider_id="",region="",service="AmazonEC2"} 30
+aws_cloud_cost_total{account_id="123",availability_zone="",category="Compute",cluster="",cost_type="amortized_net",environment="",owner="",provider_id="",region="",service="AmazonEC2"} 30
+aws_cloud_cost_total{account_id="123",availability_zone="",category="Compute",cluster="",cost_type="invoiced",environment="",owner="",provider_id="",region="",service="AmazonEC2"} 30
+aws_cloud_cost_total{account_id="123",availability_zone="",category="Compute",cluster="",cost_type="list",environment="",owner="",provider_id="",region="",service="AmazonEC2"} 30
+aws_cloud_cost_total{account_id="123",availability_zone="",category="Compute",cluster="",cost_type="net",environment="",owner="",provider_id="",region="",service="AmazonEC2"} 30
+# HELP aws_cloud_cost_window_end_timestamp_seconds Unix timestamp of the latest end of the cost item windows
+# TYPE aws_cloud_cost_window_end_timestamp_seconds gauge
+aws_cloud_cost_window_end_timestamp_seconds %d
+# HELP aws_cloud_cost_window_start_timestamp_seconds Unix timestamp of the earliest start of the cost item windows
+# TYPE aws_cloud_cost_window_start_timestamp_seconds gauge
+aws_cloud_cost_window_start_timestamp_seconds %d
+`, start.AddDate(0, 0, 30).Unix(), start.Unix())
+	names := []string{DefaultNamespace + "_cost_total", DefaultNamespace + "_cost_window_start_timestamp_seconds", DefaultNamespace + "_cost_window_end_timestamp_seconds"}
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want), names...); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCloudCostCollector_GCPProviderLabels(t *testing.T) {
+	gce := opencosttest.Item("acme-prod", "Compute Engine", "Compute", 5)
+	gce.Properties.Provider = "GCP"
+	gce.Properties.AccountName = "Acme Production"
+	gce.Properties.InvoiceEntityID = "01A2B3-C4D5E6-F7G8H9"
+	server := opencosttest.NewServer(opencosttest.WithResponse(opencosttest.Response(gce)))
+	defer server.Close()
+
+	c := New(client.New(server.URL), cache.New(time.Hour, 6*time.Hour),
+		WithCurrencySymbols(nil),
+		WithProviderLabels([]string{"azure", "gcp"}),
+	)
+	ch := make(chan prometheus.Metric, 100)
+	c.Collect(ch)
+	close(ch)
+
+	want := map[string]string{"project_id": "acme-prod", "project_name": "Acme Production", "billing_account_id": "01A2B3-C4D5E6-F7G8H9", "subscription_id": ""}
+	for m := range ch {
+		var pb dto.Metric
+		m.Write(&pb)
+		labels := make(map[string]string)
+		for _, l := range pb.GetLabel() {
+			labels[l.GetName()] = l.GetValue()
+		}
+		if labels["cost_type"] == "" {
+			continue
+		}
+		for name, value := range want {
+			if got, ok := labels[name]; !ok || got != value {
+				t.Errorf("%s = %q, want %q", name, got, value)
+			}
+		}
+		return
+	}
+	t.Error("no cost metric collected")
+}
+
+func TestCloudCostCollector_CurrencyConversion(t *testing.T) {
+	c := New(client.New("http://unused"), cache.New(time.Hour, 6*time.Hour),
+		WithCurrencySymbols(nil),
+		WithCurrencyConversion([]string{"EUR", "JPY"}),
+		WithCostPrecision(2),
+	)
+	if got := c.CostLabels(); got[len(got)-1] != "currency" {
+		t.Errorf("cost labels = %v, want currency last", got)
+	}
+
+	data := opencosttest.Response(opencosttest.Item("123", "AmazonEC2", "Compute", 10.05))
+	rates := &types.ExchangeRateResponse{Base: "USD", Rates: map[string]float64{"EUR": 0.9, "CNY": 7.1}}
+	for _, tt := range []struct {
+		name  string
+		rates *types.ExchangeRateResponse
+		want  map[string]float64 // currency -> cost
+	}{
+		// JPY has no rate, and CNY is not converted into.
+		{"rates", rates, map[string]float64{"USD": 10.05, "EUR": 9.05}},
+		{"no rates", nil, map[string]float64{"USD": 10.05}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ch := make(chan prometheus.Metric, 100)
+			c.emitCostMetrics(context.Background(), ch, data, tt.rates)
+			close(ch)
+
+			got := make(map[string]float64)
+			for m := range ch {
+				if !strings.Contains(m.Desc().String(), DefaultNamespace+"_cost_total") {
+					continue
+				}
+				var pb dto.Metric
+				m.Write(&pb)
+				labels := make(map[string]string)
+				for _, l := range pb.GetLabel() {
+					labels[l.GetName()] = l.GetValue()
+				}
+				if labels["cost_type"] == "amortized_net" {
+					got[labels["currency"]] = pb.GetGauge().GetValue()
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("costs by currency = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestCloudCostCollector_CacheHit(t *testing.T) {
 	mockResponse := `{"code": 200, "data": {"sets": []}}`
 	c := newTestCollector(t, mockResponse)
@@ -171,6 +1237,46 @@ func TestCloudCostCollector_CacheHit(t *testing.T) {
 	}
 }
 
+func TestCloudCostCollector_SharedFetch(t *testing.T) {
+	server := opencosttest.NewServer(
+		opencosttest.WithResponse(opencosttest.Response(opencosttest.Item("123", "AmazonEC2", "Compute", 1))),
+		opencosttest.WithLatency(100*time.Millisecond),
+	)
+	defer server.Close()
+	c := New(client.New(server.URL), cache.New(time.Hour, 6*time.Hour), WithCurrencySymbols(nil))
+
+	// Concurrent scrapes of the empty cache and a refresh share one fetch.
+	var wg sync.WaitGroup
+	var fetched atomic.Int32
+	for range 5 {
+		wg.Go(func() {
+			if _, ok := c.Data(context.Background()); ok {
+				fetched.Add(1)
+			}
+		})
+	}
+	wg.Go(c.refreshCache)
+	wg.Wait()
+
+	if n := server.CloudCostRequests(); n != 1 {
+		t.Errorf("OpenCost requests = %d, want 1", n)
+	}
+	if n := fetched.Load(); n != 5 {
+		t.Errorf("%d of 5 callers got data", n)
+	}
+
+	// A waiting caller gives up with its context.
+	server.SetLatency(time.Second)
+	c.cache.Invalidate()
+	go c.refreshCache()
+	time.Sleep(10 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, ok := c.Data(ctx); ok {
+		t.Error("Data() of a canceled caller returned data")
+	}
+}
+
 func TestCloudCostCollector_SelfMetrics(t *testing.T) {
 	c := newTestCollector(t, `{"code": 200, "data": {"sets": []}}`)
 
@@ -193,10 +1299,7 @@ func newTestCollector(t *testing.T, mockResponse string) *CloudCostCollector {
 func newTestCollectorWithOptions(t *testing.T, mockResponse string, opts ...Option) *CloudCostCollector {
 	t.Helper()
 
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.Write([]byte(mockResponse))
-	}))
+	server := opencosttest.NewServer(opencosttest.WithRawResponse(mockResponse))
 	t.Cleanup(server.Close)
 
 	cl := client.New(server.URL)
@@ -210,7 +1313,7 @@ func TestCloudCostCollector_ExchangeRateMetrics(t *testing.T) {
 	c := newTestCollector(t, `{"code": 200, "data": {"sets": []}}`)
 
 	// Check that the exchangeRate metric is defined
-	ch := make(chan *prometheus.Desc, 10)
+	ch := make(chan *prometheus.Desc, 30)
 	c.Describe(ch)
 	close(ch)
 
@@ -226,3 +1329,516 @@ func TestCloudCostCollector_ExchangeRateMetrics(t *testing.T) {
 		t.Error("expected currency_exchange_rate metric to be described")
 	}
 }
+
+func TestCloudCostCollector_CurrencyInfo(t *testing.T) {
+	const want = `
+# HELP currency_info ISO 4217 currency of the costs and exchange rates, with its symbol and minor unit digits
+# TYPE currency_info gauge
+currency_info{code="EUR",decimals="2",symbol="€"} 1
+currency_info{code="JPY",decimals="0",symbol="¥"} 1
+currency_info{code="USD",decimals="2",symbol="$"} 1
+`
+	c := newTestCollectorWithOptions(t, `{"code": 500}`, WithCurrencySymbols([]string{"JPY", "EUR", "USD"}))
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want), "currency_info"); err != nil {
+		t.Error(err)
+	}
+
+	c = newTestCollectorWithOptions(t, `{"code": 500}`, WithCurrencySymbols([]string{"JPY"}), WithShard(Shard{Index: 1, Count: 2, Key: "account"}))
+	if n := testutil.CollectAndCount(c, "currency_info"); n != 0 {
+		t.Errorf("shard 1 emitted %d currency_info series, want 0", n)
+	}
+}
+
+func TestCloudCostCollector_CoercedValues(t *testing.T) {
+	c := newTestCollector(t, `{"code": 200, "data": {"sets": [{"cloudCosts": {
+		"item-1": {
+			"properties": {"providerID": "i-1", "accountID": "123", "service": "AmazonEC2", "category": "Compute"},
+			"listCost": {"cost": "10.5"},
+			"netCost": {"cost": null}
+		}
+	}}]}}`)
+
+	if _, ok := c.Data(context.Background()); !ok {
+		t.Fatal("Data() should keep a response with coerced values")
+	}
+	if n := testutil.ToFloat64(c.coercedValues); n != 2 {
+		t.Errorf("coerced values = %v, want 2", n)
+	}
+}
+
+func TestCloudCostCollector_InvalidResponses(t *testing.T) {
+	c := newTestCollector(t, `{"code": 500, "data": {"sets": []}}`)
+
+	if _, ok := c.Data(context.Background()); ok {
+		t.Fatal("Data() should not cache an invalid response")
+	}
+	if n := testutil.ToFloat64(c.invalidResponses); n != 1 {
+		t.Errorf("invalid responses = %v, want 1", n)
+	}
+}
+
+func TestCloudCostCollector_PartialData(t *testing.T) {
+	c := newTestCollector(t, `{"code": 200, "data": {"sets": [{"cloudCosts": {
+		"item-1": {"properties": {"service": "AmazonEC2"}, "listCost": {"cost": "+Inf"}},
+		"item-2": {"properties": {"service": "AmazonRDS"}, "listCost": {"cost": 10}}
+	}}]}}`)
+
+	data, ok := c.Data(context.Background())
+	if !ok || len(data.Data.Sets[0].CloudCosts) != 1 {
+		t.Fatalf("Data() = %+v, want the valid item", data)
+	}
+	if n := testutil.ToFloat64(c.skippedItems); n != 1 {
+		t.Errorf("skipped items = %v, want 1", n)
+	}
+	if v := testutil.ToFloat64(c.partialData); v != 1 {
+		t.Errorf("partial data = %v, want 1", v)
+	}
+	if n := testutil.ToFloat64(c.scrapeErrors); n != 0 {
+		t.Errorf("scrape errors = %v, want 0", n)
+	}
+}
+
+func TestCloudCostCollector_OverlappingSets(t *testing.T) {
+	c := newTestCollector(t, `{"code": 200, "data": {"sets": [
+		{"window": {"start": "2026-01-01T00:00:00Z", "end": "2026-01-02T00:00:00Z"}, "cloudCosts": {
+			"ec2": {"properties": {"accountID": "123", "service": "AmazonEC2"}, "listCost": {"cost": 10}}
+		}},
+		{"window": {"start": "2026-01-01T00:00:00Z", "end": "2026-01-03T00:00:00Z"}, "cloudCosts": {
+			"ec2": {"properties": {"accountID": "123", "service": "AmazonEC2"}, "listCost": {"cost": 25}}
+		}}
+	]}}`)
+
+	data, ok := c.Data(context.Background())
+	if !ok || len(data.Data.Sets) != 1 || data.Data.Sets[0].CloudCosts["ec2"].ListCost.Cost != 25 {
+		t.Fatalf("Data() = %+v, want only the more recent set", data)
+	}
+	if n := testutil.ToFloat64(c.overlappingSets); n != 1 {
+		t.Errorf("overlapping sets = %v, want 1", n)
+	}
+}
+
+func TestCloudCostCollector_Throttled(t *testing.T) {
+	c := newTestCollectorWithOptions(t, `{"code": 200, "data": {"sets": []}}`,
+		WithFetcher(func(context.Context) (*types.CloudCostResponse, error) {
+			return nil, fmt.Errorf("after 3 retries: %w", &client.ThrottledError{Target: client.TargetOpenCost, Until: time.Now().Add(time.Minute)})
+		}),
+	)
+
+	if _, ok := c.Data(context.Background()); ok {
+		t.Fatal("Data() should report no data while throttled")
+	}
+	if n := testutil.ToFloat64(c.scrapeErrors); n != 0 {
+		t.Errorf("scrape errors = %v, want 0: throttling is not a failure", n)
+	}
+}
+
+func TestCloudCostCollector_RefreshHook(t *testing.T) {
+	called := make(chan *types.CloudCostResponse, 1)
+	c := newTestCollectorWithOptions(t, `{"code": 200, "data": {"sets": []}}`,
+		WithRefreshHook(func(_ context.Context, data *types.CloudCostResponse) {
+			called <- data
+		}),
+	)
+
+	ch := make(chan prometheus.Metric, 100)
+	c.Collect(ch)
+	close(ch)
+
+	select {
+	case data := <-called:
+		if data == nil || data.Code != 200 {
+			t.Errorf("hook received unexpected data: %+v", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("refresh hook was not called")
+	}
+}
+
+func TestCloudCostCollector_RequestID(t *testing.T) {
+	var fetchID string
+	hookID := make(chan string, 1)
+	c := newTestCollectorWithOptions(t, `{"code": 200, "data": {"sets": []}}`,
+		WithFetcher(func(ctx context.Context) (*types.CloudCostResponse, error) {
+			fetchID = requestid.FromContext(ctx)
+			return &types.CloudCostResponse{Code: 200}, nil
+		}),
+		WithRefreshHook(func(ctx context.Context, _ *types.CloudCostResponse) {
+			hookID <- requestid.FromContext(ctx)
+		}),
+	)
+
+	c.Data(context.Background())
+	if fetchID == "" {
+		t.Fatal("fetch without a request ID")
+	}
+	if id := <-hookID; id != fetchID {
+		t.Errorf("refresh hook request ID = %q, want the fetch's %q", id, fetchID)
+	}
+}
+
+func TestCloudCostCollector_StageDuration(t *testing.T) {
+	c := newTestCollector(t, `{"code": 200, "data": {"sets": [{"cloudCosts": {
+		"item-1": {"properties": {"accountID": "123", "service": "AmazonEC2", "category": "Compute"}, "listCost": {"cost": 1}}
+	}}]}}`)
+	testutil.CollectAndCount(c)
+
+	for _, stage := range []string{client.StageFetch, client.StageDecode, stageAggregate, stageEmit} {
+		var m dto.Metric
+		if err := c.stageDuration.WithLabelValues(stage).(prometheus.Histogram).Write(&m); err != nil {
+			t.Fatal(err)
+		}
+		if m.GetHistogram().GetSampleCount() != 1 {
+			t.Errorf("stage %s observed %d times, want 1", stage, m.GetHistogram().GetSampleCount())
+		}
+	}
+}
+
+func TestCloudCostCollector_RefreshSchedule(t *testing.T) {
+	schedule, err := cron.Parse("0 */6 * * *", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fetches atomic.Int32
+	fetch := func(context.Context) (*types.CloudCostResponse, error) {
+		fetches.Add(1)
+		return &types.CloudCostResponse{Code: 200, Data: types.CloudCostData{Sets: []types.CloudCostSet{}}}, nil
+	}
+	// Everything cached is stale at once, but within the max stale duration.
+	c := New(client.New("http://opencost.invalid"), cache.New(0, time.Hour),
+		WithFetcher(fetch), WithRefreshSchedule(schedule))
+
+	var next bool
+	for range 3 {
+		ch := make(chan prometheus.Metric, 100)
+		c.Collect(ch)
+		close(ch)
+		for m := range ch {
+			next = next || strings.Contains(m.Desc().String(), "next_refresh_timestamp_seconds")
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("fetches = %d, want 1: stale data should be left to the schedule", n)
+	}
+	if !next {
+		t.Error("expected the next refresh to be reported")
+	}
+}
+
+func TestShard_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		shard   Shard
+		wantErr bool
+	}{
+		{"unsharded", Shard{Index: 0, Count: 1, Key: "account"}, false},
+		{"last shard", Shard{Index: 3, Count: 4, Key: "service"}, false},
+		{"zero count", Shard{Index: 0, Count: 0, Key: "account"}, true},
+		{"index too large", Shard{Index: 4, Count: 4, Key: "account"}, true},
+		{"negative index", Shard{Index: -1, Count: 4, Key: "account"}, true},
+		{"unknown key", Shard{Index: 0, Count: 2, Key: "region"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.shard.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCloudCostCollector_Shard(t *testing.T) {
+	var items []types.CloudCostItem
+	for i := range 20 {
+		items = append(items, opencosttest.Item(fmt.Sprintf("acct-%d", i), "AmazonEC2", "Compute", 1))
+	}
+	server := opencosttest.NewServer(opencosttest.WithResponse(opencosttest.Response(items...)))
+	defer server.Close()
+
+	const count = 3
+	seen := make(map[string]int)
+	total := 0.0
+	for index := range count {
+		c := New(client.New(server.URL), cache.New(time.Hour, 6*time.Hour),
+			WithCurrencySymbols(nil),
+			WithShard(Shard{Index: index, Count: count, Key: "account"}),
+		)
+		reg := prometheus.NewPedanticRegistry()
+		reg.MustRegister(c)
+		families, err := reg.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, mf := range families {
+			if mf.GetName() != "aws_cloud_cost_total" {
+				continue
+			}
+			for _, m := range mf.GetMetric() {
+				labels := make(map[string]string)
+				for _, l := range m.GetLabel() {
+					labels[l.GetName()] = l.GetValue()
+				}
+				if labels["cost_type"] != "list" {
+					continue
+				}
+				seen[labels["account_id"]]++
+				total += m.GetGauge().GetValue()
+			}
+		}
+	}
+
+	if len(seen) != len(items) {
+		t.Errorf("shards emitted %d accounts, want %d", len(seen), len(items))
+	}
+	for acct, n := range seen {
+		if n != 1 {
+			t.Errorf("account %s emitted by %d shards, want 1", acct, n)
+		}
+	}
+	if total != float64(len(items)) {
+		t.Errorf("sum across shards = %v, want %d", total, len(items))
+	}
+}
+
+func TestCloudCostCollector_Tracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	before := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { otel.SetTracerProvider(before) })
+
+	c := newTestCollectorWithOptions(t, `{"code": 200, "data": {"sets": [{"cloudCosts": {"a": {"properties": {"service": "AmazonEC2"}}}}]}}`)
+	ch := make(chan prometheus.Metric, 100)
+	c.Collect(ch)
+	close(ch)
+
+	spans := make(map[string]tracetest.SpanStub)
+	for _, s := range exporter.GetSpans() {
+		spans[s.Name] = s
+	}
+	root, ok := spans["Collect"]
+	if !ok {
+		t.Fatal("missing Collect span")
+	}
+	for _, name := range []string{"FetchCloudCosts", "aggregate"} {
+		s, ok := spans[name]
+		if !ok {
+			t.Errorf("missing %s span", name)
+			continue
+		}
+		if s.SpanContext.TraceID() != root.SpanContext.TraceID() {
+			t.Errorf("%s span should belong to the Collect trace", name)
+		}
+	}
+}
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+// volatileMetrics vary between runs and are left out of golden files.
+var volatileMetrics = map[string]bool{
+	"cloudcost_exporter_scrape_duration_seconds":          true,
+	"cloudcost_exporter_stage_duration_seconds":           true,
+	"cloudcost_exporter_last_successful_scrape_timestamp": true,
+	"cloudcost_exporter_cache_age_seconds":                true,
+}
+
+// renderExposition gathers c and renders its full text exposition output,
+// without volatile metrics.
+func renderExposition(t *testing.T, c prometheus.Collector) []byte {
+	t.Helper()
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(c)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	enc := expfmt.NewEncoder(&buf, expfmt.NewFormat(expfmt.TypeTextPlain))
+	for _, mf := range families {
+		if volatileMetrics[mf.GetName()] {
+			continue
+		}
+		if err := enc.Encode(mf); err != nil {
+			t.Fatalf("Encode() error = %v", err)
+		}
+	}
+	return buf.Bytes()
+}
+
+// assertGolden compares got with testdata/<name>.golden, rewriting the file
+// when the -update flag is set.
+func assertGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("write golden file: %v", err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden file (run with -update to create it): %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s (run with -update to accept):\n--- got\n%s\n--- want\n%s", path, got, want)
+	}
+}
+
+func TestCloudCostCollector_Golden(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		opts    []Option
+	}{
+		{"basic", "basic.json", nil},
+		{"basic_kube_percent", "basic.json", []Option{WithKubePercentMetrics(true)}},
+		{"multi_set", "multi_set.json", nil},
+		{"multi_set_hourly_rate", "multi_set.json", []Option{WithHourlyRateMetrics(true)}},
+		{"multi_set_cumulative", "multi_set.json", []Option{WithCumulativeMetrics(true)}},
+		{"multi_set_per_set", "multi_set.json", []Option{WithAccumulate(false), WithKubePercentMetrics(true)}},
+		{"multi_set_per_cost_type", "multi_set.json", []Option{WithMetricNaming(MetricNamingPerCostType), WithHourlyRateMetrics(true), WithCumulativeMetrics(true)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fixture, err := os.ReadFile(filepath.Join("testdata", tt.fixture))
+			if err != nil {
+				t.Fatalf("read fixture: %v", err)
+			}
+			opts := append([]Option{WithCurrencySymbols(nil)}, tt.opts...)
+			c := newTestCollectorWithOptions(t, string(fixture), opts...)
+
+			assertGolden(t, tt.name, renderExposition(t, c))
+		})
+	}
+}
+
+// drain consumes metrics until ch is closed.
+func drain(ch <-chan prometheus.Metric) <-chan int {
+	done := make(chan int)
+	go func() {
+		n := 0
+		for range ch {
+			n++
+		}
+		done <- n
+	}()
+	return done
+}
+
+// benchmarks are the synthetic responses the collector is benchmarked
+// with, ranging over the item count, label cardinality and set count.
+var benchmarks = []struct {
+	name string
+	spec opencosttest.Spec
+}{
+	{"items=1000", opencosttest.Spec{Items: 1000, Accounts: 10, Services: 20, Regions: 4}},
+	{"items=10000", opencosttest.Spec{Items: 10000, Accounts: 10, Services: 20, Regions: 4}},
+	{"items=10000,providers=1", opencosttest.Spec{Items: 10000, Accounts: 10, Services: 20, Regions: 4, ProviderIDs: 1}},
+	{"items=10000,labels=10", opencosttest.Spec{Items: 10000, Accounts: 10, Services: 20, Regions: 4, Labels: 10, ProviderIDs: 1}},
+	{"items=1000,sets=30", opencosttest.Spec{Sets: 30, Items: 1000, Accounts: 10, Services: 20, Regions: 4}},
+}
+
+func BenchmarkCloudCostCollector_EmitCostMetrics(b *testing.B) {
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			data := opencosttest.Generate(bm.spec)
+			c := New(client.New("http://unused"), cache.New(time.Hour, time.Hour), WithCurrencySymbols(nil))
+
+			b.ReportAllocs()
+			var series int
+			for b.Loop() {
+				ch := make(chan prometheus.Metric, 1024)
+				done := drain(ch)
+				c.emitCostMetrics(context.Background(), ch, data, nil)
+				close(ch)
+				series = <-done
+			}
+			b.ReportMetric(float64(series), "series")
+		})
+	}
+}
+
+// BenchmarkCloudCostCollector_Gather measures a scrape served from the
+// cache: aggregation, emission and the registry's sorting and validation.
+func BenchmarkCloudCostCollector_Gather(b *testing.B) {
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			data := opencosttest.Generate(bm.spec)
+			c := New(client.New("http://unused"), cache.New(time.Hour, time.Hour),
+				WithCurrencySymbols(nil),
+				WithFetcher(func(context.Context) (*types.CloudCostResponse, error) { return data, nil }),
+			)
+			reg := prometheus.NewRegistry()
+			reg.MustRegister(c)
+			if _, err := reg.Gather(); err != nil {
+				b.Fatal(err)
+			}
+
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := reg.Gather(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func FuzzEmitCostMetrics(f *testing.F) {
+	f.Add("111", "AmazonEC2", "team-alpha", 1.5, 0.5, uint16(3))
+	f.Add("\xff\xfe", "", "\x00", math.NaN(), math.Inf(1), uint16(1))
+	f.Add("a", "b", "c", math.Inf(-1), -1.0, uint16(1500))
+
+	f.Fuzz(func(t *testing.T, account, service, owner string, cost, kubePercent float64, n uint16) {
+		c := New(client.New("http://unused"), cache.New(time.Hour, time.Hour), WithKubePercentMetrics(true), WithCurrencySymbols(nil))
+
+		v := types.CostValue{Cost: cost, KubernetesPercent: kubePercent}
+		costs := make(map[string]types.CloudCostItem)
+		for i := range int(n % 2000) {
+			costs[fmt.Sprint(i)] = types.CloudCostItem{
+				Properties: types.CloudCostProperties{
+					ProviderID: fmt.Sprint(i % 7),
+					AccountID:  account,
+					Service:    service,
+					RegionID:   service + account,
+					Labels:     map[string]string{"owner": owner, "environment": account[:len(account)/2]},
+				},
+				ListCost:         v,
+				NetCost:          v,
+				AmortizedNetCost: v,
+				InvoicedCost:     v,
+				AmortizedCost:    v,
+			}
+		}
+		data := &types.CloudCostResponse{Data: types.CloudCostData{Sets: []types.CloudCostSet{{CloudCosts: costs}, {CloudCosts: costs}}}}
+
+		ch := make(chan prometheus.Metric)
+		done := drain(ch)
+		c.emitCostMetrics(context.Background(), ch, data, nil)
+		close(ch)
+		<-done
+	})
+}
+
+func FuzzCollect(f *testing.F) {
+	f.Add([]byte(`{"code": 200, "data": {"sets": [{"cloudCosts": {"a": {"properties": {"providerID": "i-1", "accountID": "1", "service": "AmazonEC2", "regionID": "us-east-1", "labels": {"owner": "x", "cluster": "c"}}, "listCost": {"cost": 1.5, "kubernetesPercent": 0.5}}}}, {"cloudCosts": {}}]}}`))
+	f.Add([]byte(`{"data":{"sets":[{"cloudCosts":{"x":{"properties":{"labels":{"owner":"\ud800"}},"listCost":{"cost":1e308}}}}]}}`))
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		var data types.CloudCostResponse
+		if err := json.Unmarshal(body, &data); err != nil {
+			return
+		}
+		c := New(client.New("http://unused"), cache.New(time.Hour, time.Hour), WithKubePercentMetrics(true), WithCurrencySymbols(nil))
+
+		ch := make(chan prometheus.Metric)
+		done := drain(ch)
+		c.emitCostMetrics(context.Background(), ch, &data, nil)
+		close(ch)
+		<-done
+	})
+}
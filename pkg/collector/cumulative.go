@@ -0,0 +1,108 @@
+package collector
+
+import (
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+// observedSet holds the costs of one cost set by series, indexed like
+// types.CostTypes.
+type observedSet struct {
+	window types.Window
+	costs  map[costKey][5]float64
+}
+
+// cumulativeCosts accumulates the spend observed in cost sets into totals
+// that only ever grow. Sets are tracked by their window: when a window is
+// observed again, e.g. because the costs of the current day grew, only the
+// increase over the highest cost seen for it is added, so no spend is
+// counted twice however often the data is fetched.
+type cumulativeCosts struct {
+	mu sync.Mutex
+	// windows holds the highest costs seen by window and series.
+	windows map[types.Window]map[costKey][5]float64
+	// expired is the end of the latest window that fell out of the
+	// fetched range. Windows ending by then are not tracked again.
+	expired time.Time
+	totals  map[costKey][5]float64
+}
+
+func newCumulativeCosts() *cumulativeCosts {
+	return &cumulativeCosts{
+		windows: make(map[types.Window]map[costKey][5]float64),
+		totals:  make(map[costKey][5]float64),
+	}
+}
+
+// observe adds the spend in sets that was not observed before. Sets without
+// a known window, and sets whose window overlaps a tracked one without
+// matching it, as returned when OpenCost accumulates the costs of a moving
+// window, cannot be told apart from spend already counted and are ignored.
+// It returns the number of ignored sets.
+func (cc *cumulativeCosts) observe(sets []observedSet) int {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	ignored := 0
+	var earliest time.Time
+	for _, set := range sets {
+		// Windows parsed with a UTC offset have distinct locations.
+		w := types.Window{Start: set.window.Start.UTC(), End: set.window.End.UTC()}
+		if w.Validate() != nil {
+			ignored++
+			continue
+		}
+		if earliest.IsZero() || w.Start.Before(earliest) {
+			earliest = w.Start
+		}
+		seen, tracked := cc.windows[w]
+		if !tracked {
+			if !w.End.After(cc.expired) || cc.overlapsTracked(w) {
+				ignored++
+				continue
+			}
+			seen = make(map[costKey][5]float64)
+			cc.windows[w] = seen
+		}
+		for key, costs := range set.costs {
+			highest, total := seen[key], cc.totals[key]
+			for i, cost := range costs {
+				if cost > highest[i] {
+					total[i] += cost - highest[i]
+					highest[i] = cost
+				}
+			}
+			seen[key], cc.totals[key] = highest, total
+		}
+	}
+
+	// Forget the windows that fell out of the fetched range.
+	for w := range cc.windows {
+		if !earliest.IsZero() && !w.End.After(earliest) {
+			delete(cc.windows, w)
+			if w.End.After(cc.expired) {
+				cc.expired = w.End
+			}
+		}
+	}
+	return ignored
+}
+
+func (cc *cumulativeCosts) overlapsTracked(w types.Window) bool {
+	for tracked := range cc.windows {
+		if w.Overlaps(tracked) {
+			return true
+		}
+	}
+	return false
+}
+
+// snapshot returns the totals by series.
+func (cc *cumulativeCosts) snapshot() map[costKey][5]float64 {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return maps.Clone(cc.totals)
+}
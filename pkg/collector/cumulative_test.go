@@ -0,0 +1,60 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+func TestCumulativeCosts_Observe(t *testing.T) {
+	ec2 := costKey{accountID: "123", service: "AmazonEC2"}
+	day := func(d int) types.Window {
+		start := time.Date(2026, 1, d, 0, 0, 0, 0, time.UTC)
+		return types.Window{Start: start, End: start.AddDate(0, 0, 1)}
+	}
+	set := func(w types.Window, cost float64) observedSet {
+		return observedSet{window: w, costs: map[costKey][5]float64{ec2: {cost}}}
+	}
+
+	cc := newCumulativeCosts()
+	steps := []struct {
+		name        string
+		sets        []observedSet
+		want        float64
+		wantIgnored int
+	}{
+		{"first fetch", []observedSet{set(day(1), 10), set(day(2), 5)}, 15, 0},
+		{"current day grows", []observedSet{set(day(1), 10), set(day(2), 8)}, 18, 0},
+		{"same data again", []observedSet{set(day(1), 10), set(day(2), 8)}, 18, 0},
+		{"revised down", []observedSet{set(day(1), 10), set(day(2), 6)}, 18, 0},
+		{"revised up again", []observedSet{set(day(1), 10), set(day(2), 9)}, 19, 0},
+		{"window moves on", []observedSet{set(day(2), 9), set(day(3), 4)}, 23, 0},
+		{"expired day returns", []observedSet{set(day(1), 10), set(day(2), 9), set(day(3), 4)}, 23, 1},
+		{"accumulated window", []observedSet{set(types.Window{Start: day(2).Start, End: day(4).End}, 30)}, 23, 1},
+		{"unknown window", []observedSet{set(types.Window{}, 30)}, 23, 1},
+	}
+	for _, step := range steps {
+		if ignored := cc.observe(step.sets); ignored != step.wantIgnored {
+			t.Errorf("%s: ignored %d sets, want %d", step.name, ignored, step.wantIgnored)
+		}
+		if got := cc.snapshot()[ec2][0]; got != step.want {
+			t.Errorf("%s: total = %v, want %v", step.name, got, step.want)
+		}
+	}
+}
+
+func TestCumulativeCosts_WindowLocation(t *testing.T) {
+	key := costKey{service: "AmazonS3"}
+	cet := time.FixedZone("CET", 3600)
+	start := time.Date(2026, 1, 1, 1, 0, 0, 0, cet)
+	cc := newCumulativeCosts()
+	for range 2 {
+		// Each decoded window has a location of its own.
+		w := types.Window{Start: start.In(time.FixedZone("CET", 3600)), End: start.AddDate(0, 0, 1).In(time.FixedZone("CET", 3600))}
+		cc.observe([]observedSet{{window: w, costs: map[costKey][5]float64{key: {2}}}})
+	}
+	if got := cc.snapshot()[key][0]; got != 2 {
+		t.Errorf("total = %v, want 2", got)
+	}
+}
@@ -0,0 +1,41 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+// WindowFetcher fetches cloud costs for a window, like
+// client.Client.FetchCloudCostsWindow.
+type WindowFetcher func(ctx context.Context, window string) (*types.CloudCostResponse, error)
+
+// FallbackFetcher returns a Fetcher fetching the window returned by window
+// and, if it holds no cost items, the fallback windows in turn until one
+// does. Billing data of newly provisioned accounts lags a day or two, so
+// that short windows stay empty at first. The response of a fallback window
+// records it in its Window; if all windows are empty, or a fallback fails,
+// the empty response of the configured window is returned.
+func FallbackFetcher(fetch WindowFetcher, window func() string, fallbacks []string) Fetcher {
+	return func(ctx context.Context) (*types.CloudCostResponse, error) {
+		configured := window()
+		data, err := fetch(ctx, configured)
+		if err != nil || !data.Empty() {
+			return data, err
+		}
+		for _, w := range fallbacks {
+			fallback, err := fetch(ctx, w)
+			if err != nil {
+				slog.WarnContext(ctx, "failed to fetch fallback window, serving the empty configured window", "window", w, "error", err)
+				return data, nil
+			}
+			if !fallback.Empty() {
+				slog.InfoContext(ctx, "configured window holds no cost items, falling back to a larger one", "configured_window", configured, "window", w)
+				fallback.Window = w
+				return fallback, nil
+			}
+		}
+		return data, nil
+	}
+}
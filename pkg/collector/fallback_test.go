@@ -0,0 +1,86 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/opencosttest"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+func TestFallbackFetcher(t *testing.T) {
+	tests := []struct {
+		name       string
+		items      map[string]int // items by window; missing windows fail
+		wantWindow string
+		wantItems  int
+		wantCalls  []string
+	}{
+		{name: "configured window has items", items: map[string]int{"2d": 1, "3d": 2}, wantItems: 1, wantCalls: []string{"2d"}},
+		{name: "first fallback", items: map[string]int{"2d": 0, "3d": 2, "7d": 3}, wantWindow: "3d", wantItems: 2, wantCalls: []string{"2d", "3d"}},
+		{name: "last fallback", items: map[string]int{"2d": 0, "3d": 0, "7d": 3}, wantWindow: "7d", wantItems: 3, wantCalls: []string{"2d", "3d", "7d"}},
+		{name: "all empty", items: map[string]int{"2d": 0, "3d": 0, "7d": 0}, wantCalls: []string{"2d", "3d", "7d"}},
+		{name: "fallback fails", items: map[string]int{"2d": 0}, wantCalls: []string{"2d", "3d"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			fetch := func(_ context.Context, window string) (*types.CloudCostResponse, error) {
+				calls = append(calls, window)
+				n, ok := tt.items[window]
+				if !ok {
+					return nil, errors.New("unavailable")
+				}
+				items := make([]types.CloudCostItem, n)
+				for i := range items {
+					items[i] = opencosttest.Item("123", "AmazonEC2", "Compute", 1)
+				}
+				return opencosttest.Response(items...), nil
+			}
+
+			data, err := FallbackFetcher(fetch, func() string { return "2d" }, []string{"3d", "7d"})(context.Background())
+			if err != nil {
+				t.Fatalf("fetch error = %v", err)
+			}
+			if data.Window != tt.wantWindow || len(data.Data.Sets[0].CloudCosts) != tt.wantItems {
+				t.Errorf("fetched window %q with %d items, want %q with %d", data.Window, len(data.Data.Sets[0].CloudCosts), tt.wantWindow, tt.wantItems)
+			}
+			if !slices.Equal(calls, tt.wantCalls) {
+				t.Errorf("fetched windows %v, want %v", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestCloudCostCollector_EffectiveWindow(t *testing.T) {
+	const header = `
+# HELP cloudcost_exporter_effective_window_info Window the cost metrics were fetched for, which differs from the configured window after a fallback to a larger one
+# TYPE cloudcost_exporter_effective_window_info gauge
+`
+	tests := []struct {
+		name   string
+		window string
+		want   string
+	}{
+		{name: "configured", want: `cloudcost_exporter_effective_window_info{configured_window="1d",window="1d"} 1`},
+		{name: "fallback", window: "7d", want: `cloudcost_exporter_effective_window_info{configured_window="1d",window="7d"} 1`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetch := func(context.Context) (*types.CloudCostResponse, error) {
+				data := opencosttest.Response(opencosttest.Item("123", "AmazonEC2", "Compute", 1))
+				data.Window = tt.window
+				return data, nil
+			}
+			c := newTestCollectorWithOptions(t, `{"code": 500}`, WithFetcher(fetch))
+			if err := testutil.CollectAndCompare(c, strings.NewReader(header+tt.want+"\n"), "cloudcost_exporter_effective_window_info"); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package collector
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+// Matcher matches a value against an anchored regex. The zero Matcher
+// matches every value.
+type Matcher struct {
+	re     *regexp.Regexp
+	negate bool
+}
+
+// ParseMatcher parses a regex that values must match, or with a "!" prefix
+// must not match, e.g. "AmazonEC2|AmazonS3" or "!AWSSupport.*". The regex
+// is anchored at both ends. An empty string matches every value.
+func ParseMatcher(s string) (Matcher, error) {
+	if s == "" {
+		return Matcher{}, nil
+	}
+	expr, negate := strings.CutPrefix(s, "!")
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return Matcher{}, fmt.Errorf("invalid filter %q: %w", s, err)
+	}
+	return Matcher{re: re, negate: negate}, nil
+}
+
+// Matches reports whether v matches.
+func (m Matcher) Matches(v string) bool {
+	return m.re == nil || m.re.MatchString(v) != m.negate
+}
+
+// LabelMatcher matches an OpenCost label of a cost item.
+type LabelMatcher struct {
+	source types.LabelSource
+	name   string
+	Matcher
+}
+
+// labelMatchOps are the operators of a label matcher, longest first.
+var labelMatchOps = []string{"!~", "=~", "!=", "="}
+
+// ParseLabelMatchers parses comma-separated label matchers in the syntax of
+// PromQL, e.g. "team=~platform|data,env!=dev". "=" and "!=" compare the
+// label value, "=~" and "!~" match it against an anchored regex; a missing
+// label has the empty value. As in --labels, a "tag:" or "k8s:" prefix
+// reads only provider tags or Kubernetes labels.
+func ParseLabelMatchers(s string) ([]LabelMatcher, error) {
+	var matchers []LabelMatcher
+	for _, expr := range strings.Split(s, ",") {
+		expr = strings.TrimSpace(expr)
+		if expr == "" {
+			continue
+		}
+		i, op := -1, ""
+		for _, o := range labelMatchOps {
+			if j := strings.Index(expr, o); j >= 0 && (i < 0 || j < i) {
+				i, op = j, o
+			}
+		}
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid label matcher %q: want label=value, label!=value, label=~regex or label!~regex", expr)
+		}
+		source, name := labelSource(strings.TrimSpace(expr[:i]))
+		if name == "" {
+			return nil, fmt.Errorf("invalid label matcher %q: missing label name", expr)
+		}
+		value := strings.TrimSpace(expr[i+len(op):])
+		if op == "=" || op == "!=" {
+			value = regexp.QuoteMeta(value)
+		}
+		re, err := regexp.Compile("^(?:" + value + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid label matcher %q: %w", expr, err)
+		}
+		matchers = append(matchers, LabelMatcher{source: source, name: name, Matcher: Matcher{re: re, negate: op[0] == '!'}})
+	}
+	return matchers, nil
+}
+
+// Filter selects the cost items emitted as metrics. An item is emitted if
+// its service, account ID and category and all its label matchers match.
+type Filter struct {
+	Service  Matcher
+	Account  Matcher
+	Category Matcher
+	Labels   []LabelMatcher
+}
+
+// Matches reports whether item passes the filter.
+func (f Filter) Matches(item types.CloudCostItem) bool {
+	if !f.Service.Matches(item.Properties.Service) ||
+		!f.Account.Matches(item.Properties.AccountID) ||
+		!f.Category.Matches(item.Properties.Category) {
+		return false
+	}
+	for _, m := range f.Labels {
+		if !m.Matches(item.Properties.Label(m.source, m.name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// WithFilter emits only the cost items passing f, to save the cardinality
+// of the services, accounts and categories nobody looks at. Items are
+// filtered before they are aggregated, so the remaining series hold the
+// same values as without the filter; the cache, the gRPC API and the
+// exports still see every item.
+func WithFilter(f Filter) Option {
+	return func(c *CloudCostCollector) {
+		c.filter = f
+	}
+}
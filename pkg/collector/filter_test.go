@@ -0,0 +1,115 @@
+package collector
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/cache"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/client"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/opencosttest"
+)
+
+func TestParseMatcher(t *testing.T) {
+	tests := []struct {
+		input   string
+		value   string
+		want    bool
+		wantErr bool
+	}{
+		{input: "", value: "AmazonEC2", want: true},
+		{input: "AmazonEC2|AmazonS3", value: "AmazonS3", want: true},
+		{input: "AmazonEC2|AmazonS3", value: "AmazonS3Glacier", want: false},
+		{input: "!AWSSupport.*", value: "AWSSupportBusiness", want: false},
+		{input: "!AWSSupport.*", value: "AmazonEC2", want: true},
+		{input: "Amazon(", wantErr: true},
+	}
+	for _, tt := range tests {
+		m, err := ParseMatcher(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseMatcher(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if err == nil && m.Matches(tt.value) != tt.want {
+			t.Errorf("ParseMatcher(%q).Matches(%q) = %v, want %v", tt.input, tt.value, !tt.want, tt.want)
+		}
+	}
+}
+
+func TestFilter_Matches(t *testing.T) {
+	labels, err := ParseLabelMatchers("team=~platform|data, env!=dev, tag:cost-center!~")
+	if err != nil {
+		t.Fatalf("ParseLabelMatchers() error = %v", err)
+	}
+	service, _ := ParseMatcher("!AWSSupport.*")
+	f := Filter{Service: service, Labels: labels}
+
+	item := func(service string, labels map[string]string) func() bool {
+		i := opencosttest.Item("123", service, "Compute", 1)
+		i.Properties.Labels = labels
+		return func() bool { return f.Matches(i) }
+	}
+	tests := []struct {
+		name    string
+		matches func() bool
+		want    bool
+	}{
+		{"all match", item("AmazonEC2", map[string]string{"team": "data", "env": "prod", "cost-center": "42"}), true},
+		{"excluded service", item("AWSSupportBusiness", map[string]string{"team": "data", "cost-center": "42"}), false},
+		{"other team", item("AmazonEC2", map[string]string{"team": "web", "cost-center": "42"}), false},
+		{"excluded env", item("AmazonEC2", map[string]string{"team": "data", "env": "dev", "cost-center": "42"}), false},
+		{"missing required label", item("AmazonEC2", map[string]string{"team": "data"}), false},
+	}
+	for _, tt := range tests {
+		if got := tt.matches(); got != tt.want {
+			t.Errorf("%s: Matches() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	for _, invalid := range []string{"team", "=~data", "team=~(", "tag:=x"} {
+		if _, err := ParseLabelMatchers(invalid); err == nil {
+			t.Errorf("ParseLabelMatchers(%q) should fail", invalid)
+		}
+	}
+}
+
+func TestCloudCostCollector_Filter(t *testing.T) {
+	server := opencosttest.NewServer(opencosttest.WithResponse(opencosttest.Response(
+		opencosttest.Item("123", "AmazonEC2", "Compute", 1),
+		opencosttest.Item("123", "AmazonEC2", "Compute", 2),
+		opencosttest.Item("123", "AmazonS3", "Storage", 3),
+		opencosttest.Item("456", "AWSSupportBusiness", "Management", 4),
+	)))
+	defer server.Close()
+
+	account, _ := ParseMatcher("123")
+	category, _ := ParseMatcher("!Storage")
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(New(client.New(server.URL), cache.New(time.Hour, 6*time.Hour),
+		WithCurrencySymbols(nil),
+		WithFilter(Filter{Account: account, Category: category}),
+	))
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+
+	var services []string
+	for _, mf := range families {
+		if mf.GetName() != DefaultNamespace+"_cost_total" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "service" && !slices.Contains(services, l.GetValue()) {
+					services = append(services, l.GetValue())
+				}
+			}
+		}
+	}
+	if !slices.Equal(services, []string{"AmazonEC2"}) {
+		t.Errorf("cost metric services = %v, want only AmazonEC2", services)
+	}
+}
@@ -0,0 +1,160 @@
+package collector
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+// droppedExamples is the number of dropped label combinations logged.
+const droppedExamples = 10
+
+// OtherService is the service label of the services folded together by
+// WithTopServices. It is not a name a provider gives a service, so that the
+// folded costs are never merged with those of a service named "other".
+const OtherService = "__other__"
+
+// WithTopServices keeps the service label of the n services with the
+// highest amortized net cost and folds the costs of all others into the
+// service OtherService, so that accounts using hundreds of services yield
+// readable dashboards and a bounded number of series. Services are ranked
+// on every scrape by their cost over all label combinations. Only the
+// service label is folded, the other labels of the folded costs are kept;
+// the cumulative cost counter is not folded, as services moving in and out
+// of the top would reset its series. An n of 0 or less disables it.
+func WithTopServices(n int) Option {
+	return func(c *CloudCostCollector) {
+		c.topServices = n
+	}
+}
+
+// rankServices returns the services of aggregated to keep with
+// WithTopServices, or nil if all are kept.
+func (c *CloudCostCollector) rankServices(aggregated map[costKey]*aggregatedCost) map[string]bool {
+	if c.topServices <= 0 {
+		return nil
+	}
+	costs := make(map[string]*sum)
+	for key, cost := range aggregated {
+		if costs[key.service] == nil {
+			costs[key.service] = &sum{}
+		}
+		costs[key.service].merge(cost.costs[amortizedNet])
+	}
+	if len(costs) <= c.topServices {
+		return nil
+	}
+	services := slices.Collect(maps.Keys(costs))
+	slices.SortFunc(services, func(a, b string) int {
+		// Most expensive first; ties by name, so that the same services
+		// are folded on every scrape.
+		if n := cmp.Compare(costs[b].value(), costs[a].value()); n != 0 {
+			return n
+		}
+		return strings.Compare(a, b)
+	})
+	keep := make(map[string]bool, c.topServices)
+	for _, service := range services[:c.topServices] {
+		keep[service] = true
+	}
+	return keep
+}
+
+// foldServices returns aggregated with the services not in keep folded
+// into OtherService.
+func foldServices(aggregated map[costKey]*aggregatedCost, keep map[string]bool) map[costKey]*aggregatedCost {
+	folded := make(map[costKey]*aggregatedCost, len(aggregated))
+	for key, cost := range aggregated {
+		if !keep[key.service] {
+			key.service = OtherService
+		}
+		if folded[key] == nil {
+			folded[key] = &aggregatedCost{}
+		}
+		folded[key].merge(cost)
+	}
+	return folded
+}
+
+// WithMaxSeries caps the series of the cost metric, e.g. when a
+// misconfigured aggregation or labels on raw provider IDs would produce
+// more series than Prometheus can take. Beyond the limit, the label
+// combinations with the lowest amortized net cost are dropped, along with
+// their credit, hourly rate and Kubernetes percent series. Each label
+// combination counts once per cost type and currency. A limit of 0 or less
+// disables it.
+func WithMaxSeries(limit int) Option {
+	return func(c *CloudCostCollector) {
+		c.maxSeries = limit
+	}
+}
+
+// limitSeries removes the cheapest label combinations from aggregated
+// until the cost series fit the limit. It counts the dropped series and
+// logs examples whenever their number changes.
+func (c *CloudCostCollector) limitSeries(aggregated map[costKey]*aggregatedCost) {
+	if c.maxSeries <= 0 {
+		return
+	}
+	perKey := len(types.CostTypes) * (1 + len(c.convertCurrencies))
+	keep := c.maxSeries / perKey
+	if len(aggregated) <= keep {
+		c.lastDropped.Store(0)
+		return
+	}
+
+	keys := slices.Collect(maps.Keys(aggregated))
+	slices.SortFunc(keys, func(a, b costKey) int {
+		// Most expensive first; ties in label order, so that the same
+		// series are dropped on every scrape.
+		if n := cmp.Compare(aggregated[b].costs[amortizedNet].value(), aggregated[a].costs[amortizedNet].value()); n != 0 {
+			return n
+		}
+		return slices.Compare(c.labels(a), c.labels(b))
+	})
+	dropped := keys[keep:]
+	for _, key := range dropped {
+		delete(aggregated, key)
+	}
+	series := len(dropped) * perKey
+	c.seriesDropped.Add(float64(series))
+
+	if c.lastDropped.Swap(int64(series)) == int64(series) {
+		return
+	}
+	examples := make([]string, 0, droppedExamples)
+	for _, key := range dropped[:min(len(dropped), droppedExamples)] {
+		examples = append(examples, c.labelString(key))
+	}
+	slog.Warn("cost series limit exceeded, dropped the cheapest series",
+		"limit", c.maxSeries,
+		"series", len(keys)*perKey,
+		"dropped", series,
+		"dropped_examples", examples,
+	)
+}
+
+// labelString formats the labels of key, without the cost type, like a
+// PromQL selector, e.g. {account_id="123",service="AmazonEC2"}. Empty
+// labels are left out.
+func (c *CloudCostCollector) labelString(key costKey) string {
+	names := slices.Delete(slices.Clone(c.costLabels), 4, 5) // cost_type
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, value := range c.labels(key) {
+		if value == "" {
+			continue
+		}
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=%q", names[i], value)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
@@ -0,0 +1,151 @@
+package collector
+
+import (
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/cache"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/client"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/opencosttest"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+func TestCloudCostCollector_MaxSeries(t *testing.T) {
+	server := opencosttest.NewServer(opencosttest.WithResponse(opencosttest.Response(
+		opencosttest.Item("123", "AmazonEC2", "Compute", 100),
+		opencosttest.Item("123", "AmazonS3", "Storage", 1),
+		opencosttest.Item("123", "AmazonRDS", "Database", 50),
+		opencosttest.Item("123", "AWSLambda", "Compute", 1),
+	)))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		limit   int
+		want    []string
+		dropped float64
+	}{
+		{"unlimited", 0, []string{"AWSLambda", "AmazonEC2", "AmazonRDS", "AmazonS3"}, 0},
+		{"within the limit", 4 * len(types.CostTypes), []string{"AWSLambda", "AmazonEC2", "AmazonRDS", "AmazonS3"}, 0},
+		// The cheaper of the tied items is chosen by its labels.
+		{"cheapest dropped", 3 * len(types.CostTypes), []string{"AWSLambda", "AmazonEC2", "AmazonRDS"}, float64(len(types.CostTypes))},
+		{"partial combinations", 2*len(types.CostTypes) + 1, []string{"AmazonEC2", "AmazonRDS"}, float64(2 * len(types.CostTypes))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(client.New(server.URL), cache.New(time.Hour, 6*time.Hour), WithCurrencySymbols(nil), WithMaxSeries(tt.limit))
+			if got := services(t, c); !slices.Equal(got, tt.want) {
+				t.Errorf("services = %v, want %v", got, tt.want)
+			}
+			if got := testutil.ToFloat64(c.seriesDropped); got != tt.dropped {
+				t.Errorf("series dropped = %v, want %v", got, tt.dropped)
+			}
+		})
+	}
+}
+
+func TestCloudCostCollector_TopServices(t *testing.T) {
+	server := opencosttest.NewServer(opencosttest.WithResponse(opencosttest.Response(
+		opencosttest.Item("123", "AmazonEC2", "Compute", 100),
+		opencosttest.Item("123", "AmazonRDS", "Database", 50),
+		opencosttest.Item("123", "AmazonS3", "Storage", 1),
+		opencosttest.Item("456", "AmazonS3", "Storage", 2),
+		opencosttest.Item("456", "AWSLambda", "Compute", 1.5),
+	)))
+	defer server.Close()
+
+	tests := []struct {
+		name  string
+		n     int
+		want  []string
+		other map[string]float64
+	}{
+		{"disabled", 0, []string{"AWSLambda", "AmazonEC2", "AmazonRDS", "AmazonS3"}, map[string]float64{}},
+		{"all within", 4, []string{"AWSLambda", "AmazonEC2", "AmazonRDS", "AmazonS3"}, map[string]float64{}},
+		// S3 costs 3 in total and outranks Lambda, although it is the
+		// cheaper service of account 123.
+		{"folded", 3, []string{"AmazonEC2", "AmazonRDS", "AmazonS3", OtherService}, map[string]float64{"456": 1.5}},
+		{"folded per account", 2, []string{"AmazonEC2", "AmazonRDS", OtherService}, map[string]float64{"123": 1, "456": 3.5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(client.New(server.URL), cache.New(time.Hour, 6*time.Hour), WithCurrencySymbols(nil), WithTopServices(tt.n))
+			if got := services(t, c); !slices.Equal(got, tt.want) {
+				t.Errorf("services = %v, want %v", got, tt.want)
+			}
+			other := make(map[string]float64)
+			for _, m := range gatherCost(t, c) {
+				labels := make(map[string]string)
+				for _, l := range m.GetLabel() {
+					labels[l.GetName()] = l.GetValue()
+				}
+				if labels["service"] == OtherService && labels["cost_type"] == "amortized_net" {
+					other[labels["account_id"]] += m.GetGauge().GetValue()
+				}
+			}
+			if !maps.Equal(other, tt.other) {
+				t.Errorf("other by account = %v, want %v", other, tt.other)
+			}
+		})
+	}
+}
+
+func TestCloudCostCollector_LabelString(t *testing.T) {
+	c := New(client.New("http://opencost.invalid"), cache.New(time.Hour, 0), WithSourceLabel(true))
+	key := costKey{accountID: "123", service: "AmazonEC2", region: "us-east-1", source: "eu"}
+	if got, want := c.labelString(key), `{account_id="123",service="AmazonEC2",region="us-east-1",source="eu"}`; got != want {
+		t.Errorf("labelString() = %s, want %s", got, want)
+	}
+}
+
+// services returns the sorted services of the cost metric c emits.
+func services(t *testing.T, c *CloudCostCollector) []string {
+	t.Helper()
+	var got []string
+	for _, m := range gatherCost(t, c) {
+		for _, l := range m.GetLabel() {
+			if l.GetName() == "service" && !slices.Contains(got, l.GetValue()) {
+				got = append(got, l.GetValue())
+			}
+		}
+	}
+	slices.Sort(got)
+	return got
+}
+
+// gatherCost returns the series of the cost metric c emits.
+func gatherCost(t *testing.T, c *CloudCostCollector) []*dto.Metric {
+	t.Helper()
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(c)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	for _, mf := range families {
+		if mf.GetName() == DefaultNamespace+"_cost_total" {
+			return mf.GetMetric()
+		}
+	}
+	return nil
+}
+
+func TestCloudCostCollector_TopServicesNamedOther(t *testing.T) {
+	server := opencosttest.NewServer(opencosttest.WithResponse(opencosttest.Response(
+		opencosttest.Item("123", "AmazonEC2", "Compute", 100),
+		opencosttest.Item("123", "other", "Other", 50),
+		opencosttest.Item("123", "AmazonS3", "Storage", 1),
+	)))
+	defer server.Close()
+
+	c := New(client.New(server.URL), cache.New(time.Hour, 6*time.Hour), WithCurrencySymbols(nil), WithTopServices(2))
+	if got, want := services(t, c), []string{"AmazonEC2", OtherService, "other"}; !slices.Equal(got, want) {
+		t.Errorf("services = %v, want the service named other kept apart from the folded ones, %v", got, want)
+	}
+}
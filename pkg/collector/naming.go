@@ -0,0 +1,109 @@
+package collector
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+// MetricNaming decides how the cost metrics tell the cost types apart.
+type MetricNaming string
+
+const (
+	// MetricNamingLabel emits each cost metric with a cost_type label, e.g.
+	// aws_cloud_cost_total{cost_type="amortized_net"}.
+	MetricNamingLabel MetricNaming = "label"
+	// MetricNamingPerCostType emits a metric per cost type instead, e.g.
+	// aws_cloud_cost_amortized_net_usd, and with currency conversion per
+	// currency too, e.g. aws_cloud_cost_amortized_net_eur, without the
+	// cost_type and currency labels.
+	MetricNamingPerCostType MetricNaming = "per-cost-type"
+)
+
+// MetricNamings are the supported metric naming modes.
+var MetricNamings = []MetricNaming{MetricNamingLabel, MetricNamingPerCostType}
+
+// ParseMetricNaming parses a metric naming mode; empty selects
+// MetricNamingLabel.
+func ParseMetricNaming(s string) (MetricNaming, error) {
+	n := MetricNaming(strings.ToLower(strings.TrimSpace(s)))
+	if n == "" {
+		return MetricNamingLabel, nil
+	}
+	if !slices.Contains(MetricNamings, n) {
+		return "", fmt.Errorf("unknown metric naming %q (want one of label, per-cost-type)", s)
+	}
+	return n, nil
+}
+
+// WithMetricNaming sets how the cost, credit, hourly rate and cumulative
+// cost metrics tell the cost types apart. The default is
+// MetricNamingLabel. The other metrics keep their cost_type label.
+func WithMetricNaming(n MetricNaming) Option {
+	return func(c *CloudCostCollector) {
+		c.perCostType = n == MetricNamingPerCostType
+	}
+}
+
+// costMetric is a metric whose series are told apart by cost type: either
+// one metric with a cost_type label, or a metric per cost type.
+type costMetric struct {
+	labeled *prometheus.Desc   // with a cost_type label
+	perType []*prometheus.Desc // indexed like types.CostTypes
+}
+
+// newCostMetric creates the metric named name with a cost_type label or,
+// with per-cost-type naming, the metrics named by perTypeName of each cost
+// type. labels are the label names of the labeled metric, which has the
+// cost_type label at index 4.
+func (c *CloudCostCollector) newCostMetric(name string, perTypeName func(costType string) string, help string, labels []string) costMetric {
+	if !c.perCostType {
+		return costMetric{labeled: prometheus.NewDesc(name, help, labels, nil)}
+	}
+	labels = slices.Delete(slices.Clone(labels), 4, 5)
+	m := costMetric{perType: make([]*prometheus.Desc, len(types.CostTypes))}
+	for i, costType := range types.CostTypes {
+		m.perType[i] = prometheus.NewDesc(perTypeName(costType), help+", "+costType+" cost type", labels, nil)
+	}
+	return m
+}
+
+// describe sends the descriptors of m.
+func (m costMetric) describe(ch chan<- *prometheus.Desc) {
+	if m.labeled != nil {
+		ch <- m.labeled
+		return
+	}
+	for _, desc := range m.perType {
+		ch <- desc
+	}
+}
+
+// send sends the series of the cost type types.CostTypes[i], with the
+// label values of a series without the cost_type label.
+func (m costMetric) send(ch chan<- prometheus.Metric, valueType prometheus.ValueType, i int, value float64, labels []string) {
+	if m.labeled != nil {
+		sendConst(ch, m.labeled, valueType, value, withCostType(labels, types.CostTypes[i])...)
+		return
+	}
+	sendConst(ch, m.perType[i], valueType, value, labels...)
+}
+
+// costMetricName returns the per-cost-type name of the cost metric of
+// costType in currency, e.g. aws_cloud_cost_amortized_net_usd.
+func (c *CloudCostCollector) costMetricName(costType, currency string) string {
+	return c.namespace + "_cost_" + costType + "_" + strings.ToLower(currency)
+}
+
+// CostTypeMetricName returns the name of the cost metric of costType in
+// USD with per-cost-type naming, or "" with a cost_type label.
+func (c *CloudCostCollector) CostTypeMetricName(costType string) string {
+	if !c.perCostType {
+		return ""
+	}
+	return c.costMetricName(costType, "USD")
+}
@@ -0,0 +1,80 @@
+package collector
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/cache"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/client"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/opencosttest"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+func TestParseMetricNaming(t *testing.T) {
+	for input, want := range map[string]MetricNaming{"": MetricNamingLabel, "label": MetricNamingLabel, "Per-Cost-Type": MetricNamingPerCostType} {
+		if got, err := ParseMetricNaming(input); err != nil || got != want {
+			t.Errorf("ParseMetricNaming(%q) = %q, %v, want %q", input, got, err, want)
+		}
+	}
+	if _, err := ParseMetricNaming("per-currency"); err == nil {
+		t.Error("ParseMetricNaming(per-currency) should fail")
+	}
+}
+
+func TestCloudCostCollector_PerCostTypeConversion(t *testing.T) {
+	c := New(client.New("http://unused"), cache.New(time.Hour, 6*time.Hour),
+		WithCurrencySymbols(nil),
+		WithCurrencyConversion([]string{"EUR", "JPY"}),
+		WithMetricNaming(MetricNamingPerCostType),
+	)
+	if got := c.CostLabels(); slices.Contains(got, "cost_type") || slices.Contains(got, "currency") {
+		t.Errorf("cost labels = %v, want neither cost_type nor currency", got)
+	}
+	if got, want := c.CostTypeMetricName("amortized_net"), "aws_cloud_cost_amortized_net_usd"; got != want {
+		t.Errorf("CostTypeMetricName() = %q, want %q", got, want)
+	}
+
+	data := opencosttest.Response(opencosttest.Item("123", "AmazonEC2", "Compute", 10))
+	rates := &types.ExchangeRateResponse{Base: "USD", Rates: map[string]float64{"EUR": 0.5}}
+	ch := make(chan prometheus.Metric, 100)
+	c.emitCostMetrics(context.Background(), ch, data, rates)
+	close(ch)
+
+	got := make(map[string]float64)
+	for m := range ch {
+		var pb dto.Metric
+		m.Write(&pb)
+		for _, l := range pb.GetLabel() {
+			if l.GetName() == "cost_type" || l.GetName() == "currency" {
+				t.Errorf("%s has a %s label", m.Desc(), l.GetName())
+			}
+		}
+		got[fqName(m.Desc())] = pb.GetGauge().GetValue()
+	}
+	// JPY has no rate.
+	for name, want := range map[string]float64{
+		"aws_cloud_cost_list_usd":          10,
+		"aws_cloud_cost_amortized_net_usd": 10,
+		"aws_cloud_cost_amortized_net_eur": 5,
+	} {
+		if got[name] != want {
+			t.Errorf("%s = %v, want %v", name, got[name], want)
+		}
+	}
+	if _, ok := got["aws_cloud_cost_amortized_net_jpy"]; ok {
+		t.Error("emitted JPY costs without a rate")
+	}
+}
+
+// fqName returns the metric name of desc.
+func fqName(desc *prometheus.Desc) string {
+	_, s, _ := strings.Cut(desc.String(), `fqName: "`)
+	name, _, _ := strings.Cut(s, `"`)
+	return name
+}
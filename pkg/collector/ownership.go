@@ -0,0 +1,107 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"go.yaml.in/yaml/v2"
+)
+
+// Owner is who a cloud account's costs are attributed to.
+type Owner struct {
+	Team       string `yaml:"team"`
+	CostCenter string `yaml:"costCenter"`
+}
+
+// Ownership maps account IDs to their owners.
+type Ownership map[string]Owner
+
+// ownershipHeader is the header of an ownership CSV file.
+var ownershipHeader = []string{"account_id", "team", "cost_center"}
+
+// LoadOwnership reads an ownership file: a CSV file (.csv) with the header
+// account_id,team,cost_center, or otherwise a YAML map of account IDs to
+// their team and costCenter:
+//
+//	"123456789012":
+//	  team: platform
+//	  costCenter: CC-100
+func LoadOwnership(path string) (Ownership, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read ownership file: %w", err)
+	}
+	var o Ownership
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		o, err = parseOwnershipCSV(b)
+	} else {
+		err = yaml.UnmarshalStrict(b, &o)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse ownership file %s: %w", path, err)
+	}
+	if o == nil {
+		o = Ownership{}
+	}
+	for account := range o {
+		if strings.TrimSpace(account) == "" {
+			return nil, fmt.Errorf("parse ownership file %s: empty account ID", path)
+		}
+	}
+	return o, nil
+}
+
+func parseOwnershipCSV(b []byte) (Ownership, error) {
+	r := csv.NewReader(bytes.NewReader(b))
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if errors.Is(err, io.EOF) {
+		return Ownership{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Equal(header, ownershipHeader) {
+		return nil, fmt.Errorf("header %q, want %q", strings.Join(header, ","), strings.Join(ownershipHeader, ","))
+	}
+	o := make(Ownership)
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return o, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		account := strings.TrimSpace(record[0])
+		if _, ok := o[account]; ok {
+			line, _ := r.FieldPos(0)
+			return nil, fmt.Errorf("line %d: duplicate account ID %q", line, account)
+		}
+		o[account] = Owner{Team: strings.TrimSpace(record[1]), CostCenter: strings.TrimSpace(record[2])}
+	}
+}
+
+// WithOwnership adds team and cost_center labels to the cost metrics,
+// holding the owner of the item's account in o. Items of accounts not in
+// o have them empty. A nil o leaves the labels out.
+func WithOwnership(o Ownership) Option {
+	return func(c *CloudCostCollector) {
+		c.ownershipLabels = o != nil
+		c.SetOwnership(o)
+	}
+}
+
+// SetOwnership replaces the account owners at runtime, e.g. when the
+// ownership file changes. It takes effect on the next scrape, and only
+// with the labels added by WithOwnership.
+func (c *CloudCostCollector) SetOwnership(o Ownership) {
+	c.ownership.Store(&o)
+}
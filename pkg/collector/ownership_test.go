@@ -0,0 +1,100 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/cache"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/client"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/opencosttest"
+)
+
+func TestLoadOwnership(t *testing.T) {
+	want := Ownership{
+		"111": {Team: "platform", CostCenter: "CC-100"},
+		"222": {Team: "data"},
+	}
+	tests := []struct {
+		name, file, content string
+		want                Ownership
+		wantErr             bool
+	}{
+		{"yaml", "owners.yaml", "\"111\":\n  team: platform\n  costCenter: CC-100\n\"222\":\n  team: data\n", want, false},
+		{"csv", "owners.csv", "account_id,team,cost_center\n111, platform, CC-100\n222,data,\n", want, false},
+		{"empty yaml", "owners.yaml", "", Ownership{}, false},
+		{"empty csv", "owners.csv", "", Ownership{}, false},
+		{"unknown yaml field", "owners.yaml", "\"111\":\n  owner: platform\n", nil, true},
+		{"wrong csv header", "owners.csv", "account,team,cost_center\n111,platform,CC-100\n", nil, true},
+		{"short csv record", "owners.csv", "account_id,team,cost_center\n111,platform\n", nil, true},
+		{"duplicate csv account", "owners.csv", "account_id,team,cost_center\n111,a,\n111,b,\n", nil, true},
+		{"empty account", "owners.csv", "account_id,team,cost_center\n,platform,\n", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := LoadOwnership(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadOwnership() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadOwnership() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := LoadOwnership(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("LoadOwnership() of a missing file should fail")
+	}
+}
+
+func TestCloudCostCollector_Ownership(t *testing.T) {
+	c := New(client.New("http://unused"), cache.New(time.Hour, 6*time.Hour),
+		WithCurrencySymbols(nil),
+		WithOwnership(Ownership{"111": {Team: "platform", CostCenter: "CC-100"}}),
+	)
+	if got, want := c.CostLabels()[len(costLabels):], []string{"team", "cost_center"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("extra cost labels = %v, want %v", got, want)
+	}
+
+	data := opencosttest.Response(
+		opencosttest.Item("111", "AmazonEC2", "Compute", 1),
+		opencosttest.Item("222", "AmazonEC2", "Compute", 2),
+	)
+	// owners returns the team and cost center by account of the list costs.
+	owners := func() map[string][2]string {
+		ch := make(chan prometheus.Metric, 100)
+		c.emitCostMetrics(context.Background(), ch, data, nil)
+		close(ch)
+		got := make(map[string][2]string)
+		for m := range ch {
+			var pb dto.Metric
+			m.Write(&pb)
+			labels := make(map[string]string)
+			for _, l := range pb.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["cost_type"] == "list" {
+				got[labels["account_id"]] = [2]string{labels["team"], labels["cost_center"]}
+			}
+		}
+		return got
+	}
+
+	if got, want := owners(), map[string][2]string{"111": {"platform", "CC-100"}, "222": {"", ""}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("owners = %v, want %v", got, want)
+	}
+	c.SetOwnership(Ownership{"222": {Team: "data"}})
+	if got, want := owners(), map[string][2]string{"111": {"", ""}, "222": {"data", ""}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("owners after SetOwnership = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,87 @@
+package collector
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+// restatements detects restated cost data: cost sets whose window had
+// already ended when they were fetched before, and whose totals changed by
+// more than a threshold since. Providers restate past days, e.g. after
+// applying credits or correcting usage, so that the samples Prometheus
+// scraped for those days no longer hold.
+type restatements struct {
+	// threshold is the relative change of a total taken as a restatement.
+	threshold float64
+
+	mu sync.Mutex
+	// windows holds the totals of the complete windows of the last fetch
+	// by cost type, indexed like types.CostTypes.
+	windows map[types.Window][5]float64
+	// count and delta hold the number of restatements and the change of
+	// the latest one by cost type.
+	count [5]float64
+	delta [5]float64
+}
+
+func newRestatements(threshold float64) *restatements {
+	return &restatements{threshold: threshold, windows: make(map[types.Window][5]float64)}
+}
+
+// observe compares the totals of the sets whose window ended by now with
+// those of the previous fetch and returns the number of restated windows.
+// Only the windows of sets are tracked afterwards, so that windows that fell
+// out of the fetched range are forgotten.
+func (r *restatements) observe(sets []types.CloudCostSet, now time.Time) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	restated := 0
+	windows := make(map[types.Window][5]float64, len(sets))
+	for _, set := range sets {
+		bounds := set.Bounds()
+		// Windows parsed with a UTC offset have distinct locations.
+		w := types.Window{Start: bounds.Start.UTC(), End: bounds.End.UTC()}
+		if w.Validate() != nil || w.End.After(now) {
+			// The totals of ongoing windows grow as a matter of course.
+			continue
+		}
+		var totals [5]float64
+		for _, item := range set.CloudCosts {
+			for i, costType := range types.CostTypes {
+				v, _ := item.CostByType(costType)
+				totals[i] += v.Cost
+			}
+		}
+		windows[w] = totals
+
+		previous, ok := r.windows[w]
+		if !ok {
+			continue
+		}
+		changed := false
+		for i := range totals {
+			if d := totals[i] - previous[i]; math.Abs(d) > r.threshold*math.Abs(previous[i]) {
+				r.count[i]++
+				r.delta[i] = d
+				changed = true
+			}
+		}
+		if changed {
+			restated++
+		}
+	}
+	r.windows = windows
+	return restated
+}
+
+// snapshot returns the number of restatements and the change of the latest
+// one by cost type.
+func (r *restatements) snapshot() (count, delta [5]float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.count, r.delta
+}
@@ -0,0 +1,84 @@
+package collector
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/opencosttest"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+// daySet returns a set of one item costing cost for the given day of
+// January 2026.
+func daySet(d int, cost float64) types.CloudCostSet {
+	start := time.Date(2026, 1, d, 0, 0, 0, 0, time.UTC)
+	return types.CloudCostSet{
+		Window:     types.Window{Start: start, End: start.AddDate(0, 0, 1)},
+		CloudCosts: map[string]types.CloudCostItem{"i-1": opencosttest.Item("123", "AmazonEC2", "Compute", cost)},
+	}
+}
+
+func TestRestatements_Observe(t *testing.T) {
+	now := time.Date(2026, 1, 3, 12, 0, 0, 0, time.UTC)
+	r := newRestatements(0.01)
+	steps := []struct {
+		name      string
+		sets      []types.CloudCostSet
+		want      int
+		wantDelta float64
+	}{
+		{"first fetch", []types.CloudCostSet{daySet(1, 100), daySet(2, 50), daySet(3, 5)}, 0, 0},
+		{"current day grows", []types.CloudCostSet{daySet(1, 100), daySet(2, 50), daySet(3, 8)}, 0, 0},
+		{"within threshold", []types.CloudCostSet{daySet(1, 100.5), daySet(2, 50), daySet(3, 9)}, 0, 0},
+		{"restated", []types.CloudCostSet{daySet(1, 90), daySet(2, 50), daySet(3, 9)}, 1, -10.5},
+		{"restated day fetched again", []types.CloudCostSet{daySet(1, 90), daySet(2, 50), daySet(3, 9)}, 0, -10.5},
+		{"day fell out of the range", []types.CloudCostSet{daySet(2, 50), daySet(3, 9)}, 0, -10.5},
+		{"forgotten day returns", []types.CloudCostSet{daySet(1, 120), daySet(2, 52)}, 1, 2},
+	}
+	for _, step := range steps {
+		if got := r.observe(step.sets, now); got != step.want {
+			t.Errorf("%s: observe() = %d, want %d", step.name, got, step.want)
+		}
+		if _, delta := r.snapshot(); delta[0] != step.wantDelta {
+			t.Errorf("%s: delta = %v, want %v", step.name, delta[0], step.wantDelta)
+		}
+	}
+	if count, _ := r.snapshot(); count[0] != 2 {
+		t.Errorf("count = %v, want 2", count[0])
+	}
+}
+
+func TestCloudCostCollector_Restatements(t *testing.T) {
+	const want = `
+# HELP aws_cloud_cost_restated_total Number of ended cost windows whose totals changed by more than the restatement threshold when fetched again
+# TYPE aws_cloud_cost_restated_total counter
+aws_cloud_cost_restated_total{cost_type="amortized"} 1
+aws_cloud_cost_restated_total{cost_type="amortized_net"} 1
+aws_cloud_cost_restated_total{cost_type="invoiced"} 1
+aws_cloud_cost_restated_total{cost_type="list"} 1
+aws_cloud_cost_restated_total{cost_type="net"} 1
+# HELP aws_cloud_cost_restatement_delta Change in USD of the totals of the latest restated cost window
+# TYPE aws_cloud_cost_restatement_delta gauge
+aws_cloud_cost_restatement_delta{cost_type="amortized"} 25
+aws_cloud_cost_restatement_delta{cost_type="amortized_net"} 25
+aws_cloud_cost_restatement_delta{cost_type="invoiced"} 25
+aws_cloud_cost_restatement_delta{cost_type="list"} 25
+aws_cloud_cost_restatement_delta{cost_type="net"} 25
+`
+	cost := 100.0
+	fetch := func(context.Context) (*types.CloudCostResponse, error) {
+		return &types.CloudCostResponse{Code: 200, Data: types.CloudCostData{Sets: []types.CloudCostSet{daySet(1, cost)}}}, nil
+	}
+	c := newTestCollectorWithOptions(t, `{"code": 500}`, WithFetcher(fetch), WithRestatementThreshold(0.01))
+	c.fetchAndCache(context.Background())
+	cost = 125
+	c.fetchAndCache(context.Background())
+
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want), "aws_cloud_cost_restated_total", "aws_cloud_cost_restatement_delta"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,66 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// scrapeCache holds data fetched when scraped, for collectors of OpenCost
+// APIs other than the cloud cost one. Data is reused for the TTL; after a
+// failed fetch the previous data is served until it is older than the TTL
+// plus the max stale age. Concurrent scrapes wait for a single fetch.
+type scrapeCache[T any] struct {
+	name     string // for logging
+	fetch    func(ctx context.Context) (T, error)
+	ttl      time.Duration
+	maxStale time.Duration
+	timeout  time.Duration
+
+	mu        sync.Mutex
+	data      T
+	ok        bool
+	fetchedAt time.Time
+	up        bool
+}
+
+func newScrapeCache[T any](name string, fetch func(ctx context.Context) (T, error)) *scrapeCache[T] {
+	return &scrapeCache[T]{name: name, fetch: fetch, ttl: time.Hour, maxStale: 6 * time.Hour, timeout: 30 * time.Second}
+}
+
+// get returns the data to serve, if any, fetching it if it is older than
+// the TTL, and whether the last fetch succeeded.
+func (c *scrapeCache[T]) get() (data T, ok, up bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ok && time.Since(c.fetchedAt) < c.ttl {
+		return c.data, true, c.up
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	fetched, err := c.fetch(ctx)
+	if err != nil {
+		c.up = false
+		slog.WarnContext(ctx, "failed to fetch "+c.name, "error", err)
+		if c.ok && time.Since(c.fetchedAt) > c.ttl+c.maxStale {
+			var zero T
+			c.data, c.ok = zero, false
+		}
+		return c.data, c.ok, false
+	}
+	c.data, c.ok, c.fetchedAt, c.up = fetched, true, time.Now(), true
+	return c.data, true, true
+}
+
+// sendUp sends whether the last fetch succeeded as a gauge.
+func sendUp(ch chan<- prometheus.Metric, desc *prometheus.Desc, up bool) {
+	if up {
+		sendGauge(ch, desc, 1)
+	} else {
+		sendGauge(ch, desc, 0)
+	}
+}
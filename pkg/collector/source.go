@@ -0,0 +1,45 @@
+package collector
+
+import (
+	"context"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/client"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+// CostSource is the source of cloud cost data of a CloudCostCollector.
+// *client.Client, which fetches from the OpenCost API, is the default
+// implementation; others may read from elsewhere or serve fixed data in
+// tests.
+//
+// A source may also implement the unexported interfaces below, as
+// *client.Client does, to report its query window, exchange rates and
+// request statistics; the metrics of those it does not implement are not
+// emitted.
+type CostSource interface {
+	// FetchCloudCosts fetches the cloud costs of the configured window.
+	FetchCloudCosts(ctx context.Context) (*types.CloudCostResponse, error)
+	// Ping checks that the source is reachable.
+	Ping(ctx context.Context) error
+}
+
+var _ CostSource = (*client.Client)(nil)
+
+// windowSource reports the query window of a CostSource.
+type windowSource interface {
+	Window() string
+	WindowBounds() (types.Window, error)
+}
+
+// exchangeRateSource fetches the exchange rates of the currency metrics.
+type exchangeRateSource interface {
+	FetchExchangeRates(ctx context.Context, base string, symbols []string) (*types.ExchangeRateResponse, error)
+}
+
+// requestStatsSource reports the retries, throttling and response sizes
+// of the requests of a CostSource.
+type requestStatsSource interface {
+	RetryStats() client.RetryStats
+	Transfers() client.TransferStats
+	Throttling(target string) client.ThrottleStats
+}
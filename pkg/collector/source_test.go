@@ -0,0 +1,51 @@
+package collector
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/cache"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/opencosttest"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+// fakeSource serves fixed data and implements none of the optional
+// interfaces of CostSource.
+type fakeSource struct {
+	data *types.CloudCostResponse
+}
+
+func (s fakeSource) FetchCloudCosts(context.Context) (*types.CloudCostResponse, error) {
+	return s.data, nil
+}
+
+func (s fakeSource) Ping(context.Context) error { return nil }
+
+func TestNew_CostSource(t *testing.T) {
+	data := opencosttest.Response(opencosttest.Item("111", "AmazonEC2", "Compute", 1.5))
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(New(fakeSource{data: data}, cache.New(time.Hour, time.Hour)))
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	got := make(map[string]bool)
+	for _, mf := range families {
+		got[mf.GetName()] = true
+	}
+	if !got["aws_cloud_cost_total"] {
+		t.Errorf("cost metrics missing, got %v", got)
+	}
+	for name := range got {
+		for _, optional := range []string{"exchange_rate", "retries", "throttled", "response_bytes", "query_window"} {
+			if strings.Contains(name, optional) {
+				t.Errorf("%s emitted for a source that does not report it", name)
+			}
+		}
+	}
+}
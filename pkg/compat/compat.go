@@ -0,0 +1,129 @@
+// Package compat serves renamed metrics under their previous names as well,
+// so that dashboards and alerts can move to the new names during a
+// transition period instead of all at once. The metrics are registered under
+// their new names only; the old names are copies added as they are gathered.
+// Renames not made yet are served the other way round: the metric keeps its
+// old name, and the new name is the copy.
+package compat
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+)
+
+// Rename records a metric renamed in a release, or one of its labels.
+type Rename struct {
+	// Old and New are the previous and the current metric name.
+	Old string
+	New string
+	// Labels maps the label names of New to those of Old.
+	Labels map[string]string
+	// Upcoming marks a rename not made yet, which would break the queries
+	// of the old name: the metric is still registered under Old, and New
+	// is served as a copy for queries to move to ahead of the rename.
+	Upcoming bool
+}
+
+// Renames are the renames the old names, or for upcoming renames the new
+// names, are served for. Entries are removed once the old names have been
+// deprecated for a release; the changelog records each rename.
+var Renames = []Rename{
+	{
+		Old:      "cloudcost_exporter_last_successful_scrape_timestamp",
+		New:      "cloudcost_exporter_last_successful_scrape_timestamp_seconds",
+		Upcoming: true,
+	},
+	{
+		Old: "cloudcost_exporter_retries_total",
+		New: "cloudcost_exporter_client_retries_total",
+	},
+}
+
+// alias is the copy of a registered metric under another name.
+type alias struct {
+	name   string
+	help   string            // prefix of the help text
+	labels map[string]string // registered label names to those of the copy
+}
+
+type gatherer struct {
+	prometheus.Gatherer
+
+	aliases map[string]alias // by registered name
+}
+
+// Wrap returns a gatherer serving the metrics gathered by g plus, for every
+// metric renamed by renames, a copy under its old name and old label names.
+// The copies' help text names the metric replacing them. For an upcoming
+// rename, the copy is of the metric registered under the old name, under
+// the new name and label names, its help text naming the metric it will
+// replace.
+func Wrap(g prometheus.Gatherer, renames []Rename) prometheus.Gatherer {
+	if len(renames) == 0 {
+		return g
+	}
+	w := &gatherer{Gatherer: g, aliases: make(map[string]alias, len(renames))}
+	for _, r := range renames {
+		if !r.Upcoming {
+			w.aliases[r.New] = alias{name: r.Old, help: "Deprecated: use " + r.New + ". ", labels: r.Labels}
+			continue
+		}
+		labels := make(map[string]string, len(r.Labels))
+		for newName, oldName := range r.Labels {
+			labels[oldName] = newName
+		}
+		w.aliases[r.Old] = alias{name: r.New, help: "Upcoming name of " + r.Old + ". ", labels: labels}
+	}
+	return w
+}
+
+// Gather implements prometheus.Gatherer.
+func (g *gatherer) Gather() ([]*dto.MetricFamily, error) {
+	// Gather returns what it could gather along with an error.
+	families, err := g.Gatherer.Gather()
+	names := make(map[string]bool, len(families))
+	for _, mf := range families {
+		names[mf.GetName()] = true
+	}
+	out := families
+	for _, mf := range families {
+		a, ok := g.aliases[mf.GetName()]
+		// Never shadow a metric registered under the copy's name.
+		if !ok || names[a.name] {
+			continue
+		}
+		out = append(out, aliasFamily(mf, a))
+	}
+	if len(out) == len(families) {
+		return families, err
+	}
+	slices.SortFunc(out, func(a, b *dto.MetricFamily) int {
+		return strings.Compare(a.GetName(), b.GetName())
+	})
+	return out, err
+}
+
+// aliasFamily returns a copy of mf under the name and label names of a.
+func aliasFamily(mf *dto.MetricFamily, a alias) *dto.MetricFamily {
+	old := proto.Clone(mf).(*dto.MetricFamily)
+	old.Name = proto.String(a.name)
+	old.Help = proto.String(a.help + mf.GetHelp())
+	if len(a.labels) == 0 {
+		return old
+	}
+	for _, m := range old.Metric {
+		for _, l := range m.Label {
+			if name, ok := a.labels[l.GetName()]; ok {
+				l.Name = proto.String(name)
+			}
+		}
+		slices.SortFunc(m.Label, func(a, b *dto.LabelPair) int {
+			return strings.Compare(a.GetName(), b.GetName())
+		})
+	}
+	return old
+}
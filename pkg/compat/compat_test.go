@@ -0,0 +1,131 @@
+package compat
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newRegistry() *prometheus.Registry {
+	reg := prometheus.NewPedanticRegistry()
+	cost := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "cloud_cost_total", Help: "Cost"}, []string{"service", "account"})
+	cost.WithLabelValues("AmazonEC2", "123").Set(5)
+	last := prometheus.NewGauge(prometheus.GaugeOpts{Name: "last_timestamp_seconds", Help: "Last", ConstLabels: prometheus.Labels{"cluster": "prod"}})
+	last.Set(1700000000)
+	reg.MustRegister(cost, last)
+	return reg
+}
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		name    string
+		renames []Rename
+		want    string
+	}{
+		{
+			name: "no renames",
+			want: `
+# HELP cloud_cost_total Cost
+# TYPE cloud_cost_total gauge
+cloud_cost_total{account="123",service="AmazonEC2"} 5
+# HELP last_timestamp_seconds Last
+# TYPE last_timestamp_seconds gauge
+last_timestamp_seconds{cluster="prod"} 1.7e+09
+`,
+		},
+		{
+			name:    "metric",
+			renames: []Rename{{Old: "last_timestamp", New: "last_timestamp_seconds"}},
+			want: `
+# HELP cloud_cost_total Cost
+# TYPE cloud_cost_total gauge
+cloud_cost_total{account="123",service="AmazonEC2"} 5
+# HELP last_timestamp Deprecated: use last_timestamp_seconds. Last
+# TYPE last_timestamp gauge
+last_timestamp{cluster="prod"} 1.7e+09
+# HELP last_timestamp_seconds Last
+# TYPE last_timestamp_seconds gauge
+last_timestamp_seconds{cluster="prod"} 1.7e+09
+`,
+		},
+		{
+			name: "namespace and label",
+			renames: []Rename{{
+				Old:    "aws_cloud_cost_total",
+				New:    "cloud_cost_total",
+				Labels: map[string]string{"account": "z_account_id"},
+			}},
+			want: `
+# HELP aws_cloud_cost_total Deprecated: use cloud_cost_total. Cost
+# TYPE aws_cloud_cost_total gauge
+aws_cloud_cost_total{service="AmazonEC2",z_account_id="123"} 5
+# HELP cloud_cost_total Cost
+# TYPE cloud_cost_total gauge
+cloud_cost_total{account="123",service="AmazonEC2"} 5
+# HELP last_timestamp_seconds Last
+# TYPE last_timestamp_seconds gauge
+last_timestamp_seconds{cluster="prod"} 1.7e+09
+`,
+		},
+		{
+			name: "upcoming",
+			renames: []Rename{{
+				Old:      "cloud_cost_total",
+				New:      "cloud_cost",
+				Labels:   map[string]string{"account_id": "account"},
+				Upcoming: true,
+			}},
+			want: `
+# HELP cloud_cost Upcoming name of cloud_cost_total. Cost
+# TYPE cloud_cost gauge
+cloud_cost{account_id="123",service="AmazonEC2"} 5
+# HELP cloud_cost_total Cost
+# TYPE cloud_cost_total gauge
+cloud_cost_total{account="123",service="AmazonEC2"} 5
+# HELP last_timestamp_seconds Last
+# TYPE last_timestamp_seconds gauge
+last_timestamp_seconds{cluster="prod"} 1.7e+09
+`,
+		},
+		{
+			name:    "old name still registered",
+			renames: []Rename{{Old: "cloud_cost_total", New: "last_timestamp_seconds"}},
+			want: `
+# HELP cloud_cost_total Cost
+# TYPE cloud_cost_total gauge
+cloud_cost_total{account="123",service="AmazonEC2"} 5
+# HELP last_timestamp_seconds Last
+# TYPE last_timestamp_seconds gauge
+last_timestamp_seconds{cluster="prod"} 1.7e+09
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := Wrap(newRegistry(), tt.renames)
+			if err := testutil.GatherAndCompare(g, strings.NewReader(tt.want)); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestWrap_DoesNotChangeGathered(t *testing.T) {
+	reg := newRegistry()
+	g := Wrap(reg, []Rename{{Old: "aws_cloud_cost_total", New: "cloud_cost_total", Labels: map[string]string{"account": "account_id"}}})
+	for range 2 {
+		if _, err := g.Gather(); err != nil {
+			t.Fatalf("Gather() error = %v", err)
+		}
+	}
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	if got := families[0].Metric[0].Label[0].GetName(); got != "account" {
+		t.Errorf("label = %q, want the registered label unchanged", got)
+	}
+}
@@ -0,0 +1,122 @@
+// Package configwatch reads a configuration directory as mounted from a
+// Kubernetes ConfigMap or Secret and reports when its contents change, so
+// GitOps configuration changes can be applied without a pod restart.
+package configwatch
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Read returns the settings in dir, keyed by file name. Hidden files, such
+// as the "..data" symlink Kubernetes uses for atomic updates, and
+// directories are skipped. Values are trimmed of surrounding whitespace.
+func Read(dir string) (map[string]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read config directory: %w", err)
+	}
+	values := make(map[string]string, len(entries))
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		// ConfigMap keys are symlinks into the "..data" directory.
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("stat %s: %w", e.Name(), err)
+		}
+		if info.IsDir() {
+			continue
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", e.Name(), err)
+		}
+		values[e.Name()] = strings.TrimSpace(string(b))
+	}
+	return values, nil
+}
+
+// Watcher calls a function whenever the contents of a directory change.
+type Watcher struct {
+	dir      string
+	debounce time.Duration
+	onChange func()
+}
+
+// Option is a functional option for configuring the Watcher.
+type Option func(*Watcher)
+
+// WithDebounce sets how long the directory must be quiet before onChange
+// runs. Kubernetes updates a mounted volume with several file operations,
+// which are coalesced into one call.
+func WithDebounce(d time.Duration) Option {
+	return func(w *Watcher) {
+		w.debounce = d
+	}
+}
+
+// New creates a Watcher for dir.
+func New(dir string, onChange func(), opts ...Option) *Watcher {
+	w := &Watcher{
+		dir:      dir,
+		debounce: time.Second,
+		onChange: onChange,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// Run watches the directory until ctx is canceled. The directory itself is
+// watched rather than individual files, because Kubernetes replaces the
+// files by swapping a symlink.
+func (w *Watcher) Run(ctx context.Context) error {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("create watcher: %w", err)
+	}
+	defer fw.Close()
+	if err := fw.Add(w.dir); err != nil {
+		return fmt.Errorf("watch %s: %w", w.dir, err)
+	}
+
+	timer := time.NewTimer(0)
+	if !timer.Stop() {
+		<-timer.C
+	}
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-fw.Events:
+			if !ok {
+				return nil
+			}
+			if ev.Has(fsnotify.Chmod) {
+				continue
+			}
+			slog.Debug("config directory changed", "event", ev.String())
+			timer.Reset(w.debounce)
+		case err, ok := <-fw.Errors:
+			if !ok {
+				return nil
+			}
+			slog.Warn("config directory watch error", "error", err)
+		case <-timer.C:
+			w.onChange()
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package configwatch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// writeAtomic mimics how the kubelet updates a mounted ConfigMap: the new
+// files are written to a fresh directory and the "..data" symlink is
+// swapped to point at it.
+func writeAtomic(t *testing.T, dir, version string, files map[string]string) {
+	t.Helper()
+	ts := filepath.Join(dir, "..ts_"+version)
+	if err := os.Mkdir(ts, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(ts, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		link := filepath.Join(dir, name)
+		if _, err := os.Lstat(link); os.IsNotExist(err) {
+			if err := os.Symlink(filepath.Join("..data", name), link); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	tmp := filepath.Join(dir, "..data_tmp")
+	if err := os.Symlink(filepath.Base(ts), tmp); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  map[string]string
+	}{
+		{
+			name:  "trims values",
+			files: map[string]string{"LOG_LEVEL": "debug\n", "CACHE_TTL": "  30m "},
+			want:  map[string]string{"LOG_LEVEL": "debug", "CACHE_TTL": "30m"},
+		},
+		{
+			name:  "skips hidden files",
+			files: map[string]string{".hidden": "x", "WINDOW": "7d"},
+			want:  map[string]string{"WINDOW": "7d"},
+		},
+		{
+			name:  "empty directory",
+			files: map[string]string{},
+			want:  map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := Read(dir)
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Read() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRead_ConfigMapLayout(t *testing.T) {
+	dir := t.TempDir()
+	writeAtomic(t, dir, "1", map[string]string{"LOG_LEVEL": "debug"})
+
+	got, err := Read(dir)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if want := map[string]string{"LOG_LEVEL": "debug"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Read() = %v, want %v", got, want)
+	}
+}
+
+func TestRead_MissingDirectory(t *testing.T) {
+	if _, err := Read(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Read() should fail for a missing directory")
+	}
+}
+
+func TestWatcher_Run(t *testing.T) {
+	dir := t.TempDir()
+	writeAtomic(t, dir, "1", map[string]string{"LOG_LEVEL": "info"})
+
+	changes := make(chan struct{}, 10)
+	w := New(dir, func() { changes <- struct{}{} }, WithDebounce(50*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- w.Run(ctx) }()
+	// Give the watcher time to register before changing the directory.
+	time.Sleep(100 * time.Millisecond)
+
+	writeAtomic(t, dir, "2", map[string]string{"LOG_LEVEL": "debug"})
+
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("onChange was not called")
+	}
+	got, err := Read(dir)
+	if err != nil || got["LOG_LEVEL"] != "debug" {
+		t.Errorf("Read() after update = %v, %v", got, err)
+	}
+
+	// The symlink swap produces several events but only one call.
+	select {
+	case <-changes:
+		t.Error("onChange called more than once for a single update")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Run() error = %v", err)
+	}
+}
+
+func TestWatcher_RunMissingDirectory(t *testing.T) {
+	w := New(filepath.Join(t.TempDir(), "missing"), func() {})
+	if err := w.Run(context.Background()); err == nil {
+		t.Error("Run() should fail for a missing directory")
+	}
+}
@@ -0,0 +1,270 @@
+// Package costapi serves the cached cost data as JSON, summed by the
+// properties a request groups by, for consumers such as billing portals
+// that want totals without scraping and re-aggregating the metrics, and as
+// a CSV table for spreadsheets.
+package costapi
+
+import (
+	"cmp"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/collector"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types/focus"
+)
+
+// The paths the handler is served at.
+const (
+	Path    = "/api/v1/costs"
+	CSVPath = Path + ".csv"
+)
+
+// csvGroupBy are the properties the rows of the CSV table are summed by.
+var csvGroupBy = []string{"accountID", "service", "category"}
+
+// WindowStart is the group_by dimension of the start of a cost set, e.g.
+// the day with daily sets.
+const WindowStart = "windowStart"
+
+// Source returns the cost data, fetching it if none is cached yet.
+type Source func(context.Context) (*types.CloudCostResponse, bool)
+
+// Costs holds the cost of each cost type.
+type Costs struct {
+	List         float64 `json:"list"`
+	Net          float64 `json:"net"`
+	AmortizedNet float64 `json:"amortizedNet"`
+	Invoiced     float64 `json:"invoiced"`
+	Amortized    float64 `json:"amortized"`
+}
+
+// byType returns the cost of a cost type of types.CostTypes.
+func (c Costs) byType(costType string) float64 {
+	switch costType {
+	case "list":
+		return c.List
+	case "net":
+		return c.Net
+	case "amortized_net":
+		return c.AmortizedNet
+	case "invoiced":
+		return c.Invoiced
+	default:
+		return c.Amortized
+	}
+}
+
+func (c *Costs) add(item types.CloudCostItem) {
+	c.List += item.ListCost.Cost
+	c.Net += item.NetCost.Cost
+	c.AmortizedNet += item.AmortizedNetCost.Cost
+	c.Invoiced += item.InvoicedCost.Cost
+	c.Amortized += item.AmortizedCost.Cost
+}
+
+// Row is the cost of the items sharing the values of the group_by
+// properties.
+type Row struct {
+	Group map[string]string `json:"group"`
+	Items int               `json:"items"`
+	Costs Costs             `json:"costs"`
+}
+
+// Response is the body of a successful request.
+type Response struct {
+	Window  types.Window `json:"window"`
+	GroupBy []string     `json:"groupBy"`
+	Total   Costs        `json:"total"`
+	Rows    []Row        `json:"rows"`
+}
+
+// Handler serves the cost summary.
+type Handler struct {
+	data Source
+}
+
+// New creates a handler summarizing the cost data of data.
+func New(data Source) *Handler {
+	return &Handler{data: data}
+}
+
+// ServeHTTP implements http.Handler. The query parameters are:
+//
+//   - group_by: comma-separated properties as in --aggregate, e.g.
+//     accountID,service or label:team, or windowStart for one row per cost
+//     set. Without it, the response has a single row of the total.
+//   - service, accountID, category: a regex the property must match, or
+//     with a "!" prefix must not match, as in --filter-service.
+//   - labels: label matchers as in --filter-labels, e.g. team=~platform|data.
+//
+// Rows are ordered by amortized net cost, most expensive first.
+//
+// At CSVPath, the items are summed by account, service and category
+// instead, with a line per cost type of each row under the header
+// account_id,service,category,cost_type,amount,currency. group_by is
+// ignored there.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	asCSV := strings.HasSuffix(r.URL.Path, ".csv")
+	groupBy := csvGroupBy
+	if !asCSV {
+		var err error
+		if groupBy, err = parseGroupBy(query.Get("group_by")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	filter, err := parseFilter(query.Get("service"), query.Get("accountID"), query.Get("category"), query.Get("labels"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	data, ok := h.data(r.Context())
+	if !ok {
+		http.Error(w, "no cloud cost data available", http.StatusServiceUnavailable)
+		return
+	}
+	resp := summarize(data, groupBy, filter)
+	if asCSV {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="costs.csv"`)
+		err = writeCSV(w, resp)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(resp)
+	}
+	if err != nil {
+		slog.Debug("failed to write cost summary", "error", err)
+	}
+}
+
+// writeCSV writes the rows of resp, grouped by csvGroupBy, as a CSV table
+// with a line per cost type.
+func writeCSV(w io.Writer, resp Response) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"account_id", "service", "category", "cost_type", "amount", "currency"})
+	for _, row := range resp.Rows {
+		for _, costType := range types.CostTypes {
+			cw.Write([]string{
+				row.Group["accountID"], row.Group["service"], row.Group["category"],
+				costType, formatAmount(row.Costs.byType(costType)), focus.Currency,
+			})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// formatAmount formats a cost without the floating-point noise of the
+// sums, e.g. 1125.6 rather than 1125.6000000000001.
+func formatAmount(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1e9)/1e9, 'f', -1, 64)
+}
+
+// parseGroupBy parses the group_by parameter.
+func parseGroupBy(s string) ([]string, error) {
+	groupBy := []string{}
+	for name := range strings.SplitSeq(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := (types.CloudCostProperties{}).Property(name); !ok && name != WindowStart {
+			return nil, fmt.Errorf("invalid group_by %q: want a property such as accountID, service, category, regionID or label:<name>, or %s", name, WindowStart)
+		}
+		if !slices.Contains(groupBy, name) {
+			groupBy = append(groupBy, name)
+		}
+	}
+	return groupBy, nil
+}
+
+// parseFilter parses the filter parameters.
+func parseFilter(service, account, category, labels string) (collector.Filter, error) {
+	var f collector.Filter
+	var err error
+	if f.Service, err = collector.ParseMatcher(service); err != nil {
+		return f, fmt.Errorf("service: %w", err)
+	}
+	if f.Account, err = collector.ParseMatcher(account); err != nil {
+		return f, fmt.Errorf("accountID: %w", err)
+	}
+	if f.Category, err = collector.ParseMatcher(category); err != nil {
+		return f, fmt.Errorf("category: %w", err)
+	}
+	if f.Labels, err = collector.ParseLabelMatchers(labels); err != nil {
+		return f, fmt.Errorf("labels: %w", err)
+	}
+	return f, nil
+}
+
+// summarize sums the items of data passing filter by the values of the
+// groupBy properties.
+func summarize(data *types.CloudCostResponse, groupBy []string, filter collector.Filter) Response {
+	resp := Response{GroupBy: groupBy, Rows: []Row{}}
+	rows := make(map[string]*Row)
+	for _, set := range data.Data.Sets {
+		bounds, start := set.Bounds(), ""
+		if !bounds.Start.IsZero() {
+			start = bounds.Start.Format(time.RFC3339)
+			if resp.Window.Start.IsZero() || bounds.Start.Before(resp.Window.Start) {
+				resp.Window.Start = bounds.Start
+			}
+			if bounds.End.After(resp.Window.End) {
+				resp.Window.End = bounds.End
+			}
+		}
+		for _, item := range set.CloudCosts {
+			if !filter.Matches(item) {
+				continue
+			}
+			values := make([]string, len(groupBy))
+			for i, name := range groupBy {
+				if name == WindowStart {
+					values[i] = start
+				} else {
+					values[i], _ = item.Properties.Property(name)
+				}
+			}
+			key := strings.Join(values, "\x00")
+			row, ok := rows[key]
+			if !ok {
+				row = &Row{Group: make(map[string]string, len(groupBy))}
+				for i, name := range groupBy {
+					row.Group[name] = values[i]
+				}
+				rows[key] = row
+			}
+			row.Items++
+			row.Costs.add(item)
+			resp.Total.add(item)
+		}
+	}
+
+	keys := make([]string, 0, len(rows))
+	for key := range rows {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		if n := cmp.Compare(rows[b].Costs.AmortizedNet, rows[a].Costs.AmortizedNet); n != 0 {
+			return n
+		}
+		return strings.Compare(a, b)
+	})
+	for _, key := range keys {
+		resp.Rows = append(resp.Rows, *rows[key])
+	}
+	return resp
+}
@@ -0,0 +1,152 @@
+package costapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/opencosttest"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+func day(d int) time.Time {
+	return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC)
+}
+
+func testData() *types.CloudCostResponse {
+	ec2 := opencosttest.Item("111", "AmazonEC2", "Compute", 10)
+	ec2.Properties.Labels = map[string]string{"team": "platform"}
+	return &types.CloudCostResponse{Data: types.CloudCostData{Sets: []types.CloudCostSet{
+		{
+			Window: types.Window{Start: day(1), End: day(2)},
+			CloudCosts: map[string]types.CloudCostItem{
+				"a": ec2,
+				"b": opencosttest.Item("222", "AmazonS3", "Storage", 5),
+			},
+		},
+		{
+			Window: types.Window{Start: day(2), End: day(3)},
+			CloudCosts: map[string]types.CloudCostItem{
+				"a": ec2,
+				"c": opencosttest.Item("111", "AmazonS3", "Storage", 1),
+			},
+		},
+	}}}
+}
+
+func get(t *testing.T, h http.Handler, query url.Values) (int, Response) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, Path+"?"+query.Encode(), nil))
+	var resp Response
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+	}
+	return rec.Code, resp
+}
+
+func TestHandler(t *testing.T) {
+	h := New(func(context.Context) (*types.CloudCostResponse, bool) { return testData(), true })
+
+	type row struct {
+		group        map[string]string
+		amortizedNet float64
+	}
+	tests := []struct {
+		name  string
+		query url.Values
+		total float64
+		rows  []row
+	}{
+		{"total", url.Values{}, 26, []row{{map[string]string{}, 26}}},
+		{"by service", url.Values{"group_by": {"service"}}, 26, []row{
+			{map[string]string{"service": "AmazonEC2"}, 20},
+			{map[string]string{"service": "AmazonS3"}, 6},
+		}},
+		{"by day and account", url.Values{"group_by": {"windowStart,accountID"}, "service": {"!AmazonS3"}}, 20, []row{
+			{map[string]string{"windowStart": "2024-01-01T00:00:00Z", "accountID": "111"}, 10},
+			{map[string]string{"windowStart": "2024-01-02T00:00:00Z", "accountID": "111"}, 10},
+		}},
+		{"by label", url.Values{"group_by": {"label:team"}, "accountID": {"111"}}, 21, []row{
+			{map[string]string{"label:team": "platform"}, 20},
+			{map[string]string{"label:team": ""}, 1},
+		}},
+		{"label filter", url.Values{"labels": {"team=platform"}}, 20, []row{{map[string]string{}, 20}}},
+		{"nothing matches", url.Values{"category": {"Network"}}, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, resp := get(t, h, tt.query)
+			if code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", code)
+			}
+			if resp.Total.AmortizedNet != tt.total {
+				t.Errorf("total = %v, want %v", resp.Total.AmortizedNet, tt.total)
+			}
+			var rows []row
+			for _, r := range resp.Rows {
+				rows = append(rows, row{r.Group, r.Costs.AmortizedNet})
+			}
+			if !reflect.DeepEqual(rows, tt.rows) {
+				t.Errorf("rows = %v, want %v", rows, tt.rows)
+			}
+			if want := (types.Window{Start: day(1), End: day(3)}); resp.Window != want {
+				t.Errorf("window = %v, want %v", resp.Window, want)
+			}
+		})
+	}
+}
+
+func TestHandler_Errors(t *testing.T) {
+	h := New(func(context.Context) (*types.CloudCostResponse, bool) { return testData(), true })
+	for _, query := range []url.Values{
+		{"group_by": {"team"}},
+		{"group_by": {"label:"}},
+		{"service": {"Amazon("}},
+		{"labels": {"team"}},
+	} {
+		if code, _ := get(t, h, query); code != http.StatusBadRequest {
+			t.Errorf("%v: status = %d, want 400", query, code)
+		}
+	}
+
+	empty := New(func(context.Context) (*types.CloudCostResponse, bool) { return nil, false })
+	if code, _ := get(t, empty, url.Values{}); code != http.StatusServiceUnavailable {
+		t.Errorf("status without data = %d, want 503", code)
+	}
+}
+
+func TestHandler_CSV(t *testing.T) {
+	h := New(func(context.Context) (*types.CloudCostResponse, bool) { return testData(), true })
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, CSVPath+"?group_by=service&service=AmazonS3", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "text/csv; charset=utf-8" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	// group_by is ignored; the rows are by account, service and category.
+	want := `account_id,service,category,cost_type,amount,currency
+222,AmazonS3,Storage,list,5,USD
+222,AmazonS3,Storage,net,5,USD
+222,AmazonS3,Storage,amortized_net,5,USD
+222,AmazonS3,Storage,invoiced,5,USD
+222,AmazonS3,Storage,amortized,5,USD
+111,AmazonS3,Storage,list,1,USD
+111,AmazonS3,Storage,net,1,USD
+111,AmazonS3,Storage,amortized_net,1,USD
+111,AmazonS3,Storage,invoiced,1,USD
+111,AmazonS3,Storage,amortized,1,USD
+`
+	if got := rec.Body.String(); got != want {
+		t.Errorf("CSV =\n%s\nwant\n%s", got, want)
+	}
+}
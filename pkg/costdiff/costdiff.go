@@ -0,0 +1,147 @@
+// Package costdiff compares cloud costs between two windows and reports
+// per-group deltas.
+package costdiff
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+// Dimensions lists the properties that rows can be grouped by.
+var Dimensions = []string{"provider", "account_id", "service", "category", "region"}
+
+// Options controls how the windows are compared.
+type Options struct {
+	// CostType is the cost compared (default amortized_net).
+	CostType string
+	// GroupBy lists the dimensions rows are grouped by (default account_id, service).
+	GroupBy []string
+}
+
+// Row is the cost of one group in both windows.
+type Row struct {
+	Group []string
+	A     float64
+	B     float64
+}
+
+// Delta returns the change from window B to window A.
+func (r Row) Delta() float64 {
+	return r.A - r.B
+}
+
+// Percent returns the relative change from window B to window A, or +Inf
+// when the group has no cost in window B.
+func (r Row) Percent() float64 {
+	if r.B == 0 {
+		if r.A == 0 {
+			return 0
+		}
+		return math.Inf(1)
+	}
+	return r.Delta() / math.Abs(r.B) * 100
+}
+
+// Compute groups both responses and returns one row per group, sorted by
+// absolute change, largest first.
+func Compute(a, b *types.CloudCostResponse, opts Options) ([]Row, error) {
+	if opts.CostType == "" {
+		opts.CostType = "amortized_net"
+	}
+	if len(opts.GroupBy) == 0 {
+		opts.GroupBy = []string{"account_id", "service"}
+	}
+	if !slices.Contains(types.CostTypes, opts.CostType) {
+		return nil, fmt.Errorf("unknown cost type %q (want one of %s)", opts.CostType, strings.Join(types.CostTypes, ", "))
+	}
+	for _, d := range opts.GroupBy {
+		if !slices.Contains(Dimensions, d) {
+			return nil, fmt.Errorf("unknown dimension %q (want one of %s)", d, strings.Join(Dimensions, ", "))
+		}
+	}
+
+	rows := make(map[string]*Row)
+	add := func(resp *types.CloudCostResponse, set func(*Row, float64)) {
+		if resp == nil {
+			return
+		}
+		for _, s := range resp.Data.Sets {
+			for _, item := range s.CloudCosts {
+				group := groupOf(item.Properties, opts.GroupBy)
+				key := strings.Join(group, "\x00")
+				r, ok := rows[key]
+				if !ok {
+					r = &Row{Group: group}
+					rows[key] = r
+				}
+				cost, _ := item.CostByType(opts.CostType)
+				set(r, cost.Cost)
+			}
+		}
+	}
+	add(a, func(r *Row, v float64) { r.A += v })
+	add(b, func(r *Row, v float64) { r.B += v })
+
+	out := make([]Row, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, *r)
+	}
+	slices.SortFunc(out, func(x, y Row) int {
+		if c := cmp.Compare(math.Abs(y.Delta()), math.Abs(x.Delta())); c != 0 {
+			return c
+		}
+		return slices.Compare(x.Group, y.Group)
+	})
+	return out, nil
+}
+
+// Write prints rows as an aligned table with the given group headers.
+func Write(w io.Writer, groupBy []string, rows []Row) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	header := append(slices.Clone(groupBy), "WINDOW_A", "WINDOW_B", "DELTA", "CHANGE")
+	fmt.Fprintln(tw, strings.ToUpper(strings.Join(header, "\t"))+"\t")
+
+	var totalA, totalB float64
+	for _, r := range rows {
+		totalA += r.A
+		totalB += r.B
+		fmt.Fprintf(tw, "%s\t%.2f\t%.2f\t%+.2f\t%s\t\n", strings.Join(r.Group, "\t"), r.A, r.B, r.Delta(), formatPercent(r.Percent()))
+	}
+	total := Row{A: totalA, B: totalB}
+	pad := strings.Repeat("\t", max(len(groupBy)-1, 0))
+	fmt.Fprintf(tw, "TOTAL%s\t%.2f\t%.2f\t%+.2f\t%s\t\n", pad, total.A, total.B, total.Delta(), formatPercent(total.Percent()))
+	return tw.Flush()
+}
+
+func formatPercent(p float64) string {
+	if math.IsInf(p, 1) {
+		return "new"
+	}
+	return fmt.Sprintf("%+.1f%%", p)
+}
+
+func groupOf(p types.CloudCostProperties, dims []string) []string {
+	group := make([]string, len(dims))
+	for i, d := range dims {
+		switch d {
+		case "provider":
+			group[i] = p.Provider
+		case "account_id":
+			group[i] = p.AccountID
+		case "service":
+			group[i] = p.Service
+		case "category":
+			group[i] = p.Category
+		case "region":
+			group[i] = p.RegionID
+		}
+	}
+	return group
+}
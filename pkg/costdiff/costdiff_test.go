@@ -0,0 +1,111 @@
+package costdiff
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+func response(items map[string]types.CloudCostItem) *types.CloudCostResponse {
+	return &types.CloudCostResponse{Data: types.CloudCostData{Sets: []types.CloudCostSet{{CloudCosts: items}}}}
+}
+
+func item(account, service string, cost float64) types.CloudCostItem {
+	return types.CloudCostItem{
+		Properties:       types.CloudCostProperties{AccountID: account, Service: service},
+		ListCost:         types.CostValue{Cost: cost * 2},
+		AmortizedNetCost: types.CostValue{Cost: cost},
+	}
+}
+
+func TestCompute(t *testing.T) {
+	a := response(map[string]types.CloudCostItem{
+		"1": item("111", "AmazonEC2", 150),
+		"2": item("111", "AmazonS3", 20),
+		"3": item("222", "AmazonRDS", 40),
+	})
+	b := response(map[string]types.CloudCostItem{
+		"1": item("111", "AmazonEC2", 100),
+		"2": item("111", "AmazonS3", 25),
+		"4": item("222", "AWSLambda", 10),
+	})
+
+	rows, err := Compute(a, b, Options{})
+	if err != nil {
+		t.Fatalf("Compute() error = %v", err)
+	}
+
+	want := []struct {
+		group string
+		delta float64
+	}{
+		{"111/AmazonEC2", 50},
+		{"222/AmazonRDS", 40},
+		{"222/AWSLambda", -10},
+		{"111/AmazonS3", -5},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("got %d rows, want %d", len(rows), len(want))
+	}
+	for i, w := range want {
+		if got := strings.Join(rows[i].Group, "/"); got != w.group {
+			t.Errorf("row %d group = %s, want %s", i, got, w.group)
+		}
+		if rows[i].Delta() != w.delta {
+			t.Errorf("row %d delta = %v, want %v", i, rows[i].Delta(), w.delta)
+		}
+	}
+	if !math.IsInf(rows[1].Percent(), 1) {
+		t.Errorf("new group percent = %v, want +Inf", rows[1].Percent())
+	}
+	if rows[0].Percent() != 50 {
+		t.Errorf("percent = %v, want 50", rows[0].Percent())
+	}
+}
+
+func TestCompute_Options(t *testing.T) {
+	a := response(map[string]types.CloudCostItem{"1": item("111", "AmazonEC2", 10), "2": item("111", "AmazonS3", 5)})
+	b := response(map[string]types.CloudCostItem{"1": item("111", "AmazonEC2", 5)})
+
+	rows, err := Compute(a, b, Options{CostType: "list", GroupBy: []string{"account_id"}})
+	if err != nil {
+		t.Fatalf("Compute() error = %v", err)
+	}
+	if len(rows) != 1 || rows[0].A != 30 || rows[0].B != 10 {
+		t.Errorf("unexpected rows: %+v", rows)
+	}
+
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{"bad cost type", Options{CostType: "bogus"}},
+		{"bad dimension", Options{GroupBy: []string{"bogus"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Compute(a, b, tt.opts); err == nil {
+				t.Error("Compute() expected error")
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	rows := []Row{{Group: []string{"111", "AmazonEC2"}, A: 150, B: 100}}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, []string{"account_id", "service"}, rows); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{"ACCOUNT_ID", "AmazonEC2", "+50.00", "+50.0%", "TOTAL"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse_Invalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"* * * foo *",
+		"@yearly",
+	} {
+		if _, err := Parse(spec, time.UTC); err == nil {
+			t.Errorf("Parse(%q) should fail", spec)
+		}
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time zone database unavailable: %v", err)
+	}
+	utc := func(s string) time.Time {
+		t.Helper()
+		v, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	tests := []struct {
+		spec string
+		loc  *time.Location
+		from string
+		want string
+	}{
+		{"0 */6 * * *", time.UTC, "2026-10-16T07:30:00Z", "2026-10-16T12:00:00Z"},
+		{"0 */6 * * *", time.UTC, "2026-10-16T12:00:00Z", "2026-10-16T18:00:00Z"},
+		{"0 */6 * * *", time.UTC, "2026-10-16T23:59:59Z", "2026-10-17T00:00:00Z"},
+		{"*/15 * * * *", time.UTC, "2026-10-16T07:14:30Z", "2026-10-16T07:15:00Z"},
+		{"5/20 * * * *", time.UTC, "2026-10-16T07:30:00Z", "2026-10-16T07:45:00Z"},
+		{"0,30 9-17 * * mon-fri", time.UTC, "2026-10-16T17:30:00Z", "2026-10-19T09:00:00Z"},
+		{"0 0 * * 7", time.UTC, "2026-10-16T00:00:00Z", "2026-10-18T00:00:00Z"},
+		{"0 0 31 * *", time.UTC, "2026-10-31T00:00:00Z", "2026-12-31T00:00:00Z"},
+		{"0 0 29 feb *", time.UTC, "2026-10-16T00:00:00Z", "2028-02-29T00:00:00Z"},
+		// Both days restricted: either one matches.
+		{"0 0 1 * sun", time.UTC, "2026-10-16T00:00:00Z", "2026-10-18T00:00:00Z"},
+		{"@monthly", time.UTC, "2026-10-16T00:00:00Z", "2026-11-01T00:00:00Z"},
+		{"@daily", berlin, "2026-10-16T12:00:00Z", "2026-10-16T22:00:00Z"},
+		// Across the end of daylight saving time.
+		{"0 6 * * *", berlin, "2026-10-25T00:00:00Z", "2026-10-25T05:00:00Z"},
+		{"0 0 30 2 *", time.UTC, "2026-10-16T00:00:00Z", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec+" from "+tt.from, func(t *testing.T) {
+			s, err := Parse(tt.spec, tt.loc)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			got := s.Next(utc(tt.from))
+			if tt.want == "" {
+				if !got.IsZero() {
+					t.Errorf("Next() = %v, want none", got)
+				}
+				return
+			}
+			if !got.Equal(utc(tt.want)) {
+				t.Errorf("Next() = %v, want %s", got.UTC(), tt.want)
+			}
+		})
+	}
+}
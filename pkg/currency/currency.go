@@ -0,0 +1,99 @@
+// Package currency validates ISO 4217 currency codes and describes how
+// amounts in each currency are written.
+package currency
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Currency is an ISO 4217 currency.
+type Currency struct {
+	// Code is the alphabetic code, e.g. EUR.
+	Code string
+	// Symbol is the sign amounts are commonly written with, e.g. €.
+	Symbol string
+	// Decimals is the number of minor unit digits, e.g. 0 for JPY.
+	Decimals int
+}
+
+// Lookup returns the currency with the alphabetic code, in any case.
+func Lookup(code string) (Currency, bool) {
+	c, ok := currencies[strings.ToUpper(code)]
+	return c, ok
+}
+
+// Parse looks up the currencies of codes, failing on the first unknown code
+// with suggestions of what may have been meant.
+func Parse(codes []string) ([]Currency, error) {
+	out := make([]Currency, 0, len(codes))
+	for _, code := range codes {
+		c, ok := Lookup(code)
+		if !ok {
+			return nil, unknownError(code)
+		}
+		if !slices.Contains(out, c) {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+// unknownError describes why code is not a currency code.
+func unknownError(code string) error {
+	msg := fmt.Sprintf("unknown ISO 4217 currency code %q", code)
+	if suggestions := suggest(code); len(suggestions) > 0 {
+		return fmt.Errorf("%s, did you mean %s?", msg, strings.Join(suggestions, " or "))
+	}
+	return errors.New(msg)
+}
+
+// maxSuggestions caps the number of codes suggested for an unknown code.
+const maxSuggestions = 3
+
+// suggest returns the codes that code may have been meant as: the code of a
+// symbol unique to one currency, the code a longer name starts with, or the
+// codes one typo away from code.
+func suggest(code string) []string {
+	var bySymbol, byPrefix, byTypo []string
+	upper := strings.ToUpper(code)
+	for c, cur := range currencies {
+		switch {
+		case cur.Symbol == code && cur.Symbol != c:
+			bySymbol = append(bySymbol, c)
+		case len(upper) > 3 && strings.HasPrefix(upper, c):
+			byPrefix = append(byPrefix, c)
+		case len(upper) == 3 && oneTypoApart(upper, c):
+			byTypo = append(byTypo, c)
+		}
+	}
+	if len(bySymbol) == 1 {
+		return bySymbol
+	}
+	if len(byPrefix) > 0 {
+		return byPrefix
+	}
+	slices.Sort(byTypo)
+	return byTypo[:min(len(byTypo), maxSuggestions)]
+}
+
+// oneTypoApart reports whether a and b, of equal length, differ in exactly
+// one letter or by two adjacent letters swapped.
+func oneTypoApart(a, b string) bool {
+	var diffs []int
+	for i := range len(a) {
+		if a[i] != b[i] {
+			diffs = append(diffs, i)
+		}
+	}
+	switch len(diffs) {
+	case 1:
+		return true
+	case 2:
+		i, j := diffs[0], diffs[1]
+		return j == i+1 && a[i] == b[j] && a[j] == b[i]
+	}
+	return false
+}
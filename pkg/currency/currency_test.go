@@ -0,0 +1,68 @@
+package currency
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		codes   []string
+		want    []string
+		wantErr string
+	}{
+		{name: "none"},
+		{name: "codes", codes: []string{"CNY", "EUR", "JPY"}, want: []string{"CNY", "EUR", "JPY"}},
+		{name: "lower case", codes: []string{"eur"}, want: []string{"EUR"}},
+		{name: "duplicates", codes: []string{"EUR", "eur"}, want: []string{"EUR"}},
+		{name: "symbol", codes: []string{"€"}, wantErr: `unknown ISO 4217 currency code "€", did you mean EUR?`},
+		{name: "shared symbol", codes: []string{"$"}, wantErr: `unknown ISO 4217 currency code "$"`},
+		{name: "name", codes: []string{"EURO"}, wantErr: `unknown ISO 4217 currency code "EURO", did you mean EUR?`},
+		{name: "typo", codes: []string{"EUR", "JYP"}, wantErr: `unknown ISO 4217 currency code "JYP", did you mean JPY or SYP?`},
+		{name: "withdrawn", codes: []string{"DEM"}, wantErr: `unknown ISO 4217 currency code "DEM"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.codes)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Parse() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			var codes []string
+			for _, c := range got {
+				codes = append(codes, c.Code)
+			}
+			if !slices.Equal(codes, tt.want) {
+				t.Errorf("Parse() = %v, want %v", codes, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		code string
+		want Currency
+	}{
+		{"USD", Currency{"USD", "$", 2}},
+		{"JPY", Currency{"JPY", "¥", 0}},
+		{"KWD", Currency{"KWD", "د.ك", 3}},
+	}
+	for _, tt := range tests {
+		if got, ok := Lookup(tt.code); !ok || got != tt.want {
+			t.Errorf("Lookup(%q) = %v, %v, want %v", tt.code, got, ok, tt.want)
+		}
+	}
+	for code, c := range currencies {
+		if c.Code != code || len(code) != 3 || c.Symbol == "" {
+			t.Errorf("currencies[%q] = %+v", code, c)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package currency
+
+// currencies are the circulating ISO 4217 currencies, with the minor units
+// of the standard. Fund codes, precious metals and testing codes (XTS, XXX)
+// are left out. Symbols are the common local signs; where none is in wide
+// use, the code itself.
+var currencies = map[string]Currency{
+	"AED": {"AED", "د.إ", 2},
+	"AFN": {"AFN", "؋", 2},
+	"ALL": {"ALL", "L", 2},
+	"AMD": {"AMD", "֏", 2},
+	"ANG": {"ANG", "ƒ", 2},
+	"AOA": {"AOA", "Kz", 2},
+	"ARS": {"ARS", "$", 2},
+	"AUD": {"AUD", "A$", 2},
+	"AWG": {"AWG", "ƒ", 2},
+	"AZN": {"AZN", "₼", 2},
+	"BAM": {"BAM", "KM", 2},
+	"BBD": {"BBD", "$", 2},
+	"BDT": {"BDT", "৳", 2},
+	"BGN": {"BGN", "лв", 2},
+	"BHD": {"BHD", ".د.ب", 3},
+	"BIF": {"BIF", "FBu", 0},
+	"BMD": {"BMD", "$", 2},
+	"BND": {"BND", "$", 2},
+	"BOB": {"BOB", "Bs", 2},
+	"BRL": {"BRL", "R$", 2},
+	"BSD": {"BSD", "$", 2},
+	"BTN": {"BTN", "Nu.", 2},
+	"BWP": {"BWP", "P", 2},
+	"BYN": {"BYN", "Br", 2},
+	"BZD": {"BZD", "$", 2},
+	"CAD": {"CAD", "CA$", 2},
+	"CDF": {"CDF", "FC", 2},
+	"CHF": {"CHF", "CHF", 2},
+	"CLP": {"CLP", "$", 0},
+	"CNY": {"CNY", "¥", 2},
+	"COP": {"COP", "$", 2},
+	"CRC": {"CRC", "₡", 2},
+	"CUP": {"CUP", "$", 2},
+	"CVE": {"CVE", "$", 2},
+	"CZK": {"CZK", "Kč", 2},
+	"DJF": {"DJF", "Fdj", 0},
+	"DKK": {"DKK", "kr", 2},
+	"DOP": {"DOP", "RD$", 2},
+	"DZD": {"DZD", "د.ج", 2},
+	"EGP": {"EGP", "E£", 2},
+	"ERN": {"ERN", "Nfk", 2},
+	"ETB": {"ETB", "Br", 2},
+	"EUR": {"EUR", "€", 2},
+	"FJD": {"FJD", "$", 2},
+	"FKP": {"FKP", "£", 2},
+	"GBP": {"GBP", "£", 2},
+	"GEL": {"GEL", "₾", 2},
+	"GHS": {"GHS", "₵", 2},
+	"GIP": {"GIP", "£", 2},
+	"GMD": {"GMD", "D", 2},
+	"GNF": {"GNF", "FG", 0},
+	"GTQ": {"GTQ", "Q", 2},
+	"GYD": {"GYD", "$", 2},
+	"HKD": {"HKD", "HK$", 2},
+	"HNL": {"HNL", "L", 2},
+	"HTG": {"HTG", "G", 2},
+	"HUF": {"HUF", "Ft", 2},
+	"IDR": {"IDR", "Rp", 2},
+	"ILS": {"ILS", "₪", 2},
+	"INR": {"INR", "₹", 2},
+	"IQD": {"IQD", "ع.د", 3},
+	"IRR": {"IRR", "﷼", 2},
+	"ISK": {"ISK", "kr", 0},
+	"JMD": {"JMD", "$", 2},
+	"JOD": {"JOD", "د.ا", 3},
+	"JPY": {"JPY", "¥", 0},
+	"KES": {"KES", "KSh", 2},
+	"KGS": {"KGS", "с", 2},
+	"KHR": {"KHR", "៛", 2},
+	"KMF": {"KMF", "CF", 0},
+	"KPW": {"KPW", "₩", 2},
+	"KRW": {"KRW", "₩", 0},
+	"KWD": {"KWD", "د.ك", 3},
+	"KYD": {"KYD", "$", 2},
+	"KZT": {"KZT", "₸", 2},
+	"LAK": {"LAK", "₭", 2},
+	"LBP": {"LBP", "ل.ل", 2},
+	"LKR": {"LKR", "Rs", 2},
+	"LRD": {"LRD", "$", 2},
+	"LSL": {"LSL", "L", 2},
+	"LYD": {"LYD", "ل.د", 3},
+	"MAD": {"MAD", "د.م.", 2},
+	"MDL": {"MDL", "L", 2},
+	"MGA": {"MGA", "Ar", 2},
+	"MKD": {"MKD", "ден", 2},
+	"MMK": {"MMK", "K", 2},
+	"MNT": {"MNT", "₮", 2},
+	"MOP": {"MOP", "MOP$", 2},
+	"MRU": {"MRU", "UM", 2},
+	"MUR": {"MUR", "Rs", 2},
+	"MVR": {"MVR", "Rf", 2},
+	"MWK": {"MWK", "MK", 2},
+	"MXN": {"MXN", "MX$", 2},
+	"MYR": {"MYR", "RM", 2},
+	"MZN": {"MZN", "MT", 2},
+	"NAD": {"NAD", "$", 2},
+	"NGN": {"NGN", "₦", 2},
+	"NIO": {"NIO", "C$", 2},
+	"NOK": {"NOK", "kr", 2},
+	"NPR": {"NPR", "Rs", 2},
+	"NZD": {"NZD", "NZ$", 2},
+	"OMR": {"OMR", "ر.ع.", 3},
+	"PAB": {"PAB", "B/.", 2},
+	"PEN": {"PEN", "S/", 2},
+	"PGK": {"PGK", "K", 2},
+	"PHP": {"PHP", "₱", 2},
+	"PKR": {"PKR", "Rs", 2},
+	"PLN": {"PLN", "zł", 2},
+	"PYG": {"PYG", "₲", 0},
+	"QAR": {"QAR", "ر.ق", 2},
+	"RON": {"RON", "lei", 2},
+	"RSD": {"RSD", "дин.", 2},
+	"RUB": {"RUB", "₽", 2},
+	"RWF": {"RWF", "FRw", 0},
+	"SAR": {"SAR", "ر.س", 2},
+	"SBD": {"SBD", "$", 2},
+	"SCR": {"SCR", "Rs", 2},
+	"SDG": {"SDG", "ج.س.", 2},
+	"SEK": {"SEK", "kr", 2},
+	"SGD": {"SGD", "S$", 2},
+	"SHP": {"SHP", "£", 2},
+	"SLE": {"SLE", "Le", 2},
+	"SOS": {"SOS", "Sh", 2},
+	"SRD": {"SRD", "$", 2},
+	"SSP": {"SSP", "£", 2},
+	"STN": {"STN", "Db", 2},
+	"SVC": {"SVC", "₡", 2},
+	"SYP": {"SYP", "£", 2},
+	"SZL": {"SZL", "L", 2},
+	"THB": {"THB", "฿", 2},
+	"TJS": {"TJS", "SM", 2},
+	"TMT": {"TMT", "m", 2},
+	"TND": {"TND", "د.ت", 3},
+	"TOP": {"TOP", "T$", 2},
+	"TRY": {"TRY", "₺", 2},
+	"TTD": {"TTD", "$", 2},
+	"TWD": {"TWD", "NT$", 2},
+	"TZS": {"TZS", "TSh", 2},
+	"UAH": {"UAH", "₴", 2},
+	"UGX": {"UGX", "USh", 0},
+	"USD": {"USD", "$", 2},
+	"UYU": {"UYU", "$", 2},
+	"UZS": {"UZS", "soʻm", 2},
+	"VES": {"VES", "Bs.", 2},
+	"VND": {"VND", "₫", 0},
+	"VUV": {"VUV", "VT", 0},
+	"WST": {"WST", "T", 2},
+	"XAF": {"XAF", "FCFA", 0},
+	"XCD": {"XCD", "$", 2},
+	"XCG": {"XCG", "Cg", 2},
+	"XOF": {"XOF", "CFA", 0},
+	"XPF": {"XPF", "₣", 0},
+	"YER": {"YER", "﷼", 2},
+	"ZAR": {"ZAR", "R", 2},
+	"ZMW": {"ZMW", "K", 2},
+	"ZWG": {"ZWG", "ZiG", 2},
+}
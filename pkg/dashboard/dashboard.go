@@ -0,0 +1,322 @@
+// Package dashboard generates Grafana dashboards from the exporter's metric
+// names and label set, so dashboards stay in sync with configuration.
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Kinds lists the dashboards that can be generated.
+var Kinds = []string{"overview", "account", "team"}
+
+// Options describes the metrics the dashboards are built from.
+type Options struct {
+	// CostMetric is the fully-qualified cost gauge name, e.g. aws_cloud_cost_total.
+	CostMetric string
+	// SelfMetricPrefix is the prefix of the exporter's own metrics.
+	SelfMetricPrefix string
+	// Labels are the label names available on CostMetric.
+	Labels []string
+	// CostType is the cost_type shown by default (e.g. amortized_net).
+	CostType string
+	// CostTypeMetric returns the metric of a cost type when the costs are
+	// emitted as a metric per cost type rather than CostMetric with a
+	// cost_type label, and "" otherwise. It may be nil.
+	CostTypeMetric func(costType string) string
+	// TeamLabel is the label used for team drilldowns; defaults to "team"
+	// when present in Labels, otherwise "owner".
+	TeamLabel string
+}
+
+// Dashboard is the subset of the Grafana dashboard model that is generated.
+type Dashboard struct {
+	UID           string     `json:"uid"`
+	Title         string     `json:"title"`
+	Tags          []string   `json:"tags"`
+	Timezone      string     `json:"timezone"`
+	SchemaVersion int        `json:"schemaVersion"`
+	Refresh       string     `json:"refresh"`
+	Time          TimeRange  `json:"time"`
+	Templating    Templating `json:"templating"`
+	Panels        []Panel    `json:"panels"`
+}
+
+// TimeRange is the default dashboard time range.
+type TimeRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Templating holds dashboard variables.
+type Templating struct {
+	List []Variable `json:"list"`
+}
+
+// Variable is a dashboard template variable.
+type Variable struct {
+	Name       string      `json:"name"`
+	Label      string      `json:"label,omitempty"`
+	Type       string      `json:"type"`
+	Query      any         `json:"query"`
+	Datasource *Datasource `json:"datasource,omitempty"`
+	Multi      bool        `json:"multi,omitempty"`
+	IncludeAll bool        `json:"includeAll,omitempty"`
+	Refresh    int         `json:"refresh,omitempty"`
+}
+
+// Datasource references a Grafana datasource.
+type Datasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+// Panel is a dashboard panel.
+type Panel struct {
+	ID          int         `json:"id"`
+	Type        string      `json:"type"`
+	Title       string      `json:"title"`
+	GridPos     GridPos     `json:"gridPos"`
+	Datasource  *Datasource `json:"datasource,omitempty"`
+	Targets     []Target    `json:"targets,omitempty"`
+	FieldConfig FieldConfig `json:"fieldConfig"`
+}
+
+// GridPos positions a panel on the dashboard grid.
+type GridPos struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+// Target is a Prometheus query.
+type Target struct {
+	RefID        string `json:"refId"`
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat,omitempty"`
+	Instant      bool   `json:"instant,omitempty"`
+	Format       string `json:"format,omitempty"`
+}
+
+// FieldConfig configures panel value formatting.
+type FieldConfig struct {
+	Defaults FieldDefaults `json:"defaults"`
+}
+
+// FieldDefaults holds default field options.
+type FieldDefaults struct {
+	Unit string `json:"unit,omitempty"`
+}
+
+var promDS = &Datasource{Type: "prometheus", UID: "${datasource}"}
+
+// Generate builds the dashboard of the given kind.
+func Generate(kind string, opts Options) (*Dashboard, error) {
+	opts = withDefaults(opts)
+
+	b := &builder{opts: opts}
+	switch kind {
+	case "overview":
+		return b.overview(), nil
+	case "account":
+		if !opts.hasLabel("account_id") {
+			return nil, fmt.Errorf("account dashboard requires the account_id label")
+		}
+		return b.account(), nil
+	case "team":
+		if !opts.hasLabel(opts.TeamLabel) {
+			return nil, fmt.Errorf("team dashboard requires the %s label", opts.TeamLabel)
+		}
+		return b.team(), nil
+	default:
+		return nil, fmt.Errorf("unknown dashboard kind %q (want one of %s)", kind, strings.Join(Kinds, ", "))
+	}
+}
+
+// JSON renders a dashboard as indented JSON.
+func (d *Dashboard) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+func withDefaults(opts Options) Options {
+	if opts.CostMetric == "" {
+		opts.CostMetric = "aws_cloud_cost_total"
+	}
+	if opts.SelfMetricPrefix == "" {
+		opts.SelfMetricPrefix = "cloudcost_exporter"
+	}
+	if opts.CostType == "" {
+		opts.CostType = "amortized_net"
+	}
+	if opts.TeamLabel == "" {
+		opts.TeamLabel = "owner"
+		if opts.hasLabel("team") {
+			opts.TeamLabel = "team"
+		}
+	}
+	return opts
+}
+
+func (o Options) hasLabel(name string) bool {
+	return slices.Contains(o.Labels, name)
+}
+
+// costMetric returns the metric of the costs of costType, and whether it
+// has a cost_type label.
+func (o Options) costMetric(costType string) (string, bool) {
+	if o.CostTypeMetric != nil {
+		if name := o.CostTypeMetric(costType); name != "" {
+			return name, false
+		}
+	}
+	return o.CostMetric, true
+}
+
+type builder struct {
+	opts   Options
+	panels []Panel
+	x, y   int
+	rowH   int
+}
+
+// selector renders the cost metric with the default cost type and any
+// additional matchers.
+func (b *builder) selector(matchers ...string) string {
+	return b.costTypeSelector(b.opts.CostType, matchers...)
+}
+
+// costTypeSelector renders the cost metric with the given cost type and any
+// additional matchers. With currency conversion, only the USD costs are
+// selected, as the panels are in USD.
+func (b *builder) costTypeSelector(costType string, matchers ...string) string {
+	metric, labeled := b.opts.costMetric(costType)
+	var all []string
+	if labeled {
+		all = append(all, fmt.Sprintf(`cost_type="%s"`, costType))
+	}
+	if b.opts.hasLabel("currency") {
+		all = append(all, `currency="USD"`)
+	}
+	all = append(all, matchers...)
+	if len(all) == 0 {
+		return metric
+	}
+	return fmt.Sprintf("%s{%s}", metric, strings.Join(all, ", "))
+}
+
+func (b *builder) add(typ, title string, w, h int, unit string, targets ...Target) {
+	if b.x+w > 24 {
+		b.x = 0
+		b.y += b.rowH
+		b.rowH = 0
+	}
+	b.rowH = max(b.rowH, h)
+	for i := range targets {
+		targets[i].RefID = string(rune('A' + i))
+	}
+	b.panels = append(b.panels, Panel{
+		ID:          len(b.panels) + 1,
+		Type:        typ,
+		Title:       title,
+		GridPos:     GridPos{X: b.x, Y: b.y, W: w, H: h},
+		Datasource:  promDS,
+		Targets:     targets,
+		FieldConfig: FieldConfig{Defaults: FieldDefaults{Unit: unit}},
+	})
+	b.x += w
+}
+
+// breakdowns adds one "cost by <label>" panel per available label.
+func (b *builder) breakdowns(matchers []string, labels ...string) {
+	for _, l := range labels {
+		if !b.opts.hasLabel(l) {
+			continue
+		}
+		b.add("timeseries", "Cost by "+l, 12, 8, "currencyUSD", Target{
+			Expr:         fmt.Sprintf("topk(10, sum by (%s) (%s))", l, b.selector(matchers...)),
+			LegendFormat: "{{" + l + "}}",
+		})
+	}
+}
+
+func (b *builder) dashboard(uid, title string, vars ...Variable) *Dashboard {
+	list := []Variable{{
+		Name:  "datasource",
+		Label: "Datasource",
+		Type:  "datasource",
+		Query: "prometheus",
+	}}
+	list = append(list, vars...)
+
+	return &Dashboard{
+		UID:           uid,
+		Title:         title,
+		Tags:          []string{"opencost", "cloud-cost"},
+		Timezone:      "browser",
+		SchemaVersion: 39,
+		Refresh:       "1h",
+		Time:          TimeRange{From: "now-30d", To: "now"},
+		Templating:    Templating{List: list},
+		Panels:        b.panels,
+	}
+}
+
+func (b *builder) queryVar(name, label string) Variable {
+	metric, _ := b.opts.costMetric(b.opts.CostType)
+	return Variable{
+		Name:       name,
+		Label:      label,
+		Type:       "query",
+		Query:      fmt.Sprintf("label_values(%s, %s)", metric, name),
+		Datasource: promDS,
+		Multi:      true,
+		IncludeAll: true,
+		Refresh:    2,
+	}
+}
+
+func (b *builder) overview() *Dashboard {
+	b.add("stat", "Total cost", 8, 4, "currencyUSD", Target{
+		Expr:    fmt.Sprintf("sum(%s)", b.selector()),
+		Instant: true,
+	})
+	b.add("stat", "List cost", 8, 4, "currencyUSD", Target{
+		Expr:    fmt.Sprintf("sum(%s)", b.costTypeSelector("list")),
+		Instant: true,
+	})
+	b.add("stat", "Cache age", 8, 4, "s", Target{
+		Expr:    b.opts.SelfMetricPrefix + "_cache_age_seconds",
+		Instant: true,
+	})
+	b.breakdowns(nil, "service", "account_id", "region", "category", "environment", b.opts.TeamLabel)
+	return b.dashboard("cloudcost-overview", "Cloud Cost / Overview")
+}
+
+func (b *builder) account() *Dashboard {
+	m := []string{`account_id=~"$account_id"`}
+	b.add("stat", "Account cost", 24, 4, "currencyUSD", Target{
+		Expr:    fmt.Sprintf("sum(%s)", b.selector(m...)),
+		Instant: true,
+	})
+	b.breakdowns(m, "service", "region", "category", b.opts.TeamLabel)
+	b.add("table", "Services", 24, 10, "currencyUSD", Target{
+		Expr:    fmt.Sprintf("sort_desc(sum by (account_id, service) (%s))", b.selector(m...)),
+		Instant: true,
+		Format:  "table",
+	})
+	return b.dashboard("cloudcost-account", "Cloud Cost / Account", b.queryVar("account_id", "Account"))
+}
+
+func (b *builder) team() *Dashboard {
+	team := b.opts.TeamLabel
+	m := []string{fmt.Sprintf(`%s=~"$%s"`, team, team)}
+	b.add("stat", "Team cost", 24, 4, "currencyUSD", Target{
+		Expr:    fmt.Sprintf("sum(%s)", b.selector(m...)),
+		Instant: true,
+	})
+	b.breakdowns(m, "service", "account_id", "environment", "cluster")
+	return b.dashboard("cloudcost-team", "Cloud Cost / Team", b.queryVar(team, "Team"))
+}
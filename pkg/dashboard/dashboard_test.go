@@ -0,0 +1,137 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+var defaultLabels = []string{"provider_id", "account_id", "service", "category", "cost_type", "region", "availability_zone", "owner", "environment", "cluster"}
+
+func TestGenerate_Kinds(t *testing.T) {
+	for _, kind := range Kinds {
+		t.Run(kind, func(t *testing.T) {
+			d, err := Generate(kind, Options{Labels: defaultLabels})
+			if err != nil {
+				t.Fatalf("Generate() error = %v", err)
+			}
+			if len(d.Panels) == 0 {
+				t.Error("expected panels")
+			}
+
+			out, err := d.JSON()
+			if err != nil {
+				t.Fatalf("JSON() error = %v", err)
+			}
+			var decoded map[string]any
+			if err := json.Unmarshal(out, &decoded); err != nil {
+				t.Fatalf("generated JSON is invalid: %v", err)
+			}
+		})
+	}
+}
+
+func TestGenerate_UnknownKind(t *testing.T) {
+	if _, err := Generate("bogus", Options{Labels: defaultLabels}); err == nil {
+		t.Error("Generate() should fail for unknown kind")
+	}
+}
+
+func TestGenerate_UsesConfiguredMetricName(t *testing.T) {
+	d, err := Generate("overview", Options{CostMetric: "acme_cost_total", SelfMetricPrefix: "acme_exporter", Labels: defaultLabels})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	out, _ := d.JSON()
+	if strings.Contains(string(out), "aws_cloud_cost_total") {
+		t.Error("dashboard should not reference the default metric name")
+	}
+	if !strings.Contains(string(out), "acme_cost_total") || !strings.Contains(string(out), "acme_exporter_cache_age_seconds") {
+		t.Error("dashboard should reference the configured metric names")
+	}
+}
+
+func TestGenerate_CurrencyLabel(t *testing.T) {
+	d, err := Generate("overview", Options{Labels: append(defaultLabels, "currency")})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	for _, p := range d.Panels {
+		for _, target := range p.Targets {
+			if strings.Contains(target.Expr, "aws_cloud_cost_total{") && !strings.Contains(target.Expr, `currency="USD"`) {
+				t.Errorf("panel %q sums converted costs: %s", p.Title, target.Expr)
+			}
+		}
+	}
+}
+
+func TestGenerate_CostTypeMetric(t *testing.T) {
+	labels := []string{"account_id", "service", "category", "region"}
+	d, err := Generate("overview", Options{Labels: labels, CostTypeMetric: func(costType string) string {
+		return "aws_cloud_cost_" + costType + "_usd"
+	}})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	out, _ := d.JSON()
+	if strings.Contains(string(out), "aws_cloud_cost_total") || strings.Contains(string(out), "cost_type=") {
+		t.Error("dashboard should not select the cost_type label")
+	}
+	if !strings.Contains(string(out), "aws_cloud_cost_amortized_net_usd") {
+		t.Error("dashboard should reference the metric of the cost type")
+	}
+}
+
+func TestGenerate_OnlyConfiguredLabels(t *testing.T) {
+	d, err := Generate("overview", Options{Labels: []string{"account_id", "service", "cost_type"}})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	for _, p := range d.Panels {
+		if p.Title == "Cost by region" || p.Title == "Cost by owner" {
+			t.Errorf("unexpected panel %q for unconfigured label", p.Title)
+		}
+	}
+}
+
+func TestGenerate_TeamLabel(t *testing.T) {
+	tests := []struct {
+		name    string
+		labels  []string
+		want    string
+		wantErr bool
+	}{
+		{"prefers team", []string{"team", "owner"}, "team", false},
+		{"falls back to owner", []string{"owner"}, "owner", false},
+		{"missing", []string{"service"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := Generate("team", Options{Labels: tt.labels})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Generate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := d.Templating.List[1].Name; got != tt.want {
+				t.Errorf("team variable = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerate_PanelsDoNotOverlap(t *testing.T) {
+	d, err := Generate("overview", Options{Labels: defaultLabels})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	for i, a := range d.Panels {
+		for _, b := range d.Panels[i+1:] {
+			if a.GridPos.X < b.GridPos.X+b.GridPos.W && b.GridPos.X < a.GridPos.X+a.GridPos.W &&
+				a.GridPos.Y < b.GridPos.Y+b.GridPos.H && b.GridPos.Y < a.GridPos.Y+a.GridPos.H {
+				t.Errorf("panels %q and %q overlap", a.Title, b.Title)
+			}
+		}
+	}
+}
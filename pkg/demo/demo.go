@@ -0,0 +1,374 @@
+// Package demo generates realistic synthetic OpenCost cloudCost responses so
+// dashboards and the metric schema can be evaluated without a working
+// OpenCost and AWS billing integration.
+package demo
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+const day = 24 * time.Hour
+
+// maxWindow bounds the number of daily sets generated for one request.
+const maxWindow = 400 * day
+
+type account struct {
+	id, name, environment string
+	scale                 float64
+	regions               []string
+}
+
+type service struct {
+	name, category, owner string
+	daily                 float64
+	kubePercent           float64
+	weekendDip            float64
+	unit                  string  // usage unit
+	unitPrice             float64 // list cost per unit
+}
+
+var accounts = []account{
+	{"111111111111", "production", "prod", 1, []string{"us-east-1", "eu-west-1"}},
+	{"222222222222", "staging", "staging", 0.3, []string{"us-east-1"}},
+	{"333333333333", "shared-services", "shared", 0.15, []string{"us-east-1"}},
+}
+
+var services = []service{
+	{"AmazonEC2", "Compute", "platform", 420, 0.65, 0.2, "Hrs", 0.192},
+	{"AmazonEKS", "Compute", "platform", 73, 1, 0, "Hrs", 0.1},
+	{"AWSLambda", "Compute", "web", 12, 0, 0.4, "Lambda-GB-Second", 0.0000166667},
+	{"AmazonRDS", "Database", "data", 180, 0, 0, "Hrs", 0.68},
+	{"AmazonDynamoDB", "Database", "data", 30, 0, 0.3, "WriteRequestUnits", 0.00000125},
+	{"AmazonElastiCache", "Database", "data", 60, 0, 0, "Hrs", 0.34},
+	{"AmazonS3", "Storage", "data", 95, 0.2, 0, "GB-Mo", 0.023 / 30},
+	{"AmazonCloudFront", "Network", "web", 40, 0, 0.35, "GB", 0.085},
+	{"AWSDataTransfer", "Network", "web", 55, 0.3, 0.25, "GB", 0.09},
+	{"AmazonCloudWatch", "Management", "platform", 18, 0.1, 0, "Metrics", 0.3 / 30},
+}
+
+// Generator produces synthetic cloud costs. Costs are deterministic for a
+// given item and day, so repeated queries return consistent data.
+type Generator struct {
+	now func() time.Time
+}
+
+// Option configures a Generator.
+type Option func(*Generator)
+
+// WithNow sets the clock used to resolve relative windows.
+func WithNow(now func() time.Time) Option {
+	return func(g *Generator) {
+		g.now = now
+	}
+}
+
+// New creates a Generator.
+func New(opts ...Option) *Generator {
+	g := &Generator{now: time.Now}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// Generate returns one set per day between start and end, with costs
+// prorated for partial days and collapsed to the aggregate properties
+// (OpenCost names such as "accountID", "service" or "label:owner"). An empty
+// aggregate returns items at full detail.
+func (g *Generator) Generate(start, end time.Time, aggregate []string) (*types.CloudCostResponse, error) {
+	start, end = start.UTC(), end.UTC()
+	if !end.After(start) {
+		return nil, fmt.Errorf("window end %s is not after start %s", end.Format(time.RFC3339), start.Format(time.RFC3339))
+	}
+	if end.Sub(start) > maxWindow {
+		return nil, fmt.Errorf("window exceeds %d days", int(maxWindow/day))
+	}
+	for _, a := range aggregate {
+		if _, ok := (types.CloudCostProperties{}).Property(a); !ok {
+			return nil, fmt.Errorf("unknown aggregate property %q", a)
+		}
+	}
+
+	resp := &types.CloudCostResponse{Code: http.StatusOK}
+	for d := start.Truncate(day); d.Before(end); d = d.Add(day) {
+		from, to := maxTime(d, start), minTime(d.Add(day), end)
+		window := types.Window{Start: from, End: to}
+		fraction := to.Sub(from).Hours() / 24
+
+		costs := make(map[string]types.CloudCostItem)
+		for _, acc := range accounts {
+			for ri, region := range acc.regions {
+				for _, svc := range services {
+					item := g.item(acc, region, ri, svc, d, fraction)
+					item.Window = window
+					merge(costs, item, aggregate)
+				}
+			}
+		}
+		dropUnitlessUsage(costs)
+		resp.Data.Sets = append(resp.Data.Sets, types.CloudCostSet{CloudCosts: costs})
+	}
+	return resp, nil
+}
+
+// item builds the cost of one service in one account and region on day d.
+func (g *Generator) item(acc account, region string, regionIdx int, svc service, d time.Time, fraction float64) types.CloudCostItem {
+	key := acc.id + "/" + region + "/" + svc.name
+
+	// The first region carries the bulk of the spend; secondary regions
+	// run a smaller footprint.
+	list := svc.daily * acc.scale
+	if regionIdx > 0 {
+		list *= 0.4
+	}
+	// Slow growth of roughly 1% per month, a weekend dip for
+	// traffic-driven services, and +/-15% day-to-day noise.
+	list *= 1 + float64(d.Unix()/int64(day.Seconds())%365)*0.0003
+	if wd := d.Weekday(); wd == time.Saturday || wd == time.Sunday {
+		list *= 1 - svc.weekendDip
+	}
+	list *= 1 + 0.15*noise(key, d)
+	list = round(list * fraction)
+
+	// Compute is covered by savings plans; everything else is on demand
+	// with an enterprise discount.
+	net := round(list * 0.92)
+	amortized := list
+	if svc.category == "Compute" {
+		amortized = round(list * 0.72)
+	}
+	amortizedNet := round(amortized * 0.92)
+
+	cost := func(c float64) types.CostValue {
+		return types.CostValue{Cost: c, KubernetesPercent: svc.kubePercent}
+	}
+	return types.CloudCostItem{
+		Properties: types.CloudCostProperties{
+			ProviderID:        key,
+			Provider:          "AWS",
+			AccountID:         acc.id,
+			AccountName:       acc.name,
+			InvoiceEntityID:   accounts[0].id,
+			InvoiceEntityName: "acme-payer",
+			RegionID:          region,
+			AvailabilityZone:  region + "a",
+			Service:           svc.name,
+			Category:          svc.category,
+			Labels: map[string]string{
+				"owner":       svc.owner,
+				"environment": acc.environment,
+				"cluster":     acc.environment + "-" + region,
+			},
+		},
+		ListCost:         cost(list),
+		NetCost:          cost(net),
+		AmortizedNetCost: cost(amortizedNet),
+		InvoicedCost:     cost(net),
+		AmortizedCost:    cost(amortized),
+		Usage:            &types.Usage{Quantity: round(list / svc.unitPrice), Unit: svc.unit},
+	}
+}
+
+// merge adds item to costs under its aggregate key, keeping only the
+// aggregated properties, as OpenCost does.
+func merge(costs map[string]types.CloudCostItem, item types.CloudCostItem, aggregate []string) {
+	if len(aggregate) == 0 {
+		costs[item.Properties.ProviderID] = item
+		return
+	}
+
+	var props types.CloudCostProperties
+	for _, a := range aggregate {
+		v, _ := item.Properties.Property(a)
+		props.SetProperty(a, v)
+	}
+	key := props.Key(aggregate)
+
+	existing, ok := costs[key]
+	if !ok {
+		item.Properties = props
+		costs[key] = item
+		return
+	}
+	existing.ListCost = add(existing.ListCost, item.ListCost)
+	existing.NetCost = add(existing.NetCost, item.NetCost)
+	existing.AmortizedNetCost = add(existing.AmortizedNetCost, item.AmortizedNetCost)
+	existing.InvoicedCost = add(existing.InvoicedCost, item.InvoicedCost)
+	existing.AmortizedCost = add(existing.AmortizedCost, item.AmortizedCost)
+	// Usage in different units has no meaningful sum; the empty unit marks
+	// such items until dropUnitlessUsage.
+	usage, ok := existing.Usage.Add(item.Usage)
+	if !ok {
+		usage = &types.Usage{}
+	}
+	existing.Usage = usage
+	costs[key] = existing
+}
+
+// dropUnitlessUsage removes the usage of items that merged different units.
+func dropUnitlessUsage(costs map[string]types.CloudCostItem) {
+	for key, item := range costs {
+		if item.Usage != nil && item.Usage.Unit == "" {
+			item.Usage = nil
+			costs[key] = item
+		}
+	}
+}
+
+// add sums two costs, weighting the Kubernetes share by cost.
+func add(a, b types.CostValue) types.CostValue {
+	total := a.Cost + b.Cost
+	if total == 0 {
+		return types.CostValue{}
+	}
+	return types.CostValue{
+		Cost:              round(total),
+		KubernetesPercent: (a.Cost*a.KubernetesPercent + b.Cost*b.KubernetesPercent) / total,
+	}
+}
+
+// ParseWindow resolves an OpenCost window expression: an RFC3339
+// "start,end" range, "today", "yesterday", "week", "month", "lastweek",
+// "lastmonth" or a duration such as "2d" or "24h" ending now.
+func ParseWindow(window string, now time.Time) (time.Time, time.Time, error) {
+	now = now.UTC()
+	today := now.Truncate(day)
+	switch window {
+	case "today":
+		return today, now, nil
+	case "yesterday":
+		return today.Add(-day), today, nil
+	case "week":
+		return today.Add(-time.Duration(today.Weekday()) * day), now, nil
+	case "lastweek":
+		end := today.Add(-time.Duration(today.Weekday()) * day)
+		return end.Add(-7 * day), end, nil
+	case "month":
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC), now, nil
+	case "lastmonth":
+		end := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return end.AddDate(0, -1, 0), end, nil
+	}
+
+	if from, to, ok := strings.Cut(window, ","); ok {
+		start, err := time.Parse(time.RFC3339, strings.TrimSpace(from))
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid window start: %w", err)
+		}
+		end, err := time.Parse(time.RFC3339, strings.TrimSpace(to))
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid window end: %w", err)
+		}
+		return start, end, nil
+	}
+
+	if n, ok := strings.CutSuffix(window, "d"); ok {
+		days, err := strconv.Atoi(n)
+		if err != nil || days <= 0 {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid window %q", window)
+		}
+		return now.Add(-time.Duration(days) * day), now, nil
+	}
+	d, err := time.ParseDuration(window)
+	if err != nil || d <= 0 {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid window %q", window)
+	}
+	return now.Add(-d), now, nil
+}
+
+// ServeHTTP serves the subset of the OpenCost API used by the exporter:
+// /cloudCost, /healthz and /metrics.
+func (g *Generator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/healthz":
+		w.Write([]byte("ok"))
+	case "/metrics":
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		fmt.Fprintln(w, "# HELP opencost_build_info OpenCost build information")
+		fmt.Fprintln(w, "# TYPE opencost_build_info gauge")
+		fmt.Fprintln(w, `opencost_build_info{version="demo"} 1`)
+	case "/cloudCost":
+		g.serveCloudCost(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (g *Generator) serveCloudCost(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	window := q.Get("window")
+	if window == "" {
+		window = "7d"
+	}
+	start, end, err := ParseWindow(window, g.now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var aggregate []string
+	if a := q.Get("aggregate"); a != "" {
+		for _, p := range strings.Split(a, ",") {
+			aggregate = append(aggregate, strings.TrimSpace(p))
+		}
+	}
+
+	resp, err := g.Generate(start, end, aggregate)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.Debug("failed to write demo response", "error", err)
+	}
+}
+
+// Start serves the generator on a loopback port and returns its base URL
+// and a function that stops it.
+func (g *Generator) Start() (string, func() error, error) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", nil, fmt.Errorf("listen: %w", err)
+	}
+	srv := &http.Server{Handler: g, ReadHeaderTimeout: 10 * time.Second}
+	go srv.Serve(lis)
+	return "http://" + lis.Addr().String(), srv.Close, nil
+}
+
+// noise returns a deterministic value in [-1, 1] for key on day d.
+func noise(key string, d time.Time) float64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	h.Write([]byte(d.Format(time.DateOnly)))
+	return float64(h.Sum64()%2001)/1000 - 1
+}
+
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,160 @@
+package demo
+
+import (
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/client"
+)
+
+var now = time.Date(2024, 3, 14, 12, 0, 0, 0, time.UTC)
+
+func TestGenerate(t *testing.T) {
+	g := New()
+	start, end := now.Add(-2*day), now
+
+	resp, err := g.Generate(start, end, nil)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	// 12:00 two days ago through 12:00 today spans three calendar days.
+	if len(resp.Data.Sets) != 3 {
+		t.Fatalf("got %d sets, want 3", len(resp.Data.Sets))
+	}
+
+	set := resp.Data.Sets[1]
+	// 3 accounts with 4 regions in total, 10 services each.
+	if len(set.CloudCosts) != 40 {
+		t.Errorf("got %d items, want 40", len(set.CloudCosts))
+	}
+	for key, item := range set.CloudCosts {
+		if item.ListCost.Cost <= 0 {
+			t.Errorf("%s: list cost = %v, want > 0", key, item.ListCost.Cost)
+		}
+		if item.AmortizedNetCost.Cost > item.ListCost.Cost {
+			t.Errorf("%s: amortized net %v exceeds list %v", key, item.AmortizedNetCost.Cost, item.ListCost.Cost)
+		}
+		if item.Properties.Labels["owner"] == "" || item.Properties.Labels["environment"] == "" {
+			t.Errorf("%s: missing labels %v", key, item.Properties.Labels)
+		}
+	}
+
+	// Half-day sets are prorated.
+	ec2 := "111111111111/us-east-1/AmazonEC2"
+	partial, full := resp.Data.Sets[0].CloudCosts[ec2].ListCost.Cost, set.CloudCosts[ec2].ListCost.Cost
+	if partial > full*0.75 {
+		t.Errorf("partial day cost %v should be about half of %v", partial, full)
+	}
+
+	again, _ := g.Generate(start, end, nil)
+	if !reflect.DeepEqual(resp, again) {
+		t.Error("Generate() should be deterministic")
+	}
+}
+
+func TestGenerate_Aggregate(t *testing.T) {
+	resp, err := New().Generate(now.Truncate(day).Add(-day), now.Truncate(day), []string{"accountID", "label:owner"})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	costs := resp.Data.Sets[0].CloudCosts
+	// 3 accounts x 3 owners.
+	if len(costs) != 9 {
+		t.Fatalf("got %d items, want 9", len(costs))
+	}
+	item, ok := costs["111111111111/platform"]
+	if !ok {
+		t.Fatalf("missing 111111111111/platform in %v", costs)
+	}
+	if item.Properties.Service != "" || item.Properties.Labels["owner"] != "platform" {
+		t.Errorf("aggregated properties = %+v", item.Properties)
+	}
+	for key, item := range costs {
+		if err := item.Properties.CheckKey(key, []string{"accountID", "label:owner"}); err != nil {
+			t.Error(err)
+		}
+	}
+	if kp := item.ListCost.KubernetesPercent; kp <= 0 || kp >= 1 {
+		t.Errorf("blended kubernetes percent = %v, want between 0 and 1", kp)
+	}
+	// Platform's services are metered in different units.
+	if item.Usage != nil {
+		t.Errorf("usage of mixed units = %+v, want nil", item.Usage)
+	}
+
+	bySvc, err := New().Generate(now.Truncate(day).Add(-day), now.Truncate(day), []string{"service"})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if u := bySvc.Data.Sets[0].CloudCosts["AmazonEC2"].Usage; u == nil || u.Unit != "Hrs" || u.Quantity <= 0 {
+		t.Errorf("usage of AmazonEC2 = %+v, want hours summed across accounts", u)
+	}
+
+	if _, err := New().Generate(now.Add(-day), now, []string{"bogus"}); err == nil {
+		t.Error("Generate() should reject unknown aggregate properties")
+	}
+}
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		window    string
+		wantStart string
+		wantEnd   string
+		wantErr   bool
+	}{
+		{"2d", "2024-03-12T12:00:00Z", "2024-03-14T12:00:00Z", false},
+		{"24h", "2024-03-13T12:00:00Z", "2024-03-14T12:00:00Z", false},
+		{"today", "2024-03-14T00:00:00Z", "2024-03-14T12:00:00Z", false},
+		{"yesterday", "2024-03-13T00:00:00Z", "2024-03-14T00:00:00Z", false},
+		{"week", "2024-03-10T00:00:00Z", "2024-03-14T12:00:00Z", false},
+		{"lastweek", "2024-03-03T00:00:00Z", "2024-03-10T00:00:00Z", false},
+		{"month", "2024-03-01T00:00:00Z", "2024-03-14T12:00:00Z", false},
+		{"lastmonth", "2024-02-01T00:00:00Z", "2024-03-01T00:00:00Z", false},
+		{"2024-01-01T00:00:00Z,2024-01-08T00:00:00Z", "2024-01-01T00:00:00Z", "2024-01-08T00:00:00Z", false},
+		{"0d", "", "", true},
+		{"soon", "", "", true},
+		{"2024-01-01,2024-01-08", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.window, func(t *testing.T) {
+			start, end, err := ParseWindow(tt.window, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseWindow() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := start.Format(time.RFC3339); got != tt.wantStart {
+				t.Errorf("start = %s, want %s", got, tt.wantStart)
+			}
+			if got := end.Format(time.RFC3339); got != tt.wantEnd {
+				t.Errorf("end = %s, want %s", got, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	server := httptest.NewServer(New(WithNow(func() time.Time { return now })))
+	defer server.Close()
+
+	cl := client.New(server.URL, client.WithWindow("2d"))
+	resp, err := cl.FetchCloudCosts(context.Background())
+	if err != nil {
+		t.Fatalf("FetchCloudCosts() error = %v", err)
+	}
+	if len(resp.Data.Sets) != 3 || len(resp.Data.Sets[0].CloudCosts) != 40 {
+		t.Errorf("got %d sets with %d items, want 3 sets with 40 items", len(resp.Data.Sets), len(resp.Data.Sets[0].CloudCosts))
+	}
+	if err := cl.Ping(context.Background()); err != nil {
+		t.Errorf("Ping() error = %v", err)
+	}
+
+	bad := client.New(server.URL, client.WithWindow("soon"), client.WithMaxRetries(0))
+	if _, err := bad.FetchCloudCosts(context.Background()); err == nil {
+		t.Error("FetchCloudCosts() should fail for an invalid window")
+	}
+}
@@ -0,0 +1,185 @@
+// Package downsample rolls the daily cost sets of long windows up to weeks
+// or months, merging their items by coarser properties, so that e.g. a 90
+// day window is held, exposed and exported as 13 weekly sets instead of 90
+// daily ones with an item per resource.
+package downsample
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+// Period is the granularity sets are rolled up to.
+type Period string
+
+// Periods sets can be rolled up to.
+const (
+	// Week starts at midnight on Sunday, as the "week" window does.
+	Week Period = "week"
+	// Month starts at midnight on the 1st.
+	Month Period = "month"
+)
+
+// ParsePeriod parses a period name. The empty name disables downsampling
+// and parses as the empty period.
+func ParsePeriod(name string) (Period, error) {
+	switch p := Period(name); p {
+	case "", Week, Month:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown downsampling period %q, want %s or %s", name, Week, Month)
+	}
+}
+
+// start returns the start of the period holding t, in the location of t.
+func (p Period) start(t time.Time) time.Time {
+	y, m, d := t.Date()
+	switch p {
+	case Month:
+		return time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+	default:
+		day := time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+		return day.AddDate(0, 0, -int(day.Weekday()))
+	}
+}
+
+// Downsampler rolls the sets of responses spanning MinSpan or more up to
+// Period. Items of a rolled up set are merged by their properties without
+// the provider ID and availability zone, which are left empty.
+type Downsampler struct {
+	Period Period
+	// MinSpan is the shortest span of the set windows of a response that
+	// is rolled up.
+	MinSpan time.Duration
+	// Location aligns the periods, e.g. to local midnight; UTC if nil.
+	Location *time.Location
+}
+
+// Enabled reports whether d rolls any responses up.
+func (d Downsampler) Enabled() bool {
+	return d.Period != ""
+}
+
+// Apply returns data with its sets rolled up if it spans d.MinSpan or more,
+// and data itself otherwise. Sets without a known window are kept as they
+// are. A rolled up set covers the windows of the sets it was rolled up
+// from, so that it does not overlap the sets of other periods.
+func (d Downsampler) Apply(data *types.CloudCostResponse) (*types.CloudCostResponse, bool) {
+	if !d.Enabled() || span(data) < d.MinSpan {
+		return data, false
+	}
+	loc := d.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	out := &types.CloudCostResponse{Code: data.Code, Window: data.Window}
+	periods := make(map[time.Time]int) // index of the set of a period in out
+	merged := make(map[int]map[string]string)
+	for _, set := range data.Data.Sets {
+		bounds := set.Bounds()
+		if bounds.Validate() != nil {
+			out.Data.Sets = append(out.Data.Sets, set)
+			continue
+		}
+		start := d.Period.start(bounds.Start.In(loc))
+		i, ok := periods[start]
+		if !ok {
+			i = len(out.Data.Sets)
+			periods[start] = i
+			merged[i] = make(map[string]string)
+			out.Data.Sets = append(out.Data.Sets, types.CloudCostSet{
+				CloudCosts: make(map[string]types.CloudCostItem),
+				Window:     bounds,
+			})
+		}
+		into := &out.Data.Sets[i]
+		into.Window = extend(into.Window, bounds)
+		into.Skip(set.Skipped()...)
+		for _, key := range slices.Sorted(maps.Keys(set.CloudCosts)) {
+			item := set.CloudCosts[key]
+			item.Properties.ProviderID = ""
+			item.Properties.AvailabilityZone = ""
+			if item.Window.Validate() != nil {
+				item.Window = bounds
+			}
+			id := identity(item)
+			if k, ok := merged[i][id]; ok {
+				into.CloudCosts[k] = add(into.CloudCosts[k], item)
+				continue
+			}
+			if _, taken := into.CloudCosts[key]; taken {
+				// Another day's item of the key had other properties.
+				key = fmt.Sprintf("%s#%d", key, len(into.CloudCosts))
+			}
+			merged[i][id] = key
+			into.CloudCosts[key] = item
+		}
+	}
+	return out, true
+}
+
+// span returns the time from the earliest start to the latest end of the
+// known set windows of data.
+func span(data *types.CloudCostResponse) time.Duration {
+	var w types.Window
+	for _, set := range data.Data.Sets {
+		if b := set.Bounds(); b.Validate() == nil {
+			w = extend(w, b)
+		}
+	}
+	return w.End.Sub(w.Start)
+}
+
+// extend returns the bounds of w, which may be zero, and other.
+func extend(w, other types.Window) types.Window {
+	if w.Start.IsZero() || other.Start.Before(w.Start) {
+		w.Start = other.Start
+	}
+	if other.End.After(w.End) {
+		w.End = other.End
+	}
+	return w
+}
+
+// identity returns what items merged into one have in common: their
+// properties and source.
+func identity(item types.CloudCostItem) string {
+	// Maps are encoded with sorted keys, so equal properties encode alike.
+	b, _ := json.Marshal(struct {
+		Properties types.CloudCostProperties
+		Source     string
+	}{item.Properties, item.Source})
+	return string(b)
+}
+
+// add returns the sum of the costs and usage of a and b, with the window
+// covering both. Kubernetes percentages are weighted by cost.
+func add(a, b types.CloudCostItem) types.CloudCostItem {
+	a.Window = extend(a.Window, b.Window)
+	a.ListCost = addCost(a.ListCost, b.ListCost)
+	a.NetCost = addCost(a.NetCost, b.NetCost)
+	a.AmortizedNetCost = addCost(a.AmortizedNetCost, b.AmortizedNetCost)
+	a.InvoicedCost = addCost(a.InvoicedCost, b.InvoicedCost)
+	a.AmortizedCost = addCost(a.AmortizedCost, b.AmortizedCost)
+	if usage, ok := a.Usage.Add(b.Usage); ok {
+		a.Usage = usage
+	} else {
+		// Quantities in different units have no sum.
+		a.Usage = nil
+	}
+	return a
+}
+
+func addCost(a, b types.CostValue) types.CostValue {
+	sum := types.CostValue{Cost: a.Cost + b.Cost}
+	if sum.Cost != 0 {
+		sum.KubernetesPercent = (a.Cost*a.KubernetesPercent + b.Cost*b.KubernetesPercent) / sum.Cost
+	}
+	return sum
+}
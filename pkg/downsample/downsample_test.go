@@ -0,0 +1,98 @@
+package downsample
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/opencosttest"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+// days returns a response of n daily sets from 2026-01-01, each with an
+// EC2 instance in two availability zones costing 1 and 3 with a
+// Kubernetes percentage of 1 and 0.
+func days(n int) *types.CloudCostResponse {
+	resp := &types.CloudCostResponse{Code: 200}
+	for d := range n {
+		start := time.Date(2026, 1, 1+d, 0, 0, 0, 0, time.UTC)
+		w := types.Window{Start: start, End: start.AddDate(0, 0, 1)}
+		set := types.CloudCostSet{Window: w, CloudCosts: map[string]types.CloudCostItem{}}
+		for i, cost := range []float64{1, 3} {
+			item := opencosttest.Item("123", "AmazonEC2", "Compute", cost)
+			item.Properties.ProviderID = fmt.Sprintf("i-%d", i)
+			item.Properties.AvailabilityZone = fmt.Sprintf("us-east-1%c", 'a'+i)
+			item.ListCost.KubernetesPercent = 1 - float64(i)
+			item.Window = w
+			set.CloudCosts[item.Properties.ProviderID] = item
+		}
+		resp.Data.Sets = append(resp.Data.Sets, set)
+	}
+	return resp
+}
+
+func TestDownsampler_Apply(t *testing.T) {
+	tests := []struct {
+		name     string
+		d        Downsampler
+		days     int
+		want     bool
+		wantSets []int // days per set
+	}{
+		{name: "disabled", days: 60},
+		{name: "short window", d: Downsampler{Period: Week, MinSpan: 30 * 24 * time.Hour}, days: 7},
+		// 2026-01-01 is a Thursday.
+		{name: "weeks", d: Downsampler{Period: Week, MinSpan: 14 * 24 * time.Hour}, days: 14, want: true, wantSets: []int{3, 7, 4}},
+		{name: "months", d: Downsampler{Period: Month}, days: 45, want: true, wantSets: []int{31, 14}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := days(tt.days)
+			got, ok := tt.d.Apply(data)
+			if ok != tt.want {
+				t.Fatalf("Apply() rolled up = %v, want %v", ok, tt.want)
+			}
+			if !ok {
+				if got != data {
+					t.Error("Apply() changed data it did not roll up")
+				}
+				return
+			}
+			if len(got.Data.Sets) != len(tt.wantSets) {
+				t.Fatalf("Apply() = %d sets, want %d", len(got.Data.Sets), len(tt.wantSets))
+			}
+			for i, set := range got.Data.Sets {
+				n := tt.wantSets[i]
+				if d := set.Window.Duration(); d != time.Duration(n)*24*time.Hour {
+					t.Errorf("set %d window %s, want %d days", i, d, n)
+				}
+				if len(set.CloudCosts) != 1 {
+					t.Fatalf("set %d has %d items, want the instances merged into 1", i, len(set.CloudCosts))
+				}
+				for _, item := range set.CloudCosts {
+					if item.Properties.ProviderID != "" || item.Properties.AvailabilityZone != "" {
+						t.Errorf("set %d item properties %+v, want no provider ID and availability zone", i, item.Properties)
+					}
+					if item.ListCost.Cost != float64(4*n) || math.Abs(item.ListCost.KubernetesPercent-0.25) > 1e-9 {
+						t.Errorf("set %d list cost %+v, want %d at 25%% Kubernetes", i, item.ListCost, 4*n)
+					}
+					if item.Window != set.Window {
+						t.Errorf("set %d item window %+v, want the set's %+v", i, item.Window, set.Window)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestParsePeriod(t *testing.T) {
+	for _, name := range []string{"", "week", "month"} {
+		if p, err := ParsePeriod(name); err != nil || string(p) != name {
+			t.Errorf("ParsePeriod(%q) = %q, %v", name, p, err)
+		}
+	}
+	if _, err := ParsePeriod("day"); err == nil {
+		t.Error("ParsePeriod(day) should fail")
+	}
+}
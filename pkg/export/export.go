@@ -0,0 +1,220 @@
+// Package export writes cloud cost data to object storage as Parquet files
+// partitioned by date and account, so it can be queried with Athena or
+// BigQuery alongside the raw billing exports.
+package export
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+// Row is a single flattened cloud cost line item as written to Parquet.
+type Row struct {
+	Date              string            `parquet:"date"`
+	WindowStart       string            `parquet:"window_start"`
+	WindowEnd         string            `parquet:"window_end"`
+	Provider          string            `parquet:"provider"`
+	ProviderID        string            `parquet:"provider_id"`
+	AccountID         string            `parquet:"account_id"`
+	AccountName       string            `parquet:"account_name"`
+	InvoiceEntityID   string            `parquet:"invoice_entity_id"`
+	Service           string            `parquet:"service"`
+	Category          string            `parquet:"category"`
+	Region            string            `parquet:"region"`
+	AvailabilityZone  string            `parquet:"availability_zone"`
+	ListCost          float64           `parquet:"list_cost"`
+	NetCost           float64           `parquet:"net_cost"`
+	AmortizedNetCost  float64           `parquet:"amortized_net_cost"`
+	InvoicedCost      float64           `parquet:"invoiced_cost"`
+	AmortizedCost     float64           `parquet:"amortized_cost"`
+	KubernetesPercent float64           `parquet:"kubernetes_percent"`
+	UsageQuantity     *float64          `parquet:"usage_quantity,optional"`
+	UsageUnit         string            `parquet:"usage_unit"`
+	Labels            map[string]string `parquet:"labels"`
+	KubernetesLabels  map[string]string `parquet:"kubernetes_labels"`
+}
+
+// Sink uploads a single object to object storage.
+type Sink interface {
+	Put(ctx context.Context, key string, body []byte, contentType string) error
+}
+
+// Exporter converts CloudCost responses into partitioned Parquet objects.
+type Exporter struct {
+	sink   Sink
+	prefix string
+}
+
+// New creates an Exporter that writes objects below prefix using sink.
+func New(sink Sink, prefix string) *Exporter {
+	return &Exporter{
+		sink:   sink,
+		prefix: strings.Trim(prefix, "/"),
+	}
+}
+
+// NewFromURL creates an Exporter from a destination URL such as
+// s3://bucket/prefix or gs://bucket/prefix.
+func NewFromURL(rawURL string, opts ...SinkOption) (*Exporter, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse export URL: %w", err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("export URL %q has no bucket", rawURL)
+	}
+
+	var sink Sink
+	switch u.Scheme {
+	case "s3":
+		sink = NewS3Sink(u.Host, opts...)
+	case "gs", "gcs":
+		sink = NewGCSSink(u.Host, opts...)
+	default:
+		return nil, fmt.Errorf("unsupported export URL scheme %q (want s3 or gs)", u.Scheme)
+	}
+
+	return New(sink, u.Path), nil
+}
+
+// Export writes one Parquet object per date/account partition. Objects are
+// named deterministically so that re-exporting the same window overwrites
+// the previous files instead of duplicating rows.
+func (e *Exporter) Export(ctx context.Context, data *types.CloudCostResponse) error {
+	partitions := partition(Rows(data))
+
+	keys := make([]partitionKey, 0, len(partitions))
+	for k := range partitions {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].date != keys[j].date {
+			return keys[i].date < keys[j].date
+		}
+		return keys[i].accountID < keys[j].accountID
+	})
+
+	for _, k := range keys {
+		var buf bytes.Buffer
+		if err := WriteParquet(&buf, partitions[k]); err != nil {
+			return fmt.Errorf("encode partition %s/%s: %w", k.date, k.accountID, err)
+		}
+
+		key := e.objectKey(k)
+		if err := e.sink.Put(ctx, key, buf.Bytes(), "application/vnd.apache.parquet"); err != nil {
+			return fmt.Errorf("upload %s: %w", key, err)
+		}
+
+		slog.DebugContext(ctx, "exported parquet partition",
+			"key", key,
+			"rows", len(partitions[k]),
+			"bytes", buf.Len(),
+		)
+	}
+
+	slog.InfoContext(ctx, "exported cloud costs", "partitions", len(keys))
+	return nil
+}
+
+func (e *Exporter) objectKey(k partitionKey) string {
+	account := k.accountID
+	if account == "" {
+		account = "unknown"
+	}
+	return path.Join(e.prefix,
+		"date="+k.date,
+		"account_id="+account,
+		"cloudcost.parquet",
+	)
+}
+
+// Rows flattens a CloudCost response into one Row per line item.
+func Rows(data *types.CloudCostResponse) []Row {
+	if data == nil {
+		return nil
+	}
+
+	var rows []Row
+	for _, set := range data.Data.Sets {
+		for _, item := range set.CloudCosts {
+			p := item.Properties
+			row := Row{
+				Date:              dateOf(item.Window.Start),
+				WindowStart:       formatTime(item.Window.Start),
+				WindowEnd:         formatTime(item.Window.End),
+				Provider:          p.Provider,
+				ProviderID:        p.ProviderID,
+				AccountID:         p.AccountID,
+				AccountName:       p.AccountName,
+				InvoiceEntityID:   p.InvoiceEntityID,
+				Service:           p.Service,
+				Category:          p.Category,
+				Region:            p.RegionID,
+				AvailabilityZone:  p.AvailabilityZone,
+				ListCost:          item.ListCost.Cost,
+				NetCost:           item.NetCost.Cost,
+				AmortizedNetCost:  item.AmortizedNetCost.Cost,
+				InvoicedCost:      item.InvoicedCost.Cost,
+				AmortizedCost:     item.AmortizedCost.Cost,
+				KubernetesPercent: item.ListCost.KubernetesPercent,
+				Labels:            p.Labels,
+				KubernetesLabels:  p.KubernetesLabels,
+			}
+			if u := item.Usage; u != nil {
+				quantity := u.Quantity
+				row.UsageQuantity = &quantity
+				row.UsageUnit = u.Unit
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+type partitionKey struct {
+	date      string
+	accountID string
+}
+
+// partition groups rows by date and account ID.
+func partition(rows []Row) map[partitionKey][]Row {
+	out := make(map[partitionKey][]Row)
+	for _, r := range rows {
+		k := partitionKey{date: r.Date, accountID: r.AccountID}
+		out[k] = append(out[k], r)
+	}
+	return out
+}
+
+// WriteParquet encodes rows as a snappy-compressed Parquet file.
+func WriteParquet(w io.Writer, rows []Row) error {
+	return parquet.Write(w, rows, parquet.Compression(&parquet.Snappy))
+}
+
+// dateOf returns the YYYY-MM-DD date of t, or "unknown" if t is zero.
+func dateOf(t time.Time) string {
+	if t.IsZero() {
+		return "unknown"
+	}
+	return t.UTC().Format("2006-01-02")
+}
+
+// formatTime returns t in RFC3339, or "" if t is zero.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,287 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+type memorySink struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	err     error
+}
+
+// jan returns midnight UTC of the given day of January 2024.
+func jan(day int) time.Time {
+	return time.Date(2024, 1, day, 0, 0, 0, 0, time.UTC)
+}
+
+func (m *memorySink) Put(_ context.Context, key string, body []byte, _ string) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.objects == nil {
+		m.objects = make(map[string][]byte)
+	}
+	m.objects[key] = body
+	return nil
+}
+
+func testResponse() *types.CloudCostResponse {
+	return &types.CloudCostResponse{
+		Code: 200,
+		Data: types.CloudCostData{
+			Sets: []types.CloudCostSet{
+				{
+					CloudCosts: map[string]types.CloudCostItem{
+						"a": {
+							Properties: types.CloudCostProperties{AccountID: "111", Service: "AmazonEC2"},
+							Window:     types.Window{Start: jan(1), End: jan(2)},
+							ListCost:   types.CostValue{Cost: 10},
+							Usage:      &types.Usage{Quantity: 52, Unit: "Hrs"},
+						},
+						"b": {
+							Properties: types.CloudCostProperties{AccountID: "222", Service: "AmazonS3"},
+							Window:     types.Window{Start: jan(1), End: jan(2)},
+							ListCost:   types.CostValue{Cost: 5},
+						},
+					},
+				},
+				{
+					CloudCosts: map[string]types.CloudCostItem{
+						"c": {
+							Properties: types.CloudCostProperties{AccountID: "111", Service: "AmazonEC2"},
+							Window:     types.Window{Start: jan(2), End: jan(3)},
+							ListCost:   types.CostValue{Cost: 12},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestExporter_Export_Partitions(t *testing.T) {
+	sink := &memorySink{}
+	e := New(sink, "/costs/")
+
+	if err := e.Export(context.Background(), testResponse()); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	want := []string{
+		"costs/date=2024-01-01/account_id=111/cloudcost.parquet",
+		"costs/date=2024-01-01/account_id=222/cloudcost.parquet",
+		"costs/date=2024-01-02/account_id=111/cloudcost.parquet",
+	}
+	if len(sink.objects) != len(want) {
+		t.Fatalf("got %d objects, want %d: %v", len(sink.objects), len(want), sink.objects)
+	}
+	for _, key := range want {
+		if _, ok := sink.objects[key]; !ok {
+			t.Errorf("missing object %q", key)
+		}
+	}
+}
+
+func TestExporter_Export_SinkError(t *testing.T) {
+	sink := &memorySink{err: errors.New("boom")}
+	e := New(sink, "")
+
+	if err := e.Export(context.Background(), testResponse()); err == nil {
+		t.Error("Export() should return sink error")
+	}
+}
+
+func TestWriteParquet_RoundTrip(t *testing.T) {
+	rows := Rows(testResponse())
+
+	var buf bytes.Buffer
+	if err := WriteParquet(&buf, rows); err != nil {
+		t.Fatalf("WriteParquet() error = %v", err)
+	}
+
+	got, err := parquet.Read[Row](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("parquet.Read() error = %v", err)
+	}
+	if len(got) != len(rows) {
+		t.Fatalf("got %d rows, want %d", len(got), len(rows))
+	}
+
+	var total float64
+	var usage []float64
+	for _, r := range got {
+		total += r.ListCost
+		if r.UsageQuantity != nil {
+			usage = append(usage, *r.UsageQuantity)
+			if r.UsageUnit != "Hrs" {
+				t.Errorf("usage unit = %q, want Hrs", r.UsageUnit)
+			}
+		}
+	}
+	if total != 27 {
+		t.Errorf("total list cost = %v, want 27", total)
+	}
+	// Items without usage keep it null rather than 0.
+	if len(usage) != 1 || usage[0] != 52 {
+		t.Errorf("usage quantities = %v, want [52]", usage)
+	}
+}
+
+func TestDateOf(t *testing.T) {
+	tests := []struct {
+		in   time.Time
+		want string
+	}{
+		{jan(1), "2024-01-01"},
+		{time.Date(2024, 1, 1, 23, 0, 0, 0, time.FixedZone("", -2*60*60)), "2024-01-02"},
+		{time.Time{}, "unknown"},
+	}
+	for _, tt := range tests {
+		if got := dateOf(tt.in); got != tt.want {
+			t.Errorf("dateOf(%v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNewFromURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{"s3://bucket/prefix", false},
+		{"gs://bucket/prefix", false},
+		{"file:///tmp/x", true},
+		{"s3:///prefix", true},
+	}
+	for _, tt := range tests {
+		_, err := NewFromURL(tt.url)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NewFromURL(%q) error = %v, wantErr %v", tt.url, err, tt.wantErr)
+		}
+	}
+}
+
+func TestS3Sink_Put(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+
+	var gotPath, gotAuth string
+	var gotBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.EscapedPath()
+		gotAuth = r.Header.Get("Authorization")
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	s := NewS3Sink("bucket", WithEndpoint(server.URL), WithRegion("eu-west-1"))
+	err := s.Put(context.Background(), "costs/date=2024-01-01/cloudcost.parquet", []byte("data"), "application/octet-stream")
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	if gotPath != "/bucket/costs/date%3D2024-01-01/cloudcost.parquet" {
+		t.Errorf("path = %q", gotPath)
+	}
+	if !strings.HasPrefix(gotAuth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") || !strings.Contains(gotAuth, "/eu-west-1/s3/aws4_request") {
+		t.Errorf("Authorization = %q", gotAuth)
+	}
+	if string(gotBody) != "data" {
+		t.Errorf("body = %q", gotBody)
+	}
+}
+
+func TestS3Sink_Put_NoCredentials(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_ROLE_ARN", "")
+
+	s := NewS3Sink("bucket", WithEndpoint("http://127.0.0.1:1"))
+	if err := s.Put(context.Background(), "k", nil, "x"); err == nil {
+		t.Error("Put() should fail without credentials")
+	}
+}
+
+func TestSignV4_KnownSignature(t *testing.T) {
+	// Deterministic signing: identical inputs yield identical signatures.
+	newReq := func() *http.Request {
+		req, _ := http.NewRequest(http.MethodPut, "https://bucket.s3.us-east-1.amazonaws.com/key", nil)
+		req.Header.Set("Content-Type", "text/plain")
+		return req
+	}
+	creds := credentials{accessKeyID: "AK", secretAccessKey: "SK"}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	r1, r2 := newReq(), newReq()
+	signV4(r1, []byte("x"), creds, "us-east-1", "s3", now)
+	signV4(r2, []byte("x"), creds, "us-east-1", "s3", now)
+	if r1.Header.Get("Authorization") != r2.Header.Get("Authorization") {
+		t.Error("signatures differ for identical requests")
+	}
+
+	r3 := newReq()
+	signV4(r3, []byte("y"), creds, "us-east-1", "s3", now)
+	if r1.Header.Get("Authorization") == r3.Header.Get("Authorization") {
+		t.Error("signature should depend on payload")
+	}
+	if r1.Header.Get("X-Amz-Date") != "20240101T000000Z" {
+		t.Errorf("X-Amz-Date = %q", r1.Header.Get("X-Amz-Date"))
+	}
+}
+
+func TestGCSSink_Put(t *testing.T) {
+	t.Setenv("GOOGLE_OAUTH_ACCESS_TOKEN", "tok")
+
+	var gotName, gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/upload/storage/v1/b/bucket/o" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		gotName = r.URL.Query().Get("name")
+		gotAuth = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	s := NewGCSSink("bucket", WithEndpoint(server.URL))
+	if err := s.Put(context.Background(), "a/b.parquet", []byte("data"), "x"); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if gotName != "a/b.parquet" {
+		t.Errorf("name = %q", gotName)
+	}
+	if gotAuth != "Bearer tok" {
+		t.Errorf("Authorization = %q", gotAuth)
+	}
+}
+
+func TestGCSSink_Put_ServerError(t *testing.T) {
+	t.Setenv("GOOGLE_OAUTH_ACCESS_TOKEN", "tok")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	s := NewGCSSink("bucket", WithEndpoint(server.URL))
+	if err := s.Put(context.Background(), "k", nil, "x"); err == nil {
+		t.Error("Put() should fail on 403")
+	}
+}
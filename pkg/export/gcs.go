@@ -0,0 +1,110 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/secret"
+)
+
+const (
+	defaultGCSEndpoint = "https://storage.googleapis.com"
+	gceTokenURL        = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+// GCSSink uploads objects to Google Cloud Storage using the JSON API.
+type GCSSink struct {
+	bucket string
+	cfg    sinkConfig
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+// NewGCSSink creates a sink for the given bucket. The access token is read
+// from GOOGLE_OAUTH_ACCESS_TOKEN or requested from the GCE metadata server
+// (GKE workload identity).
+func NewGCSSink(bucket string, opts ...SinkOption) *GCSSink {
+	cfg := newSinkConfig(opts)
+	if cfg.endpoint == "" {
+		cfg.endpoint = defaultGCSEndpoint
+	}
+	return &GCSSink{
+		bucket: bucket,
+		cfg:    cfg,
+	}
+}
+
+// Put implements Sink.
+func (s *GCSSink) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	q := url.Values{}
+	q.Set("uploadType", "media")
+	q.Set("name", key)
+	endpoint := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?%s", s.cfg.endpoint, url.PathEscape(s.bucket), q.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	token, err := s.accessToken(ctx)
+	if err != nil {
+		return fmt.Errorf("resolve GCS access token: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	return doUpload(s.cfg.httpClient, req)
+}
+
+func (s *GCSSink) accessToken(ctx context.Context) (string, error) {
+	if token, err := secret.FromEnv("GOOGLE_OAUTH_ACCESS_TOKEN").Get(); err != nil {
+		return "", fmt.Errorf("GOOGLE_OAUTH_ACCESS_TOKEN: %w", err)
+	} else if token != "" {
+		return token, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && time.Until(s.tokenExpiry) > time.Minute {
+		return s.token, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gceTokenURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	resp, err := s.cfg.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("decode response: %w", err)
+	}
+
+	s.token = result.AccessToken
+	s.tokenExpiry = time.Now().Add(time.Duration(result.ExpiresIn) * time.Second)
+	return s.token, nil
+}
@@ -0,0 +1,313 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/secret"
+)
+
+// SinkOption is a functional option for configuring object storage sinks.
+type SinkOption func(*sinkConfig)
+
+type sinkConfig struct {
+	endpoint   string
+	region     string
+	httpClient *http.Client
+}
+
+// WithEndpoint overrides the object storage endpoint, e.g. for MinIO or tests.
+// S3 requests against a custom endpoint use path-style addressing.
+func WithEndpoint(endpoint string) SinkOption {
+	return func(c *sinkConfig) {
+		c.endpoint = strings.TrimRight(endpoint, "/")
+	}
+}
+
+// WithRegion sets the AWS region used for S3 request signing.
+func WithRegion(region string) SinkOption {
+	return func(c *sinkConfig) {
+		c.region = region
+	}
+}
+
+// WithHTTPClient sets the HTTP client used for uploads.
+func WithHTTPClient(hc *http.Client) SinkOption {
+	return func(c *sinkConfig) {
+		c.httpClient = hc
+	}
+}
+
+func newSinkConfig(opts []SinkOption) sinkConfig {
+	cfg := sinkConfig{
+		region:     firstNonEmpty(os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"), "us-east-1"),
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// S3Sink uploads objects to Amazon S3 (or an S3-compatible store) using
+// AWS Signature Version 4.
+type S3Sink struct {
+	bucket string
+	cfg    sinkConfig
+	creds  *awsCredentials
+}
+
+// NewS3Sink creates a sink for the given bucket. Credentials are read from
+// AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY/AWS_SESSION_TOKEN, or obtained via
+// AssumeRoleWithWebIdentity when AWS_ROLE_ARN and AWS_WEB_IDENTITY_TOKEN_FILE
+// are set (EKS IAM roles for service accounts).
+func NewS3Sink(bucket string, opts ...SinkOption) *S3Sink {
+	cfg := newSinkConfig(opts)
+	return &S3Sink{
+		bucket: bucket,
+		cfg:    cfg,
+		creds:  &awsCredentials{httpClient: cfg.httpClient},
+	}
+}
+
+// Put implements Sink.
+func (s *S3Sink) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	var endpoint string
+	if s.cfg.endpoint != "" {
+		endpoint = s.cfg.endpoint + "/" + s.bucket + "/" + escapePath(key)
+	} else {
+		endpoint = fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.bucket, s.cfg.region, escapePath(key))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	creds, err := s.creds.get(ctx)
+	if err != nil {
+		return fmt.Errorf("resolve AWS credentials: %w", err)
+	}
+	signV4(req, body, creds, s.cfg.region, "s3", time.Now())
+
+	return doUpload(s.cfg.httpClient, req)
+}
+
+func doUpload(hc *http.Client, req *http.Request) error {
+	resp, err := hc.Do(req)
+	if err != nil {
+		return fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// signV4 signs req in place with AWS Signature Version 4.
+func signV4(req *http.Request, body []byte, creds credentials, region, service string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+
+	signedHeaders := []string{"content-type", "host", "x-amz-content-sha256", "x-amz-date"}
+	if creds.sessionToken != "" {
+		signedHeaders = append(signedHeaders, "x-amz-security-token")
+	}
+
+	var canonicalHeaders strings.Builder
+	for _, h := range signedHeaders {
+		v := req.Header.Get(h)
+		if h == "host" {
+			v = req.URL.Host
+		}
+		canonicalHeaders.WriteString(h + ":" + strings.TrimSpace(v) + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+creds.secretAccessKey), day)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.accessKeyID, scope, strings.Join(signedHeaders, ";"), signature,
+	))
+}
+
+type credentials struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+	expires         time.Time
+}
+
+// awsCredentials resolves and caches AWS credentials from the environment.
+type awsCredentials struct {
+	httpClient *http.Client
+
+	mu     sync.Mutex
+	cached credentials
+}
+
+func (a *awsCredentials) get(ctx context.Context) (credentials, error) {
+	// Static keys may be given in files (AWS_ACCESS_KEY_ID_FILE etc.),
+	// re-read on every upload so that rotated keys are used.
+	if id := secret.FromEnv("AWS_ACCESS_KEY_ID"); id != nil {
+		var creds credentials
+		var err error
+		if creds.accessKeyID, err = id.Get(); err != nil {
+			return credentials{}, fmt.Errorf("AWS_ACCESS_KEY_ID: %w", err)
+		}
+		if creds.secretAccessKey, err = secret.FromEnv("AWS_SECRET_ACCESS_KEY").Get(); err != nil {
+			return credentials{}, fmt.Errorf("AWS_SECRET_ACCESS_KEY: %w", err)
+		}
+		if creds.sessionToken, err = secret.FromEnv("AWS_SESSION_TOKEN").Get(); err != nil {
+			return credentials{}, fmt.Errorf("AWS_SESSION_TOKEN: %w", err)
+		}
+		return creds, nil
+	}
+
+	roleARN := os.Getenv("AWS_ROLE_ARN")
+	tokenFile := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+	if roleARN == "" || tokenFile == "" {
+		return credentials{}, fmt.Errorf("no credentials found: set AWS_ACCESS_KEY_ID or AWS_ROLE_ARN/AWS_WEB_IDENTITY_TOKEN_FILE")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.cached.accessKeyID != "" && time.Until(a.cached.expires) > 5*time.Minute {
+		return a.cached, nil
+	}
+
+	creds, err := a.assumeRoleWithWebIdentity(ctx, roleARN, tokenFile)
+	if err != nil {
+		return credentials{}, err
+	}
+	a.cached = creds
+	return creds, nil
+}
+
+func (a *awsCredentials) assumeRoleWithWebIdentity(ctx context.Context, roleARN, tokenFile string) (credentials, error) {
+	token, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return credentials{}, fmt.Errorf("read web identity token: %w", err)
+	}
+
+	q := url.Values{}
+	q.Set("Action", "AssumeRoleWithWebIdentity")
+	q.Set("Version", "2011-06-15")
+	q.Set("RoleArn", roleARN)
+	q.Set("RoleSessionName", firstNonEmpty(os.Getenv("AWS_ROLE_SESSION_NAME"), "opencost-cloudcost-exporter"))
+	q.Set("WebIdentityToken", strings.TrimSpace(string(token)))
+
+	endpoint := "https://sts.amazonaws.com/"
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		endpoint = "https://sts." + region + ".amazonaws.com/"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+q.Encode(), nil)
+	if err != nil {
+		return credentials{}, fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return credentials{}, fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return credentials{}, fmt.Errorf("read response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return credentials{}, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Credentials struct {
+			AccessKeyID     string    `xml:"AccessKeyId"`
+			SecretAccessKey string    `xml:"SecretAccessKey"`
+			SessionToken    string    `xml:"SessionToken"`
+			Expiration      time.Time `xml:"Expiration"`
+		} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return credentials{}, fmt.Errorf("decode response: %w", err)
+	}
+
+	return credentials{
+		accessKeyID:     result.Credentials.AccessKeyID,
+		secretAccessKey: result.Credentials.SecretAccessKey,
+		sessionToken:    result.Credentials.SessionToken,
+		expires:         result.Credentials.Expiration,
+	}, nil
+}
+
+// escapePath URI-encodes each segment of an object key as required by SigV4.
+func escapePath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+		segments[i] = strings.ReplaceAll(segments[i], "=", "%3D")
+	}
+	return strings.Join(segments, "/")
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,197 @@
+// Package exporter runs the exporter's pipeline of OpenCost client, cache,
+// collector and metrics server as a library, for programs embedding it
+// instead of running the opencost-cloudcost-exporter binary.
+//
+// It covers the core of the binary: the cloud cost metrics, their cache and
+// the /metrics, /healthz and /readyz endpoints. Features of the binary such
+// as federation, leader election, exports and alerts are assembled in its
+// main package from the packages under pkg and are not part of Config.
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/cache"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/client"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/collector"
+)
+
+// Defaults of the zero values of Config, those of the binary's flags.
+const (
+	DefaultWindow   = "2d"
+	DefaultCacheTTL = time.Hour
+	DefaultMaxStale = 6 * time.Hour
+)
+
+// shutdownTimeout bounds how long Run waits for in-flight scrapes when its
+// context is canceled.
+const shutdownTimeout = 10 * time.Second
+
+// Config configures an Exporter.
+type Config struct {
+	// OpenCostURL is the base URL of the OpenCost API, e.g.
+	// "http://opencost.opencost:9003". It is required unless Source is set.
+	OpenCostURL string
+	// Window is the time window of the cost queries, DefaultWindow if
+	// empty.
+	Window string
+	// CacheTTL is how long fetched costs are served before they are
+	// fetched again, DefaultCacheTTL if zero.
+	CacheTTL time.Duration
+	// MaxStale is how long past CacheTTL the previous costs are served
+	// while fetches fail, DefaultMaxStale if zero.
+	MaxStale time.Duration
+	// Addr is the address the metrics server listens on, e.g. ":9100". If
+	// empty, Run serves nothing, for programs serving Handler or gathering
+	// Registry themselves.
+	Addr string
+	// ClientOptions configure the OpenCost client, e.g. its authentication
+	// or retries. Window is applied before them.
+	ClientOptions []client.Option
+	// CollectorOptions configure the collector, e.g. its currency symbols,
+	// label mappings or refresh schedule.
+	CollectorOptions []collector.Option
+	// Source replaces the OpenCost client as the source of the cloud costs,
+	// e.g. to serve costs from another system. ClientOptions and Window
+	// are then ignored.
+	Source collector.CostSource
+}
+
+// Exporter is the pipeline of an embedded exporter. Create it with New and
+// start it with Run.
+type Exporter struct {
+	source    collector.CostSource
+	cache     *cache.Cache
+	collector *collector.CloudCostCollector
+	registry  *prometheus.Registry
+	handler   http.Handler
+	addr      string
+}
+
+// New creates an Exporter from cfg. Its metrics are registered in a
+// registry of their own, see Registry.
+func New(cfg Config) (*Exporter, error) {
+	if cfg.CacheTTL < 0 || cfg.MaxStale < 0 {
+		return nil, errors.New("cache TTL and max stale age must not be negative")
+	}
+	if cfg.Window == "" {
+		cfg.Window = DefaultWindow
+	}
+	if cfg.CacheTTL == 0 {
+		cfg.CacheTTL = DefaultCacheTTL
+	}
+	if cfg.MaxStale == 0 {
+		cfg.MaxStale = DefaultMaxStale
+	}
+
+	e := &Exporter{
+		source:   cfg.Source,
+		cache:    cache.New(cfg.CacheTTL, cfg.MaxStale),
+		registry: prometheus.NewRegistry(),
+		addr:     cfg.Addr,
+	}
+	if e.source == nil {
+		u, err := url.Parse(cfg.OpenCostURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid OpenCost URL %q", cfg.OpenCostURL)
+		}
+		requests := client.NewRequestMetrics()
+		if err := e.registry.Register(requests); err != nil {
+			return nil, fmt.Errorf("register client metrics: %w", err)
+		}
+		opts := append([]client.Option{client.WithWindow(cfg.Window), client.WithRequestMetrics(requests)}, cfg.ClientOptions...)
+		e.source = client.New(cfg.OpenCostURL, opts...)
+	}
+	e.collector = collector.New(e.source, e.cache, cfg.CollectorOptions...)
+	if err := e.registry.Register(e.collector); err != nil {
+		return nil, fmt.Errorf("register collector: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.InstrumentMetricHandler(e.registry, promhttp.HandlerFor(e.registry, promhttp.HandlerOpts{})))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/readyz", e.ready)
+	e.handler = mux
+	return e, nil
+}
+
+// Registry returns the registry of the exporter's metrics, for programs
+// gathering them alongside their own, e.g. with prometheus.Gatherers.
+func (e *Exporter) Registry() *prometheus.Registry {
+	return e.registry
+}
+
+// Handler returns the handler of the metrics server: /metrics, /healthz
+// and /readyz, the latter reporting ready once costs are cached or the
+// source is reachable.
+func (e *Exporter) Handler() http.Handler {
+	return e.handler
+}
+
+// Collector returns the collector, e.g. to refresh its cache or change its
+// currency symbols at runtime.
+func (e *Exporter) Collector() *collector.CloudCostCollector {
+	return e.collector
+}
+
+// Run refreshes the cache on the collector's refresh schedule, if any, and
+// serves Handler on the configured address until ctx is canceled. It then
+// shuts the server down gracefully and returns nil, or returns the error
+// the server failed with. Run must be called only once.
+func (e *Exporter) Run(ctx context.Context) error {
+	go e.collector.RunSchedule(ctx)
+	if e.addr == "" {
+		<-ctx.Done()
+		return nil
+	}
+
+	server := &http.Server{
+		Addr:         e.addr,
+		Handler:      e.handler,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		slog.Info("server listening", "addr", e.addr)
+		errCh <- server.ListenAndServe()
+	}()
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("serve metrics: %w", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("shut down metrics server: %w", err)
+	}
+	return nil
+}
+
+// ready reports ready if costs are cached or, before the first fetch, the
+// source is reachable.
+func (e *Exporter) ready(w http.ResponseWriter, r *http.Request) {
+	if !e.cache.IsPopulated() {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+		if err := e.source.Ping(ctx); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("not ready: " + err.Error()))
+			return
+		}
+	}
+	w.Write([]byte("ready"))
+}
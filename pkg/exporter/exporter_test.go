@@ -0,0 +1,129 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/collector"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/opencosttest"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+func get(t *testing.T, h http.Handler, path string) (int, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	body, _ := io.ReadAll(rec.Body)
+	return rec.Code, string(body)
+}
+
+func TestNew(t *testing.T) {
+	server := opencosttest.NewServer(opencosttest.WithResponse(opencosttest.Response(
+		opencosttest.Item("111", "AmazonEC2", "Compute", 1.5),
+	)))
+	defer server.Close()
+
+	e, err := New(Config{
+		OpenCostURL:      server.URL,
+		Window:           "7d",
+		CollectorOptions: []collector.Option{collector.WithCurrencySymbols(nil)},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if code, body := get(t, e.Handler(), "/readyz"); code != http.StatusOK {
+		t.Errorf("/readyz = %d %q, want 200", code, body)
+	}
+	code, body := get(t, e.Handler(), "/metrics")
+	if code != http.StatusOK {
+		t.Fatalf("/metrics = %d, want 200", code)
+	}
+	for _, want := range []string{`aws_cloud_cost_total{`, `cloudcost_exporter_client_requests_total{code="200",endpoint="/healthz",target="opencost"} 1`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/metrics misses %s", want)
+		}
+	}
+	// The readiness check pinged OpenCost before the scrape fetched.
+	if got := server.Requests()[1].Query.Get("window"); got != "7d" {
+		t.Errorf("window = %q, want 7d", got)
+	}
+	families, err := e.Registry().Gather()
+	if err != nil || len(families) == 0 {
+		t.Errorf("Registry().Gather() = %d families, %v", len(families), err)
+	}
+}
+
+type downSource struct{}
+
+func (downSource) FetchCloudCosts(context.Context) (*types.CloudCostResponse, error) {
+	return nil, errors.New("down")
+}
+
+func (downSource) Ping(context.Context) error { return errors.New("down") }
+
+func TestNew_Source(t *testing.T) {
+	e, err := New(Config{Source: downSource{}})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if code, body := get(t, e.Handler(), "/readyz"); code != http.StatusServiceUnavailable || body != "not ready: down" {
+		t.Errorf("/readyz = %d %q, want 503", code, body)
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"no URL", Config{}},
+		{"relative URL", Config{OpenCostURL: "opencost:9003"}},
+		{"negative TTL", Config{OpenCostURL: "http://opencost:9003", CacheTTL: -time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.cfg); err == nil {
+				t.Error("New() should fail")
+			}
+		})
+	}
+}
+
+func TestExporter_Run(t *testing.T) {
+	e, err := New(Config{OpenCostURL: "http://opencost:9003", Addr: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- e.Run(ctx) }()
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() did not return after cancel")
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	e, err = New(Config{OpenCostURL: "http://opencost:9003", Addr: lis.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Run(context.Background()); err == nil {
+		t.Error("Run() should fail on an address in use")
+	}
+}
@@ -0,0 +1,307 @@
+// Package federation merges the cloud costs of several OpenCost instances,
+// typically one per cluster, into a single view.
+//
+// Instances whose clusters share a cloud account all report that account's
+// line items. To count them once, every provider ID is kept only from the
+// first source, in configured order, that reports it; items without a
+// provider ID are de-duplicated by their item key. Kept items are tagged
+// with the name of their source.
+package federation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/client"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+// Endpoint is a named OpenCost URL with the client settings of the
+// instance. Zero settings leave the exporter-wide ones in effect.
+type Endpoint struct {
+	Name string
+	URL  string
+
+	// TokenFile holds the bearer token requests are authenticated with.
+	TokenFile string
+	// APIKeyFile holds the API key requests are authenticated with.
+	APIKeyFile string
+	TLS        client.TLSOptions
+	Timeout    time.Duration
+	// Cluster is the cluster label of the instance's cost items without
+	// one, e.g. for one OpenCost per cluster.
+	Cluster string
+}
+
+// ParseEndpoints parses comma-separated "name=url" pairs, e.g.
+// "eu=http://opencost.eu:9003,us=http://opencost.us:9003". Names must be
+// unique; their order sets the de-duplication priority. The URL may be
+// followed by semicolon-separated settings of the instance, e.g.
+// "eu=https://opencost.eu;cluster=prod-eu;timeout=10s"; see parseSetting.
+func ParseEndpoints(s string) ([]Endpoint, error) {
+	var endpoints []Endpoint
+	seen := make(map[string]bool)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, rest, ok := strings.Cut(pair, "=")
+		settings := strings.Split(rest, ";")
+		name, rawURL := strings.TrimSpace(name), strings.TrimSpace(settings[0])
+		if !ok || name == "" || rawURL == "" {
+			return nil, fmt.Errorf("invalid federation source %q: want name=url", pair)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate federation source %q", name)
+		}
+		u, err := url.Parse(rawURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid URL of federation source %q: %q", name, rawURL)
+		}
+		e := Endpoint{Name: name, URL: rawURL}
+		for _, setting := range settings[1:] {
+			if err := e.parseSetting(strings.TrimSpace(setting)); err != nil {
+				return nil, fmt.Errorf("federation source %q: %w", name, err)
+			}
+		}
+		if !e.TLS.IsZero() && u.Scheme != "https" {
+			return nil, fmt.Errorf("federation source %q: TLS settings need an https URL", name)
+		}
+		seen[name] = true
+		endpoints = append(endpoints, e)
+	}
+	if len(endpoints) == 0 {
+		return nil, errors.New("no federation sources")
+	}
+	return endpoints, nil
+}
+
+// parseSetting parses a key=value setting of the endpoint: token-file,
+// api-key-file, ca-file, cert-file, key-file, server-name, insecure-skip-verify (true or
+// false), timeout (a duration) or cluster.
+func (e *Endpoint) parseSetting(setting string) error {
+	if setting == "" {
+		return nil
+	}
+	key, value, ok := strings.Cut(setting, "=")
+	key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+	if !ok || value == "" {
+		return fmt.Errorf("invalid setting %q: want key=value", setting)
+	}
+	switch key {
+	case "token-file":
+		e.TokenFile = value
+	case "api-key-file":
+		e.APIKeyFile = value
+	case "ca-file":
+		e.TLS.CAFile = value
+	case "cert-file":
+		e.TLS.CertFile = value
+	case "key-file":
+		e.TLS.KeyFile = value
+	case "server-name":
+		e.TLS.ServerName = value
+	case "insecure-skip-verify":
+		insecure, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid insecure-skip-verify %q", value)
+		}
+		e.TLS.InsecureSkipVerify = insecure
+	case "timeout":
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			return fmt.Errorf("invalid timeout %q", value)
+		}
+		e.Timeout = timeout
+	case "cluster":
+		e.Cluster = value
+	default:
+		return fmt.Errorf("unknown setting %q", key)
+	}
+	return nil
+}
+
+// Clusters returns the cluster names of the endpoints that have one, keyed
+// by endpoint name, for collector.WithSourceClusters.
+func Clusters(endpoints []Endpoint) map[string]string {
+	clusters := make(map[string]string)
+	for _, e := range endpoints {
+		if e.Cluster != "" {
+			clusters[e.Name] = e.Cluster
+		}
+	}
+	return clusters
+}
+
+// Source is a federated OpenCost instance.
+type Source struct {
+	Name   string
+	Client *client.Client
+}
+
+// Federation fetches from all sources and merges their responses. It is a
+// prometheus.Collector reporting the state of each source.
+type Federation struct {
+	sources []Source
+	window  func() string
+
+	up      *prometheus.GaugeVec
+	dropped *prometheus.GaugeVec
+}
+
+// New creates a Federation of sources, in priority order. Each fetch queries
+// the window returned by window, so that it follows configuration reloads.
+func New(window func() string, sources ...Source) *Federation {
+	f := &Federation{
+		sources: sources,
+		window:  window,
+		up: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "cloudcost_exporter",
+			Name:      "federation_source_up",
+			Help:      "Whether the last fetch from the federated OpenCost instance succeeded (1) or failed (0)",
+		}, []string{"source"}),
+		dropped: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "cloudcost_exporter",
+			Name:      "federation_duplicate_items",
+			Help:      "Cost items of the federated OpenCost instance dropped as duplicates in the last fetch",
+		}, []string{"source"}),
+	}
+	for _, s := range sources {
+		f.up.WithLabelValues(s.Name)
+		f.dropped.WithLabelValues(s.Name)
+	}
+	return f
+}
+
+// Describe implements prometheus.Collector.
+func (f *Federation) Describe(ch chan<- *prometheus.Desc) {
+	f.up.Describe(ch)
+	f.dropped.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (f *Federation) Collect(ch chan<- prometheus.Metric) {
+	f.up.Collect(ch)
+	f.dropped.Collect(ch)
+}
+
+// result is the outcome of fetching one source.
+type result struct {
+	name string
+	data *types.CloudCostResponse
+	err  error
+}
+
+// Fetch fetches from all sources concurrently and returns the merged
+// response. Failed sources are left out of it; Fetch fails only if every
+// source fails. It has the signature of collector.Fetcher.
+func (f *Federation) Fetch(ctx context.Context) (*types.CloudCostResponse, error) {
+	return f.FetchWindow(ctx, f.window())
+}
+
+// FetchWindow is Fetch for the given window instead of the federation's.
+func (f *Federation) FetchWindow(ctx context.Context, window string) (*types.CloudCostResponse, error) {
+	results := make([]result, len(f.sources))
+	var wg sync.WaitGroup
+	for i, s := range f.sources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, err := s.Client.FetchCloudCostsWindow(ctx, window)
+			results[i] = result{name: s.Name, data: data, err: err}
+		}()
+	}
+	wg.Wait()
+
+	var errs []error
+	for _, r := range results {
+		if r.err != nil {
+			f.up.WithLabelValues(r.name).Set(0)
+			slog.WarnContext(ctx, "failed to fetch cloud costs from federated OpenCost", "source", r.name, "error", r.err)
+			errs = append(errs, fmt.Errorf("%s: %w", r.name, r.err))
+			continue
+		}
+		f.up.WithLabelValues(r.name).Set(1)
+	}
+	if len(errs) == len(results) {
+		return nil, fmt.Errorf("all federation sources failed: %w", errors.Join(errs...))
+	}
+
+	merged, dropped := merge(results)
+	for _, r := range results {
+		f.dropped.WithLabelValues(r.name).Set(float64(dropped[r.name]))
+	}
+	return merged, nil
+}
+
+// Ping succeeds if any source is reachable.
+func (f *Federation) Ping(ctx context.Context) error {
+	var errs []error
+	for _, s := range f.sources {
+		err := s.Client.Ping(ctx)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", s.Name, err))
+	}
+	return errors.Join(errs...)
+}
+
+// merge combines the successful results, set by set, and returns the number
+// of duplicate items dropped per source.
+func merge(results []result) (*types.CloudCostResponse, map[string]int) {
+	owner := make(map[string]string) // provider ID -> source
+	sets := 0
+	for _, r := range results {
+		if r.data == nil {
+			continue
+		}
+		sets = max(sets, len(r.data.Data.Sets))
+		for _, set := range r.data.Data.Sets {
+			for _, item := range set.CloudCosts {
+				if id := item.Properties.ProviderID; id != "" && owner[id] == "" {
+					owner[id] = r.name
+				}
+			}
+		}
+	}
+
+	out := &types.CloudCostResponse{Code: http.StatusOK, Data: types.CloudCostData{Sets: make([]types.CloudCostSet, sets)}}
+	for i := range out.Data.Sets {
+		out.Data.Sets[i].CloudCosts = make(map[string]types.CloudCostItem)
+	}
+	dropped := make(map[string]int)
+	for _, r := range results {
+		if r.data == nil {
+			continue
+		}
+		for i, set := range r.data.Data.Sets {
+			out.Data.Sets[i].Skip(set.Skipped()...)
+			if out.Data.Sets[i].Window.Validate() != nil {
+				out.Data.Sets[i].Window = set.Window
+			}
+			merged := out.Data.Sets[i].CloudCosts
+			for key, item := range set.CloudCosts {
+				id := item.Properties.ProviderID
+				if _, seen := merged[key]; seen || (id != "" && owner[id] != r.name) {
+					dropped[r.name]++
+					continue
+				}
+				item.Source = r.name
+				merged[key] = item
+			}
+		}
+	}
+	return out, dropped
+}
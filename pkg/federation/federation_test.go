@@ -0,0 +1,160 @@
+package federation
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/client"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/opencosttest"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+func item(providerID string, cost float64) types.CloudCostItem {
+	i := opencosttest.Item("123", "AmazonEC2", "Compute", cost)
+	i.Properties.ProviderID = providerID
+	return i
+}
+
+func newSource(t *testing.T, name string, items ...types.CloudCostItem) (Source, *opencosttest.Server) {
+	t.Helper()
+	server := opencosttest.NewServer(opencosttest.WithResponse(opencosttest.Response(items...)))
+	t.Cleanup(server.Close)
+	return Source{Name: name, Client: client.New(server.URL, client.WithMaxRetries(0))}, server
+}
+
+func TestParseEndpoints(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []Endpoint
+		wantErr bool
+	}{
+		{
+			name:  "ordered",
+			input: "eu=http://opencost.eu:9003, us=https://opencost.us",
+			want:  []Endpoint{{Name: "eu", URL: "http://opencost.eu:9003"}, {Name: "us", URL: "https://opencost.us"}},
+		},
+		{
+			name:  "settings",
+			input: "eu=https://opencost.eu;token-file=/var/run/eu/token;api-key-file=/var/run/eu/api-key; ca-file=/etc/eu/ca.crt;cert-file=/etc/eu/tls.crt;key-file=/etc/eu/tls.key;server-name=opencost;insecure-skip-verify=false;timeout=10s;cluster=prod-eu,us=http://opencost.us;",
+			want: []Endpoint{
+				{
+					Name:       "eu",
+					URL:        "https://opencost.eu",
+					TokenFile:  "/var/run/eu/token",
+					APIKeyFile: "/var/run/eu/api-key",
+					TLS:        client.TLSOptions{CAFile: "/etc/eu/ca.crt", CertFile: "/etc/eu/tls.crt", KeyFile: "/etc/eu/tls.key", ServerName: "opencost"},
+					Timeout:    10 * time.Second,
+					Cluster:    "prod-eu",
+				},
+				{Name: "us", URL: "http://opencost.us"},
+			},
+		},
+		{name: "unknown setting", input: "eu=https://a;user=admin", wantErr: true},
+		{name: "setting without value", input: "eu=https://a;timeout", wantErr: true},
+		{name: "invalid timeout", input: "eu=https://a;timeout=-1s", wantErr: true},
+		{name: "invalid insecure", input: "eu=https://a;insecure-skip-verify=maybe", wantErr: true},
+		{name: "TLS over http", input: "eu=http://a;ca-file=/ca.crt", wantErr: true},
+		{name: "empty", input: " , ", wantErr: true},
+		{name: "missing name", input: "=http://opencost:9003", wantErr: true},
+		{name: "missing url", input: "eu", wantErr: true},
+		{name: "invalid url", input: "eu=opencost:9003", wantErr: true},
+		{name: "duplicate", input: "eu=http://a,eu=http://b", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseEndpoints(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseEndpoints() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseEndpoints() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFederation_Fetch(t *testing.T) {
+	// Both clusters run in the shared account and report its items.
+	eu, euServer := newSource(t, "eu", item("i-shared", 10), item("i-eu", 1), item("", 3))
+	us, _ := newSource(t, "us", item("i-shared", 10), item("i-us", 2), item("", 3))
+	f := New(func() string { return "7d" }, eu, us)
+
+	data, err := f.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if w := euServer.Requests()[0].Query.Get("window"); w != "7d" {
+		t.Errorf("window = %q, want 7d", w)
+	}
+
+	got := make(map[string]string) // item key -> source
+	var total float64
+	for _, set := range data.Data.Sets {
+		for key, item := range set.CloudCosts {
+			got[key] = item.Source
+			total += item.ListCost.Cost
+		}
+	}
+	want := map[string]string{"i-shared": "eu", "i-eu": "eu", "i-us": "us", "item-2": "eu"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("merged items = %v, want %v", got, want)
+	}
+	if total != 16 {
+		t.Errorf("total cost = %v, want 16 (shared items counted once)", total)
+	}
+	if n := testutil.ToFloat64(f.dropped.WithLabelValues("us")); n != 2 {
+		t.Errorf("duplicate items of us = %v, want 2", n)
+	}
+	if n := testutil.ToFloat64(f.dropped.WithLabelValues("eu")); n != 0 {
+		t.Errorf("duplicate items of eu = %v, want 0", n)
+	}
+}
+
+func TestFederation_FetchFailures(t *testing.T) {
+	eu, euServer := newSource(t, "eu", item("i-shared", 10))
+	us, usServer := newSource(t, "us", item("i-shared", 10), item("i-us", 2))
+	f := New(func() string { return "1d" }, eu, us)
+
+	// The shared items fall back to the next source while eu is down.
+	euServer.FailNext(1, http.StatusInternalServerError)
+	data, err := f.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if src := data.Data.Sets[0].CloudCosts["i-shared"].Source; src != "us" {
+		t.Errorf("source of shared item = %q, want us", src)
+	}
+	if up := testutil.ToFloat64(f.up.WithLabelValues("eu")); up != 0 {
+		t.Errorf("eu up = %v, want 0", up)
+	}
+	if up := testutil.ToFloat64(f.up.WithLabelValues("us")); up != 1 {
+		t.Errorf("us up = %v, want 1", up)
+	}
+
+	euServer.FailNext(1, http.StatusInternalServerError)
+	usServer.FailNext(1, http.StatusInternalServerError)
+	if _, err := f.Fetch(context.Background()); err == nil {
+		t.Error("Fetch() should fail when every source fails")
+	}
+}
+
+func TestFederation_Ping(t *testing.T) {
+	eu, euServer := newSource(t, "eu")
+	us, usServer := newSource(t, "us")
+	f := New(func() string { return "1d" }, eu, us)
+
+	euServer.SetHealthy(false)
+	if err := f.Ping(context.Background()); err != nil {
+		t.Errorf("Ping() with one healthy source error = %v", err)
+	}
+	usServer.SetHealthy(false)
+	if err := f.Ping(context.Background()); err == nil {
+		t.Error("Ping() should fail when no source is healthy")
+	}
+}
@@ -0,0 +1,251 @@
+// Package grpcapi serves the exporter's cached cloud cost data over gRPC so
+// other services can consume it with typed clients.
+package grpcapi
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/api/cloudcostv1"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/cache"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+// Server implements cloudcostv1.CloudCostServiceServer on top of the cache.
+type Server struct {
+	cloudcostv1.UnimplementedCloudCostServiceServer
+
+	cache *cache.Cache
+
+	mu          sync.Mutex
+	subscribers map[chan *cloudcostv1.CostUpdate]struct{}
+	done        chan struct{}
+	closeOnce   sync.Once
+}
+
+// New creates a new Server reading from ca.
+func New(ca *cache.Cache) *Server {
+	return &Server{
+		cache:       ca,
+		subscribers: make(map[chan *cloudcostv1.CostUpdate]struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// Close ends the StreamUpdates streams with codes.Unavailable, so that
+// grpc.Server.GracefulStop, which waits for every open RPC, need not wait
+// for subscribers to disconnect. Streams opened afterwards end at once.
+func (s *Server) Close() {
+	s.closeOnce.Do(func() { close(s.done) })
+}
+
+// Register registers the service on gs.
+func (s *Server) Register(gs *grpc.Server) {
+	cloudcostv1.RegisterCloudCostServiceServer(gs, s)
+}
+
+// ListCosts implements cloudcostv1.CloudCostServiceServer.
+func (s *Server) ListCosts(_ context.Context, req *cloudcostv1.ListCostsRequest) (*cloudcostv1.ListCostsResponse, error) {
+	data, fetchedAt, err := s.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &cloudcostv1.ListCostsResponse{
+		FetchedAt: timestamppb.New(fetchedAt),
+	}
+	for _, set := range data.Data.Sets {
+		for _, item := range set.CloudCosts {
+			p := item.Properties
+			if !matches(req.GetAccountId(), p.AccountID) ||
+				!matches(req.GetService(), p.Service) ||
+				!matches(req.GetCategory(), p.Category) {
+				continue
+			}
+			resp.Items = append(resp.Items, toProtoItem(item))
+		}
+	}
+	return resp, nil
+}
+
+// GetSummary implements cloudcostv1.CloudCostServiceServer.
+func (s *Server) GetSummary(_ context.Context, req *cloudcostv1.GetSummaryRequest) (*cloudcostv1.GetSummaryResponse, error) {
+	for _, dim := range req.GetGroupBy() {
+		if _, ok := dimensions[dim]; !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "unsupported group_by dimension %q", dim)
+		}
+	}
+
+	data, fetchedAt, err := s.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	type group struct {
+		values map[string]string
+		costs  *cloudcostv1.CostValues
+	}
+	groups := make(map[string]*group)
+	total := &cloudcostv1.CostValues{}
+
+	for _, set := range data.Data.Sets {
+		for _, item := range set.CloudCosts {
+			values := make(map[string]string, len(req.GetGroupBy()))
+			parts := make([]string, 0, len(req.GetGroupBy()))
+			for _, dim := range req.GetGroupBy() {
+				v := dimensions[dim](item.Properties)
+				values[dim] = v
+				parts = append(parts, v)
+			}
+			key := strings.Join(parts, "\x00")
+
+			g, ok := groups[key]
+			if !ok {
+				g = &group{values: values, costs: &cloudcostv1.CostValues{}}
+				groups[key] = g
+			}
+			addCosts(g.costs, item)
+			addCosts(total, item)
+		}
+	}
+
+	keys := make([]string, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	resp := &cloudcostv1.GetSummaryResponse{
+		Total:     total,
+		FetchedAt: timestamppb.New(fetchedAt),
+	}
+	for _, k := range keys {
+		resp.Rows = append(resp.Rows, &cloudcostv1.SummaryRow{
+			Group: groups[k].values,
+			Costs: groups[k].costs,
+		})
+	}
+	return resp, nil
+}
+
+// StreamUpdates implements cloudcostv1.CloudCostServiceServer.
+func (s *Server) StreamUpdates(_ *cloudcostv1.StreamUpdatesRequest, stream cloudcostv1.CloudCostService_StreamUpdatesServer) error {
+	ch := make(chan *cloudcostv1.CostUpdate, 1)
+
+	s.mu.Lock()
+	s.subscribers[ch] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.subscribers, ch)
+		s.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-s.done:
+			return status.Error(codes.Unavailable, "server is shutting down")
+		case update := <-ch:
+			if err := stream.Send(update); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Notify publishes a refresh to all StreamUpdates subscribers. It matches
+// collector.RefreshHook so it can be registered with WithRefreshHook.
+// Slow subscribers miss intermediate updates rather than blocking refreshes.
+func (s *Server) Notify(_ context.Context, data *types.CloudCostResponse) {
+	update := &cloudcostv1.CostUpdate{
+		FetchedAt: timestamppb.New(time.Now()),
+		Total:     &cloudcostv1.CostValues{},
+	}
+	for _, set := range data.Data.Sets {
+		for _, item := range set.CloudCosts {
+			update.ItemCount++
+			addCosts(update.Total, item)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subscribers {
+		select {
+		case ch <- update:
+		default:
+		}
+	}
+}
+
+func (s *Server) snapshot() (*types.CloudCostResponse, time.Time, error) {
+	data, fetchedAt, ok := s.cache.Snapshot()
+	if !ok {
+		return nil, time.Time{}, status.Error(codes.Unavailable, "no cost data cached yet")
+	}
+	return data, fetchedAt, nil
+}
+
+// dimensions maps supported group_by values to property accessors.
+var dimensions = map[string]func(types.CloudCostProperties) string{
+	"provider":   func(p types.CloudCostProperties) string { return p.Provider },
+	"account_id": func(p types.CloudCostProperties) string { return p.AccountID },
+	"service":    func(p types.CloudCostProperties) string { return p.Service },
+	"category":   func(p types.CloudCostProperties) string { return p.Category },
+	"region":     func(p types.CloudCostProperties) string { return p.RegionID },
+}
+
+func matches(filter, value string) bool {
+	return filter == "" || filter == value
+}
+
+func addCosts(dst *cloudcostv1.CostValues, item types.CloudCostItem) {
+	dst.List += item.ListCost.Cost
+	dst.Net += item.NetCost.Cost
+	dst.AmortizedNet += item.AmortizedNetCost.Cost
+	dst.Invoiced += item.InvoicedCost.Cost
+	dst.Amortized += item.AmortizedCost.Cost
+}
+
+func toProtoItem(item types.CloudCostItem) *cloudcostv1.CostItem {
+	p := item.Properties
+	return &cloudcostv1.CostItem{
+		ProviderId:       p.ProviderID,
+		Provider:         p.Provider,
+		AccountId:        p.AccountID,
+		AccountName:      p.AccountName,
+		Service:          p.Service,
+		Category:         p.Category,
+		Region:           p.RegionID,
+		AvailabilityZone: p.AvailabilityZone,
+		Labels:           p.Labels,
+		WindowStart:      timestamp(item.Window.Start),
+		WindowEnd:        timestamp(item.Window.End),
+		Costs: &cloudcostv1.CostValues{
+			List:         item.ListCost.Cost,
+			Net:          item.NetCost.Cost,
+			AmortizedNet: item.AmortizedNetCost.Cost,
+			Invoiced:     item.InvoicedCost.Cost,
+			Amortized:    item.AmortizedCost.Cost,
+		},
+		KubernetesPercent: item.ListCost.KubernetesPercent,
+	}
+}
+
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
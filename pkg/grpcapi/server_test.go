@@ -0,0 +1,197 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/api/cloudcostv1"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/cache"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+func testData() *types.CloudCostResponse {
+	return &types.CloudCostResponse{
+		Code: 200,
+		Data: types.CloudCostData{
+			Sets: []types.CloudCostSet{{
+				CloudCosts: map[string]types.CloudCostItem{
+					"a": {
+						Properties:       types.CloudCostProperties{AccountID: "111", Service: "AmazonEC2", Category: "Compute"},
+						Window:           types.Window{Start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+						ListCost:         types.CostValue{Cost: 10},
+						AmortizedNetCost: types.CostValue{Cost: 8},
+					},
+					"b": {
+						Properties:       types.CloudCostProperties{AccountID: "222", Service: "AmazonS3", Category: "Storage"},
+						ListCost:         types.CostValue{Cost: 5},
+						AmortizedNetCost: types.CostValue{Cost: 4},
+					},
+					"c": {
+						Properties:       types.CloudCostProperties{AccountID: "111", Service: "AmazonS3", Category: "Storage"},
+						ListCost:         types.CostValue{Cost: 1},
+						AmortizedNetCost: types.CostValue{Cost: 1},
+					},
+				},
+			}},
+		},
+	}
+}
+
+func newTestClient(t *testing.T, srv *Server) cloudcostv1.CloudCostServiceClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer()
+	srv.Register(gs)
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("grpc.NewClient() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return cloudcostv1.NewCloudCostServiceClient(conn)
+}
+
+func TestServer_ListCosts(t *testing.T) {
+	ca := cache.New(time.Hour, time.Hour)
+	ca.Set(testData())
+	cl := newTestClient(t, New(ca))
+
+	tests := []struct {
+		name string
+		req  *cloudcostv1.ListCostsRequest
+		want int
+	}{
+		{"no filter", &cloudcostv1.ListCostsRequest{}, 3},
+		{"by account", &cloudcostv1.ListCostsRequest{AccountId: "111"}, 2},
+		{"by account and service", &cloudcostv1.ListCostsRequest{AccountId: "111", Service: "AmazonS3"}, 1},
+		{"no match", &cloudcostv1.ListCostsRequest{Category: "Network"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := cl.ListCosts(context.Background(), tt.req)
+			if err != nil {
+				t.Fatalf("ListCosts() error = %v", err)
+			}
+			if len(resp.GetItems()) != tt.want {
+				t.Errorf("got %d items, want %d", len(resp.GetItems()), tt.want)
+			}
+			if resp.GetFetchedAt() == nil {
+				t.Error("fetched_at should be set")
+			}
+		})
+	}
+}
+
+func TestServer_ListCosts_EmptyCache(t *testing.T) {
+	cl := newTestClient(t, New(cache.New(time.Hour, time.Hour)))
+
+	_, err := cl.ListCosts(context.Background(), &cloudcostv1.ListCostsRequest{})
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("ListCosts() code = %v, want Unavailable", status.Code(err))
+	}
+}
+
+func TestServer_GetSummary(t *testing.T) {
+	ca := cache.New(time.Hour, time.Hour)
+	ca.Set(testData())
+	cl := newTestClient(t, New(ca))
+
+	resp, err := cl.GetSummary(context.Background(), &cloudcostv1.GetSummaryRequest{GroupBy: []string{"account_id"}})
+	if err != nil {
+		t.Fatalf("GetSummary() error = %v", err)
+	}
+	if len(resp.GetRows()) != 2 {
+		t.Fatalf("got %d rows, want 2", len(resp.GetRows()))
+	}
+	if got := resp.GetRows()[0].GetGroup()["account_id"]; got != "111" {
+		t.Errorf("first row account_id = %q, want 111", got)
+	}
+	if got := resp.GetRows()[0].GetCosts().GetList(); got != 11 {
+		t.Errorf("account 111 list cost = %v, want 11", got)
+	}
+	if got := resp.GetTotal().GetAmortizedNet(); got != 13 {
+		t.Errorf("total amortized_net = %v, want 13", got)
+	}
+}
+
+func TestServer_GetSummary_InvalidDimension(t *testing.T) {
+	ca := cache.New(time.Hour, time.Hour)
+	ca.Set(testData())
+	cl := newTestClient(t, New(ca))
+
+	_, err := cl.GetSummary(context.Background(), &cloudcostv1.GetSummaryRequest{GroupBy: []string{"bogus"}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("GetSummary() code = %v, want InvalidArgument", status.Code(err))
+	}
+}
+
+func TestServer_StreamUpdates(t *testing.T) {
+	srv := New(cache.New(time.Hour, time.Hour))
+	cl := newTestClient(t, srv)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := cl.StreamUpdates(ctx, &cloudcostv1.StreamUpdatesRequest{})
+	if err != nil {
+		t.Fatalf("StreamUpdates() error = %v", err)
+	}
+
+	// Wait for the subscription to be registered before notifying.
+	for i := 0; i < 100; i++ {
+		srv.mu.Lock()
+		n := len(srv.subscribers)
+		srv.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	srv.Notify(ctx, testData())
+
+	update, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv() error = %v", err)
+	}
+	if update.GetItemCount() != 3 {
+		t.Errorf("item_count = %d, want 3", update.GetItemCount())
+	}
+	if update.GetTotal().GetList() != 16 {
+		t.Errorf("total list = %v, want 16", update.GetTotal().GetList())
+	}
+}
+
+func TestServer_Close(t *testing.T) {
+	srv := New(cache.New(time.Hour, time.Hour))
+	cl := newTestClient(t, srv)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := cl.StreamUpdates(ctx, &cloudcostv1.StreamUpdatesRequest{})
+	if err != nil {
+		t.Fatalf("StreamUpdates() error = %v", err)
+	}
+
+	srv.Close()
+	if _, err := stream.Recv(); status.Code(err) != codes.Unavailable {
+		t.Errorf("Recv() error = %v, want Unavailable", err)
+	}
+}
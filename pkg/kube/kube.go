@@ -0,0 +1,155 @@
+// Package kube is a minimal client for the Kubernetes API, authenticating
+// with the pod's service account. It covers the few calls the exporter
+// makes without depending on a Kubernetes client library.
+package kube
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// ServiceAccountDir holds the in-cluster credentials.
+const ServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// Client calls the Kubernetes API.
+type Client struct {
+	url   string
+	token func() (string, error)
+	hc    *http.Client
+}
+
+// NewClient creates a Client for the API at url with a static bearer token.
+func NewClient(url, token string, hc *http.Client) *Client {
+	if hc == nil {
+		hc = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Client{
+		url:   strings.TrimSuffix(url, "/"),
+		token: func() (string, error) { return token, nil },
+		hc:    hc,
+	}
+}
+
+// InCluster creates a Client from the pod's service account.
+func InCluster() (*Client, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not running in Kubernetes (KUBERNETES_SERVICE_HOST is not set)")
+	}
+	ca, err := os.ReadFile(ServiceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("read service account CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("invalid service account CA certificate")
+	}
+
+	return &Client{
+		url:   "https://" + net.JoinHostPort(host, port),
+		token: TokenFile(ServiceAccountDir + "/token"),
+		hc: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+		},
+	}, nil
+}
+
+// TokenFile returns a token source reading the bearer token in path, such as
+// a projected service account token. The file is re-read on every call
+// because the kubelet rotates the token before it expires.
+func TokenFile(path string) func() (string, error) {
+	return func() (string, error) {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		token := strings.TrimSpace(string(b))
+		if token == "" {
+			return "", fmt.Errorf("%s is empty", path)
+		}
+		return token, nil
+	}
+}
+
+// Namespace returns the pod's namespace, or "default" outside a cluster.
+func Namespace() string {
+	b, err := os.ReadFile(ServiceAccountDir + "/namespace")
+	if err != nil || len(bytes.TrimSpace(b)) == 0 {
+		return "default"
+	}
+	return string(bytes.TrimSpace(b))
+}
+
+// Do sends a request with a JSON body to path, e.g.
+// "/apis/coordination.k8s.io/v1/namespaces/default/leases". The caller
+// must close the response body.
+func (c *Client) Do(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	return c.do(ctx, method, path, "application/json", body)
+}
+
+// Patch sends a JSON merge patch to path. The caller must close the
+// response body.
+func (c *Client) Patch(ctx context.Context, path string, patch []byte) (*http.Response, error) {
+	return c.do(ctx, http.MethodPatch, path, "application/merge-patch+json", patch)
+}
+
+func (c *Client) do(ctx context.Context, method, path, contentType string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.url+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	token, err := c.token()
+	if err != nil {
+		return nil, fmt.Errorf("read service account token: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	return resp, nil
+}
+
+// NodeLabels returns the labels of the node name.
+func (c *Client) NodeLabels(ctx context.Context, name string) (map[string]string, error) {
+	resp, err := c.Do(ctx, http.MethodGet, "/api/v1/nodes/"+url.PathEscape(name), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get node %s: %w", name, APIError(resp))
+	}
+	var node struct {
+		Metadata struct {
+			Labels map[string]string `json:"labels"`
+		} `json:"metadata"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&node); err != nil {
+		return nil, fmt.Errorf("decode node %s: %w", name, err)
+	}
+	return node.Metadata.Labels, nil
+}
+
+// APIError returns an error describing an unsuccessful response.
+func APIError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("kubernetes API returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+}
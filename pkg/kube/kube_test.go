@@ -0,0 +1,139 @@
+package kube
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestClient_Do(t *testing.T) {
+	tests := []struct {
+		name            string
+		do              func(c *Client) (*http.Response, error)
+		wantMethod      string
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name: "get",
+			do: func(c *Client) (*http.Response, error) {
+				return c.Do(context.Background(), http.MethodGet, "/api/v1/x", nil)
+			},
+			wantMethod: http.MethodGet,
+		},
+		{
+			name: "put",
+			do: func(c *Client) (*http.Response, error) {
+				return c.Do(context.Background(), http.MethodPut, "/api/v1/x", []byte(`{"a":1}`))
+			},
+			wantMethod:      http.MethodPut,
+			wantContentType: "application/json",
+			wantBody:        `{"a":1}`,
+		},
+		{
+			name: "patch",
+			do: func(c *Client) (*http.Response, error) {
+				return c.Patch(context.Background(), "/api/v1/x", []byte(`{"status":{}}`))
+			},
+			wantMethod:      http.MethodPatch,
+			wantContentType: "application/merge-patch+json",
+			wantBody:        `{"status":{}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				switch {
+				case r.Header.Get("Authorization") != "Bearer secret":
+					t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+				case r.Method != tt.wantMethod:
+					t.Errorf("method = %s, want %s", r.Method, tt.wantMethod)
+				case r.URL.Path != "/api/v1/x":
+					t.Errorf("path = %s", r.URL.Path)
+				case r.Header.Get("Content-Type") != tt.wantContentType:
+					t.Errorf("Content-Type = %q, want %q", r.Header.Get("Content-Type"), tt.wantContentType)
+				case string(body) != tt.wantBody:
+					t.Errorf("body = %q, want %q", body, tt.wantBody)
+				}
+			}))
+			defer srv.Close()
+
+			resp, err := tt.do(NewClient(srv.URL+"/", "secret", srv.Client()))
+			if err != nil {
+				t.Fatalf("request error = %v", err)
+			}
+			resp.Body.Close()
+		})
+	}
+}
+
+func TestClient_NodeLabels(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/nodes/ip-10-0-0-1" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"metadata":{"name":"ip-10-0-0-1","labels":{"alpha.eksctl.io/cluster-name":"eks-main"}}}`))
+	}))
+	defer srv.Close()
+	c := NewClient(srv.URL, "secret", srv.Client())
+
+	labels, err := c.NodeLabels(context.Background(), "ip-10-0-0-1")
+	if err != nil {
+		t.Fatalf("NodeLabels() error = %v", err)
+	}
+	if got := labels["alpha.eksctl.io/cluster-name"]; got != "eks-main" {
+		t.Errorf("cluster-name label = %q, want eks-main", got)
+	}
+
+	if _, err := c.NodeLabels(context.Background(), "missing"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("NodeLabels() for a missing node error = %v, want a 404", err)
+	}
+}
+
+func TestTokenFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	token := TokenFile(path)
+
+	if _, err := token(); err == nil {
+		t.Error("token() should fail for a missing file")
+	}
+	for _, want := range []string{"first", "rotated"} {
+		if err := os.WriteFile(path, []byte(want+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := token(); err != nil || got != want {
+			t.Errorf("token() = %q, %v, want %q", got, err, want)
+		}
+	}
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := token(); err == nil {
+		t.Error("token() should fail for an empty file")
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rec.WriteHeader(http.StatusForbidden)
+	rec.WriteString(`{"message":"leases is forbidden"}` + "\n")
+
+	err := APIError(rec.Result())
+	if err == nil || !strings.Contains(err.Error(), "403") || !strings.Contains(err.Error(), "forbidden") {
+		t.Errorf("APIError() = %v", err)
+	}
+}
+
+func TestInCluster_OutsideCluster(t *testing.T) {
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	if _, err := InCluster(); err == nil {
+		t.Error("InCluster() should fail outside a cluster")
+	}
+}
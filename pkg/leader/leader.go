@@ -0,0 +1,352 @@
+// Package leader implements Kubernetes Lease-based leader election so that,
+// in a highly-available deployment, only one replica fetches from OpenCost.
+// Followers read the leader's cached data over HTTP and all replicas keep
+// serving /metrics.
+//
+// The Lease API is called with the pod's service account via pkg/kube.
+package leader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/kube"
+)
+
+// AddressAnnotation records the leader's advertised base URL on the Lease.
+const AddressAnnotation = "opencost-cloudcost-exporter/address"
+
+// microTime is the Kubernetes MicroTime wire format.
+const microTime = "2006-01-02T15:04:05.000000Z07:00"
+
+var errConflict = errors.New("lease was modified concurrently")
+
+// Config identifies the Lease and this replica.
+type Config struct {
+	// Namespace and Name locate the Lease object.
+	Namespace string
+	Name      string
+	// Identity uniquely identifies this replica, usually the pod name.
+	Identity string
+	// Address is the base URL other replicas use to reach this one.
+	Address string
+	// LeaseDuration is how long a lease is valid without renewal.
+	LeaseDuration time.Duration
+}
+
+// Elector campaigns for and holds a Lease.
+type Elector struct {
+	cfg     Config
+	api     *kube.Client
+	now     func() time.Time
+	retry   time.Duration
+	leading prometheus.Gauge
+
+	mu         sync.Mutex
+	isLeader   bool
+	renewedAt  time.Time
+	holder     string
+	leaderAddr string
+}
+
+// Option configures an Elector.
+type Option func(*Elector)
+
+// WithAPIServer talks to the Kubernetes API at url with a static bearer
+// token instead of the in-cluster configuration.
+func WithAPIServer(url, token string, hc *http.Client) Option {
+	return func(e *Elector) {
+		e.api = kube.NewClient(url, token, hc)
+	}
+}
+
+// WithRetryPeriod sets how often the lease is renewed or retried. It
+// defaults to a third of the lease duration.
+func WithRetryPeriod(d time.Duration) Option {
+	return func(e *Elector) {
+		e.retry = d
+	}
+}
+
+// New creates an Elector. Without WithAPIServer it uses the in-cluster
+// service account.
+func New(cfg Config, opts ...Option) (*Elector, error) {
+	if cfg.Name == "" || cfg.Identity == "" {
+		return nil, errors.New("leader election requires a lease name and identity")
+	}
+	if cfg.LeaseDuration <= 0 {
+		cfg.LeaseDuration = 15 * time.Second
+	}
+	e := &Elector{
+		cfg: cfg,
+		now: time.Now,
+		leading: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "cloudcost_exporter",
+			Name:      "leader",
+			Help:      "Whether this replica is the elected leader (1) or a follower (0)",
+		}),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	if e.retry <= 0 {
+		e.retry = cfg.LeaseDuration / 3
+	}
+	if e.api == nil {
+		api, err := kube.InCluster()
+		if err != nil {
+			return nil, fmt.Errorf("leader election: %w", err)
+		}
+		e.api = api
+	}
+	if e.cfg.Namespace == "" {
+		e.cfg.Namespace = kube.Namespace()
+	}
+	return e, nil
+}
+
+// Run campaigns until ctx is canceled, then releases the lease if held.
+func (e *Elector) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.retry)
+	defer ticker.Stop()
+	for {
+		if err := e.tryAcquireOrRenew(ctx); err != nil && ctx.Err() == nil {
+			slog.Warn("leader election failed", "lease", e.cfg.Name, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			e.release()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// IsLeader reports whether this replica holds a lease renewed within the
+// lease duration.
+func (e *Elector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.isLeader && e.now().Sub(e.renewedAt) < e.cfg.LeaseDuration
+}
+
+// Leader returns the current leader's identity and advertised address, as
+// last observed.
+func (e *Elector) Leader() (identity, address string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.holder, e.leaderAddr
+}
+
+// Describe implements prometheus.Collector.
+func (e *Elector) Describe(ch chan<- *prometheus.Desc) {
+	e.leading.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (e *Elector) Collect(ch chan<- prometheus.Metric) {
+	if e.IsLeader() {
+		e.leading.Set(1)
+	} else {
+		e.leading.Set(0)
+	}
+	e.leading.Collect(ch)
+}
+
+// lease is the subset of a coordination.k8s.io/v1 Lease that is used.
+type lease struct {
+	APIVersion string        `json:"apiVersion"`
+	Kind       string        `json:"kind"`
+	Metadata   leaseMetadata `json:"metadata"`
+	Spec       leaseSpec     `json:"spec"`
+}
+
+type leaseMetadata struct {
+	Name            string            `json:"name"`
+	Namespace       string            `json:"namespace"`
+	ResourceVersion string            `json:"resourceVersion,omitempty"`
+	Annotations     map[string]string `json:"annotations,omitempty"`
+}
+
+type leaseSpec struct {
+	HolderIdentity       string `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds int    `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          string `json:"acquireTime,omitempty"`
+	RenewTime            string `json:"renewTime,omitempty"`
+	LeaseTransitions     int    `json:"leaseTransitions,omitempty"`
+}
+
+// expired reports whether the lease has no holder or was not renewed in
+// time.
+func (l *lease) expired(now time.Time) bool {
+	if l.Spec.HolderIdentity == "" {
+		return true
+	}
+	renewed, err := time.Parse(microTime, l.Spec.RenewTime)
+	if err != nil {
+		return true
+	}
+	return now.After(renewed.Add(time.Duration(l.Spec.LeaseDurationSeconds) * time.Second))
+}
+
+func (e *Elector) tryAcquireOrRenew(ctx context.Context) error {
+	now := e.now()
+	current, err := e.get(ctx)
+	if err != nil {
+		e.setFollower("", "")
+		return err
+	}
+
+	if current == nil {
+		l := e.newLease(now)
+		l.Spec.AcquireTime = now.UTC().Format(microTime)
+		if err := e.write(ctx, http.MethodPost, l); err != nil {
+			return err
+		}
+		e.setLeader(now)
+		slog.Info("acquired leadership", "lease", e.cfg.Name, "identity", e.cfg.Identity)
+		return nil
+	}
+
+	if current.Spec.HolderIdentity != e.cfg.Identity && !current.expired(now) {
+		e.setFollower(current.Spec.HolderIdentity, current.Metadata.Annotations[AddressAnnotation])
+		return nil
+	}
+
+	l := e.newLease(now)
+	l.Metadata.ResourceVersion = current.Metadata.ResourceVersion
+	l.Spec.AcquireTime = current.Spec.AcquireTime
+	l.Spec.LeaseTransitions = current.Spec.LeaseTransitions
+	acquiring := current.Spec.HolderIdentity != e.cfg.Identity
+	if acquiring {
+		l.Spec.AcquireTime = now.UTC().Format(microTime)
+		l.Spec.LeaseTransitions++
+	}
+	if err := e.write(ctx, http.MethodPut, l); err != nil {
+		if errors.Is(err, errConflict) {
+			// Another replica won the race; observe it next round.
+			return nil
+		}
+		return err
+	}
+	e.setLeader(now)
+	if acquiring {
+		slog.Info("acquired leadership", "lease", e.cfg.Name, "identity", e.cfg.Identity, "previous", current.Spec.HolderIdentity)
+	}
+	return nil
+}
+
+// release gives up the lease so another replica can take over immediately.
+func (e *Elector) release() {
+	if !e.IsLeader() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	current, err := e.get(ctx)
+	if err != nil || current == nil || current.Spec.HolderIdentity != e.cfg.Identity {
+		return
+	}
+	current.Spec.HolderIdentity = ""
+	current.Spec.LeaseDurationSeconds = 1
+	if err := e.write(ctx, http.MethodPut, current); err != nil {
+		slog.Warn("failed to release lease", "lease", e.cfg.Name, "error", err)
+		return
+	}
+	e.setFollower("", "")
+	slog.Info("released leadership", "lease", e.cfg.Name)
+}
+
+func (e *Elector) newLease(now time.Time) *lease {
+	return &lease{
+		APIVersion: "coordination.k8s.io/v1",
+		Kind:       "Lease",
+		Metadata: leaseMetadata{
+			Name:        e.cfg.Name,
+			Namespace:   e.cfg.Namespace,
+			Annotations: map[string]string{AddressAnnotation: e.cfg.Address},
+		},
+		Spec: leaseSpec{
+			HolderIdentity:       e.cfg.Identity,
+			LeaseDurationSeconds: int(e.cfg.LeaseDuration.Round(time.Second) / time.Second),
+			RenewTime:            now.UTC().Format(microTime),
+		},
+	}
+}
+
+func (e *Elector) setLeader(now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.isLeader, e.renewedAt = true, now
+	e.holder, e.leaderAddr = e.cfg.Identity, e.cfg.Address
+}
+
+func (e *Elector) setFollower(holder, addr string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.isLeader && holder != "" {
+		slog.Info("lost leadership", "lease", e.cfg.Name, "leader", holder)
+	}
+	e.isLeader = false
+	e.holder, e.leaderAddr = holder, addr
+}
+
+func (e *Elector) leasesPath() string {
+	return fmt.Sprintf("/apis/coordination.k8s.io/v1/namespaces/%s/leases", e.cfg.Namespace)
+}
+
+// get returns the lease, or nil if it does not exist.
+func (e *Elector) get(ctx context.Context) (*lease, error) {
+	resp, err := e.api.Do(ctx, http.MethodGet, e.leasesPath()+"/"+e.cfg.Name, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var l lease
+		if err := json.NewDecoder(resp.Body).Decode(&l); err != nil {
+			return nil, fmt.Errorf("decode lease: %w", err)
+		}
+		return &l, nil
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, kube.APIError(resp)
+	}
+}
+
+// write creates (POST) or updates (PUT) the lease.
+func (e *Elector) write(ctx context.Context, method string, l *lease) error {
+	body, err := json.Marshal(l)
+	if err != nil {
+		return fmt.Errorf("encode lease: %w", err)
+	}
+	path := e.leasesPath()
+	if method == http.MethodPut {
+		path += "/" + e.cfg.Name
+	}
+	resp, err := e.api.Do(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusConflict:
+		return errConflict
+	case resp.StatusCode >= 300:
+		return kube.APIError(resp)
+	}
+	return nil
+}
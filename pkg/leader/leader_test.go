@@ -0,0 +1,247 @@
+package leader
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+// fakeAPI is a minimal Lease API with optimistic concurrency.
+type fakeAPI struct {
+	mu      sync.Mutex
+	lease   *lease
+	version int
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer test-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, "/apis/coordination.k8s.io/v1/namespaces/ns/leases") {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if f.lease == nil {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(f.lease)
+	case http.MethodPost, http.MethodPut:
+		var l lease
+		json.NewDecoder(r.Body).Decode(&l)
+		if r.Method == http.MethodPost && f.lease != nil {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		if r.Method == http.MethodPut && (f.lease == nil || l.Metadata.ResourceVersion != f.lease.Metadata.ResourceVersion) {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		f.version++
+		l.Metadata.ResourceVersion = strconv.Itoa(f.version)
+		f.lease = &l
+		json.NewEncoder(w).Encode(f.lease)
+	}
+}
+
+func (f *fakeAPI) holder() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.lease == nil {
+		return ""
+	}
+	return f.lease.Spec.HolderIdentity
+}
+
+func newElector(t *testing.T, api *httptest.Server, identity string, now func() time.Time) *Elector {
+	t.Helper()
+	e, err := New(Config{
+		Namespace:     "ns",
+		Name:          "exporter",
+		Identity:      identity,
+		Address:       "http://" + identity + ":9100",
+		LeaseDuration: 15 * time.Second,
+	}, WithAPIServer(api.URL, "test-token", api.Client()))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if now != nil {
+		e.now = now
+	}
+	return e
+}
+
+func TestElector_SingleLeader(t *testing.T) {
+	api := httptest.NewServer(&fakeAPI{})
+	defer api.Close()
+	ctx := context.Background()
+
+	a := newElector(t, api, "a", nil)
+	b := newElector(t, api, "b", nil)
+
+	if err := a.tryAcquireOrRenew(ctx); err != nil {
+		t.Fatalf("a: %v", err)
+	}
+	if err := b.tryAcquireOrRenew(ctx); err != nil {
+		t.Fatalf("b: %v", err)
+	}
+	if !a.IsLeader() || b.IsLeader() {
+		t.Fatalf("IsLeader() a=%v b=%v, want only a", a.IsLeader(), b.IsLeader())
+	}
+	if holder, addr := b.Leader(); holder != "a" || addr != "http://a:9100" {
+		t.Errorf("b.Leader() = %q, %q", holder, addr)
+	}
+
+	// Renewal keeps leadership.
+	if err := a.tryAcquireOrRenew(ctx); err != nil || !a.IsLeader() {
+		t.Errorf("renew: err=%v leader=%v", err, a.IsLeader())
+	}
+}
+
+func TestElector_TakeoverAfterExpiry(t *testing.T) {
+	fake := &fakeAPI{}
+	api := httptest.NewServer(fake)
+	defer api.Close()
+	ctx := context.Background()
+
+	now := time.Now()
+	a := newElector(t, api, "a", func() time.Time { return now })
+	a.tryAcquireOrRenew(ctx)
+
+	later := now.Add(time.Minute)
+	b := newElector(t, api, "b", func() time.Time { return later })
+	if err := b.tryAcquireOrRenew(ctx); err != nil {
+		t.Fatalf("b: %v", err)
+	}
+	if !b.IsLeader() || fake.holder() != "b" {
+		t.Errorf("b should take over an expired lease (holder %q)", fake.holder())
+	}
+	if fake.lease.Spec.LeaseTransitions != 1 {
+		t.Errorf("leaseTransitions = %d, want 1", fake.lease.Spec.LeaseTransitions)
+	}
+
+	// a notices it lost the lease.
+	a.now = func() time.Time { return later }
+	a.tryAcquireOrRenew(ctx)
+	if a.IsLeader() {
+		t.Error("a should be a follower after takeover")
+	}
+}
+
+func TestElector_LeadershipExpiresWithoutRenewal(t *testing.T) {
+	api := httptest.NewServer(&fakeAPI{})
+	defer api.Close()
+
+	now := time.Now()
+	a := newElector(t, api, "a", func() time.Time { return now })
+	a.tryAcquireOrRenew(context.Background())
+	if !a.IsLeader() {
+		t.Fatal("a should lead")
+	}
+	now = now.Add(20 * time.Second)
+	if a.IsLeader() {
+		t.Error("leadership should lapse when the lease is not renewed")
+	}
+}
+
+func TestElector_RunReleases(t *testing.T) {
+	fake := &fakeAPI{}
+	api := httptest.NewServer(fake)
+	defer api.Close()
+
+	a := newElector(t, api, "a", nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		a.Run(ctx)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !a.IsLeader() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !a.IsLeader() {
+		t.Fatal("a should acquire the lease")
+	}
+	cancel()
+	<-done
+
+	if fake.holder() != "" {
+		t.Errorf("lease should be released, holder = %q", fake.holder())
+	}
+	b := newElector(t, api, "b", nil)
+	b.tryAcquireOrRenew(context.Background())
+	if !b.IsLeader() {
+		t.Error("b should acquire a released lease immediately")
+	}
+}
+
+func TestFetcher(t *testing.T) {
+	api := httptest.NewServer(&fakeAPI{})
+	defer api.Close()
+
+	cached := &types.CloudCostResponse{Code: 200, Data: types.CloudCostData{Sets: []types.CloudCostSet{{}}}}
+	leaderSrv := httptest.NewServer(CacheHandler(func(context.Context) (*types.CloudCostResponse, bool) {
+		return cached, true
+	}))
+	defer leaderSrv.Close()
+
+	ctx := context.Background()
+	leader, err := New(Config{Namespace: "ns", Name: "exporter", Identity: "a", Address: leaderSrv.URL},
+		WithAPIServer(api.URL, "test-token", api.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	follower := newElector(t, api, "b", nil)
+
+	directCalls := 0
+	direct := func(context.Context) (*types.CloudCostResponse, error) {
+		directCalls++
+		return &types.CloudCostResponse{Code: 200}, nil
+	}
+
+	// No leader known yet: fall back to OpenCost.
+	if _, err := Fetcher(follower, nil, direct)(ctx); err != nil || directCalls != 1 {
+		t.Fatalf("fallback: err=%v direct calls=%d", err, directCalls)
+	}
+
+	leader.tryAcquireOrRenew(ctx)
+	follower.tryAcquireOrRenew(ctx)
+
+	data, err := Fetcher(follower, nil, direct)(ctx)
+	if err != nil {
+		t.Fatalf("follower fetch error = %v", err)
+	}
+	if directCalls != 1 || len(data.Data.Sets) != 1 {
+		t.Errorf("follower should read the leader's cache (direct calls %d, sets %d)", directCalls, len(data.Data.Sets))
+	}
+
+	if _, err := Fetcher(leader, nil, direct)(ctx); err != nil || directCalls != 2 {
+		t.Errorf("leader should fetch directly: err=%v direct calls=%d", err, directCalls)
+	}
+}
+
+func TestCacheHandler_Empty(t *testing.T) {
+	h := CacheHandler(func(context.Context) (*types.CloudCostResponse, bool) { return nil, false })
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, CachePath, nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rec.Code)
+	}
+}
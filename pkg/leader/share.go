@@ -0,0 +1,85 @@
+package leader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+// CachePath is where a replica serves its cached cloud costs to followers.
+const CachePath = "/leader/cache"
+
+// Source returns cached cloud costs, fetching them if necessary, and
+// reports whether data is available.
+type Source func(ctx context.Context) (*types.CloudCostResponse, bool)
+
+// CacheHandler serves the data from src as JSON. Followers read it instead
+// of querying OpenCost.
+func CacheHandler(src Source) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := src(r.Context())
+		if !ok {
+			http.Error(w, "no cloud cost data available", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(data); err != nil {
+			slog.Debug("failed to write cached data", "error", err)
+		}
+	})
+}
+
+// Fetcher returns a fetch function that queries OpenCost via direct while
+// this replica leads, and otherwise reads the leader's cache. If no leader
+// is known (e.g. during startup), it falls back to direct.
+func Fetcher(e *Elector, hc *http.Client, direct func(context.Context) (*types.CloudCostResponse, error)) func(context.Context) (*types.CloudCostResponse, error) {
+	if hc == nil {
+		hc = &http.Client{Timeout: 30 * time.Second}
+	}
+	return func(ctx context.Context) (*types.CloudCostResponse, error) {
+		if e.IsLeader() {
+			return direct(ctx)
+		}
+		holder, addr := e.Leader()
+		if addr == "" {
+			slog.Debug("no leader known, fetching from OpenCost directly")
+			return direct(ctx)
+		}
+		data, err := fetchFromLeader(ctx, hc, addr)
+		if err != nil {
+			return nil, fmt.Errorf("fetch from leader %s: %w", holder, err)
+		}
+		return data, nil
+	}
+}
+
+func fetchFromLeader(ctx context.Context, hc *http.Client, addr string) (*types.CloudCostResponse, error) {
+	endpoint, err := url.JoinPath(addr, CachePath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid leader address: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	var data types.CloudCostResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	return &data, nil
+}
@@ -0,0 +1,210 @@
+// Package memguard watches the exporter's memory usage against the Go
+// memory limit (GOMEMLIMIT) and degrades functionality while it runs short,
+// so that a large OpenCost response slows the exporter down instead of
+// getting it OOM-killed mid-scrape.
+package memguard
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"runtime/debug"
+	"runtime/metrics"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// recovery is the fraction of the threshold usage must fall below to end
+// the pressure, so that usage around the threshold does not flap.
+const recovery = 0.8
+
+// Hook is invoked when memory pressure starts (true) and ends (false).
+type Hook func(pressure bool)
+
+// Guard compares the memory usage of the Go runtime with the memory limit.
+// When usage exceeds threshold times the limit, it runs the hooks, which
+// shed memory, and returns memory to the OS. Without a memory limit, it only
+// reports the usage.
+type Guard struct {
+	threshold float64
+	limit     int64
+	interval  time.Duration
+	usage     func() uint64
+	hooks     []Hook
+
+	mu       sync.Mutex
+	last     uint64
+	pressure bool
+
+	usageDesc    *prometheus.Desc
+	limitDesc    *prometheus.Desc
+	pressureDesc *prometheus.Desc
+	events       prometheus.Counter
+}
+
+// Option is a functional option for configuring the Guard.
+type Option func(*Guard)
+
+// WithHook registers a hook run when memory pressure starts and ends.
+func WithHook(hook Hook) Option {
+	return func(g *Guard) {
+		g.hooks = append(g.hooks, hook)
+	}
+}
+
+// WithInterval sets how often the memory usage is checked (default 5s).
+func WithInterval(interval time.Duration) Option {
+	return func(g *Guard) {
+		g.interval = interval
+	}
+}
+
+// WithLimit replaces the memory limit, which defaults to GOMEMLIMIT or the
+// limit set with debug.SetMemoryLimit.
+func WithLimit(limit int64) Option {
+	return func(g *Guard) {
+		g.limit = limit
+	}
+}
+
+// WithUsage replaces the source of the memory usage, e.g. in tests.
+func WithUsage(usage func() uint64) Option {
+	return func(g *Guard) {
+		g.usage = usage
+	}
+}
+
+// New creates a guard that degrades above threshold, a fraction of the
+// memory limit. A threshold of 0 disables degradation.
+func New(threshold float64, opts ...Option) *Guard {
+	g := &Guard{
+		threshold: threshold,
+		limit:     debug.SetMemoryLimit(-1),
+		interval:  5 * time.Second,
+		usage:     readUsage,
+		usageDesc: prometheus.NewDesc(
+			"cloudcost_exporter_memory_usage_bytes",
+			"Memory used by the Go runtime, as counted against the memory limit",
+			nil, nil,
+		),
+		limitDesc: prometheus.NewDesc(
+			"cloudcost_exporter_memory_limit_bytes",
+			"Memory limit of the Go runtime (GOMEMLIMIT)",
+			nil, nil,
+		),
+		pressureDesc: prometheus.NewDesc(
+			"cloudcost_exporter_memory_pressure",
+			"Whether memory usage exceeds the pressure threshold and the exporter is degraded (1) or not (0)",
+			nil, nil,
+		),
+		events: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "cloudcost_exporter",
+			Name:      "memory_pressure_events_total",
+			Help:      "Number of times memory usage exceeded the pressure threshold",
+		}),
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// readUsage returns the memory the runtime counts against the memory limit.
+func readUsage() uint64 {
+	samples := []metrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+	metrics.Read(samples)
+	return samples[0].Value.Uint64() - samples[1].Value.Uint64()
+}
+
+// limited reports whether a memory limit is set.
+func (g *Guard) limited() bool {
+	return g.limit > 0 && g.limit < math.MaxInt64
+}
+
+// Run checks the memory usage every interval until ctx is canceled.
+func (g *Guard) Run(ctx context.Context) {
+	ticker := time.NewTicker(g.interval)
+	defer ticker.Stop()
+	for {
+		g.Check()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check reads the memory usage and starts or ends the memory pressure.
+func (g *Guard) Check() {
+	usage := g.usage()
+
+	g.mu.Lock()
+	g.last = usage
+	was := g.pressure
+	if g.threshold > 0 && g.limited() {
+		threshold := g.threshold * float64(g.limit)
+		switch {
+		case !was && float64(usage) > threshold:
+			g.pressure = true
+		case was && float64(usage) < recovery*threshold:
+			g.pressure = false
+		}
+	}
+	pressure := g.pressure
+	g.mu.Unlock()
+
+	if pressure == was {
+		return
+	}
+	if pressure {
+		g.events.Inc()
+		slog.Warn("memory usage exceeds the pressure threshold, degrading", "usage_bytes", usage, "limit_bytes", g.limit, "threshold", g.threshold)
+	} else {
+		slog.Info("memory pressure ended", "usage_bytes", usage, "limit_bytes", g.limit)
+	}
+	for _, hook := range g.hooks {
+		hook(pressure)
+	}
+	if pressure {
+		debug.FreeOSMemory()
+	}
+}
+
+// Pressure reports whether memory usage exceeds the threshold.
+func (g *Guard) Pressure() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.pressure
+}
+
+// Describe implements prometheus.Collector.
+func (g *Guard) Describe(ch chan<- *prometheus.Desc) {
+	ch <- g.usageDesc
+	ch <- g.limitDesc
+	ch <- g.pressureDesc
+	g.events.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (g *Guard) Collect(ch chan<- prometheus.Metric) {
+	g.mu.Lock()
+	usage, pressure := g.last, g.pressure
+	g.mu.Unlock()
+
+	ch <- prometheus.MustNewConstMetric(g.usageDesc, prometheus.GaugeValue, float64(usage))
+	if g.limited() {
+		ch <- prometheus.MustNewConstMetric(g.limitDesc, prometheus.GaugeValue, float64(g.limit))
+	}
+	value := 0.0
+	if pressure {
+		value = 1
+	}
+	ch <- prometheus.MustNewConstMetric(g.pressureDesc, prometheus.GaugeValue, value)
+	g.events.Collect(ch)
+}
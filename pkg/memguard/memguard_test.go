@@ -0,0 +1,74 @@
+package memguard
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestGuard_Check(t *testing.T) {
+	var usage uint64
+	var hooked []bool
+	g := New(0.9,
+		WithLimit(1000),
+		WithUsage(func() uint64 { return usage }),
+		WithHook(func(pressure bool) { hooked = append(hooked, pressure) }),
+	)
+
+	// Pressure starts above 900 bytes and ends below 80% of that.
+	for _, step := range []struct {
+		usage uint64
+		want  bool
+	}{
+		{500, false},
+		{950, true},
+		{800, true},
+		{700, false},
+		{910, true},
+	} {
+		usage = step.usage
+		g.Check()
+		if got := g.Pressure(); got != step.want {
+			t.Errorf("usage %d: Pressure() = %v, want %v", step.usage, got, step.want)
+		}
+	}
+	if want := []bool{true, false, true}; !slices.Equal(hooked, want) {
+		t.Errorf("hooks ran with %v, want %v", hooked, want)
+	}
+
+	want := `
+# HELP cloudcost_exporter_memory_pressure_events_total Number of times memory usage exceeded the pressure threshold
+# TYPE cloudcost_exporter_memory_pressure_events_total counter
+cloudcost_exporter_memory_pressure_events_total 2
+# HELP cloudcost_exporter_memory_limit_bytes Memory limit of the Go runtime (GOMEMLIMIT)
+# TYPE cloudcost_exporter_memory_limit_bytes gauge
+cloudcost_exporter_memory_limit_bytes 1000
+# HELP cloudcost_exporter_memory_pressure Whether memory usage exceeds the pressure threshold and the exporter is degraded (1) or not (0)
+# TYPE cloudcost_exporter_memory_pressure gauge
+cloudcost_exporter_memory_pressure 1
+# HELP cloudcost_exporter_memory_usage_bytes Memory used by the Go runtime, as counted against the memory limit
+# TYPE cloudcost_exporter_memory_usage_bytes gauge
+cloudcost_exporter_memory_usage_bytes 910
+`
+	if err := testutil.CollectAndCompare(g, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGuard_NoLimit(t *testing.T) {
+	g := New(0.9, WithLimit(0), WithUsage(func() uint64 { return 1 << 40 }))
+	g.Check()
+	if g.Pressure() {
+		t.Error("Pressure() = true without a memory limit")
+	}
+	if n := testutil.CollectAndCount(g, "cloudcost_exporter_memory_limit_bytes"); n != 0 {
+		t.Errorf("limit series = %d, want none without a limit", n)
+	}
+
+	// The real usage is read from the runtime.
+	if usage := New(0).usage(); usage == 0 {
+		t.Error("usage() = 0")
+	}
+}
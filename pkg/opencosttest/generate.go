@@ -0,0 +1,83 @@
+package opencosttest
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+// Spec describes a synthetic response built by Generate. The number of
+// distinct label values sets the cardinality of the exported series, which
+// is at most the product of Accounts, Services, Regions, Labels (cubed, for
+// the owner, environment and cluster labels) and ProviderIDs.
+type Spec struct {
+	// Sets is the number of consecutive daily sets (default 1).
+	Sets int
+	// Items is the number of cost items per set.
+	Items int
+	// Accounts, Services and Regions are the numbers of distinct account
+	// IDs, services and regions (default 1 each).
+	Accounts int
+	Services int
+	Regions  int
+	// Labels is the number of distinct values of each of the owner,
+	// environment and cluster labels. With 0, items have no labels.
+	Labels int
+	// ProviderIDs is the number of distinct provider IDs. With 0, every
+	// item has a provider ID of its own, as most OpenCost line items do.
+	ProviderIDs int
+	// Start is the start of the first set (default 2026-01-01 UTC).
+	Start time.Time
+	// Seed seeds the costs and label values; the same spec always yields
+	// the same response.
+	Seed uint64
+}
+
+// Generate builds a successful response as described by spec, for
+// benchmarks and load tests that need responses of a realistic size.
+func Generate(spec Spec) *types.CloudCostResponse {
+	spec.Sets = max(spec.Sets, 1)
+	spec.Accounts = max(spec.Accounts, 1)
+	spec.Services = max(spec.Services, 1)
+	spec.Regions = max(spec.Regions, 1)
+	if spec.Start.IsZero() {
+		spec.Start = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	rng := rand.New(rand.NewPCG(spec.Seed, 0))
+
+	sets := make([]types.CloudCostSet, spec.Sets)
+	for s := range sets {
+		w := types.Window{Start: spec.Start.AddDate(0, 0, s), End: spec.Start.AddDate(0, 0, s+1)}
+		costs := make(map[string]types.CloudCostItem, spec.Items)
+		for i := range spec.Items {
+			item := Item(
+				fmt.Sprintf("%012d", rng.IntN(spec.Accounts)),
+				fmt.Sprintf("Service%d", rng.IntN(spec.Services)),
+				"Compute",
+				float64(rng.IntN(100_000))/100,
+			)
+			item.Properties.RegionID = fmt.Sprintf("region-%d", rng.IntN(spec.Regions))
+			item.Properties.ProviderID = fmt.Sprintf("i-%08x", i)
+			if spec.ProviderIDs > 0 {
+				item.Properties.ProviderID = fmt.Sprintf("i-%08x", rng.IntN(spec.ProviderIDs))
+			}
+			if spec.Labels > 0 {
+				item.Properties.Labels = map[string]string{
+					"owner":       fmt.Sprintf("team-%d", rng.IntN(spec.Labels)),
+					"environment": fmt.Sprintf("env-%d", rng.IntN(spec.Labels)),
+					"cluster":     fmt.Sprintf("cluster-%d", rng.IntN(spec.Labels)),
+				}
+			}
+			item.Window = w
+			costs[fmt.Sprintf("item-%d", i)] = item
+		}
+		sets[s] = types.CloudCostSet{Window: w, CloudCosts: costs}
+	}
+	return &types.CloudCostResponse{
+		Code: http.StatusOK,
+		Data: types.CloudCostData{Sets: sets},
+	}
+}
@@ -0,0 +1,73 @@
+package opencosttest
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGenerate(t *testing.T) {
+	spec := Spec{Sets: 3, Items: 200, Accounts: 2, Services: 5, Regions: 3, Labels: 2, ProviderIDs: 10}
+	resp := Generate(spec)
+	if err := resp.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if len(resp.Data.Sets) != 3 {
+		t.Fatalf("got %d sets, want 3", len(resp.Data.Sets))
+	}
+
+	distinct := map[string]map[string]bool{}
+	note := func(label, value string) {
+		if distinct[label] == nil {
+			distinct[label] = map[string]bool{}
+		}
+		distinct[label][value] = true
+	}
+	var prevEnd time.Time
+	for i, set := range resp.Data.Sets {
+		if len(set.CloudCosts) != 200 {
+			t.Errorf("set %d has %d items, want 200", i, len(set.CloudCosts))
+		}
+		if set.Window.Duration() != 24*time.Hour || (i > 0 && !set.Window.Start.Equal(prevEnd)) {
+			t.Errorf("set %d window = %+v, want the day after the previous set", i, set.Window)
+		}
+		prevEnd = set.Window.End
+		for _, item := range set.CloudCosts {
+			note("account", item.Properties.AccountID)
+			note("service", item.Properties.Service)
+			note("region", item.Properties.RegionID)
+			note("owner", item.Properties.Labels["owner"])
+			note("provider", item.Properties.ProviderID)
+		}
+	}
+	for label, want := range map[string]int{"account": 2, "service": 5, "region": 3, "owner": 2, "provider": 10} {
+		if got := len(distinct[label]); got != want {
+			t.Errorf("%d distinct %s values, want %d", got, label, want)
+		}
+	}
+
+	if !reflect.DeepEqual(Generate(spec), resp) {
+		t.Error("Generate() is not deterministic")
+	}
+	spec.Seed = 1
+	if reflect.DeepEqual(Generate(spec), resp) {
+		t.Error("Generate() ignores the seed")
+	}
+}
+
+func TestGenerate_Defaults(t *testing.T) {
+	resp := Generate(Spec{Items: 3})
+	if len(resp.Data.Sets) != 1 || len(resp.Data.Sets[0].CloudCosts) != 3 {
+		t.Fatalf("got %+v, want one set of 3 items", resp.Data.Sets)
+	}
+	ids := map[string]bool{}
+	for _, item := range resp.Data.Sets[0].CloudCosts {
+		ids[item.Properties.ProviderID] = true
+		if item.Properties.Labels != nil {
+			t.Errorf("labels = %v, want none", item.Properties.Labels)
+		}
+	}
+	if len(ids) != 3 {
+		t.Errorf("%d distinct provider IDs, want one per item", len(ids))
+	}
+}
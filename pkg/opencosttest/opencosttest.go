@@ -0,0 +1,271 @@
+// Package opencosttest provides a mock OpenCost server for tests of code
+// that embeds the exporter's client or collector.
+//
+// The server serves /cloudCost from a configurable fixture, /healthz and
+// /metrics, and supports injected latency and failures:
+//
+//	srv := opencosttest.NewServer(
+//		opencosttest.WithResponse(opencosttest.Response(
+//			opencosttest.Item("111", "AmazonEC2", "Compute", 100),
+//		)),
+//	)
+//	defer srv.Close()
+//	cl := client.New(srv.URL)
+package opencosttest
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+// Request records a request received by the server.
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+}
+
+// Server is a mock OpenCost API server. Its configuration may be changed
+// while it is serving.
+type Server struct {
+	// URL is the base URL of the server, for use with client.New.
+	URL string
+
+	srv *httptest.Server
+
+	mu         sync.Mutex
+	body       []byte
+	latency    time.Duration
+	failures   int
+	failStatus int
+	unhealthy  bool
+	gzip       bool
+	metrics    string
+	requests   []Request
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithResponse serves resp from /cloudCost.
+func WithResponse(resp *types.CloudCostResponse) Option {
+	return func(s *Server) {
+		s.setResponse(resp)
+	}
+}
+
+// WithRawResponse serves body verbatim from /cloudCost, e.g. to test
+// malformed or unusual payloads.
+func WithRawResponse(body string) Option {
+	return func(s *Server) {
+		s.body = []byte(body)
+	}
+}
+
+// WithLatency delays every response by d, or until the request is canceled.
+func WithLatency(d time.Duration) Option {
+	return func(s *Server) {
+		s.latency = d
+	}
+}
+
+// WithFailures makes the next n /cloudCost requests fail with status.
+func WithFailures(n, status int) Option {
+	return func(s *Server) {
+		s.failures, s.failStatus = n, status
+	}
+}
+
+// WithGzip compresses /cloudCost responses with gzip for requests that
+// accept it.
+func WithGzip() Option {
+	return func(s *Server) {
+		s.gzip = true
+	}
+}
+
+// WithMetrics serves text in the Prometheus exposition format from /metrics.
+func WithMetrics(text string) Option {
+	return func(s *Server) {
+		s.metrics = text
+	}
+}
+
+// NewServer starts a mock OpenCost server. By default it serves an empty
+// successful response. Callers must Close it.
+func NewServer(opts ...Option) *Server {
+	s := &Server{}
+	s.setResponse(&types.CloudCostResponse{Code: http.StatusOK, Data: types.CloudCostData{Sets: []types.CloudCostSet{}}})
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.srv.URL
+	return s
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// SetResponse replaces the /cloudCost fixture.
+func (s *Server) SetResponse(resp *types.CloudCostResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setResponse(resp)
+}
+
+func (s *Server) setResponse(resp *types.CloudCostResponse) {
+	body, err := json.Marshal(resp)
+	if err != nil {
+		panic(fmt.Sprintf("opencosttest: marshal response: %v", err))
+	}
+	s.body = body
+}
+
+// SetLatency changes the response latency.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// FailNext makes the next n /cloudCost requests fail with status.
+func (s *Server) FailNext(n, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures, s.failStatus = n, status
+}
+
+// SetHealthy controls whether /healthz reports healthy.
+func (s *Server) SetHealthy(healthy bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unhealthy = !healthy
+}
+
+// Requests returns the requests received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// CloudCostRequests returns the number of /cloudCost requests received.
+func (s *Server) CloudCostRequests() int {
+	n := 0
+	for _, r := range s.Requests() {
+		if r.Path == "/cloudCost" {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path, Query: r.URL.Query(), Header: r.Header.Clone()})
+	latency, body, metrics, unhealthy, compress := s.latency, s.body, s.metrics, s.unhealthy, s.gzip
+	fail := 0
+	if r.URL.Path == "/cloudCost" && s.failures > 0 {
+		s.failures--
+		fail = s.failStatus
+	}
+	s.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	switch r.URL.Path {
+	case "/cloudCost":
+		if fail != 0 {
+			http.Error(w, "injected failure", fail)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if compress && strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Header().Set("Content-Encoding", "gzip")
+			zw := gzip.NewWriter(w)
+			zw.Write(body)
+			zw.Close()
+			return
+		}
+		w.Write(body)
+	case "/healthz":
+		if unhealthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	case "/metrics":
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		w.Write([]byte(metrics))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// Response builds a successful response with one set holding items, keyed
+// by their provider ID or, if empty, their position.
+func Response(items ...types.CloudCostItem) *types.CloudCostResponse {
+	costs := make(map[string]types.CloudCostItem, len(items))
+	for i, item := range items {
+		key := item.Properties.ProviderID
+		if key == "" {
+			key = fmt.Sprintf("item-%d", i)
+		}
+		costs[key] = item
+	}
+	return &types.CloudCostResponse{
+		Code: http.StatusOK,
+		Data: types.CloudCostData{Sets: []types.CloudCostSet{{CloudCosts: costs}}},
+	}
+}
+
+// Item builds a cost item with every cost type set to cost.
+func Item(accountID, service, category string, cost float64) types.CloudCostItem {
+	v := types.CostValue{Cost: cost}
+	return types.CloudCostItem{
+		Properties: types.CloudCostProperties{
+			Provider:  "AWS",
+			AccountID: accountID,
+			Service:   service,
+			Category:  category,
+		},
+		ListCost:         v,
+		NetCost:          v,
+		AmortizedNetCost: v,
+		InvoicedCost:     v,
+		AmortizedCost:    v,
+	}
+}
+
+// LoadFixture reads a cloudCost response from a JSON file.
+func LoadFixture(path string) (*types.CloudCostResponse, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read fixture: %w", err)
+	}
+	var resp types.CloudCostResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("decode fixture %s: %w", path, err)
+	}
+	return &resp, nil
+}
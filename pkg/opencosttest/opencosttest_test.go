@@ -0,0 +1,99 @@
+package opencosttest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/client"
+)
+
+func TestServer_Response(t *testing.T) {
+	srv := NewServer(WithResponse(Response(
+		Item("111", "AmazonEC2", "Compute", 100),
+		Item("222", "AmazonS3", "Storage", 5),
+	)))
+	defer srv.Close()
+
+	resp, err := client.New(srv.URL, client.WithWindow("7d")).FetchCloudCosts(context.Background())
+	if err != nil {
+		t.Fatalf("FetchCloudCosts() error = %v", err)
+	}
+	if got := len(resp.Data.Sets[0].CloudCosts); got != 2 {
+		t.Errorf("got %d items, want 2", got)
+	}
+
+	reqs := srv.Requests()
+	if len(reqs) != 1 || reqs[0].Query.Get("window") != "7d" {
+		t.Errorf("requests = %+v, want one with window=7d", reqs)
+	}
+
+	srv.SetResponse(Response())
+	resp, _ = client.New(srv.URL).FetchCloudCosts(context.Background())
+	if got := len(resp.Data.Sets[0].CloudCosts); got != 0 {
+		t.Errorf("after SetResponse got %d items, want 0", got)
+	}
+}
+
+func TestServer_Failures(t *testing.T) {
+	srv := NewServer(WithFailures(1, http.StatusBadGateway))
+	defer srv.Close()
+
+	// The client retries once after a 1s backoff and then succeeds.
+	cl := client.New(srv.URL, client.WithMaxRetries(1))
+	if _, err := cl.FetchCloudCosts(context.Background()); err != nil {
+		t.Fatalf("FetchCloudCosts() error = %v", err)
+	}
+	if got := srv.CloudCostRequests(); got != 2 {
+		t.Errorf("got %d requests, want 2", got)
+	}
+
+	srv.FailNext(1, http.StatusInternalServerError)
+	if _, err := client.New(srv.URL, client.WithMaxRetries(0)).FetchCloudCosts(context.Background()); err == nil {
+		t.Error("FetchCloudCosts() should fail on injected error")
+	}
+}
+
+func TestServer_Latency(t *testing.T) {
+	srv := NewServer(WithLatency(200 * time.Millisecond))
+	defer srv.Close()
+
+	cl := client.New(srv.URL, client.WithTimeout(20*time.Millisecond), client.WithMaxRetries(0))
+	if _, err := cl.FetchCloudCosts(context.Background()); err == nil {
+		t.Error("FetchCloudCosts() should time out")
+	}
+
+	srv.SetLatency(0)
+	if _, err := cl.FetchCloudCosts(context.Background()); err != nil {
+		t.Errorf("FetchCloudCosts() error = %v", err)
+	}
+}
+
+func TestServer_Healthz(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	cl := client.New(srv.URL)
+
+	if err := cl.Ping(context.Background()); err != nil {
+		t.Errorf("Ping() error = %v", err)
+	}
+	srv.SetHealthy(false)
+	if err := cl.Ping(context.Background()); err == nil {
+		t.Error("Ping() should fail when unhealthy")
+	}
+}
+
+func TestLoadFixture(t *testing.T) {
+	resp, err := LoadFixture("../types/testdata/cloudcost-response.json")
+	if err != nil {
+		t.Fatalf("LoadFixture() error = %v", err)
+	}
+	if len(resp.Data.Sets) == 0 {
+		t.Error("fixture should contain sets")
+	}
+
+	if _, err := LoadFixture("missing.json"); err == nil {
+		t.Error("LoadFixture() should fail for a missing file")
+	}
+}
@@ -0,0 +1,283 @@
+// Package operator reconciles the exporter's runtime configuration from a
+// CloudCostExporterConfig custom resource, so platform teams can manage
+// cost-export policy declaratively per cluster.
+//
+// The controller polls a single resource, hands its spec to the exporter
+// as flag values whenever its generation changes, and reports the outcome
+// in the resource's status.
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/kube"
+)
+
+// API group, version and resource of CloudCostExporterConfig.
+const (
+	Group    = "cloudcost-exporter.hawky-4s.github.io"
+	Version  = "v1alpha1"
+	Resource = "cloudcostexporterconfigs"
+)
+
+// Spec is the desired exporter configuration. Empty fields leave the
+// exporter's own configuration in place.
+type Spec struct {
+	Window        string            `json:"window,omitempty"`
+	CacheTTL      string            `json:"cacheTTL,omitempty"`
+	MaxStale      string            `json:"maxStale,omitempty"`
+	AlertRules    []string          `json:"alertRules,omitempty"`
+	Budgets       []string          `json:"budgets,omitempty"`
+	BudgetLevels  string            `json:"budgetLevels,omitempty"`
+	LabelMappings map[string]string `json:"labelMappings,omitempty"`
+	Sinks         Sinks             `json:"sinks,omitempty"`
+}
+
+// Sinks are the destinations cost data and alerts are sent to.
+type Sinks struct {
+	Export           *ExportSink `json:"export,omitempty"`
+	AlertWebhookURL  string      `json:"alertWebhookURL,omitempty"`
+	Slack            *ChatSink   `json:"slack,omitempty"`
+	Teams            *ChatSink   `json:"teams,omitempty"`
+	ReportWebhookURL string      `json:"reportWebhookURL,omitempty"`
+}
+
+// ExportSink configures Parquet export to object storage.
+type ExportSink struct {
+	URL      string `json:"url"`
+	Endpoint string `json:"endpoint,omitempty"`
+	Region   string `json:"region,omitempty"`
+}
+
+// ChatSink configures a Slack or Microsoft Teams alert notifier.
+type ChatSink struct {
+	WebhookURL string `json:"webhookURL,omitempty"`
+	Routes     string `json:"routes,omitempty"`
+}
+
+// Flags returns the spec as exporter flag values keyed by flag name.
+func (s Spec) Flags() map[string]string {
+	flags := make(map[string]string)
+	set := func(name, value string) {
+		if value != "" {
+			flags[name] = value
+		}
+	}
+	set("window", s.Window)
+	set("cache-ttl", s.CacheTTL)
+	set("max-stale", s.MaxStale)
+	set("alert-rules", strings.Join(s.AlertRules, ";"))
+	set("budgets", strings.Join(s.Budgets, ";"))
+	set("budget-levels", s.BudgetLevels)
+	if len(s.LabelMappings) > 0 {
+		var pairs []string
+		for _, label := range slices.Sorted(maps.Keys(s.LabelMappings)) {
+			pairs = append(pairs, label+"="+s.LabelMappings[label])
+		}
+		set("label-mappings", strings.Join(pairs, ","))
+	}
+	if e := s.Sinks.Export; e != nil {
+		set("export-url", e.URL)
+		set("export-endpoint", e.Endpoint)
+		set("export-region", e.Region)
+	}
+	set("alert-webhook-url", s.Sinks.AlertWebhookURL)
+	if c := s.Sinks.Slack; c != nil {
+		set("slack-webhook-url", c.WebhookURL)
+		set("slack-routes", c.Routes)
+	}
+	if c := s.Sinks.Teams; c != nil {
+		set("teams-webhook-url", c.WebhookURL)
+		set("teams-routes", c.Routes)
+	}
+	set("report-webhook-url", s.Sinks.ReportWebhookURL)
+	return flags
+}
+
+// resource is the part of a CloudCostExporterConfig the controller reads.
+type resource struct {
+	Metadata struct {
+		Generation int64 `json:"generation"`
+	} `json:"metadata"`
+	Spec Spec `json:"spec"`
+}
+
+// Condition is a status condition of the resource.
+type Condition struct {
+	Type               string `json:"type"`
+	Status             string `json:"status"`
+	Reason             string `json:"reason"`
+	Message            string `json:"message"`
+	LastTransitionTime string `json:"lastTransitionTime"`
+}
+
+// ApplyFunc applies flag values from the resource to the running exporter.
+// Passing nil removes all values previously applied.
+type ApplyFunc func(flags map[string]string) error
+
+// Controller reconciles one CloudCostExporterConfig.
+type Controller struct {
+	api       *kube.Client
+	namespace string
+	name      string
+	apply     ApplyFunc
+	interval  time.Duration
+	now       func() time.Time
+
+	observed int64 // generation last applied, 0 if none
+}
+
+// Option configures a Controller.
+type Option func(*Controller)
+
+// WithInterval sets how often the resource is polled.
+func WithInterval(d time.Duration) Option {
+	return func(c *Controller) {
+		c.interval = d
+	}
+}
+
+// New creates a Controller for the resource namespace/name.
+func New(api *kube.Client, namespace, name string, apply ApplyFunc, opts ...Option) *Controller {
+	c := &Controller{
+		api:       api,
+		namespace: namespace,
+		name:      name,
+		apply:     apply,
+		interval:  30 * time.Second,
+		now:       time.Now,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// ParseRef parses a "[namespace/]name" resource reference, defaulting the
+// namespace to defaultNamespace.
+func ParseRef(ref, defaultNamespace string) (namespace, name string, err error) {
+	namespace, name, ok := strings.Cut(ref, "/")
+	if !ok {
+		namespace, name = defaultNamespace, ref
+	}
+	if namespace == "" || name == "" || strings.Contains(name, "/") {
+		return "", "", fmt.Errorf("invalid resource reference %q: want [namespace/]name", ref)
+	}
+	return namespace, name, nil
+}
+
+// Run reconciles until ctx is canceled.
+func (c *Controller) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		if err := c.reconcile(ctx); err != nil {
+			slog.Error("failed to reconcile CloudCostExporterConfig", "namespace", c.namespace, "name", c.name, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reconcile applies the resource if its generation changed since the last
+// successful apply, and removes the applied values if it was deleted.
+func (c *Controller) reconcile(ctx context.Context) error {
+	res, err := c.get(ctx)
+	if err != nil {
+		return err
+	}
+	if res == nil {
+		if c.observed != 0 {
+			slog.Info("CloudCostExporterConfig deleted, reverting to the exporter's own configuration", "name", c.name)
+			if err := c.apply(nil); err != nil {
+				return fmt.Errorf("revert configuration: %w", err)
+			}
+			c.observed = 0
+		}
+		return nil
+	}
+	if res.Metadata.Generation == c.observed {
+		return nil
+	}
+
+	applyErr := c.apply(res.Spec.Flags())
+	cond := Condition{
+		Type:               "Ready",
+		Status:             "True",
+		Reason:             "Applied",
+		Message:            "configuration applied",
+		LastTransitionTime: c.now().UTC().Format(time.RFC3339),
+	}
+	if applyErr != nil {
+		cond.Status, cond.Reason, cond.Message = "False", "InvalidSpec", applyErr.Error()
+	}
+	if err := c.updateStatus(ctx, res.Metadata.Generation, cond); err != nil {
+		return err
+	}
+	// A rejected generation is not retried until the resource changes.
+	c.observed = res.Metadata.Generation
+	if applyErr != nil {
+		return fmt.Errorf("apply generation %d: %w", res.Metadata.Generation, applyErr)
+	}
+	slog.Info("applied CloudCostExporterConfig", "name", c.name, "generation", res.Metadata.Generation)
+	return nil
+}
+
+func (c *Controller) path() string {
+	return fmt.Sprintf("/apis/%s/%s/namespaces/%s/%s/%s", Group, Version, c.namespace, Resource, c.name)
+}
+
+// get returns the resource, or nil if it does not exist.
+func (c *Controller) get(ctx context.Context) (*resource, error) {
+	resp, err := c.api.Do(ctx, http.MethodGet, c.path(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var res resource
+		if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+			return nil, fmt.Errorf("decode CloudCostExporterConfig: %w", err)
+		}
+		return &res, nil
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, kube.APIError(resp)
+	}
+}
+
+// updateStatus records the observed generation and condition.
+func (c *Controller) updateStatus(ctx context.Context, generation int64, cond Condition) error {
+	patch, err := json.Marshal(map[string]any{
+		"status": map[string]any{
+			"observedGeneration": generation,
+			"conditions":         []Condition{cond},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("encode status: %w", err)
+	}
+	resp, err := c.api.Patch(ctx, c.path()+"/status", patch)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("update status: %w", kube.APIError(resp))
+	}
+	return nil
+}
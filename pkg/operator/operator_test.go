@@ -0,0 +1,199 @@
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/kube"
+)
+
+const resourcePath = "/apis/" + Group + "/" + Version + "/namespaces/ns/" + Resource + "/cfg"
+
+// fakeAPI serves one CloudCostExporterConfig and records status patches.
+type fakeAPI struct {
+	mu      sync.Mutex
+	object  map[string]any // nil if the resource does not exist
+	patches []map[string]any
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == resourcePath:
+		if f.object == nil {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(f.object)
+	case r.Method == http.MethodPatch && r.URL.Path == resourcePath+"/status":
+		if r.Header.Get("Content-Type") != "application/merge-patch+json" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var patch map[string]any
+		json.Unmarshal(body, &patch)
+		f.patches = append(f.patches, patch)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeAPI) set(generation int, spec map[string]any) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if spec == nil {
+		f.object = nil
+		return
+	}
+	f.object = map[string]any{
+		"metadata": map[string]any{"name": "cfg", "generation": generation},
+		"spec":     spec,
+	}
+}
+
+func (f *fakeAPI) lastCondition(t *testing.T) map[string]any {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.patches) == 0 {
+		t.Fatal("no status patch recorded")
+	}
+	status := f.patches[len(f.patches)-1]["status"].(map[string]any)
+	return status["conditions"].([]any)[0].(map[string]any)
+}
+
+func TestSpec_Flags(t *testing.T) {
+	tests := []struct {
+		name string
+		spec Spec
+		want map[string]string
+	}{
+		{"empty", Spec{}, map[string]string{}},
+		{
+			name: "policy",
+			spec: Spec{
+				Window:        "7d",
+				AlertRules:    []string{"list > 100", "prod: account_id=1 net > 5"},
+				Budgets:       []string{"prod: account_id=1 amortized_net 1000"},
+				LabelMappings: map[string]string{"owner": "team", "environment": "env"},
+			},
+			want: map[string]string{
+				"window":         "7d",
+				"alert-rules":    "list > 100;prod: account_id=1 net > 5",
+				"budgets":        "prod: account_id=1 amortized_net 1000",
+				"label-mappings": "environment=env,owner=team",
+			},
+		},
+		{
+			name: "sinks",
+			spec: Spec{Sinks: Sinks{
+				Export: &ExportSink{URL: "s3://bucket/prefix", Region: "eu-west-1"},
+				Slack:  &ChatSink{WebhookURL: "https://hooks.slack.test/x"},
+			}},
+			want: map[string]string{
+				"export-url":        "s3://bucket/prefix",
+				"export-region":     "eu-west-1",
+				"slack-webhook-url": "https://hooks.slack.test/x",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.spec.Flags(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Flags() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRef(t *testing.T) {
+	tests := []struct {
+		ref           string
+		wantNamespace string
+		wantName      string
+		wantErr       bool
+	}{
+		{"cfg", "default", "cfg", false},
+		{"monitoring/cfg", "monitoring", "cfg", false},
+		{"", "", "", true},
+		{"monitoring/", "", "", true},
+		{"a/b/c", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			ns, name, err := ParseRef(tt.ref, "default")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRef() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if ns != tt.wantNamespace || name != tt.wantName {
+				t.Errorf("ParseRef() = %q, %q, want %q, %q", ns, name, tt.wantNamespace, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestController_Reconcile(t *testing.T) {
+	fake := &fakeAPI{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	var applied []map[string]string
+	applyErr := error(nil)
+	c := New(kube.NewClient(srv.URL, "token", srv.Client()), "ns", "cfg", func(flags map[string]string) error {
+		applied = append(applied, flags)
+		return applyErr
+	})
+	ctx := context.Background()
+
+	// No resource yet: nothing to apply.
+	if err := c.reconcile(ctx); err != nil || len(applied) != 0 {
+		t.Fatalf("reconcile without resource: err=%v applied=%v", err, applied)
+	}
+
+	fake.set(1, map[string]any{"window": "7d"})
+	if err := c.reconcile(ctx); err != nil {
+		t.Fatalf("reconcile() error = %v", err)
+	}
+	if len(applied) != 1 || applied[0]["window"] != "7d" {
+		t.Fatalf("applied = %v, want window=7d", applied)
+	}
+	if cond := fake.lastCondition(t); cond["status"] != "True" {
+		t.Errorf("condition = %v, want Ready=True", cond)
+	}
+
+	// Unchanged generation is not applied again.
+	c.reconcile(ctx)
+	if len(applied) != 1 {
+		t.Errorf("unchanged generation applied again: %v", applied)
+	}
+
+	// A rejected spec is reported in the status.
+	applyErr = errors.New("invalid alert rules")
+	fake.set(2, map[string]any{"alertRules": []string{"bogus"}})
+	if err := c.reconcile(ctx); err == nil {
+		t.Error("reconcile() should return the apply error")
+	}
+	if cond := fake.lastCondition(t); cond["status"] != "False" || cond["message"] != "invalid alert rules" {
+		t.Errorf("condition = %v, want Ready=False with the apply error", cond)
+	}
+
+	// Deleting the resource reverts the applied values.
+	applyErr = nil
+	fake.set(0, nil)
+	if err := c.reconcile(ctx); err != nil {
+		t.Fatalf("reconcile after delete: %v", err)
+	}
+	if last := applied[len(applied)-1]; last != nil {
+		t.Errorf("apply after delete = %v, want nil", last)
+	}
+}
@@ -0,0 +1,112 @@
+// Package otlpmetrics pushes the exporter's Prometheus metrics to an
+// OpenTelemetry collector with an OTLP metric exporter, for pipelines that
+// do not scrape.
+package otlpmetrics
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	prombridge "go.opentelemetry.io/contrib/bridges/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/tracing"
+)
+
+// The OTLP transports.
+const (
+	ProtocolGRPC = "grpc"
+	ProtocolHTTP = "http/protobuf"
+)
+
+// Enabled reports whether metrics are pushed for endpoint, i.e. endpoint
+// or the OTEL_EXPORTER_OTLP_METRICS_ENDPOINT variable is set. Unlike
+// tracing, the generic OTEL_EXPORTER_OTLP_ENDPOINT alone does not enable
+// it, so that sending traces does not start pushing metrics too.
+func Enabled(endpoint string) bool {
+	return endpoint != "" || os.Getenv("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT") != ""
+}
+
+// Setup starts pushing the metrics of gatherer every interval to endpoint
+// over protocol, grpc (e.g. http://otel-collector:4317) or http/protobuf
+// (e.g. http://otel-collector:4318; the /v1/metrics path is added when the
+// URL has none). Counters become cumulative sums and gauges gauges, with
+// the Prometheus labels as attributes. If endpoint is empty the standard
+// OTEL_EXPORTER_OTLP_METRICS_ENDPOINT variable is used; if that is unset
+// too, nothing is pushed. The returned function pushes the metrics a last
+// time and stops the exporter.
+func Setup(ctx context.Context, gatherer prometheus.Gatherer, endpoint, protocol string, interval time.Duration, version string) (func(context.Context) error, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid OTLP metrics interval %s: must be positive", interval)
+	}
+	if protocol != ProtocolGRPC && protocol != ProtocolHTTP {
+		return nil, fmt.Errorf("invalid OTLP metrics protocol %q: want %s or %s", protocol, ProtocolGRPC, ProtocolHTTP)
+	}
+	if !Enabled(endpoint) {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var u *url.URL
+	if endpoint != "" {
+		var err error
+		if u, err = url.Parse(endpoint); err != nil {
+			return nil, fmt.Errorf("invalid OTLP metrics endpoint: %w", err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+			return nil, fmt.Errorf("invalid OTLP metrics endpoint %q: want an http or https URL", endpoint)
+		}
+	}
+	exporter, err := newExporter(ctx, u, protocol)
+	if err != nil {
+		return nil, fmt.Errorf("create OTLP metric exporter: %w", err)
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithFromEnv(),
+		resource.WithAttributes(
+			attribute.String("service.name", tracing.ServiceName),
+			attribute.String("service.version", version),
+		),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create metric resource: %w", err)
+	}
+
+	// The meter provider has no instruments of its own; the bridge hands
+	// the reader whatever the gatherer collects at each push.
+	reader := sdkmetric.NewPeriodicReader(exporter,
+		sdkmetric.WithInterval(interval),
+		sdkmetric.WithProducer(prombridge.NewMetricProducer(prombridge.WithGatherer(gatherer))),
+	)
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader), sdkmetric.WithResource(res))
+	return mp.Shutdown, nil
+}
+
+// newExporter creates the exporter of protocol, sending to u or, if u is
+// nil, to the endpoint of the OTEL_EXPORTER_OTLP_* variables.
+func newExporter(ctx context.Context, u *url.URL, protocol string) (sdkmetric.Exporter, error) {
+	if protocol == ProtocolGRPC {
+		var opts []otlpmetricgrpc.Option
+		if u != nil {
+			opts = append(opts, otlpmetricgrpc.WithEndpointURL(u.String()))
+		}
+		return otlpmetricgrpc.New(ctx, opts...)
+	}
+	var opts []otlpmetrichttp.Option
+	if u != nil {
+		if strings.Trim(u.Path, "/") == "" {
+			u.Path = "/v1/metrics"
+		}
+		opts = append(opts, otlpmetrichttp.WithEndpointURL(u.String()))
+	}
+	return otlpmetrichttp.New(ctx, opts...)
+}
@@ -0,0 +1,149 @@
+package otlpmetrics
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	collectorpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// registry returns a registry of a cost gauge and a counter.
+func registry() *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	cost := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "aws_cloud_cost_total", Help: "Cost"}, []string{"service"})
+	cost.WithLabelValues("AmazonEC2").Set(42)
+	scrapes := prometheus.NewCounter(prometheus.CounterOpts{Name: "cloudcost_exporter_scrapes_total", Help: "Scrapes"})
+	scrapes.Inc()
+	reg.MustRegister(cost, scrapes)
+	return reg
+}
+
+// receiver records the metric requests of an OTLP collector.
+type receiver struct {
+	collectorpb.UnimplementedMetricsServiceServer
+	mu       sync.Mutex
+	requests []*collectorpb.ExportMetricsServiceRequest
+}
+
+func (r *receiver) Export(_ context.Context, req *collectorpb.ExportMetricsServiceRequest) (*collectorpb.ExportMetricsServiceResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+	return &collectorpb.ExportMetricsServiceResponse{}, nil
+}
+
+// check fails t unless the received requests hold the gauge of registry
+// with its label and the counter.
+func (r *receiver) check(t *testing.T) {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.requests) == 0 {
+		t.Fatal("no metrics were pushed")
+	}
+	var gauge, sum bool
+	for _, rm := range r.requests[len(r.requests)-1].GetResourceMetrics() {
+		for _, sm := range rm.GetScopeMetrics() {
+			for _, m := range sm.GetMetrics() {
+				switch m.GetName() {
+				case "aws_cloud_cost_total":
+					dp := m.GetGauge().GetDataPoints()
+					gauge = len(dp) == 1 && dp[0].GetAsDouble() == 42 &&
+						len(dp[0].GetAttributes()) == 1 && dp[0].GetAttributes()[0].GetValue().GetStringValue() == "AmazonEC2"
+				case "cloudcost_exporter_scrapes_total":
+					sum = m.GetSum().GetIsMonotonic()
+				}
+			}
+		}
+	}
+	if !gauge || !sum {
+		t.Errorf("pushed gauge = %v, monotonic sum = %v, want both", gauge, sum)
+	}
+}
+
+func TestSetup_HTTP(t *testing.T) {
+	rec := &receiver{}
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/metrics" {
+			http.NotFound(w, r)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		req := &collectorpb.ExportMetricsServiceRequest{}
+		if err := proto.Unmarshal(body, req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rec.Export(r.Context(), req)
+		w.Header().Set("Content-Type", "application/x-protobuf")
+	}))
+	defer collector.Close()
+
+	shutdown, err := Setup(context.Background(), registry(), collector.URL, ProtocolHTTP, time.Hour, "test")
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+	// Shutting down pushes the metrics a last time.
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown() error = %v", err)
+	}
+	rec.check(t)
+}
+
+func TestSetup_GRPC(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &receiver{}
+	server := grpc.NewServer()
+	collectorpb.RegisterMetricsServiceServer(server, rec)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	shutdown, err := Setup(context.Background(), registry(), "http://"+lis.Addr().String(), ProtocolGRPC, time.Hour, "test")
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown() error = %v", err)
+	}
+	rec.check(t)
+}
+
+func TestSetup_Invalid(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT", "")
+	tests := []struct {
+		name               string
+		endpoint, protocol string
+		interval           time.Duration
+	}{
+		{"unknown protocol", "http://otel:4318", "http/json", time.Minute},
+		{"no interval", "http://otel:4318", ProtocolHTTP, 0},
+		{"no scheme", "otel:4317", ProtocolGRPC, time.Minute},
+		// Checked even while disabled, so that a typo fails at startup.
+		{"disabled with unknown protocol", "", "udp", time.Minute},
+	}
+	for _, tt := range tests {
+		if _, err := Setup(context.Background(), registry(), tt.endpoint, tt.protocol, tt.interval, "test"); err == nil {
+			t.Errorf("%s: Setup() should fail", tt.name)
+		}
+	}
+
+	shutdown, err := Setup(context.Background(), registry(), "", ProtocolHTTP, time.Minute, "test")
+	if err != nil {
+		t.Fatalf("Setup() without endpoint error = %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("shutdown() error = %v", err)
+	}
+}
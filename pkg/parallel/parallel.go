@@ -0,0 +1,43 @@
+// Package parallel runs independent pieces of work on a bounded number of
+// goroutines.
+package parallel
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Workers returns the default number of workers, the number of CPUs the Go
+// runtime may use, which respects container CPU limits.
+func Workers() int {
+	return runtime.GOMAXPROCS(0)
+}
+
+// For calls fn for each index in [0, n) on at most workers goroutines and
+// returns when all calls have returned. With a single worker or index, fn
+// runs on the calling goroutine.
+func For(n, workers int, fn func(i int)) {
+	workers = min(workers, n)
+	if workers <= 1 {
+		for i := range n {
+			fn(i)
+		}
+		return
+	}
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+	for i := range n {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
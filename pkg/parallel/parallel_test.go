@@ -0,0 +1,45 @@
+package parallel
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+func TestFor(t *testing.T) {
+	tests := []struct {
+		name    string
+		n       int
+		workers int
+	}{
+		{"none", 0, 4},
+		{"sequential", 5, 1},
+		{"no workers", 5, 0},
+		{"fewer workers", 100, 4},
+		{"more workers", 3, 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := make([]int, tt.n)
+			var running, peak atomic.Int32
+			For(tt.n, tt.workers, func(i int) {
+				n := running.Add(1)
+				for {
+					p := peak.Load()
+					if n <= p || peak.CompareAndSwap(p, n) {
+						break
+					}
+				}
+				calls[i]++
+				running.Add(-1)
+			})
+			for i, c := range calls {
+				if c != 1 {
+					t.Errorf("fn(%d) called %d times, want 1", i, c)
+				}
+			}
+			if p := int(peak.Load()); p > max(tt.workers, 1) {
+				t.Errorf("%d concurrent calls, want at most %d", p, max(tt.workers, 1))
+			}
+		})
+	}
+}
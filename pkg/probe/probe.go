@@ -0,0 +1,139 @@
+// Package probe serves the cost metrics of an OpenCost instance named by
+// the request, following the multi-target pattern of the blackbox exporter:
+// Prometheus discovers the instances and scrapes
+// /probe?target=<opencost-url>, and the exporter fetches that instance on
+// demand. Nothing is cached between probes.
+package probe
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/client"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/requestid"
+)
+
+// Path is the path the handler is served at.
+const Path = "/probe"
+
+// Func fetches the cost data of the OpenCost instance at target for window,
+// or the configured window if empty, and returns a collector of its
+// metrics.
+type Func func(ctx context.Context, target, window string) (prometheus.Collector, error)
+
+// Handler serves probes.
+type Handler struct {
+	probe   Func
+	allowed *regexp.Regexp
+	timeout time.Duration
+}
+
+// Option configures a Handler.
+type Option func(*Handler)
+
+// WithAllowedTargets restricts the targets to the URLs matching re, which
+// should be anchored, so that the exporter cannot be used to send requests
+// anywhere.
+func WithAllowedTargets(re *regexp.Regexp) Option {
+	return func(h *Handler) {
+		h.allowed = re
+	}
+}
+
+// WithTimeout caps the duration of a probe. Prometheus' scrape timeout,
+// from the X-Prometheus-Scrape-Timeout-Seconds header, caps it further.
+func WithTimeout(timeout time.Duration) Option {
+	return func(h *Handler) {
+		h.timeout = timeout
+	}
+}
+
+// New creates a handler probing targets with probe.
+func New(probe Func, opts ...Option) *Handler {
+	h := &Handler{probe: probe, timeout: 30 * time.Second}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// ServeHTTP implements http.Handler. The response holds the metrics of the
+// target plus probe_success and probe_duration_seconds; a failed fetch is
+// reported with probe_success 0 rather than an error status, so that
+// Prometheus records it.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("target")
+	if err := h.validTarget(target); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	window := r.URL.Query().Get("window")
+	if window != "" {
+		if _, err := client.ResolveWindow(window, time.Now()); err != nil {
+			http.Error(w, fmt.Sprintf("invalid window %q: %v", window, err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(requestid.Ensure(r.Context()), h.probeTimeout(r))
+	defer cancel()
+
+	success := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_success",
+		Help: "Whether the cost data of the target could be fetched",
+	})
+	duration := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_duration_seconds",
+		Help: "Duration of fetching the cost data of the target",
+	})
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(success, duration)
+
+	start := time.Now()
+	collector, err := h.probe(ctx, target, window)
+	duration.Set(time.Since(start).Seconds())
+	if err != nil {
+		slog.WarnContext(ctx, "probe failed", "target", target, "window", window, "error", err)
+	} else if err := reg.Register(collector); err != nil {
+		slog.ErrorContext(ctx, "failed to register probe collector", "target", target, "error", err)
+	} else {
+		success.Set(1)
+	}
+	promhttp.HandlerFor(reg, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+// validTarget checks that target is an http or https URL allowed by the
+// handler.
+func (h *Handler) validTarget(target string) error {
+	if target == "" {
+		return fmt.Errorf("missing target parameter")
+	}
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid target %q: want an http or https URL", target)
+	}
+	if h.allowed != nil && !h.allowed.MatchString(target) {
+		return fmt.Errorf("target %q is not allowed", target)
+	}
+	return nil
+}
+
+// probeTimeout returns the timeout of a probe: the handler's, capped by
+// Prometheus' scrape timeout less a margin for the response.
+func (h *Handler) probeTimeout(r *http.Request) time.Duration {
+	timeout := h.timeout
+	if s := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"); s != "" {
+		if scrape, err := time.ParseDuration(s + "s"); err == nil && scrape > time.Second {
+			timeout = min(timeout, scrape-500*time.Millisecond)
+		}
+	}
+	return timeout
+}
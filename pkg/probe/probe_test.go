@@ -0,0 +1,114 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// fakeProbe records its arguments and returns a collector of one gauge, or
+// err.
+type fakeProbe struct {
+	target, window string
+	deadline       time.Duration
+	err            error
+}
+
+func (f *fakeProbe) probe(ctx context.Context, target, window string) (prometheus.Collector, error) {
+	f.target, f.window = target, window
+	if d, ok := ctx.Deadline(); ok {
+		f.deadline = time.Until(d)
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	g := prometheus.NewGauge(prometheus.GaugeOpts{Name: "aws_cloud_cost_total", Help: "Cost"})
+	g.Set(42)
+	return g, nil
+}
+
+func get(h http.Handler, query url.Values, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, Path+"?"+query.Encode(), nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandler(t *testing.T) {
+	f := &fakeProbe{}
+	h := New(f.probe)
+
+	rec := get(h, url.Values{"target": {"http://opencost.eu:9003"}, "window": {"7d"}}, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{"aws_cloud_cost_total 42", "probe_success 1", "probe_duration_seconds"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("response lacks %q:\n%s", want, body)
+		}
+	}
+	if f.target != "http://opencost.eu:9003" || f.window != "7d" {
+		t.Errorf("probed %q for %q, want http://opencost.eu:9003 for 7d", f.target, f.window)
+	}
+
+	f.err = errors.New("connection refused")
+	rec = get(h, url.Values{"target": {"http://opencost.us:9003"}}, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status of a failed probe = %d, want 200", rec.Code)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "probe_success 0") || strings.Contains(body, "aws_cloud_cost_total") {
+		t.Errorf("failed probe response:\n%s", body)
+	}
+}
+
+func TestHandler_InvalidRequests(t *testing.T) {
+	h := New((&fakeProbe{}).probe, WithAllowedTargets(regexp.MustCompile(`^https://opencost\.[a-z]+\.example(:\d+)?$`)))
+	tests := []struct {
+		name  string
+		query url.Values
+	}{
+		{"missing target", url.Values{}},
+		{"not http", url.Values{"target": {"file:///etc/passwd"}}},
+		{"no host", url.Values{"target": {"http://"}}},
+		{"not allowed", url.Values{"target": {"http://169.254.169.254"}}},
+		{"allowed prefix only", url.Values{"target": {"https://opencost.eu.example.evil.com"}}},
+		{"invalid window", url.Values{"target": {"https://opencost.eu.example"}, "window": {"month offset soon"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := get(h, tt.query, nil); rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", rec.Code)
+			}
+		})
+	}
+	if rec := get(h, url.Values{"target": {"https://opencost.eu.example:9003"}}, nil); rec.Code != http.StatusOK {
+		t.Errorf("status of an allowed target = %d, want 200", rec.Code)
+	}
+}
+
+func TestHandler_Timeout(t *testing.T) {
+	f := &fakeProbe{}
+	h := New(f.probe, WithTimeout(time.Minute))
+	target := url.Values{"target": {"http://opencost:9003"}}
+
+	get(h, target, nil)
+	if f.deadline < 59*time.Second || f.deadline > time.Minute {
+		t.Errorf("deadline = %v, want the handler's timeout", f.deadline)
+	}
+	get(h, target, http.Header{"X-Prometheus-Scrape-Timeout-Seconds": {"10"}})
+	if f.deadline < 9*time.Second || f.deadline > 10*time.Second {
+		t.Errorf("deadline = %v, want below the scrape timeout", f.deadline)
+	}
+}
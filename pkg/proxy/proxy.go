@@ -0,0 +1,266 @@
+// Package proxy serves the OpenCost /cloudCost API through a response cache
+// with the same TTL and stale-data semantics as the exporter, so dashboards
+// querying OpenCost directly do not overload it.
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/requestid"
+)
+
+// Prefix is the API path served by the proxy.
+const Prefix = "/cloudCost"
+
+// Upstream performs GET requests against the OpenCost API.
+type Upstream interface {
+	Get(ctx context.Context, path, rawQuery string) (*http.Response, error)
+}
+
+// entry is a cached upstream response.
+type entry struct {
+	body        []byte
+	contentType string
+	fetchedAt   time.Time
+	refreshing  bool
+}
+
+// call is an in-flight upstream request shared by concurrent misses.
+type call struct {
+	done chan struct{}
+	e    *entry
+	err  error
+}
+
+// Proxy is an http.Handler caching successful upstream responses per path
+// and query. Fresh entries are served directly, stale entries are served
+// while being refreshed in the background, and entries older than
+// ttl+maxStale are fetched synchronously. Upstream failures fall back to any
+// entry within ttl+maxStale.
+type Proxy struct {
+	upstream   Upstream
+	ttl        time.Duration
+	maxStale   time.Duration
+	maxEntries int
+	timeout    time.Duration
+
+	mu       sync.Mutex
+	entries  map[string]*entry
+	inflight map[string]*call
+	degraded atomic.Bool
+
+	requests *prometheus.CounterVec
+}
+
+// Option is a functional option for configuring the Proxy.
+type Option func(*Proxy)
+
+// WithMaxEntries limits the number of cached responses (default 100). The
+// oldest entry is evicted when the limit is reached.
+func WithMaxEntries(n int) Option {
+	return func(p *Proxy) {
+		p.maxEntries = n
+	}
+}
+
+// WithTimeout sets the upstream request timeout (default 60s).
+func WithTimeout(timeout time.Duration) Option {
+	return func(p *Proxy) {
+		p.timeout = timeout
+	}
+}
+
+// New creates a proxy in front of upstream.
+func New(upstream Upstream, ttl, maxStale time.Duration, opts ...Option) *Proxy {
+	p := &Proxy{
+		upstream:   upstream,
+		ttl:        ttl,
+		maxStale:   maxStale,
+		maxEntries: 100,
+		timeout:    60 * time.Second,
+		entries:    make(map[string]*entry),
+		inflight:   make(map[string]*call),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "cloudcost_exporter",
+			Name:      "proxy_requests_total",
+			Help:      "Proxied OpenCost API requests by cache result (hit, stale, miss, error)",
+		}, []string{"result"}),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// SetDegraded drops the cached responses and stops caching new ones while
+// degraded is true, e.g. under memory pressure. Requests are then passed
+// through to upstream, still sharing concurrent identical requests.
+func (p *Proxy) SetDegraded(degraded bool) {
+	p.degraded.Store(degraded)
+	if degraded {
+		p.mu.Lock()
+		clear(p.entries)
+		p.mu.Unlock()
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (p *Proxy) Describe(ch chan<- *prometheus.Desc) {
+	p.requests.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (p *Proxy) Collect(ch chan<- prometheus.Metric) {
+	p.requests.Collect(ch)
+}
+
+// ServeHTTP implements http.Handler.
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	key := cacheKey(r.URL)
+	now := time.Now()
+
+	p.mu.Lock()
+	e, ok := p.entries[key]
+	if ok && now.Sub(e.fetchedAt) <= p.ttl {
+		p.mu.Unlock()
+		p.serve(w, e, "HIT", now)
+		return
+	}
+	if ok && now.Sub(e.fetchedAt) <= p.ttl+p.maxStale {
+		if !e.refreshing {
+			e.refreshing = true
+			go p.refresh(key, r.URL.Path, r.URL.RawQuery)
+		}
+		p.mu.Unlock()
+		p.serve(w, e, "STALE", now)
+		return
+	}
+	p.mu.Unlock()
+
+	// Pass the caller's request ID, if any, on to OpenCost.
+	ctx := r.Context()
+	if id := r.Header.Get(requestid.Header); id != "" {
+		ctx = requestid.NewContext(ctx, id)
+	}
+	ctx = requestid.Ensure(ctx)
+	fresh, err := p.fetch(ctx, key, r.URL.Path, r.URL.RawQuery)
+	if err != nil {
+		p.requests.WithLabelValues("error").Inc()
+		slog.ErrorContext(ctx, "failed to proxy OpenCost request", "path", r.URL.Path, "error", err)
+		http.Error(w, "upstream error: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	p.serve(w, fresh, "MISS", now)
+}
+
+func (p *Proxy) serve(w http.ResponseWriter, e *entry, result string, now time.Time) {
+	p.requests.WithLabelValues(strings.ToLower(result)).Inc()
+	w.Header().Set("Content-Type", e.contentType)
+	w.Header().Set("X-Cache", result)
+	w.Header().Set("Age", strconv.Itoa(int(now.Sub(e.fetchedAt).Seconds())))
+	w.Write(e.body)
+}
+
+// refresh re-fetches a stale entry in the background.
+func (p *Proxy) refresh(key, path, rawQuery string) {
+	ctx := requestid.Ensure(context.Background())
+	if _, err := p.fetch(ctx, key, path, rawQuery); err != nil {
+		slog.WarnContext(ctx, "background refresh of proxied OpenCost request failed", "path", path, "error", err)
+		p.mu.Lock()
+		if e, ok := p.entries[key]; ok {
+			e.refreshing = false
+		}
+		p.mu.Unlock()
+	}
+}
+
+// fetch requests key from upstream, sharing the request with concurrent
+// callers. On failure, an entry within ttl+maxStale is returned instead.
+func (p *Proxy) fetch(ctx context.Context, key, path, rawQuery string) (*entry, error) {
+	p.mu.Lock()
+	if c, ok := p.inflight[key]; ok {
+		p.mu.Unlock()
+		select {
+		case <-c.done:
+			return c.e, c.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	c := &call{done: make(chan struct{})}
+	p.inflight[key] = c
+	p.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), p.timeout)
+	defer cancel()
+	e, err := p.get(ctx, path, rawQuery)
+
+	p.mu.Lock()
+	delete(p.inflight, key)
+	if err == nil {
+		if !p.degraded.Load() {
+			p.store(key, e)
+		}
+	} else if old, ok := p.entries[key]; ok && time.Since(old.fetchedAt) <= p.ttl+p.maxStale {
+		e, err = old, nil
+	}
+	c.e, c.err = e, err
+	p.mu.Unlock()
+	close(c.done)
+	return e, err
+}
+
+func (p *Proxy) get(ctx context.Context, path, rawQuery string) (*entry, error) {
+	resp, err := p.upstream.Get(ctx, path, rawQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return &entry{body: body, contentType: resp.Header.Get("Content-Type"), fetchedAt: time.Now()}, nil
+}
+
+// store adds e, evicting the oldest entry when full. Callers hold p.mu.
+func (p *Proxy) store(key string, e *entry) {
+	if _, ok := p.entries[key]; !ok && len(p.entries) >= p.maxEntries {
+		var oldestKey string
+		var oldest time.Time
+		for k, v := range p.entries {
+			if oldestKey == "" || v.fetchedAt.Before(oldest) {
+				oldestKey, oldest = k, v.fetchedAt
+			}
+		}
+		delete(p.entries, oldestKey)
+	}
+	p.entries[key] = e
+}
+
+// cacheKey identifies a request by path and canonically ordered query.
+func cacheKey(u *url.URL) string {
+	return u.Path + "?" + u.Query().Encode()
+}
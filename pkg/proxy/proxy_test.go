@@ -0,0 +1,178 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// fakeUpstream counts requests and returns a body derived from the query.
+type fakeUpstream struct {
+	calls  atomic.Int32
+	fail   atomic.Bool
+	delay  time.Duration
+	status int
+}
+
+func (u *fakeUpstream) Get(ctx context.Context, path, rawQuery string) (*http.Response, error) {
+	u.calls.Add(1)
+	time.Sleep(u.delay)
+	if u.fail.Load() {
+		return nil, io.ErrUnexpectedEOF
+	}
+	status := u.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"path":"` + path + `","query":"` + rawQuery + `"}`)),
+	}, nil
+}
+
+func get(t *testing.T, h http.Handler, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	return rec
+}
+
+func TestProxy_CacheHit(t *testing.T) {
+	up := &fakeUpstream{}
+	p := New(up, time.Hour, time.Hour)
+
+	first := get(t, p, "/cloudCost?window=7d&aggregate=service")
+	second := get(t, p, "/cloudCost?aggregate=service&window=7d")
+	other := get(t, p, "/cloudCost?window=1d")
+
+	if first.Header().Get("X-Cache") != "MISS" || second.Header().Get("X-Cache") != "HIT" || other.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("X-Cache = %s, %s, %s; want MISS, HIT, MISS",
+			first.Header().Get("X-Cache"), second.Header().Get("X-Cache"), other.Header().Get("X-Cache"))
+	}
+	if first.Body.String() != second.Body.String() || !strings.Contains(first.Body.String(), "window=7d") {
+		t.Errorf("unexpected bodies %q / %q", first.Body.String(), second.Body.String())
+	}
+	if second.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Content-Type = %q", second.Header().Get("Content-Type"))
+	}
+	if got := up.calls.Load(); got != 2 {
+		t.Errorf("upstream calls = %d, want 2", got)
+	}
+	if got := testutil.ToFloat64(p.requests.WithLabelValues("hit")); got != 1 {
+		t.Errorf("hit counter = %v, want 1", got)
+	}
+}
+
+func TestProxy_StaleWhileRefresh(t *testing.T) {
+	up := &fakeUpstream{}
+	p := New(up, time.Millisecond, time.Hour)
+
+	get(t, p, "/cloudCost?window=7d")
+	time.Sleep(5 * time.Millisecond)
+
+	up.fail.Store(true)
+	rec := get(t, p, "/cloudCost?window=7d")
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Cache") != "STALE" {
+		t.Errorf("got %d %s, want 200 STALE", rec.Code, rec.Header().Get("X-Cache"))
+	}
+
+	// Wait for the failed background refresh before the next stale hit.
+	for i := 0; i < 100 && up.calls.Load() < 2; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if rec := get(t, p, "/cloudCost?window=7d"); rec.Code != http.StatusOK {
+		t.Errorf("stale data should be served while upstream is down, got %d", rec.Code)
+	}
+}
+
+func TestProxy_UpstreamError(t *testing.T) {
+	tests := []struct {
+		name string
+		up   *fakeUpstream
+	}{
+		{"transport error", func() *fakeUpstream { u := &fakeUpstream{}; u.fail.Store(true); return u }()},
+		{"non-200 status", &fakeUpstream{status: http.StatusInternalServerError}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(tt.up, time.Hour, time.Hour)
+			if rec := get(t, p, "/cloudCost"); rec.Code != http.StatusBadGateway {
+				t.Errorf("status = %d, want 502", rec.Code)
+			}
+			if rec := get(t, p, "/cloudCost"); rec.Code != http.StatusBadGateway {
+				t.Errorf("errors should not be cached, got %d", rec.Code)
+			}
+		})
+	}
+}
+
+func TestProxy_CoalescesConcurrentMisses(t *testing.T) {
+	up := &fakeUpstream{delay: 50 * time.Millisecond}
+	p := New(up, time.Hour, time.Hour)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			get(t, p, "/cloudCost?window=7d")
+		}()
+	}
+	wg.Wait()
+
+	if got := up.calls.Load(); got != 1 {
+		t.Errorf("upstream calls = %d, want 1", got)
+	}
+}
+
+func TestProxy_Eviction(t *testing.T) {
+	up := &fakeUpstream{}
+	p := New(up, time.Hour, time.Hour, WithMaxEntries(1))
+
+	get(t, p, "/cloudCost?window=1d")
+	get(t, p, "/cloudCost?window=2d")
+	if rec := get(t, p, "/cloudCost?window=1d"); rec.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("evicted entry should miss, got %s", rec.Header().Get("X-Cache"))
+	}
+}
+
+func TestProxy_MethodNotAllowed(t *testing.T) {
+	p := New(&fakeUpstream{}, time.Hour, time.Hour)
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/cloudCost", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status = %d, want 405", rec.Code)
+	}
+}
+
+func TestProxy_Degraded(t *testing.T) {
+	up := &fakeUpstream{}
+	p := New(up, time.Hour, time.Hour)
+
+	get(t, p, "/cloudCost?window=7d")
+	p.SetDegraded(true)
+	for range 2 {
+		if rec := get(t, p, "/cloudCost?window=7d"); rec.Header().Get("X-Cache") != "MISS" || rec.Code != http.StatusOK {
+			t.Errorf("degraded: X-Cache = %q, status %d; want an uncached MISS", rec.Header().Get("X-Cache"), rec.Code)
+		}
+	}
+
+	p.SetDegraded(false)
+	get(t, p, "/cloudCost?window=7d")
+	if rec := get(t, p, "/cloudCost?window=7d"); rec.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("recovered: X-Cache = %q, want HIT", rec.Header().Get("X-Cache"))
+	}
+	if got := up.calls.Load(); got != 4 {
+		t.Errorf("upstream calls = %d, want 4", got)
+	}
+}
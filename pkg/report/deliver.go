@@ -0,0 +1,104 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/secret"
+)
+
+// Sender delivers a rendered report.
+type Sender interface {
+	Send(ctx context.Context, subject, contentType string, body []byte) error
+}
+
+// WebhookSender POSTs reports as JSON to a URL.
+type WebhookSender struct {
+	URL    string
+	Client *http.Client
+}
+
+// webhookPayload is the JSON body posted by WebhookSender.
+type webhookPayload struct {
+	Subject     string `json:"subject"`
+	ContentType string `json:"content_type"`
+	Body        string `json:"body"`
+}
+
+// Send implements Sender.
+func (s *WebhookSender) Send(ctx context.Context, subject, contentType string, body []byte) error {
+	payload, err := json.Marshal(webhookPayload{Subject: subject, ContentType: contentType, Body: string(body)})
+	if err != nil {
+		return fmt.Errorf("encode webhook payload: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	hc := s.Client
+	if hc == nil {
+		hc = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return fmt.Errorf("post report: %w", secret.StripURL(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// EmailSender sends reports over SMTP.
+type EmailSender struct {
+	// Addr is the SMTP server address (host:port).
+	Addr string
+	From string
+	To   []string
+	// Username and Password enable PLAIN authentication when set.
+	Username string
+	Password *secret.Value
+
+	// sendMail is overridden in tests.
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// Send implements Sender.
+func (s *EmailSender) Send(_ context.Context, subject, contentType string, body []byte) error {
+	var auth smtp.Auth
+	if s.Username != "" {
+		password, err := s.Password.Get()
+		if err != nil {
+			return fmt.Errorf("SMTP password: %w", err)
+		}
+		host, _, _ := strings.Cut(s.Addr, ":")
+		auth = smtp.PlainAuth("", s.Username, password, host)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: %s\r\n\r\n", contentType)
+	msg.Write(body)
+
+	send := s.sendMail
+	if send == nil {
+		send = smtp.SendMail
+	}
+	if err := send(s.Addr, auth, s.From, s.To, msg.Bytes()); err != nil {
+		return fmt.Errorf("send email: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	"text/template"
+)
+
+// Formats lists the supported output formats.
+var Formats = []string{"markdown", "html"}
+
+var funcs = map[string]any{
+	"money": func(v float64) string { return fmt.Sprintf("$%.2f", v) },
+	"change": func(l Line) string {
+		c := l.Change()
+		if c == nil {
+			return "new"
+		}
+		return fmt.Sprintf("%+.1f%%", *c)
+	},
+	// table bundles arguments for the nested HTML table template.
+	"table": func(header string, lines []Line) any {
+		return struct {
+			Header string
+			Lines  []Line
+		}{header, lines}
+	},
+}
+
+var markdownTmpl = template.Must(template.New("markdown").Funcs(funcs).Parse(`# {{.Title}}
+
+**Total:** {{money .Total.Cost}} ({{change .Total}} vs. previous month, {{money .Total.Previous}})
+
+## Top services
+
+| Service | Cost | Previous | Change |
+|---------|-----:|---------:|-------:|
+{{- range .TopServices}}
+| {{.Name}} | {{money .Cost}} | {{money .Previous}} | {{change .}} |
+{{- end}}
+
+## Teams
+
+| Team | Cost | Previous | Change |
+|------|-----:|---------:|-------:|
+{{- range .Teams}}
+| {{.Name}} | {{money .Cost}} | {{money .Previous}} | {{change .}} |
+{{- end}}
+`))
+
+var htmlTmpl = htmltemplate.Must(htmltemplate.New("html").Funcs(funcs).Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body style="font-family: sans-serif">
+<h1>{{.Title}}</h1>
+<p><strong>Total:</strong> {{money .Total.Cost}} ({{change .Total}} vs. previous month, {{money .Total.Previous}})</p>
+{{- define "table"}}
+<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>{{.Header}}</th><th>Cost</th><th>Previous</th><th>Change</th></tr>
+{{- range .Lines}}
+<tr><td>{{.Name}}</td><td align="right">{{money .Cost}}</td><td align="right">{{money .Previous}}</td><td align="right">{{change .}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+<h2>Top services</h2>
+{{- template "table" (table "Service" .TopServices)}}
+<h2>Teams</h2>
+{{- template "table" (table "Team" .Teams)}}
+</body>
+</html>
+`))
+
+// Render renders the report in the given format.
+func Render(r *Report, format string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "markdown":
+		err = markdownTmpl.Execute(&buf, r)
+	case "html":
+		err = htmlTmpl.Execute(&buf, r)
+	default:
+		return nil, fmt.Errorf("unknown format %q (want one of %s)", format, strings.Join(Formats, ", "))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("render report: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ContentType returns the MIME type of the given format.
+func ContentType(format string) string {
+	if format == "html" {
+		return "text/html; charset=utf-8"
+	}
+	return "text/markdown; charset=utf-8"
+}
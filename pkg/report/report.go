@@ -0,0 +1,159 @@
+// Package report builds monthly cloud cost summaries, renders them as
+// Markdown or HTML and delivers them by email or webhook.
+package report
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+// Fetcher fetches cloud costs for an explicit window.
+type Fetcher interface {
+	FetchCloudCostsWindow(ctx context.Context, window string) (*types.CloudCostResponse, error)
+}
+
+// Options controls report contents.
+type Options struct {
+	// TopN is the number of services listed (default 10).
+	TopN int
+	// TeamLabel is the provider label holding the team name (default "team",
+	// falling back to "owner" per item).
+	TeamLabel string
+}
+
+// Line is a named cost with its previous-month value.
+type Line struct {
+	Name     string
+	Cost     float64
+	Previous float64
+}
+
+// Change returns the month-over-month change in percent, or nil if there was
+// no spend in the previous month.
+func (l Line) Change() *float64 {
+	if l.Previous == 0 {
+		return nil
+	}
+	c := (l.Cost - l.Previous) / l.Previous * 100
+	return &c
+}
+
+// Report is a monthly cost summary. Costs are amortized net costs.
+type Report struct {
+	Month       time.Time
+	Total       Line
+	TopServices []Line
+	Teams       []Line
+}
+
+// Title returns the report title, e.g. "Cloud cost report: March 2024".
+func (r *Report) Title() string {
+	return "Cloud cost report: " + r.Month.Format("January 2006")
+}
+
+// MonthWindow returns the OpenCost window covering the calendar month that
+// contains t, in UTC.
+func MonthWindow(t time.Time) string {
+	start := monthStart(t)
+	return start.Format(time.RFC3339) + "," + start.AddDate(0, 1, 0).Format(time.RFC3339)
+}
+
+// Generate fetches the given month and the month before it and builds the
+// report.
+func Generate(ctx context.Context, f Fetcher, month time.Time, opts Options) (*Report, error) {
+	month = monthStart(month)
+	current, err := f.FetchCloudCostsWindow(ctx, MonthWindow(month))
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s: %w", month.Format("2006-01"), err)
+	}
+	prev := month.AddDate(0, -1, 0)
+	previous, err := f.FetchCloudCostsWindow(ctx, MonthWindow(prev))
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s: %w", prev.Format("2006-01"), err)
+	}
+	return Build(month, current, previous, opts), nil
+}
+
+// Build summarizes current against previous for the given month.
+func Build(month time.Time, current, previous *types.CloudCostResponse, opts Options) *Report {
+	if opts.TopN <= 0 {
+		opts.TopN = 10
+	}
+	if opts.TeamLabel == "" {
+		opts.TeamLabel = "team"
+	}
+
+	services := map[string]*Line{}
+	teams := map[string]*Line{}
+	r := &Report{Month: monthStart(month), Total: Line{Name: "Total"}}
+
+	add := func(resp *types.CloudCostResponse, set func(*Line, float64)) {
+		if resp == nil {
+			return
+		}
+		for _, s := range resp.Data.Sets {
+			for _, item := range s.CloudCosts {
+				cost := item.AmortizedNetCost.Cost
+				set(&r.Total, cost)
+				set(lineFor(services, item.Properties.Service), cost)
+				set(lineFor(teams, teamOf(item.Properties, opts.TeamLabel)), cost)
+			}
+		}
+	}
+	add(current, func(l *Line, v float64) { l.Cost += v })
+	add(previous, func(l *Line, v float64) { l.Previous += v })
+
+	r.TopServices = sorted(services)
+	if len(r.TopServices) > opts.TopN {
+		r.TopServices = r.TopServices[:opts.TopN]
+	}
+	r.Teams = sorted(teams)
+	return r
+}
+
+func lineFor(m map[string]*Line, name string) *Line {
+	if name == "" {
+		name = "(none)"
+	}
+	l, ok := m[name]
+	if !ok {
+		l = &Line{Name: name}
+		m[name] = l
+	}
+	return l
+}
+
+func teamOf(p types.CloudCostProperties, label string) string {
+	if t := p.Labels[label]; t != "" {
+		return t
+	}
+	return p.Labels["owner"]
+}
+
+// sorted returns the lines by descending cost, dropping those with no spend
+// in either month.
+func sorted(m map[string]*Line) []Line {
+	out := make([]Line, 0, len(m))
+	for _, l := range m {
+		if l.Cost != 0 || l.Previous != 0 {
+			out = append(out, *l)
+		}
+	}
+	slices.SortFunc(out, func(a, b Line) int {
+		if c := cmp.Compare(b.Cost, a.Cost); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return out
+}
+
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
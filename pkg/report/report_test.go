@@ -0,0 +1,181 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/secret"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+func item(service, team string, cost float64) types.CloudCostItem {
+	return types.CloudCostItem{
+		Properties:       types.CloudCostProperties{Service: service, Labels: map[string]string{"team": team}},
+		AmortizedNetCost: types.CostValue{Cost: cost},
+	}
+}
+
+func response(items ...types.CloudCostItem) *types.CloudCostResponse {
+	m := make(map[string]types.CloudCostItem, len(items))
+	for i, it := range items {
+		m[string(rune('a'+i))] = it
+	}
+	return &types.CloudCostResponse{Data: types.CloudCostData{Sets: []types.CloudCostSet{{CloudCosts: m}}}}
+}
+
+var march = time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+
+func testReport() *Report {
+	current := response(item("AmazonEC2", "platform", 300), item("AmazonS3", "data", 50), item("AmazonRDS", "data", 100))
+	previous := response(item("AmazonEC2", "platform", 200), item("AmazonS3", "data", 100))
+	return Build(march, current, previous, Options{TopN: 2})
+}
+
+func TestBuild(t *testing.T) {
+	r := testReport()
+
+	if !r.Month.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Month = %v, want 2024-03-01", r.Month)
+	}
+	if r.Total.Cost != 450 || r.Total.Previous != 300 {
+		t.Errorf("Total = %+v, want 450/300", r.Total)
+	}
+	if c := r.Total.Change(); c == nil || *c != 50 {
+		t.Errorf("Total change = %v, want 50", c)
+	}
+	if len(r.TopServices) != 2 || r.TopServices[0].Name != "AmazonEC2" || r.TopServices[1].Name != "AmazonRDS" {
+		t.Errorf("TopServices = %+v", r.TopServices)
+	}
+	if r.TopServices[1].Change() != nil {
+		t.Error("new service should have no change")
+	}
+	if len(r.Teams) != 2 || r.Teams[0].Name != "platform" || r.Teams[1].Cost != 150 {
+		t.Errorf("Teams = %+v", r.Teams)
+	}
+}
+
+func TestMonthWindow(t *testing.T) {
+	if got, want := MonthWindow(march), "2024-03-01T00:00:00Z,2024-04-01T00:00:00Z"; got != want {
+		t.Errorf("MonthWindow() = %q, want %q", got, want)
+	}
+	if got, want := NextRun(time.Date(2024, 12, 31, 23, 0, 0, 0, time.UTC)), time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("NextRun() = %v, want %v", got, want)
+	}
+}
+
+func TestRender(t *testing.T) {
+	r := testReport()
+
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{"markdown", []string{"# Cloud cost report: March 2024", "| AmazonEC2 | $300.00 | $200.00 | +50.0% |", "| AmazonRDS | $100.00 | $0.00 | new |"}},
+		{"html", []string{"<h1>Cloud cost report: March 2024</h1>", "<td>platform</td>", "<td>AmazonRDS</td>"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			out, err := Render(r, tt.format)
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(out), want) {
+					t.Errorf("output missing %q:\n%s", want, out)
+				}
+			}
+		})
+	}
+
+	if _, err := Render(r, "pdf"); err == nil {
+		t.Error("Render() should fail for unknown format")
+	}
+}
+
+func TestWebhookSender(t *testing.T) {
+	var got webhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer server.Close()
+
+	s := &WebhookSender{URL: server.URL}
+	if err := s.Send(context.Background(), "subject", "text/html", []byte("<p>hi</p>")); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if got.Subject != "subject" || got.Body != "<p>hi</p>" || got.ContentType != "text/html" {
+		t.Errorf("unexpected payload %+v", got)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	if err := (&WebhookSender{URL: failing.URL}).Send(context.Background(), "s", "text/plain", nil); err == nil {
+		t.Error("Send() should fail on non-2xx status")
+	}
+}
+
+func TestEmailSender(t *testing.T) {
+	var gotAddr string
+	var gotTo []string
+	var gotMsg string
+	var gotAuth smtp.Auth
+	s := &EmailSender{
+		Addr:     "smtp.example.com:587",
+		From:     "finops@example.com",
+		To:       []string{"a@example.com", "b@example.com"},
+		Username: "user",
+		Password: secret.Inline("pass"),
+		sendMail: func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+			gotAddr, gotAuth, gotTo, gotMsg = addr, a, to, string(msg)
+			return nil
+		},
+	}
+
+	if err := s.Send(context.Background(), "Report", "text/html; charset=utf-8", []byte("<p>hi</p>")); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if gotAddr != "smtp.example.com:587" || len(gotTo) != 2 || gotAuth == nil {
+		t.Errorf("unexpected delivery addr=%s to=%v auth=%v", gotAddr, gotTo, gotAuth)
+	}
+	for _, want := range []string{"Subject: Report\r\n", "To: a@example.com, b@example.com\r\n", "Content-Type: text/html; charset=utf-8\r\n\r\n<p>hi</p>"} {
+		if !strings.Contains(gotMsg, want) {
+			t.Errorf("message missing %q:\n%s", want, gotMsg)
+		}
+	}
+}
+
+type fakeFetcher map[string]*types.CloudCostResponse
+
+func (f fakeFetcher) FetchCloudCostsWindow(_ context.Context, window string) (*types.CloudCostResponse, error) {
+	return f[window], nil
+}
+
+type recordingSender struct{ subjects []string }
+
+func (s *recordingSender) Send(_ context.Context, subject, _ string, _ []byte) error {
+	s.subjects = append(s.subjects, subject)
+	return nil
+}
+
+func TestScheduler_Send(t *testing.T) {
+	f := fakeFetcher{
+		MonthWindow(march):                   response(item("AmazonEC2", "platform", 10)),
+		MonthWindow(march.AddDate(0, -1, 0)): response(item("AmazonEC2", "platform", 5)),
+	}
+	rec := &recordingSender{}
+
+	if err := NewScheduler(f, Options{}, "markdown", rec).Send(context.Background(), march); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if len(rec.subjects) != 1 || rec.subjects[0] != "Cloud cost report: March 2024" {
+		t.Errorf("subjects = %v", rec.subjects)
+	}
+}
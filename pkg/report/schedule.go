@@ -0,0 +1,74 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/requestid"
+)
+
+// Scheduler generates the previous month's report on the first of every
+// month (UTC) and delivers it to all senders.
+type Scheduler struct {
+	fetcher Fetcher
+	opts    Options
+	format  string
+	senders []Sender
+}
+
+// NewScheduler creates a scheduler rendering reports in format.
+func NewScheduler(f Fetcher, opts Options, format string, senders ...Sender) *Scheduler {
+	return &Scheduler{fetcher: f, opts: opts, format: format, senders: senders}
+}
+
+// Run blocks until ctx is cancelled, sending a report at the start of each
+// month.
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		next := NextRun(time.Now())
+		slog.Info("next monthly report scheduled", "at", next)
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		sendCtx := requestid.NewContext(ctx, requestid.New())
+		if err := s.Send(sendCtx, next.AddDate(0, -1, 0)); err != nil {
+			slog.ErrorContext(sendCtx, "failed to send monthly report", "error", err)
+		}
+	}
+}
+
+// Send generates the report for month and delivers it to all senders.
+func (s *Scheduler) Send(ctx context.Context, month time.Time) error {
+	r, err := Generate(ctx, s.fetcher, month, s.opts)
+	if err != nil {
+		return err
+	}
+	body, err := Render(r, s.format)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, sender := range s.senders {
+		if err := sender.Send(ctx, r.Title(), ContentType(s.format), body); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 {
+		slog.InfoContext(ctx, "monthly report sent", "month", r.Month.Format("2006-01"), "recipients", len(s.senders))
+	}
+	return errors.Join(errs...)
+}
+
+// NextRun returns the start of the next month after t, in UTC.
+func NextRun(t time.Time) time.Time {
+	return monthStart(t).AddDate(0, 1, 0)
+}
@@ -0,0 +1,85 @@
+// Package requestid identifies the refresh cycles of the exporter, so that
+// the log lines of concurrent refreshes can be told apart and matched with
+// OpenCost's logs. An ID travels in the context: requests made with it send
+// it as the X-Request-ID header, and a slog handler adds it to the records
+// logged with it.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+)
+
+// Header is the HTTP header carrying the ID.
+const Header = "X-Request-ID"
+
+// LogKey is the key of the ID in log records.
+const LogKey = "request_id"
+
+type contextKey struct{}
+
+// New returns a random ID.
+func New() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// NewContext returns a copy of ctx carrying id.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the ID carried by ctx, or "" if none.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Ensure returns ctx if it carries an ID already, and otherwise a copy of
+// ctx carrying a new one.
+func Ensure(ctx context.Context) context.Context {
+	if FromContext(ctx) != "" {
+		return ctx
+	}
+	return NewContext(ctx, New())
+}
+
+// SetHeader sets the Header of req to the ID carried by its context, if any.
+func SetHeader(req *http.Request) {
+	if id := FromContext(req.Context()); id != "" {
+		req.Header.Set(Header, id)
+	}
+}
+
+type handler struct {
+	slog.Handler
+}
+
+// NewHandler returns a handler adding the ID carried by the context of a
+// record, if any, to the records passed on to h.
+func NewHandler(h slog.Handler) slog.Handler {
+	return handler{h}
+}
+
+// Handle implements slog.Handler.
+func (h handler) Handle(ctx context.Context, r slog.Record) error {
+	if id := FromContext(ctx); id != "" {
+		r = r.Clone()
+		r.AddAttrs(slog.String(LogKey, id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs implements slog.Handler.
+func (h handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return handler{h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup implements slog.Handler.
+func (h handler) WithGroup(name string) slog.Handler {
+	return handler{h.Handler.WithGroup(name)}
+}
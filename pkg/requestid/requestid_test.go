@@ -0,0 +1,65 @@
+package requestid
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	a, b := New(), New()
+	if len(a) != 16 || a == b {
+		t.Errorf("New() = %q, %q, want distinct 16 digit IDs", a, b)
+	}
+}
+
+func TestEnsure(t *testing.T) {
+	ctx := Ensure(context.Background())
+	id := FromContext(ctx)
+	if id == "" {
+		t.Fatal("Ensure() did not add an ID")
+	}
+	if got := FromContext(Ensure(ctx)); got != id {
+		t.Errorf("Ensure() replaced ID %q with %q", id, got)
+	}
+}
+
+func TestSetHeader(t *testing.T) {
+	req, _ := http.NewRequestWithContext(NewContext(context.Background(), "abc"), http.MethodGet, "http://opencost", nil)
+	SetHeader(req)
+	if got := req.Header.Get(Header); got != "abc" {
+		t.Errorf("%s = %q, want abc", Header, got)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, "http://opencost", nil)
+	SetHeader(req)
+	if _, ok := req.Header[Header]; ok {
+		t.Errorf("%s set without an ID", Header)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(slog.NewJSONHandler(&buf, nil))).With("component", "collector")
+
+	logger.InfoContext(NewContext(context.Background(), "abc"), "fetched")
+	logger.Info("scraped")
+
+	dec := json.NewDecoder(&buf)
+	for _, want := range []string{"abc", ""} {
+		var record map[string]any
+		if err := dec.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		got, _ := record[LogKey].(string)
+		if got != want {
+			t.Errorf("%s of %q = %q, want %q", LogKey, record["msg"], got, want)
+		}
+		if record["component"] != "collector" {
+			t.Errorf("record %q lost the logger's attributes", record["msg"])
+		}
+	}
+}
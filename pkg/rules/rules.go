@@ -0,0 +1,242 @@
+// Package rules renders recommended Prometheus recording and alerting rules
+// for the exporter's configured metric names and thresholds.
+package rules
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v2"
+)
+
+// Formats lists the supported output formats.
+var Formats = []string{"prometheus", "prometheusrule"}
+
+// Options parameterizes the generated rules.
+type Options struct {
+	// CostMetric is the fully-qualified cost gauge name, e.g. aws_cloud_cost_total.
+	CostMetric string
+	// SelfMetricPrefix is the prefix of the exporter's own metrics.
+	SelfMetricPrefix string
+	// Labels are the label names available on CostMetric.
+	Labels []string
+	// CostType is the cost_type used for aggregations (e.g. amortized_net).
+	CostType string
+	// CostTypeMetric returns the metric of a cost type when the costs are
+	// emitted as a metric per cost type rather than CostMetric with a
+	// cost_type label, and "" otherwise. It may be nil.
+	CostTypeMetric func(costType string) string
+	// StaleAfter is the data age after which the stale alert fires.
+	StaleAfter time.Duration
+	// DailySpendThreshold fires CloudCostDailySpendHigh when exceeded (0 disables).
+	DailySpendThreshold float64
+	// Budget fires CloudCostBudgetExceeded when window spend exceeds it (0 disables).
+	Budget float64
+	// SpikePercent is the day-over-day increase that fires CloudCostSpike (0 disables).
+	SpikePercent float64
+	// AnomalyZScore is the z-score over 7 days that fires CloudCostAnomaly (0 disables).
+	AnomalyZScore float64
+}
+
+// RuleFile is a Prometheus rule file.
+type RuleFile struct {
+	Groups []Group `yaml:"groups"`
+}
+
+// Group is a named group of rules.
+type Group struct {
+	Name     string `yaml:"name"`
+	Interval string `yaml:"interval,omitempty"`
+	Rules    []Rule `yaml:"rules"`
+}
+
+// Rule is a recording or alerting rule.
+type Rule struct {
+	Record      string            `yaml:"record,omitempty"`
+	Alert       string            `yaml:"alert,omitempty"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// aggregations are the recording rule dimensions, by name suffix.
+var aggregations = []struct {
+	name   string
+	labels []string
+}{
+	{"service", []string{"service"}},
+	{"account", []string{"account_id"}},
+	{"region", []string{"region"}},
+	{"category", []string{"category"}},
+	{"owner", []string{"owner"}},
+	{"team", []string{"team"}},
+	{"environment", []string{"environment"}},
+	{"cluster", []string{"cluster"}},
+	{"account_service", []string{"account_id", "service"}},
+	{"service_region", []string{"service", "region"}},
+}
+
+// Generate builds the recommended rule file.
+func Generate(opts Options) RuleFile {
+	opts = withDefaults(opts)
+	base := strings.TrimSuffix(opts.CostMetric, "_total")
+	selector := fmt.Sprintf(`%s{cost_type="%s"}`, opts.CostMetric, opts.CostType)
+	if slices.Contains(opts.Labels, "currency") {
+		// Converted costs would be summed with the USD costs.
+		selector = fmt.Sprintf(`%s{cost_type="%s", currency="USD"}`, opts.CostMetric, opts.CostType)
+	}
+	if opts.CostTypeMetric != nil {
+		if metric := opts.CostTypeMetric(opts.CostType); metric != "" {
+			// The metric is already of one cost type in USD.
+			selector = metric
+		}
+	}
+	windowTotal := base + ":total:window"
+	total := base + ":total:daily"
+	// The costs are those of the whole query window; the daily rules divide
+	// them by its length in days, from the window bounds of the collector.
+	days := fmt.Sprintf("scalar((max(%s_window_end_timestamp_seconds) - min(%s_window_start_timestamp_seconds)) / 86400)", base, base)
+
+	recording := []Rule{
+		{
+			Record: windowTotal,
+			Expr:   fmt.Sprintf("sum(%s)", selector),
+		},
+		{
+			Record: total,
+			Expr:   fmt.Sprintf("%s / %s", windowTotal, days),
+		},
+	}
+	for _, agg := range aggregations {
+		if !hasAll(opts.Labels, agg.labels) {
+			continue
+		}
+		recording = append(recording, Rule{
+			Record: fmt.Sprintf("%s:by_%s:daily", base, agg.name),
+			Expr:   fmt.Sprintf("sum by (%s) (%s) / %s", strings.Join(agg.labels, ", "), selector, days),
+		})
+	}
+
+	alerts := []Rule{
+		{
+			Alert:  "CloudCostDataStale",
+			Expr:   fmt.Sprintf("time() - %s_last_successful_scrape_timestamp > %d", opts.SelfMetricPrefix, int64(opts.StaleAfter.Seconds())),
+			For:    "15m",
+			Labels: map[string]string{"severity": "warning"},
+			Annotations: map[string]string{
+				"summary": fmt.Sprintf("Cloud cost data has not been refreshed for more than %s", opts.StaleAfter),
+			},
+		},
+		{
+			Alert:  "CloudCostScrapeFailures",
+			Expr:   fmt.Sprintf("increase(%s_scrape_errors_total[1h]) > 3", opts.SelfMetricPrefix),
+			For:    "30m",
+			Labels: map[string]string{"severity": "warning"},
+			Annotations: map[string]string{
+				"summary": "The exporter repeatedly fails to fetch cloud costs from OpenCost",
+			},
+		},
+	}
+
+	if opts.DailySpendThreshold > 0 {
+		alerts = append(alerts, Rule{
+			Alert:  "CloudCostDailySpendHigh",
+			Expr:   fmt.Sprintf("%s > %s", total, formatFloat(opts.DailySpendThreshold)),
+			For:    "1h",
+			Labels: map[string]string{"severity": "warning"},
+			Annotations: map[string]string{
+				"summary": fmt.Sprintf("Daily cloud spend exceeds $%s", formatFloat(opts.DailySpendThreshold)),
+			},
+		})
+	}
+	if opts.Budget > 0 {
+		alerts = append(alerts, Rule{
+			Alert:  "CloudCostBudgetExceeded",
+			Expr:   fmt.Sprintf("%s > %s", windowTotal, formatFloat(opts.Budget)),
+			For:    "1h",
+			Labels: map[string]string{"severity": "critical"},
+			Annotations: map[string]string{
+				"summary":     fmt.Sprintf("Cloud spend exceeds the budget of $%s", formatFloat(opts.Budget)),
+				"description": "Current spend is {{ $value | humanize }} USD.",
+			},
+		})
+	}
+	if opts.SpikePercent > 0 {
+		alerts = append(alerts, Rule{
+			Alert:  "CloudCostSpike",
+			Expr:   fmt.Sprintf("%s / %s offset 1d > %s", total, total, formatFloat(1+opts.SpikePercent/100)),
+			For:    "2h",
+			Labels: map[string]string{"severity": "warning"},
+			Annotations: map[string]string{
+				"summary": fmt.Sprintf("Cloud costs increased by %s%%+ day-over-day", formatFloat(opts.SpikePercent)),
+			},
+		})
+	}
+	if opts.AnomalyZScore > 0 {
+		alerts = append(alerts, Rule{
+			Alert: "CloudCostAnomaly",
+			Expr: fmt.Sprintf("(%s - avg_over_time(%s[7d])) / stddev_over_time(%s[7d]) > %s",
+				total, total, total, formatFloat(opts.AnomalyZScore)),
+			For:    "2h",
+			Labels: map[string]string{"severity": "warning"},
+			Annotations: map[string]string{
+				"summary": fmt.Sprintf("Cloud spend deviates more than %s standard deviations from the 7-day average", formatFloat(opts.AnomalyZScore)),
+			},
+		})
+	}
+
+	return RuleFile{Groups: []Group{
+		{Name: "cloudcost.rules", Rules: recording},
+		{Name: "cloudcost.alerts", Rules: alerts},
+	}}
+}
+
+// Render renders the rules in the given format: a plain Prometheus rule
+// file or a Prometheus Operator PrometheusRule resource named name.
+func Render(rf RuleFile, format, name string) ([]byte, error) {
+	switch format {
+	case "prometheus":
+		return yaml.Marshal(rf)
+	case "prometheusrule":
+		return yaml.Marshal(map[string]any{
+			"apiVersion": "monitoring.coreos.com/v1",
+			"kind":       "PrometheusRule",
+			"metadata":   map[string]string{"name": name},
+			"spec":       rf,
+		})
+	default:
+		return nil, fmt.Errorf("unknown format %q (want one of %s)", format, strings.Join(Formats, ", "))
+	}
+}
+
+func withDefaults(opts Options) Options {
+	if opts.CostMetric == "" {
+		opts.CostMetric = "aws_cloud_cost_total"
+	}
+	if opts.SelfMetricPrefix == "" {
+		opts.SelfMetricPrefix = "cloudcost_exporter"
+	}
+	if opts.CostType == "" {
+		opts.CostType = "amortized_net"
+	}
+	if opts.StaleAfter <= 0 {
+		opts.StaleAfter = 7 * time.Hour
+	}
+	return opts
+}
+
+func hasAll(labels, want []string) bool {
+	for _, l := range want {
+		if !slices.Contains(labels, l) {
+			return false
+		}
+	}
+	return true
+}
+
+func formatFloat(f float64) string {
+	return fmt.Sprintf("%g", f)
+}
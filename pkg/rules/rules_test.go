@@ -0,0 +1,138 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.yaml.in/yaml/v2"
+)
+
+var defaultLabels = []string{"provider_id", "account_id", "service", "category", "cost_type", "region", "availability_zone", "owner", "environment", "cluster"}
+
+func findRule(rf RuleFile, name string) *Rule {
+	for _, g := range rf.Groups {
+		for i, r := range g.Rules {
+			if r.Record == name || r.Alert == name {
+				return &g.Rules[i]
+			}
+		}
+	}
+	return nil
+}
+
+func TestGenerate_Defaults(t *testing.T) {
+	rf := Generate(Options{Labels: defaultLabels})
+
+	if r := findRule(rf, "aws_cloud_cost:total:window"); r == nil || r.Expr != `sum(aws_cloud_cost_total{cost_type="amortized_net"})` {
+		t.Errorf("unexpected window total rule: %+v", r)
+	}
+	days := "scalar((max(aws_cloud_cost_window_end_timestamp_seconds) - min(aws_cloud_cost_window_start_timestamp_seconds)) / 86400)"
+	if r := findRule(rf, "aws_cloud_cost:total:daily"); r == nil || r.Expr != "aws_cloud_cost:total:window / "+days {
+		t.Errorf("daily total should divide the window total by its days, got %+v", r)
+	}
+	if r := findRule(rf, "aws_cloud_cost:by_account:daily"); r == nil || !strings.HasSuffix(r.Expr, " / "+days) {
+		t.Errorf("daily rules should divide by the window days, got %+v", r)
+	}
+	if findRule(rf, "aws_cloud_cost:by_team:daily") != nil {
+		t.Error("by_team rule requires the team label")
+	}
+	for _, alert := range []string{"CloudCostDataStale", "CloudCostScrapeFailures"} {
+		if findRule(rf, alert) == nil {
+			t.Errorf("expected %s alert", alert)
+		}
+	}
+	for _, alert := range []string{"CloudCostBudgetExceeded", "CloudCostSpike", "CloudCostAnomaly", "CloudCostDailySpendHigh"} {
+		if findRule(rf, alert) != nil {
+			t.Errorf("%s should be disabled by default", alert)
+		}
+	}
+}
+
+func TestGenerate_Thresholds(t *testing.T) {
+	rf := Generate(Options{
+		Labels:              defaultLabels,
+		StaleAfter:          2 * time.Hour,
+		DailySpendThreshold: 5000,
+		Budget:              100000,
+		SpikePercent:        20,
+		AnomalyZScore:       3,
+	})
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"CloudCostDataStale", "> 7200"},
+		{"CloudCostDailySpendHigh", "aws_cloud_cost:total:daily > 5000"},
+		{"CloudCostBudgetExceeded", "aws_cloud_cost:total:window > 100000"},
+		{"CloudCostSpike", "> 1.2"},
+		{"CloudCostAnomaly", "> 3"},
+	}
+	for _, tt := range tests {
+		r := findRule(rf, tt.name)
+		if r == nil {
+			t.Errorf("missing rule %s", tt.name)
+			continue
+		}
+		if !strings.Contains(r.Expr, tt.want) {
+			t.Errorf("%s expr = %q, want it to contain %q", tt.name, r.Expr, tt.want)
+		}
+	}
+}
+
+func TestGenerate_CustomNames(t *testing.T) {
+	rf := Generate(Options{CostMetric: "acme_cost_total", SelfMetricPrefix: "acme_exporter", Labels: []string{"service"}})
+
+	if findRule(rf, "acme_cost:total:daily") == nil || findRule(rf, "acme_cost:by_service:daily") == nil {
+		t.Error("recording rules should use the configured metric name")
+	}
+	if r := findRule(rf, "CloudCostScrapeFailures"); !strings.Contains(r.Expr, "acme_exporter_scrape_errors_total") {
+		t.Errorf("scrape failure alert should use the self-metric prefix, got %q", r.Expr)
+	}
+}
+
+func TestGenerate_CurrencyLabel(t *testing.T) {
+	rf := Generate(Options{Labels: append(defaultLabels, "currency")})
+
+	for _, name := range []string{"aws_cloud_cost:total:window", "aws_cloud_cost:by_service:daily"} {
+		if r := findRule(rf, name); r == nil || !strings.Contains(r.Expr, `currency="USD"`) {
+			t.Errorf("%s should only sum the USD costs, got %+v", name, r)
+		}
+	}
+}
+
+func TestGenerate_CostTypeMetric(t *testing.T) {
+	rf := Generate(Options{Labels: []string{"account_id", "service"}, CostTypeMetric: func(costType string) string {
+		return "aws_cloud_cost_" + costType + "_usd"
+	}})
+
+	r := findRule(rf, "aws_cloud_cost:by_service:daily")
+	if r == nil || !strings.HasPrefix(r.Expr, "sum by (service) (aws_cloud_cost_amortized_net_usd) / ") {
+		t.Errorf("rule should sum the metric of the cost type, got %+v", r)
+	}
+}
+
+func TestRender(t *testing.T) {
+	rf := Generate(Options{Labels: defaultLabels})
+
+	for _, format := range Formats {
+		out, err := Render(rf, format, "cloudcost")
+		if err != nil {
+			t.Fatalf("Render(%s) error = %v", format, err)
+		}
+		var decoded map[string]any
+		if err := yaml.Unmarshal(out, &decoded); err != nil {
+			t.Fatalf("Render(%s) produced invalid YAML: %v", format, err)
+		}
+	}
+
+	out, _ := Render(rf, "prometheusrule", "cloudcost")
+	if !strings.Contains(string(out), "kind: PrometheusRule") {
+		t.Error("prometheusrule format should render a PrometheusRule resource")
+	}
+
+	if _, err := Render(rf, "bogus", ""); err == nil {
+		t.Error("Render() should fail for unknown format")
+	}
+}
@@ -0,0 +1,110 @@
+// Package secret reads credentials such as API keys and passwords from
+// files, typically a mounted Kubernetes Secret, so that they appear neither
+// in command-line flags nor in the process environment. Files are re-read
+// on every use, so a rotated Secret takes effect without a restart.
+//
+// A Value never prints its contents: fmt, slog and encoding/json all see
+// "[REDACTED]".
+package secret
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Redacted replaces secrets in output.
+const Redacted = "[REDACTED]"
+
+// Value is a credential given inline or in a file.
+type Value struct {
+	file   string
+	inline string
+}
+
+// File returns a Value read from path on every use.
+func File(path string) *Value {
+	return &Value{file: path}
+}
+
+// Inline returns a Value holding s.
+func Inline(s string) *Value {
+	return &Value{inline: s}
+}
+
+// FromEnv returns the credential the environment names: the file in
+// name_FILE if that is set, otherwise the value of name. It returns nil if
+// neither is set or the file does not exist, so that optional keys of a
+// mounted Secret can be left out.
+func FromEnv(name string) *Value {
+	if path := os.Getenv(name + "_FILE"); path != "" {
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return File(path)
+	}
+	if s := os.Getenv(name); s != "" {
+		return Inline(s)
+	}
+	return nil
+}
+
+// Get returns the credential, re-reading its file. Surrounding whitespace,
+// such as a trailing newline, is trimmed. A nil Value is empty.
+func (v *Value) Get() (string, error) {
+	if v == nil {
+		return "", nil
+	}
+	if v.file == "" {
+		return v.inline, nil
+	}
+	b, err := os.ReadFile(v.file)
+	if err != nil {
+		return "", fmt.Errorf("read secret: %w", err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// String implements fmt.Stringer without revealing the credential.
+func (v *Value) String() string {
+	return Redacted
+}
+
+// GoString implements fmt.GoStringer without revealing the credential.
+func (v *Value) GoString() string {
+	return Redacted
+}
+
+// LogValue implements slog.LogValuer without revealing the credential.
+func (v *Value) LogValue() slog.Value {
+	return slog.StringValue(Redacted)
+}
+
+// MarshalJSON implements json.Marshaler without revealing the credential.
+func (v *Value) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + Redacted + `"`), nil
+}
+
+// RedactURL returns raw with the password of its user info redacted, for
+// logging. Unparsable URLs are redacted entirely.
+func RedactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return Redacted
+	}
+	return u.Redacted()
+}
+
+// StripURL removes the request URL from err if it is a *url.Error, for URLs
+// that are secrets themselves, such as chat webhooks.
+func StripURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return fmt.Errorf("%s request: %w", urlErr.Op, urlErr.Err)
+	}
+	return err
+}
@@ -0,0 +1,120 @@
+// Package selfmetrics renames, drops or labels the exporter's own
+// cloudcost_exporter_* metrics as they are gathered, so that many exporter
+// instances behind one federation endpoint can be told apart. The metrics
+// are registered under their usual names by every package; only what is
+// served changes.
+package selfmetrics
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+)
+
+// Prefix is the prefix the exporter's own metrics are registered with.
+const Prefix = "cloudcost_exporter"
+
+// InstanceLabel is the name of the label identifying the exporter instance.
+const InstanceLabel = "exporter_instance"
+
+type gatherer struct {
+	prometheus.Gatherer
+
+	prefix   string
+	disabled bool
+	instance string
+}
+
+// Option is a functional option for configuring the gatherer.
+type Option func(*gatherer)
+
+// WithPrefix serves the metrics with prefix instead of Prefix.
+func WithPrefix(prefix string) Option {
+	return func(g *gatherer) {
+		g.prefix = prefix
+	}
+}
+
+// WithDisabled leaves the metrics out entirely.
+func WithDisabled(disabled bool) Option {
+	return func(g *gatherer) {
+		g.disabled = disabled
+	}
+}
+
+// WithInstance adds an InstanceLabel label with the value instance to the
+// metrics. An empty instance adds no label.
+func WithInstance(instance string) Option {
+	return func(g *gatherer) {
+		g.instance = instance
+	}
+}
+
+// ValidatePrefix checks that prefix yields valid metric names.
+func ValidatePrefix(prefix string) error {
+	if prefix == "" || !model.LegacyValidation.IsValidMetricName(prefix+"_up") {
+		return fmt.Errorf("invalid self-metrics prefix %q", prefix)
+	}
+	return nil
+}
+
+// Wrap returns a gatherer serving the metrics gathered by g with the
+// exporter's own metrics changed as configured. Other metrics, such as the
+// cost metrics and the Go runtime metrics, are served unchanged.
+func Wrap(g prometheus.Gatherer, opts ...Option) prometheus.Gatherer {
+	w := &gatherer{Gatherer: g, prefix: Prefix}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if w.prefix == Prefix && !w.disabled && w.instance == "" {
+		return g
+	}
+	return w
+}
+
+// Gather implements prometheus.Gatherer.
+func (g *gatherer) Gather() ([]*dto.MetricFamily, error) {
+	// Gather returns what it could gather along with an error.
+	families, err := g.Gatherer.Gather()
+	out := families[:0]
+	for _, mf := range families {
+		name, ok := strings.CutPrefix(mf.GetName(), Prefix+"_")
+		if !ok {
+			out = append(out, mf)
+			continue
+		}
+		if g.disabled {
+			continue
+		}
+		name = g.prefix + "_" + name
+		mf.Name = &name
+		if g.instance != "" {
+			for _, m := range mf.Metric {
+				m.Label = withLabel(m.Label, InstanceLabel, g.instance)
+			}
+		}
+		out = append(out, mf)
+	}
+	// Renamed families may sort elsewhere.
+	slices.SortFunc(out, func(a, b *dto.MetricFamily) int {
+		return strings.Compare(a.GetName(), b.GetName())
+	})
+	return out, err
+}
+
+// withLabel sets the label name to value in labels, which are sorted by
+// name, keeping them sorted.
+func withLabel(labels []*dto.LabelPair, name, value string) []*dto.LabelPair {
+	i, found := slices.BinarySearchFunc(labels, name, func(l *dto.LabelPair, name string) int {
+		return strings.Compare(l.GetName(), name)
+	})
+	if found {
+		labels[i].Value = &value
+		return labels
+	}
+	return slices.Insert(labels, i, &dto.LabelPair{Name: &name, Value: &value})
+}
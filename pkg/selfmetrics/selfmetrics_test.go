@@ -0,0 +1,107 @@
+package selfmetrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newRegistry() *prometheus.Registry {
+	reg := prometheus.NewPedanticRegistry()
+	cost := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "aws_cloud_cost_total", Help: "Cost"}, []string{"service"})
+	cost.WithLabelValues("AmazonEC2").Set(5)
+	errors := prometheus.NewCounter(prometheus.CounterOpts{Namespace: Prefix, Name: "scrape_errors_total", Help: "Errors", ConstLabels: prometheus.Labels{"cluster": "prod"}})
+	errors.Inc()
+	hits := prometheus.NewCounter(prometheus.CounterOpts{Namespace: Prefix, Name: "cache_hits_total", Help: "Hits"})
+	reg.MustRegister(cost, errors, hits)
+	return reg
+}
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{
+			name: "unchanged",
+			want: `
+# HELP aws_cloud_cost_total Cost
+# TYPE aws_cloud_cost_total gauge
+aws_cloud_cost_total{service="AmazonEC2"} 5
+# HELP cloudcost_exporter_cache_hits_total Hits
+# TYPE cloudcost_exporter_cache_hits_total counter
+cloudcost_exporter_cache_hits_total 0
+# HELP cloudcost_exporter_scrape_errors_total Errors
+# TYPE cloudcost_exporter_scrape_errors_total counter
+cloudcost_exporter_scrape_errors_total{cluster="prod"} 1
+`,
+		},
+		{
+			name: "prefix and instance",
+			opts: []Option{WithPrefix("billing_exporter"), WithInstance("exporter-0")},
+			want: `
+# HELP aws_cloud_cost_total Cost
+# TYPE aws_cloud_cost_total gauge
+aws_cloud_cost_total{service="AmazonEC2"} 5
+# HELP billing_exporter_cache_hits_total Hits
+# TYPE billing_exporter_cache_hits_total counter
+billing_exporter_cache_hits_total{exporter_instance="exporter-0"} 0
+# HELP billing_exporter_scrape_errors_total Errors
+# TYPE billing_exporter_scrape_errors_total counter
+billing_exporter_scrape_errors_total{cluster="prod",exporter_instance="exporter-0"} 1
+`,
+		},
+		{
+			name: "disabled",
+			opts: []Option{WithDisabled(true), WithInstance("exporter-0")},
+			want: `
+# HELP aws_cloud_cost_total Cost
+# TYPE aws_cloud_cost_total gauge
+aws_cloud_cost_total{service="AmazonEC2"} 5
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := Wrap(newRegistry(), tt.opts...)
+			if err := testutil.GatherAndCompare(g, strings.NewReader(tt.want)); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestWithLabel(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   Prefix,
+		Name:        "info",
+		Help:        "Info",
+		ConstLabels: prometheus.Labels{"a": "1", InstanceLabel: "old", "z": "2"},
+	}))
+	want := `
+# HELP cloudcost_exporter_info Info
+# TYPE cloudcost_exporter_info gauge
+cloudcost_exporter_info{a="1",exporter_instance="new",z="2"} 0
+`
+	if err := testutil.GatherAndCompare(Wrap(reg, WithInstance("new")), strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestValidatePrefix(t *testing.T) {
+	for prefix, valid := range map[string]bool{
+		"cloudcost_exporter": true,
+		"team_a:exporter":    true,
+		"":                   false,
+		"1exporter":          false,
+		"cloud-cost":         false,
+	} {
+		if err := ValidatePrefix(prefix); (err == nil) != valid {
+			t.Errorf("ValidatePrefix(%q) error = %v, want valid %v", prefix, err, valid)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Package servertls serves HTTPS with a certificate and an optional client
+// CA read from files, typically a mounted Kubernetes Secret. The files are
+// read again on Reload, e.g. on SIGHUP, so that renewed certificates are
+// served without a restart.
+package servertls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+)
+
+// Options are the files TLS is served from.
+type Options struct {
+	// CertFile and KeyFile are the PEM server certificate and key.
+	CertFile string
+	KeyFile  string
+	// ClientCAFile is a PEM bundle of the CAs client certificates must be
+	// signed by. Without it, clients are not asked for certificates.
+	ClientCAFile string
+}
+
+// Enabled reports whether o serves TLS at all.
+func (o Options) Enabled() bool {
+	return o.CertFile != "" || o.KeyFile != "" || o.ClientCAFile != ""
+}
+
+// Certs holds the certificate and client CAs currently served.
+type Certs struct {
+	opts      Options
+	cert      atomic.Pointer[tls.Certificate]
+	clientCAs atomic.Pointer[x509.CertPool]
+}
+
+// New reads the files of opts, failing if they cannot be read.
+func New(opts Options) (*Certs, error) {
+	if opts.CertFile == "" || opts.KeyFile == "" {
+		return nil, errors.New("TLS needs both a certificate and a key file")
+	}
+	c := &Certs{opts: opts}
+	if err := c.Reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Reload reads the files again. If any cannot be read, the previous
+// certificate and client CAs are kept.
+func (c *Certs) Reload() error {
+	cert, err := tls.LoadX509KeyPair(c.opts.CertFile, c.opts.KeyFile)
+	if err != nil {
+		return fmt.Errorf("load server certificate: %w", err)
+	}
+	var pool *x509.CertPool
+	if c.opts.ClientCAFile != "" {
+		pem, err := os.ReadFile(c.opts.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("read client CA file: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates in client CA file %s", c.opts.ClientCAFile)
+		}
+	}
+	c.cert.Store(&cert)
+	c.clientCAs.Store(pool)
+	return nil
+}
+
+// Config returns the TLS configuration of the server. Every handshake uses
+// the certificate and client CAs last loaded; with client CAs, clients must
+// present a certificate signed by one of them.
+func (c *Certs) Config() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cfg := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*c.cert.Load()},
+			}
+			if pool := c.clientCAs.Load(); pool != nil {
+				cfg.ClientCAs = pool
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+			return cfg, nil
+		},
+	}
+}
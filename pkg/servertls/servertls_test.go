@@ -0,0 +1,194 @@
+package servertls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCert writes a self-signed certificate for the loopback addresses
+// named name and its key as PEM files to dir and returns the key pair and
+// the file paths.
+func writeCert(t *testing.T, dir, name string) (tls.Certificate, string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		IPAddresses:           []net.IP{net.IPv6loopback, net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(certFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pair, certFile, keyFile
+}
+
+// serve starts an HTTPS server with the configuration of c.
+func serve(t *testing.T, c *Certs) *httptest.Server {
+	t.Helper()
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = c.Config()
+	server.Config.ErrorLog = log.New(io.Discard, "", 0) // rejected handshakes
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// get requests url trusting the certificate in caFile and presenting the
+// client certificates, returning the server's certificate name.
+func get(url, caFile string, clientCerts ...tls.Certificate) (string, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return "", err
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(pem)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      roots,
+		Certificates: clientCerts,
+	}}}
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return resp.TLS.PeerCertificates[0].Subject.CommonName, nil
+}
+
+func TestNew(t *testing.T) {
+	dir := t.TempDir()
+	_, certFile, keyFile := writeCert(t, dir, "server")
+	notPEM := filepath.Join(dir, "not.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr bool
+	}{
+		{"certificate", Options{CertFile: certFile, KeyFile: keyFile}, false},
+		{"client CA", Options{CertFile: certFile, KeyFile: keyFile, ClientCAFile: certFile}, false},
+		{"certificate without key", Options{CertFile: certFile}, true},
+		{"client CA without certificate", Options{ClientCAFile: certFile}, true},
+		{"invalid key", Options{CertFile: certFile, KeyFile: notPEM}, true},
+		{"missing client CA", Options{CertFile: certFile, KeyFile: keyFile, ClientCAFile: filepath.Join(dir, "missing.crt")}, true},
+		{"client CA without certificates", Options{CertFile: certFile, KeyFile: keyFile, ClientCAFile: notPEM}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.opts); (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.opts.Enabled() {
+				t.Error("Enabled() = false with TLS files")
+			}
+		})
+	}
+	if (Options{}).Enabled() {
+		t.Error("Enabled() = true without TLS files")
+	}
+}
+
+func TestCerts_Reload(t *testing.T) {
+	dir := t.TempDir()
+	_, certFile, keyFile := writeCert(t, dir, "first")
+	c, err := New(Options{CertFile: certFile, KeyFile: keyFile})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	server := serve(t, c)
+
+	if name, err := get(server.URL, certFile); err != nil || name != "first" {
+		t.Fatalf("get() = %q, %v, want first", name, err)
+	}
+
+	// A renewed certificate is served after a reload.
+	_, renewedCert, renewedKey := writeCert(t, dir, "renewed")
+	for _, f := range [][2]string{{renewedCert, certFile}, {renewedKey, keyFile}} {
+		if err := os.Rename(f[0], f[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if name, err := get(server.URL, certFile); err != nil || name != "renewed" {
+		t.Errorf("get() after Reload() = %q, %v, want renewed", name, err)
+	}
+
+	// A broken certificate is rejected and the previous one kept.
+	if err := os.WriteFile(certFile, []byte("broken"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Reload(); err == nil {
+		t.Error("Reload() of a broken certificate should fail")
+	}
+	if c.cert.Load().Leaf.Subject.CommonName != "renewed" {
+		t.Error("Reload() of a broken certificate replaced the served one")
+	}
+}
+
+func TestCerts_ClientCA(t *testing.T) {
+	dir := t.TempDir()
+	_, certFile, keyFile := writeCert(t, dir, "server")
+	clientCert, clientCAFile, _ := writeCert(t, dir, "client")
+	other, _, _ := writeCert(t, dir, "other")
+	c, err := New(Options{CertFile: certFile, KeyFile: keyFile, ClientCAFile: clientCAFile})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	server := serve(t, c)
+
+	if _, err := get(server.URL, certFile, clientCert); err != nil {
+		t.Errorf("get() with a trusted client certificate error = %v", err)
+	}
+	if _, err := get(server.URL, certFile); err == nil {
+		t.Error("get() without a client certificate should fail")
+	}
+	if _, err := get(server.URL, certFile, other); err == nil {
+		t.Error("get() with an untrusted client certificate should fail")
+	}
+}
@@ -0,0 +1,78 @@
+package types
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// AllocationResponse represents the response from the /allocation
+// endpoint: a set of allocations per step of the window, keyed by their
+// aggregated name, or a single set when queried with accumulate=true.
+type AllocationResponse struct {
+	Code int                     `json:"code"`
+	Data []map[string]Allocation `json:"data"`
+}
+
+// Allocation is the cost of the Kubernetes workloads aggregated under one
+// name, e.g. "namespace/controller/pod", by resource.
+type Allocation struct {
+	Name             string               `json:"name"`
+	Properties       AllocationProperties `json:"properties"`
+	Window           Window               `json:"window"`
+	CPUCost          float64              `json:"cpuCost"`
+	GPUCost          float64              `json:"gpuCost"`
+	RAMCost          float64              `json:"ramCost"`
+	PVCost           float64              `json:"pvCost"`
+	NetworkCost      float64              `json:"networkCost"`
+	LoadBalancerCost float64              `json:"loadBalancerCost"`
+	SharedCost       float64              `json:"sharedCost"`
+	ExternalCost     float64              `json:"externalCost"`
+}
+
+// AllocationProperties are the Kubernetes properties the allocations were
+// aggregated by. Properties not aggregated by are empty.
+type AllocationProperties struct {
+	Cluster        string `json:"cluster"`
+	Namespace      string `json:"namespace"`
+	ControllerKind string `json:"controllerKind"`
+	Controller     string `json:"controller"`
+	Pod            string `json:"pod"`
+}
+
+// AllocationCostTypes are the resources the cost of an allocation is split
+// by. Their sum is the allocation's total cost.
+var AllocationCostTypes = []string{"cpu", "gpu", "ram", "pv", "network", "load_balancer", "shared", "external"}
+
+// CostByType returns the cost of the allocation for one of
+// AllocationCostTypes.
+func (a Allocation) CostByType(costType string) (float64, bool) {
+	switch costType {
+	case "cpu":
+		return a.CPUCost, true
+	case "gpu":
+		return a.GPUCost, true
+	case "ram":
+		return a.RAMCost, true
+	case "pv":
+		return a.PVCost, true
+	case "network":
+		return a.NetworkCost, true
+	case "load_balancer":
+		return a.LoadBalancerCost, true
+	case "shared":
+		return a.SharedCost, true
+	case "external":
+		return a.ExternalCost, true
+	default:
+		return 0, false
+	}
+}
+
+// Validate checks that the response reports success, wrapping
+// ErrInvalidResponse otherwise.
+func (r *AllocationResponse) Validate() error {
+	if r.Code != http.StatusOK {
+		return fmt.Errorf("%w: allocation code %d", ErrInvalidResponse, r.Code)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+// AssetResponse represents the response from the /assets endpoint.
+type AssetResponse struct {
+	Code int      `json:"code"`
+	Data AssetSet `json:"data"`
+}
+
+// AssetSet holds assets keyed by their asset key. It decodes from a single
+// set, as OpenCost sends, or from an array of sets per step, as Kubecost
+// sends, whose assets are summed.
+type AssetSet map[string]Asset
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *AssetSet) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 || b[0] != '[' {
+		var set map[string]Asset
+		if err := json.Unmarshal(b, &set); err != nil {
+			return err
+		}
+		*s = set
+		return nil
+	}
+	var sets []map[string]Asset
+	if err := json.Unmarshal(b, &sets); err != nil {
+		return err
+	}
+	merged := make(AssetSet)
+	for _, set := range sets {
+		for key, a := range set {
+			if prev, ok := merged[key]; ok {
+				prev.TotalCost += a.TotalCost
+				a = prev
+			}
+			merged[key] = a
+		}
+	}
+	*s = merged
+	return nil
+}
+
+// Asset is the cost of a piece of infrastructure, such as a node, a disk or
+// a load balancer, over the window.
+type Asset struct {
+	// Type is e.g. "Node", "Disk", "LoadBalancer" or "ClusterManagement".
+	Type       string          `json:"type"`
+	Properties AssetProperties `json:"properties"`
+	Window     Window          `json:"window"`
+	// TotalCost includes the adjustment reconciling the asset with the
+	// cloud bill.
+	TotalCost float64 `json:"totalCost"`
+}
+
+// AssetProperties describe an asset.
+type AssetProperties struct {
+	Category   string `json:"category"`
+	Provider   string `json:"provider"`
+	Account    string `json:"account"`
+	Service    string `json:"service"`
+	Cluster    string `json:"cluster"`
+	Name       string `json:"name"`
+	ProviderID string `json:"providerID"`
+}
+
+// TypeName returns the asset type in snake case, e.g. "load_balancer" for
+// "LoadBalancer".
+func (a Asset) TypeName() string {
+	var b strings.Builder
+	for i, r := range a.Type {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Validate checks that the response reports success, wrapping
+// ErrInvalidResponse otherwise.
+func (r *AssetResponse) Validate() error {
+	if r.Code != http.StatusOK {
+		return fmt.Errorf("%w: assets code %d", ErrInvalidResponse, r.Code)
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+// Package focus represents cloud costs in the FinOps Open Cost and Usage
+// Specification (FOCUS) 1.0, so that exports and APIs share one
+// spec-compliant schema that FinOps tooling understands.
+//
+// OpenCost's cost types map to the FOCUS cost columns as follows:
+//
+//	ListCost          ListCost
+//	NetCost           ContractedCost
+//	AmortizedNetCost  EffectiveCost
+//	InvoicedCost      BilledCost
+//
+// AmortizedCost and the Kubernetes share of the cost have no FOCUS column
+// and are kept in the x_ extension columns the specification allows.
+package focus
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+// Currency is the billing currency of OpenCost's cloud costs.
+const Currency = "USD"
+
+// Row is one FOCUS cost and usage row. Column names are those of the
+// specification; nullable columns are pointers.
+type Row struct {
+	AvailabilityZone   string            `json:"AvailabilityZone,omitempty" parquet:"AvailabilityZone,optional"`
+	BilledCost         float64           `json:"BilledCost" parquet:"BilledCost"`
+	BillingAccountID   string            `json:"BillingAccountId" parquet:"BillingAccountId"`
+	BillingAccountName string            `json:"BillingAccountName,omitempty" parquet:"BillingAccountName,optional"`
+	BillingCurrency    string            `json:"BillingCurrency" parquet:"BillingCurrency"`
+	BillingPeriodStart time.Time         `json:"BillingPeriodStart" parquet:"BillingPeriodStart,timestamp"`
+	BillingPeriodEnd   time.Time         `json:"BillingPeriodEnd" parquet:"BillingPeriodEnd,timestamp"`
+	ChargeCategory     string            `json:"ChargeCategory" parquet:"ChargeCategory"`
+	ChargeClass        *string           `json:"ChargeClass" parquet:"ChargeClass,optional"`
+	ChargeDescription  string            `json:"ChargeDescription" parquet:"ChargeDescription"`
+	ChargeFrequency    string            `json:"ChargeFrequency" parquet:"ChargeFrequency"`
+	ChargePeriodStart  time.Time         `json:"ChargePeriodStart" parquet:"ChargePeriodStart,timestamp"`
+	ChargePeriodEnd    time.Time         `json:"ChargePeriodEnd" parquet:"ChargePeriodEnd,timestamp"`
+	ConsumedQuantity   *float64          `json:"ConsumedQuantity" parquet:"ConsumedQuantity,optional"`
+	ConsumedUnit       *string           `json:"ConsumedUnit" parquet:"ConsumedUnit,optional"`
+	ContractedCost     float64           `json:"ContractedCost" parquet:"ContractedCost"`
+	EffectiveCost      float64           `json:"EffectiveCost" parquet:"EffectiveCost"`
+	InvoiceIssuerName  string            `json:"InvoiceIssuerName" parquet:"InvoiceIssuerName"`
+	ListCost           float64           `json:"ListCost" parquet:"ListCost"`
+	ProviderName       string            `json:"ProviderName" parquet:"ProviderName"`
+	PublisherName      string            `json:"PublisherName" parquet:"PublisherName"`
+	RegionID           string            `json:"RegionId,omitempty" parquet:"RegionId,optional"`
+	ResourceID         string            `json:"ResourceId,omitempty" parquet:"ResourceId,optional"`
+	ServiceCategory    string            `json:"ServiceCategory" parquet:"ServiceCategory"`
+	ServiceName        string            `json:"ServiceName" parquet:"ServiceName"`
+	SubAccountID       string            `json:"SubAccountId,omitempty" parquet:"SubAccountId,optional"`
+	SubAccountName     string            `json:"SubAccountName,omitempty" parquet:"SubAccountName,optional"`
+	Tags               map[string]string `json:"Tags,omitempty" parquet:"Tags"`
+
+	XAmortizedCost     float64 `json:"x_AmortizedCost" parquet:"x_AmortizedCost"`
+	XKubernetesPercent float64 `json:"x_KubernetesPercent" parquet:"x_KubernetesPercent"`
+}
+
+// Allowed values of the FOCUS columns with a closed set of values.
+var (
+	ChargeCategories  = []string{"Adjustment", "Credit", "Purchase", "Tax", "Usage"}
+	ChargeFrequencies = []string{"One-Time", "Recurring", "Usage-Based"}
+	ServiceCategories = []string{
+		"AI and Machine Learning", "Analytics", "Business Applications", "Compute",
+		"Databases", "Developer Tools", "Identity", "Integration", "Internet of Things",
+		"Management and Governance", "Media", "Migration", "Mobile", "Multicloud",
+		"Networking", "Other", "Security", "Storage", "Web",
+	}
+)
+
+// serviceCategories maps OpenCost categories that are not FOCUS service
+// categories already.
+var serviceCategories = map[string]string{
+	"Network":    "Networking",
+	"Database":   "Databases",
+	"Management": "Management and Governance",
+}
+
+// FromItem converts an OpenCost cost item. OpenCost reports no separate
+// billing account for some providers; the account is used then.
+func FromItem(item types.CloudCostItem) Row {
+	p := item.Properties
+	start, end := item.Window.Start.UTC(), item.Window.End.UTC()
+	billingStart := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	row := Row{
+		AvailabilityZone:   p.AvailabilityZone,
+		BilledCost:         item.InvoicedCost.Cost,
+		BillingAccountID:   p.InvoiceEntityID,
+		BillingAccountName: p.InvoiceEntityName,
+		BillingCurrency:    Currency,
+		BillingPeriodStart: billingStart,
+		BillingPeriodEnd:   billingStart.AddDate(0, 1, 0),
+		ChargeCategory:     "Usage",
+		ChargeDescription:  description(p),
+		ChargeFrequency:    "Usage-Based",
+		ChargePeriodStart:  start,
+		ChargePeriodEnd:    end,
+		ContractedCost:     item.NetCost.Cost,
+		EffectiveCost:      item.AmortizedNetCost.Cost,
+		InvoiceIssuerName:  p.Provider,
+		ListCost:           item.ListCost.Cost,
+		ProviderName:       p.Provider,
+		PublisherName:      p.Provider,
+		RegionID:           p.RegionID,
+		ResourceID:         p.ProviderID,
+		ServiceCategory:    serviceCategory(p.Category),
+		ServiceName:        p.Service,
+		SubAccountID:       p.AccountID,
+		SubAccountName:     p.AccountName,
+		Tags:               p.Labels,
+		XAmortizedCost:     item.AmortizedCost.Cost,
+		XKubernetesPercent: item.ListCost.KubernetesPercent,
+	}
+	if row.BillingAccountID == "" {
+		row.BillingAccountID, row.BillingAccountName = p.AccountID, p.AccountName
+	}
+	if row.BilledCost < 0 {
+		row.ChargeCategory = "Credit"
+	}
+	if u := item.Usage; u != nil && u.Unit != "" {
+		quantity, unit := u.Quantity, u.Unit
+		row.ConsumedQuantity, row.ConsumedUnit = &quantity, &unit
+	}
+	return row
+}
+
+// FromResponse converts all items of a response, ordered by charge period
+// and resource for reproducible output.
+func FromResponse(data *types.CloudCostResponse) []Row {
+	var rows []Row
+	for _, set := range data.Data.Sets {
+		for _, item := range set.CloudCosts {
+			rows = append(rows, FromItem(item))
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if !rows[i].ChargePeriodStart.Equal(rows[j].ChargePeriodStart) {
+			return rows[i].ChargePeriodStart.Before(rows[j].ChargePeriodStart)
+		}
+		if rows[i].SubAccountID != rows[j].SubAccountID {
+			return rows[i].SubAccountID < rows[j].SubAccountID
+		}
+		if rows[i].ServiceName != rows[j].ServiceName {
+			return rows[i].ServiceName < rows[j].ServiceName
+		}
+		return rows[i].ResourceID < rows[j].ResourceID
+	})
+	return rows
+}
+
+func description(p types.CloudCostProperties) string {
+	if p.Category == "" {
+		return p.Service
+	}
+	return fmt.Sprintf("%s %s", p.Service, p.Category)
+}
+
+func serviceCategory(category string) string {
+	if c, ok := serviceCategories[category]; ok {
+		return c
+	}
+	if slices.Contains(ServiceCategories, category) {
+		return category
+	}
+	return "Other"
+}
+
+// Validate checks the row against the FOCUS 1.0 requirements this package
+// covers: mandatory columns, allowed values, and consistent periods and
+// quantities. All violations are reported.
+func (r Row) Validate() error {
+	var errs []error
+	for _, c := range []struct{ column, value string }{
+		{"BillingAccountId", r.BillingAccountID},
+		{"ChargeDescription", r.ChargeDescription},
+		{"InvoiceIssuerName", r.InvoiceIssuerName},
+		{"ProviderName", r.ProviderName},
+		{"PublisherName", r.PublisherName},
+		{"ServiceName", r.ServiceName},
+	} {
+		if c.value == "" {
+			errs = append(errs, fmt.Errorf("%s is empty", c.column))
+		}
+	}
+	if !isCurrencyCode(r.BillingCurrency) {
+		errs = append(errs, fmt.Errorf("BillingCurrency %q is not an ISO 4217 code", r.BillingCurrency))
+	}
+	for _, c := range []struct {
+		column, value string
+		allowed       []string
+	}{
+		{"ChargeCategory", r.ChargeCategory, ChargeCategories},
+		{"ChargeFrequency", r.ChargeFrequency, ChargeFrequencies},
+		{"ServiceCategory", r.ServiceCategory, ServiceCategories},
+	} {
+		if !slices.Contains(c.allowed, c.value) {
+			errs = append(errs, fmt.Errorf("%s %q is not one of %q", c.column, c.value, c.allowed))
+		}
+	}
+	if r.ChargeClass != nil && *r.ChargeClass != "Correction" {
+		errs = append(errs, fmt.Errorf("ChargeClass %q is not Correction or null", *r.ChargeClass))
+	}
+	if !r.ChargePeriodEnd.After(r.ChargePeriodStart) {
+		errs = append(errs, errors.New("ChargePeriodEnd is not after ChargePeriodStart"))
+	}
+	if !r.BillingPeriodEnd.After(r.BillingPeriodStart) {
+		errs = append(errs, errors.New("BillingPeriodEnd is not after BillingPeriodStart"))
+	}
+	if (r.ConsumedQuantity == nil) != (r.ConsumedUnit == nil) {
+		errs = append(errs, errors.New("ConsumedQuantity and ConsumedUnit must both be set or both be null"))
+	}
+	return errors.Join(errs...)
+}
+
+func isCurrencyCode(s string) bool {
+	if len(s) != 3 {
+		return false
+	}
+	for _, c := range s {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
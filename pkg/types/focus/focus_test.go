@@ -0,0 +1,206 @@
+package focus
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/types"
+)
+
+var day = time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+
+func item() types.CloudCostItem {
+	return types.CloudCostItem{
+		Properties: types.CloudCostProperties{
+			ProviderID:        "i-0abc123def456",
+			Provider:          "AWS",
+			AccountID:         "883112916672",
+			AccountName:       "workload",
+			InvoiceEntityID:   "453316427866",
+			InvoiceEntityName: "payer",
+			AvailabilityZone:  "eu-west-1a",
+			RegionID:          "eu-west-1",
+			Service:           "AmazonEC2",
+			Category:          "Compute",
+			Labels:            map[string]string{"owner": "team-alpha"},
+		},
+		Window:           types.Window{Start: day, End: day.AddDate(0, 0, 1)},
+		ListCost:         types.CostValue{Cost: 100, KubernetesPercent: 0.75},
+		NetCost:          types.CostValue{Cost: 90},
+		AmortizedNetCost: types.CostValue{Cost: 80},
+		InvoicedCost:     types.CostValue{Cost: 85},
+		AmortizedCost:    types.CostValue{Cost: 95},
+		Usage:            &types.Usage{Quantity: 24, Unit: "Hrs"},
+	}
+}
+
+func TestFromItem(t *testing.T) {
+	quantity, unit := 24.0, "Hrs"
+	want := Row{
+		AvailabilityZone:   "eu-west-1a",
+		BilledCost:         85,
+		BillingAccountID:   "453316427866",
+		BillingAccountName: "payer",
+		BillingCurrency:    "USD",
+		BillingPeriodStart: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		BillingPeriodEnd:   time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+		ChargeCategory:     "Usage",
+		ChargeDescription:  "AmazonEC2 Compute",
+		ChargeFrequency:    "Usage-Based",
+		ChargePeriodStart:  day,
+		ChargePeriodEnd:    day.AddDate(0, 0, 1),
+		ConsumedQuantity:   &quantity,
+		ConsumedUnit:       &unit,
+		ContractedCost:     90,
+		EffectiveCost:      80,
+		InvoiceIssuerName:  "AWS",
+		ListCost:           100,
+		ProviderName:       "AWS",
+		PublisherName:      "AWS",
+		RegionID:           "eu-west-1",
+		ResourceID:         "i-0abc123def456",
+		ServiceCategory:    "Compute",
+		ServiceName:        "AmazonEC2",
+		SubAccountID:       "883112916672",
+		SubAccountName:     "workload",
+		Tags:               map[string]string{"owner": "team-alpha"},
+		XAmortizedCost:     95,
+		XKubernetesPercent: 0.75,
+	}
+
+	got := FromItem(item())
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FromItem() = %+v, want %+v", got, want)
+	}
+	if err := got.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
+
+func TestFromItem_Variants(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*types.CloudCostItem)
+		check  func(Row) bool
+	}{
+		{
+			name:   "billing account falls back to the account",
+			modify: func(i *types.CloudCostItem) { i.Properties.InvoiceEntityID, i.Properties.InvoiceEntityName = "", "" },
+			check:  func(r Row) bool { return r.BillingAccountID == "883112916672" && r.BillingAccountName == "workload" },
+		},
+		{
+			name:   "credit",
+			modify: func(i *types.CloudCostItem) { i.InvoicedCost.Cost = -10 },
+			check:  func(r Row) bool { return r.ChargeCategory == "Credit" },
+		},
+		{
+			name:   "OpenCost network category",
+			modify: func(i *types.CloudCostItem) { i.Properties.Category = "Network" },
+			check:  func(r Row) bool { return r.ServiceCategory == "Networking" },
+		},
+		{
+			name:   "unknown category",
+			modify: func(i *types.CloudCostItem) { i.Properties.Category = "Misc" },
+			check:  func(r Row) bool { return r.ServiceCategory == "Other" },
+		},
+		{
+			name:   "no usage",
+			modify: func(i *types.CloudCostItem) { i.Usage = nil },
+			check:  func(r Row) bool { return r.ConsumedQuantity == nil && r.ConsumedUnit == nil },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := item()
+			tt.modify(&i)
+			row := FromItem(i)
+			if !tt.check(row) {
+				t.Errorf("FromItem() = %+v", row)
+			}
+			if err := row.Validate(); err != nil {
+				t.Errorf("Validate() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestRow_SpecExamples(t *testing.T) {
+	b, err := os.ReadFile("testdata/spec-example.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows []Row
+	if err := json.Unmarshal(b, &rows); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	for i, row := range rows {
+		if err := row.Validate(); err != nil {
+			t.Errorf("Validate() of example %d error = %v", i, err)
+		}
+	}
+
+	// The examples round-trip through the JSON and Parquet encodings.
+	var buf bytes.Buffer
+	if err := parquet.Write(&buf, rows); err != nil {
+		t.Fatalf("parquet.Write() error = %v", err)
+	}
+	back, err := parquet.Read[Row](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("parquet.Read() error = %v", err)
+	}
+	for i := range rows {
+		if !reflect.DeepEqual(back[i], rows[i]) {
+			t.Errorf("Parquet round trip of example %d = %+v, want %+v", i, back[i], rows[i])
+		}
+	}
+}
+
+func TestRow_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Row)
+	}{
+		{"missing billing account", func(r *Row) { r.BillingAccountID = "" }},
+		{"currency", func(r *Row) { r.BillingCurrency = "usd" }},
+		{"charge category", func(r *Row) { r.ChargeCategory = "Discount" }},
+		{"service category", func(r *Row) { r.ServiceCategory = "Network" }},
+		{"charge class", func(r *Row) { r.ChargeClass = new(string) }},
+		{"charge period", func(r *Row) { r.ChargePeriodEnd = r.ChargePeriodStart }},
+		{"unit without quantity", func(r *Row) { r.ConsumedQuantity = nil }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row := FromItem(item())
+			tt.modify(&row)
+			if err := row.Validate(); err == nil {
+				t.Error("Validate() should fail")
+			}
+		})
+	}
+}
+
+func TestFromResponse(t *testing.T) {
+	rds := item()
+	rds.Properties.Service, rds.Properties.ProviderID = "AmazonRDS", "db-1"
+	earlier := item()
+	earlier.Window = types.Window{Start: day.AddDate(0, 0, -1), End: day}
+	data := &types.CloudCostResponse{Data: types.CloudCostData{Sets: []types.CloudCostSet{
+		{CloudCosts: map[string]types.CloudCostItem{"rds": rds, "ec2": item()}},
+		{CloudCosts: map[string]types.CloudCostItem{"ec2": earlier}},
+	}}}
+
+	var got []string
+	for _, row := range FromResponse(data) {
+		got = append(got, row.ChargePeriodStart.Format(time.DateOnly)+" "+row.ServiceName)
+	}
+	want := []string{"2026-01-14 AmazonEC2", "2026-01-15 AmazonEC2", "2026-01-15 AmazonRDS"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FromResponse() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,149 @@
+package types
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Property returns the value of an OpenCost aggregate property, such as
+// "accountID", "service" or "label:owner". It reports false for names
+// OpenCost cannot aggregate by.
+func (p CloudCostProperties) Property(name string) (string, bool) {
+	if label, ok := strings.CutPrefix(name, "label:"); ok {
+		return p.Labels[label], label != ""
+	}
+	switch name {
+	case "provider":
+		return p.Provider, true
+	case "providerID":
+		return p.ProviderID, true
+	case "accountID":
+		return p.AccountID, true
+	case "invoiceEntityID":
+		return p.InvoiceEntityID, true
+	case "regionID":
+		return p.RegionID, true
+	case "availabilityZone":
+		return p.AvailabilityZone, true
+	case "service":
+		return p.Service, true
+	case "category":
+		return p.Category, true
+	default:
+		return "", false
+	}
+}
+
+// SetProperty sets an OpenCost aggregate property. See Property.
+func (p *CloudCostProperties) SetProperty(name, value string) {
+	if label, ok := strings.CutPrefix(name, "label:"); ok {
+		if p.Labels == nil {
+			p.Labels = make(map[string]string)
+		}
+		p.Labels[label] = value
+		return
+	}
+	switch name {
+	case "provider":
+		p.Provider = value
+	case "providerID":
+		p.ProviderID = value
+	case "accountID":
+		p.AccountID = value
+	case "invoiceEntityID":
+		p.InvoiceEntityID = value
+	case "regionID":
+		p.RegionID = value
+	case "availabilityZone":
+		p.AvailabilityZone = value
+	case "service":
+		p.Service = value
+	case "category":
+		p.Category = value
+	}
+}
+
+// ParseAggregate parses a comma-separated list of the properties OpenCost
+// aggregates cost items by, e.g. "accountID,service,label:team". Empty
+// means no aggregation, one item per resource.
+func ParseAggregate(s string) ([]string, error) {
+	var aggregate []string
+	for _, a := range strings.Split(s, ",") {
+		a = strings.TrimSpace(a)
+		if a == "" {
+			continue
+		}
+		if _, ok := (CloudCostProperties{}).Property(a); !ok {
+			return nil, fmt.Errorf("unknown aggregate property %q: want one of %s or label:<name>", a, strings.Join(AggregateProperties, ", "))
+		}
+		if slices.Contains(aggregate, a) {
+			return nil, fmt.Errorf("aggregate property %q listed twice", a)
+		}
+		aggregate = append(aggregate, a)
+	}
+	return aggregate, nil
+}
+
+// AggregateProperties are the properties OpenCost aggregates cost items by,
+// besides labels.
+var AggregateProperties = []string{"provider", "providerID", "accountID", "invoiceEntityID", "regionID", "availabilityZone", "service", "category"}
+
+// Key returns the cloudCosts map key OpenCost uses for the properties when
+// aggregating by aggregate: the property values joined by "/".
+func (p CloudCostProperties) Key(aggregate []string) string {
+	values := make([]string, len(aggregate))
+	for i, a := range aggregate {
+		values[i], _ = p.Property(a)
+	}
+	return strings.Join(values, "/")
+}
+
+// ParseKey parses a cloudCosts map key of a response aggregated by
+// aggregate into the properties it encodes. Only a provider ID may itself
+// contain "/", as ARNs and Azure resource IDs do; keys that cannot be split
+// unambiguously are rejected.
+func ParseKey(key string, aggregate []string) (CloudCostProperties, error) {
+	var p CloudCostProperties
+	for _, a := range aggregate {
+		if _, ok := p.Property(a); !ok {
+			return p, fmt.Errorf("unknown aggregate property %q", a)
+		}
+	}
+	parts := strings.Split(key, "/")
+	extra := len(parts) - len(aggregate)
+	if extra < 0 || extra > 0 && !slices.Contains(aggregate, "providerID") {
+		return p, fmt.Errorf("key %q has %d parts, want %d for %s", key, len(parts), len(aggregate), strings.Join(aggregate, ","))
+	}
+	for _, a := range aggregate {
+		n := 1
+		if a == "providerID" {
+			n += extra
+			extra = 0
+		}
+		p.SetProperty(a, strings.Join(parts[:n], "/"))
+		parts = parts[n:]
+	}
+	return p, nil
+}
+
+// CheckKey cross-checks a cloudCosts map key of a response aggregated by
+// aggregate against the properties of its item, reporting the properties
+// whose values differ.
+func (p CloudCostProperties) CheckKey(key string, aggregate []string) error {
+	fromKey, err := ParseKey(key, aggregate)
+	if err != nil {
+		return err
+	}
+	var mismatches []string
+	for _, a := range aggregate {
+		want, _ := fromKey.Property(a)
+		if got, _ := p.Property(a); got != want {
+			mismatches = append(mismatches, fmt.Sprintf("%s %q in key, %q in properties", a, want, got))
+		}
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("key %q does not match its properties: %s", key, strings.Join(mismatches, "; "))
+	}
+	return nil
+}
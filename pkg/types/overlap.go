@@ -0,0 +1,84 @@
+package types
+
+// Bounds returns the window the set covers: its own window if valid, and
+// otherwise the bounds of its valid item windows. It returns a zero window
+// if neither is known.
+func (s CloudCostSet) Bounds() Window {
+	if s.Window.Validate() == nil {
+		return s.Window
+	}
+	var w Window
+	for _, item := range s.CloudCosts {
+		if item.Window.Validate() != nil {
+			continue
+		}
+		if w.Start.IsZero() || item.Window.Start.Before(w.Start) {
+			w.Start = item.Window.Start
+		}
+		if item.Window.End.After(w.End) {
+			w.End = item.Window.End
+		}
+	}
+	return w
+}
+
+// Bounds returns the window the sets of the response cover together, from
+// the earliest start to the latest end of their Bounds. It returns a zero
+// window if none is known.
+func (r *CloudCostResponse) Bounds() Window {
+	var w Window
+	for _, set := range r.Data.Sets {
+		bounds := set.Bounds()
+		if bounds.Start.IsZero() {
+			continue
+		}
+		if w.Start.IsZero() || bounds.Start.Before(w.Start) {
+			w.Start = bounds.Start
+		}
+		if bounds.End.After(w.End) {
+			w.End = bounds.End
+		}
+	}
+	return w
+}
+
+// Overlaps reports whether the windows share any time. Adjacent windows,
+// where one ends when the other starts, do not overlap.
+func (w Window) Overlaps(other Window) bool {
+	return w.Start.Before(other.End) && other.Start.Before(w.End)
+}
+
+// DropOverlapping removes the sets whose window overlaps that of a later
+// set and returns their number. OpenCost returns such sets, e.g. when its
+// accumulate settings change, and summing them would count the overlapping
+// costs twice. Later sets are more recent and are kept; so are sets whose
+// window is unknown.
+func (r *CloudCostResponse) DropOverlapping() int {
+	var kept []Window
+	drop := make([]bool, len(r.Data.Sets))
+	for i := len(r.Data.Sets) - 1; i >= 0; i-- {
+		w := r.Data.Sets[i].Bounds()
+		if w.Validate() != nil {
+			continue
+		}
+		for _, k := range kept {
+			if w.Overlaps(k) {
+				drop[i] = true
+				break
+			}
+		}
+		if !drop[i] {
+			kept = append(kept, w)
+		}
+	}
+
+	sets := r.Data.Sets[:0]
+	for i, set := range r.Data.Sets {
+		if !drop[i] {
+			sets = append(sets, set)
+		}
+	}
+	n := len(r.Data.Sets) - len(sets)
+	r.Data.Sets = sets
+	return n
+}
@@ -0,0 +1,187 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/parallel"
+)
+
+// UnmarshalJSON implements json.Unmarshaler, decoding the sets concurrently
+// on up to GOMAXPROCS goroutines, as responses of daily sets over a long
+// window carry many large sets. Sets stays nil if the sets are missing.
+func (d *CloudCostData) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	var raw struct {
+		Sets []json.RawMessage `json:"sets"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if raw.Sets == nil {
+		d.Sets = nil
+		return nil
+	}
+	sets := make([]CloudCostSet, len(raw.Sets))
+	errs := make([]error, len(raw.Sets))
+	parallel.For(len(raw.Sets), parallel.Workers(), func(i int) {
+		if err := json.Unmarshal(raw.Sets[i], &sets[i]); err != nil {
+			errs[i] = fmt.Errorf("sets[%d]: %w", i, err)
+		}
+	})
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	d.Sets = sets
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler, decoding the cost items one by
+// one with a streaming decoder. An item that cannot be decoded, e.g. because
+// OpenCost sent an object where a string belongs, is left out and recorded
+// in Skipped instead of failing the whole response.
+func (s *CloudCostSet) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if err := expectDelim(dec, '{'); err != nil {
+		return fmt.Errorf("cost set: %w", err)
+	}
+	return s.decodeFields(dec)
+}
+
+// decodeFields decodes the fields of a set from dec, positioned after its
+// opening brace, up to and including its closing brace.
+func (s *CloudCostSet) decodeFields(dec *json.Decoder) error {
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch k, _ := key.(string); {
+		case strings.EqualFold(k, "cloudCosts"):
+			if err := s.decodeItems(dec); err != nil {
+				return fmt.Errorf("cloudCosts: %w", err)
+			}
+		case strings.EqualFold(k, "window"):
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return err
+			}
+			// A malformed set window only prevents overlap detection by
+			// it; see Bounds.
+			_ = json.Unmarshal(raw, &s.Window)
+		default:
+			var ignored json.RawMessage
+			if err := dec.Decode(&ignored); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := dec.Token()
+	return err
+}
+
+func (s *CloudCostSet) decodeItems(dec *json.Decoder) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		s.CloudCosts = nil
+		return nil
+	}
+	if tok != json.Delim('{') {
+		return fmt.Errorf("want an object, got %v", tok)
+	}
+	s.CloudCosts = make(map[string]CloudCostItem)
+	// Decoding into raw reuses its storage, and items copy what they keep.
+	var raw json.RawMessage
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := tok.(string)
+		if err := dec.Decode(&raw); err != nil {
+			return err
+		}
+		var item CloudCostItem
+		if err := json.Unmarshal(raw, &item); err != nil {
+			s.Skip(fmt.Errorf("item %q: %w", key, err))
+			continue
+		}
+		s.CloudCosts[key] = item
+	}
+	_, err = dec.Token()
+	return err
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != want {
+		return fmt.Errorf("want %v, got %v", want, tok)
+	}
+	return nil
+}
+
+// Skip records that an item was left out of the set, e.g. when merging
+// sets that had items skipped.
+func (s *CloudCostSet) Skip(errs ...error) {
+	s.skipped = append(s.skipped, errs...)
+}
+
+// Skipped returns why items were left out of the set.
+func (s CloudCostSet) Skipped() []error {
+	return s.skipped
+}
+
+// DropInvalid removes the items that fail validation, such as items with
+// NaN costs, records them in Skipped and returns their number, so that the
+// rest of the response can still be used.
+func (r *CloudCostResponse) DropInvalid() int {
+	n := 0
+	for i := range r.Data.Sets {
+		set := &r.Data.Sets[i]
+		for _, key := range slices.Sorted(maps.Keys(set.CloudCosts)) {
+			if err := set.CloudCosts[key].validate(); err != nil {
+				set.Skip(fmt.Errorf("item %q: %w", key, err))
+				delete(set.CloudCosts, key)
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// Skipped returns why items were left out of the response while decoding
+// or by DropInvalid.
+func (r *CloudCostResponse) Skipped() []error {
+	var errs []error
+	for _, set := range r.Data.Sets {
+		errs = append(errs, set.skipped...)
+	}
+	return errs
+}
+
+// Partial reports whether items were left out of the response.
+func (r *CloudCostResponse) Partial() bool {
+	for _, set := range r.Data.Sets {
+		if len(set.skipped) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package types
+
+import "strings"
+
+// Resource is the type and name of the cloud resource a cost item is for,
+// derived from its ProviderID.
+type Resource struct {
+	// Type is e.g. "instance" for an AWS ARN or EC2 ID, "instances" for a
+	// GCP resource name or "microsoft.compute/virtualmachines" for an
+	// Azure resource ID.
+	Type string
+	// Name is the name or ID of the resource within its type.
+	Name string
+}
+
+// awsIDPrefixes are the resource types of the AWS resource IDs OpenCost
+// reports instead of ARNs, e.g. i-0abc for an EC2 instance, by prefix.
+var awsIDPrefixes = map[string]string{
+	"i":        "instance",
+	"vol":      "volume",
+	"snap":     "snapshot",
+	"eni":      "network-interface",
+	"eipalloc": "elastic-ip",
+	"nat":      "natgateway",
+	"igw":      "internet-gateway",
+	"vpc":      "vpc",
+	"subnet":   "subnet",
+	"sg":       "security-group",
+	"ami":      "image",
+	"vpce":     "vpc-endpoint",
+	"tgw":      "transit-gateway",
+	"fs":       "file-system",
+}
+
+// ParseResource derives the resource of a ProviderID in one of the formats
+// OpenCost reports:
+//
+//   - AWS ARNs, arn:aws:rds:us-east-1:123:db:orders or
+//     arn:aws:ec2:us-east-1:123:instance/i-0abc, with the resource type of
+//     the ARN or, without one such as for S3 buckets, the service;
+//   - AWS resource IDs, i-0abc or vol-0abc, for the known ID prefixes;
+//   - GCP resource names, //compute.googleapis.com/projects/p/zones/z/instances/vm
+//     or projects/p/zones/z/instances/vm, with the last collection as type;
+//   - Azure resource IDs, /subscriptions/s/resourceGroups/g/providers/
+//     Microsoft.Compute/virtualMachines/vm, with the lowercased namespace
+//     and types as type and the names of nested resources joined by "/".
+//
+// Other IDs, such as those of Kubernetes nodes, yield an empty Resource.
+func ParseResource(providerID string) Resource {
+	switch {
+	case strings.HasPrefix(providerID, "arn:"):
+		return parseARN(providerID)
+	case strings.HasPrefix(providerID, "/subscriptions/"):
+		return parseAzureResourceID(providerID)
+	case strings.HasPrefix(providerID, "//"), strings.HasPrefix(providerID, "projects/"):
+		return parseGCPResourceName(providerID)
+	}
+	if prefix, _, ok := strings.Cut(providerID, "-"); ok {
+		if typ, ok := awsIDPrefixes[prefix]; ok && !strings.ContainsAny(providerID, "/:") {
+			return Resource{Type: typ, Name: providerID}
+		}
+	}
+	return Resource{}
+}
+
+// parseARN parses arn:partition:service:region:account:resource, where
+// resource is type/name, type:name or name.
+func parseARN(arn string) Resource {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) < 6 || parts[5] == "" {
+		return Resource{}
+	}
+	service, resource := parts[2], parts[5]
+	if i := strings.IndexAny(resource, "/:"); i > 0 {
+		return Resource{Type: resource[:i], Name: resource[i+1:]}
+	}
+	return Resource{Type: service, Name: resource}
+}
+
+// parseGCPResourceName parses a full or relative resource name of
+// alternating collections and IDs, such as projects/p/zones/z/instances/vm.
+func parseGCPResourceName(name string) Resource {
+	if rest, ok := strings.CutPrefix(name, "//"); ok {
+		// Drop the service, e.g. compute.googleapis.com.
+		_, name, _ = strings.Cut(rest, "/")
+	}
+	parts := strings.Split(strings.Trim(name, "/"), "/")
+	if len(parts) < 2 || len(parts)%2 != 0 {
+		return Resource{}
+	}
+	return Resource{Type: parts[len(parts)-2], Name: parts[len(parts)-1]}
+}
+
+// parseAzureResourceID parses an ID such as
+// /subscriptions/s/resourceGroups/g/providers/Microsoft.Sql/servers/db1/databases/orders,
+// of type microsoft.sql/servers/databases named db1/orders.
+func parseAzureResourceID(id string) Resource {
+	parts := strings.Split(strings.Trim(id, "/"), "/")
+	// The last providers segment, as extension resources have several.
+	i := len(parts) - 1
+	for i >= 0 && !strings.EqualFold(parts[i], "providers") {
+		i--
+	}
+	parts = parts[i+1:]
+	if i < 0 || len(parts) < 3 || len(parts)%2 != 1 {
+		return Resource{}
+	}
+	typ := []string{parts[0]}
+	var names []string
+	for j := 1; j+1 < len(parts); j += 2 {
+		typ = append(typ, parts[j])
+		names = append(names, parts[j+1])
+	}
+	// Resource types are case-insensitive; names are kept as reported.
+	return Resource{Type: strings.ToLower(strings.Join(typ, "/")), Name: strings.Join(names, "/")}
+}
+
+// Resource returns the resource of the ProviderID of the properties. See
+// ParseResource.
+func (p CloudCostProperties) Resource() Resource {
+	return ParseResource(p.ProviderID)
+}
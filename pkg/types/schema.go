@@ -0,0 +1,65 @@
+package types
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// OpenCost has renamed cost item properties across versions. The JSON names
+// of CloudCostProperties are those of current releases; propertyAliases
+// lists the names earlier releases used for the same property, so that an
+// OpenCost upgrade or downgrade does not silently empty metric labels.
+// encoding/json already matches names case-insensitively, so differences in
+// casing such as "accountId" need no alias.
+var propertyAliases = []struct {
+	name    string
+	aliases []string
+	field   func(*CloudCostProperties) *string
+}{
+	{"accountID", []string{"workGroupID"}, func(p *CloudCostProperties) *string { return &p.AccountID }},
+	{"invoiceEntityID", []string{"billingID"}, func(p *CloudCostProperties) *string { return &p.InvoiceEntityID }},
+	{"regionID", []string{"region"}, func(p *CloudCostProperties) *string { return &p.RegionID }},
+}
+
+// UnmarshalJSON implements json.Unmarshaler, normalizing the property names
+// of earlier OpenCost releases. A current name takes precedence over its
+// aliases.
+func (p *CloudCostProperties) UnmarshalJSON(b []byte) error {
+	type plain CloudCostProperties
+	if err := json.Unmarshal(b, (*plain)(p)); err != nil {
+		return err
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	for _, a := range propertyAliases {
+		field := a.field(p)
+		if *field != "" || lookupFold(raw, a.name) != nil {
+			continue
+		}
+		for _, alias := range a.aliases {
+			if v := lookupFold(raw, alias); v != nil {
+				// A non-string value leaves the property empty, as it would
+				// under its current name.
+				_ = json.Unmarshal(v, field)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// lookupFold returns the value of key in raw, matching the key
+// case-insensitively like encoding/json does.
+func lookupFold(raw map[string]json.RawMessage, key string) json.RawMessage {
+	if v, ok := raw[key]; ok {
+		return v
+	}
+	for k, v := range raw {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DecodeCloudCostResponse decodes a cloudCost response as it is read from
+// r, item by item, into the same result as json.Unmarshal. Unlike reading
+// the body whole first, which responses of long windows make hundreds of
+// megabytes large, only the decoded items and the item being decoded are
+// held in memory. The sets are decoded one after the other rather than
+// concurrently.
+func DecodeCloudCostResponse(r io.Reader) (*CloudCostResponse, error) {
+	dec := json.NewDecoder(r)
+	var resp CloudCostResponse
+	if err := resp.decode(dec); err != nil {
+		if errors.Is(err, io.EOF) {
+			// The decoder reports a body ending between tokens as EOF.
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if tok, err := dec.Token(); err != io.EOF {
+		if err == nil {
+			err = fmt.Errorf("unexpected %v after the response", tok)
+		}
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (r *CloudCostResponse) decode(dec *json.Decoder) error {
+	tok, err := dec.Token()
+	if err != nil || tok == nil {
+		return err
+	}
+	if tok != json.Delim('{') {
+		return fmt.Errorf("response: want an object, got %v", tok)
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch k, _ := key.(string); {
+		case strings.EqualFold(k, "code"):
+			err = dec.Decode(&r.Code)
+		case strings.EqualFold(k, "data"):
+			if err = r.Data.decode(dec); err != nil {
+				err = fmt.Errorf("data: %w", err)
+			}
+		case strings.EqualFold(k, "window"):
+			err = dec.Decode(&r.Window)
+		default:
+			var ignored json.RawMessage
+			err = dec.Decode(&ignored)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err = dec.Token()
+	return err
+}
+
+func (d *CloudCostData) decode(dec *json.Decoder) error {
+	tok, err := dec.Token()
+	if err != nil || tok == nil {
+		return err
+	}
+	if tok != json.Delim('{') {
+		return fmt.Errorf("want an object, got %v", tok)
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if k, _ := key.(string); strings.EqualFold(k, "sets") {
+			err = d.decodeSets(dec)
+		} else {
+			var ignored json.RawMessage
+			err = dec.Decode(&ignored)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err = dec.Token()
+	return err
+}
+
+func (d *CloudCostData) decodeSets(dec *json.Decoder) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		d.Sets = nil
+		return nil
+	}
+	if tok != json.Delim('[') {
+		return fmt.Errorf("sets: want an array, got %v", tok)
+	}
+	d.Sets = []CloudCostSet{}
+	for i := 0; dec.More(); i++ {
+		var set CloudCostSet
+		tok, err := dec.Token()
+		switch {
+		case err != nil, tok == nil:
+		case tok == json.Delim('{'):
+			err = set.decodeFields(dec)
+		default:
+			err = fmt.Errorf("cost set: want {, got %v", tok)
+		}
+		if err != nil {
+			return fmt.Errorf("sets[%d]: %w", i, err)
+		}
+		d.Sets = append(d.Sets, set)
+	}
+	_, err = dec.Token()
+	return err
+}
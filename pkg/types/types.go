@@ -1,10 +1,89 @@
 // Package types defines the data structures for the OpenCost cloudCost API response.
 package types
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
 // CloudCostResponse represents the response from the /cloudCost endpoint.
 type CloudCostResponse struct {
 	Code int           `json:"code"`
 	Data CloudCostData `json:"data"`
+
+	// Window is the window the data was fetched for if the exporter fell
+	// back to it from the configured window, which held no cost items;
+	// empty otherwise. OpenCost does not send it.
+	Window string `json:"window,omitempty"`
+}
+
+// Empty reports whether no set of the response holds cost items.
+func (r *CloudCostResponse) Empty() bool {
+	for _, set := range r.Data.Sets {
+		if len(set.CloudCosts) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// ErrInvalidResponse is returned by CloudCostResponse.Validate.
+var ErrInvalidResponse = errors.New("invalid cloud cost response")
+
+// Validate checks that the response can be cached and exported: it must
+// report success and carry a sets array, and its costs must be finite,
+// since Prometheus cannot represent NaN or infinite costs meaningfully.
+// Item windows may be open, as OpenCost reports them for ongoing periods,
+// but must not end before they start. All problems are reported, wrapping
+// ErrInvalidResponse.
+func (r *CloudCostResponse) Validate() error {
+	if r.Code != http.StatusOK {
+		return fmt.Errorf("%w: code %d", ErrInvalidResponse, r.Code)
+	}
+	if r.Data.Sets == nil {
+		return fmt.Errorf("%w: no sets", ErrInvalidResponse)
+	}
+	var errs []error
+	for i, set := range r.Data.Sets {
+		for _, key := range slices.Sorted(maps.Keys(set.CloudCosts)) {
+			if err := set.CloudCosts[key].validate(); err != nil {
+				errs = append(errs, fmt.Errorf("set %d item %q: %w", i, key, err))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrInvalidResponse, errors.Join(errs...))
+	}
+	return nil
+}
+
+// validate checks the numbers and window of the item.
+func (i CloudCostItem) validate() error {
+	for _, costType := range CostTypes {
+		v, _ := i.CostByType(costType)
+		if !finite(v.Cost) || !finite(v.KubernetesPercent) {
+			return fmt.Errorf("%s cost %v, kubernetes percent %v not finite", costType, v.Cost, v.KubernetesPercent)
+		}
+	}
+	if i.Usage != nil && !finite(i.Usage.Quantity) {
+		return fmt.Errorf("usage quantity %v not finite", i.Usage.Quantity)
+	}
+	if !i.Window.Start.IsZero() && !i.Window.End.IsZero() && !i.Window.End.After(i.Window.Start) {
+		return i.Window.Validate()
+	}
+	return nil
+}
+
+func finite(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
 }
 
 // CloudCostData contains the cost data sets.
@@ -15,6 +94,10 @@ type CloudCostData struct {
 // CloudCostSet represents a set of cloud costs for a time window.
 type CloudCostSet struct {
 	CloudCosts map[string]CloudCostItem `json:"cloudCosts"`
+	Window     Window                   `json:"window"`
+
+	// skipped holds why items were left out of the set; see Skipped.
+	skipped []error
 }
 
 // CloudCostItem represents a single cloud cost entry.
@@ -26,6 +109,36 @@ type CloudCostItem struct {
 	AmortizedNetCost CostValue           `json:"amortizedNetCost"`
 	InvoicedCost     CostValue           `json:"invoicedCost"`
 	AmortizedCost    CostValue           `json:"amortizedCost"`
+
+	// Usage is the quantity consumed, for providers and OpenCost versions
+	// that report it; nil otherwise.
+	Usage *Usage `json:"usage,omitempty"`
+
+	// Source names the OpenCost instance the item was fetched from in
+	// federation mode. It is not part of the OpenCost API.
+	Source string `json:"source,omitempty"`
+}
+
+// CostTypes lists the cost type names used in metric labels, in the order
+// OpenCost reports them.
+var CostTypes = []string{"list", "net", "amortized_net", "invoiced", "amortized"}
+
+// CostByType returns the cost for a cost type name such as "amortized_net".
+func (i CloudCostItem) CostByType(costType string) (CostValue, bool) {
+	switch costType {
+	case "list":
+		return i.ListCost, true
+	case "net":
+		return i.NetCost, true
+	case "amortized_net":
+		return i.AmortizedNetCost, true
+	case "invoiced":
+		return i.InvoicedCost, true
+	case "amortized":
+		return i.AmortizedCost, true
+	default:
+		return CostValue{}, false
+	}
 }
 
 // CloudCostProperties contains metadata about the cloud cost.
@@ -41,21 +154,302 @@ type CloudCostProperties struct {
 	Service           string            `json:"service"`
 	Category          string            `json:"category"`
 	Labels            map[string]string `json:"labels,omitempty"`
+
+	// KubernetesLabels are the Kubernetes labels of the resource, for
+	// OpenCost builds that report them apart from Labels. Labels then still
+	// holds them mixed with the provider's resource tags.
+	KubernetesLabels map[string]string `json:"kubernetesLabels,omitempty"`
+}
+
+// LabelSource selects where Label looks up a label.
+type LabelSource int
+
+const (
+	// AnyLabel is a resource tag or a Kubernetes label.
+	AnyLabel LabelSource = iota
+	// ProviderTag is a resource tag of the cloud provider.
+	ProviderTag
+	// KubernetesLabel is a Kubernetes label.
+	KubernetesLabel
+)
+
+// Label returns the label key from source. Without KubernetesLabels, all
+// labels count as provider tags, since Kubernetes labels cannot be told
+// apart then.
+func (p CloudCostProperties) Label(source LabelSource, key string) string {
+	switch source {
+	case ProviderTag:
+		if v, ok := p.KubernetesLabels[key]; ok && p.Labels[key] == v {
+			return ""
+		}
+		return p.Labels[key]
+	case KubernetesLabel:
+		return p.KubernetesLabels[key]
+	default:
+		if v, ok := p.Labels[key]; ok {
+			return v
+		}
+		return p.KubernetesLabels[key]
+	}
+}
+
+// Provider names as reported in CloudCostProperties.Provider.
+const (
+	ProviderAWS   = "AWS"
+	ProviderAzure = "Azure"
+	ProviderGCP   = "GCP"
+)
+
+// IsProvider reports whether the properties belong to provider, ignoring
+// case.
+func (p CloudCostProperties) IsProvider(provider string) bool {
+	return strings.EqualFold(p.Provider, provider)
+}
+
+// AzureProperties are the Azure meanings of the generic properties.
+type AzureProperties struct {
+	SubscriptionID   string
+	SubscriptionName string
+	ResourceGroup    string
+}
+
+// Azure returns the Azure view of the properties: OpenCost reports the
+// subscription as the account, and the resource group is part of the
+// resource ID in ProviderID. It reports false for other providers.
+func (p CloudCostProperties) Azure() (AzureProperties, bool) {
+	if !p.IsProvider(ProviderAzure) {
+		return AzureProperties{}, false
+	}
+	return AzureProperties{
+		SubscriptionID:   p.AccountID,
+		SubscriptionName: p.AccountName,
+		ResourceGroup:    azureResourceGroup(p.ProviderID),
+	}, true
+}
+
+// azureResourceGroup extracts the resource group from an Azure resource ID
+// such as "/subscriptions/<id>/resourceGroups/<group>/providers/...".
+// Resource IDs are case-insensitive; the group is lowercased so that
+// differently cased IDs of one group aggregate together.
+func azureResourceGroup(resourceID string) string {
+	parts := strings.Split(resourceID, "/")
+	for i := 0; i+1 < len(parts); i++ {
+		if strings.EqualFold(parts[i], "resourceGroups") {
+			return strings.ToLower(parts[i+1])
+		}
+	}
+	return ""
 }
 
-// Window represents the time window for the cost data.
+// GCPProperties are the GCP meanings of the generic properties.
+type GCPProperties struct {
+	ProjectID          string
+	ProjectName        string
+	BillingAccountID   string
+	BillingAccountName string
+}
+
+// GCP returns the GCP view of the properties: OpenCost reports the project
+// as the account and the billing account as the invoice entity. It reports
+// false for other providers.
+func (p CloudCostProperties) GCP() (GCPProperties, bool) {
+	if !p.IsProvider(ProviderGCP) {
+		return GCPProperties{}, false
+	}
+	return GCPProperties{
+		ProjectID:          p.AccountID,
+		ProjectName:        p.AccountName,
+		BillingAccountID:   p.InvoiceEntityID,
+		BillingAccountName: p.InvoiceEntityName,
+	}, true
+}
+
+// Window represents the time window for the cost data. OpenCost reports its
+// bounds as RFC3339 timestamps; missing bounds are zero.
 type Window struct {
-	Start string `json:"start"`
-	End   string `json:"end"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It accepts null and empty
+// bounds, which OpenCost sends for open windows, as zero times. Malformed
+// bounds are zero as well, leaving the window invalid rather than failing
+// the whole response.
+func (w *Window) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		Start *string `json:"start"`
+		End   *string `json:"end"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	*w = Window{Start: parseBound(raw.Start), End: parseBound(raw.End)}
+	return nil
+}
+
+func parseBound(s *string) time.Time {
+	if s == nil {
+		return time.Time{}
+	}
+	t, _ := time.Parse(time.RFC3339, *s)
+	return t
+}
+
+// MarshalJSON implements json.Marshaler, encoding zero bounds as null so
+// that the result decodes to the same Window.
+func (w Window) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Start *string `json:"start"`
+		End   *string `json:"end"`
+	}{formatBound(w.Start), formatBound(w.End)})
+}
+
+func formatBound(t time.Time) *string {
+	if t.IsZero() {
+		return nil
+	}
+	s := t.Format(time.RFC3339)
+	return &s
+}
+
+// Duration returns the length of the window, or 0 if a bound is missing.
+func (w Window) Duration() time.Duration {
+	if w.Start.IsZero() || w.End.IsZero() {
+		return 0
+	}
+	return w.End.Sub(w.Start)
+}
+
+// Validate reports whether both bounds are set and End is after Start.
+func (w Window) Validate() error {
+	switch {
+	case w.Start.IsZero() || w.End.IsZero():
+		return errors.New("window bound missing")
+	case w.Duration() <= 0:
+		return fmt.Errorf("window end %s is not after start %s", w.End.Format(time.RFC3339), w.Start.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Usage is a consumed quantity in the provider's unit, e.g. 24 "Hrs" of an
+// instance or 150 "GB-Mo" of storage.
+type Usage struct {
+	Quantity float64 `json:"quantity"`
+	Unit     string  `json:"unit"`
+
+	coerced int
+}
+
+// UnmarshalJSON implements json.Unmarshaler, accepting a quantity sent as a
+// string or null. See CloudCostResponse.Coercions.
+func (u *Usage) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		Quantity json.RawMessage `json:"quantity"`
+		Unit     string          `json:"unit"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	*u = Usage{Unit: raw.Unit}
+	var ok bool
+	if u.Quantity, ok = parseNumber(raw.Quantity); !ok {
+		u.coerced++
+	}
+	return nil
+}
+
+// Add returns the sum of u and other. Quantities in different units cannot
+// be added, and Add reports false for them. A nil Usage adds nothing.
+func (u *Usage) Add(other *Usage) (*Usage, bool) {
+	switch {
+	case u == nil:
+		return other, true
+	case other == nil:
+		return u, true
+	case u.Unit != other.Unit:
+		return nil, false
+	}
+	return &Usage{Quantity: u.Quantity + other.Quantity, Unit: u.Unit}, true
 }
 
 // CostValue represents a cost amount with Kubernetes attribution.
 type CostValue struct {
 	Cost              float64 `json:"cost"`
 	KubernetesPercent float64 `json:"kubernetesPercent"`
+
+	coerced int
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Some OpenCost builds send
+// amounts as strings or null, or a bare amount instead of the object; they
+// are accepted rather than failing the whole response. See
+// CloudCostResponse.Coercions.
+func (v *CostValue) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		Cost              json.RawMessage `json:"cost"`
+		KubernetesPercent json.RawMessage `json:"kubernetesPercent"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil || string(b) == "null" {
+		cost, _ := parseNumber(b)
+		*v = CostValue{Cost: cost, coerced: 1}
+		return nil
+	}
+	*v = CostValue{}
+	for _, f := range []struct {
+		raw json.RawMessage
+		dst *float64
+	}{{raw.Cost, &v.Cost}, {raw.KubernetesPercent, &v.KubernetesPercent}} {
+		n, ok := parseNumber(f.raw)
+		if !ok {
+			v.coerced++
+		}
+		*f.dst = n
+	}
+	return nil
+}
+
+// parseNumber decodes a JSON number. It also accepts a number in a string,
+// and takes null, empty strings and anything else unparsable as 0, but
+// reports false for all of these. An absent value is 0 without being
+// coerced.
+func parseNumber(raw json.RawMessage) (float64, bool) {
+	if len(raw) == 0 {
+		return 0, true
+	}
+	if string(raw) == "null" {
+		return 0, false
+	}
+	var f float64
+	if err := json.Unmarshal(raw, &f); err == nil {
+		return f, true
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		f, _ = strconv.ParseFloat(strings.TrimSpace(s), 64)
+	}
+	return f, false
+}
+
+// Coercions returns the number of numeric values in the response that
+// OpenCost sent as strings, null or otherwise not as JSON numbers, and that
+// were decoded leniently.
+func (r *CloudCostResponse) Coercions() int {
+	n := 0
+	for _, set := range r.Data.Sets {
+		for _, item := range set.CloudCosts {
+			for _, v := range []CostValue{item.ListCost, item.NetCost, item.AmortizedNetCost, item.InvoicedCost, item.AmortizedCost} {
+				n += v.coerced
+			}
+			if item.Usage != nil {
+				n += item.Usage.coerced
+			}
+		}
+	}
+	return n
 }
 
-// ExchangeRateResponse represents the response from the Frankfurter API.
+// ExchangeRateResponse represents the response from the Frankfurter API, to
+// which the other exchange rate providers are converted.
 type ExchangeRateResponse struct {
 	Amount float64            `json:"amount"`
 	Base   string             `json:"base"`
@@ -2,8 +2,15 @@ package types
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"os"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestCloudCostResponseUnmarshal(t *testing.T) {
@@ -43,6 +50,56 @@ func TestCloudCostResponseUnmarshal(t *testing.T) {
 	}
 }
 
+func TestDecodeCloudCostResponse(t *testing.T) {
+	var inputs []string
+	for _, name := range []string{"cloudcost-response.json", "cloudcost-response-legacy.json"} {
+		data, err := os.ReadFile("testdata/" + name)
+		if err != nil {
+			t.Fatalf("failed to read fixture: %v", err)
+		}
+		inputs = append(inputs, string(data))
+	}
+	inputs = append(inputs,
+		`null`,
+		`{}`,
+		`{"code": 200, "data": null}`,
+		`{"code": 200, "data": {"sets": null}}`,
+		`{"Code": 200, "Data": {"Sets": [null, {"cloudCosts": null}]}, "window": "7d", "message": {"ignored": [1]}}`,
+		`{"code": 200, "data": {"other": 1, "sets": [{"window": {"start": "2026-01-01T00:00:00Z", "end": "2026-01-02T00:00:00Z"}, "cloudCosts": {
+			"ok": {"properties": {"accountID": "111"}, "listCost": {"cost": 1}},
+			"bad": {"properties": {"accountID": {}}}
+		}}]}}`,
+	)
+	for _, input := range inputs {
+		var want CloudCostResponse
+		if err := json.Unmarshal([]byte(input), &want); err != nil {
+			t.Fatalf("Unmarshal(%.40s) error = %v", input, err)
+		}
+		got, err := DecodeCloudCostResponse(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("DecodeCloudCostResponse(%.40s) error = %v", input, err)
+		}
+		if !reflect.DeepEqual(*got, want) {
+			t.Errorf("DecodeCloudCostResponse(%.40s) = %+v, want %+v", input, *got, want)
+		}
+	}
+
+	for _, input := range []string{
+		``,
+		`{"code": 200, "data": {"sets": [`,
+		`{"code": 200,`,
+		`{"code": 200} {}`,
+		`{"code": "200"}`,
+		`{"code": 200, "data": {"sets": {}}}`,
+		`{"code": 200, "data": {"sets": [1]}}`,
+		`[]`,
+	} {
+		if _, err := DecodeCloudCostResponse(strings.NewReader(input)); err == nil {
+			t.Errorf("DecodeCloudCostResponse(%s) error = nil, want an error", input)
+		}
+	}
+}
+
 func TestCloudCostItemUnmarshal(t *testing.T) {
 	input := `{
 		"properties": {
@@ -61,7 +118,8 @@ func TestCloudCostItemUnmarshal(t *testing.T) {
 		"netCost": {"cost": 80.40, "kubernetesPercent": 0.75},
 		"amortizedNetCost": {"cost": 70.30, "kubernetesPercent": 0.75},
 		"invoicedCost": {"cost": 80.40, "kubernetesPercent": 0.75},
-		"amortizedCost": {"cost": 90.45, "kubernetesPercent": 0.75}
+		"amortizedCost": {"cost": 90.45, "kubernetesPercent": 0.75},
+		"usage": {"quantity": 24, "unit": "Hrs"}
 	}`
 
 	var item CloudCostItem
@@ -106,8 +164,15 @@ func TestCloudCostItemUnmarshal(t *testing.T) {
 	}
 
 	// Verify window
-	if item.Window.Start != "2026-01-01T00:00:00Z" {
-		t.Errorf("Window.Start = %v, want 2026-01-01T00:00:00Z", item.Window.Start)
+	if want := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC); !item.Window.Start.Equal(want) {
+		t.Errorf("Window.Start = %v, want %v", item.Window.Start, want)
+	}
+	if item.Window.Duration() != 24*time.Hour {
+		t.Errorf("Window.Duration() = %v, want 24h", item.Window.Duration())
+	}
+
+	if item.Usage == nil || *item.Usage != (Usage{Quantity: 24, Unit: "Hrs"}) {
+		t.Errorf("Usage = %+v, want 24 Hrs", item.Usage)
 	}
 }
 
@@ -197,3 +262,680 @@ func TestCloudCostResponseFromFixture(t *testing.T) {
 		t.Errorf("Total ListCost = %v, want %v", totalListCost, expectedTotal)
 	}
 }
+
+func TestCloudCostResponse_SchemaVariants(t *testing.T) {
+	decode := func(name string) map[string]CloudCostItem {
+		data, err := os.ReadFile("testdata/" + name)
+		if err != nil {
+			t.Fatalf("failed to read fixture: %v", err)
+		}
+		var resp CloudCostResponse
+		if err := json.Unmarshal(data, &resp); err != nil {
+			t.Fatalf("failed to unmarshal %s: %v", name, err)
+		}
+		return resp.Data.Sets[0].CloudCosts
+	}
+
+	// The legacy fixture uses the property names of earlier OpenCost
+	// releases and different casing.
+	want := decode("cloudcost-response.json")
+	got := decode("cloudcost-response-legacy.json")
+	for key, item := range want {
+		if !reflect.DeepEqual(got[key].Properties, item.Properties) {
+			t.Errorf("properties of %s = %+v, want %+v", key, got[key].Properties, item.Properties)
+		}
+	}
+}
+
+func TestCloudCostProperties_Aliases(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  CloudCostProperties
+	}{
+		{
+			name:  "aliases",
+			input: `{"workGroupID": "123", "billingID": "456", "region": "eu-west-1"}`,
+			want:  CloudCostProperties{AccountID: "123", InvoiceEntityID: "456", RegionID: "eu-west-1"},
+		},
+		{
+			name:  "current name wins",
+			input: `{"accountID": "123", "workGroupID": "old"}`,
+			want:  CloudCostProperties{AccountID: "123"},
+		},
+		{
+			name:  "empty current name",
+			input: `{"accountID": "", "workGroupID": "old"}`,
+		},
+		{
+			name:  "alias casing",
+			input: `{"WorkGroupId": "123"}`,
+			want:  CloudCostProperties{AccountID: "123"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got CloudCostProperties
+			if err := json.Unmarshal([]byte(tt.input), &got); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unmarshal() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAggregate(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{"empty", "", nil, false},
+		{"properties and labels", " accountID, service ,label:team", []string{"accountID", "service", "label:team"}, false},
+		{"unknown property", "service,cluster", nil, true},
+		{"label without name", "label:", nil, true},
+		{"duplicate", "service,service", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAggregate(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAggregate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseAggregate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseResource(t *testing.T) {
+	tests := []struct {
+		providerID string
+		want       Resource
+	}{
+		{"arn:aws:rds:us-east-1:123:db:orders", Resource{"db", "orders"}},
+		{"arn:aws:ec2:us-east-1:123:instance/i-0abc", Resource{"instance", "i-0abc"}},
+		{"arn:aws:logs:eu-central-1:123:log-group:/aws/rds/instance/orders/error", Resource{"log-group", "/aws/rds/instance/orders/error"}},
+		{"arn:aws:s3:::my-bucket", Resource{"s3", "my-bucket"}},
+		{"arn:aws:s3", Resource{}},
+		{"i-0abc123", Resource{"instance", "i-0abc123"}},
+		{"vol-0abc123", Resource{"volume", "vol-0abc123"}},
+		{"ip-10-0-0-1.ec2.internal", Resource{}},
+		{"//compute.googleapis.com/projects/p/zones/us-east1-b/instances/vm-1", Resource{"instances", "vm-1"}},
+		{"projects/p/datasets/sales", Resource{"datasets", "sales"}},
+		{"projects/p/zones", Resource{}},
+		{"/subscriptions/s/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/vm1", Resource{"microsoft.compute/virtualmachines", "vm1"}},
+		{"/subscriptions/s/resourceGroups/rg/providers/Microsoft.Sql/servers/db1/databases/orders", Resource{"microsoft.sql/servers/databases", "db1/orders"}},
+		{"/subscriptions/s/resourceGroups/rg/providers/Microsoft.Compute", Resource{}},
+		{"/subscriptions/s/resourceGroups/rg", Resource{}},
+		{"", Resource{}},
+	}
+	for _, tt := range tests {
+		if got := ParseResource(tt.providerID); got != tt.want {
+			t.Errorf("ParseResource(%q) = %+v, want %+v", tt.providerID, got, tt.want)
+		}
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		name      string
+		key       string
+		aggregate []string
+		want      CloudCostProperties
+		wantErr   bool
+	}{
+		{
+			name:      "service and category",
+			key:       "AmazonEC2/Compute",
+			aggregate: []string{"service", "category"},
+			want:      CloudCostProperties{Service: "AmazonEC2", Category: "Compute"},
+		},
+		{
+			name:      "label",
+			key:       "123/team-alpha",
+			aggregate: []string{"accountID", "label:owner"},
+			want:      CloudCostProperties{AccountID: "123", Labels: map[string]string{"owner": "team-alpha"}},
+		},
+		{
+			name:      "provider ID with slashes",
+			key:       "Azure//subscriptions/sub-1/resourceGroups/rg/vm-1/Compute",
+			aggregate: []string{"provider", "providerID", "category"},
+			want:      CloudCostProperties{Provider: "Azure", ProviderID: "/subscriptions/sub-1/resourceGroups/rg/vm-1", Category: "Compute"},
+		},
+		{
+			name:      "empty values",
+			key:       "/Compute",
+			aggregate: []string{"service", "category"},
+			want:      CloudCostProperties{Category: "Compute"},
+		},
+		{name: "too few parts", key: "AmazonEC2", aggregate: []string{"service", "category"}, wantErr: true},
+		{name: "too many parts", key: "a/b/c", aggregate: []string{"service", "category"}, wantErr: true},
+		{name: "unknown property", key: "a", aggregate: []string{"cluster"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseKey(tt.key, tt.aggregate)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseKey() = %+v, want %+v", got, tt.want)
+			}
+			if key := got.Key(tt.aggregate); key != tt.key {
+				t.Errorf("Key() = %q, want %q", key, tt.key)
+			}
+		})
+	}
+}
+
+func TestCloudCostProperties_CheckKey(t *testing.T) {
+	aggregate := []string{"accountID", "service"}
+	p := CloudCostProperties{AccountID: "123", Service: "AmazonEC2", Category: "Compute"}
+
+	if err := p.CheckKey("123/AmazonEC2", aggregate); err != nil {
+		t.Errorf("CheckKey() of a matching key error = %v", err)
+	}
+	if err := p.CheckKey("123/AmazonRDS", aggregate); err == nil {
+		t.Error("CheckKey() should report a service differing from the key")
+	}
+	if err := p.CheckKey("123", aggregate); err == nil {
+		t.Error("CheckKey() should reject a malformed key")
+	}
+}
+
+func TestCloudCostItem_CostByType(t *testing.T) {
+	item := CloudCostItem{
+		ListCost:         CostValue{Cost: 1},
+		NetCost:          CostValue{Cost: 2},
+		AmortizedNetCost: CostValue{Cost: 3},
+		InvoicedCost:     CostValue{Cost: 4},
+		AmortizedCost:    CostValue{Cost: 5},
+	}
+
+	for i, costType := range CostTypes {
+		got, ok := item.CostByType(costType)
+		if !ok || got.Cost != float64(i+1) {
+			t.Errorf("CostByType(%q) = %v, %v, want %v", costType, got.Cost, ok, i+1)
+		}
+	}
+	if _, ok := item.CostByType("bogus"); ok {
+		t.Error("CostByType() should reject unknown cost types")
+	}
+}
+
+func FuzzCloudCostResponseUnmarshal(f *testing.F) {
+	f.Add([]byte(`{"code": 200, "data": {"sets": [{"cloudCosts": {"a": {"properties": {"accountID": "1", "service": "AmazonEC2", "labels": {"owner": "x"}}, "listCost": {"cost": 1.5, "kubernetesPercent": 0.5}}}}]}}`))
+	f.Add([]byte(`{"code": 200, "data": {"sets": [{"cloudCosts": {}}]}}`))
+	f.Add([]byte(`{"data": {"sets": [{"cloudCosts": {"x": {"listCost": {"cost": -1e308}, "properties": {"labels": null}}}}]}}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var resp CloudCostResponse
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return
+		}
+		for _, set := range resp.Data.Sets {
+			for _, item := range set.CloudCosts {
+				for _, costType := range CostTypes {
+					if _, ok := item.CostByType(costType); !ok {
+						t.Fatalf("CostByType(%q) not ok", costType)
+					}
+				}
+			}
+		}
+
+		// Anything that decodes must survive a round trip unchanged.
+		out, err := json.Marshal(resp)
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		var again CloudCostResponse
+		if err := json.Unmarshal(out, &again); err != nil {
+			t.Fatalf("re-Unmarshal() error = %v", err)
+		}
+		if !reflect.DeepEqual(normalize(resp), normalize(again)) {
+			t.Fatalf("round trip changed the response:\n%+v\n%+v", resp, again)
+		}
+	})
+}
+
+// normalize maps empty label maps to nil, which omitempty drops on encoding.
+func normalize(resp CloudCostResponse) CloudCostResponse {
+	for _, set := range resp.Data.Sets {
+		for k, item := range set.CloudCosts {
+			if len(item.Properties.Labels) == 0 {
+				item.Properties.Labels = nil
+				set.CloudCosts[k] = item
+			}
+		}
+	}
+	return resp
+}
+
+func TestWindowJSON(t *testing.T) {
+	day := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		input       string
+		want        Window
+		wantInvalid bool
+	}{
+		{
+			name:  "bounded",
+			input: `{"start": "2026-01-01T00:00:00Z", "end": "2026-01-02T00:00:00Z"}`,
+			want:  Window{Start: day, End: day.Add(24 * time.Hour)},
+		},
+		{
+			name:        "open",
+			input:       `{"start": "2026-01-01T00:00:00Z", "end": null}`,
+			want:        Window{Start: day},
+			wantInvalid: true,
+		},
+		{
+			name:        "empty",
+			input:       `{"start": "", "end": ""}`,
+			wantInvalid: true,
+		},
+		{
+			name:        "reversed",
+			input:       `{"start": "2026-01-02T00:00:00Z", "end": "2026-01-01T00:00:00Z"}`,
+			want:        Window{Start: day.Add(24 * time.Hour), End: day},
+			wantInvalid: true,
+		},
+		{
+			name:        "malformed",
+			input:       `{"start": "yesterday", "end": "2026-01-01T00:00:00Z"}`,
+			want:        Window{End: day},
+			wantInvalid: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var w Window
+			if err := json.Unmarshal([]byte(tt.input), &w); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if !w.Start.Equal(tt.want.Start) || !w.End.Equal(tt.want.End) {
+				t.Errorf("Unmarshal() = %v, want %v", w, tt.want)
+			}
+			if err := w.Validate(); (err != nil) != tt.wantInvalid {
+				t.Errorf("Validate() error = %v, wantInvalid %v", err, tt.wantInvalid)
+			}
+
+			// Marshaling round-trips.
+			b, err := json.Marshal(w)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			var back Window
+			if err := json.Unmarshal(b, &back); err != nil || back != w {
+				t.Errorf("round trip of %s = %v, %v, want %v", b, back, err, w)
+			}
+		})
+	}
+}
+
+func TestCostValueUnmarshal(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		wantCost    float64
+		wantPercent float64
+		wantCoerced int
+	}{
+		{name: "numbers", input: `{"cost": 12.5, "kubernetesPercent": 0.4}`, wantCost: 12.5, wantPercent: 0.4},
+		{name: "absent", input: `{}`},
+		{name: "strings", input: `{"cost": "12.5", "kubernetesPercent": " 0.4 "}`, wantCost: 12.5, wantPercent: 0.4, wantCoerced: 2},
+		{name: "null fields", input: `{"cost": null, "kubernetesPercent": null}`, wantCoerced: 2},
+		{name: "unparsable", input: `{"cost": "n/a", "kubernetesPercent": true}`, wantCoerced: 2},
+		{name: "empty string", input: `{"cost": "", "kubernetesPercent": 1}`, wantPercent: 1, wantCoerced: 1},
+		{name: "null", input: `null`, wantCoerced: 1},
+		{name: "bare number", input: `7.25`, wantCost: 7.25, wantCoerced: 1},
+		{name: "bare string", input: `"7.25"`, wantCost: 7.25, wantCoerced: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v CostValue
+			if err := json.Unmarshal([]byte(tt.input), &v); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if v.Cost != tt.wantCost || v.KubernetesPercent != tt.wantPercent || v.coerced != tt.wantCoerced {
+				t.Errorf("Unmarshal() = %v/%v with %d coerced, want %v/%v with %d",
+					v.Cost, v.KubernetesPercent, v.coerced, tt.wantCost, tt.wantPercent, tt.wantCoerced)
+			}
+		})
+	}
+}
+
+func TestCloudCostResponse_Coercions(t *testing.T) {
+	// One item with flaky fields must not cost the others.
+	input := `{"code": 200, "data": {"sets": [{"cloudCosts": {
+		"good": {"listCost": {"cost": 1}, "netCost": {"cost": 1}},
+		"flaky": {
+			"listCost": {"cost": "2.5"},
+			"netCost": null,
+			"amortizedCost": {"cost": null, "kubernetesPercent": 0},
+			"usage": {"quantity": "24", "unit": "Hrs"}
+		}
+	}}]}}`
+	var resp CloudCostResponse
+	if err := json.Unmarshal([]byte(input), &resp); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	costs := resp.Data.Sets[0].CloudCosts
+	if len(costs) != 2 || costs["flaky"].ListCost.Cost != 2.5 || costs["flaky"].Usage.Quantity != 24 {
+		t.Errorf("Unmarshal() = %+v", costs)
+	}
+	if n := resp.Coercions(); n != 4 {
+		t.Errorf("Coercions() = %d, want 4", n)
+	}
+}
+
+func TestCloudCostResponse_Validate(t *testing.T) {
+	day := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	response := func(item CloudCostItem) *CloudCostResponse {
+		return &CloudCostResponse{Code: 200, Data: CloudCostData{Sets: []CloudCostSet{{
+			CloudCosts: map[string]CloudCostItem{"item-1": item},
+		}}}}
+	}
+	tests := []struct {
+		name    string
+		resp    *CloudCostResponse
+		wantErr bool
+	}{
+		{name: "valid", resp: response(CloudCostItem{ListCost: CostValue{Cost: 1}, Window: Window{Start: day, End: day.AddDate(0, 0, 1)}})},
+		{name: "empty", resp: &CloudCostResponse{Code: 200, Data: CloudCostData{Sets: []CloudCostSet{}}}},
+		{name: "open window", resp: response(CloudCostItem{Window: Window{Start: day}})},
+		{name: "error code", resp: &CloudCostResponse{Code: 500, Data: CloudCostData{Sets: []CloudCostSet{}}}, wantErr: true},
+		{name: "no sets", resp: &CloudCostResponse{Code: 200}, wantErr: true},
+		{name: "NaN cost", resp: response(CloudCostItem{NetCost: CostValue{Cost: math.NaN()}}), wantErr: true},
+		{name: "infinite percent", resp: response(CloudCostItem{AmortizedCost: CostValue{KubernetesPercent: math.Inf(1)}}), wantErr: true},
+		{name: "infinite usage", resp: response(CloudCostItem{Usage: &Usage{Quantity: math.Inf(-1)}}), wantErr: true},
+		{name: "reversed window", resp: response(CloudCostItem{Window: Window{Start: day, End: day.AddDate(0, 0, -1)}}), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.resp.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidResponse) {
+				t.Errorf("Validate() error = %v, want ErrInvalidResponse", err)
+			}
+		})
+	}
+}
+
+func TestCloudCostSet_SkipsMalformedItems(t *testing.T) {
+	input := `{"code": 200, "data": {"sets": [
+		{"window": {}, "CloudCosts": {
+			"good": {"properties": {"service": "AmazonEC2"}, "listCost": {"cost": 1}},
+			"bad-properties": {"properties": "AmazonS3"},
+			"bad-labels": {"properties": {"labels": ["owner"]}},
+			"nan": {"listCost": {"cost": "NaN"}}
+		}},
+		{"cloudCosts": null},
+		null
+	]}}`
+	var resp CloudCostResponse
+	if err := json.Unmarshal([]byte(input), &resp); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if n := resp.DropInvalid(); n != 1 {
+		t.Errorf("DropInvalid() = %d, want 1", n)
+	}
+	if err := resp.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	if got := resp.Data.Sets[0].CloudCosts; len(got) != 1 || got["good"].Properties.Service != "AmazonEC2" {
+		t.Errorf("CloudCosts = %+v, want only the good item", got)
+	}
+	if n := len(resp.Skipped()); n != 3 || !resp.Partial() {
+		t.Errorf("Skipped() = %v, want 3 items", resp.Skipped())
+	}
+	if len(resp.Data.Sets) != 3 || resp.Data.Sets[1].CloudCosts != nil {
+		t.Errorf("Sets = %+v", resp.Data.Sets)
+	}
+
+	// Syntax errors still fail the whole response.
+	if err := json.Unmarshal([]byte(`{"data": {"sets": [{"cloudCosts": {"a": {]}}`), &resp); err == nil {
+		t.Error("Unmarshal() of invalid JSON should fail")
+	}
+}
+
+func TestCloudCostData_Unmarshal(t *testing.T) {
+	// Sets are decoded concurrently but keep their order.
+	var b strings.Builder
+	b.WriteString(`{"sets": [`)
+	for i := range 50 {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, `{"cloudCosts": {"item": {"listCost": {"cost": %d}}}}`, i)
+	}
+	b.WriteString(`]}`)
+	var data CloudCostData
+	if err := json.Unmarshal([]byte(b.String()), &data); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if len(data.Sets) != 50 {
+		t.Fatalf("len(Sets) = %d, want 50", len(data.Sets))
+	}
+	for i, set := range data.Sets {
+		if got := set.CloudCosts["item"].ListCost.Cost; got != float64(i) {
+			t.Errorf("Sets[%d] cost = %v, want %d", i, got, i)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		wantNil bool
+		wantErr string
+	}{
+		{name: "no sets", input: `{}`, wantNil: true},
+		{name: "null sets", input: `{"sets": null}`, wantNil: true},
+		{name: "empty sets", input: `{"sets": []}`},
+		{name: "invalid set", input: `{"sets": [{}, "set"]}`, wantErr: "sets[1]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var data CloudCostData
+			err := json.Unmarshal([]byte(tt.input), &data)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Unmarshal() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if (data.Sets == nil) != tt.wantNil {
+				t.Errorf("Sets = %#v, want nil: %v", data.Sets, tt.wantNil)
+			}
+		})
+	}
+}
+
+func TestCloudCostResponse_DropOverlapping(t *testing.T) {
+	day := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	days := func(from, to int) Window {
+		return Window{Start: day.AddDate(0, 0, from), End: day.AddDate(0, 0, to)}
+	}
+	itemIn := func(w Window) map[string]CloudCostItem {
+		return map[string]CloudCostItem{"item": {Window: w}}
+	}
+	tests := []struct {
+		name string
+		sets []CloudCostSet
+		want []int // indexes of the kept sets
+	}{
+		{"adjacent", []CloudCostSet{{Window: days(0, 1)}, {Window: days(1, 2)}}, []int{0, 1}},
+		{"duplicate", []CloudCostSet{{Window: days(0, 1)}, {Window: days(0, 1)}}, []int{1}},
+		{"re-accumulated", []CloudCostSet{{Window: days(0, 1)}, {Window: days(1, 2)}, {Window: days(0, 7)}}, []int{2}},
+		{"partial overlap", []CloudCostSet{{Window: days(0, 2)}, {Window: days(1, 3)}, {Window: days(3, 4)}}, []int{1, 2}},
+		{"window from items", []CloudCostSet{{CloudCosts: itemIn(days(0, 1))}, {Window: days(0, 1)}}, []int{1}},
+		{"unknown window", []CloudCostSet{{}, {Window: days(0, 1)}, {}}, []int{0, 1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &CloudCostResponse{Data: CloudCostData{Sets: slices.Clone(tt.sets)}}
+			if n := r.DropOverlapping(); n != len(tt.sets)-len(tt.want) {
+				t.Errorf("DropOverlapping() = %d, want %d", n, len(tt.sets)-len(tt.want))
+			}
+			var want []CloudCostSet
+			for _, i := range tt.want {
+				want = append(want, tt.sets[i])
+			}
+			if !reflect.DeepEqual(r.Data.Sets, want) {
+				t.Errorf("sets = %+v, want %+v", r.Data.Sets, want)
+			}
+		})
+	}
+}
+
+func TestCloudCostResponse_Bounds(t *testing.T) {
+	day := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := &CloudCostResponse{Data: CloudCostData{Sets: []CloudCostSet{
+		{Window: Window{Start: day.AddDate(0, 0, 1), End: day.AddDate(0, 0, 2)}},
+		{},
+		{Window: Window{Start: day, End: day.AddDate(0, 0, 1)}},
+	}}}
+	if got, want := r.Bounds(), (Window{Start: day, End: day.AddDate(0, 0, 2)}); got != want {
+		t.Errorf("Bounds() = %+v, want %+v", got, want)
+	}
+	if got := (&CloudCostResponse{}).Bounds(); got != (Window{}) {
+		t.Errorf("Bounds() of no sets = %+v, want zero", got)
+	}
+}
+
+func TestCloudCostSet_Window(t *testing.T) {
+	var resp CloudCostResponse
+	input := `{"code": 200, "data": {"sets": [
+		{"window": {"start": "2026-01-01T00:00:00Z", "end": "2026-01-02T00:00:00Z"}, "cloudCosts": {}},
+		{"window": "yesterday", "cloudCosts": {}}
+	]}}`
+	if err := json.Unmarshal([]byte(input), &resp); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if w := resp.Data.Sets[0].Window; w.Duration() != 24*time.Hour {
+		t.Errorf("Window = %+v, want one day", w)
+	}
+	if w := resp.Data.Sets[1].Window; !w.Start.IsZero() || !w.End.IsZero() {
+		t.Errorf("malformed Window = %+v, want zero", w)
+	}
+}
+
+func TestUsage_Add(t *testing.T) {
+	hrs := &Usage{Quantity: 24, Unit: "Hrs"}
+	tests := []struct {
+		name   string
+		a, b   *Usage
+		want   *Usage
+		wantOK bool
+	}{
+		{name: "same unit", a: hrs, b: &Usage{Quantity: 6, Unit: "Hrs"}, want: &Usage{Quantity: 30, Unit: "Hrs"}, wantOK: true},
+		{name: "nil", a: nil, b: hrs, want: hrs, wantOK: true},
+		{name: "nil other", a: hrs, b: nil, want: hrs, wantOK: true},
+		{name: "different units", a: hrs, b: &Usage{Quantity: 1, Unit: "GB-Mo"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.a.Add(tt.b)
+			if ok != tt.wantOK || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Add() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestCloudCostProperties_Azure(t *testing.T) {
+	tests := []struct {
+		name   string
+		props  CloudCostProperties
+		want   AzureProperties
+		wantOK bool
+	}{
+		{
+			name: "virtual machine",
+			props: CloudCostProperties{
+				Provider:    "azure",
+				AccountID:   "0b1f6471-1bf0-4dda-aec3-111122223333",
+				AccountName: "prod",
+				ProviderID:  "/subscriptions/0b1f6471-1bf0-4dda-aec3-111122223333/resourceGroups/Web-RG/providers/Microsoft.Compute/virtualMachines/web-1",
+			},
+			want:   AzureProperties{SubscriptionID: "0b1f6471-1bf0-4dda-aec3-111122223333", SubscriptionName: "prod", ResourceGroup: "web-rg"},
+			wantOK: true,
+		},
+		{
+			name:   "no resource ID",
+			props:  CloudCostProperties{Provider: ProviderAzure, AccountID: "sub", ProviderID: "vm-1"},
+			want:   AzureProperties{SubscriptionID: "sub"},
+			wantOK: true,
+		},
+		{name: "other provider", props: CloudCostProperties{Provider: ProviderAWS, AccountID: "123"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.props.Azure()
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("Azure() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestCloudCostProperties_GCP(t *testing.T) {
+	props := CloudCostProperties{
+		Provider:          ProviderGCP,
+		AccountID:         "acme-prod",
+		AccountName:       "Acme Production",
+		InvoiceEntityID:   "01A2B3-C4D5E6-F7G8H9",
+		InvoiceEntityName: "Acme Billing",
+	}
+	want := GCPProperties{ProjectID: "acme-prod", ProjectName: "Acme Production", BillingAccountID: "01A2B3-C4D5E6-F7G8H9", BillingAccountName: "Acme Billing"}
+	if got, ok := props.GCP(); !ok || got != want {
+		t.Errorf("GCP() = %+v, %v, want %+v, true", got, ok, want)
+	}
+	if _, ok := (CloudCostProperties{Provider: ProviderAzure}).GCP(); ok {
+		t.Error("GCP() of Azure properties should report false")
+	}
+}
+
+func TestCloudCostProperties_Label(t *testing.T) {
+	mixed := CloudCostProperties{
+		Labels:           map[string]string{"team": "web", "app": "shop", "cost-center": "42"},
+		KubernetesLabels: map[string]string{"app": "shop", "tier": "frontend"},
+	}
+	tests := []struct {
+		source LabelSource
+		key    string
+		want   string
+	}{
+		{AnyLabel, "app", "shop"},
+		{AnyLabel, "tier", "frontend"},
+		{ProviderTag, "team", "web"},
+		{ProviderTag, "app", ""},
+		{KubernetesLabel, "app", "shop"},
+		{KubernetesLabel, "team", ""},
+	}
+	for _, tt := range tests {
+		if got := mixed.Label(tt.source, tt.key); got != tt.want {
+			t.Errorf("Label(%v, %q) = %q, want %q", tt.source, tt.key, got, tt.want)
+		}
+	}
+
+	// Without Kubernetes labels, all labels count as tags.
+	tagsOnly := CloudCostProperties{Labels: map[string]string{"app": "shop"}}
+	if got := tagsOnly.Label(ProviderTag, "app"); got != "shop" {
+		t.Errorf("Label(ProviderTag, app) = %q, want shop", got)
+	}
+}
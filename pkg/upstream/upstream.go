@@ -0,0 +1,187 @@
+// Package upstream re-exposes an allowlisted subset of OpenCost's own
+// Prometheus metrics, so a single scrape target covers both cost data and
+// the health of its source.
+package upstream
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+// DefaultAllowlist selects OpenCost's build info and error counters.
+var DefaultAllowlist = []string{"opencost_build_info", ".*_errors?_total"}
+
+// Collector scrapes an upstream /metrics endpoint on every Collect and
+// forwards the families whose name matches the allowlist.
+type Collector struct {
+	url        string
+	allow      *regexp.Regexp
+	httpClient *http.Client
+	token      func() (string, error)
+
+	up *prometheus.Desc
+}
+
+// Option is a functional option for configuring the Collector.
+type Option func(*Collector)
+
+// WithTimeout sets the upstream scrape timeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Collector) {
+		c.httpClient.Timeout = timeout
+	}
+}
+
+// WithBearerToken authenticates scrapes with the token returned by token,
+// which is called for every scrape so that rotated tokens are used.
+func WithBearerToken(token func() (string, error)) Option {
+	return func(c *Collector) {
+		c.token = token
+	}
+}
+
+// New creates a collector for the metrics endpoint at url. Each allowlist
+// entry is a regular expression that must match a whole metric name.
+func New(url string, allowlist []string, opts ...Option) (*Collector, error) {
+	if len(allowlist) == 0 {
+		allowlist = DefaultAllowlist
+	}
+	allow, err := regexp.Compile("^(?:" + strings.Join(allowlist, "|") + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid metric allowlist: %w", err)
+	}
+
+	c := &Collector{
+		url:        url,
+		allow:      allow,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+		up: prometheus.NewDesc(
+			"cloudcost_exporter_upstream_up",
+			"Whether the last scrape of OpenCost's own metrics succeeded (1) or failed (0)",
+			nil, nil,
+		),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// Describe implements prometheus.Collector. It describes nothing, making
+// this an unchecked collector: forwarded metrics are only known after
+// scraping.
+func (c *Collector) Describe(chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	families, err := c.scrape(context.Background())
+	if err != nil {
+		slog.Error("failed to scrape OpenCost metrics", "url", c.url, "error", err)
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 0)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 1)
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		if c.allow.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, m := range convert(families[name]) {
+			ch <- m
+		}
+	}
+}
+
+func (c *Collector) scrape(ctx context.Context) (map[string]*dto.MetricFamily, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Accept", string(expfmt.NewFormat(expfmt.TypeTextPlain)))
+	if c.token != nil {
+		token, err := c.token()
+		if err != nil {
+			return nil, fmt.Errorf("read OpenCost token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	parser := expfmt.NewTextParser(model.UTF8Validation)
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("parse metrics: %w", err)
+	}
+	return families, nil
+}
+
+// convert turns a parsed family into const metrics. Samples that cannot be
+// represented are skipped.
+func convert(mf *dto.MetricFamily) []prometheus.Metric {
+	var out []prometheus.Metric
+	for _, m := range mf.GetMetric() {
+		names := make([]string, 0, len(m.GetLabel()))
+		values := make([]string, 0, len(m.GetLabel()))
+		for _, l := range m.GetLabel() {
+			names = append(names, l.GetName())
+			values = append(values, l.GetValue())
+		}
+		desc := prometheus.NewDesc(mf.GetName(), mf.GetHelp(), names, nil)
+
+		var metric prometheus.Metric
+		var err error
+		switch mf.GetType() {
+		case dto.MetricType_COUNTER:
+			metric, err = prometheus.NewConstMetric(desc, prometheus.CounterValue, m.GetCounter().GetValue(), values...)
+		case dto.MetricType_GAUGE:
+			metric, err = prometheus.NewConstMetric(desc, prometheus.GaugeValue, m.GetGauge().GetValue(), values...)
+		case dto.MetricType_UNTYPED:
+			metric, err = prometheus.NewConstMetric(desc, prometheus.UntypedValue, m.GetUntyped().GetValue(), values...)
+		case dto.MetricType_HISTOGRAM:
+			h := m.GetHistogram()
+			buckets := make(map[float64]uint64, len(h.GetBucket()))
+			for _, b := range h.GetBucket() {
+				buckets[b.GetUpperBound()] = b.GetCumulativeCount()
+			}
+			metric, err = prometheus.NewConstHistogram(desc, h.GetSampleCount(), h.GetSampleSum(), buckets, values...)
+		case dto.MetricType_SUMMARY:
+			s := m.GetSummary()
+			quantiles := make(map[float64]float64, len(s.GetQuantile()))
+			for _, q := range s.GetQuantile() {
+				quantiles[q.GetQuantile()] = q.GetValue()
+			}
+			metric, err = prometheus.NewConstSummary(desc, s.GetSampleCount(), s.GetSampleSum(), quantiles, values...)
+		default:
+			continue
+		}
+		if err != nil {
+			slog.Debug("skipping upstream sample", "metric", mf.GetName(), "error", err)
+			continue
+		}
+		out = append(out, metric)
+	}
+	return out
+}
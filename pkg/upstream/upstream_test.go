@@ -0,0 +1,141 @@
+package upstream
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+const upstreamMetrics = `# HELP opencost_build_info OpenCost build information
+# TYPE opencost_build_info gauge
+opencost_build_info{version="1.110.0"} 1
+# HELP opencost_pipeline_errors_total Pipeline errors
+# TYPE opencost_pipeline_errors_total counter
+opencost_pipeline_errors_total{pipeline="cloudcost"} 3
+# HELP node_total_hourly_cost Node cost
+# TYPE node_total_hourly_cost gauge
+node_total_hourly_cost{node="a"} 0.5
+# HELP opencost_request_duration_seconds Request latency
+# TYPE opencost_request_duration_seconds histogram
+opencost_request_duration_seconds_bucket{le="1"} 2
+opencost_request_duration_seconds_bucket{le="+Inf"} 3
+opencost_request_duration_seconds_sum 4.5
+opencost_request_duration_seconds_count 3
+`
+
+func newUpstream(t *testing.T, status int) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(upstreamMetrics))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL + "/metrics"
+}
+
+func TestCollector_Allowlist(t *testing.T) {
+	tests := []struct {
+		name      string
+		allowlist []string
+		want      []string
+		notWant   []string
+	}{
+		{
+			name:    "default",
+			want:    []string{"opencost_build_info", "opencost_pipeline_errors_total"},
+			notWant: []string{"node_total_hourly_cost", "opencost_request_duration_seconds"},
+		},
+		{
+			name:      "custom",
+			allowlist: []string{"opencost_request_.*"},
+			want:      []string{"opencost_request_duration_seconds"},
+			notWant:   []string{"opencost_build_info"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New(newUpstream(t, http.StatusOK), tt.allowlist)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			reg := prometheus.NewPedanticRegistry()
+			reg.MustRegister(c)
+
+			var out strings.Builder
+			families, err := reg.Gather()
+			if err != nil {
+				t.Fatalf("Gather() error = %v", err)
+			}
+			for _, mf := range families {
+				out.WriteString(mf.String())
+			}
+			for _, name := range append(tt.want, "cloudcost_exporter_upstream_up") {
+				if !strings.Contains(out.String(), name) {
+					t.Errorf("missing %s", name)
+				}
+			}
+			for _, name := range tt.notWant {
+				if strings.Contains(out.String(), `"`+name+`"`) {
+					t.Errorf("%s should not be forwarded", name)
+				}
+			}
+		})
+	}
+}
+
+func TestCollector_Values(t *testing.T) {
+	c, _ := New(newUpstream(t, http.StatusOK), nil)
+
+	want := `
+# HELP opencost_pipeline_errors_total Pipeline errors
+# TYPE opencost_pipeline_errors_total counter
+opencost_pipeline_errors_total{pipeline="cloudcost"} 3
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want), "opencost_pipeline_errors_total"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCollector_UpstreamDown(t *testing.T) {
+	c, _ := New(newUpstream(t, http.StatusInternalServerError), nil)
+
+	want := `
+# HELP cloudcost_exporter_upstream_up Whether the last scrape of OpenCost's own metrics succeeded (1) or failed (0)
+# TYPE cloudcost_exporter_upstream_up gauge
+cloudcost_exporter_upstream_up 0
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCollector_WithBearerToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(upstreamMetrics))
+	}))
+	defer server.Close()
+
+	c, _ := New(server.URL+"/metrics", nil, WithBearerToken(func() (string, error) { return "secret", nil }))
+	want := `
+# HELP cloudcost_exporter_upstream_up Whether the last scrape of OpenCost's own metrics succeeded (1) or failed (0)
+# TYPE cloudcost_exporter_upstream_up gauge
+cloudcost_exporter_upstream_up 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want), "cloudcost_exporter_upstream_up"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNew_InvalidAllowlist(t *testing.T) {
+	if _, err := New("http://localhost", []string{"("}); err == nil {
+		t.Error("New() should reject invalid regular expressions")
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/alert"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/cache"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/client"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/collector"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/configwatch"
+	"github.com/hawky-4s-/opencost-cloudcost-exporter/pkg/export"
+)
+
+// alertFlags are the flags that configure the alert engine, which is
+// rebuilt when any of them changes.
+var alertFlags = []string{
+	"alert-rules", "alert-webhook-url", "alert-message-template",
+	"budgets", "budget-levels", "opsgenie-url",
+	"slack-webhook-url", "slack-routes", "teams-webhook-url", "teams-routes",
+}
+
+// exportFlags are the flags that configure the Parquet exporter, which is
+// rebuilt when any of them changes.
+var exportFlags = []string{"export-url", "export-endpoint", "export-region"}
+
+// reloadPath is where a POST or PUT request reloads the configuration.
+const reloadPath = "/-/reload"
+
+// liveFlags are applied in place without rebuilding anything.
+var liveFlags = []string{"log-level", "cache-ttl", "max-stale", "window", "label-mappings", "currency-symbols"}
+
+// reloader applies configuration changes without a restart: changes to
+// --config-dir and --config on SIGHUP or a request to reloadPath, changes
+// to --config-dir when the directory changes, and flag values from a
+// CloudCostExporterConfig resource via apply. The flags in
+// liveFlags, alertFlags and exportFlags are applied live; other changes
+// are logged and take effect on restart.
+type reloader struct {
+	args     []string
+	dir      string
+	file     string
+	values   map[string]string // flag values currently in effect
+	client   *client.Client
+	coll     *collector.CloudCostCollector
+	cache    *cache.Cache
+	alerts   *atomic.Pointer[alert.Engine]
+	exporter *atomic.Pointer[export.Exporter]
+	hash     *configHash
+
+	mu        sync.Mutex
+	overrides map[string]string // flag values from the resource
+
+	successful  prometheus.Gauge
+	successTime prometheus.Gauge
+}
+
+// init creates the reload metrics and returns r for registration.
+func (r *reloader) init() *reloader {
+	r.successful = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "cloudcost_exporter",
+		Name:      "config_last_reload_successful",
+		Help:      "Whether the last configuration reload succeeded",
+	})
+	r.successTime = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "cloudcost_exporter",
+		Name:      "config_last_reload_success_timestamp_seconds",
+		Help:      "Unix timestamp of the last successful configuration reload",
+	})
+	r.successful.Set(1)
+	r.successTime.SetToCurrentTime()
+	return r
+}
+
+// Describe implements prometheus.Collector.
+func (r *reloader) Describe(ch chan<- *prometheus.Desc) {
+	r.successful.Describe(ch)
+	r.successTime.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (r *reloader) Collect(ch chan<- prometheus.Metric) {
+	r.successful.Collect(ch)
+	r.successTime.Collect(ch)
+}
+
+// run reloads on SIGHUP and on changes to the directory until ctx is
+// canceled.
+func (r *reloader) run(ctx context.Context) {
+	trigger := make(chan struct{}, 1)
+	if r.dir != "" {
+		w := configwatch.New(r.dir, func() {
+			select {
+			case trigger <- struct{}{}:
+			default:
+			}
+		})
+		go func() {
+			if err := w.Run(ctx); err != nil {
+				slog.Error("failed to watch config directory; reload with SIGHUP", "error", err)
+			}
+		}()
+	}
+
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sighup:
+			slog.Info("received SIGHUP, reloading configuration")
+		case <-trigger:
+			slog.Info("config directory changed, reloading configuration")
+		}
+		r.mu.Lock()
+		r.reload()
+		r.mu.Unlock()
+	}
+}
+
+// ServeHTTP reloads the configuration on a POST or PUT request, like
+// SIGHUP. An invalid configuration is answered with 500 and the error.
+func (r *reloader) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost && req.Method != http.MethodPut {
+		w.Header().Set("Allow", "POST, PUT")
+		http.Error(w, "method not allowed, use POST or PUT", http.StatusMethodNotAllowed)
+		return
+	}
+	slog.Info("reload requested over HTTP, reloading configuration")
+	r.mu.Lock()
+	err := r.reload()
+	r.mu.Unlock()
+	if err != nil {
+		http.Error(w, "failed to reload configuration: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintln(w, "configuration reloaded")
+}
+
+// apply replaces the flag values from the CloudCostExporterConfig resource
+// and reloads. It implements operator.ApplyFunc. If the result is invalid,
+// the previous values are kept.
+func (r *reloader) apply(overrides map[string]string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous := r.overrides
+	r.overrides = overrides
+	if err := r.reload(); err != nil {
+		r.overrides = previous
+		return err
+	}
+	return nil
+}
+
+// reload re-reads the configuration and applies what changed. An invalid
+// configuration is rejected as a whole and the running one is kept. The
+// caller must hold r.mu.
+func (r *reloader) reload() error {
+	next, values, err := loadConfig(r.dir, r.file, r.overrides, r.args)
+	if err != nil {
+		return r.failed(err)
+	}
+
+	var changed, restart []string
+	for _, name := range slices.Sorted(maps.Keys(values)) {
+		if values[name] != r.values[name] {
+			changed = append(changed, name)
+		}
+	}
+	var alertsChanged, exportChanged bool
+	for _, name := range changed {
+		switch {
+		case slices.Contains(liveFlags, name):
+		case slices.Contains(alertFlags, name):
+			alertsChanged = true
+		case slices.Contains(exportFlags, name):
+			exportChanged = true
+		default:
+			restart = append(restart, name)
+		}
+	}
+
+	// Validate everything before applying anything.
+	if _, err := client.ResolveWindow(next.window, time.Now()); err != nil {
+		return r.failed(err)
+	}
+	mappings, err := collector.ParseLabelMappings(next.labelMappings)
+	if err != nil {
+		return r.failed(err)
+	}
+	symbols, err := next.symbols()
+	if err != nil {
+		return r.failed(err)
+	}
+	var engine *alert.Engine
+	if alertsChanged {
+		if engine, err = next.alertEngine(); err != nil {
+			return r.failed(err)
+		}
+	}
+	var exp *export.Exporter
+	if exportChanged {
+		if exp, err = next.newExporter(); err != nil {
+			return r.failed(err)
+		}
+	}
+
+	logLevel.Set(parseLevel(next.logLevel))
+	r.cache.SetTTL(next.cacheTTL, next.maxStale)
+	r.coll.SetLabelMappings(mappings)
+	r.coll.SetCurrencySymbols(symbols)
+	if next.window != r.client.Window() {
+		// Cached data answers the old window.
+		r.client.SetWindow(next.window)
+		r.cache.Invalidate()
+	}
+	if alertsChanged {
+		r.alerts.Store(engine)
+	}
+	if exportChanged {
+		r.exporter.Store(exp)
+	}
+	for _, name := range changed {
+		if !slices.Contains(restart, name) {
+			r.values[name] = values[name]
+		}
+	}
+
+	r.hash.set(r.values)
+	r.successful.Set(1)
+	r.successTime.SetToCurrentTime()
+	if len(restart) > 0 {
+		slog.Warn("configuration changes take effect after a restart", "flags", restart)
+	}
+	slog.Info("configuration reloaded", "changed", len(changed)-len(restart))
+	return nil
+}
+
+func (r *reloader) failed(err error) error {
+	r.successful.Set(0)
+	slog.Error("failed to reload configuration, keeping the current one", "error", err)
+	return err
+}
+
+// watchOwnership reloads the ownership file of coll when it changes or on
+// SIGHUP until ctx is canceled, keeping the previous owners if the file
+// cannot be read. Like --config-dir, the directory of the file is watched,
+// so that updates of a mounted ConfigMap are seen.
+func watchOwnership(ctx context.Context, path string, coll *collector.CloudCostCollector) {
+	trigger := make(chan struct{}, 1)
+	w := configwatch.New(filepath.Dir(path), func() {
+		select {
+		case trigger <- struct{}{}:
+		default:
+		}
+	})
+	go func() {
+		if err := w.Run(ctx); err != nil {
+			slog.Error("failed to watch the ownership file; reload with SIGHUP", "error", err)
+		}
+	}()
+
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sighup:
+		case <-trigger:
+		}
+		ownership, err := collector.LoadOwnership(path)
+		if err != nil {
+			slog.Error("failed to reload the ownership file, keeping the current owners", "error", err)
+			continue
+		}
+		coll.SetOwnership(ownership)
+		slog.Info("reloaded the ownership file", "accounts", len(ownership))
+	}
+}